@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"net/mail"
 	"os"
 	"os/signal"
 	"runtime"
@@ -16,14 +17,41 @@ import (
 	"github.com/ardanlabs/conf/v3"
 	"github.com/gin-gonic/gin"
 	"github.com/hamidoujand/jumble/internal/auth"
+	"github.com/hamidoujand/jumble/internal/authz"
 	"github.com/hamidoujand/jumble/internal/debug"
+	auditBus "github.com/hamidoujand/jumble/internal/domains/audit/bus"
+	"github.com/hamidoujand/jumble/internal/domains/audit/store/auditdb"
+	departmentBus "github.com/hamidoujand/jumble/internal/domains/department/bus"
+	departmentHandlers "github.com/hamidoujand/jumble/internal/domains/department/handler"
+	"github.com/hamidoujand/jumble/internal/domains/department/store/departmentdb"
+	groupBus "github.com/hamidoujand/jumble/internal/domains/group/bus"
+	groupHandlers "github.com/hamidoujand/jumble/internal/domains/group/handler"
+	"github.com/hamidoujand/jumble/internal/domains/group/store/groupdb"
 	healthHandlers "github.com/hamidoujand/jumble/internal/domains/health/handler"
+	oauthBus "github.com/hamidoujand/jumble/internal/domains/oauth/bus"
+	oauthHandlers "github.com/hamidoujand/jumble/internal/domains/oauth/handler"
+	"github.com/hamidoujand/jumble/internal/domains/oauth/store/oauthdb"
+	orgBus "github.com/hamidoujand/jumble/internal/domains/org/bus"
+	orgHandlers "github.com/hamidoujand/jumble/internal/domains/org/handler"
+	"github.com/hamidoujand/jumble/internal/domains/org/store/orgdb"
+	roleBus "github.com/hamidoujand/jumble/internal/domains/role/bus"
+	roleHandlers "github.com/hamidoujand/jumble/internal/domains/role/handler"
+	"github.com/hamidoujand/jumble/internal/domains/role/store/roledb"
+	scimHandlers "github.com/hamidoujand/jumble/internal/domains/scim/handler"
+	serviceAccountBus "github.com/hamidoujand/jumble/internal/domains/serviceaccount/bus"
+	serviceAccountHandlers "github.com/hamidoujand/jumble/internal/domains/serviceaccount/handler"
+	"github.com/hamidoujand/jumble/internal/domains/serviceaccount/store/serviceaccountdb"
 	"github.com/hamidoujand/jumble/internal/domains/user/bus"
 	userHandlers "github.com/hamidoujand/jumble/internal/domains/user/handler"
 	"github.com/hamidoujand/jumble/internal/domains/user/store/userdb"
+	"github.com/hamidoujand/jumble/internal/mailer"
 	"github.com/hamidoujand/jumble/internal/metrics"
 	"github.com/hamidoujand/jumble/internal/mid"
+	"github.com/hamidoujand/jumble/internal/oidc"
+	"github.com/hamidoujand/jumble/internal/password"
+	"github.com/hamidoujand/jumble/internal/scheduler"
 	"github.com/hamidoujand/jumble/internal/sqldb"
+	"github.com/hamidoujand/jumble/internal/webauthn"
 	"github.com/hamidoujand/jumble/pkg/keystore"
 	"github.com/hamidoujand/jumble/pkg/logger"
 	"github.com/hamidoujand/jumble/pkg/telemetry"
@@ -65,6 +93,9 @@ func run(ctx context.Context, log *logger.Logger) error {
 			DebugHost      string        `conf:"default:0.0.0.0:3000"`
 			APIHost        string        `conf:"default:0.0.0.0:8000"`
 			HealthCheck    string        `conf:"default:0.0.0.0:9000"`
+
+			//TrustedProxies are allowed to set X-Forwarded-For, client ips feed the login throttling.
+			TrustedProxies []string
 		}
 
 		DB struct {
@@ -79,10 +110,114 @@ func run(ctx context.Context, log *logger.Logger) error {
 		}
 
 		Auth struct {
-			Keys        string        `conf:"default:/etc/rsa-keys"`
-			ActiveKey   string        `conf:"default:f7b7936a-1ca3-4015-811b-ec31b61e3071"`
-			Issuer      string        `conf:"default:jumple project"`
-			TokenMaxAge time.Duration `conf:"default:1h"`
+			Keys          string        `conf:"default:/etc/rsa-keys"`
+			ActiveKey     string        `conf:"default:f7b7936a-1ca3-4015-811b-ec31b61e3071"`
+			Issuer        string        `conf:"default:jumple project"`
+			Audience      string        `conf:"default:jumble"`
+			Leeway        time.Duration `conf:"default:30s"`
+			TokenMaxAge   time.Duration `conf:"default:1h"`
+			ResetTokenTTL time.Duration `conf:"default:30m"`
+
+			VerifyTokenTTL       time.Duration `conf:"default:24h"`
+			VerifyResendInterval time.Duration `conf:"default:1m"`
+			RequireVerifiedEmail bool          `conf:"default:false"`
+
+			InviteTTL time.Duration `conf:"default:72h"`
+			//DisableSignup leaves invitations as the only way to create an account.
+			DisableSignup bool `conf:"default:false"`
+
+			IntrospectionCacheTTL time.Duration `conf:"default:30s"`
+			ImpersonationTTL      time.Duration `conf:"default:15m"`
+
+			//KeyMaxAge is how long the active key may be used before the scheduler reports it
+			//has to be rotated, zero turns the check off.
+			KeyMaxAge time.Duration `conf:"default:2160h"`
+		}
+
+		Cookie struct {
+			//Enabled lets browser clients keep the token in an HttpOnly cookie instead of storage
+			//scripts can read, logins then set it and requests may authenticate with it.
+			Enabled  bool   `conf:"default:false"`
+			Name     string `conf:"default:jumble_session"`
+			CSRFName string `conf:"default:jumble_csrf"`
+			Domain   string
+			SameSite string `conf:"default:lax"`
+		}
+
+		Lockout struct {
+			MaxFailures   int           `conf:"default:5"`
+			MaxIPFailures int           `conf:"default:50"`
+			Window        time.Duration `conf:"default:15m"`
+			Lockout       time.Duration `conf:"default:15m"`
+			BaseDelay     time.Duration `conf:"default:1s"`
+			MaxDelay      time.Duration `conf:"default:30s"`
+		}
+
+		Password struct {
+			MinLength     int  `conf:"default:8"`
+			MaxLength     int  `conf:"default:128"`
+			RequireUpper  bool `conf:"default:false"`
+			RequireLower  bool `conf:"default:false"`
+			RequireDigit  bool `conf:"default:false"`
+			RequireSymbol bool `conf:"default:false"`
+			History       int  `conf:"default:5"`
+			//MaxAge of zero never expires passwords.
+			MaxAge time.Duration `conf:"default:0s"`
+			//BreachedList is a sorted Pwned Passwords SHA-1 file, empty disables the check.
+			BreachedList string
+
+			//Hasher is either "argon2id" or "bcrypt", existing hashes are upgraded on login.
+			Hasher         string `conf:"default:argon2id"`
+			Argon2Time     uint32 `conf:"default:3"`
+			Argon2MemoryKB uint32 `conf:"default:65536"`
+			Argon2Threads  uint8  `conf:"default:4"`
+			BcryptCost     int    `conf:"default:10"`
+		}
+
+		Mail struct {
+			//Backend is either "smtp" or "file", file drops emails into the Dir.
+			Backend  string `conf:"default:file"`
+			From     string `conf:"default:jumble <no-reply@jumble.local>"`
+			Dir      string `conf:"default:/tmp/jumble-outbox"`
+			Addr     string `conf:"default:smtp:587"`
+			Username string
+			Password string        `conf:"mask"`
+			StartTLS bool          `conf:"default:true"`
+			Timeout  time.Duration `conf:"default:30s"`
+			BaseURL  string        `conf:"default:http://localhost:8000"`
+			//InviteURL is the page that accepts invitations, the token is added as a query.
+			InviteURL string
+		}
+
+		WebAuthn struct {
+			RPID    string        `conf:"default:localhost"`
+			RPName  string        `conf:"default:jumble"`
+			Origins []string      `conf:"default:http://localhost:8000"`
+			Timeout time.Duration `conf:"default:5m"`
+		}
+
+		Scheduler struct {
+			Enabled bool `conf:"default:true"`
+			//DeletedUserRetention is how long deleted users are kept before they are purged.
+			DeletedUserRetention time.Duration `conf:"default:720h"`
+		}
+
+		SCIM struct {
+			//Token is what the identity provider sends as a bearer token, empty turns SCIM off.
+			Token string `conf:"mask"`
+		}
+
+		OAuth struct {
+			CodeTTL         time.Duration `conf:"default:1m"`
+			RefreshTokenTTL time.Duration `conf:"default:720h"`
+			//AllowedOrigins are the SPA origins allowed to call the token and userinfo endpoints.
+			AllowedOrigins []string
+		}
+
+		OIDC struct {
+			//Providers is a JSON array of external identity providers users can log in with, e.g.
+			//[{"name":"corp","issuer":"https://idp.example.com","clientId":"jumble","clientSecret":"..."}].
+			Providers string `conf:"mask"`
 		}
 
 		Tempo struct {
@@ -113,9 +248,10 @@ func run(ctx context.Context, log *logger.Logger) error {
 
 	//==========================================================================
 	//Debug Server
+	debugMux := debug.Register()
 	go func() {
 		log.Info(ctx, "debug server starting", "host", cfg.Web.DebugHost)
-		if err := http.ListenAndServe(cfg.Web.DebugHost, debug.Register()); err != nil {
+		if err := http.ListenAndServe(cfg.Web.DebugHost, debugMux); err != nil {
 			log.Error(ctx, "failed to start debug server", "host", cfg.Web.DebugHost, "err", err.Error())
 			return
 		}
@@ -184,13 +320,253 @@ func run(ctx context.Context, log *logger.Logger) error {
 	validActiveKid := ks.GetActiveKid()
 	log.Info(ctx, "setting active KID was successfull", "activeKID", validActiveKid)
 
-	store := userdb.NewStore(db, tracer)
-	usrBus := bus.New(store)
+	auditLog := auditBus.New(auditdb.NewStore(db, tracer))
+
+	policy := password.Policy{
+		MinLength:     cfg.Password.MinLength,
+		MaxLength:     cfg.Password.MaxLength,
+		RequireUpper:  cfg.Password.RequireUpper,
+		RequireLower:  cfg.Password.RequireLower,
+		RequireDigit:  cfg.Password.RequireDigit,
+		RequireSymbol: cfg.Password.RequireSymbol,
+		History:       cfg.Password.History,
+		MaxAge:        cfg.Password.MaxAge,
+	}
+
+	if cfg.Password.BreachedList != "" {
+		breached, err := password.OpenBreachedFile(cfg.Password.BreachedList)
+		if err != nil {
+			return fmt.Errorf("openBreachedFile: %w", err)
+		}
+		defer breached.Close()
+
+		policy.Breached = breached
+		log.Info(ctx, "breached password list loaded", "path", cfg.Password.BreachedList)
+	}
+
+	var hasher password.Hasher
+	switch cfg.Password.Hasher {
+	case "argon2id":
+		hasher = password.Argon2id{
+			Time:       cfg.Password.Argon2Time,
+			Memory:     cfg.Password.Argon2MemoryKB,
+			Threads:    cfg.Password.Argon2Threads,
+			SaltLength: password.DefaultArgon2id.SaltLength,
+			KeyLength:  password.DefaultArgon2id.KeyLength,
+		}
+	case "bcrypt":
+		hasher = password.Bcrypt{Cost: cfg.Password.BcryptCost}
+	default:
+		return fmt.Errorf("unknown password hasher: %q", cfg.Password.Hasher)
+	}
 
-	a := auth.New(ks, usrBus, cfg.Auth.Issuer)
+	rlBus := roleBus.New(roledb.NewStore(db, tracer))
+	deptBus := departmentBus.New(departmentdb.NewStore(db, tracer))
+	orgsBus := orgBus.New(orgdb.NewStore(db, tracer), orgBus.WithRoleValidator(rlBus))
+	grpBus := groupBus.New(groupdb.NewStore(db, tracer), groupBus.WithRoleValidator(rlBus))
+	oauthsBus := oauthBus.New(oauthdb.NewStore(db, tracer))
+	saBus := serviceAccountBus.New(serviceaccountdb.NewStore(db, tracer), serviceAccountBus.WithRoleValidator(rlBus))
+
+	store := userdb.NewStore(db, tracer)
+	usrBus := bus.New(store,
+		bus.WithAuditor(auditLog),
+		bus.WithRoleValidator(rlBus),
+		bus.WithDepartmentValidator(deptBus),
+		bus.WithPasswordPolicy(policy),
+		bus.WithHasher(hasher),
+		bus.WithLockoutPolicy(bus.LockoutPolicy{
+			MaxFailures:   cfg.Lockout.MaxFailures,
+			MaxIPFailures: cfg.Lockout.MaxIPFailures,
+			Window:        cfg.Lockout.Window,
+			Lockout:       cfg.Lockout.Lockout,
+			BaseDelay:     cfg.Lockout.BaseDelay,
+			MaxDelay:      cfg.Lockout.MaxDelay,
+		}),
+	)
+
+	a := auth.New(ks, cfg.Auth.Issuer, auth.WithAudience(cfg.Auth.Audience), auth.WithLeeway(cfg.Auth.Leeway))
 
 	log.Info(ctx, "auth initialized", "key-count", count)
 
+	//==========================================================================
+	// Mailer init
+	from, err := mail.ParseAddress(cfg.Mail.From)
+	if err != nil {
+		return fmt.Errorf("parse mail from: %w", err)
+	}
+
+	var backend mailer.Mailer
+	switch cfg.Mail.Backend {
+	case "smtp":
+		backend, err = mailer.NewSMTP(mailer.SMTPConfig{
+			Addr:     cfg.Mail.Addr,
+			Username: cfg.Mail.Username,
+			Password: cfg.Mail.Password,
+			From:     *from,
+			StartTLS: cfg.Mail.StartTLS,
+			Timeout:  cfg.Mail.Timeout,
+		})
+	case "file":
+		backend, err = mailer.NewFile(cfg.Mail.Dir, *from)
+	default:
+		err = fmt.Errorf("unknown backend: %s", cfg.Mail.Backend)
+	}
+
+	if err != nil {
+		return fmt.Errorf("mailer: %w", err)
+	}
+
+	mlr := mailer.Instrument(backend, cfg.Mail.Backend, log, tracer)
+
+	log.Info(ctx, "mailer initialized", "backend", cfg.Mail.Backend)
+
+	//==========================================================================
+	// Scheduler init
+	sched := scheduler.New(db, log, tracer)
+	debugMux.Handle("/debug/scheduler", sched.StatusHandler())
+
+	err = sched.Add("purge-expired-user-tokens", "@hourly", time.Minute, func(ctx context.Context) error {
+		n, err := usrBus.DeleteExpiredTokens(ctx)
+		if err != nil {
+			return fmt.Errorf("deleteExpiredTokens: %w", err)
+		}
+
+		log.Info(ctx, "purged expired user tokens", "count", n)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("add job: %w", err)
+	}
+
+	err = sched.Add("purge-expired-invitations", "@hourly", time.Minute, func(ctx context.Context) error {
+		n, err := usrBus.DeleteExpiredInvitations(ctx)
+		if err != nil {
+			return fmt.Errorf("deleteExpiredInvitations: %w", err)
+		}
+
+		log.Info(ctx, "purged expired invitations", "count", n)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("add job: %w", err)
+	}
+
+	err = sched.Add("purge-expired-passkey-sessions", "@hourly", time.Minute, func(ctx context.Context) error {
+		n, err := usrBus.DeleteExpiredPasskeySessions(ctx)
+		if err != nil {
+			return fmt.Errorf("deleteExpiredPasskeySessions: %w", err)
+		}
+
+		log.Info(ctx, "purged expired passkey sessions", "count", n)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("add job: %w", err)
+	}
+
+	err = sched.Add("purge-stale-login-throttles", "@hourly", time.Minute, func(ctx context.Context) error {
+		n, err := usrBus.DeleteStaleThrottles(ctx)
+		if err != nil {
+			return fmt.Errorf("deleteStaleThrottles: %w", err)
+		}
+
+		log.Info(ctx, "purged stale login throttles", "count", n)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("add job: %w", err)
+	}
+
+	err = sched.Add("purge-expired-federated-sessions", "@hourly", time.Minute, func(ctx context.Context) error {
+		n, err := usrBus.DeleteExpiredFederatedSessions(ctx)
+		if err != nil {
+			return fmt.Errorf("deleteExpiredFederatedSessions: %w", err)
+		}
+
+		log.Info(ctx, "purged expired federated sessions", "count", n)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("add job: %w", err)
+	}
+
+	err = sched.Add("purge-expired-sessions", "@hourly", time.Minute, func(ctx context.Context) error {
+		n, err := usrBus.DeleteExpiredSessions(ctx)
+		if err != nil {
+			return fmt.Errorf("deleteExpiredSessions: %w", err)
+		}
+
+		log.Info(ctx, "purged expired sessions", "count", n)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("add job: %w", err)
+	}
+
+	err = sched.Add("purge-deleted-users", "@daily", time.Minute, func(ctx context.Context) error {
+		n, err := usrBus.PurgeDeletedUsers(ctx, cfg.Scheduler.DeletedUserRetention)
+		if err != nil {
+			return fmt.Errorf("purgeDeletedUsers: %w", err)
+		}
+
+		log.Info(ctx, "purged deleted users", "count", n)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("add job: %w", err)
+	}
+
+	if cfg.Auth.KeyMaxAge > 0 {
+		//a failed check shows up as the last error of the job on the debug server.
+		err = sched.Add("verify-key-expiry", "@daily", time.Minute, func(ctx context.Context) error {
+			modTime, err := ks.ModTime(validActiveKid)
+			if err != nil {
+				return fmt.Errorf("modTime: %w", err)
+			}
+
+			age := time.Since(modTime)
+			if age > cfg.Auth.KeyMaxAge {
+				return fmt.Errorf("active key %s is %s old, it has to be rotated after %s", validActiveKid, age.Round(time.Hour), cfg.Auth.KeyMaxAge)
+			}
+
+			log.Info(ctx, "active key is within its max age", "kid", validActiveKid, "age", age.Round(time.Hour))
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("add job: %w", err)
+		}
+	}
+
+	err = sched.Add("purge-expired-oauth-grants", "@hourly", time.Minute, func(ctx context.Context) error {
+		n, err := oauthsBus.DeleteExpired(ctx)
+		if err != nil {
+			return fmt.Errorf("deleteExpired: %w", err)
+		}
+
+		log.Info(ctx, "purged expired oauth grants", "count", n)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("add job: %w", err)
+	}
+
+	if cfg.Scheduler.Enabled {
+		schedulerCtx, cancel := context.WithCancel(ctx)
+		schedulerDone := make(chan struct{})
+		go func() {
+			defer close(schedulerDone)
+			log.Info(ctx, "scheduler starting")
+			sched.Run(schedulerCtx)
+		}()
+
+		//stop the scheduler and wait for running jobs to finish.
+		defer func() {
+			cancel()
+			<-schedulerDone
+			log.Info(ctx, "scheduler stopped")
+		}()
+	}
+
 	//==========================================================================
 	// Metrics init
 	m := metrics.New()
@@ -198,22 +574,168 @@ func run(ctx context.Context, log *logger.Logger) error {
 	//==========================================================================
 	// Router init
 	r := gin.New()
+	if err := r.SetTrustedProxies(cfg.Web.TrustedProxies); err != nil {
+		return fmt.Errorf("setTrustedProxies: %w", err)
+	}
 
 	//middleare stack
 	r.Use(mid.Telemetry(tracer))
 	r.Use(mid.Logger(log))
 	r.Use(mid.Metrics(m))
+	r.Use(mid.Error(log))
 	r.Use(mid.Panic(log))
 
+	providerCfgs, err := oidc.ParseConfigs(cfg.OIDC.Providers)
+	if err != nil {
+		return fmt.Errorf("parse oidc providers: %w", err)
+	}
+
+	var cookies *mid.Cookies
+	if cfg.Cookie.Enabled {
+		sameSite, err := mid.ParseSameSite(cfg.Cookie.SameSite)
+		if err != nil {
+			return fmt.Errorf("parse cookie same site: %w", err)
+		}
+
+		cookies = &mid.Cookies{
+			Name:     cfg.Cookie.Name,
+			CSRFName: cfg.Cookie.CSRFName,
+			Domain:   cfg.Cookie.Domain,
+			SameSite: sameSite,
+		}
+	}
+
+	//one client for all providers, the timeout keeps a slow provider from hanging logins.
+	oidcClient := &http.Client{Timeout: 10 * time.Second}
+	providers := make([]*oidc.Provider, len(providerCfgs))
+	for i, pc := range providerCfgs {
+		providers[i] = oidc.New(pc, oidcClient)
+	}
+
+	//every domain authenticates requests the same way, so the checks can not drift apart.
+	authConf := mid.AuthConf{
+		Log:                  log,
+		Auth:                 a,
+		UserBus:              usrBus,
+		OrgBus:               orgsBus,
+		GroupBus:             grpBus,
+		RoleBus:              rlBus,
+		ServiceAccountBus:    saBus,
+		RequireVerifiedEmail: cfg.Auth.RequireVerifiedEmail,
+		Cookies:              cookies,
+	}
+
 	userHandlers.RegisterRoutes(userHandlers.Conf{
-		UserBus:     usrBus,
-		Auth:        a,
-		Kid:         validActiveKid,
-		Issuer:      cfg.Auth.Issuer,
-		TokenMaxAge: cfg.Auth.TokenMaxAge,
-		Tracer:      tracer,
-		Logger:      log,
-		Router:      r,
+		Router:        r,
+		AuthConf:      authConf,
+		UserBus:       usrBus,
+		RoleBus:       rlBus,
+		OrgBus:        orgsBus,
+		Authorizer:    authz.New(log, authz.DefaultPolicies),
+		Auth:          a,
+		Kid:           validActiveKid,
+		Issuer:        cfg.Auth.Issuer,
+		TokenMaxAge:   cfg.Auth.TokenMaxAge,
+		ResetTokenTTL: cfg.Auth.ResetTokenTTL,
+		Mailer:        mlr,
+		BaseURL:       cfg.Mail.BaseURL,
+		WebAuthn: webauthn.New(webauthn.Config{
+			RPID:    cfg.WebAuthn.RPID,
+			RPName:  cfg.WebAuthn.RPName,
+			Origins: cfg.WebAuthn.Origins,
+			Timeout: cfg.WebAuthn.Timeout,
+		}),
+
+		VerifyTokenTTL:       cfg.Auth.VerifyTokenTTL,
+		VerifyResendInterval: cfg.Auth.VerifyResendInterval,
+
+		InviteTTL:     cfg.Auth.InviteTTL,
+		InviteURL:     cfg.Mail.InviteURL,
+		DisableSignup: cfg.Auth.DisableSignup,
+
+		Providers: providers,
+
+		IntrospectionCacheTTL: cfg.Auth.IntrospectionCacheTTL,
+		ImpersonationTTL:      cfg.Auth.ImpersonationTTL,
+		OAuthBus:              oauthsBus,
+
+		Tracer: tracer,
+		Logger: log,
+	})
+
+	roleHandlers.RegisterRoutes(roleHandlers.Conf{
+		Router:   r,
+		AuthConf: authConf,
+		RoleBus:  rlBus,
+		Tracer:   tracer,
+		Logger:   log,
+	})
+
+	orgHandlers.RegisterRoutes(orgHandlers.Conf{
+		Router:   r,
+		AuthConf: authConf,
+		OrgBus:   orgsBus,
+		RoleBus:  rlBus,
+		UserBus:  usrBus,
+		Auth:     a,
+		Kid:      validActiveKid,
+		Tracer:   tracer,
+		Logger:   log,
+	})
+
+	groupHandlers.RegisterRoutes(groupHandlers.Conf{
+		Router:   r,
+		AuthConf: authConf,
+		GroupBus: grpBus,
+		RoleBus:  rlBus,
+		UserBus:  usrBus,
+		Tracer:   tracer,
+		Logger:   log,
+	})
+
+	departmentHandlers.RegisterRoutes(departmentHandlers.Conf{
+		Router:        r,
+		AuthConf:      authConf,
+		DepartmentBus: deptBus,
+		RoleBus:       rlBus,
+		Tracer:        tracer,
+		Logger:        log,
+	})
+
+	serviceAccountHandlers.RegisterRoutes(serviceAccountHandlers.Conf{
+		Router:            r,
+		AuthConf:          authConf,
+		ServiceAccountBus: saBus,
+		RoleBus:           rlBus,
+		Tracer:            tracer,
+		Logger:            log,
+	})
+
+	scimHandlers.RegisterRoutes(scimHandlers.Conf{
+		Router:   r,
+		UserBus:  usrBus,
+		GroupBus: grpBus,
+		Token:    cfg.SCIM.Token,
+		BaseURL:  cfg.Mail.BaseURL,
+		Tracer:   tracer,
+		Logger:   log,
+	})
+
+	oauthHandlers.RegisterRoutes(oauthHandlers.Conf{
+		Router:          r,
+		AuthConf:        authConf,
+		OAuthBus:        oauthsBus,
+		UserBus:         usrBus,
+		RoleBus:         rlBus,
+		Auth:            a,
+		Kid:             validActiveKid,
+		Issuer:          cfg.Mail.BaseURL,
+		AccessTokenTTL:  cfg.Auth.TokenMaxAge,
+		RefreshTokenTTL: cfg.OAuth.RefreshTokenTTL,
+		CodeTTL:         cfg.OAuth.CodeTTL,
+		AllowedOrigins:  cfg.OAuth.AllowedOrigins,
+		Tracer:          tracer,
+		Logger:          log,
 	})
 
 	healthCheckMux := healthHandlers.RegisterRoutes(healthHandlers.Conf{
@@ -5,11 +5,11 @@ import (
 	"crypto/rsa"
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v4"
-	"github.com/google/uuid"
-	"github.com/hamidoujand/jumble/internal/domains/user/bus"
 )
 
 var (
@@ -18,11 +18,65 @@ var (
 	ErrKIDMalformed = errors.New("kid in token header is malformed")
 	ErrUserDisabled = errors.New("user is disabled")
 	ErrInvalidToken = errors.New("invalid token")
+	ErrExpired      = errors.New("token is expired")
+	ErrNotValidYet  = errors.New("token is not valid yet")
+	ErrIssuer       = errors.New("token is from another issuer")
+	ErrAudience     = errors.New("token is meant for another audience")
+)
+
+// TokenTypeMFAChallenge marks the short lived token handed out after the password check of users
+// with MFA enabled, it is only good for finishing the login and never accepted as an access token.
+const TokenTypeMFAChallenge = "mfa_challenge"
+
+// ScopeAPI is carried by tokens that may call the API of this service, tokens issued to OAuth
+// clients only get it when the client was allowed to ask for it.
+const ScopeAPI = "api"
+
+// Authentication methods used in the "amr" claim, values come from RFC 8176.
+const (
+	AMRPassword    = "pwd"
+	AMROTP         = "otp"
+	AMRMFA         = "mfa"
+	AMRHardwareKey = "hwk"
+
+	//AMRFederated is not registered in RFC 8176, it marks logins done at an external identity
+	//provider.
+	AMRFederated = "fed"
 )
 
 type Claims struct {
 	jwt.RegisteredClaims
 	Roles []string `json:"roles"`
+	AMR   []string `json:"amr,omitempty"`
+
+	//TokenType is empty for access tokens.
+	TokenType string `json:"token_type,omitempty"`
+
+	//Org scopes the token to one organization, the roles of the user in that org apply instead
+	//of the global ones.
+	Org string `json:"org,omitempty"`
+
+	//Scope lists the space separated scopes of the token, ClientID is set on tokens issued to
+	//OAuth clients.
+	Scope    string `json:"scope,omitempty"`
+	ClientID string `json:"client_id,omitempty"`
+
+	//Act names the admin acting as the subject on impersonation tokens, see RFC 8693 section 4.1.
+	Act *Actor `json:"act,omitempty"`
+
+	//SessionID ties the token to the login it was issued for, revoking that session revokes the
+	//token. Tokens that are not issued at a login have none.
+	SessionID string `json:"sid,omitempty"`
+}
+
+// HasScope reports whether the token was issued with the scope.
+func (c Claims) HasScope(scope string) bool {
+	return slices.Contains(strings.Fields(c.Scope), scope)
+}
+
+// Actor is the party acting on behalf of the subject of a token.
+type Actor struct {
+	Subject string `json:"sub"`
 }
 
 type keyLoader interface {
@@ -30,25 +84,67 @@ type keyLoader interface {
 	PublicKey(kid string) (*rsa.PublicKey, error)
 }
 
-type userBus interface {
-	QueryByID(ctx context.Context, id uuid.UUID) (bus.User, error)
-}
-
 type Auth struct {
 	keyLoader    keyLoader
 	signinMethod jwt.SigningMethod
 	parser       *jwt.Parser
+	issuer       string
+	audience     string
+	leeway       time.Duration
 }
 
-func New(loader keyLoader, usrBus userBus, issuer string) *Auth {
-	return &Auth{
+// Option configures optional settings of the Auth.
+type Option func(*Auth)
+
+// WithAudience only accepts tokens issued for the audience, it is also what new tokens are issued
+// for. Without it the audience of tokens is not checked.
+func WithAudience(aud string) Option {
+	return func(a *Auth) {
+		a.audience = aud
+	}
+}
+
+// WithLeeway allows for clocks that drift apart by up to d when checking "exp", "nbf" and "iat".
+func WithLeeway(d time.Duration) Option {
+	return func(a *Auth) {
+		a.leeway = d
+	}
+}
+
+// New returns an Auth that only accepts tokens from the issuer.
+func New(loader keyLoader, issuer string, opts ...Option) *Auth {
+	a := Auth{
 		keyLoader:    loader,
 		signinMethod: jwt.GetSigningMethod(jwt.SigningMethodRS256.Name),
-		parser:       jwt.NewParser(jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Name})),
+		//the registered claims are checked by ParseToken, the parser does not know about leeway.
+		parser: jwt.NewParser(jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Name}), jwt.WithoutClaimsValidation()),
+		issuer: issuer,
+	}
+
+	for _, opt := range opts {
+		opt(&a)
 	}
+
+	return &a
 }
 
+// GenerateToken signs an access token, the issuer and audience of the Auth are used when the
+// claims do not set them.
 func (a *Auth) GenerateToken(kid string, c Claims) (string, error) {
+	if c.Issuer == "" {
+		c.Issuer = a.issuer
+	}
+
+	if len(c.Audience) == 0 && a.audience != "" {
+		c.Audience = jwt.ClaimStrings{a.audience}
+	}
+
+	return a.Sign(kid, c)
+}
+
+// Sign signs any set of claims with the key, it is used for tokens that are not access tokens
+// like OpenID Connect ID tokens.
+func (a *Auth) Sign(kid string, c jwt.Claims) (string, error) {
 	t := jwt.NewWithClaims(a.signinMethod, c)
 
 	t.Header["kid"] = kid
@@ -66,6 +162,16 @@ func (a *Auth) GenerateToken(kid string, c Claims) (string, error) {
 	return token, nil
 }
 
+// PublicKey returns the key tokens signed with kid are verified with, so it can be published.
+func (a *Auth) PublicKey(kid string) (*rsa.PublicKey, error) {
+	pub, err := a.keyLoader.PublicKey(kid)
+	if err != nil {
+		return nil, fmt.Errorf("publicKey: %w", err)
+	}
+
+	return pub, nil
+}
+
 func (a *Auth) VerifyToken(ctx context.Context, bearer string) (Claims, error) {
 	//check for format "Bearer <TOKEN>"
 	if !strings.HasPrefix(bearer, "Bearer ") {
@@ -74,6 +180,11 @@ func (a *Auth) VerifyToken(ctx context.Context, bearer string) (Claims, error) {
 
 	token := bearer[7:] // get rid of "Bearer "
 
+	return a.ParseToken(ctx, token)
+}
+
+// ParseToken verifies the signature and the registered claims of a raw token.
+func (a *Auth) ParseToken(ctx context.Context, token string) (Claims, error) {
 	var claims Claims
 	verfiedToken, err := a.parser.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
 		//fetch the public key
@@ -105,9 +216,38 @@ func (a *Auth) VerifyToken(ctx context.Context, bearer string) (Claims, error) {
 		return Claims{}, ErrInvalidToken
 	}
 
+	if err := a.validate(claims, time.Now()); err != nil {
+		return Claims{}, err
+	}
+
 	return claims, nil
 }
 
+// validate checks the registered claims, every token needs to expire and come from the issuer.
+func (a *Auth) validate(c Claims, now time.Time) error {
+	if c.ExpiresAt == nil || !now.Add(-a.leeway).Before(c.ExpiresAt.Time) {
+		return ErrExpired
+	}
+
+	if c.NotBefore != nil && now.Add(a.leeway).Before(c.NotBefore.Time) {
+		return ErrNotValidYet
+	}
+
+	if c.IssuedAt != nil && now.Add(a.leeway).Before(c.IssuedAt.Time) {
+		return ErrNotValidYet
+	}
+
+	if c.Issuer != a.issuer {
+		return fmt.Errorf("%w: %q", ErrIssuer, c.Issuer)
+	}
+
+	if a.audience != "" && !slices.Contains(c.Audience, a.audience) {
+		return fmt.Errorf("%w: %v", ErrAudience, []string(c.Audience))
+	}
+
+	return nil
+}
+
 func (a *Auth) Authorized(c Claims, roleSet map[string]struct{}) error {
 	for _, role := range c.Roles {
 		_, ok := roleSet[role]
@@ -5,6 +5,7 @@ import (
 	"crypto/rand"
 	"crypto/rsa"
 	"errors"
+	"slices"
 	"testing"
 	"time"
 
@@ -18,7 +19,7 @@ import (
 func Test_Auth(t *testing.T) {
 	ks := newKeyStore(t)
 
-	a := auth.New(ks, nil, "auth_test")
+	a := auth.New(ks, "auth_test")
 
 	ts := tests{
 		a:  a,
@@ -102,6 +103,71 @@ func (ts tests) authorization(t *testing.T) {
 	}
 }
 
+func Test_Validation(t *testing.T) {
+	ks := newKeyStore(t)
+	a := auth.New(ks, "auth_test", auth.WithAudience("jumble"), auth.WithLeeway(time.Minute))
+
+	now := time.Now()
+	claims := func(edit func(c *auth.Claims)) auth.Claims {
+		c := auth.Claims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   uuid.NewString(),
+				IssuedAt:  jwt.NewNumericDate(now),
+				ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+			},
+		}
+		edit(&c)
+		return c
+	}
+
+	tests := map[string]struct {
+		claims auth.Claims
+		err    error
+	}{
+		"valid":                {claims: claims(func(c *auth.Claims) {})},
+		"expired":              {claims: claims(func(c *auth.Claims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-2 * time.Minute)) }), err: auth.ErrExpired},
+		"expired in leeway":    {claims: claims(func(c *auth.Claims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-30 * time.Second)) })},
+		"no expiry":            {claims: claims(func(c *auth.Claims) { c.ExpiresAt = nil }), err: auth.ErrExpired},
+		"not valid yet":        {claims: claims(func(c *auth.Claims) { c.NotBefore = jwt.NewNumericDate(now.Add(2 * time.Minute)) }), err: auth.ErrNotValidYet},
+		"not before in leeway": {claims: claims(func(c *auth.Claims) { c.NotBefore = jwt.NewNumericDate(now.Add(30 * time.Second)) })},
+		"issued in future":     {claims: claims(func(c *auth.Claims) { c.IssuedAt = jwt.NewNumericDate(now.Add(2 * time.Minute)) }), err: auth.ErrNotValidYet},
+		"other issuer":         {claims: claims(func(c *auth.Claims) { c.Issuer = "someone-else" }), err: auth.ErrIssuer},
+		"other audience":       {claims: claims(func(c *auth.Claims) { c.Audience = jwt.ClaimStrings{"other-service"} }), err: auth.ErrAudience},
+		"one of audiences":     {claims: claims(func(c *auth.Claims) { c.Audience = jwt.ClaimStrings{"other-service", "jumble"} })},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			token, err := a.GenerateToken(ks.kid, tt.claims)
+			if err != nil {
+				t.Fatalf("failed to generate token: %s", err)
+			}
+
+			got, err := a.ParseToken(context.Background(), token)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error=%v, got=%v", tt.err, err)
+			}
+
+			//the issuer and audience of the Auth are filled in when missing.
+			if err == nil && (got.Issuer != "auth_test" || !slices.Contains(got.Audience, "jumble")) {
+				t.Errorf("expected issuer and audience to be set, got=%s %v", got.Issuer, got.Audience)
+			}
+		})
+	}
+}
+
+func Test_HasScope(t *testing.T) {
+	c := auth.Claims{Scope: "openid api"}
+
+	if !c.HasScope(auth.ScopeAPI) || !c.HasScope("openid") {
+		t.Errorf("expected %q to have both scopes", c.Scope)
+	}
+
+	if c.HasScope("ap") || c.HasScope("email") {
+		t.Errorf("expected %q to only have its own scopes", c.Scope)
+	}
+}
+
 // =============================================================================
 
 type keyStore struct {
@@ -0,0 +1,85 @@
+// Package authz decides who can do what to which resource. Every policy of the service is
+// declared in policies.go, handlers only describe the subject, the action and the resource.
+package authz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	roleBus "github.com/hamidoujand/jumble/internal/domains/role/bus"
+	"github.com/hamidoujand/jumble/pkg/logger"
+)
+
+var ErrDenied = errors.New("you are not allowed to take this action")
+
+// Subject is whoever is taking the action.
+type Subject struct {
+	ID          uuid.UUID
+	Department  string
+	Permissions roleBus.PermissionSet
+
+	//OrgID is the org the subject acts in, uuid.Nil when it acts globally.
+	OrgID uuid.UUID
+}
+
+// Resource is what the action is taken on, attributes that do not apply are left empty.
+type Resource struct {
+	Type       string
+	ID         string
+	OwnerID    uuid.UUID
+	Department string
+
+	//OrgID is the org of the subject when the resource belongs to it, uuid.Nil otherwise.
+	OrgID uuid.UUID
+}
+
+func (r Resource) String() string {
+	return r.Type + ":" + r.ID
+}
+
+// Condition is one way of being allowed to take an action.
+type Condition struct {
+	Name  string
+	Match func(sub Subject, res Resource) bool
+}
+
+// Policies maps each action to its conditions, matching any of them is enough. Actions without
+// a policy are always denied.
+type Policies map[string][]Condition
+
+type Authorizer struct {
+	policies Policies
+	log      *logger.Logger
+}
+
+func New(log *logger.Logger, policies Policies) *Authorizer {
+	return &Authorizer{
+		policies: policies,
+		log:      log,
+	}
+}
+
+// Authorize returns nil when the subject can take the action on the resource and ErrDenied when
+// it can not, denials are logged along with the conditions that were checked.
+func (a *Authorizer) Authorize(ctx context.Context, sub Subject, action string, res Resource) error {
+	conds, ok := a.policies[action]
+	if !ok {
+		a.log.Warn(ctx, "authz denied", "subject", sub.ID, "action", action, "resource", res.String(), "reason", "no policy")
+		return fmt.Errorf("%w: no policy for %s", ErrDenied, action)
+	}
+
+	names := make([]string, len(conds))
+	for i, cond := range conds {
+		if cond.Match(sub, res) {
+			a.log.Debug(ctx, "authz allowed", "subject", sub.ID, "action", action, "resource", res.String(), "condition", cond.Name)
+			return nil
+		}
+		names[i] = cond.Name
+	}
+
+	a.log.Info(ctx, "authz denied", "subject", sub.ID, "action", action, "resource", res.String(), "checked", strings.Join(names, ","))
+	return ErrDenied
+}
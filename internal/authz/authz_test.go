@@ -0,0 +1,155 @@
+package authz_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/hamidoujand/jumble/internal/authz"
+	roleBus "github.com/hamidoujand/jumble/internal/domains/role/bus"
+	"github.com/hamidoujand/jumble/pkg/logger"
+)
+
+func Test_Authorize(t *testing.T) {
+	owner := uuid.New()
+	other := uuid.New()
+	org := uuid.New()
+
+	perms := func(ps ...string) roleBus.PermissionSet {
+		set := make(roleBus.PermissionSet)
+		for _, p := range ps {
+			set[p] = struct{}{}
+		}
+		return set
+	}
+
+	target := authz.Resource{
+		Type:       authz.ResourceUser,
+		ID:         owner.String(),
+		OwnerID:    owner,
+		Department: "sales",
+	}
+
+	tests := []struct {
+		name    string
+		sub     authz.Subject
+		action  string
+		inOrg   bool
+		allowed bool
+	}{
+		{
+			name:    "owner_reads_self",
+			sub:     authz.Subject{ID: owner, Permissions: perms()},
+			action:  authz.ActionUserRead,
+			allowed: true,
+		},
+		{
+			name:    "other_reads_without_permission",
+			sub:     authz.Subject{ID: other, Department: "sales", Permissions: perms()},
+			action:  authz.ActionUserRead,
+			allowed: false,
+		},
+		{
+			name:    "other_reads_with_permission",
+			sub:     authz.Subject{ID: other, Permissions: perms(roleBus.PermUsersRead)},
+			action:  authz.ActionUserRead,
+			allowed: true,
+		},
+		{
+			name:    "department_reader_same_department",
+			sub:     authz.Subject{ID: other, Department: "sales", Permissions: perms(roleBus.PermUsersDepartmentRead)},
+			action:  authz.ActionUserRead,
+			allowed: true,
+		},
+		{
+			name:    "department_reader_other_department",
+			sub:     authz.Subject{ID: other, Department: "shipping", Permissions: perms(roleBus.PermUsersDepartmentRead)},
+			action:  authz.ActionUserRead,
+			allowed: false,
+		},
+		{
+			name:    "owner_deletes_self",
+			sub:     authz.Subject{ID: owner, Permissions: perms()},
+			action:  authz.ActionUserDelete,
+			allowed: true,
+		},
+		{
+			name:    "other_deletes_with_wrong_permission",
+			sub:     authz.Subject{ID: other, Permissions: perms(roleBus.PermUsersDisable)},
+			action:  authz.ActionUserDelete,
+			allowed: false,
+		},
+		{
+			name:    "other_disables_with_permission",
+			sub:     authz.Subject{ID: other, Permissions: perms(roleBus.PermUsersDisable)},
+			action:  authz.ActionUserDisable,
+			allowed: true,
+		},
+		{
+			name:    "other_updates_with_permission",
+			sub:     authz.Subject{ID: other, Permissions: perms(roleBus.PermUsersWrite)},
+			action:  authz.ActionUserUpdate,
+			allowed: true,
+		},
+		{
+			name:    "org_admin_deletes_member",
+			sub:     authz.Subject{ID: other, OrgID: org, Permissions: perms(roleBus.PermUsersDelete)},
+			action:  authz.ActionUserDelete,
+			inOrg:   true,
+			allowed: true,
+		},
+		{
+			name:    "org_admin_deletes_outsider",
+			sub:     authz.Subject{ID: other, OrgID: org, Permissions: perms(roleBus.PermUsersDelete)},
+			action:  authz.ActionUserDelete,
+			allowed: false,
+		},
+		{
+			name:    "org_department_reader_outsider",
+			sub:     authz.Subject{ID: other, OrgID: org, Department: "sales", Permissions: perms(roleBus.PermUsersDepartmentRead)},
+			action:  authz.ActionUserRead,
+			allowed: false,
+		},
+		{
+			name:    "unknown_action",
+			sub:     authz.Subject{ID: owner, Permissions: perms(roleBus.Permissions()...)},
+			action:  "user.explode",
+			allowed: false,
+		},
+	}
+
+	for _, ts := range tests {
+		t.Run(ts.name, func(t *testing.T) {
+			var output bytes.Buffer
+			fn := func(_ context.Context) string { return "" }
+			log := logger.New(&output, logger.LevelInfo, "authz_test", fn)
+
+			a := authz.New(log, authz.DefaultPolicies)
+
+			res := target
+			if ts.inOrg {
+				res.OrgID = org
+			}
+
+			err := a.Authorize(context.Background(), ts.sub, ts.action, res)
+			if ts.allowed {
+				if err != nil {
+					t.Errorf("expected to be allowed: %s", err)
+				}
+				return
+			}
+
+			if !errors.Is(err, authz.ErrDenied) {
+				t.Errorf("err=%s, got=%v", authz.ErrDenied, err)
+			}
+
+			//denials are logged with what was asked for.
+			if !strings.Contains(output.String(), "authz denied") || !strings.Contains(output.String(), ts.action) {
+				t.Errorf("expected the denial to be logged, got=%s", output.String())
+			}
+		})
+	}
+}
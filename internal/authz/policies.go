@@ -0,0 +1,79 @@
+package authz
+
+import (
+	"github.com/google/uuid"
+	roleBus "github.com/hamidoujand/jumble/internal/domains/role/bus"
+)
+
+// Resource types.
+const (
+	ResourceUser = "user"
+)
+
+// Actions on resources, they differ from permissions since a permission is only one of the ways
+// to be allowed.
+const (
+	ActionUserRead    = "user.read"
+	ActionUserUpdate  = "user.update"
+	ActionUserDelete  = "user.delete"
+	ActionUserDisable = "user.disable"
+)
+
+// DefaultPolicies are the policies of the service.
+var DefaultPolicies = Policies{
+	ActionUserRead: {
+		Owner(),
+		HasPermission(roleBus.PermUsersRead),
+		SameDepartment(roleBus.PermUsersDepartmentRead),
+	},
+	ActionUserUpdate: {
+		Owner(),
+		HasPermission(roleBus.PermUsersWrite),
+	},
+	ActionUserDelete: {
+		Owner(),
+		HasPermission(roleBus.PermUsersDelete),
+	},
+	ActionUserDisable: {
+		Owner(),
+		HasPermission(roleBus.PermUsersDisable),
+	},
+}
+
+// ==============================================================================
+
+// Owner matches when the subject owns the resource.
+func Owner() Condition {
+	return Condition{
+		Name: "owner",
+		Match: func(sub Subject, res Resource) bool {
+			return res.OwnerID != uuid.Nil && sub.ID == res.OwnerID
+		},
+	}
+}
+
+// HasPermission matches when the subject holds the permission, permissions granted in an org only
+// reach resources of that org.
+func HasPermission(perm string) Condition {
+	return Condition{
+		Name: "permission:" + perm,
+		Match: func(sub Subject, res Resource) bool {
+			return sub.Permissions.Has(perm) && sameOrg(sub, res)
+		},
+	}
+}
+
+// SameDepartment matches when the subject holds the permission and belongs to the department of
+// the resource.
+func SameDepartment(perm string) Condition {
+	return Condition{
+		Name: "department:" + perm,
+		Match: func(sub Subject, res Resource) bool {
+			return sub.Permissions.Has(perm) && sameOrg(sub, res) && sub.Department != "" && sub.Department == res.Department
+		},
+	}
+}
+
+func sameOrg(sub Subject, res Resource) bool {
+	return sub.OrgID == res.OrgID
+}
@@ -0,0 +1,66 @@
+// Package bus provides the business logic of the audit log, an append-only record of security
+// relevant events.
+package bus
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Actions recorded in the audit log.
+const (
+	ActionLoginLocked          = "login.locked"
+	ActionUserUnlock           = "user.unlocked"
+	ActionUserInvited          = "user.invited"
+	ActionInvitationAccepted   = "invitation.accepted"
+	ActionInvitationRevoked    = "invitation.revoked"
+	ActionIdentityLinked       = "identity.linked"
+	ActionUserProvisioned      = "user.provisioned"
+	ActionImpersonationStarted = "impersonation.started"
+	ActionImpersonatedRequest  = "impersonation.request"
+	ActionSessionRevoked       = "session.revoked"
+)
+
+type store interface {
+	Create(ctx context.Context, e Entry) error
+	QueryByTarget(ctx context.Context, target string) ([]Entry, error)
+}
+
+type Bus struct {
+	store store
+}
+
+func New(store store) *Bus {
+	return &Bus{store: store}
+}
+
+func (b *Bus) Create(ctx context.Context, ne NewEntry) (Entry, error) {
+	e := Entry{
+		ID:        uuid.New(),
+		ActorID:   ne.ActorID,
+		Action:    ne.Action,
+		Target:    ne.Target,
+		IP:        ne.IP,
+		Details:   ne.Details,
+		CreatedAt: time.Now().Truncate(time.Microsecond),
+	}
+
+	if err := b.store.Create(ctx, e); err != nil {
+		return Entry{}, fmt.Errorf("create: %w", err)
+	}
+
+	return e, nil
+}
+
+// QueryByTarget returns the entries about the target, newest first.
+func (b *Bus) QueryByTarget(ctx context.Context, target string) ([]Entry, error) {
+	es, err := b.store.QueryByTarget(ctx, target)
+	if err != nil {
+		return nil, fmt.Errorf("queryByTarget: %w", err)
+	}
+
+	return es, nil
+}
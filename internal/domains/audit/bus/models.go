@@ -0,0 +1,27 @@
+package bus
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Entry is a single audit log record, ActorID is uuid.Nil when the system did the action and
+// Target names what the action was about, e.g. "user:<id>" or "ip:<addr>".
+type Entry struct {
+	ID        uuid.UUID
+	ActorID   uuid.UUID
+	Action    string
+	Target    string
+	IP        string
+	Details   map[string]string
+	CreatedAt time.Time
+}
+
+type NewEntry struct {
+	ActorID uuid.UUID
+	Action  string
+	Target  string
+	IP      string
+	Details map[string]string
+}
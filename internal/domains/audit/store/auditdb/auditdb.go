@@ -0,0 +1,81 @@
+package auditdb
+
+import (
+	"context"
+	"fmt"
+
+	auditBus "github.com/hamidoujand/jumble/internal/domains/audit/bus"
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type Store struct {
+	db     *sqlx.DB
+	tracer trace.Tracer
+}
+
+func NewStore(db *sqlx.DB, tracer trace.Tracer) *Store {
+	return &Store{
+		db:     db,
+		tracer: tracer,
+	}
+}
+
+func (s *Store) Create(ctx context.Context, e auditBus.Entry) error {
+	const q = `
+	INSERT INTO audit_log (id,actor_id,action,target,ip,details,created_at)
+	VALUES (:id,:actor_id,:action,:target,:ip,:details,:created_at)
+	`
+
+	ctx, span := s.tracer.Start(ctx, "audit.store.create")
+	defer span.End()
+
+	dbEntry, err := fromBusEntry(e)
+	if err != nil {
+		return fmt.Errorf("fromBusEntry: %w", err)
+	}
+
+	if _, err := s.db.NamedExecContext(ctx, q, dbEntry); err != nil {
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) QueryByTarget(ctx context.Context, target string) ([]auditBus.Entry, error) {
+	data := map[string]any{
+		"target": target,
+	}
+
+	const q = `SELECT * FROM audit_log WHERE target = :target ORDER BY created_at DESC`
+
+	ctx, span := s.tracer.Start(ctx, "audit.store.queryByTarget")
+	defer span.End()
+
+	rows, err := s.db.NamedQueryContext(ctx, q, data)
+	if err != nil {
+		return nil, fmt.Errorf("namedQueryContext: %w", err)
+	}
+
+	defer rows.Close()
+
+	var es []auditBus.Entry
+	for rows.Next() {
+		var e entry
+		if err := rows.StructScan(&e); err != nil {
+			return nil, fmt.Errorf("structScan: %w", err)
+		}
+
+		busEntry, err := toBusEntry(e)
+		if err != nil {
+			return nil, fmt.Errorf("toBusEntry: %w", err)
+		}
+		es = append(es, busEntry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("preparing next row to scan: %w", err)
+	}
+
+	return es, nil
+}
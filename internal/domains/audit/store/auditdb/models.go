@@ -0,0 +1,55 @@
+package auditdb
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	auditBus "github.com/hamidoujand/jumble/internal/domains/audit/bus"
+)
+
+type entry struct {
+	ID        uuid.UUID      `db:"id"`
+	ActorID   uuid.NullUUID  `db:"actor_id"`
+	Action    string         `db:"action"`
+	Target    string         `db:"target"`
+	IP        sql.NullString `db:"ip"`
+	Details   []byte         `db:"details"`
+	CreatedAt time.Time      `db:"created_at"`
+}
+
+func fromBusEntry(e auditBus.Entry) (entry, error) {
+	details, err := json.Marshal(e.Details)
+	if err != nil {
+		return entry{}, fmt.Errorf("marshal details: %w", err)
+	}
+
+	return entry{
+		ID:        e.ID,
+		ActorID:   uuid.NullUUID{UUID: e.ActorID, Valid: e.ActorID != uuid.Nil},
+		Action:    e.Action,
+		Target:    e.Target,
+		IP:        sql.NullString{String: e.IP, Valid: e.IP != ""},
+		Details:   details,
+		CreatedAt: e.CreatedAt,
+	}, nil
+}
+
+func toBusEntry(e entry) (auditBus.Entry, error) {
+	var details map[string]string
+	if err := json.Unmarshal(e.Details, &details); err != nil {
+		return auditBus.Entry{}, fmt.Errorf("unmarshal details: %w", err)
+	}
+
+	return auditBus.Entry{
+		ID:        e.ID,
+		ActorID:   e.ActorID.UUID,
+		Action:    e.Action,
+		Target:    e.Target,
+		IP:        e.IP.String,
+		Details:   details,
+		CreatedAt: e.CreatedAt,
+	}, nil
+}
@@ -0,0 +1,135 @@
+// Package bus provides the business logic of departments.
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+var (
+	ErrDepartmentNotFound   = errors.New("department not found")
+	ErrInvalidName          = errors.New("department names are 1 to 64 lower case letters, digits, spaces, '_' or '-' starting with a letter")
+	ErrDuplicatedDepartment = errors.New("department already exists")
+	ErrDepartmentInUse      = errors.New("department still has users")
+)
+
+var departmentName = regexp.MustCompile(`^[a-z][a-z0-9 _-]{0,63}$`)
+
+type store interface {
+	Create(ctx context.Context, d Department) error
+	Update(ctx context.Context, d Department) error
+	Rename(ctx context.Context, oldName string, d Department) error
+	Delete(ctx context.Context, d Department) error
+	QueryByName(ctx context.Context, name string) (Department, error)
+	Query(ctx context.Context) ([]Department, error)
+	Counts(ctx context.Context) ([]Count, error)
+}
+
+type Bus struct {
+	store store
+}
+
+func New(store store) *Bus {
+	return &Bus{store: store}
+}
+
+func (b *Bus) Create(ctx context.Context, nd NewDepartment) (Department, error) {
+	if !departmentName.MatchString(nd.Name) {
+		return Department{}, ErrInvalidName
+	}
+
+	now := time.Now().Truncate(time.Microsecond)
+
+	d := Department{
+		Name:        nd.Name,
+		Description: nd.Description,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	if err := b.store.Create(ctx, d); err != nil {
+		return Department{}, fmt.Errorf("create: %w", err)
+	}
+
+	return d, nil
+}
+
+// Update changes the department, renaming moves every user and invitation of the old name to the
+// new one.
+func (b *Bus) Update(ctx context.Context, d Department, ud UpdateDepartment) (Department, error) {
+	if ud.Description != nil {
+		d.Description = *ud.Description
+	}
+
+	d.UpdatedAt = time.Now().Truncate(time.Microsecond)
+
+	if ud.Name != nil && *ud.Name != d.Name {
+		if !departmentName.MatchString(*ud.Name) {
+			return Department{}, ErrInvalidName
+		}
+
+		oldName := d.Name
+		d.Name = *ud.Name
+
+		if err := b.store.Rename(ctx, oldName, d); err != nil {
+			return Department{}, fmt.Errorf("rename: %w", err)
+		}
+
+		return d, nil
+	}
+
+	if err := b.store.Update(ctx, d); err != nil {
+		return Department{}, fmt.Errorf("update: %w", err)
+	}
+
+	return d, nil
+}
+
+// Delete removes the department, departments with users can not be deleted.
+func (b *Bus) Delete(ctx context.Context, d Department) error {
+	if err := b.store.Delete(ctx, d); err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+
+	return nil
+}
+
+func (b *Bus) QueryByName(ctx context.Context, name string) (Department, error) {
+	d, err := b.store.QueryByName(ctx, name)
+	if err != nil {
+		return Department{}, fmt.Errorf("queryByName: %w", err)
+	}
+
+	return d, nil
+}
+
+func (b *Bus) Query(ctx context.Context) ([]Department, error) {
+	ds, err := b.store.Query(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return ds, nil
+}
+
+// Counts returns how many users each department has, empty departments included.
+func (b *Bus) Counts(ctx context.Context) ([]Count, error) {
+	cs, err := b.store.Counts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("counts: %w", err)
+	}
+
+	return cs, nil
+}
+
+// Validate returns ErrDepartmentNotFound when there is no department with the name.
+func (b *Bus) Validate(ctx context.Context, name string) error {
+	if _, err := b.store.QueryByName(ctx, name); err != nil {
+		return fmt.Errorf("queryByName: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,180 @@
+package bus_test
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/mail"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hamidoujand/jumble/internal/dbtest"
+	"github.com/hamidoujand/jumble/internal/domains/department/bus"
+	"github.com/hamidoujand/jumble/internal/domains/department/store/departmentdb"
+	userBus "github.com/hamidoujand/jumble/internal/domains/user/bus"
+	"github.com/hamidoujand/jumble/internal/domains/user/store/userdb"
+	"github.com/hamidoujand/jumble/pkg/docker"
+	"github.com/hamidoujand/jumble/pkg/telemetry"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var container docker.Container
+var tracer trace.Tracer
+
+func TestMain(m *testing.M) {
+	// before all
+	var err error
+	container, err = dbtest.CreateDBContainer()
+	if err != nil {
+		log.Fatalf("createDBContainer: %s", err)
+	}
+
+	defer docker.StopContainer(container.Name)
+	cfg := telemetry.Config{
+		ServiceName: "department_bus_test",
+		Host:        "",
+		Build:       "v0.0.1",
+	}
+
+	cleanup, err := telemetry.SetupOTelSDK(cfg)
+	if err != nil {
+		log.Fatalf("setupOTelSDK: %s", err)
+	}
+
+	tracer = otel.Tracer("department_bus_tests")
+
+	defer cleanup(context.Background())
+
+	// tests
+	os.Exit(m.Run())
+
+}
+
+func Test_Departments(t *testing.T) {
+	t.Parallel()
+
+	db := dbtest.New(t, container, "departments")
+	b := bus.New(departmentdb.NewStore(db, tracer))
+	usrBus := userBus.New(userdb.NewStore(db, tracer), userBus.WithDepartmentValidator(b))
+
+	//the departments that used to be hardcoded are seeded.
+	for _, name := range []string{"sales", "shipping", "marketing"} {
+		if err := b.Validate(context.Background(), name); err != nil {
+			t.Errorf("expected %s to exist: %s", name, err)
+		}
+	}
+
+	d, err := b.Create(context.Background(), bus.NewDepartment{Name: "support", Description: "Helps customers"})
+	if err != nil {
+		t.Fatalf("failed to create a department: %s", err)
+	}
+
+	if _, err := b.Create(context.Background(), bus.NewDepartment{Name: "support"}); !errors.Is(err, bus.ErrDuplicatedDepartment) {
+		t.Errorf("err=%s, got=%v", bus.ErrDuplicatedDepartment, err)
+	}
+
+	if _, err := b.Create(context.Background(), bus.NewDepartment{Name: "Not Valid!"}); !errors.Is(err, bus.ErrInvalidName) {
+		t.Errorf("err=%s, got=%v", bus.ErrInvalidName, err)
+	}
+
+	roles, err := userBus.ParseManyRoles([]string{"user"})
+	if err != nil {
+		t.Fatalf("failed to parse roles: %s", err)
+	}
+
+	nu := userBus.NewUser{
+		Name:       "John Doe",
+		Email:      mail.Address{Name: "John Doe", Address: "john@gmail.com"},
+		Roles:      roles,
+		Department: "loading",
+		Password:   "test1234",
+	}
+
+	if _, err := usrBus.Create(context.Background(), nu); !errors.Is(err, bus.ErrDepartmentNotFound) {
+		t.Errorf("err=%s, got=%v", bus.ErrDepartmentNotFound, err)
+	}
+
+	nu.Department = d.Name
+	usr, err := usrBus.Create(context.Background(), nu)
+	if err != nil {
+		t.Fatalf("failed to create a user: %s", err)
+	}
+
+	inv, _, err := usrBus.CreateInvitation(context.Background(), userBus.NewInvitation{
+		Email:      mail.Address{Address: "jane@gmail.com"},
+		Roles:      roles,
+		Department: d.Name,
+		TTL:        time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("failed to create an invitation: %s", err)
+	}
+
+	//renaming moves the users and pending invitations along.
+	renamed, err := b.Update(context.Background(), d, bus.UpdateDepartment{Name: newPointer("customer success")})
+	if err != nil {
+		t.Fatalf("failed to rename the department: %s", err)
+	}
+
+	inv, err = usrBus.QueryInvitationByID(context.Background(), inv.ID)
+	if err != nil {
+		t.Fatalf("failed to query the invitation: %s", err)
+	}
+
+	if inv.Department != renamed.Name {
+		t.Errorf("department=%s, got=%s", renamed.Name, inv.Department)
+	}
+
+	usr, err = usrBus.QueryByID(context.Background(), usr.ID)
+	if err != nil {
+		t.Fatalf("failed to query the user: %s", err)
+	}
+
+	if usr.Department != renamed.Name {
+		t.Errorf("department=%s, got=%s", renamed.Name, usr.Department)
+	}
+
+	if _, err := b.QueryByName(context.Background(), "support"); !errors.Is(err, bus.ErrDepartmentNotFound) {
+		t.Errorf("err=%s, got=%v", bus.ErrDepartmentNotFound, err)
+	}
+
+	if _, err := b.Update(context.Background(), renamed, bus.UpdateDepartment{Name: newPointer("sales")}); !errors.Is(err, bus.ErrDuplicatedDepartment) {
+		t.Errorf("err=%s, got=%v", bus.ErrDuplicatedDepartment, err)
+	}
+
+	counts, err := b.Counts(context.Background())
+	if err != nil {
+		t.Fatalf("failed to count users: %s", err)
+	}
+
+	got := make(map[string]int)
+	for _, c := range counts {
+		got[c.Name] = c.Users
+	}
+
+	if got[renamed.Name] != 1 {
+		t.Errorf("users=%d, got=%d", 1, got[renamed.Name])
+	}
+
+	if n, ok := got["shipping"]; !ok || n != 0 {
+		t.Errorf("expected empty departments to be counted, got=%v", got)
+	}
+
+	if err := b.Delete(context.Background(), renamed); !errors.Is(err, bus.ErrDepartmentInUse) {
+		t.Errorf("err=%s, got=%v", bus.ErrDepartmentInUse, err)
+	}
+
+	if err := usrBus.Delete(context.Background(), usr); err != nil {
+		t.Fatalf("failed to delete the user: %s", err)
+	}
+
+	if err := b.Delete(context.Background(), renamed); err != nil {
+		t.Fatalf("failed to delete the department: %s", err)
+	}
+}
+
+func newPointer[T any](val T) *T {
+	return &val
+}
@@ -0,0 +1,29 @@
+package bus
+
+import "time"
+
+// Department is where a user works, users refer to it by name.
+type Department struct {
+	Name        string
+	Description string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type NewDepartment struct {
+	Name        string
+	Description string
+}
+
+// UpdateDepartment changes the fields that are set, a new Name renames the department for every
+// user in it too.
+type UpdateDepartment struct {
+	Name        *string
+	Description *string
+}
+
+// Count is the number of users in a department.
+type Count struct {
+	Name  string
+	Users int
+}
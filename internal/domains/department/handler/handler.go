@@ -0,0 +1,160 @@
+// Package handler provides endpoints to manage departments.
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	departmentBus "github.com/hamidoujand/jumble/internal/domains/department/bus"
+	"github.com/hamidoujand/jumble/internal/errs"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type handler struct {
+	departmentBus *departmentBus.Bus
+	tracer        trace.Tracer
+}
+
+func (h *handler) CreateDepartment(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "department.handler.createDepartment")
+	defer span.End()
+
+	var nd newDepartment
+	if err := c.ShouldBindJSON(&nd); err != nil {
+		c.Error(err)
+		return
+	}
+
+	d, err := h.departmentBus.Create(ctx, toBusNewDepartment(nd))
+	if errors.Is(err, departmentBus.ErrDuplicatedDepartment) || errors.Is(err, departmentBus.ErrInvalidName) {
+		c.Error(errs.New(http.StatusBadRequest, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "create: %s", err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, toAppDepartment(d))
+}
+
+func (h *handler) UpdateDepartment(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "department.handler.updateDepartment")
+	defer span.End()
+
+	var ud updateDepartment
+	if err := c.ShouldBindJSON(&ud); err != nil {
+		c.Error(err)
+		return
+	}
+
+	d, err := h.departmentBus.QueryByName(ctx, c.Param("name"))
+	if errors.Is(err, departmentBus.ErrDepartmentNotFound) {
+		c.Error(errs.New(http.StatusNotFound, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "queryByName: %s", err))
+		return
+	}
+
+	updated, err := h.departmentBus.Update(ctx, d, toBusUpdateDepartment(ud))
+	if errors.Is(err, departmentBus.ErrDuplicatedDepartment) || errors.Is(err, departmentBus.ErrInvalidName) {
+		c.Error(errs.New(http.StatusBadRequest, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "update: %s", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, toAppDepartment(updated))
+}
+
+func (h *handler) DeleteDepartment(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "department.handler.deleteDepartment")
+	defer span.End()
+
+	d, err := h.departmentBus.QueryByName(ctx, c.Param("name"))
+	if errors.Is(err, departmentBus.ErrDepartmentNotFound) {
+		c.Error(errs.New(http.StatusNotFound, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "queryByName: %s", err))
+		return
+	}
+
+	err = h.departmentBus.Delete(ctx, d)
+	if errors.Is(err, departmentBus.ErrDepartmentInUse) {
+		c.Error(errs.New(http.StatusConflict, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "delete: %s", err))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *handler) QueryDepartmentByName(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "department.handler.queryDepartmentByName")
+	defer span.End()
+
+	d, err := h.departmentBus.QueryByName(ctx, c.Param("name"))
+	if errors.Is(err, departmentBus.ErrDepartmentNotFound) {
+		c.Error(errs.New(http.StatusNotFound, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "queryByName: %s", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, toAppDepartment(d))
+}
+
+func (h *handler) QueryDepartments(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "department.handler.queryDepartments")
+	defer span.End()
+
+	ds, err := h.departmentBus.Query(ctx)
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "query: %s", err))
+		return
+	}
+
+	apps := make([]department, len(ds))
+	for i, d := range ds {
+		apps[i] = toAppDepartment(d)
+	}
+
+	c.JSON(http.StatusOK, apps)
+}
+
+// QueryCounts returns the number of users in each department.
+func (h *handler) QueryCounts(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "department.handler.queryCounts")
+	defer span.End()
+
+	cs, err := h.departmentBus.Counts(ctx)
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "counts: %s", err))
+		return
+	}
+
+	apps := make([]count, len(cs))
+	for i, cnt := range cs {
+		apps[i] = count{Name: cnt.Name, Users: cnt.Users}
+	}
+
+	c.JSON(http.StatusOK, apps)
+}
@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"time"
+
+	departmentBus "github.com/hamidoujand/jumble/internal/domains/department/bus"
+)
+
+type department struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	CreatedAt   string `json:"createdAt"`
+	UpdatedAt   string `json:"updatedAt"`
+}
+
+func toAppDepartment(d departmentBus.Department) department {
+	return department{
+		Name:        d.Name,
+		Description: d.Description,
+		CreatedAt:   d.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:   d.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+type count struct {
+	Name  string `json:"name"`
+	Users int    `json:"users"`
+}
+
+// ==============================================================================
+
+type newDepartment struct {
+	Name        string `json:"name" binding:"required,max=64"`
+	Description string `json:"description" binding:"max=400"`
+}
+
+func toBusNewDepartment(nd newDepartment) departmentBus.NewDepartment {
+	return departmentBus.NewDepartment{
+		Name:        nd.Name,
+		Description: nd.Description,
+	}
+}
+
+type updateDepartment struct {
+	Name        *string `json:"name" binding:"omitempty,min=1,max=64"`
+	Description *string `json:"description" binding:"omitempty,max=400"`
+}
+
+func toBusUpdateDepartment(ud updateDepartment) departmentBus.UpdateDepartment {
+	return departmentBus.UpdateDepartment{
+		Name:        ud.Name,
+		Description: ud.Description,
+	}
+}
@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/hamidoujand/jumble/internal/auth"
+	departmentBus "github.com/hamidoujand/jumble/internal/domains/department/bus"
+	roleBus "github.com/hamidoujand/jumble/internal/domains/role/bus"
+	"github.com/hamidoujand/jumble/internal/mid"
+	"github.com/hamidoujand/jumble/pkg/logger"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type Conf struct {
+	Router        *gin.Engine
+	AuthConf      mid.AuthConf
+	DepartmentBus *departmentBus.Bus
+	RoleBus       *roleBus.Bus
+	Tracer        trace.Tracer
+	Logger        *logger.Logger
+}
+
+// RegisterRoutes takes the mux and register endpoints on it.
+func RegisterRoutes(cfg Conf) {
+	h := handler{
+		departmentBus: cfg.DepartmentBus,
+		tracer:        cfg.Tracer,
+	}
+
+	authenticated := mid.Authenticate(cfg.AuthConf)
+
+	api := mid.RequireScope(auth.ScopeAPI)
+
+	can := func(perms ...string) gin.HandlerFunc {
+		return mid.RequirePermission(cfg.Logger, cfg.RoleBus, perms...)
+	}
+
+	//departments are shared by every org, members can list them but only global admins change them.
+	global := mid.GlobalScope()
+
+	departments := cfg.Router.Group("/v1/departments", authenticated, api)
+
+	departments.GET("/", h.QueryDepartments)
+	departments.GET("/counts", global, can(roleBus.PermUsersRead), h.QueryCounts)
+	departments.GET("/:name", h.QueryDepartmentByName)
+	departments.POST("/", global, can(roleBus.PermDepartmentsWrite), h.CreateDepartment)
+	departments.PUT("/:name", global, can(roleBus.PermDepartmentsWrite), h.UpdateDepartment)
+	departments.DELETE("/:name", global, can(roleBus.PermDepartmentsWrite), h.DeleteDepartment)
+}
@@ -0,0 +1,223 @@
+package departmentdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	departmentBus "github.com/hamidoujand/jumble/internal/domains/department/bus"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+)
+
+type Store struct {
+	db     *sqlx.DB
+	tracer trace.Tracer
+}
+
+func NewStore(db *sqlx.DB, tracer trace.Tracer) *Store {
+	return &Store{
+		db:     db,
+		tracer: tracer,
+	}
+}
+
+func (s *Store) Create(ctx context.Context, d departmentBus.Department) error {
+	const q = `
+	INSERT INTO departments (name,description,created_at,updated_at)
+	VALUES (:name,:description,:created_at,:updated_at)
+	`
+
+	ctx, span := s.tracer.Start(ctx, "department.store.create")
+	defer span.End()
+
+	if _, err := s.db.NamedExecContext(ctx, q, fromBusDepartment(d)); err != nil {
+		var pgerror *pgconn.PgError
+		if errors.As(err, &pgerror) {
+			if pgerror.Code == uniqueViolation {
+				return departmentBus.ErrDuplicatedDepartment
+			}
+		}
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) Update(ctx context.Context, d departmentBus.Department) error {
+	const q = `
+	UPDATE departments 
+	SET 
+		description = :description,
+		updated_at = :updated_at
+	WHERE 
+		name = :name
+	`
+
+	ctx, span := s.tracer.Start(ctx, "department.store.update")
+	defer span.End()
+
+	if _, err := s.db.NamedExecContext(ctx, q, fromBusDepartment(d)); err != nil {
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	return nil
+}
+
+// Rename renames the department, the foreign keys on users and invitations move them along.
+func (s *Store) Rename(ctx context.Context, oldName string, d departmentBus.Department) error {
+	data := map[string]any{
+		"old_name":    oldName,
+		"name":        d.Name,
+		"description": d.Description,
+		"updated_at":  d.UpdatedAt,
+	}
+
+	const q = `
+	UPDATE departments 
+	SET 
+		name = :name,
+		description = :description,
+		updated_at = :updated_at
+	WHERE 
+		name = :old_name
+	`
+
+	ctx, span := s.tracer.Start(ctx, "department.store.rename")
+	defer span.End()
+
+	if _, err := s.db.NamedExecContext(ctx, q, data); err != nil {
+		var pgerror *pgconn.PgError
+		if errors.As(err, &pgerror) {
+			if pgerror.Code == uniqueViolation {
+				return departmentBus.ErrDuplicatedDepartment
+			}
+		}
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	return nil
+}
+
+// Delete only deletes departments without users, the foreign key on users refuses the rest even
+// when a user joins while it is being deleted.
+func (s *Store) Delete(ctx context.Context, d departmentBus.Department) error {
+	const q = `
+	DELETE FROM departments 
+	WHERE 
+		name = :name
+	`
+
+	ctx, span := s.tracer.Start(ctx, "department.store.delete")
+	defer span.End()
+
+	if _, err := s.db.NamedExecContext(ctx, q, fromBusDepartment(d)); err != nil {
+		var pgerror *pgconn.PgError
+		if errors.As(err, &pgerror) {
+			if pgerror.Code == foreignKeyViolation {
+				return departmentBus.ErrDepartmentInUse
+			}
+		}
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) QueryByName(ctx context.Context, name string) (departmentBus.Department, error) {
+	data := map[string]any{
+		"name": name,
+	}
+
+	const q = `SELECT * FROM departments WHERE name = :name`
+
+	ctx, span := s.tracer.Start(ctx, "department.store.queryByName")
+	defer span.End()
+
+	rows, err := s.db.NamedQueryContext(ctx, q, data)
+	if err != nil {
+		return departmentBus.Department{}, fmt.Errorf("namedQueryContext: %w", err)
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		return departmentBus.Department{}, departmentBus.ErrDepartmentNotFound
+	}
+
+	var d department
+	if err := rows.StructScan(&d); err != nil {
+		return departmentBus.Department{}, fmt.Errorf("structScan: %w", err)
+	}
+
+	return toBusDepartment(d), nil
+}
+
+func (s *Store) Query(ctx context.Context) ([]departmentBus.Department, error) {
+	const q = `SELECT * FROM departments ORDER BY name`
+
+	ctx, span := s.tracer.Start(ctx, "department.store.query")
+	defer span.End()
+
+	rows, err := s.db.NamedQueryContext(ctx, q, map[string]any{})
+	if err != nil {
+		return nil, fmt.Errorf("namedQueryContext: %w", err)
+	}
+
+	defer rows.Close()
+
+	var ds []departmentBus.Department
+	for rows.Next() {
+		var d department
+		if err := rows.StructScan(&d); err != nil {
+			return nil, fmt.Errorf("structScan: %w", err)
+		}
+		ds = append(ds, toBusDepartment(d))
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("preparing next row to scan: %w", err)
+	}
+
+	return ds, nil
+}
+
+func (s *Store) Counts(ctx context.Context) ([]departmentBus.Count, error) {
+	const q = `
+	SELECT d.name, count(u.id) AS users FROM departments d 
+	LEFT JOIN users u ON u.department = d.name 
+	GROUP BY d.name 
+	ORDER BY d.name
+	`
+
+	ctx, span := s.tracer.Start(ctx, "department.store.counts")
+	defer span.End()
+
+	rows, err := s.db.NamedQueryContext(ctx, q, map[string]any{})
+	if err != nil {
+		return nil, fmt.Errorf("namedQueryContext: %w", err)
+	}
+
+	defer rows.Close()
+
+	var cs []departmentBus.Count
+	for rows.Next() {
+		var c count
+		if err := rows.StructScan(&c); err != nil {
+			return nil, fmt.Errorf("structScan: %w", err)
+		}
+		cs = append(cs, departmentBus.Count{Name: c.Name, Users: c.Users})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("preparing next row to scan: %w", err)
+	}
+
+	return cs, nil
+}
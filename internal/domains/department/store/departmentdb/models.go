@@ -0,0 +1,37 @@
+package departmentdb
+
+import (
+	"time"
+
+	departmentBus "github.com/hamidoujand/jumble/internal/domains/department/bus"
+)
+
+type department struct {
+	Name        string    `db:"name"`
+	Description string    `db:"description"`
+	CreatedAt   time.Time `db:"created_at"`
+	UpdatedAt   time.Time `db:"updated_at"`
+}
+
+func fromBusDepartment(d departmentBus.Department) department {
+	return department{
+		Name:        d.Name,
+		Description: d.Description,
+		CreatedAt:   d.CreatedAt,
+		UpdatedAt:   d.UpdatedAt,
+	}
+}
+
+func toBusDepartment(d department) departmentBus.Department {
+	return departmentBus.Department{
+		Name:        d.Name,
+		Description: d.Description,
+		CreatedAt:   d.CreatedAt,
+		UpdatedAt:   d.UpdatedAt,
+	}
+}
+
+type count struct {
+	Name  string `db:"name"`
+	Users int    `db:"users"`
+}
@@ -0,0 +1,285 @@
+// Package bus provides the business logic of groups, the teams users are organized in.
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrGroupNotFound    = errors.New("group not found")
+	ErrDuplicatedGroup  = errors.New("group name already in use")
+	ErrParentNotFound   = errors.New("parent group not found")
+	ErrCycle            = errors.New("a group can not be nested under itself or its subgroups")
+	ErrHasSubgroups     = errors.New("group has subgroups, move or delete them first")
+	ErrMemberNotFound   = errors.New("member not found")
+	ErrDuplicatedMember = errors.New("user is already a member")
+)
+
+type store interface {
+	Create(ctx context.Context, g Group) error
+	Update(ctx context.Context, g Group) error
+	Delete(ctx context.Context, g Group) error
+	QueryByID(ctx context.Context, id uuid.UUID) (Group, error)
+	Query(ctx context.Context) ([]Group, error)
+	QueryAncestors(ctx context.Context, id uuid.UUID) ([]Group, error)
+	QueryByUser(ctx context.Context, userID uuid.UUID) ([]Group, error)
+	QueryRoles(ctx context.Context, userID uuid.UUID) ([]string, error)
+	CreateMember(ctx context.Context, m Member) error
+	DeleteMember(ctx context.Context, m Member) error
+	QueryMember(ctx context.Context, groupID uuid.UUID, userID uuid.UUID) (Member, error)
+	QueryMembers(ctx context.Context, groupID uuid.UUID) ([]Member, error)
+}
+
+// roleValidator makes sure roles exist before they are given to a group, the role bus satisfies it.
+type roleValidator interface {
+	Validate(ctx context.Context, names []string) error
+}
+
+type Bus struct {
+	store store
+	roles roleValidator
+}
+
+// Option configures optional dependencies of the Bus.
+type Option func(*Bus)
+
+// WithRoleValidator rejects groups with roles that do not exist.
+func WithRoleValidator(v roleValidator) Option {
+	return func(b *Bus) {
+		b.roles = v
+	}
+}
+
+func New(store store, opts ...Option) *Bus {
+	b := Bus{store: store}
+
+	for _, opt := range opts {
+		opt(&b)
+	}
+
+	return &b
+}
+
+func (b *Bus) Create(ctx context.Context, ng NewGroup) (Group, error) {
+	if err := b.validateRoles(ctx, ng.Roles); err != nil {
+		return Group{}, err
+	}
+
+	if ng.ParentID != nil {
+		if _, err := b.store.QueryByID(ctx, *ng.ParentID); err != nil {
+			if errors.Is(err, ErrGroupNotFound) {
+				return Group{}, ErrParentNotFound
+			}
+			return Group{}, fmt.Errorf("queryByID: %w", err)
+		}
+	}
+
+	roles := ng.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+
+	now := time.Now().Truncate(time.Microsecond)
+
+	g := Group{
+		ID:          uuid.New(),
+		Name:        ng.Name,
+		Description: ng.Description,
+		ParentID:    ng.ParentID,
+		Roles:       roles,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	if err := b.store.Create(ctx, g); err != nil {
+		return Group{}, fmt.Errorf("create: %w", err)
+	}
+
+	return g, nil
+}
+
+func (b *Bus) Update(ctx context.Context, g Group, ug UpdateGroup) (Group, error) {
+	if ug.Name != nil {
+		g.Name = *ug.Name
+	}
+
+	if ug.Description != nil {
+		g.Description = *ug.Description
+	}
+
+	if ug.Roles != nil {
+		if err := b.validateRoles(ctx, ug.Roles); err != nil {
+			return Group{}, err
+		}
+		g.Roles = ug.Roles
+	}
+
+	if ug.ParentID != nil {
+		if *ug.ParentID == uuid.Nil {
+			g.ParentID = nil
+		} else {
+			if err := b.checkCycle(ctx, g, *ug.ParentID); err != nil {
+				return Group{}, err
+			}
+			g.ParentID = ug.ParentID
+		}
+	}
+
+	g.UpdatedAt = time.Now().Truncate(time.Microsecond)
+	if err := b.store.Update(ctx, g); err != nil {
+		return Group{}, fmt.Errorf("update: %w", err)
+	}
+
+	return g, nil
+}
+
+// Delete removes the group and its memberships, groups with subgroups can not be deleted.
+func (b *Bus) Delete(ctx context.Context, g Group) error {
+	if err := b.store.Delete(ctx, g); err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+
+	return nil
+}
+
+func (b *Bus) QueryByID(ctx context.Context, id uuid.UUID) (Group, error) {
+	g, err := b.store.QueryByID(ctx, id)
+	if err != nil {
+		return Group{}, fmt.Errorf("queryByID: %w", err)
+	}
+
+	return g, nil
+}
+
+// Query returns every group, clients build the tree from the parent ids.
+func (b *Bus) Query(ctx context.Context) ([]Group, error) {
+	gs, err := b.store.Query(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return gs, nil
+}
+
+// QueryByUser returns the groups the user was added to directly.
+func (b *Bus) QueryByUser(ctx context.Context, userID uuid.UUID) ([]Group, error) {
+	gs, err := b.store.QueryByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("queryByUser: %w", err)
+	}
+
+	return gs, nil
+}
+
+// Roles returns the roles a user gets from their groups and all of their ancestors.
+func (b *Bus) Roles(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	roles, err := b.store.QueryRoles(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("queryRoles: %w", err)
+	}
+
+	return roles, nil
+}
+
+// InheritedRoles returns the roles members of the group get, those of the group and of all of
+// its ancestors.
+func (b *Bus) InheritedRoles(ctx context.Context, id uuid.UUID) ([]string, error) {
+	ancestors, err := b.store.QueryAncestors(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("queryAncestors: %w", err)
+	}
+
+	if len(ancestors) == 0 {
+		return nil, ErrGroupNotFound
+	}
+
+	var roles []string
+	for _, a := range ancestors {
+		roles = append(roles, a.Roles...)
+	}
+
+	return roles, nil
+}
+
+// ==============================================================================
+
+func (b *Bus) AddMember(ctx context.Context, g Group, userID uuid.UUID) (Member, error) {
+	m := Member{
+		GroupID:   g.ID,
+		UserID:    userID,
+		CreatedAt: time.Now().Truncate(time.Microsecond),
+	}
+
+	if err := b.store.CreateMember(ctx, m); err != nil {
+		return Member{}, fmt.Errorf("createMember: %w", err)
+	}
+
+	return m, nil
+}
+
+func (b *Bus) RemoveMember(ctx context.Context, m Member) error {
+	if err := b.store.DeleteMember(ctx, m); err != nil {
+		return fmt.Errorf("deleteMember: %w", err)
+	}
+
+	return nil
+}
+
+func (b *Bus) QueryMember(ctx context.Context, groupID uuid.UUID, userID uuid.UUID) (Member, error) {
+	m, err := b.store.QueryMember(ctx, groupID, userID)
+	if err != nil {
+		return Member{}, fmt.Errorf("queryMember: %w", err)
+	}
+
+	return m, nil
+}
+
+// QueryMembers returns the direct members of the group, members of subgroups can be found by
+// filtering users on the group.
+func (b *Bus) QueryMembers(ctx context.Context, groupID uuid.UUID) ([]Member, error) {
+	ms, err := b.store.QueryMembers(ctx, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("queryMembers: %w", err)
+	}
+
+	return ms, nil
+}
+
+// ==============================================================================
+
+func (b *Bus) validateRoles(ctx context.Context, roles []string) error {
+	if b.roles == nil || len(roles) == 0 {
+		return nil
+	}
+
+	if err := b.roles.Validate(ctx, roles); err != nil {
+		return fmt.Errorf("validateRoles: %w", err)
+	}
+
+	return nil
+}
+
+// checkCycle makes sure g is not the new parent or one of its ancestors.
+func (b *Bus) checkCycle(ctx context.Context, g Group, parentID uuid.UUID) error {
+	ancestors, err := b.store.QueryAncestors(ctx, parentID)
+	if err != nil {
+		return fmt.Errorf("queryAncestors: %w", err)
+	}
+
+	if len(ancestors) == 0 {
+		return ErrParentNotFound
+	}
+
+	for _, a := range ancestors {
+		if a.ID == g.ID {
+			return ErrCycle
+		}
+	}
+
+	return nil
+}
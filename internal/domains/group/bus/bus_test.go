@@ -0,0 +1,216 @@
+package bus_test
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/mail"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/hamidoujand/jumble/internal/dbtest"
+	"github.com/hamidoujand/jumble/internal/domains/group/bus"
+	"github.com/hamidoujand/jumble/internal/domains/group/store/groupdb"
+	roleBus "github.com/hamidoujand/jumble/internal/domains/role/bus"
+	"github.com/hamidoujand/jumble/internal/domains/role/store/roledb"
+	userBus "github.com/hamidoujand/jumble/internal/domains/user/bus"
+	"github.com/hamidoujand/jumble/internal/domains/user/store/userdb"
+	"github.com/hamidoujand/jumble/internal/page"
+	"github.com/hamidoujand/jumble/pkg/docker"
+	"github.com/hamidoujand/jumble/pkg/telemetry"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var container docker.Container
+var tracer trace.Tracer
+
+func TestMain(m *testing.M) {
+	// before all
+	var err error
+	container, err = dbtest.CreateDBContainer()
+	if err != nil {
+		log.Fatalf("createDBContainer: %s", err)
+	}
+
+	defer docker.StopContainer(container.Name)
+	cfg := telemetry.Config{
+		ServiceName: "group_bus_test",
+		Host:        "",
+		Build:       "v0.0.1",
+	}
+
+	cleanup, err := telemetry.SetupOTelSDK(cfg)
+	if err != nil {
+		log.Fatalf("setupOTelSDK: %s", err)
+	}
+
+	tracer = otel.Tracer("group_bus_tests")
+
+	defer cleanup(context.Background())
+
+	// tests
+	os.Exit(m.Run())
+
+}
+
+func Test_Groups(t *testing.T) {
+	t.Parallel()
+
+	db := dbtest.New(t, container, "groups")
+	rb := roleBus.New(roledb.NewStore(db, tracer))
+	b := bus.New(groupdb.NewStore(db, tracer), bus.WithRoleValidator(rb))
+	usrBus := userBus.New(userdb.NewStore(db, tracer))
+
+	support, err := rb.Create(context.Background(), roleBus.NewRole{
+		Name:        "support",
+		Permissions: []string{roleBus.PermUsersUnlock},
+	})
+	if err != nil {
+		t.Fatalf("failed to create a role: %s", err)
+	}
+
+	roles, err := userBus.ParseManyRoles([]string{"user"})
+	if err != nil {
+		t.Fatalf("failed to parse roles: %s", err)
+	}
+
+	newUser := func(name string, email string) userBus.User {
+		usr, err := usrBus.Create(context.Background(), userBus.NewUser{
+			Name:       name,
+			Email:      mail.Address{Name: name, Address: email},
+			Roles:      roles,
+			Department: "sales",
+			Password:   "test1234",
+		})
+		if err != nil {
+			t.Fatalf("failed to create a user: %s", err)
+		}
+		return usr
+	}
+
+	john := newUser("John Doe", "john@gmail.com")
+	jane := newUser("Jane Doe", "jane@gmail.com")
+	newUser("Bob Smith", "bob@gmail.com")
+
+	if _, err := b.Create(context.Background(), bus.NewGroup{Name: "Helpdesk", Roles: []string{"auditor"}}); !errors.Is(err, roleBus.ErrRoleNotFound) {
+		t.Errorf("err=%s, got=%v", roleBus.ErrRoleNotFound, err)
+	}
+
+	eng, err := b.Create(context.Background(), bus.NewGroup{Name: "Engineering", Roles: []string{support.Name}})
+	if err != nil {
+		t.Fatalf("failed to create a group: %s", err)
+	}
+
+	if _, err := b.Create(context.Background(), bus.NewGroup{Name: "Engineering"}); !errors.Is(err, bus.ErrDuplicatedGroup) {
+		t.Errorf("err=%s, got=%v", bus.ErrDuplicatedGroup, err)
+	}
+
+	platform, err := b.Create(context.Background(), bus.NewGroup{Name: "Platform", ParentID: &eng.ID})
+	if err != nil {
+		t.Fatalf("failed to create a subgroup: %s", err)
+	}
+
+	inherited, err := b.InheritedRoles(context.Background(), platform.ID)
+	if err != nil {
+		t.Fatalf("failed to query inherited roles: %s", err)
+	}
+
+	if !slices.Equal(inherited, []string{support.Name}) {
+		t.Errorf("roles=%v, got=%v", []string{support.Name}, inherited)
+	}
+
+	//a group can not end up under its own subgroup.
+	if _, err := b.Update(context.Background(), eng, bus.UpdateGroup{ParentID: &platform.ID}); !errors.Is(err, bus.ErrCycle) {
+		t.Errorf("err=%s, got=%v", bus.ErrCycle, err)
+	}
+
+	if _, err := b.AddMember(context.Background(), platform, jane.ID); err != nil {
+		t.Fatalf("failed to add a member: %s", err)
+	}
+
+	if _, err := b.AddMember(context.Background(), platform, jane.ID); !errors.Is(err, bus.ErrDuplicatedMember) {
+		t.Errorf("err=%s, got=%v", bus.ErrDuplicatedMember, err)
+	}
+
+	if _, err := b.AddMember(context.Background(), eng, john.ID); err != nil {
+		t.Fatalf("failed to add a member: %s", err)
+	}
+
+	//members of a subgroup get the roles of its ancestors.
+	got, err := b.Roles(context.Background(), jane.ID)
+	if err != nil {
+		t.Fatalf("failed to query roles: %s", err)
+	}
+
+	if !slices.Equal(got, []string{support.Name}) {
+		t.Errorf("roles=%v, got=%v", []string{support.Name}, got)
+	}
+
+	//filtering on a group includes the members of its subgroups.
+	orderBy, err := userBus.ParseOrderBy("name,asc")
+	if err != nil {
+		t.Fatalf("expected to parse order by clause: %s", err)
+	}
+
+	p, err := page.Parse("1", "10")
+	if err != nil {
+		t.Fatalf("expected to parse page: %s", err)
+	}
+
+	users, err := usrBus.Query(context.Background(), userBus.QueryFilter{Groups: []uuid.UUID{eng.ID}}, orderBy, p)
+	if err != nil {
+		t.Fatalf("failed to query users of the group: %s", err)
+	}
+
+	if len(users) != 2 {
+		t.Errorf("expected 2 users in the group, got=%d", len(users))
+	}
+
+	users, err = usrBus.Query(context.Background(), userBus.QueryFilter{Groups: []uuid.UUID{platform.ID}}, orderBy, p)
+	if err != nil {
+		t.Fatalf("failed to query users of the subgroup: %s", err)
+	}
+
+	if len(users) != 1 || users[0].ID != jane.ID {
+		t.Errorf("expected only %s in the subgroup, got=%v", jane.ID, users)
+	}
+
+	//roles handed out by a group are in use.
+	if err := rb.Delete(context.Background(), support); !errors.Is(err, roleBus.ErrRoleInUse) {
+		t.Errorf("err=%s, got=%v", roleBus.ErrRoleInUse, err)
+	}
+
+	if err := b.Delete(context.Background(), eng); !errors.Is(err, bus.ErrHasSubgroups) {
+		t.Errorf("err=%s, got=%v", bus.ErrHasSubgroups, err)
+	}
+
+	top := uuid.Nil
+	platform, err = b.Update(context.Background(), platform, bus.UpdateGroup{ParentID: &top})
+	if err != nil {
+		t.Fatalf("failed to move the subgroup: %s", err)
+	}
+
+	if platform.ParentID != nil {
+		t.Errorf("expected the group to be at the top, got parent=%s", platform.ParentID)
+	}
+
+	got, err = b.Roles(context.Background(), jane.ID)
+	if err != nil {
+		t.Fatalf("failed to query roles: %s", err)
+	}
+
+	if len(got) != 0 {
+		t.Errorf("expected no roles after leaving the tree, got=%v", got)
+	}
+
+	if err := b.Delete(context.Background(), eng); err != nil {
+		t.Fatalf("failed to delete the group: %s", err)
+	}
+
+	if _, err := b.QueryMember(context.Background(), eng.ID, john.ID); !errors.Is(err, bus.ErrMemberNotFound) {
+		t.Errorf("err=%s, got=%v", bus.ErrMemberNotFound, err)
+	}
+}
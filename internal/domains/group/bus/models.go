@@ -0,0 +1,41 @@
+package bus
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Group is a team of users, it may be nested under a parent group. Members of a group are
+// members of all of its ancestors and get the roles of all of them.
+type Group struct {
+	ID          uuid.UUID
+	Name        string
+	Description string
+	ParentID    *uuid.UUID
+	Roles       []string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type NewGroup struct {
+	Name        string
+	Description string
+	ParentID    *uuid.UUID
+	Roles       []string
+}
+
+// UpdateGroup changes the fields that are set, a ParentID of uuid.Nil moves the group to the top.
+type UpdateGroup struct {
+	Name        *string
+	Description *string
+	ParentID    *uuid.UUID
+	Roles       []string
+}
+
+// Member is a user added to a group directly.
+type Member struct {
+	GroupID   uuid.UUID
+	UserID    uuid.UUID
+	CreatedAt time.Time
+}
@@ -0,0 +1,368 @@
+// Package handler provides endpoints to manage groups and their members.
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	groupBus "github.com/hamidoujand/jumble/internal/domains/group/bus"
+	roleBus "github.com/hamidoujand/jumble/internal/domains/role/bus"
+	userBus "github.com/hamidoujand/jumble/internal/domains/user/bus"
+	"github.com/hamidoujand/jumble/internal/errs"
+	"github.com/hamidoujand/jumble/internal/mid"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type handler struct {
+	groupBus *groupBus.Bus
+	userBus  *userBus.Bus
+	roleBus  *roleBus.Bus
+	tracer   trace.Tracer
+}
+
+func (h *handler) CreateGroup(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "group.handler.createGroup")
+	defer span.End()
+
+	var ng newGroup
+	if err := c.ShouldBindJSON(&ng); err != nil {
+		c.Error(err)
+		return
+	}
+
+	busGroup, err := toBusNewGroup(ng)
+	if err != nil {
+		c.Error(errs.New(http.StatusBadRequest, "%s", err))
+		return
+	}
+
+	if !h.canAssignRoles(c, busGroup.Roles) {
+		return
+	}
+
+	if !h.canGrant(ctx, c, busGroup.Roles, busGroup.ParentID) {
+		return
+	}
+
+	g, err := h.groupBus.Create(ctx, busGroup)
+	if errors.Is(err, groupBus.ErrDuplicatedGroup) || errors.Is(err, groupBus.ErrParentNotFound) || errors.Is(err, roleBus.ErrRoleNotFound) {
+		c.Error(errs.New(http.StatusBadRequest, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "create: %s", err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, toAppGroup(g))
+}
+
+func (h *handler) UpdateGroup(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "group.handler.updateGroup")
+	defer span.End()
+
+	var ug updateGroup
+	if err := c.ShouldBindJSON(&ug); err != nil {
+		c.Error(err)
+		return
+	}
+
+	busGroup, err := toBusUpdateGroup(ug)
+	if err != nil {
+		c.Error(errs.New(http.StatusBadRequest, "%s", err))
+		return
+	}
+
+	if !h.canAssignRoles(c, busGroup.Roles) {
+		return
+	}
+
+	g, ok := h.loadGroup(ctx, c)
+	if !ok {
+		return
+	}
+
+	//members get the new roles or those of a new parent, either way they are handed out here.
+	if busGroup.Roles != nil || busGroup.ParentID != nil {
+		roles := g.Roles
+		if busGroup.Roles != nil {
+			roles = busGroup.Roles
+		}
+
+		parentID := g.ParentID
+		if busGroup.ParentID != nil {
+			parentID = busGroup.ParentID
+			if *parentID == uuid.Nil {
+				parentID = nil
+			}
+		}
+
+		if !h.canGrant(ctx, c, roles, parentID) {
+			return
+		}
+	}
+
+	updated, err := h.groupBus.Update(ctx, g, busGroup)
+	if errors.Is(err, groupBus.ErrDuplicatedGroup) || errors.Is(err, groupBus.ErrParentNotFound) ||
+		errors.Is(err, groupBus.ErrCycle) || errors.Is(err, roleBus.ErrRoleNotFound) {
+		c.Error(errs.New(http.StatusBadRequest, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "update: %s", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, toAppGroup(updated))
+}
+
+func (h *handler) DeleteGroup(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "group.handler.deleteGroup")
+	defer span.End()
+
+	g, ok := h.loadGroup(ctx, c)
+	if !ok {
+		return
+	}
+
+	err := h.groupBus.Delete(ctx, g)
+	if errors.Is(err, groupBus.ErrHasSubgroups) {
+		c.Error(errs.New(http.StatusConflict, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "delete: %s", err))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *handler) QueryGroupByID(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "group.handler.queryGroupByID")
+	defer span.End()
+
+	g, ok := h.loadGroup(ctx, c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, toAppGroup(g))
+}
+
+func (h *handler) QueryGroups(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "group.handler.queryGroups")
+	defer span.End()
+
+	gs, err := h.groupBus.Query(ctx)
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "query: %s", err))
+		return
+	}
+
+	apps := make([]group, len(gs))
+	for i, g := range gs {
+		apps[i] = toAppGroup(g)
+	}
+
+	c.JSON(http.StatusOK, apps)
+}
+
+// ==============================================================================
+
+func (h *handler) QueryMembers(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "group.handler.queryMembers")
+	defer span.End()
+
+	g, ok := h.loadGroup(ctx, c)
+	if !ok {
+		return
+	}
+
+	ms, err := h.groupBus.QueryMembers(ctx, g.ID)
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "queryMembers: %s", err))
+		return
+	}
+
+	apps := make([]member, len(ms))
+	for i, m := range ms {
+		apps[i] = toAppMember(m)
+	}
+
+	c.JSON(http.StatusOK, apps)
+}
+
+func (h *handler) AddMember(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "group.handler.addMember")
+	defer span.End()
+
+	var nm newMember
+	if err := c.ShouldBindJSON(&nm); err != nil {
+		c.Error(err)
+		return
+	}
+
+	userID, err := uuid.Parse(nm.UserID)
+	if err != nil {
+		c.Error(errs.New(http.StatusBadRequest, "invalid user id: %s", nm.UserID))
+		return
+	}
+
+	g, ok := h.loadGroup(ctx, c)
+	if !ok {
+		return
+	}
+
+	usr, err := h.userBus.QueryByID(ctx, userID)
+	if errors.Is(err, userBus.ErrUserNotFound) {
+		c.Error(errs.New(http.StatusBadRequest, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "queryByID: %s", err))
+		return
+	}
+
+	//the new member gets the roles of the group and its ancestors.
+	if !h.canGrant(ctx, c, g.Roles, g.ParentID) {
+		return
+	}
+
+	m, err := h.groupBus.AddMember(ctx, g, usr.ID)
+	if errors.Is(err, groupBus.ErrDuplicatedMember) {
+		c.Error(errs.New(http.StatusConflict, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "addMember: %s", err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, toAppMember(m))
+}
+
+func (h *handler) RemoveMember(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "group.handler.removeMember")
+	defer span.End()
+
+	g, ok := h.loadGroup(ctx, c)
+	if !ok {
+		return
+	}
+
+	p := c.Param("userId")
+
+	userID, err := uuid.Parse(p)
+	if err != nil {
+		c.Error(errs.New(http.StatusBadRequest, "invalid user id: %s", p))
+		return
+	}
+
+	m, err := h.groupBus.QueryMember(ctx, g.ID, userID)
+	if errors.Is(err, groupBus.ErrMemberNotFound) {
+		c.Error(errs.New(http.StatusNotFound, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "queryMember: %s", err))
+		return
+	}
+
+	if err := h.groupBus.RemoveMember(ctx, m); err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "removeMember: %s", err))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ==============================================================================
+
+// canAssignRoles makes sure only users who can assign roles directly can hand them out through a
+// group, when they can not the error is already set on the context.
+func (h *handler) canAssignRoles(c *gin.Context, roles []string) bool {
+	if len(roles) == 0 {
+		return true
+	}
+
+	ps, err := mid.Permissions(c, h.roleBus)
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "permissions: %s", err))
+		return false
+	}
+
+	if !ps.Has(roleBus.PermUsersRolesAssign) {
+		c.Error(errs.New(http.StatusForbidden, "assigning roles needs the %s permission", roleBus.PermUsersRolesAssign))
+		return false
+	}
+
+	return true
+}
+
+// canGrant makes sure members of a group with the roles, nested under parentID when set, get no
+// permission the authenticated user lacks. When they would the error is already set on the
+// context.
+func (h *handler) canGrant(ctx context.Context, c *gin.Context, roles []string, parentID *uuid.UUID) bool {
+	if parentID != nil {
+		inherited, err := h.groupBus.InheritedRoles(ctx, *parentID)
+		if errors.Is(err, groupBus.ErrGroupNotFound) {
+			c.Error(errs.New(http.StatusBadRequest, "%s", groupBus.ErrParentNotFound))
+			return false
+		}
+
+		if err != nil {
+			c.Error(errs.New(http.StatusInternalServerError, "inheritedRoles: %s", err))
+			return false
+		}
+
+		roles = append(slices.Clone(roles), inherited...)
+	}
+
+	ok, err := mid.CanGrant(c, h.roleBus, roles)
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "canGrant: %s", err))
+		return false
+	}
+
+	if !ok {
+		c.Error(errs.New(http.StatusForbidden, "group roles can not grant permissions you do not have"))
+		return false
+	}
+
+	return true
+}
+
+// loadGroup fetches the group in the path, when it fails the error is already set on the context.
+func (h *handler) loadGroup(ctx context.Context, c *gin.Context) (groupBus.Group, bool) {
+	p := c.Param("id")
+
+	groupID, err := uuid.Parse(p)
+	if err != nil {
+		c.Error(errs.New(http.StatusBadRequest, "invalid id: %s", p))
+		return groupBus.Group{}, false
+	}
+
+	g, err := h.groupBus.QueryByID(ctx, groupID)
+	if errors.Is(err, groupBus.ErrGroupNotFound) {
+		c.Error(errs.New(http.StatusNotFound, "%s", err))
+		return groupBus.Group{}, false
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "queryByID: %s", err))
+		return groupBus.Group{}, false
+	}
+
+	return g, true
+}
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	groupBus "github.com/hamidoujand/jumble/internal/domains/group/bus"
+)
+
+type group struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	ParentID    *string  `json:"parentId"`
+	Roles       []string `json:"roles"`
+	CreatedAt   string   `json:"createdAt"`
+	UpdatedAt   string   `json:"updatedAt"`
+}
+
+func toAppGroup(g groupBus.Group) group {
+	var parentID *string
+	if g.ParentID != nil {
+		id := g.ParentID.String()
+		parentID = &id
+	}
+
+	return group{
+		ID:          g.ID.String(),
+		Name:        g.Name,
+		Description: g.Description,
+		ParentID:    parentID,
+		Roles:       g.Roles,
+		CreatedAt:   g.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:   g.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+type member struct {
+	GroupID   string `json:"groupId"`
+	UserID    string `json:"userId"`
+	CreatedAt string `json:"createdAt"`
+}
+
+func toAppMember(m groupBus.Member) member {
+	return member{
+		GroupID:   m.GroupID.String(),
+		UserID:    m.UserID.String(),
+		CreatedAt: m.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+// ==============================================================================
+
+type newGroup struct {
+	Name        string   `json:"name" binding:"required,max=120"`
+	Description string   `json:"description" binding:"max=400"`
+	ParentID    *string  `json:"parentId" binding:"omitempty,uuid"`
+	Roles       []string `json:"roles" binding:"omitempty,dive,required,max=32"`
+}
+
+func toBusNewGroup(ng newGroup) (groupBus.NewGroup, error) {
+	parentID, err := parseParentID(ng.ParentID)
+	if err != nil {
+		return groupBus.NewGroup{}, err
+	}
+
+	return groupBus.NewGroup{
+		Name:        ng.Name,
+		Description: ng.Description,
+		ParentID:    parentID,
+		Roles:       ng.Roles,
+	}, nil
+}
+
+// updateGroup moves the group to the top when parentId is an empty string.
+type updateGroup struct {
+	Name        *string  `json:"name" binding:"omitempty,min=1,max=120"`
+	Description *string  `json:"description" binding:"omitempty,max=400"`
+	ParentID    *string  `json:"parentId" binding:"omitempty,uuid|len=0"`
+	Roles       []string `json:"roles" binding:"omitempty,dive,required,max=32"`
+}
+
+func toBusUpdateGroup(ug updateGroup) (groupBus.UpdateGroup, error) {
+	var parentID *uuid.UUID
+	if ug.ParentID != nil {
+		if *ug.ParentID == "" {
+			top := uuid.Nil
+			parentID = &top
+		} else {
+			id, err := parseParentID(ug.ParentID)
+			if err != nil {
+				return groupBus.UpdateGroup{}, err
+			}
+			parentID = id
+		}
+	}
+
+	return groupBus.UpdateGroup{
+		Name:        ug.Name,
+		Description: ug.Description,
+		ParentID:    parentID,
+		Roles:       ug.Roles,
+	}, nil
+}
+
+func parseParentID(p *string) (*uuid.UUID, error) {
+	if p == nil {
+		return nil, nil
+	}
+
+	id, err := uuid.Parse(*p)
+	if err != nil {
+		return nil, fmt.Errorf("invalid parent id: %s", *p)
+	}
+
+	return &id, nil
+}
+
+type newMember struct {
+	UserID string `json:"userId" binding:"required,uuid"`
+}
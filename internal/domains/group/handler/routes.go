@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/hamidoujand/jumble/internal/auth"
+	groupBus "github.com/hamidoujand/jumble/internal/domains/group/bus"
+	roleBus "github.com/hamidoujand/jumble/internal/domains/role/bus"
+	userBus "github.com/hamidoujand/jumble/internal/domains/user/bus"
+	"github.com/hamidoujand/jumble/internal/mid"
+	"github.com/hamidoujand/jumble/pkg/logger"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type Conf struct {
+	Router   *gin.Engine
+	AuthConf mid.AuthConf
+	GroupBus *groupBus.Bus
+	RoleBus  *roleBus.Bus
+	UserBus  *userBus.Bus
+	Tracer   trace.Tracer
+	Logger   *logger.Logger
+}
+
+// RegisterRoutes takes the mux and register endpoints on it.
+func RegisterRoutes(cfg Conf) {
+	h := handler{
+		groupBus: cfg.GroupBus,
+		userBus:  cfg.UserBus,
+		roleBus:  cfg.RoleBus,
+		tracer:   cfg.Tracer,
+	}
+
+	authenticated := mid.Authenticate(cfg.AuthConf)
+
+	api := mid.RequireScope(auth.ScopeAPI)
+
+	can := func(perms ...string) gin.HandlerFunc {
+		return mid.RequirePermission(cfg.Logger, cfg.RoleBus, perms...)
+	}
+
+	//groups span every org, so org scoped tokens can not see them.
+	groups := cfg.Router.Group("/v1/groups", authenticated, api, mid.GlobalScope())
+
+	groups.GET("/", can(roleBus.PermGroupsRead), h.QueryGroups)
+	groups.GET("/:id", can(roleBus.PermGroupsRead), h.QueryGroupByID)
+	groups.POST("/", mid.NotImpersonated(), can(roleBus.PermGroupsWrite), h.CreateGroup)
+	groups.PUT("/:id", mid.NotImpersonated(), can(roleBus.PermGroupsWrite), h.UpdateGroup)
+	groups.DELETE("/:id", can(roleBus.PermGroupsWrite), h.DeleteGroup)
+	groups.GET("/:id/members", can(roleBus.PermGroupsRead), h.QueryMembers)
+	groups.POST("/:id/members", mid.NotImpersonated(), can(roleBus.PermGroupsMembersWrite), h.AddMember)
+	groups.DELETE("/:id/members/:userId", mid.NotImpersonated(), can(roleBus.PermGroupsMembersWrite), h.RemoveMember)
+}
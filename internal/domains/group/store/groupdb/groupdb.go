@@ -0,0 +1,341 @@
+package groupdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	groupBus "github.com/hamidoujand/jumble/internal/domains/group/bus"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+)
+
+type Store struct {
+	db     *sqlx.DB
+	tracer trace.Tracer
+}
+
+func NewStore(db *sqlx.DB, tracer trace.Tracer) *Store {
+	return &Store{
+		db:     db,
+		tracer: tracer,
+	}
+}
+
+func (s *Store) Create(ctx context.Context, g groupBus.Group) error {
+	const q = `
+	INSERT INTO groups (id,name,description,parent_id,roles,created_at,updated_at)
+	VALUES (:id,:name,:description,:parent_id,:roles,:created_at,:updated_at)
+	`
+
+	ctx, span := s.tracer.Start(ctx, "group.store.create")
+	defer span.End()
+
+	if _, err := s.db.NamedExecContext(ctx, q, fromBusGroup(g)); err != nil {
+		return mapError(err)
+	}
+
+	return nil
+}
+
+func (s *Store) Update(ctx context.Context, g groupBus.Group) error {
+	const q = `
+	UPDATE groups 
+	SET 
+		name = :name,
+		description = :description,
+		parent_id = :parent_id,
+		roles = :roles,
+		updated_at = :updated_at
+	WHERE 
+		id = :id
+	`
+
+	ctx, span := s.tracer.Start(ctx, "group.store.update")
+	defer span.End()
+
+	if _, err := s.db.NamedExecContext(ctx, q, fromBusGroup(g)); err != nil {
+		return mapError(err)
+	}
+
+	return nil
+}
+
+func (s *Store) Delete(ctx context.Context, g groupBus.Group) error {
+	const q = `DELETE FROM groups WHERE id = :id`
+
+	ctx, span := s.tracer.Start(ctx, "group.store.delete")
+	defer span.End()
+
+	if _, err := s.db.NamedExecContext(ctx, q, fromBusGroup(g)); err != nil {
+		var pgerror *pgconn.PgError
+		if errors.As(err, &pgerror) && pgerror.Code == foreignKeyViolation {
+			return groupBus.ErrHasSubgroups
+		}
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) QueryByID(ctx context.Context, id uuid.UUID) (groupBus.Group, error) {
+	data := map[string]any{
+		"id": id,
+	}
+
+	const q = `SELECT * FROM groups WHERE id = :id`
+
+	ctx, span := s.tracer.Start(ctx, "group.store.queryByID")
+	defer span.End()
+
+	gs, err := s.query(ctx, q, data)
+	if err != nil {
+		return groupBus.Group{}, err
+	}
+
+	if len(gs) == 0 {
+		return groupBus.Group{}, groupBus.ErrGroupNotFound
+	}
+
+	return gs[0], nil
+}
+
+func (s *Store) Query(ctx context.Context) ([]groupBus.Group, error) {
+	const q = `SELECT * FROM groups ORDER BY name`
+
+	ctx, span := s.tracer.Start(ctx, "group.store.query")
+	defer span.End()
+
+	return s.query(ctx, q, map[string]any{})
+}
+
+// QueryAncestors returns the group followed by its parent, the parent of its parent and so on.
+func (s *Store) QueryAncestors(ctx context.Context, id uuid.UUID) ([]groupBus.Group, error) {
+	data := map[string]any{
+		"id": id,
+	}
+
+	const q = `
+	WITH RECURSIVE ancestors AS (
+		SELECT g.*, 0 AS depth FROM groups g WHERE g.id = :id
+		UNION ALL
+		SELECT p.*, a.depth + 1 FROM groups p JOIN ancestors a ON p.id = a.parent_id
+	)
+	SELECT id,name,description,parent_id,roles,created_at,updated_at FROM ancestors ORDER BY depth
+	`
+
+	ctx, span := s.tracer.Start(ctx, "group.store.queryAncestors")
+	defer span.End()
+
+	return s.query(ctx, q, data)
+}
+
+// QueryByUser returns the groups the user is a direct member of.
+func (s *Store) QueryByUser(ctx context.Context, userID uuid.UUID) ([]groupBus.Group, error) {
+	data := map[string]any{
+		"user_id": userID,
+	}
+
+	const q = `
+	SELECT g.* FROM groups g 
+	JOIN group_members m ON m.group_id = g.id 
+	WHERE m.user_id = :user_id 
+	ORDER BY g.name
+	`
+
+	ctx, span := s.tracer.Start(ctx, "group.store.queryByUser")
+	defer span.End()
+
+	return s.query(ctx, q, data)
+}
+
+// QueryRoles returns the distinct roles of the groups of the user and all of their ancestors.
+func (s *Store) QueryRoles(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	data := map[string]any{
+		"user_id": userID,
+	}
+
+	const q = `
+	WITH RECURSIVE chain AS (
+		SELECT g.id, g.parent_id, g.roles FROM groups g 
+		JOIN group_members m ON m.group_id = g.id 
+		WHERE m.user_id = :user_id
+		UNION
+		SELECT p.id, p.parent_id, p.roles FROM groups p JOIN chain c ON p.id = c.parent_id
+	)
+	SELECT DISTINCT unnest(roles) AS role FROM chain ORDER BY role
+	`
+
+	ctx, span := s.tracer.Start(ctx, "group.store.queryRoles")
+	defer span.End()
+
+	rows, err := s.db.NamedQueryContext(ctx, q, data)
+	if err != nil {
+		return nil, fmt.Errorf("namedQueryContext: %w", err)
+	}
+
+	defer rows.Close()
+
+	var roles []string
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		roles = append(roles, role)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("preparing next row to scan: %w", err)
+	}
+
+	return roles, nil
+}
+
+// ==============================================================================
+
+func (s *Store) CreateMember(ctx context.Context, m groupBus.Member) error {
+	const q = `
+	INSERT INTO group_members (group_id,user_id,created_at)
+	VALUES (:group_id,:user_id,:created_at)
+	`
+
+	ctx, span := s.tracer.Start(ctx, "group.store.createMember")
+	defer span.End()
+
+	if _, err := s.db.NamedExecContext(ctx, q, fromBusMember(m)); err != nil {
+		var pgerror *pgconn.PgError
+		if errors.As(err, &pgerror) {
+			switch pgerror.Code {
+			case uniqueViolation:
+				return groupBus.ErrDuplicatedMember
+			case foreignKeyViolation:
+				return groupBus.ErrMemberNotFound
+			}
+		}
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) DeleteMember(ctx context.Context, m groupBus.Member) error {
+	const q = `DELETE FROM group_members WHERE group_id = :group_id AND user_id = :user_id`
+
+	ctx, span := s.tracer.Start(ctx, "group.store.deleteMember")
+	defer span.End()
+
+	if _, err := s.db.NamedExecContext(ctx, q, fromBusMember(m)); err != nil {
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) QueryMember(ctx context.Context, groupID uuid.UUID, userID uuid.UUID) (groupBus.Member, error) {
+	data := map[string]any{
+		"group_id": groupID,
+		"user_id":  userID,
+	}
+
+	const q = `SELECT * FROM group_members WHERE group_id = :group_id AND user_id = :user_id`
+
+	ctx, span := s.tracer.Start(ctx, "group.store.queryMember")
+	defer span.End()
+
+	ms, err := s.queryMembers(ctx, q, data)
+	if err != nil {
+		return groupBus.Member{}, err
+	}
+
+	if len(ms) == 0 {
+		return groupBus.Member{}, groupBus.ErrMemberNotFound
+	}
+
+	return ms[0], nil
+}
+
+func (s *Store) QueryMembers(ctx context.Context, groupID uuid.UUID) ([]groupBus.Member, error) {
+	data := map[string]any{
+		"group_id": groupID,
+	}
+
+	const q = `SELECT * FROM group_members WHERE group_id = :group_id ORDER BY created_at`
+
+	ctx, span := s.tracer.Start(ctx, "group.store.queryMembers")
+	defer span.End()
+
+	return s.queryMembers(ctx, q, data)
+}
+
+// ==============================================================================
+
+func (s *Store) query(ctx context.Context, q string, data map[string]any) ([]groupBus.Group, error) {
+	rows, err := s.db.NamedQueryContext(ctx, q, data)
+	if err != nil {
+		return nil, fmt.Errorf("namedQueryContext: %w", err)
+	}
+
+	defer rows.Close()
+
+	var gs []groupBus.Group
+	for rows.Next() {
+		var g group
+		if err := rows.StructScan(&g); err != nil {
+			return nil, fmt.Errorf("structScan: %w", err)
+		}
+		gs = append(gs, toBusGroup(g))
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("preparing next row to scan: %w", err)
+	}
+
+	return gs, nil
+}
+
+func (s *Store) queryMembers(ctx context.Context, q string, data map[string]any) ([]groupBus.Member, error) {
+	rows, err := s.db.NamedQueryContext(ctx, q, data)
+	if err != nil {
+		return nil, fmt.Errorf("namedQueryContext: %w", err)
+	}
+
+	defer rows.Close()
+
+	var ms []groupBus.Member
+	for rows.Next() {
+		var m member
+		if err := rows.StructScan(&m); err != nil {
+			return nil, fmt.Errorf("structScan: %w", err)
+		}
+		ms = append(ms, toBusMember(m))
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("preparing next row to scan: %w", err)
+	}
+
+	return ms, nil
+}
+
+// mapError turns constraint violations on groups into the errors of the bus.
+func mapError(err error) error {
+	var pgerror *pgconn.PgError
+	if errors.As(err, &pgerror) {
+		switch pgerror.Code {
+		case uniqueViolation:
+			return groupBus.ErrDuplicatedGroup
+		case foreignKeyViolation:
+			return groupBus.ErrParentNotFound
+		}
+	}
+
+	return fmt.Errorf("namedExecContext: %w", err)
+}
@@ -0,0 +1,65 @@
+package groupdb
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	groupBus "github.com/hamidoujand/jumble/internal/domains/group/bus"
+	"github.com/hamidoujand/jumble/internal/sqldb"
+)
+
+type group struct {
+	ID          uuid.UUID       `db:"id"`
+	Name        string          `db:"name"`
+	Description string          `db:"description"`
+	ParentID    *uuid.UUID      `db:"parent_id"`
+	Roles       sqldb.TextArray `db:"roles"`
+	CreatedAt   time.Time       `db:"created_at"`
+	UpdatedAt   time.Time       `db:"updated_at"`
+}
+
+func fromBusGroup(g groupBus.Group) group {
+	return group{
+		ID:          g.ID,
+		Name:        g.Name,
+		Description: g.Description,
+		ParentID:    g.ParentID,
+		Roles:       sqldb.TextArray(g.Roles),
+		CreatedAt:   g.CreatedAt,
+		UpdatedAt:   g.UpdatedAt,
+	}
+}
+
+func toBusGroup(g group) groupBus.Group {
+	return groupBus.Group{
+		ID:          g.ID,
+		Name:        g.Name,
+		Description: g.Description,
+		ParentID:    g.ParentID,
+		Roles:       []string(g.Roles),
+		CreatedAt:   g.CreatedAt,
+		UpdatedAt:   g.UpdatedAt,
+	}
+}
+
+type member struct {
+	GroupID   uuid.UUID `db:"group_id"`
+	UserID    uuid.UUID `db:"user_id"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+func fromBusMember(m groupBus.Member) member {
+	return member{
+		GroupID:   m.GroupID,
+		UserID:    m.UserID,
+		CreatedAt: m.CreatedAt,
+	}
+}
+
+func toBusMember(m member) groupBus.Member {
+	return groupBus.Member{
+		GroupID:   m.GroupID,
+		UserID:    m.UserID,
+		CreatedAt: m.CreatedAt,
+	}
+}
@@ -0,0 +1,214 @@
+// Package bus provides the business logic of the OAuth 2.0 authorization server, the clients
+// registered with it and the grants users give them.
+package bus
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrClientNotFound      = errors.New("client not found")
+	ErrInvalidClient       = errors.New("invalid client credentials")
+	ErrInvalidClientConfig = errors.New("invalid client configuration")
+	ErrInvalidGrant        = errors.New("grant is invalid, expired or revoked")
+	ErrInvalidScope        = errors.New("scope is not allowed for the client")
+)
+
+type store interface {
+	CreateClient(ctx context.Context, c Client) error
+	DeleteClient(ctx context.Context, c Client) error
+	QueryClientByID(ctx context.Context, id uuid.UUID) (Client, error)
+	QueryClients(ctx context.Context) ([]Client, error)
+	CreateCode(ctx context.Context, c Code) error
+	QueryCodeByHash(ctx context.Context, hash []byte) (Code, error)
+	UseCode(ctx context.Context, c Code, now time.Time) error
+	DeleteExpiredCodes(ctx context.Context, now time.Time) (int, error)
+	CreateRefreshToken(ctx context.Context, rt RefreshToken) error
+	QueryRefreshTokenByHash(ctx context.Context, hash []byte) (RefreshToken, error)
+	RevokeRefreshToken(ctx context.Context, rt RefreshToken, now time.Time) error
+	RevokeFamily(ctx context.Context, familyID uuid.UUID, now time.Time) error
+	DeleteExpiredRefreshTokens(ctx context.Context, now time.Time) (int, error)
+}
+
+type Bus struct {
+	store store
+}
+
+func New(store store) *Bus {
+	return &Bus{store: store}
+}
+
+// CreateClient registers a client, for confidential clients the plain secret is returned only once.
+func (b *Bus) CreateClient(ctx context.Context, nc NewClient) (Client, string, error) {
+	if err := validateClient(nc); err != nil {
+		return Client{}, "", err
+	}
+
+	now := time.Now().Truncate(time.Microsecond)
+
+	c := Client{
+		ID:           uuid.New(),
+		Name:         nc.Name,
+		RedirectURIs: nc.RedirectURIs,
+		GrantTypes:   nc.GrantTypes,
+		Scopes:       nc.Scopes,
+		CreatedBy:    nc.CreatedBy,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+
+	if c.RedirectURIs == nil {
+		c.RedirectURIs = []string{}
+	}
+
+	if c.Scopes == nil {
+		c.Scopes = []string{}
+	}
+
+	var secret string
+	if nc.Confidential {
+		var err error
+		secret, err = newSecret()
+		if err != nil {
+			return Client{}, "", fmt.Errorf("newSecret: %w", err)
+		}
+		c.SecretHash = hashSecret(secret)
+	}
+
+	if err := b.store.CreateClient(ctx, c); err != nil {
+		return Client{}, "", fmt.Errorf("createClient: %w", err)
+	}
+
+	return c, secret, nil
+}
+
+// DeleteClient removes the client along with every code and refresh token issued to it.
+func (b *Bus) DeleteClient(ctx context.Context, c Client) error {
+	if err := b.store.DeleteClient(ctx, c); err != nil {
+		return fmt.Errorf("deleteClient: %w", err)
+	}
+
+	return nil
+}
+
+func (b *Bus) QueryClientByID(ctx context.Context, id uuid.UUID) (Client, error) {
+	c, err := b.store.QueryClientByID(ctx, id)
+	if err != nil {
+		return Client{}, fmt.Errorf("queryClientByID: %w", err)
+	}
+
+	return c, nil
+}
+
+func (b *Bus) QueryClients(ctx context.Context) ([]Client, error) {
+	cs, err := b.store.QueryClients(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("queryClients: %w", err)
+	}
+
+	return cs, nil
+}
+
+// AuthenticateClient checks the credentials presented at the token endpoint, public clients only
+// identify themselves. Every failure is reported as ErrInvalidClient.
+func (b *Bus) AuthenticateClient(ctx context.Context, id uuid.UUID, secret string) (Client, error) {
+	c, err := b.store.QueryClientByID(ctx, id)
+	if errors.Is(err, ErrClientNotFound) {
+		return Client{}, ErrInvalidClient
+	}
+
+	if err != nil {
+		return Client{}, fmt.Errorf("queryClientByID: %w", err)
+	}
+
+	if c.Public() {
+		if secret != "" {
+			return Client{}, ErrInvalidClient
+		}
+		return c, nil
+	}
+
+	if subtle.ConstantTimeCompare(hashSecret(secret), c.SecretHash) != 1 {
+		return Client{}, ErrInvalidClient
+	}
+
+	return c, nil
+}
+
+// ==============================================================================
+
+func validateClient(nc NewClient) error {
+	if len(nc.GrantTypes) == 0 {
+		return fmt.Errorf("%w: at least one grant type is required", ErrInvalidClientConfig)
+	}
+
+	for _, g := range nc.GrantTypes {
+		switch g {
+		case GrantAuthorizationCode, GrantRefreshToken, GrantClientCredentials:
+		default:
+			return fmt.Errorf("%w: unknown grant type %q", ErrInvalidClientConfig, g)
+		}
+	}
+
+	code := slices.Contains(nc.GrantTypes, GrantAuthorizationCode)
+
+	if code && len(nc.RedirectURIs) == 0 {
+		return fmt.Errorf("%w: %s needs at least one redirect uri", ErrInvalidClientConfig, GrantAuthorizationCode)
+	}
+
+	//refresh tokens are only ever issued along with an authorization code exchange.
+	if slices.Contains(nc.GrantTypes, GrantRefreshToken) && !code {
+		return fmt.Errorf("%w: %s needs %s", ErrInvalidClientConfig, GrantRefreshToken, GrantAuthorizationCode)
+	}
+
+	if slices.Contains(nc.GrantTypes, GrantClientCredentials) && !nc.Confidential {
+		return fmt.Errorf("%w: %s is only for confidential clients", ErrInvalidClientConfig, GrantClientCredentials)
+	}
+
+	for _, uri := range nc.RedirectURIs {
+		u, err := url.Parse(uri)
+		if err != nil || u.Scheme == "" || u.Host == "" || u.Fragment != "" {
+			return fmt.Errorf("%w: redirect uri %q must be absolute and without a fragment", ErrInvalidClientConfig, uri)
+		}
+
+		//uris are stored in a TEXT[], these would need quoting there.
+		if strings.ContainsAny(uri, `,"{}\ `) {
+			return fmt.Errorf("%w: redirect uri %q must be escaped", ErrInvalidClientConfig, uri)
+		}
+	}
+
+	for _, s := range nc.Scopes {
+		if s == "" || strings.ContainsAny(s, `,"{}\ `) {
+			return fmt.Errorf("%w: invalid scope %q", ErrInvalidClientConfig, s)
+		}
+	}
+
+	return nil
+}
+
+// newSecret returns 32 random bytes, enough entropy that a fast hash is all the storage needs.
+func newSecret() (string, error) {
+	bs := make([]byte, 32)
+	if _, err := rand.Read(bs); err != nil {
+		return "", fmt.Errorf("read: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(bs), nil
+}
+
+func hashSecret(secret string) []byte {
+	sum := sha256.Sum256([]byte(secret))
+	return sum[:]
+}
@@ -0,0 +1,311 @@
+package bus_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/mail"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hamidoujand/jumble/internal/dbtest"
+	"github.com/hamidoujand/jumble/internal/domains/oauth/bus"
+	"github.com/hamidoujand/jumble/internal/domains/oauth/store/oauthdb"
+	userBus "github.com/hamidoujand/jumble/internal/domains/user/bus"
+	"github.com/hamidoujand/jumble/internal/domains/user/store/userdb"
+	"github.com/hamidoujand/jumble/pkg/docker"
+	"github.com/hamidoujand/jumble/pkg/telemetry"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var container docker.Container
+var tracer trace.Tracer
+
+func TestMain(m *testing.M) {
+	// before all
+	var err error
+	container, err = dbtest.CreateDBContainer()
+	if err != nil {
+		log.Fatalf("createDBContainer: %s", err)
+	}
+
+	defer docker.StopContainer(container.Name)
+	cfg := telemetry.Config{
+		ServiceName: "oauth_bus_test",
+		Host:        "",
+		Build:       "v0.0.1",
+	}
+
+	cleanup, err := telemetry.SetupOTelSDK(cfg)
+	if err != nil {
+		log.Fatalf("setupOTelSDK: %s", err)
+	}
+
+	tracer = otel.Tracer("oauth_bus_tests")
+
+	defer cleanup(context.Background())
+
+	// tests
+	os.Exit(m.Run())
+
+}
+
+func Test_Clients(t *testing.T) {
+	t.Parallel()
+
+	db := dbtest.New(t, container, "clients")
+	b := bus.New(oauthdb.NewStore(db, tracer))
+
+	invalid := []bus.NewClient{
+		{Name: "no redirect", GrantTypes: []string{bus.GrantAuthorizationCode}},
+		{Name: "unknown grant", GrantTypes: []string{"password"}},
+		{Name: "public machine", GrantTypes: []string{bus.GrantClientCredentials}},
+		{Name: "relative", GrantTypes: []string{bus.GrantAuthorizationCode}, RedirectURIs: []string{"/callback"}},
+		{Name: "fragment", GrantTypes: []string{bus.GrantAuthorizationCode}, RedirectURIs: []string{"https://app.example.com/cb#x"}},
+		{Name: "refresh only", GrantTypes: []string{bus.GrantRefreshToken}, Confidential: true},
+	}
+
+	for _, nc := range invalid {
+		if _, _, err := b.CreateClient(context.Background(), nc); !errors.Is(err, bus.ErrInvalidClientConfig) {
+			t.Errorf("%s: err=%s, got=%v", nc.Name, bus.ErrInvalidClientConfig, err)
+		}
+	}
+
+	web, secret, err := b.CreateClient(context.Background(), bus.NewClient{
+		Name:         "web",
+		RedirectURIs: []string{"https://app.example.com/callback"},
+		GrantTypes:   []string{bus.GrantAuthorizationCode, bus.GrantRefreshToken},
+		Scopes:       []string{bus.ScopeOpenID, bus.ScopeEmail},
+		Confidential: true,
+	})
+	if err != nil {
+		t.Fatalf("failed to create a client: %s", err)
+	}
+
+	if secret == "" || web.Public() {
+		t.Errorf("expected a confidential client to get a secret")
+	}
+
+	spa, secret, err := b.CreateClient(context.Background(), bus.NewClient{
+		Name:         "spa",
+		RedirectURIs: []string{"http://localhost:3000/callback"},
+		GrantTypes:   []string{bus.GrantAuthorizationCode},
+		Scopes:       []string{bus.ScopeOpenID},
+	})
+	if err != nil {
+		t.Fatalf("failed to create a client: %s", err)
+	}
+
+	if secret != "" || !spa.Public() {
+		t.Errorf("expected a public client to get no secret")
+	}
+
+	cs, err := b.QueryClients(context.Background())
+	if err != nil {
+		t.Fatalf("failed to query clients: %s", err)
+	}
+
+	if len(cs) != 2 {
+		t.Errorf("len(clients)=%d, got=%d", 2, len(cs))
+	}
+
+	if _, err := b.AuthenticateClient(context.Background(), spa.ID, ""); err != nil {
+		t.Errorf("expected a public client to authenticate without a secret: %s", err)
+	}
+
+	if _, err := b.AuthenticateClient(context.Background(), web.ID, ""); !errors.Is(err, bus.ErrInvalidClient) {
+		t.Errorf("err=%s, got=%v", bus.ErrInvalidClient, err)
+	}
+
+	if err := b.DeleteClient(context.Background(), spa); err != nil {
+		t.Fatalf("failed to delete a client: %s", err)
+	}
+
+	if _, err := b.QueryClientByID(context.Background(), spa.ID); !errors.Is(err, bus.ErrClientNotFound) {
+		t.Errorf("err=%s, got=%v", bus.ErrClientNotFound, err)
+	}
+}
+
+func Test_Codes(t *testing.T) {
+	t.Parallel()
+
+	db := dbtest.New(t, container, "codes")
+	b := bus.New(oauthdb.NewStore(db, tracer))
+	usr := newUser(t, userBus.New(userdb.NewStore(db, tracer)))
+
+	cl, _, err := b.CreateClient(context.Background(), bus.NewClient{
+		Name:         "spa",
+		RedirectURIs: []string{"http://localhost:3000/callback"},
+		GrantTypes:   []string{bus.GrantAuthorizationCode},
+		Scopes:       []string{bus.ScopeOpenID},
+	})
+	if err != nil {
+		t.Fatalf("failed to create a client: %s", err)
+	}
+
+	verifier := strings.Repeat("v", 43)
+	sum := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	code, err := b.CreateCode(context.Background(), bus.NewCode{
+		ClientID:      cl.ID,
+		UserID:        usr.ID,
+		RedirectURI:   cl.RedirectURIs[0],
+		Scopes:        []string{bus.ScopeOpenID},
+		Nonce:         "n-0S6_WzA2Mj",
+		CodeChallenge: challenge,
+		AMR:           []string{"pwd"},
+		AuthTime:      time.Now(),
+	}, time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create a code: %s", err)
+	}
+
+	if _, err := b.ExchangeCode(context.Background(), cl, code, cl.RedirectURIs[0], strings.Repeat("x", 43)); !errors.Is(err, bus.ErrInvalidGrant) {
+		t.Errorf("wrong verifier: err=%s, got=%v", bus.ErrInvalidGrant, err)
+	}
+
+	if _, err := b.ExchangeCode(context.Background(), cl, code, "http://localhost:3000/other", verifier); !errors.Is(err, bus.ErrInvalidGrant) {
+		t.Errorf("wrong redirect: err=%s, got=%v", bus.ErrInvalidGrant, err)
+	}
+
+	got, err := b.ExchangeCode(context.Background(), cl, code, cl.RedirectURIs[0], verifier)
+	if err != nil {
+		t.Fatalf("failed to exchange a code: %s", err)
+	}
+
+	if got.UserID != usr.ID || got.Nonce != "n-0S6_WzA2Mj" {
+		t.Errorf("code=%+v, expected the grant of user %s", got, usr.ID)
+	}
+
+	s, err := userBus.New(userdb.NewStore(db, tracer)).CreateSession(context.Background(), usr, cl.Name, "127.0.0.1", time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create a session: %s", err)
+	}
+
+	rt, err := b.CreateRefreshToken(context.Background(), got, s.ID, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create a refresh token: %s", err)
+	}
+
+	//codes are single use.
+	if _, err := b.ExchangeCode(context.Background(), cl, code, cl.RedirectURIs[0], verifier); !errors.Is(err, bus.ErrInvalidGrant) {
+		t.Errorf("reused code: err=%s, got=%v", bus.ErrInvalidGrant, err)
+	}
+
+	//replaying the code revokes the refresh tokens issued for it.
+	if _, _, err := b.RotateRefreshToken(context.Background(), cl, rt, nil); !errors.Is(err, bus.ErrInvalidGrant) {
+		t.Errorf("refresh token of a reused code: err=%s, got=%v", bus.ErrInvalidGrant, err)
+	}
+}
+
+func Test_RefreshTokens(t *testing.T) {
+	t.Parallel()
+
+	db := dbtest.New(t, container, "refresh_tokens")
+	b := bus.New(oauthdb.NewStore(db, tracer))
+	users := userBus.New(userdb.NewStore(db, tracer))
+	usr := newUser(t, users)
+
+	cl, _, err := b.CreateClient(context.Background(), bus.NewClient{
+		Name:         "web",
+		RedirectURIs: []string{"https://app.example.com/callback"},
+		GrantTypes:   []string{bus.GrantAuthorizationCode, bus.GrantRefreshToken},
+		Scopes:       []string{bus.ScopeOpenID, bus.ScopeEmail},
+		Confidential: true,
+	})
+	if err != nil {
+		t.Fatalf("failed to create a client: %s", err)
+	}
+
+	s, err := users.CreateSession(context.Background(), usr, cl.Name, "127.0.0.1", time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create a session: %s", err)
+	}
+
+	code := bus.Code{
+		FamilyID: uuid.New(),
+		ClientID: cl.ID,
+		UserID:   usr.ID,
+		Scopes:   []string{bus.ScopeOpenID, bus.ScopeEmail},
+		AMR:      []string{"pwd"},
+		AuthTime: time.Now(),
+	}
+
+	first, err := b.CreateRefreshToken(context.Background(), code, s.ID, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create a refresh token: %s", err)
+	}
+
+	//asking for more than was granted must not burn the token.
+	if _, _, err := b.RotateRefreshToken(context.Background(), cl, first, []string{bus.ScopeProfile}); !errors.Is(err, bus.ErrInvalidScope) {
+		t.Errorf("err=%s, got=%v", bus.ErrInvalidScope, err)
+	}
+
+	rt, second, err := b.RotateRefreshToken(context.Background(), cl, first, []string{bus.ScopeOpenID})
+	if err != nil {
+		t.Fatalf("failed to rotate a refresh token: %s", err)
+	}
+
+	//narrowing only applies to the access token, the refresh token keeps the original grant.
+	if want := []string{bus.ScopeOpenID, bus.ScopeEmail}; !slices.Equal(rt.Scopes, want) {
+		t.Errorf("scopes=%v, got=%v", want, rt.Scopes)
+	}
+
+	if rt.SessionID != s.ID {
+		t.Errorf("sessionID=%s, got=%s", s.ID, rt.SessionID)
+	}
+
+	//replaying the old token revokes the whole family.
+	if _, _, err := b.RotateRefreshToken(context.Background(), cl, first, nil); !errors.Is(err, bus.ErrInvalidGrant) {
+		t.Errorf("reused token: err=%s, got=%v", bus.ErrInvalidGrant, err)
+	}
+
+	if _, _, err := b.RotateRefreshToken(context.Background(), cl, second, nil); !errors.Is(err, bus.ErrInvalidGrant) {
+		t.Errorf("revoked family: err=%s, got=%v", bus.ErrInvalidGrant, err)
+	}
+
+	//families can be revoked on their own, like when the session they belong to is revoked.
+	code.FamilyID = uuid.New()
+	third, err := b.CreateRefreshToken(context.Background(), code, s.ID, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create a refresh token: %s", err)
+	}
+
+	if err := b.RevokeFamily(context.Background(), code.FamilyID); err != nil {
+		t.Fatalf("failed to revoke a family: %s", err)
+	}
+
+	if _, _, err := b.RotateRefreshToken(context.Background(), cl, third, nil); !errors.Is(err, bus.ErrInvalidGrant) {
+		t.Errorf("revoked session: err=%s, got=%v", bus.ErrInvalidGrant, err)
+	}
+}
+
+func newUser(t *testing.T, b *userBus.Bus) userBus.User {
+	t.Helper()
+
+	roles, err := userBus.ParseManyRoles([]string{"user"})
+	if err != nil {
+		t.Fatalf("failed to parse roles: %s", err)
+	}
+
+	usr, err := b.Create(context.Background(), userBus.NewUser{
+		Name:       "John Doe",
+		Email:      mail.Address{Name: "John Doe", Address: "john@gmail.com"},
+		Roles:      roles,
+		Department: "sales",
+		Password:   "test1234",
+	})
+	if err != nil {
+		t.Fatalf("failed to create a user: %s", err)
+	}
+
+	return usr
+}
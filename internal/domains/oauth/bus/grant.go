@@ -0,0 +1,219 @@
+package bus
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CreateCode issues an authorization code for what the user approved, the plain code is returned
+// only once so it can be handed to the client.
+func (b *Bus) CreateCode(ctx context.Context, nc NewCode, ttl time.Duration) (string, error) {
+	code, err := newSecret()
+	if err != nil {
+		return "", fmt.Errorf("newSecret: %w", err)
+	}
+
+	now := time.Now().Truncate(time.Microsecond)
+
+	c := Code{
+		Hash:          hashSecret(code),
+		FamilyID:      uuid.New(),
+		ClientID:      nc.ClientID,
+		UserID:        nc.UserID,
+		RedirectURI:   nc.RedirectURI,
+		Scopes:        nc.Scopes,
+		Nonce:         nc.Nonce,
+		CodeChallenge: nc.CodeChallenge,
+		AMR:           nc.AMR,
+		AuthTime:      nc.AuthTime.Truncate(time.Microsecond),
+		ExpiresAt:     now.Add(ttl),
+		CreatedAt:     now,
+	}
+
+	if err := b.store.CreateCode(ctx, c); err != nil {
+		return "", fmt.Errorf("createCode: %w", err)
+	}
+
+	return code, nil
+}
+
+// ExchangeCode consumes the code, it has to be presented by the client it was issued to along with
+// the same redirect URI and the PKCE verifier. A code that was already used is a sign it leaked, so
+// the refresh tokens issued for it are revoked, see RFC 6749 section 4.1.2. Every failure is
+// reported as ErrInvalidGrant.
+func (b *Bus) ExchangeCode(ctx context.Context, client Client, code string, redirectURI string, verifier string) (Code, error) {
+	c, err := b.store.QueryCodeByHash(ctx, hashSecret(code))
+	if err != nil {
+		return Code{}, fmt.Errorf("queryCodeByHash: %w", err)
+	}
+
+	now := time.Now()
+	if c.UsedAt != nil {
+		if err := b.store.RevokeFamily(ctx, c.FamilyID, now); err != nil {
+			return Code{}, fmt.Errorf("revokeFamily: %w", err)
+		}
+		return Code{}, ErrInvalidGrant
+	}
+
+	if now.After(c.ExpiresAt) || c.ClientID != client.ID || c.RedirectURI != redirectURI {
+		return Code{}, ErrInvalidGrant
+	}
+
+	if !VerifyChallenge(c.CodeChallenge, verifier) {
+		return Code{}, ErrInvalidGrant
+	}
+
+	//store only marks it if nobody else used it in the meantime, losing that race counts as reuse.
+	err = b.store.UseCode(ctx, c, now)
+	if errors.Is(err, ErrInvalidGrant) {
+		if err := b.store.RevokeFamily(ctx, c.FamilyID, now); err != nil {
+			return Code{}, fmt.Errorf("revokeFamily: %w", err)
+		}
+		return Code{}, ErrInvalidGrant
+	}
+
+	if err != nil {
+		return Code{}, fmt.Errorf("useCode: %w", err)
+	}
+
+	return c, nil
+}
+
+// CreateRefreshToken starts the family of refresh tokens of the code in the session of the login,
+// the plain token is returned only once.
+func (b *Bus) CreateRefreshToken(ctx context.Context, c Code, sessionID uuid.UUID, ttl time.Duration) (string, error) {
+	now := time.Now().Truncate(time.Microsecond)
+
+	rt := RefreshToken{
+		ID:        uuid.New(),
+		FamilyID:  c.FamilyID,
+		SessionID: sessionID,
+		ClientID:  c.ClientID,
+		UserID:    c.UserID,
+		Scopes:    c.Scopes,
+		AMR:       c.AMR,
+		AuthTime:  c.AuthTime,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+
+	return b.createRefreshToken(ctx, rt)
+}
+
+// RotateRefreshToken revokes the token and returns it along with the plain token replacing it. A
+// token that was already rotated is a sign it leaked, so its whole family is revoked. Every
+// failure is reported as ErrInvalidGrant, except for scopes that were never granted which are
+// checked first so asking for them does not use the token up.
+func (b *Bus) RotateRefreshToken(ctx context.Context, client Client, token string, scopes []string) (RefreshToken, string, error) {
+	rt, err := b.store.QueryRefreshTokenByHash(ctx, hashSecret(token))
+	if err != nil {
+		return RefreshToken{}, "", fmt.Errorf("queryRefreshTokenByHash: %w", err)
+	}
+
+	now := time.Now()
+	if rt.ClientID != client.ID || now.After(rt.ExpiresAt) {
+		return RefreshToken{}, "", ErrInvalidGrant
+	}
+
+	for _, s := range scopes {
+		if !slices.Contains(rt.Scopes, s) {
+			return RefreshToken{}, "", ErrInvalidScope
+		}
+	}
+
+	if rt.RevokedAt != nil {
+		if err := b.store.RevokeFamily(ctx, rt.FamilyID, now); err != nil {
+			return RefreshToken{}, "", fmt.Errorf("revokeFamily: %w", err)
+		}
+		return RefreshToken{}, "", ErrInvalidGrant
+	}
+
+	//losing the race against another request with the same token counts as reuse too.
+	err = b.store.RevokeRefreshToken(ctx, rt, now)
+	if errors.Is(err, ErrInvalidGrant) {
+		if err := b.store.RevokeFamily(ctx, rt.FamilyID, now); err != nil {
+			return RefreshToken{}, "", fmt.Errorf("revokeFamily: %w", err)
+		}
+		return RefreshToken{}, "", ErrInvalidGrant
+	}
+
+	if err != nil {
+		return RefreshToken{}, "", fmt.Errorf("revokeRefreshToken: %w", err)
+	}
+
+	next := rt
+	next.ID = uuid.New()
+	next.RevokedAt = nil
+	next.CreatedAt = now.Truncate(time.Microsecond)
+
+	newToken, err := b.createRefreshToken(ctx, next)
+	if err != nil {
+		return RefreshToken{}, "", err
+	}
+
+	return rt, newToken, nil
+}
+
+// RevokeFamily revokes every refresh token of the family, for when the grant they were issued for
+// no longer holds.
+func (b *Bus) RevokeFamily(ctx context.Context, familyID uuid.UUID) error {
+	if err := b.store.RevokeFamily(ctx, familyID, time.Now()); err != nil {
+		return fmt.Errorf("revokeFamily: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteExpired removes the expired codes and refresh tokens, it returns how many were removed.
+func (b *Bus) DeleteExpired(ctx context.Context) (int, error) {
+	now := time.Now()
+
+	codes, err := b.store.DeleteExpiredCodes(ctx, now)
+	if err != nil {
+		return 0, fmt.Errorf("deleteExpiredCodes: %w", err)
+	}
+
+	tokens, err := b.store.DeleteExpiredRefreshTokens(ctx, now)
+	if err != nil {
+		return 0, fmt.Errorf("deleteExpiredRefreshTokens: %w", err)
+	}
+
+	return codes + tokens, nil
+}
+
+// VerifyChallenge checks the PKCE verifier against the S256 challenge, see RFC 7636.
+func VerifyChallenge(challenge string, verifier string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+// ==============================================================================
+
+func (b *Bus) createRefreshToken(ctx context.Context, rt RefreshToken) (string, error) {
+	token, err := newSecret()
+	if err != nil {
+		return "", fmt.Errorf("newSecret: %w", err)
+	}
+
+	rt.Hash = hashSecret(token)
+
+	if err := b.store.CreateRefreshToken(ctx, rt); err != nil {
+		return "", fmt.Errorf("createRefreshToken: %w", err)
+	}
+
+	return token, nil
+}
@@ -0,0 +1,121 @@
+package bus
+
+import (
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Grant types a client can be allowed to use at the token endpoint.
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantRefreshToken      = "refresh_token"
+	GrantClientCredentials = "client_credentials"
+)
+
+// Scopes defined by OpenID Connect, clients may also be given scopes of their own.
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+// Client is an application allowed to get tokens for users, or for itself with client credentials.
+// Public clients like SPAs can not keep a secret, so they have none and must use PKCE.
+type Client struct {
+	ID           uuid.UUID
+	Name         string
+	SecretHash   []byte
+	RedirectURIs []string
+	GrantTypes   []string
+	Scopes       []string
+	CreatedBy    *uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// Public reports whether the client has no secret.
+func (c Client) Public() bool {
+	return len(c.SecretHash) == 0
+}
+
+func (c Client) AllowsGrant(grant string) bool {
+	return slices.Contains(c.GrantTypes, grant)
+}
+
+// AllowsRedirect reports whether uri is one of the registered redirect URIs, they must match exactly.
+func (c Client) AllowsRedirect(uri string) bool {
+	return slices.Contains(c.RedirectURIs, uri)
+}
+
+// AllowsScopes reports whether the client may ask for all of the scopes.
+func (c Client) AllowsScopes(scopes []string) bool {
+	for _, s := range scopes {
+		if !slices.Contains(c.Scopes, s) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// NewClient registers a client, Confidential ones are handed a secret.
+type NewClient struct {
+	Name         string
+	RedirectURIs []string
+	GrantTypes   []string
+	Scopes       []string
+	Confidential bool
+	CreatedBy    *uuid.UUID
+}
+
+// Code is an authorization code handed to the client through the redirect URI, it is exchanged
+// once for tokens by presenting the PKCE verifier of CodeChallenge. The refresh tokens issued for
+// it start the family FamilyID.
+type Code struct {
+	Hash          []byte
+	FamilyID      uuid.UUID
+	ClientID      uuid.UUID
+	UserID        uuid.UUID
+	RedirectURI   string
+	Scopes        []string
+	Nonce         string
+	CodeChallenge string
+	AMR           []string
+	AuthTime      time.Time
+	ExpiresAt     time.Time
+	UsedAt        *time.Time
+	CreatedAt     time.Time
+}
+
+// NewCode is what the user approved at the authorization endpoint, AMR and AuthTime describe
+// how and when they logged in.
+type NewCode struct {
+	ClientID      uuid.UUID
+	UserID        uuid.UUID
+	RedirectURI   string
+	Scopes        []string
+	Nonce         string
+	CodeChallenge string
+	AMR           []string
+	AuthTime      time.Time
+}
+
+// RefreshToken keeps the grant of a user to a client alive after the access token expires. Every
+// use replaces it with a new one of the same family, which never outlives the first one. The
+// family belongs to the session of the login, revoking the session revokes it too.
+type RefreshToken struct {
+	ID        uuid.UUID
+	Hash      []byte
+	FamilyID  uuid.UUID
+	SessionID uuid.UUID
+	ClientID  uuid.UUID
+	UserID    uuid.UUID
+	Scopes    []string
+	AMR       []string
+	AuthTime  time.Time
+	ExpiresAt time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
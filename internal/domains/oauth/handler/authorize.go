@@ -0,0 +1,377 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"embed"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/hamidoujand/jumble/internal/auth"
+	oauthBus "github.com/hamidoujand/jumble/internal/domains/oauth/bus"
+	userBus "github.com/hamidoujand/jumble/internal/domains/user/bus"
+)
+
+//go:embed templates/authorize.html
+var templateFiles embed.FS
+
+var authorizePage = template.Must(template.ParseFS(templateFiles, "templates/authorize.html"))
+
+// csrfCookie holds the token the login form has to post back, another site can make the browser
+// post the form but it can not read the cookie to fill the token in.
+const csrfCookie = "oauth_csrf"
+
+// authError is a problem with an authorization request. Unless redirect is set the redirect uri can
+// not be trusted, so the error is shown to the user instead of being sent back to the client.
+type authError struct {
+	code        string
+	description string
+	redirect    bool
+}
+
+func (e *authError) Error() string {
+	return e.description
+}
+
+// approval is a checked authorization request.
+type approval struct {
+	client      oauthBus.Client
+	redirectURI string
+	scopes      []string
+}
+
+// pageData fills the login and consent page, Fatal pages only show the error.
+type pageData struct {
+	ClientName string
+	Scopes     []string
+	Request    authRequest
+	Email      string
+	Error      string
+	MFA        bool
+	Fatal      bool
+	CSRFToken  string
+}
+
+// Authorize shows the login and consent page for a valid authorization request.
+func (h *handler) Authorize(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "oauth.handler.authorize")
+	defer span.End()
+
+	var ar authRequest
+	if err := c.ShouldBindQuery(&ar); err != nil {
+		h.render(c, http.StatusBadRequest, pageData{Fatal: true, Error: "The request is malformed."})
+		return
+	}
+
+	app, err := h.checkRequest(ctx, ar)
+	if err != nil {
+		h.authFailed(c, ar, app, err)
+		return
+	}
+
+	//there are no sessions to check silently, the user always has to log in.
+	if ar.Prompt == "none" {
+		h.redirect(c, app.redirectURI, map[string]string{"error": "login_required", "state": ar.State})
+		return
+	}
+
+	h.render(c, http.StatusOK, pageData{ClientName: app.client.Name, Scopes: app.scopes, Request: ar})
+}
+
+// Login checks the credentials posted from the login page, on success the user is sent back to
+// the client with an authorization code.
+func (h *handler) Login(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "oauth.handler.login")
+	defer span.End()
+
+	var l login
+	if err := c.ShouldBind(&l); err != nil {
+		h.render(c, http.StatusBadRequest, pageData{Fatal: true, Error: "The request is malformed."})
+		return
+	}
+
+	if !validCSRF(c, l.CSRFToken) {
+		h.render(c, http.StatusForbidden, pageData{Fatal: true, Error: "The sign in page expired, go back to the application and try again."})
+		return
+	}
+
+	app, err := h.checkRequest(ctx, l.authRequest)
+	if err != nil {
+		h.authFailed(c, l.authRequest, app, err)
+		return
+	}
+
+	if l.Action != "allow" {
+		h.redirect(c, app.redirectURI, map[string]string{"error": errAccessDenied, "state": l.State})
+		return
+	}
+
+	page := pageData{ClientName: app.client.Name, Scopes: app.scopes, Request: l.authRequest, Email: l.Email}
+
+	retry := func(status int, msg string) {
+		page.Error = msg
+		h.render(c, status, page)
+	}
+
+	email, err := mail.ParseAddress(l.Email)
+	if err != nil {
+		retry(http.StatusUnauthorized, "Invalid email or password.")
+		return
+	}
+
+	ip := c.ClientIP()
+
+	wait, err := h.userBus.CheckLogin(ctx, *email, ip)
+	if errors.Is(err, userBus.ErrLoginLocked) {
+		c.Header("Retry-After", fmt.Sprint(int64((wait+time.Second-1)/time.Second)))
+		retry(http.StatusTooManyRequests, "Too many failed attempts, try again later.")
+		return
+	}
+
+	if err != nil {
+		h.internalError(c, "checkLogin", err)
+		return
+	}
+
+	usr, err := h.userBus.Authenticate(ctx, *email, l.Password)
+	switch {
+	case errors.Is(err, userBus.ErrInvalidCredentials):
+		if err := h.userBus.LoginFailed(ctx, *email, ip); err != nil {
+			h.log.Error(ctx, "loginFailed", "err", err.Error())
+		}
+		retry(http.StatusUnauthorized, "Invalid email or password.")
+		return
+
+	case errors.Is(err, userBus.ErrPasswordExpired):
+		retry(http.StatusForbidden, "Your password has expired, change it before signing in.")
+		return
+
+	case err != nil:
+		h.internalError(c, "authenticate", err)
+		return
+	}
+
+	if !usr.Enabled {
+		retry(http.StatusUnauthorized, "Invalid email or password.")
+		return
+	}
+
+	if h.requireVerifiedEmail && usr.EmailVerifiedAt == nil {
+		retry(http.StatusForbidden, "Verify your email address before signing in.")
+		return
+	}
+
+	amr := []string{auth.AMRPassword}
+
+	if usr.MFAEnabledAt != nil {
+		page.MFA = true
+
+		if l.Code == "" {
+			retry(http.StatusUnauthorized, "Enter the code from your authenticator app.")
+			return
+		}
+
+		err := h.userBus.VerifyMFA(ctx, usr, l.Code)
+		if errors.Is(err, userBus.ErrInvalidMFACode) || errors.Is(err, userBus.ErrMFANotEnrolled) {
+			if err := h.userBus.LoginFailed(ctx, usr.Email, ip); err != nil {
+				h.log.Error(ctx, "loginFailed", "err", err.Error())
+			}
+			retry(http.StatusUnauthorized, "Invalid code.")
+			return
+		}
+
+		if err != nil {
+			h.internalError(c, "verifyMFA", err)
+			return
+		}
+
+		amr = []string{auth.AMRPassword, auth.AMROTP, auth.AMRMFA}
+	}
+
+	if err := h.userBus.LoginSucceeded(ctx, usr.Email); err != nil {
+		h.log.Error(ctx, "loginSucceeded", "userID", usr.ID, "err", err.Error())
+	}
+
+	code, err := h.oauthBus.CreateCode(ctx, oauthBus.NewCode{
+		ClientID:      app.client.ID,
+		UserID:        usr.ID,
+		RedirectURI:   app.redirectURI,
+		Scopes:        app.scopes,
+		Nonce:         l.Nonce,
+		CodeChallenge: l.CodeChallenge,
+		AMR:           amr,
+		AuthTime:      time.Now(),
+	}, h.codeTTL)
+	if err != nil {
+		h.internalError(c, "createCode", err)
+		return
+	}
+
+	h.redirect(c, app.redirectURI, map[string]string{"code": code, "state": l.State})
+}
+
+// ==============================================================================
+
+// checkRequest validates the authorization request, see RFC 6749 section 4.1.1. PKCE is required
+// from every client, not only the public ones.
+func (h *handler) checkRequest(ctx context.Context, ar authRequest) (approval, error) {
+	clientID, err := uuid.Parse(ar.ClientID)
+	if err != nil {
+		return approval{}, &authError{code: errInvalidRequest, description: "The application is not registered."}
+	}
+
+	client, err := h.oauthBus.QueryClientByID(ctx, clientID)
+	if errors.Is(err, oauthBus.ErrClientNotFound) {
+		return approval{}, &authError{code: errInvalidRequest, description: "The application is not registered."}
+	}
+
+	if err != nil {
+		return approval{}, fmt.Errorf("queryClientByID: %w", err)
+	}
+
+	//OpenID Connect always asks for the redirect uri, so it is never filled in for the client.
+	if !client.AllowsRedirect(ar.RedirectURI) {
+		return approval{}, &authError{code: errInvalidRequest, description: "The redirect address is not registered for the application."}
+	}
+
+	app := approval{
+		client:      client,
+		redirectURI: ar.RedirectURI,
+		scopes:      parseScopes(ar.Scope),
+	}
+
+	switch {
+	case ar.ResponseType != "code":
+		return app, &authError{code: errUnsupportedResponseType, description: "only the code response type is supported", redirect: true}
+
+	case !client.AllowsGrant(oauthBus.GrantAuthorizationCode):
+		return app, &authError{code: errUnauthorizedClient, description: "client may not use the authorization code grant", redirect: true}
+
+	case ar.CodeChallenge == "" || ar.CodeChallengeMethod != "S256":
+		return app, &authError{code: errInvalidRequest, description: "PKCE with the S256 method is required", redirect: true}
+
+	case len(app.scopes) == 0 || !client.AllowsScopes(app.scopes):
+		return app, &authError{code: errInvalidScope, description: oauthBus.ErrInvalidScope.Error(), redirect: true}
+	}
+
+	return app, nil
+}
+
+// authFailed reports a failed check of the authorization request, either to the client or, when
+// the redirect uri is not trusted, to the user.
+func (h *handler) authFailed(c *gin.Context, ar authRequest, app approval, err error) {
+	var ae *authError
+	if !errors.As(err, &ae) {
+		h.internalError(c, "checkRequest", err)
+		return
+	}
+
+	if !ae.redirect {
+		h.render(c, http.StatusBadRequest, pageData{Fatal: true, Error: ae.description})
+		return
+	}
+
+	h.redirect(c, app.redirectURI, map[string]string{
+		"error":             ae.code,
+		"error_description": ae.description,
+		"state":             ar.State,
+	})
+}
+
+// redirect sends the user back to the client with the params added to the redirect uri, empty
+// ones are left out. The issuer is added so clients can tell authorization servers apart, see
+// RFC 9207.
+func (h *handler) redirect(c *gin.Context, redirectURI string, params map[string]string) {
+	//registered uris were parsed when the client was created.
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		h.internalError(c, "parse", err)
+		return
+	}
+
+	q := u.Query()
+	for k, v := range params {
+		if v != "" {
+			q.Set(k, v)
+		}
+	}
+	q.Set("iss", h.issuer)
+	u.RawQuery = q.Encode()
+
+	noStore(c)
+	c.Redirect(http.StatusSeeOther, u.String())
+}
+
+func (h *handler) internalError(c *gin.Context, op string, err error) {
+	h.log.Error(c.Request.Context(), op, "err", err.Error())
+	h.render(c, http.StatusInternalServerError, pageData{Fatal: true, Error: "Please try again later."})
+}
+
+// render writes the page, it may not be framed by other sites so the consent can not be
+// clickjacked. Forms are rendered with the CSRF token of the browser, a new one is handed out when
+// it has none.
+func (h *handler) render(c *gin.Context, status int, data pageData) {
+	if !data.Fatal {
+		token, err := csrfToken(c)
+		if err != nil {
+			h.internalError(c, "csrfToken", err)
+			return
+		}
+		data.CSRFToken = token
+	}
+
+	noStore(c)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Header("X-Frame-Options", "DENY")
+	c.Header("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; frame-ancestors 'none'")
+	c.Status(status)
+
+	if err := authorizePage.Execute(c.Writer, data); err != nil {
+		h.log.Error(c.Request.Context(), "render authorize page", "err", err.Error())
+	}
+}
+
+// csrfToken returns the CSRF token of the browser, or sets a new one. The cookie is only sent back
+// to the authorize endpoint.
+func csrfToken(c *gin.Context) (string, error) {
+	if cookie, err := c.Request.Cookie(csrfCookie); err == nil && cookie.Value != "" {
+		return cookie.Value, nil
+	}
+
+	bs := make([]byte, 32)
+	if _, err := rand.Read(bs); err != nil {
+		return "", fmt.Errorf("read: %w", err)
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(bs)
+
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     csrfCookie,
+		Value:    token,
+		Path:     c.Request.URL.Path,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return token, nil
+}
+
+// validCSRF reports whether the posted token matches the cookie of the browser.
+func validCSRF(c *gin.Context, token string) bool {
+	cookie, err := c.Request.Cookie(csrfCookie)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(token), []byte(cookie.Value)) == 1
+}
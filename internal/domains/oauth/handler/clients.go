@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	oauthBus "github.com/hamidoujand/jumble/internal/domains/oauth/bus"
+	userBus "github.com/hamidoujand/jumble/internal/domains/user/bus"
+	"github.com/hamidoujand/jumble/internal/errs"
+)
+
+// CreateClient registers a client, the secret of confidential clients is only in this response.
+func (h *handler) CreateClient(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "oauth.handler.createClient")
+	defer span.End()
+
+	val, ok := c.Get("user")
+	if !ok {
+		c.Error(errs.New(http.StatusUnauthorized, "%s", http.StatusText(http.StatusUnauthorized)))
+		return
+	}
+
+	admin, ok := val.(userBus.User)
+	if !ok {
+		c.Error(errs.New(http.StatusUnauthorized, "%s", http.StatusText(http.StatusUnauthorized)))
+		return
+	}
+
+	var nc newClient
+	if err := c.ShouldBindJSON(&nc); err != nil {
+		c.Error(err)
+		return
+	}
+
+	client, secret, err := h.oauthBus.CreateClient(ctx, toBusNewClient(nc, admin.ID))
+	if errors.Is(err, oauthBus.ErrInvalidClientConfig) {
+		c.Error(errs.New(http.StatusBadRequest, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "createClient: %s", err))
+		return
+	}
+
+	app := toAppClient(client)
+	app.Secret = secret
+
+	c.JSON(http.StatusCreated, app)
+}
+
+func (h *handler) QueryClients(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "oauth.handler.queryClients")
+	defer span.End()
+
+	cs, err := h.oauthBus.QueryClients(ctx)
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "queryClients: %s", err))
+		return
+	}
+
+	apps := make([]client, len(cs))
+	for i, cl := range cs {
+		apps[i] = toAppClient(cl)
+	}
+
+	c.JSON(http.StatusOK, apps)
+}
+
+func (h *handler) QueryClientByID(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "oauth.handler.queryClientByID")
+	defer span.End()
+
+	cl, ok := h.loadClient(ctx, c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, toAppClient(cl))
+}
+
+// DeleteClient removes the client, tokens it already holds stay valid until they expire but can
+// not be refreshed.
+func (h *handler) DeleteClient(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "oauth.handler.deleteClient")
+	defer span.End()
+
+	cl, ok := h.loadClient(ctx, c)
+	if !ok {
+		return
+	}
+
+	if err := h.oauthBus.DeleteClient(ctx, cl); err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "deleteClient: %s", err))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ==============================================================================
+
+// loadClient fetches the client in the path, when it fails the error is already set on the context.
+func (h *handler) loadClient(ctx context.Context, c *gin.Context) (oauthBus.Client, bool) {
+	p := c.Param("id")
+
+	clientID, err := uuid.Parse(p)
+	if err != nil {
+		c.Error(errs.New(http.StatusBadRequest, "invalid client id: %s", p))
+		return oauthBus.Client{}, false
+	}
+
+	cl, err := h.oauthBus.QueryClientByID(ctx, clientID)
+	if errors.Is(err, oauthBus.ErrClientNotFound) {
+		c.Error(errs.New(http.StatusNotFound, "%s", err))
+		return oauthBus.Client{}, false
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "queryClientByID: %s", err))
+		return oauthBus.Client{}, false
+	}
+
+	return cl, true
+}
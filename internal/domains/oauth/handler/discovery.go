@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"encoding/base64"
+	"math/big"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hamidoujand/jumble/internal/auth"
+	oauthBus "github.com/hamidoujand/jumble/internal/domains/oauth/bus"
+	"github.com/hamidoujand/jumble/internal/errs"
+)
+
+// Discovery returns the OpenID provider metadata clients configure themselves with, see OpenID
+// Connect Discovery section 3.
+func (h *handler) Discovery(c *gin.Context) {
+	_, span := h.tracer.Start(c.Request.Context(), "oauth.handler.discovery")
+	defer span.End()
+
+	c.JSON(http.StatusOK, providerMetadata{
+		Issuer:                h.issuer,
+		AuthorizationEndpoint: h.issuer + "/oauth/authorize",
+		TokenEndpoint:         h.issuer + "/oauth/token",
+		UserInfoEndpoint:      h.issuer + "/oauth/userinfo",
+		JWKSURI:               h.issuer + "/oauth/jwks",
+		ResponseTypes:         []string{"code"},
+		GrantTypes: []string{
+			oauthBus.GrantAuthorizationCode,
+			oauthBus.GrantRefreshToken,
+			oauthBus.GrantClientCredentials,
+		},
+		SubjectTypes:                 []string{"public"},
+		IDTokenSigningAlgs:           []string{"RS256"},
+		Scopes:                       []string{oauthBus.ScopeOpenID, oauthBus.ScopeProfile, oauthBus.ScopeEmail, auth.ScopeAPI},
+		TokenEndpointAuthMethods:     []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethods:         []string{"S256"},
+		Claims:                       []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "amr", "name", "email", "email_verified"},
+		AuthorizationResponseIss:     true,
+		RequestURIParameterSupported: false,
+	})
+}
+
+// JWKS publishes the public key tokens are signed with, see RFC 7517.
+func (h *handler) JWKS(c *gin.Context) {
+	_, span := h.tracer.Start(c.Request.Context(), "oauth.handler.jwks")
+	defer span.End()
+
+	pub, err := h.a.PublicKey(h.kid)
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "publicKey: %s", err))
+		return
+	}
+
+	k := jwk{
+		KeyType:   "RSA",
+		Use:       "sig",
+		Algorithm: "RS256",
+		KeyID:     h.kid,
+		Modulus:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		Exponent:  base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}
+
+	c.JSON(http.StatusOK, jwks{Keys: []jwk{k}})
+}
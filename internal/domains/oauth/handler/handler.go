@@ -0,0 +1,229 @@
+// Package handler provides the endpoints of the OAuth 2.0 and OpenID Connect authorization
+// server, along with the ones admins use to register clients.
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/hamidoujand/jumble/internal/auth"
+	oauthBus "github.com/hamidoujand/jumble/internal/domains/oauth/bus"
+	userBus "github.com/hamidoujand/jumble/internal/domains/user/bus"
+	"github.com/hamidoujand/jumble/internal/errs"
+	"github.com/hamidoujand/jumble/pkg/logger"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Error codes of RFC 6749 section 5.2, plus the ones of the authorization endpoint.
+const (
+	errInvalidRequest          = "invalid_request"
+	errInvalidClient           = "invalid_client"
+	errInvalidGrant            = "invalid_grant"
+	errUnauthorizedClient      = "unauthorized_client"
+	errUnsupportedGrantType    = "unsupported_grant_type"
+	errUnsupportedResponseType = "unsupported_response_type"
+	errInvalidScope            = "invalid_scope"
+	errAccessDenied            = "access_denied"
+	errInvalidToken            = "invalid_token"
+	errInsufficientScope       = "insufficient_scope"
+	errServerError             = "server_error"
+)
+
+type handler struct {
+	oauthBus             *oauthBus.Bus
+	userBus              *userBus.Bus
+	a                    *auth.Auth
+	kid                  string
+	issuer               string
+	accessTokenTTL       time.Duration
+	refreshTokenTTL      time.Duration
+	codeTTL              time.Duration
+	requireVerifiedEmail bool
+	tracer               trace.Tracer
+	log                  *logger.Logger
+}
+
+// fail sets the error along with the OAuth error code renderErrors puts in the response.
+func fail(c *gin.Context, status int, code string, err error) {
+	c.Set("oauthError", code)
+	c.Error(errs.New(status, "%s", err))
+}
+
+// renderErrors renders the errors set by the handlers the way RFC 6749 asks for, the app wide
+// Error middleware leaves responses that are already written alone.
+func (h *handler) renderErrors(c *gin.Context) {
+	c.Next()
+
+	if c.Writer.Written() || len(c.Errors) == 0 {
+		return
+	}
+
+	err := c.Errors.Last().Err
+
+	status := http.StatusInternalServerError
+	code := c.GetString("oauthError")
+	description := http.StatusText(http.StatusInternalServerError)
+
+	var appErr *errs.Error
+	var validationErrors validator.ValidationErrors
+
+	switch {
+	case errors.As(err, &appErr):
+		status = appErr.Code
+		if status != http.StatusInternalServerError {
+			description = appErr.Message
+		}
+
+		h.log.Error(c.Request.Context(), "error while handling oauth request", "err", err, "fileName", appErr.FileName, "funcName", appErr.FuncName)
+
+	case errors.As(err, &validationErrors):
+		status = http.StatusBadRequest
+		code = errInvalidRequest
+		description = validationErrors.Error()
+
+	default:
+		h.log.Error(c.Request.Context(), "unknown oauth error", "err", err)
+	}
+
+	if code == "" {
+		code = errServerError
+		if status < http.StatusInternalServerError {
+			code = errInvalidRequest
+		}
+	}
+
+	//bearer token errors are reported in the header as well, see RFC 6750 section 3.
+	if code == errInvalidToken || code == errInsufficientScope {
+		c.Header("WWW-Authenticate", `Bearer error="`+code+`"`)
+	}
+
+	noStore(c)
+	c.JSON(status, oauthError{Error: code, Description: description})
+}
+
+// noStore keeps tokens and the pages that lead to them out of caches.
+func noStore(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+}
+
+// cors lets the SPAs on the allowed origins call the endpoint from the browser, preflight requests
+// are answered right away.
+func cors(origins []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" || !slices.Contains(origins, origin) {
+			c.Next()
+			return
+		}
+
+		c.Header("Access-Control-Allow-Origin", origin)
+		c.Header("Vary", "Origin")
+
+		if c.Request.Method == http.MethodOptions {
+			c.Header("Access-Control-Allow-Methods", "GET, POST")
+			c.Header("Access-Control-Allow-Headers", "Authorization, Content-Type")
+			c.Header("Access-Control-Max-Age", "600")
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// ==============================================================================
+
+// idClaims are the claims of an ID token, see OpenID Connect Core section 2. The profile and email
+// claims are only filled in when the matching scopes were granted.
+type idClaims struct {
+	jwt.RegisteredClaims
+	Nonce         string   `json:"nonce,omitempty"`
+	AuthTime      int64    `json:"auth_time"`
+	AMR           []string `json:"amr,omitempty"`
+	Name          string   `json:"name,omitempty"`
+	Email         string   `json:"email,omitempty"`
+	EmailVerified *bool    `json:"email_verified,omitempty"`
+}
+
+// grant is what a token response is issued for, it comes from a code or a refresh token.
+type grant struct {
+	client    oauthBus.Client
+	user      userBus.User
+	sessionID uuid.UUID
+	scopes    []string
+	amr       []string
+	authTime  time.Time
+	nonce     string
+}
+
+// accessToken issues an access token for the user, it works like the ones from the login endpoint
+// and carries the client and the granted scopes on top. Access tokens are for this service, so
+// they get the issuer and audience of the Auth and not the issuer of the ID tokens.
+func (h *handler) accessToken(g grant) (string, error) {
+	now := time.Now()
+
+	claims := auth.Claims{
+		Roles:     userBus.RolesToString(g.user.Roles),
+		AMR:       g.amr,
+		Scope:     strings.Join(g.scopes, " "),
+		ClientID:  g.client.ID.String(),
+		SessionID: g.sessionID.String(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   g.user.ID.String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(h.accessTokenTTL)),
+		},
+	}
+
+	return h.a.GenerateToken(h.kid, claims)
+}
+
+// idToken issues an ID token for the client, it is only asked for along with the openid scope.
+func (h *handler) idToken(g grant) (string, error) {
+	now := time.Now()
+
+	claims := idClaims{
+		Nonce:    g.nonce,
+		AuthTime: g.authTime.Unix(),
+		AMR:      g.amr,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    h.issuer,
+			Subject:   g.user.ID.String(),
+			Audience:  jwt.ClaimStrings{g.client.ID.String()},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(h.accessTokenTTL)),
+		},
+	}
+
+	if slices.Contains(g.scopes, oauthBus.ScopeProfile) {
+		claims.Name = g.user.Name
+	}
+
+	if slices.Contains(g.scopes, oauthBus.ScopeEmail) {
+		verified := g.user.EmailVerifiedAt != nil
+		claims.Email = g.user.Email.Address
+		claims.EmailVerified = &verified
+	}
+
+	return h.a.Sign(h.kid, claims)
+}
+
+// parseScopes splits a space separated scope parameter, duplicates are dropped.
+func parseScopes(scope string) []string {
+	var scopes []string
+	for _, s := range strings.Fields(scope) {
+		if !slices.Contains(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+
+	return scopes
+}
@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	oauthBus "github.com/hamidoujand/jumble/internal/domains/oauth/bus"
+)
+
+type client struct {
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	Confidential bool     `json:"confidential"`
+	Secret       string   `json:"secret,omitempty"`
+	RedirectURIs []string `json:"redirectUris"`
+	GrantTypes   []string `json:"grantTypes"`
+	Scopes       []string `json:"scopes"`
+	CreatedBy    *string  `json:"createdBy"`
+	CreatedAt    string   `json:"createdAt"`
+	UpdatedAt    string   `json:"updatedAt"`
+}
+
+func toAppClient(c oauthBus.Client) client {
+	var createdBy *string
+	if c.CreatedBy != nil {
+		id := c.CreatedBy.String()
+		createdBy = &id
+	}
+
+	return client{
+		ID:           c.ID.String(),
+		Name:         c.Name,
+		Confidential: !c.Public(),
+		RedirectURIs: c.RedirectURIs,
+		GrantTypes:   c.GrantTypes,
+		Scopes:       c.Scopes,
+		CreatedBy:    createdBy,
+		CreatedAt:    c.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:    c.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+// newClient registers a client, SPAs and other clients that can not keep a secret are public.
+type newClient struct {
+	Name         string   `json:"name" binding:"required,max=120"`
+	RedirectURIs []string `json:"redirectUris" binding:"omitempty,dive,required,url,max=2000"`
+	GrantTypes   []string `json:"grantTypes" binding:"required,gt=0,dive,oneof=authorization_code refresh_token client_credentials"`
+	Scopes       []string `json:"scopes" binding:"omitempty,dive,required,max=64"`
+	Confidential bool     `json:"confidential"`
+}
+
+func toBusNewClient(nc newClient, createdBy uuid.UUID) oauthBus.NewClient {
+	return oauthBus.NewClient{
+		Name:         nc.Name,
+		RedirectURIs: nc.RedirectURIs,
+		GrantTypes:   nc.GrantTypes,
+		Scopes:       nc.Scopes,
+		Confidential: nc.Confidential,
+		CreatedBy:    &createdBy,
+	}
+}
+
+// ==============================================================================
+
+// authRequest is an authorization request, see RFC 6749 section 4.1.1 and RFC 7636.
+type authRequest struct {
+	ResponseType        string `form:"response_type"`
+	ClientID            string `form:"client_id"`
+	RedirectURI         string `form:"redirect_uri"`
+	Scope               string `form:"scope"`
+	State               string `form:"state"`
+	Nonce               string `form:"nonce"`
+	Prompt              string `form:"prompt"`
+	CodeChallenge       string `form:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method"`
+}
+
+// login is posted by the login page, Action is "allow" or "deny".
+type login struct {
+	authRequest
+	Email     string `form:"email"`
+	Password  string `form:"password"`
+	Code      string `form:"code"`
+	Action    string `form:"action"`
+	CSRFToken string `form:"csrf_token"`
+}
+
+// tokenRequest holds the parameters of every grant, see RFC 6749 sections 4.1.3, 4.4.2 and 6.
+type tokenRequest struct {
+	GrantType    string `form:"grant_type" binding:"required"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	RefreshToken string `form:"refresh_token"`
+	Scope        string `form:"scope"`
+}
+
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+type oauthError struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+type userInfo struct {
+	Subject       string `json:"sub"`
+	Name          string `json:"name,omitempty"`
+	Email         string `json:"email,omitempty"`
+	EmailVerified *bool  `json:"email_verified,omitempty"`
+	UpdatedAt     int64  `json:"updated_at,omitempty"`
+}
+
+// ==============================================================================
+
+type providerMetadata struct {
+	Issuer                       string   `json:"issuer"`
+	AuthorizationEndpoint        string   `json:"authorization_endpoint"`
+	TokenEndpoint                string   `json:"token_endpoint"`
+	UserInfoEndpoint             string   `json:"userinfo_endpoint"`
+	JWKSURI                      string   `json:"jwks_uri"`
+	ResponseTypes                []string `json:"response_types_supported"`
+	GrantTypes                   []string `json:"grant_types_supported"`
+	SubjectTypes                 []string `json:"subject_types_supported"`
+	IDTokenSigningAlgs           []string `json:"id_token_signing_alg_values_supported"`
+	Scopes                       []string `json:"scopes_supported"`
+	TokenEndpointAuthMethods     []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethods         []string `json:"code_challenge_methods_supported"`
+	Claims                       []string `json:"claims_supported"`
+	AuthorizationResponseIss     bool     `json:"authorization_response_iss_parameter_supported"`
+	RequestURIParameterSupported bool     `json:"request_uri_parameter_supported"`
+}
+
+type jwk struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	Modulus   string `json:"n"`
+	Exponent  string `json:"e"`
+}
+
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hamidoujand/jumble/internal/auth"
+	oauthBus "github.com/hamidoujand/jumble/internal/domains/oauth/bus"
+	roleBus "github.com/hamidoujand/jumble/internal/domains/role/bus"
+	userBus "github.com/hamidoujand/jumble/internal/domains/user/bus"
+	"github.com/hamidoujand/jumble/internal/mid"
+	"github.com/hamidoujand/jumble/pkg/logger"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type Conf struct {
+	Router   *gin.Engine
+	AuthConf mid.AuthConf
+	OAuthBus *oauthBus.Bus
+	UserBus  *userBus.Bus
+	RoleBus  *roleBus.Bus
+	Auth     *auth.Auth
+	Kid      string
+
+	//Issuer is the public address of the service, it is the "iss" of every token the server
+	//issues and the base of the endpoints in the discovery document.
+	Issuer string
+
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	CodeTTL         time.Duration
+
+	//AllowedOrigins are the origins of the SPAs allowed to call the token and userinfo endpoints.
+	AllowedOrigins []string
+
+	Tracer trace.Tracer
+	Logger *logger.Logger
+}
+
+// RegisterRoutes takes the mux and register endpoints on it.
+func RegisterRoutes(cfg Conf) {
+	h := handler{
+		oauthBus:             cfg.OAuthBus,
+		userBus:              cfg.UserBus,
+		a:                    cfg.Auth,
+		kid:                  cfg.Kid,
+		issuer:               cfg.Issuer,
+		accessTokenTTL:       cfg.AccessTokenTTL,
+		refreshTokenTTL:      cfg.RefreshTokenTTL,
+		codeTTL:              cfg.CodeTTL,
+		requireVerifiedEmail: cfg.AuthConf.RequireVerifiedEmail,
+		tracer:               cfg.Tracer,
+		log:                  cfg.Logger,
+	}
+
+	cfg.Router.GET("/.well-known/openid-configuration", h.Discovery)
+
+	browser := cors(cfg.AllowedOrigins)
+
+	oauth := cfg.Router.Group("/oauth")
+	oauth.GET("/jwks", browser, h.JWKS)
+	oauth.GET("/authorize", h.Authorize)
+	oauth.POST("/authorize", h.Login)
+	oauth.OPTIONS("/token", browser)
+	oauth.POST("/token", browser, h.renderErrors, h.Token)
+	oauth.OPTIONS("/userinfo", browser)
+	oauth.GET("/userinfo", browser, h.renderErrors, h.UserInfo)
+	oauth.POST("/userinfo", browser, h.renderErrors, h.UserInfo)
+
+	authenticated := mid.Authenticate(cfg.AuthConf)
+
+	api := mid.RequireScope(auth.ScopeAPI)
+
+	can := func(perms ...string) gin.HandlerFunc {
+		return mid.RequirePermission(cfg.Logger, cfg.RoleBus, perms...)
+	}
+
+	//clients can act for any user, so only global admins manage them.
+	clients := cfg.Router.Group("/v1/oauth/clients", authenticated, api, mid.GlobalScope())
+
+	clients.GET("/", can(roleBus.PermOAuthClientsRead), h.QueryClients)
+	clients.GET("/:id", can(roleBus.PermOAuthClientsRead), h.QueryClientByID)
+	clients.POST("/", can(roleBus.PermOAuthClientsWrite), h.CreateClient)
+	clients.DELETE("/:id", can(roleBus.PermOAuthClientsWrite), h.DeleteClient)
+}
@@ -0,0 +1,324 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/hamidoujand/jumble/internal/auth"
+	oauthBus "github.com/hamidoujand/jumble/internal/domains/oauth/bus"
+	userBus "github.com/hamidoujand/jumble/internal/domains/user/bus"
+	"github.com/hamidoujand/jumble/internal/errs"
+)
+
+// Token issues tokens for the authorization_code, refresh_token and client_credentials grants.
+// Clients authenticate with HTTP basic auth or with their credentials in the form, public clients
+// only send their id.
+func (h *handler) Token(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "oauth.handler.token")
+	defer span.End()
+
+	var tr tokenRequest
+	if err := c.ShouldBindWith(&tr, binding.Form); err != nil {
+		c.Error(err)
+		return
+	}
+
+	switch tr.GrantType {
+	case oauthBus.GrantAuthorizationCode, oauthBus.GrantRefreshToken, oauthBus.GrantClientCredentials:
+	default:
+		fail(c, http.StatusBadRequest, errUnsupportedGrantType, errors.New("grant type is not supported"))
+		return
+	}
+
+	client, ok := h.authenticateClient(ctx, c, tr)
+	if !ok {
+		return
+	}
+
+	if !client.AllowsGrant(tr.GrantType) {
+		fail(c, http.StatusBadRequest, errUnauthorizedClient, errors.New("client may not use this grant type"))
+		return
+	}
+
+	switch tr.GrantType {
+	case oauthBus.GrantAuthorizationCode:
+		h.exchangeCode(ctx, c, client, tr)
+	case oauthBus.GrantRefreshToken:
+		h.refresh(ctx, c, client, tr)
+	case oauthBus.GrantClientCredentials:
+		h.clientCredentials(c, client, tr)
+	}
+}
+
+// ==============================================================================
+
+func (h *handler) exchangeCode(ctx context.Context, c *gin.Context, client oauthBus.Client, tr tokenRequest) {
+	if tr.Code == "" || tr.RedirectURI == "" || tr.CodeVerifier == "" {
+		fail(c, http.StatusBadRequest, errInvalidRequest, errors.New("code, redirect_uri and code_verifier are required"))
+		return
+	}
+
+	code, err := h.oauthBus.ExchangeCode(ctx, client, tr.Code, tr.RedirectURI, tr.CodeVerifier)
+	if errors.Is(err, oauthBus.ErrInvalidGrant) {
+		fail(c, http.StatusBadRequest, errInvalidGrant, oauthBus.ErrInvalidGrant)
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "exchangeCode: %s", err))
+		return
+	}
+
+	usr, ok := h.grantUser(ctx, c, code.UserID, code.AuthTime)
+	if !ok {
+		return
+	}
+
+	//the grant shows up among the sessions of the user, so it can be revoked like any other login.
+	refresh := client.AllowsGrant(oauthBus.GrantRefreshToken)
+
+	ttl := h.accessTokenTTL
+	if refresh {
+		ttl = h.refreshTokenTTL
+	}
+
+	s, err := h.userBus.CreateSession(ctx, usr, client.Name, c.ClientIP(), ttl)
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "createSession: %s", err))
+		return
+	}
+
+	var refreshToken string
+	if refresh {
+		refreshToken, err = h.oauthBus.CreateRefreshToken(ctx, code, s.ID, h.refreshTokenTTL)
+		if err != nil {
+			c.Error(errs.New(http.StatusInternalServerError, "createRefreshToken: %s", err))
+			return
+		}
+	}
+
+	g := grant{
+		client:    client,
+		user:      usr,
+		sessionID: s.ID,
+		scopes:    code.Scopes,
+		amr:       code.AMR,
+		authTime:  code.AuthTime,
+		nonce:     code.Nonce,
+	}
+
+	h.respondTokens(c, g, refreshToken)
+}
+
+// refresh rotates the refresh token, the client may ask for fewer scopes than were granted.
+func (h *handler) refresh(ctx context.Context, c *gin.Context, client oauthBus.Client, tr tokenRequest) {
+	if tr.RefreshToken == "" {
+		fail(c, http.StatusBadRequest, errInvalidRequest, errors.New("refresh_token is required"))
+		return
+	}
+
+	scopes := parseScopes(tr.Scope)
+
+	rt, refreshToken, err := h.oauthBus.RotateRefreshToken(ctx, client, tr.RefreshToken, scopes)
+	switch {
+	case errors.Is(err, oauthBus.ErrInvalidGrant):
+		fail(c, http.StatusBadRequest, errInvalidGrant, oauthBus.ErrInvalidGrant)
+		return
+
+	case errors.Is(err, oauthBus.ErrInvalidScope):
+		fail(c, http.StatusBadRequest, errInvalidScope, errors.New("scope was not granted"))
+		return
+
+	case err != nil:
+		c.Error(errs.New(http.StatusInternalServerError, "rotateRefreshToken: %s", err))
+		return
+	}
+
+	if !h.grantSession(ctx, c, rt) {
+		return
+	}
+
+	usr, ok := h.grantUser(ctx, c, rt.UserID, rt.AuthTime)
+	if !ok {
+		return
+	}
+
+	if len(scopes) == 0 {
+		scopes = rt.Scopes
+	}
+
+	g := grant{
+		client:    client,
+		user:      usr,
+		sessionID: rt.SessionID,
+		scopes:    scopes,
+		amr:       rt.AMR,
+		authTime:  rt.AuthTime,
+	}
+
+	h.respondTokens(c, g, refreshToken)
+}
+
+// clientCredentials issues a token to the client itself, it has no user so there are no roles
+// and no ID token.
+func (h *handler) clientCredentials(c *gin.Context, client oauthBus.Client, tr tokenRequest) {
+	scopes := parseScopes(tr.Scope)
+	if !client.AllowsScopes(scopes) || slices.Contains(scopes, oauthBus.ScopeOpenID) {
+		fail(c, http.StatusBadRequest, errInvalidScope, oauthBus.ErrInvalidScope)
+		return
+	}
+
+	now := time.Now()
+
+	claims := auth.Claims{
+		Scope:    strings.Join(scopes, " "),
+		ClientID: client.ID.String(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   client.ID.String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(h.accessTokenTTL)),
+		},
+	}
+
+	token, err := h.a.GenerateToken(h.kid, claims)
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "generateToken: %s", err))
+		return
+	}
+
+	noStore(c)
+	c.JSON(http.StatusOK, tokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(h.accessTokenTTL.Seconds()),
+		Scope:       claims.Scope,
+	})
+}
+
+// ==============================================================================
+
+// authenticateClient identifies the client, when it fails the error is already set on the context.
+func (h *handler) authenticateClient(ctx context.Context, c *gin.Context, tr tokenRequest) (oauthBus.Client, bool) {
+	id, secret := tr.ClientID, tr.ClientSecret
+
+	//basic auth credentials are form encoded first, see RFC 6749 section 2.3.1.
+	user, pass, basic := c.Request.BasicAuth()
+	if basic {
+		id, _ = url.QueryUnescape(user)
+		secret, _ = url.QueryUnescape(pass)
+	}
+
+	invalid := func() {
+		if basic {
+			c.Header("WWW-Authenticate", `Basic realm="jumble"`)
+		}
+		fail(c, http.StatusUnauthorized, errInvalidClient, oauthBus.ErrInvalidClient)
+	}
+
+	clientID, err := uuid.Parse(id)
+	if err != nil {
+		invalid()
+		return oauthBus.Client{}, false
+	}
+
+	client, err := h.oauthBus.AuthenticateClient(ctx, clientID, secret)
+	if errors.Is(err, oauthBus.ErrInvalidClient) {
+		invalid()
+		return oauthBus.Client{}, false
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "authenticateClient: %s", err))
+		return oauthBus.Client{}, false
+	}
+
+	return client, true
+}
+
+// grantSession checks the session the refresh token was issued in, once it is revoked or expired
+// the whole family goes with it. When it fails the error is already set on the context.
+func (h *handler) grantSession(ctx context.Context, c *gin.Context, rt oauthBus.RefreshToken) bool {
+	s, err := h.userBus.QuerySessionByID(ctx, rt.UserID, rt.SessionID)
+	if err != nil && !errors.Is(err, userBus.ErrSessionNotFound) {
+		c.Error(errs.New(http.StatusInternalServerError, "querySessionByID: %s", err))
+		return false
+	}
+
+	if err == nil && s.Check(time.Now()) == nil {
+		return true
+	}
+
+	if err := h.oauthBus.RevokeFamily(ctx, rt.FamilyID); err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "revokeFamily: %s", err))
+		return false
+	}
+
+	fail(c, http.StatusBadRequest, errInvalidGrant, oauthBus.ErrInvalidGrant)
+	return false
+}
+
+// grantUser loads the user a grant was given by, disabling the user, changing the password after
+// the login or, when verified emails are required, an unverified email revokes the grant. When it
+// fails the error is already set on the context.
+func (h *handler) grantUser(ctx context.Context, c *gin.Context, userID uuid.UUID, authTime time.Time) (userBus.User, bool) {
+	usr, err := h.userBus.QueryByID(ctx, userID)
+	if errors.Is(err, userBus.ErrUserNotFound) {
+		fail(c, http.StatusBadRequest, errInvalidGrant, oauthBus.ErrInvalidGrant)
+		return userBus.User{}, false
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "queryByID: %s", err))
+		return userBus.User{}, false
+	}
+
+	if !usr.Enabled || authTime.Before(usr.PasswordChangedAt) {
+		fail(c, http.StatusBadRequest, errInvalidGrant, oauthBus.ErrInvalidGrant)
+		return userBus.User{}, false
+	}
+
+	if h.requireVerifiedEmail && usr.EmailVerifiedAt == nil {
+		fail(c, http.StatusBadRequest, errInvalidGrant, oauthBus.ErrInvalidGrant)
+		return userBus.User{}, false
+	}
+
+	return usr, true
+}
+
+// respondTokens writes the access token, the ID token when openid was granted and the refresh
+// token when there is one.
+func (h *handler) respondTokens(c *gin.Context, g grant, refreshToken string) {
+	accessToken, err := h.accessToken(g)
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "accessToken: %s", err))
+		return
+	}
+
+	tr := tokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(h.accessTokenTTL.Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        strings.Join(g.scopes, " "),
+	}
+
+	if slices.Contains(g.scopes, oauthBus.ScopeOpenID) {
+		tr.IDToken, err = h.idToken(g)
+		if err != nil {
+			c.Error(errs.New(http.StatusInternalServerError, "idToken: %s", err))
+			return
+		}
+	}
+
+	noStore(c)
+	c.JSON(http.StatusOK, tr)
+}
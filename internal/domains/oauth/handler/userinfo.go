@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	oauthBus "github.com/hamidoujand/jumble/internal/domains/oauth/bus"
+	userBus "github.com/hamidoujand/jumble/internal/domains/user/bus"
+	"github.com/hamidoujand/jumble/internal/errs"
+)
+
+// UserInfo returns the claims about the owner of the access token the granted scopes allow, see
+// OpenID Connect Core section 5.3. Only tokens issued with the openid scope are accepted.
+func (h *handler) UserInfo(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "oauth.handler.userInfo")
+	defer span.End()
+
+	claims, err := h.a.VerifyToken(ctx, c.GetHeader("Authorization"))
+	if err != nil || claims.TokenType != "" {
+		fail(c, http.StatusUnauthorized, errInvalidToken, errors.New("access token is invalid"))
+		return
+	}
+
+	scopes := strings.Fields(claims.Scope)
+	if !slices.Contains(scopes, oauthBus.ScopeOpenID) {
+		fail(c, http.StatusForbidden, errInsufficientScope, errors.New("access token was not issued for openid"))
+		return
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		fail(c, http.StatusUnauthorized, errInvalidToken, errors.New("access token is invalid"))
+		return
+	}
+
+	usr, err := h.userBus.QueryByID(ctx, userID)
+	if errors.Is(err, userBus.ErrUserNotFound) {
+		fail(c, http.StatusUnauthorized, errInvalidToken, errors.New("access token is invalid"))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "queryByID: %s", err))
+		return
+	}
+
+	//same rules as the Authenticate middleware, "iat" only has seconds precision.
+	if !usr.Enabled || claims.IssuedAt == nil || claims.IssuedAt.Before(usr.PasswordChangedAt.Truncate(time.Second)) {
+		fail(c, http.StatusUnauthorized, errInvalidToken, errors.New("access token is revoked"))
+		return
+	}
+
+	ui := userInfo{
+		Subject: usr.ID.String(),
+	}
+
+	if slices.Contains(scopes, oauthBus.ScopeProfile) {
+		ui.Name = usr.Name
+		ui.UpdatedAt = usr.UpdatedAt.Unix()
+	}
+
+	if slices.Contains(scopes, oauthBus.ScopeEmail) {
+		verified := usr.EmailVerifiedAt != nil
+		ui.Email = usr.Email.Address
+		ui.EmailVerified = &verified
+	}
+
+	noStore(c)
+	c.JSON(http.StatusOK, ui)
+}
@@ -0,0 +1,222 @@
+package oauthdb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	oauthBus "github.com/hamidoujand/jumble/internal/domains/oauth/bus"
+)
+
+func (s *Store) CreateCode(ctx context.Context, c oauthBus.Code) error {
+	const q = `
+	INSERT INTO oauth_codes (code_hash,family_id,client_id,user_id,redirect_uri,scopes,nonce,code_challenge,amr,auth_time,expires_at,used_at,created_at)
+	VALUES (:code_hash,:family_id,:client_id,:user_id,:redirect_uri,:scopes,:nonce,:code_challenge,:amr,:auth_time,:expires_at,:used_at,:created_at)
+	`
+
+	ctx, span := s.tracer.Start(ctx, "oauth.store.createCode")
+	defer span.End()
+
+	if _, err := s.db.NamedExecContext(ctx, q, fromBusCode(c)); err != nil {
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) QueryCodeByHash(ctx context.Context, hash []byte) (oauthBus.Code, error) {
+	data := map[string]any{
+		"code_hash": hash,
+	}
+
+	const q = `SELECT * FROM oauth_codes WHERE code_hash = :code_hash`
+
+	ctx, span := s.tracer.Start(ctx, "oauth.store.queryCodeByHash")
+	defer span.End()
+
+	rows, err := s.db.NamedQueryContext(ctx, q, data)
+	if err != nil {
+		return oauthBus.Code{}, fmt.Errorf("namedQueryContext: %w", err)
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return oauthBus.Code{}, fmt.Errorf("preparing next row to scan: %w", err)
+		}
+		return oauthBus.Code{}, oauthBus.ErrInvalidGrant
+	}
+
+	var c code
+	if err := rows.StructScan(&c); err != nil {
+		return oauthBus.Code{}, fmt.Errorf("structScan: %w", err)
+	}
+
+	return toBusCode(c), nil
+}
+
+func (s *Store) UseCode(ctx context.Context, c oauthBus.Code, now time.Time) error {
+	data := map[string]any{
+		"code_hash": c.Hash,
+		"used_at":   now,
+	}
+
+	//the "used_at IS NULL" makes sure a code is only exchanged once.
+	const q = `UPDATE oauth_codes SET used_at = :used_at WHERE code_hash = :code_hash AND used_at IS NULL`
+
+	ctx, span := s.tracer.Start(ctx, "oauth.store.useCode")
+	defer span.End()
+
+	res, err := s.db.NamedExecContext(ctx, q, data)
+	if err != nil {
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rowsAffected: %w", err)
+	}
+
+	if n == 0 {
+		return oauthBus.ErrInvalidGrant
+	}
+
+	return nil
+}
+
+func (s *Store) DeleteExpiredCodes(ctx context.Context, now time.Time) (int, error) {
+	data := map[string]any{
+		"now": now,
+	}
+
+	const q = `DELETE FROM oauth_codes WHERE expires_at < :now`
+
+	ctx, span := s.tracer.Start(ctx, "oauth.store.deleteExpiredCodes")
+	defer span.End()
+
+	return s.exec(ctx, q, data)
+}
+
+// ==============================================================================
+
+func (s *Store) CreateRefreshToken(ctx context.Context, rt oauthBus.RefreshToken) error {
+	const q = `
+	INSERT INTO oauth_refresh_tokens (id,token_hash,family_id,session_id,client_id,user_id,scopes,amr,auth_time,expires_at,revoked_at,created_at)
+	VALUES (:id,:token_hash,:family_id,:session_id,:client_id,:user_id,:scopes,:amr,:auth_time,:expires_at,:revoked_at,:created_at)
+	`
+
+	ctx, span := s.tracer.Start(ctx, "oauth.store.createRefreshToken")
+	defer span.End()
+
+	if _, err := s.db.NamedExecContext(ctx, q, fromBusRefreshToken(rt)); err != nil {
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) QueryRefreshTokenByHash(ctx context.Context, hash []byte) (oauthBus.RefreshToken, error) {
+	data := map[string]any{
+		"token_hash": hash,
+	}
+
+	const q = `SELECT * FROM oauth_refresh_tokens WHERE token_hash = :token_hash`
+
+	ctx, span := s.tracer.Start(ctx, "oauth.store.queryRefreshTokenByHash")
+	defer span.End()
+
+	rows, err := s.db.NamedQueryContext(ctx, q, data)
+	if err != nil {
+		return oauthBus.RefreshToken{}, fmt.Errorf("namedQueryContext: %w", err)
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return oauthBus.RefreshToken{}, fmt.Errorf("preparing next row to scan: %w", err)
+		}
+		return oauthBus.RefreshToken{}, oauthBus.ErrInvalidGrant
+	}
+
+	var rt refreshToken
+	if err := rows.StructScan(&rt); err != nil {
+		return oauthBus.RefreshToken{}, fmt.Errorf("structScan: %w", err)
+	}
+
+	return toBusRefreshToken(rt), nil
+}
+
+func (s *Store) RevokeRefreshToken(ctx context.Context, rt oauthBus.RefreshToken, now time.Time) error {
+	data := map[string]any{
+		"id":         rt.ID,
+		"revoked_at": now,
+	}
+
+	//the "revoked_at IS NULL" makes sure a token is only rotated once.
+	const q = `UPDATE oauth_refresh_tokens SET revoked_at = :revoked_at WHERE id = :id AND revoked_at IS NULL`
+
+	ctx, span := s.tracer.Start(ctx, "oauth.store.revokeRefreshToken")
+	defer span.End()
+
+	n, err := s.exec(ctx, q, data)
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return oauthBus.ErrInvalidGrant
+	}
+
+	return nil
+}
+
+func (s *Store) RevokeFamily(ctx context.Context, familyID uuid.UUID, now time.Time) error {
+	data := map[string]any{
+		"family_id":  familyID,
+		"revoked_at": now,
+	}
+
+	const q = `UPDATE oauth_refresh_tokens SET revoked_at = :revoked_at WHERE family_id = :family_id AND revoked_at IS NULL`
+
+	ctx, span := s.tracer.Start(ctx, "oauth.store.revokeFamily")
+	defer span.End()
+
+	if _, err := s.exec(ctx, q, data); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Store) DeleteExpiredRefreshTokens(ctx context.Context, now time.Time) (int, error) {
+	data := map[string]any{
+		"now": now,
+	}
+
+	const q = `DELETE FROM oauth_refresh_tokens WHERE expires_at < :now`
+
+	ctx, span := s.tracer.Start(ctx, "oauth.store.deleteExpiredRefreshTokens")
+	defer span.End()
+
+	return s.exec(ctx, q, data)
+}
+
+// ==============================================================================
+
+// exec runs a statement and returns the number of rows it touched.
+func (s *Store) exec(ctx context.Context, q string, data map[string]any) (int, error) {
+	res, err := s.db.NamedExecContext(ctx, q, data)
+	if err != nil {
+		return 0, fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("rowsAffected: %w", err)
+	}
+
+	return int(n), nil
+}
@@ -0,0 +1,181 @@
+package oauthdb
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	oauthBus "github.com/hamidoujand/jumble/internal/domains/oauth/bus"
+	"github.com/hamidoujand/jumble/internal/sqldb"
+)
+
+type client struct {
+	ID           uuid.UUID       `db:"id"`
+	Name         string          `db:"name"`
+	SecretHash   []byte          `db:"secret_hash"`
+	RedirectURIs sqldb.TextArray `db:"redirect_uris"`
+	GrantTypes   sqldb.TextArray `db:"grant_types"`
+	Scopes       sqldb.TextArray `db:"scopes"`
+	CreatedBy    uuid.NullUUID   `db:"created_by"`
+	CreatedAt    time.Time       `db:"created_at"`
+	UpdatedAt    time.Time       `db:"updated_at"`
+}
+
+func fromBusClient(c oauthBus.Client) client {
+	var createdBy uuid.NullUUID
+	if c.CreatedBy != nil {
+		createdBy = uuid.NullUUID{UUID: *c.CreatedBy, Valid: true}
+	}
+
+	return client{
+		ID:           c.ID,
+		Name:         c.Name,
+		SecretHash:   c.SecretHash,
+		RedirectURIs: sqldb.TextArray(c.RedirectURIs),
+		GrantTypes:   sqldb.TextArray(c.GrantTypes),
+		Scopes:       sqldb.TextArray(c.Scopes),
+		CreatedBy:    createdBy,
+		CreatedAt:    c.CreatedAt,
+		UpdatedAt:    c.UpdatedAt,
+	}
+}
+
+func toBusClient(c client) oauthBus.Client {
+	var createdBy *uuid.UUID
+	if c.CreatedBy.Valid {
+		createdBy = &c.CreatedBy.UUID
+	}
+
+	return oauthBus.Client{
+		ID:           c.ID,
+		Name:         c.Name,
+		SecretHash:   c.SecretHash,
+		RedirectURIs: []string(c.RedirectURIs),
+		GrantTypes:   []string(c.GrantTypes),
+		Scopes:       []string(c.Scopes),
+		CreatedBy:    createdBy,
+		CreatedAt:    c.CreatedAt,
+		UpdatedAt:    c.UpdatedAt,
+	}
+}
+
+type code struct {
+	Hash          []byte          `db:"code_hash"`
+	FamilyID      uuid.UUID       `db:"family_id"`
+	ClientID      uuid.UUID       `db:"client_id"`
+	UserID        uuid.UUID       `db:"user_id"`
+	RedirectURI   string          `db:"redirect_uri"`
+	Scopes        sqldb.TextArray `db:"scopes"`
+	Nonce         string          `db:"nonce"`
+	CodeChallenge string          `db:"code_challenge"`
+	AMR           sqldb.TextArray `db:"amr"`
+	AuthTime      time.Time       `db:"auth_time"`
+	ExpiresAt     time.Time       `db:"expires_at"`
+	UsedAt        sql.NullTime    `db:"used_at"`
+	CreatedAt     time.Time       `db:"created_at"`
+}
+
+func fromBusCode(c oauthBus.Code) code {
+	var usedAt sql.NullTime
+	if c.UsedAt != nil {
+		usedAt = sql.NullTime{Time: *c.UsedAt, Valid: true}
+	}
+
+	return code{
+		Hash:          c.Hash,
+		FamilyID:      c.FamilyID,
+		ClientID:      c.ClientID,
+		UserID:        c.UserID,
+		RedirectURI:   c.RedirectURI,
+		Scopes:        sqldb.TextArray(c.Scopes),
+		Nonce:         c.Nonce,
+		CodeChallenge: c.CodeChallenge,
+		AMR:           sqldb.TextArray(c.AMR),
+		AuthTime:      c.AuthTime,
+		ExpiresAt:     c.ExpiresAt,
+		UsedAt:        usedAt,
+		CreatedAt:     c.CreatedAt,
+	}
+}
+
+func toBusCode(c code) oauthBus.Code {
+	var usedAt *time.Time
+	if c.UsedAt.Valid {
+		usedAt = &c.UsedAt.Time
+	}
+
+	return oauthBus.Code{
+		Hash:          c.Hash,
+		FamilyID:      c.FamilyID,
+		ClientID:      c.ClientID,
+		UserID:        c.UserID,
+		RedirectURI:   c.RedirectURI,
+		Scopes:        []string(c.Scopes),
+		Nonce:         c.Nonce,
+		CodeChallenge: c.CodeChallenge,
+		AMR:           []string(c.AMR),
+		AuthTime:      c.AuthTime,
+		ExpiresAt:     c.ExpiresAt,
+		UsedAt:        usedAt,
+		CreatedAt:     c.CreatedAt,
+	}
+}
+
+type refreshToken struct {
+	ID        uuid.UUID       `db:"id"`
+	Hash      []byte          `db:"token_hash"`
+	FamilyID  uuid.UUID       `db:"family_id"`
+	SessionID uuid.UUID       `db:"session_id"`
+	ClientID  uuid.UUID       `db:"client_id"`
+	UserID    uuid.UUID       `db:"user_id"`
+	Scopes    sqldb.TextArray `db:"scopes"`
+	AMR       sqldb.TextArray `db:"amr"`
+	AuthTime  time.Time       `db:"auth_time"`
+	ExpiresAt time.Time       `db:"expires_at"`
+	RevokedAt sql.NullTime    `db:"revoked_at"`
+	CreatedAt time.Time       `db:"created_at"`
+}
+
+func fromBusRefreshToken(rt oauthBus.RefreshToken) refreshToken {
+	var revokedAt sql.NullTime
+	if rt.RevokedAt != nil {
+		revokedAt = sql.NullTime{Time: *rt.RevokedAt, Valid: true}
+	}
+
+	return refreshToken{
+		ID:        rt.ID,
+		Hash:      rt.Hash,
+		FamilyID:  rt.FamilyID,
+		SessionID: rt.SessionID,
+		ClientID:  rt.ClientID,
+		UserID:    rt.UserID,
+		Scopes:    sqldb.TextArray(rt.Scopes),
+		AMR:       sqldb.TextArray(rt.AMR),
+		AuthTime:  rt.AuthTime,
+		ExpiresAt: rt.ExpiresAt,
+		RevokedAt: revokedAt,
+		CreatedAt: rt.CreatedAt,
+	}
+}
+
+func toBusRefreshToken(rt refreshToken) oauthBus.RefreshToken {
+	var revokedAt *time.Time
+	if rt.RevokedAt.Valid {
+		revokedAt = &rt.RevokedAt.Time
+	}
+
+	return oauthBus.RefreshToken{
+		ID:        rt.ID,
+		Hash:      rt.Hash,
+		FamilyID:  rt.FamilyID,
+		SessionID: rt.SessionID,
+		ClientID:  rt.ClientID,
+		UserID:    rt.UserID,
+		Scopes:    []string(rt.Scopes),
+		AMR:       []string(rt.AMR),
+		AuthTime:  rt.AuthTime,
+		ExpiresAt: rt.ExpiresAt,
+		RevokedAt: revokedAt,
+		CreatedAt: rt.CreatedAt,
+	}
+}
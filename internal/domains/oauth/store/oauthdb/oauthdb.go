@@ -0,0 +1,109 @@
+package oauthdb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	oauthBus "github.com/hamidoujand/jumble/internal/domains/oauth/bus"
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type Store struct {
+	db     *sqlx.DB
+	tracer trace.Tracer
+}
+
+func NewStore(db *sqlx.DB, tracer trace.Tracer) *Store {
+	return &Store{
+		db:     db,
+		tracer: tracer,
+	}
+}
+
+func (s *Store) CreateClient(ctx context.Context, c oauthBus.Client) error {
+	const q = `
+	INSERT INTO oauth_clients (id,name,secret_hash,redirect_uris,grant_types,scopes,created_by,created_at,updated_at)
+	VALUES (:id,:name,:secret_hash,:redirect_uris,:grant_types,:scopes,:created_by,:created_at,:updated_at)
+	`
+
+	ctx, span := s.tracer.Start(ctx, "oauth.store.createClient")
+	defer span.End()
+
+	if _, err := s.db.NamedExecContext(ctx, q, fromBusClient(c)); err != nil {
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) DeleteClient(ctx context.Context, c oauthBus.Client) error {
+	const q = `DELETE FROM oauth_clients WHERE id = :id`
+
+	ctx, span := s.tracer.Start(ctx, "oauth.store.deleteClient")
+	defer span.End()
+
+	if _, err := s.db.NamedExecContext(ctx, q, fromBusClient(c)); err != nil {
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) QueryClientByID(ctx context.Context, id uuid.UUID) (oauthBus.Client, error) {
+	data := map[string]any{
+		"id": id,
+	}
+
+	const q = `SELECT * FROM oauth_clients WHERE id = :id`
+
+	ctx, span := s.tracer.Start(ctx, "oauth.store.queryClientByID")
+	defer span.End()
+
+	cs, err := s.queryClients(ctx, q, data)
+	if err != nil {
+		return oauthBus.Client{}, err
+	}
+
+	if len(cs) == 0 {
+		return oauthBus.Client{}, oauthBus.ErrClientNotFound
+	}
+
+	return cs[0], nil
+}
+
+func (s *Store) QueryClients(ctx context.Context) ([]oauthBus.Client, error) {
+	const q = `SELECT * FROM oauth_clients ORDER BY name`
+
+	ctx, span := s.tracer.Start(ctx, "oauth.store.queryClients")
+	defer span.End()
+
+	return s.queryClients(ctx, q, map[string]any{})
+}
+
+// ==============================================================================
+
+func (s *Store) queryClients(ctx context.Context, q string, data map[string]any) ([]oauthBus.Client, error) {
+	rows, err := s.db.NamedQueryContext(ctx, q, data)
+	if err != nil {
+		return nil, fmt.Errorf("namedQueryContext: %w", err)
+	}
+
+	defer rows.Close()
+
+	var cs []oauthBus.Client
+	for rows.Next() {
+		var c client
+		if err := rows.StructScan(&c); err != nil {
+			return nil, fmt.Errorf("structScan: %w", err)
+		}
+		cs = append(cs, toBusClient(c))
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("preparing next row to scan: %w", err)
+	}
+
+	return cs, nil
+}
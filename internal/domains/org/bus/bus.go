@@ -0,0 +1,256 @@
+// Package bus provides the business logic of organizations, the tenants of the service.
+//
+// Users are not owned by an org, one account logs in with its email and joins any number of orgs
+// through memberships. That is why emails stay unique across the service, and a user is a member
+// of an org at most once.
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrOrgNotFound      = errors.New("organization not found")
+	ErrDuplicatedSlug   = errors.New("slug already in use")
+	ErrInvalidSlug      = errors.New("slugs are 3 to 64 lower case letters, digits or '-'")
+	ErrMemberNotFound   = errors.New("member not found")
+	ErrDuplicatedMember = errors.New("user is already a member")
+	ErrLastAdmin        = errors.New("an organization needs at least one admin")
+)
+
+// RoleAdmin is the role the creator of an org gets, it manages the org and its members.
+const RoleAdmin = "admin"
+
+var slug = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{2,63}$`)
+
+type store interface {
+	Create(ctx context.Context, o Org, creator Member) error
+	Update(ctx context.Context, o Org) error
+	Delete(ctx context.Context, o Org) error
+	QueryByID(ctx context.Context, id uuid.UUID) (Org, error)
+	QueryByUser(ctx context.Context, userID uuid.UUID) ([]Org, error)
+	CreateMember(ctx context.Context, m Member) error
+	UpdateMember(ctx context.Context, m Member) error
+	DeleteMember(ctx context.Context, m Member) error
+	QueryMember(ctx context.Context, orgID uuid.UUID, userID uuid.UUID) (Member, error)
+	QueryMembers(ctx context.Context, orgID uuid.UUID) ([]Member, error)
+}
+
+// roleValidator makes sure roles exist before they are assigned, the role bus satisfies it.
+type roleValidator interface {
+	Validate(ctx context.Context, names []string) error
+}
+
+type Bus struct {
+	store store
+	roles roleValidator
+}
+
+// Option configures optional dependencies of the Bus.
+type Option func(*Bus)
+
+// WithRoleValidator rejects members with roles that do not exist.
+func WithRoleValidator(v roleValidator) Option {
+	return func(b *Bus) {
+		b.roles = v
+	}
+}
+
+func New(store store, opts ...Option) *Bus {
+	b := Bus{store: store}
+
+	for _, opt := range opts {
+		opt(&b)
+	}
+
+	return &b
+}
+
+// Create creates the org with the creator as its first admin.
+func (b *Bus) Create(ctx context.Context, creatorID uuid.UUID, no NewOrg) (Org, error) {
+	if !slug.MatchString(no.Slug) {
+		return Org{}, ErrInvalidSlug
+	}
+
+	now := time.Now().Truncate(time.Microsecond)
+
+	o := Org{
+		ID:        uuid.New(),
+		Name:      no.Name,
+		Slug:      no.Slug,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	creator := Member{
+		OrgID:     o.ID,
+		UserID:    creatorID,
+		Roles:     []string{RoleAdmin},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if err := b.store.Create(ctx, o, creator); err != nil {
+		return Org{}, fmt.Errorf("create: %w", err)
+	}
+
+	return o, nil
+}
+
+func (b *Bus) Update(ctx context.Context, o Org, uo UpdateOrg) (Org, error) {
+	if uo.Name != nil {
+		o.Name = *uo.Name
+	}
+
+	o.UpdatedAt = time.Now().Truncate(time.Microsecond)
+	if err := b.store.Update(ctx, o); err != nil {
+		return Org{}, fmt.Errorf("update: %w", err)
+	}
+
+	return o, nil
+}
+
+func (b *Bus) Delete(ctx context.Context, o Org) error {
+	if err := b.store.Delete(ctx, o); err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+
+	return nil
+}
+
+func (b *Bus) QueryByID(ctx context.Context, id uuid.UUID) (Org, error) {
+	o, err := b.store.QueryByID(ctx, id)
+	if err != nil {
+		return Org{}, fmt.Errorf("queryByID: %w", err)
+	}
+
+	return o, nil
+}
+
+// QueryByUser returns the orgs the user is a member of.
+func (b *Bus) QueryByUser(ctx context.Context, userID uuid.UUID) ([]Org, error) {
+	orgs, err := b.store.QueryByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("queryByUser: %w", err)
+	}
+
+	return orgs, nil
+}
+
+// ==============================================================================
+
+func (b *Bus) AddMember(ctx context.Context, o Org, userID uuid.UUID, roles []string) (Member, error) {
+	if err := b.validateRoles(ctx, roles); err != nil {
+		return Member{}, err
+	}
+
+	now := time.Now().Truncate(time.Microsecond)
+
+	m := Member{
+		OrgID:     o.ID,
+		UserID:    userID,
+		Roles:     roles,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if err := b.store.CreateMember(ctx, m); err != nil {
+		return Member{}, fmt.Errorf("createMember: %w", err)
+	}
+
+	return m, nil
+}
+
+func (b *Bus) UpdateMember(ctx context.Context, m Member, roles []string) (Member, error) {
+	if err := b.validateRoles(ctx, roles); err != nil {
+		return Member{}, err
+	}
+
+	if isAdmin(m) && !isAdmin(Member{Roles: roles}) {
+		if err := b.checkLastAdmin(ctx, m); err != nil {
+			return Member{}, err
+		}
+	}
+
+	m.Roles = roles
+	m.UpdatedAt = time.Now().Truncate(time.Microsecond)
+
+	if err := b.store.UpdateMember(ctx, m); err != nil {
+		return Member{}, fmt.Errorf("updateMember: %w", err)
+	}
+
+	return m, nil
+}
+
+func (b *Bus) RemoveMember(ctx context.Context, m Member) error {
+	if isAdmin(m) {
+		if err := b.checkLastAdmin(ctx, m); err != nil {
+			return err
+		}
+	}
+
+	if err := b.store.DeleteMember(ctx, m); err != nil {
+		return fmt.Errorf("deleteMember: %w", err)
+	}
+
+	return nil
+}
+
+func (b *Bus) QueryMember(ctx context.Context, orgID uuid.UUID, userID uuid.UUID) (Member, error) {
+	m, err := b.store.QueryMember(ctx, orgID, userID)
+	if err != nil {
+		return Member{}, fmt.Errorf("queryMember: %w", err)
+	}
+
+	return m, nil
+}
+
+func (b *Bus) QueryMembers(ctx context.Context, orgID uuid.UUID) ([]Member, error) {
+	ms, err := b.store.QueryMembers(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("queryMembers: %w", err)
+	}
+
+	return ms, nil
+}
+
+// ==============================================================================
+
+func (b *Bus) validateRoles(ctx context.Context, roles []string) error {
+	if b.roles == nil || len(roles) == 0 {
+		return nil
+	}
+
+	if err := b.roles.Validate(ctx, roles); err != nil {
+		return fmt.Errorf("validateRoles: %w", err)
+	}
+
+	return nil
+}
+
+// checkLastAdmin keeps the org from losing its last admin, nobody could manage it after that.
+func (b *Bus) checkLastAdmin(ctx context.Context, m Member) error {
+	ms, err := b.store.QueryMembers(ctx, m.OrgID)
+	if err != nil {
+		return fmt.Errorf("queryMembers: %w", err)
+	}
+
+	for _, other := range ms {
+		if other.UserID != m.UserID && isAdmin(other) {
+			return nil
+		}
+	}
+
+	return ErrLastAdmin
+}
+
+func isAdmin(m Member) bool {
+	return slices.Contains(m.Roles, RoleAdmin)
+}
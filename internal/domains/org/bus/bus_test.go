@@ -0,0 +1,198 @@
+package bus_test
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/mail"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/hamidoujand/jumble/internal/dbtest"
+	"github.com/hamidoujand/jumble/internal/domains/org/bus"
+	"github.com/hamidoujand/jumble/internal/domains/org/store/orgdb"
+	roleBus "github.com/hamidoujand/jumble/internal/domains/role/bus"
+	"github.com/hamidoujand/jumble/internal/domains/role/store/roledb"
+	userBus "github.com/hamidoujand/jumble/internal/domains/user/bus"
+	"github.com/hamidoujand/jumble/internal/domains/user/store/userdb"
+	"github.com/hamidoujand/jumble/internal/page"
+	"github.com/hamidoujand/jumble/pkg/docker"
+	"github.com/hamidoujand/jumble/pkg/telemetry"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var container docker.Container
+var tracer trace.Tracer
+
+func TestMain(m *testing.M) {
+	// before all
+	var err error
+	container, err = dbtest.CreateDBContainer()
+	if err != nil {
+		log.Fatalf("createDBContainer: %s", err)
+	}
+
+	defer docker.StopContainer(container.Name)
+	cfg := telemetry.Config{
+		ServiceName: "org_bus_test",
+		Host:        "",
+		Build:       "v0.0.1",
+	}
+
+	cleanup, err := telemetry.SetupOTelSDK(cfg)
+	if err != nil {
+		log.Fatalf("setupOTelSDK: %s", err)
+	}
+
+	tracer = otel.Tracer("org_bus_tests")
+
+	defer cleanup(context.Background())
+
+	// tests
+	os.Exit(m.Run())
+
+}
+
+func Test_Orgs(t *testing.T) {
+	t.Parallel()
+
+	db := dbtest.New(t, container, "orgs")
+	rb := roleBus.New(roledb.NewStore(db, tracer))
+	b := bus.New(orgdb.NewStore(db, tracer), bus.WithRoleValidator(rb))
+	usrBus := userBus.New(userdb.NewStore(db, tracer))
+
+	roles, err := userBus.ParseManyRoles([]string{"user"})
+	if err != nil {
+		t.Fatalf("failed to parse roles: %s", err)
+	}
+
+	newUser := func(name string, email string) userBus.User {
+		usr, err := usrBus.Create(context.Background(), userBus.NewUser{
+			Name:       name,
+			Email:      mail.Address{Name: name, Address: email},
+			Roles:      roles,
+			Department: "sales",
+			Password:   "test1234",
+		})
+		if err != nil {
+			t.Fatalf("failed to create a user: %s", err)
+		}
+		return usr
+	}
+
+	owner := newUser("John Doe", "john@gmail.com")
+	jane := newUser("Jane Doe", "jane@gmail.com")
+	outsider := newUser("Bob Smith", "bob@gmail.com")
+
+	o, err := b.Create(context.Background(), owner.ID, bus.NewOrg{Name: "Acme", Slug: "acme"})
+	if err != nil {
+		t.Fatalf("failed to create an org: %s", err)
+	}
+
+	if _, err := b.Create(context.Background(), jane.ID, bus.NewOrg{Name: "Acme 2", Slug: "acme"}); !errors.Is(err, bus.ErrDuplicatedSlug) {
+		t.Errorf("err=%s, got=%v", bus.ErrDuplicatedSlug, err)
+	}
+
+	if _, err := b.Create(context.Background(), jane.ID, bus.NewOrg{Name: "Acme", Slug: "Not Valid"}); !errors.Is(err, bus.ErrInvalidSlug) {
+		t.Errorf("err=%s, got=%v", bus.ErrInvalidSlug, err)
+	}
+
+	//the creator is the first admin.
+	m, err := b.QueryMember(context.Background(), o.ID, owner.ID)
+	if err != nil {
+		t.Fatalf("failed to query the creator: %s", err)
+	}
+
+	if !slices.Equal(m.Roles, []string{bus.RoleAdmin}) {
+		t.Errorf("roles=%v, got=%v", []string{bus.RoleAdmin}, m.Roles)
+	}
+
+	if _, err := b.AddMember(context.Background(), o, jane.ID, []string{"auditor"}); !errors.Is(err, roleBus.ErrRoleNotFound) {
+		t.Errorf("err=%s, got=%v", roleBus.ErrRoleNotFound, err)
+	}
+
+	janeM, err := b.AddMember(context.Background(), o, jane.ID, []string{"user"})
+	if err != nil {
+		t.Fatalf("failed to add a member: %s", err)
+	}
+
+	if _, err := b.AddMember(context.Background(), o, jane.ID, []string{"user"}); !errors.Is(err, bus.ErrDuplicatedMember) {
+		t.Errorf("err=%s, got=%v", bus.ErrDuplicatedMember, err)
+	}
+
+	if _, err := b.AddMember(context.Background(), o, uuid.New(), []string{"user"}); !errors.Is(err, bus.ErrMemberNotFound) {
+		t.Errorf("err=%s, got=%v", bus.ErrMemberNotFound, err)
+	}
+
+	if _, err := b.QueryMember(context.Background(), o.ID, outsider.ID); !errors.Is(err, bus.ErrMemberNotFound) {
+		t.Errorf("err=%s, got=%v", bus.ErrMemberNotFound, err)
+	}
+
+	orgs, err := b.QueryByUser(context.Background(), jane.ID)
+	if err != nil {
+		t.Fatalf("failed to query orgs of the user: %s", err)
+	}
+
+	if len(orgs) != 1 || orgs[0].ID != o.ID {
+		t.Errorf("expected jane to be in %s, got=%v", o.ID, orgs)
+	}
+
+	//users are scoped to the org they are queried in.
+	orderBy, err := userBus.ParseOrderBy("name,asc")
+	if err != nil {
+		t.Fatalf("expected to parse order by clause: %s", err)
+	}
+
+	p, err := page.Parse("1", "10")
+	if err != nil {
+		t.Fatalf("expected to parse page: %s", err)
+	}
+
+	orgID := o.ID
+	users, err := usrBus.Query(context.Background(), userBus.QueryFilter{OrgID: &orgID}, orderBy, p)
+	if err != nil {
+		t.Fatalf("failed to query users of the org: %s", err)
+	}
+
+	if len(users) != 2 {
+		t.Errorf("expected 2 users in the org, got=%d", len(users))
+	}
+
+	for _, usr := range users {
+		if usr.ID == outsider.ID {
+			t.Errorf("did not expect %s in the org", outsider.ID)
+		}
+	}
+
+	//the last admin can not leave or be demoted.
+	if _, err := b.UpdateMember(context.Background(), m, []string{"user"}); !errors.Is(err, bus.ErrLastAdmin) {
+		t.Errorf("err=%s, got=%v", bus.ErrLastAdmin, err)
+	}
+
+	if err := b.RemoveMember(context.Background(), m); !errors.Is(err, bus.ErrLastAdmin) {
+		t.Errorf("err=%s, got=%v", bus.ErrLastAdmin, err)
+	}
+
+	if _, err := b.UpdateMember(context.Background(), janeM, []string{bus.RoleAdmin}); err != nil {
+		t.Fatalf("failed to promote a member: %s", err)
+	}
+
+	if err := b.RemoveMember(context.Background(), m); err != nil {
+		t.Fatalf("failed to remove the creator: %s", err)
+	}
+
+	if err := b.Delete(context.Background(), o); err != nil {
+		t.Fatalf("failed to delete the org: %s", err)
+	}
+
+	if _, err := b.QueryByID(context.Background(), o.ID); !errors.Is(err, bus.ErrOrgNotFound) {
+		t.Errorf("err=%s, got=%v", bus.ErrOrgNotFound, err)
+	}
+
+	if _, err := b.QueryMember(context.Background(), o.ID, jane.ID); !errors.Is(err, bus.ErrMemberNotFound) {
+		t.Errorf("err=%s, got=%v", bus.ErrMemberNotFound, err)
+	}
+}
@@ -0,0 +1,34 @@
+package bus
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Org is a tenant, users take part in it through their membership.
+type Org struct {
+	ID        uuid.UUID
+	Name      string
+	Slug      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type NewOrg struct {
+	Name string
+	Slug string
+}
+
+type UpdateOrg struct {
+	Name *string
+}
+
+// Member ties a user to an org, Roles only apply within that org.
+type Member struct {
+	OrgID     uuid.UUID
+	UserID    uuid.UUID
+	Roles     []string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
@@ -0,0 +1,400 @@
+// Package handler provides endpoints to interact with organizations and their members.
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/hamidoujand/jumble/internal/auth"
+	orgBus "github.com/hamidoujand/jumble/internal/domains/org/bus"
+	roleBus "github.com/hamidoujand/jumble/internal/domains/role/bus"
+	userBus "github.com/hamidoujand/jumble/internal/domains/user/bus"
+	"github.com/hamidoujand/jumble/internal/errs"
+	"github.com/hamidoujand/jumble/internal/mid"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type handler struct {
+	orgBus  *orgBus.Bus
+	userBus *userBus.Bus
+	a       *auth.Auth
+	kid     string
+	tracer  trace.Tracer
+}
+
+func (h *handler) CreateOrg(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "org.handler.createOrg")
+	defer span.End()
+
+	usr, ok := currentUser(c)
+	if !ok {
+		c.Error(errs.New(http.StatusUnauthorized, "%s", http.StatusText(http.StatusUnauthorized)))
+		return
+	}
+
+	var no newOrg
+	if err := c.ShouldBindJSON(&no); err != nil {
+		c.Error(err)
+		return
+	}
+
+	o, err := h.orgBus.Create(ctx, usr.ID, toBusNewOrg(no))
+	if errors.Is(err, orgBus.ErrInvalidSlug) || errors.Is(err, orgBus.ErrDuplicatedSlug) {
+		c.Error(errs.New(http.StatusBadRequest, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "create: %s", err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, toAppOrg(o))
+}
+
+// QueryOrgs returns the orgs the current user is a member of.
+func (h *handler) QueryOrgs(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "org.handler.queryOrgs")
+	defer span.End()
+
+	usr, ok := currentUser(c)
+	if !ok {
+		c.Error(errs.New(http.StatusUnauthorized, "%s", http.StatusText(http.StatusUnauthorized)))
+		return
+	}
+
+	orgs, err := h.orgBus.QueryByUser(ctx, usr.ID)
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "queryByUser: %s", err))
+		return
+	}
+
+	apps := make([]org, len(orgs))
+	for i, o := range orgs {
+		apps[i] = toAppOrg(o)
+	}
+
+	c.JSON(http.StatusOK, apps)
+}
+
+func (h *handler) QueryOrgByID(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "org.handler.queryOrgByID")
+	defer span.End()
+
+	o, ok := h.loadOrg(ctx, c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, toAppOrg(o))
+}
+
+func (h *handler) UpdateOrg(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "org.handler.updateOrg")
+	defer span.End()
+
+	var uo updateOrg
+	if err := c.ShouldBindJSON(&uo); err != nil {
+		c.Error(err)
+		return
+	}
+
+	o, ok := h.loadOrg(ctx, c)
+	if !ok {
+		return
+	}
+
+	updated, err := h.orgBus.Update(ctx, o, toBusUpdateOrg(uo))
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "update: %s", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, toAppOrg(updated))
+}
+
+func (h *handler) DeleteOrg(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "org.handler.deleteOrg")
+	defer span.End()
+
+	o, ok := h.loadOrg(ctx, c)
+	if !ok {
+		return
+	}
+
+	if err := h.orgBus.Delete(ctx, o); err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "delete: %s", err))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// IssueToken exchanges the token of a member for one scoped to the org, it expires together with
+// the token it was exchanged for.
+func (h *handler) IssueToken(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "org.handler.issueToken")
+	defer span.End()
+
+	usr, ok := currentUser(c)
+	if !ok {
+		c.Error(errs.New(http.StatusUnauthorized, "%s", http.StatusText(http.StatusUnauthorized)))
+		return
+	}
+
+	val, ok := c.Get("claims")
+	if !ok {
+		c.Error(errs.New(http.StatusUnauthorized, "%s", http.StatusText(http.StatusUnauthorized)))
+		return
+	}
+
+	claims, ok := val.(auth.Claims)
+	if !ok {
+		c.Error(errs.New(http.StatusUnauthorized, "%s", http.StatusText(http.StatusUnauthorized)))
+		return
+	}
+
+	o, ok := h.loadOrg(ctx, c)
+	if !ok {
+		return
+	}
+
+	m, err := h.orgBus.QueryMember(ctx, o.ID, usr.ID)
+	if errors.Is(err, orgBus.ErrMemberNotFound) {
+		c.Error(errs.New(http.StatusForbidden, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "queryMember: %s", err))
+		return
+	}
+
+	claims.Org = o.ID.String()
+	claims.Roles = m.Roles
+	claims.IssuedAt = jwt.NewNumericDate(time.Now())
+
+	token, err := h.a.GenerateToken(h.kid, claims)
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "generateToken: %s", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, orgToken{Token: token})
+}
+
+// ==============================================================================
+
+func (h *handler) QueryMembers(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "org.handler.queryMembers")
+	defer span.End()
+
+	o, ok := h.loadOrg(ctx, c)
+	if !ok {
+		return
+	}
+
+	ms, err := h.orgBus.QueryMembers(ctx, o.ID)
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "queryMembers: %s", err))
+		return
+	}
+
+	apps := make([]member, len(ms))
+	for i, m := range ms {
+		apps[i] = toAppMember(m)
+	}
+
+	c.JSON(http.StatusOK, apps)
+}
+
+func (h *handler) AddMember(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "org.handler.addMember")
+	defer span.End()
+
+	var nm newMember
+	if err := c.ShouldBindJSON(&nm); err != nil {
+		c.Error(err)
+		return
+	}
+
+	userID, err := parseUserID(nm.UserID)
+	if err != nil {
+		c.Error(errs.New(http.StatusBadRequest, "%s", err))
+		return
+	}
+
+	o, ok := h.loadOrg(ctx, c)
+	if !ok {
+		return
+	}
+
+	usr, err := h.userBus.QueryByID(ctx, userID)
+	if errors.Is(err, userBus.ErrUserNotFound) {
+		c.Error(errs.New(http.StatusBadRequest, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "queryByID: %s", err))
+		return
+	}
+
+	m, err := h.orgBus.AddMember(ctx, o, usr.ID, nm.Roles)
+	if errors.Is(err, roleBus.ErrRoleNotFound) {
+		c.Error(errs.New(http.StatusBadRequest, "%s", err))
+		return
+	}
+
+	if errors.Is(err, orgBus.ErrDuplicatedMember) {
+		c.Error(errs.New(http.StatusConflict, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "addMember: %s", err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, toAppMember(m))
+}
+
+func (h *handler) UpdateMember(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "org.handler.updateMember")
+	defer span.End()
+
+	var um updateMember
+	if err := c.ShouldBindJSON(&um); err != nil {
+		c.Error(err)
+		return
+	}
+
+	m, ok := h.loadMember(ctx, c)
+	if !ok {
+		return
+	}
+
+	updated, err := h.orgBus.UpdateMember(ctx, m, um.Roles)
+	if errors.Is(err, roleBus.ErrRoleNotFound) {
+		c.Error(errs.New(http.StatusBadRequest, "%s", err))
+		return
+	}
+
+	if errors.Is(err, orgBus.ErrLastAdmin) {
+		c.Error(errs.New(http.StatusConflict, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "updateMember: %s", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, toAppMember(updated))
+}
+
+func (h *handler) RemoveMember(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "org.handler.removeMember")
+	defer span.End()
+
+	m, ok := h.loadMember(ctx, c)
+	if !ok {
+		return
+	}
+
+	err := h.orgBus.RemoveMember(ctx, m)
+	if errors.Is(err, orgBus.ErrLastAdmin) {
+		c.Error(errs.New(http.StatusConflict, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "removeMember: %s", err))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ==============================================================================
+
+// InOrg only lets org scoped tokens of the org in the path through, so the roles of a member
+// never apply to another org. It has to run after Authenticate.
+func InOrg() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		m, ok := mid.Membership(c)
+		if !ok || m.OrgID.String() != c.Param("id") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "token is not scoped to this org"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func currentUser(c *gin.Context) (userBus.User, bool) {
+	val, ok := c.Get("user")
+	if !ok {
+		return userBus.User{}, false
+	}
+
+	usr, ok := val.(userBus.User)
+	return usr, ok
+}
+
+// loadOrg fetches the org in the path, when it fails the error is already set on the context.
+func (h *handler) loadOrg(ctx context.Context, c *gin.Context) (orgBus.Org, bool) {
+	p := c.Param("id")
+
+	orgID, err := uuid.Parse(p)
+	if err != nil {
+		c.Error(errs.New(http.StatusBadRequest, "invalid id: %s", p))
+		return orgBus.Org{}, false
+	}
+
+	o, err := h.orgBus.QueryByID(ctx, orgID)
+	if errors.Is(err, orgBus.ErrOrgNotFound) {
+		c.Error(errs.New(http.StatusNotFound, "%s", err))
+		return orgBus.Org{}, false
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "queryByID: %s", err))
+		return orgBus.Org{}, false
+	}
+
+	return o, true
+}
+
+// loadMember fetches the member in the path, when it fails the error is already set on the context.
+func (h *handler) loadMember(ctx context.Context, c *gin.Context) (orgBus.Member, bool) {
+	o, ok := h.loadOrg(ctx, c)
+	if !ok {
+		return orgBus.Member{}, false
+	}
+
+	userID, err := parseUserID(c.Param("userId"))
+	if err != nil {
+		c.Error(errs.New(http.StatusBadRequest, "%s", err))
+		return orgBus.Member{}, false
+	}
+
+	m, err := h.orgBus.QueryMember(ctx, o.ID, userID)
+	if errors.Is(err, orgBus.ErrMemberNotFound) {
+		c.Error(errs.New(http.StatusNotFound, "%s", err))
+		return orgBus.Member{}, false
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "queryMember: %s", err))
+		return orgBus.Member{}, false
+	}
+
+	return m, true
+}
@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	orgBus "github.com/hamidoujand/jumble/internal/domains/org/bus"
+)
+
+type org struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Slug      string `json:"slug"`
+	CreatedAt string `json:"createdAt"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+func toAppOrg(o orgBus.Org) org {
+	return org{
+		ID:        o.ID.String(),
+		Name:      o.Name,
+		Slug:      o.Slug,
+		CreatedAt: o.CreatedAt.Format(time.RFC3339),
+		UpdatedAt: o.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+type member struct {
+	OrgID     string   `json:"orgId"`
+	UserID    string   `json:"userId"`
+	Roles     []string `json:"roles"`
+	CreatedAt string   `json:"createdAt"`
+	UpdatedAt string   `json:"updatedAt"`
+}
+
+func toAppMember(m orgBus.Member) member {
+	return member{
+		OrgID:     m.OrgID.String(),
+		UserID:    m.UserID.String(),
+		Roles:     m.Roles,
+		CreatedAt: m.CreatedAt.Format(time.RFC3339),
+		UpdatedAt: m.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+type orgToken struct {
+	Token string `json:"token"`
+}
+
+// ==============================================================================
+
+type newOrg struct {
+	Name string `json:"name" binding:"required,max=200"`
+	Slug string `json:"slug" binding:"required,max=64"`
+}
+
+func toBusNewOrg(no newOrg) orgBus.NewOrg {
+	return orgBus.NewOrg{
+		Name: no.Name,
+		Slug: no.Slug,
+	}
+}
+
+type updateOrg struct {
+	Name *string `json:"name" binding:"omitempty,min=1,max=200"`
+}
+
+func toBusUpdateOrg(uo updateOrg) orgBus.UpdateOrg {
+	return orgBus.UpdateOrg{
+		Name: uo.Name,
+	}
+}
+
+type newMember struct {
+	UserID string   `json:"userId" binding:"required"`
+	Roles  []string `json:"roles" binding:"required,gt=0,dive,required,max=32"`
+}
+
+func parseUserID(id string) (uuid.UUID, error) {
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid user id: %s", id)
+	}
+
+	return userID, nil
+}
+
+type updateMember struct {
+	Roles []string `json:"roles" binding:"required,gt=0,dive,required,max=32"`
+}
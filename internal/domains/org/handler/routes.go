@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/hamidoujand/jumble/internal/auth"
+	orgBus "github.com/hamidoujand/jumble/internal/domains/org/bus"
+	roleBus "github.com/hamidoujand/jumble/internal/domains/role/bus"
+	userBus "github.com/hamidoujand/jumble/internal/domains/user/bus"
+	"github.com/hamidoujand/jumble/internal/mid"
+	"github.com/hamidoujand/jumble/pkg/logger"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type Conf struct {
+	Router   *gin.Engine
+	AuthConf mid.AuthConf
+	OrgBus   *orgBus.Bus
+	RoleBus  *roleBus.Bus
+	UserBus  *userBus.Bus
+	Auth     *auth.Auth
+	Kid      string
+	Tracer   trace.Tracer
+	Logger   *logger.Logger
+}
+
+// RegisterRoutes takes the mux and register endpoints on it.
+func RegisterRoutes(cfg Conf) {
+	h := handler{
+		orgBus:  cfg.OrgBus,
+		userBus: cfg.UserBus,
+		a:       cfg.Auth,
+		kid:     cfg.Kid,
+		tracer:  cfg.Tracer,
+	}
+
+	authenticated := mid.Authenticate(cfg.AuthConf)
+
+	api := mid.RequireScope(auth.ScopeAPI)
+
+	can := func(perms ...string) gin.HandlerFunc {
+		return mid.RequirePermission(cfg.Logger, cfg.RoleBus, perms...)
+	}
+
+	orgs := cfg.Router.Group("/v1/orgs", authenticated, api)
+
+	//any user can start an org and swap their token for one scoped to an org they belong to.
+	orgs.POST("/", h.CreateOrg)
+	orgs.GET("/", h.QueryOrgs)
+	//org tokens would drop the impersonation, so impersonators stay out of orgs.
+	orgs.POST("/:id/token", mid.NotImpersonated(), h.IssueToken)
+
+	//everything else needs a token scoped to the org, permissions come from the member roles.
+	org := orgs.Group("/:id", InOrg())
+
+	org.GET("", h.QueryOrgByID)
+	org.PUT("", can(roleBus.PermOrgsWrite), h.UpdateOrg)
+	org.DELETE("", can(roleBus.PermOrgsWrite), h.DeleteOrg)
+	org.GET("/members", can(roleBus.PermOrgsMembersRead), h.QueryMembers)
+	org.POST("/members", mid.NotImpersonated(), can(roleBus.PermOrgsMembersWrite), h.AddMember)
+	org.PUT("/members/:userId", mid.NotImpersonated(), can(roleBus.PermOrgsMembersWrite), h.UpdateMember)
+	org.DELETE("/members/:userId", mid.NotImpersonated(), can(roleBus.PermOrgsMembersWrite), h.RemoveMember)
+}
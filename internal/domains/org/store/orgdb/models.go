@@ -0,0 +1,65 @@
+package orgdb
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	orgBus "github.com/hamidoujand/jumble/internal/domains/org/bus"
+	"github.com/hamidoujand/jumble/internal/sqldb"
+)
+
+type org struct {
+	ID        uuid.UUID `db:"id"`
+	Name      string    `db:"name"`
+	Slug      string    `db:"slug"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+func fromBusOrg(o orgBus.Org) org {
+	return org{
+		ID:        o.ID,
+		Name:      o.Name,
+		Slug:      o.Slug,
+		CreatedAt: o.CreatedAt,
+		UpdatedAt: o.UpdatedAt,
+	}
+}
+
+func toBusOrg(o org) orgBus.Org {
+	return orgBus.Org{
+		ID:        o.ID,
+		Name:      o.Name,
+		Slug:      o.Slug,
+		CreatedAt: o.CreatedAt,
+		UpdatedAt: o.UpdatedAt,
+	}
+}
+
+type member struct {
+	OrgID     uuid.UUID       `db:"org_id"`
+	UserID    uuid.UUID       `db:"user_id"`
+	Roles     sqldb.TextArray `db:"roles"`
+	CreatedAt time.Time       `db:"created_at"`
+	UpdatedAt time.Time       `db:"updated_at"`
+}
+
+func fromBusMember(m orgBus.Member) member {
+	return member{
+		OrgID:     m.OrgID,
+		UserID:    m.UserID,
+		Roles:     sqldb.TextArray(m.Roles),
+		CreatedAt: m.CreatedAt,
+		UpdatedAt: m.UpdatedAt,
+	}
+}
+
+func toBusMember(m member) orgBus.Member {
+	return orgBus.Member{
+		OrgID:     m.OrgID,
+		UserID:    m.UserID,
+		Roles:     []string(m.Roles),
+		CreatedAt: m.CreatedAt,
+		UpdatedAt: m.UpdatedAt,
+	}
+}
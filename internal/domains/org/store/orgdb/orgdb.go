@@ -0,0 +1,291 @@
+package orgdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	orgBus "github.com/hamidoujand/jumble/internal/domains/org/bus"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+)
+
+type Store struct {
+	db     *sqlx.DB
+	tracer trace.Tracer
+}
+
+func NewStore(db *sqlx.DB, tracer trace.Tracer) *Store {
+	return &Store{
+		db:     db,
+		tracer: tracer,
+	}
+}
+
+// Create stores the org and its first member together, an org without members can not be managed.
+func (s *Store) Create(ctx context.Context, o orgBus.Org, creator orgBus.Member) error {
+	const q = `
+	INSERT INTO organizations (id,name,slug,created_at,updated_at)
+	VALUES (:id,:name,:slug,:created_at,:updated_at)
+	`
+
+	ctx, span := s.tracer.Start(ctx, "org.store.create")
+	defer span.End()
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginTxx: %w", err)
+	}
+
+	defer tx.Rollback()
+
+	if _, err := tx.NamedExecContext(ctx, q, fromBusOrg(o)); err != nil {
+		var pgerror *pgconn.PgError
+		if errors.As(err, &pgerror) {
+			if pgerror.Code == uniqueViolation {
+				return orgBus.ErrDuplicatedSlug
+			}
+		}
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	if _, err := tx.NamedExecContext(ctx, insertMember, fromBusMember(creator)); err != nil {
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) Update(ctx context.Context, o orgBus.Org) error {
+	const q = `
+	UPDATE organizations 
+	SET 
+		name = :name,
+		updated_at = :updated_at
+	WHERE 
+		id = :id
+	`
+
+	ctx, span := s.tracer.Start(ctx, "org.store.update")
+	defer span.End()
+
+	if _, err := s.db.NamedExecContext(ctx, q, fromBusOrg(o)); err != nil {
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) Delete(ctx context.Context, o orgBus.Org) error {
+	const q = `DELETE FROM organizations WHERE id = :id`
+
+	ctx, span := s.tracer.Start(ctx, "org.store.delete")
+	defer span.End()
+
+	if _, err := s.db.NamedExecContext(ctx, q, fromBusOrg(o)); err != nil {
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) QueryByID(ctx context.Context, id uuid.UUID) (orgBus.Org, error) {
+	data := map[string]any{
+		"id": id,
+	}
+
+	const q = `SELECT * FROM organizations WHERE id = :id`
+
+	ctx, span := s.tracer.Start(ctx, "org.store.queryByID")
+	defer span.End()
+
+	rows, err := s.db.NamedQueryContext(ctx, q, data)
+	if err != nil {
+		return orgBus.Org{}, fmt.Errorf("namedQueryContext: %w", err)
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		return orgBus.Org{}, orgBus.ErrOrgNotFound
+	}
+
+	var o org
+	if err := rows.StructScan(&o); err != nil {
+		return orgBus.Org{}, fmt.Errorf("structScan: %w", err)
+	}
+
+	return toBusOrg(o), nil
+}
+
+func (s *Store) QueryByUser(ctx context.Context, userID uuid.UUID) ([]orgBus.Org, error) {
+	data := map[string]any{
+		"user_id": userID,
+	}
+
+	const q = `
+	SELECT o.* FROM organizations o 
+	JOIN org_members m ON m.org_id = o.id 
+	WHERE m.user_id = :user_id 
+	ORDER BY o.name
+	`
+
+	ctx, span := s.tracer.Start(ctx, "org.store.queryByUser")
+	defer span.End()
+
+	rows, err := s.db.NamedQueryContext(ctx, q, data)
+	if err != nil {
+		return nil, fmt.Errorf("namedQueryContext: %w", err)
+	}
+
+	defer rows.Close()
+
+	var orgs []orgBus.Org
+	for rows.Next() {
+		var o org
+		if err := rows.StructScan(&o); err != nil {
+			return nil, fmt.Errorf("structScan: %w", err)
+		}
+		orgs = append(orgs, toBusOrg(o))
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("preparing next row to scan: %w", err)
+	}
+
+	return orgs, nil
+}
+
+// ==============================================================================
+
+const insertMember = `
+	INSERT INTO org_members (org_id,user_id,roles,created_at,updated_at)
+	VALUES (:org_id,:user_id,:roles,:created_at,:updated_at)
+	`
+
+func (s *Store) CreateMember(ctx context.Context, m orgBus.Member) error {
+	ctx, span := s.tracer.Start(ctx, "org.store.createMember")
+	defer span.End()
+
+	if _, err := s.db.NamedExecContext(ctx, insertMember, fromBusMember(m)); err != nil {
+		var pgerror *pgconn.PgError
+		if errors.As(err, &pgerror) {
+			switch pgerror.Code {
+			case uniqueViolation:
+				return orgBus.ErrDuplicatedMember
+			case foreignKeyViolation:
+				return orgBus.ErrMemberNotFound
+			}
+		}
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) UpdateMember(ctx context.Context, m orgBus.Member) error {
+	const q = `
+	UPDATE org_members 
+	SET 
+		roles = :roles,
+		updated_at = :updated_at
+	WHERE 
+		org_id = :org_id AND user_id = :user_id
+	`
+
+	ctx, span := s.tracer.Start(ctx, "org.store.updateMember")
+	defer span.End()
+
+	if _, err := s.db.NamedExecContext(ctx, q, fromBusMember(m)); err != nil {
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) DeleteMember(ctx context.Context, m orgBus.Member) error {
+	const q = `DELETE FROM org_members WHERE org_id = :org_id AND user_id = :user_id`
+
+	ctx, span := s.tracer.Start(ctx, "org.store.deleteMember")
+	defer span.End()
+
+	if _, err := s.db.NamedExecContext(ctx, q, fromBusMember(m)); err != nil {
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) QueryMember(ctx context.Context, orgID uuid.UUID, userID uuid.UUID) (orgBus.Member, error) {
+	data := map[string]any{
+		"org_id":  orgID,
+		"user_id": userID,
+	}
+
+	const q = `SELECT * FROM org_members WHERE org_id = :org_id AND user_id = :user_id`
+
+	ctx, span := s.tracer.Start(ctx, "org.store.queryMember")
+	defer span.End()
+
+	rows, err := s.db.NamedQueryContext(ctx, q, data)
+	if err != nil {
+		return orgBus.Member{}, fmt.Errorf("namedQueryContext: %w", err)
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		return orgBus.Member{}, orgBus.ErrMemberNotFound
+	}
+
+	var m member
+	if err := rows.StructScan(&m); err != nil {
+		return orgBus.Member{}, fmt.Errorf("structScan: %w", err)
+	}
+
+	return toBusMember(m), nil
+}
+
+func (s *Store) QueryMembers(ctx context.Context, orgID uuid.UUID) ([]orgBus.Member, error) {
+	data := map[string]any{
+		"org_id": orgID,
+	}
+
+	const q = `SELECT * FROM org_members WHERE org_id = :org_id ORDER BY created_at`
+
+	ctx, span := s.tracer.Start(ctx, "org.store.queryMembers")
+	defer span.End()
+
+	rows, err := s.db.NamedQueryContext(ctx, q, data)
+	if err != nil {
+		return nil, fmt.Errorf("namedQueryContext: %w", err)
+	}
+
+	defer rows.Close()
+
+	var ms []orgBus.Member
+	for rows.Next() {
+		var m member
+		if err := rows.StructScan(&m); err != nil {
+			return nil, fmt.Errorf("structScan: %w", err)
+		}
+		ms = append(ms, toBusMember(m))
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("preparing next row to scan: %w", err)
+	}
+
+	return ms, nil
+}
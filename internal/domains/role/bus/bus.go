@@ -0,0 +1,188 @@
+// Package bus provides the business logic of roles and the permissions they grant.
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"time"
+)
+
+var (
+	ErrRoleNotFound      = errors.New("role not found")
+	ErrInvalidRoleName   = errors.New("role names are 1 to 32 lower case letters, digits, '_' or '-' starting with a letter")
+	ErrDuplicatedRole    = errors.New("role already exists")
+	ErrBuiltInRole       = errors.New("built-in role can not be changed")
+	ErrRoleInUse         = errors.New("role is still assigned to users")
+	ErrUnknownPermission = errors.New("unknown permission")
+)
+
+// Admin is the built-in role that holds every permission, including the ones added after it was
+// created, so it never has to be updated.
+const Admin = "admin"
+
+// roleName keeps role names safe to store in a TEXT[] without quoting.
+var roleName = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,31}$`)
+
+// ValidName reports whether name can be used as a role name.
+func ValidName(name string) bool {
+	return roleName.MatchString(name)
+}
+
+type store interface {
+	Create(ctx context.Context, r Role) error
+	Update(ctx context.Context, r Role) error
+	Delete(ctx context.Context, r Role) error
+	QueryByName(ctx context.Context, name string) (Role, error)
+	QueryByNames(ctx context.Context, names []string) ([]Role, error)
+	Query(ctx context.Context) ([]Role, error)
+	CountUsers(ctx context.Context, name string) (int, error)
+}
+
+type Bus struct {
+	store store
+}
+
+func New(store store) *Bus {
+	return &Bus{store: store}
+}
+
+func (b *Bus) Create(ctx context.Context, nr NewRole) (Role, error) {
+	if !ValidName(nr.Name) {
+		return Role{}, ErrInvalidRoleName
+	}
+
+	if err := ValidatePermissions(nr.Permissions); err != nil {
+		return Role{}, err
+	}
+
+	now := time.Now().Truncate(time.Microsecond)
+
+	r := Role{
+		Name:        nr.Name,
+		Description: nr.Description,
+		Permissions: nr.Permissions,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	if err := b.store.Create(ctx, r); err != nil {
+		return Role{}, fmt.Errorf("create: %w", err)
+	}
+
+	return r, nil
+}
+
+func (b *Bus) Update(ctx context.Context, r Role, ur UpdateRole) (Role, error) {
+	if r.Name == Admin {
+		return Role{}, ErrBuiltInRole
+	}
+
+	if ur.Description != nil {
+		r.Description = *ur.Description
+	}
+
+	if ur.Permissions != nil {
+		if err := ValidatePermissions(ur.Permissions); err != nil {
+			return Role{}, err
+		}
+		r.Permissions = ur.Permissions
+	}
+
+	r.UpdatedAt = time.Now().Truncate(time.Microsecond)
+	if err := b.store.Update(ctx, r); err != nil {
+		return Role{}, fmt.Errorf("update: %w", err)
+	}
+
+	return r, nil
+}
+
+// Delete removes a role that no user holds anymore.
+func (b *Bus) Delete(ctx context.Context, r Role) error {
+	if r.BuiltIn {
+		return ErrBuiltInRole
+	}
+
+	n, err := b.store.CountUsers(ctx, r.Name)
+	if err != nil {
+		return fmt.Errorf("countUsers: %w", err)
+	}
+
+	if n > 0 {
+		return ErrRoleInUse
+	}
+
+	if err := b.store.Delete(ctx, r); err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+
+	return nil
+}
+
+func (b *Bus) QueryByName(ctx context.Context, name string) (Role, error) {
+	r, err := b.store.QueryByName(ctx, name)
+	if err != nil {
+		return Role{}, fmt.Errorf("queryByName: %w", err)
+	}
+
+	return r, nil
+}
+
+func (b *Bus) Query(ctx context.Context) ([]Role, error) {
+	rs, err := b.store.Query(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return rs, nil
+}
+
+// Validate makes sure every one of the names is an existing role.
+func (b *Bus) Validate(ctx context.Context, names []string) error {
+	rs, err := b.store.QueryByNames(ctx, names)
+	if err != nil {
+		return fmt.Errorf("queryByNames: %w", err)
+	}
+
+	for _, name := range names {
+		found := slices.ContainsFunc(rs, func(r Role) bool {
+			return r.Name == name
+		})
+
+		if !found {
+			return fmt.Errorf("%w: %s", ErrRoleNotFound, name)
+		}
+	}
+
+	return nil
+}
+
+// Permissions resolves the permissions granted by the roles, unknown roles grant nothing.
+func (b *Bus) Permissions(ctx context.Context, names []string) (PermissionSet, error) {
+	ps := make(PermissionSet)
+	if len(names) == 0 {
+		return ps, nil
+	}
+
+	if slices.Contains(names, Admin) {
+		for _, perm := range knownPermissions {
+			ps[perm] = struct{}{}
+		}
+		return ps, nil
+	}
+
+	rs, err := b.store.QueryByNames(ctx, names)
+	if err != nil {
+		return nil, fmt.Errorf("queryByNames: %w", err)
+	}
+
+	for _, r := range rs {
+		for _, perm := range r.Permissions {
+			ps[perm] = struct{}{}
+		}
+	}
+
+	return ps, nil
+}
@@ -0,0 +1,170 @@
+package bus_test
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/mail"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/hamidoujand/jumble/internal/dbtest"
+	"github.com/hamidoujand/jumble/internal/domains/role/bus"
+	"github.com/hamidoujand/jumble/internal/domains/role/store/roledb"
+	userBus "github.com/hamidoujand/jumble/internal/domains/user/bus"
+	"github.com/hamidoujand/jumble/internal/domains/user/store/userdb"
+	"github.com/hamidoujand/jumble/pkg/docker"
+	"github.com/hamidoujand/jumble/pkg/telemetry"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var container docker.Container
+var tracer trace.Tracer
+
+func TestMain(m *testing.M) {
+	// before all
+	var err error
+	container, err = dbtest.CreateDBContainer()
+	if err != nil {
+		log.Fatalf("createDBContainer: %s", err)
+	}
+
+	defer docker.StopContainer(container.Name)
+	cfg := telemetry.Config{
+		ServiceName: "role_bus_test",
+		Host:        "",
+		Build:       "v0.0.1",
+	}
+
+	cleanup, err := telemetry.SetupOTelSDK(cfg)
+	if err != nil {
+		log.Fatalf("setupOTelSDK: %s", err)
+	}
+
+	tracer = otel.Tracer("role_bus_tests")
+
+	defer cleanup(context.Background())
+
+	// tests
+	os.Exit(m.Run())
+
+}
+
+func Test_Roles(t *testing.T) {
+	t.Parallel()
+
+	db := dbtest.New(t, container, "roles")
+	b := bus.New(roledb.NewStore(db, tracer))
+
+	nr := bus.NewRole{
+		Name:        "support",
+		Description: "Helps users with their accounts",
+		Permissions: []string{bus.PermUsersRead, bus.PermUsersUnlock},
+	}
+
+	r, err := b.Create(context.Background(), nr)
+	if err != nil {
+		t.Fatalf("failed to create a role: %s", err)
+	}
+
+	if _, err := b.Create(context.Background(), nr); !errors.Is(err, bus.ErrDuplicatedRole) {
+		t.Errorf("err=%s, got=%v", bus.ErrDuplicatedRole, err)
+	}
+
+	nr.Name = "Not Valid"
+	if _, err := b.Create(context.Background(), nr); !errors.Is(err, bus.ErrInvalidRoleName) {
+		t.Errorf("err=%s, got=%v", bus.ErrInvalidRoleName, err)
+	}
+
+	nr.Name = "auditor"
+	nr.Permissions = []string{"users:everything"}
+	if _, err := b.Create(context.Background(), nr); !errors.Is(err, bus.ErrUnknownPermission) {
+		t.Errorf("err=%s, got=%v", bus.ErrUnknownPermission, err)
+	}
+
+	if err := b.Validate(context.Background(), []string{"user", "support"}); err != nil {
+		t.Errorf("expected roles to be valid: %s", err)
+	}
+
+	if err := b.Validate(context.Background(), []string{"user", "auditor"}); !errors.Is(err, bus.ErrRoleNotFound) {
+		t.Errorf("err=%s, got=%v", bus.ErrRoleNotFound, err)
+	}
+
+	ps, err := b.Permissions(context.Background(), []string{"user", "support"})
+	if err != nil {
+		t.Fatalf("failed to resolve permissions: %s", err)
+	}
+
+	if !ps.HasAll(bus.PermUsersRead, bus.PermUsersUnlock) || ps.Has(bus.PermUsersDelete) {
+		t.Errorf("unexpected permissions: %v", ps)
+	}
+
+	ps, err = b.Permissions(context.Background(), []string{bus.Admin})
+	if err != nil {
+		t.Fatalf("failed to resolve permissions: %s", err)
+	}
+
+	if !ps.HasAll(bus.Permissions()...) {
+		t.Errorf("expected admin to have every permission, got=%v", ps)
+	}
+
+	//updates apply on the next lookup.
+	updated, err := b.Update(context.Background(), r, bus.UpdateRole{Permissions: []string{bus.PermUsersRead}})
+	if err != nil {
+		t.Fatalf("failed to update the role: %s", err)
+	}
+
+	if !slices.Equal(updated.Permissions, []string{bus.PermUsersRead}) {
+		t.Errorf("permissions=%v, got=%v", []string{bus.PermUsersRead}, updated.Permissions)
+	}
+
+	admin, err := b.QueryByName(context.Background(), bus.Admin)
+	if err != nil {
+		t.Fatalf("failed to query admin: %s", err)
+	}
+
+	if _, err := b.Update(context.Background(), admin, bus.UpdateRole{Permissions: []string{}}); !errors.Is(err, bus.ErrBuiltInRole) {
+		t.Errorf("err=%s, got=%v", bus.ErrBuiltInRole, err)
+	}
+
+	if err := b.Delete(context.Background(), admin); !errors.Is(err, bus.ErrBuiltInRole) {
+		t.Errorf("err=%s, got=%v", bus.ErrBuiltInRole, err)
+	}
+
+	//a role can not be deleted while users hold it.
+	usrBus := userBus.New(userdb.NewStore(db, tracer), userBus.WithRoleValidator(b))
+
+	roles, err := userBus.ParseManyRoles([]string{"support"})
+	if err != nil {
+		t.Fatalf("failed to parse roles: %s", err)
+	}
+
+	usr, err := usrBus.Create(context.Background(), userBus.NewUser{
+		Name:       "John Doe",
+		Email:      mail.Address{Name: "John Doe", Address: "john@gmail.com"},
+		Roles:      roles,
+		Department: "sales",
+		Password:   "test1234",
+	})
+	if err != nil {
+		t.Fatalf("failed to create a user: %s", err)
+	}
+
+	if err := b.Delete(context.Background(), updated); !errors.Is(err, bus.ErrRoleInUse) {
+		t.Errorf("err=%s, got=%v", bus.ErrRoleInUse, err)
+	}
+
+	if err := usrBus.Delete(context.Background(), usr); err != nil {
+		t.Fatalf("failed to delete the user: %s", err)
+	}
+
+	if err := b.Delete(context.Background(), updated); err != nil {
+		t.Fatalf("failed to delete the role: %s", err)
+	}
+
+	if _, err := b.QueryByName(context.Background(), "support"); !errors.Is(err, bus.ErrRoleNotFound) {
+		t.Errorf("err=%s, got=%v", bus.ErrRoleNotFound, err)
+	}
+}
@@ -0,0 +1,26 @@
+package bus
+
+import "time"
+
+// Role is a named set of permissions, users hold roles by name.
+type Role struct {
+	Name        string
+	Description string
+	Permissions []string
+
+	//BuiltIn roles are created by the migrations and can not be deleted.
+	BuiltIn   bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type NewRole struct {
+	Name        string
+	Description string
+	Permissions []string
+}
+
+type UpdateRole struct {
+	Description *string
+	Permissions []string
+}
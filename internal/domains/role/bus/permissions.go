@@ -0,0 +1,120 @@
+package bus
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Permissions a role can grant, names are "<resource>:<action>".
+const (
+	PermUsersRead = "users:read"
+	//PermUsersDepartmentRead only allows reading users of one's own department.
+	PermUsersDepartmentRead = "users:department:read"
+	PermUsersWrite          = "users:write"
+	PermUsersDelete         = "users:delete"
+	PermUsersDisable        = "users:disable"
+	PermUsersUnlock         = "users:unlock"
+	PermUsersRolesAssign    = "users:roles:assign"
+	PermUsersInvite         = "users:invite"
+	PermUsersImpersonate    = "users:impersonate"
+	PermRolesRead           = "roles:read"
+	PermRolesWrite          = "roles:write"
+	PermOrgsWrite           = "orgs:write"
+	PermOrgsMembersRead     = "orgs:members:read"
+	PermOrgsMembersWrite    = "orgs:members:write"
+	PermGroupsRead          = "groups:read"
+	PermGroupsWrite         = "groups:write"
+	//PermGroupsMembersWrite hands out the roles of the group along with the membership.
+	PermGroupsMembersWrite = "groups:members:write"
+	PermDepartmentsWrite   = "departments:write"
+	PermOAuthClientsRead   = "oauth:clients:read"
+	PermOAuthClientsWrite  = "oauth:clients:write"
+	//PermServiceAccountsWrite also covers issuing and revoking their API keys.
+	PermServiceAccountsRead  = "service_accounts:read"
+	PermServiceAccountsWrite = "service_accounts:write"
+	PermTokensIntrospect     = "tokens:introspect"
+)
+
+var knownPermissions = []string{
+	PermUsersRead,
+	PermUsersDepartmentRead,
+	PermUsersWrite,
+	PermUsersDelete,
+	PermUsersDisable,
+	PermUsersUnlock,
+	PermUsersRolesAssign,
+	PermUsersInvite,
+	PermUsersImpersonate,
+	PermRolesRead,
+	PermRolesWrite,
+	PermOrgsWrite,
+	PermOrgsMembersRead,
+	PermOrgsMembersWrite,
+	PermGroupsRead,
+	PermGroupsWrite,
+	PermGroupsMembersWrite,
+	PermDepartmentsWrite,
+	PermOAuthClientsRead,
+	PermOAuthClientsWrite,
+	PermServiceAccountsRead,
+	PermServiceAccountsWrite,
+	PermTokensIntrospect,
+}
+
+// Permissions returns every permission known to the service.
+func Permissions() []string {
+	return slices.Clone(knownPermissions)
+}
+
+// PermissionSet is the set of permissions granted to a user through all of their roles.
+type PermissionSet map[string]struct{}
+
+func (ps PermissionSet) Has(perm string) bool {
+	_, ok := ps[perm]
+	return ok
+}
+
+// HasAll reports whether every one of perms is in the set.
+func (ps PermissionSet) HasAll(perms ...string) bool {
+	for _, perm := range perms {
+		if !ps.Has(perm) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Covers reports whether every permission of other is also in the set.
+func (ps PermissionSet) Covers(other PermissionSet) bool {
+	for perm := range other {
+		if !ps.Has(perm) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Restrict returns the permissions of the set that are also in perms.
+func (ps PermissionSet) Restrict(perms []string) PermissionSet {
+	restricted := make(PermissionSet, len(perms))
+	for _, perm := range perms {
+		if ps.Has(perm) {
+			restricted[perm] = struct{}{}
+		}
+	}
+
+	return restricted
+}
+
+// ValidatePermissions fails with ErrUnknownPermission for the first permission that is not known.
+func ValidatePermissions(perms []string) error {
+	for _, perm := range perms {
+		if !slices.Contains(knownPermissions, perm) {
+			return fmt.Errorf("%w: %s", ErrUnknownPermission, perm)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	roleBus "github.com/hamidoujand/jumble/internal/domains/role/bus"
+	"github.com/hamidoujand/jumble/internal/errs"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type handler struct {
+	roleBus *roleBus.Bus
+	tracer  trace.Tracer
+}
+
+func (h *handler) CreateRole(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "role.handler.createRole")
+	defer span.End()
+
+	var nr newRole
+	if err := c.ShouldBindJSON(&nr); err != nil {
+		c.Error(err)
+		return
+	}
+
+	r, err := h.roleBus.Create(ctx, toBusNewRole(nr))
+	if errors.Is(err, roleBus.ErrDuplicatedRole) || errors.Is(err, roleBus.ErrUnknownPermission) || errors.Is(err, roleBus.ErrInvalidRoleName) {
+		c.Error(errs.New(http.StatusBadRequest, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "create: %s", err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, toAppRole(r))
+}
+
+func (h *handler) UpdateRole(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "role.handler.updateRole")
+	defer span.End()
+
+	var ur updateRole
+	if err := c.ShouldBindJSON(&ur); err != nil {
+		c.Error(err)
+		return
+	}
+
+	r, err := h.roleBus.QueryByName(ctx, c.Param("name"))
+	if errors.Is(err, roleBus.ErrRoleNotFound) {
+		c.Error(errs.New(http.StatusNotFound, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "queryByName: %s", err))
+		return
+	}
+
+	updated, err := h.roleBus.Update(ctx, r, toBusUpdateRole(ur))
+	if errors.Is(err, roleBus.ErrBuiltInRole) || errors.Is(err, roleBus.ErrUnknownPermission) {
+		c.Error(errs.New(http.StatusBadRequest, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "update: %s", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, toAppRole(updated))
+}
+
+func (h *handler) DeleteRole(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "role.handler.deleteRole")
+	defer span.End()
+
+	r, err := h.roleBus.QueryByName(ctx, c.Param("name"))
+	if errors.Is(err, roleBus.ErrRoleNotFound) {
+		c.Error(errs.New(http.StatusNotFound, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "queryByName: %s", err))
+		return
+	}
+
+	err = h.roleBus.Delete(ctx, r)
+	if errors.Is(err, roleBus.ErrBuiltInRole) || errors.Is(err, roleBus.ErrRoleInUse) {
+		c.Error(errs.New(http.StatusConflict, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "delete: %s", err))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *handler) QueryRoleByName(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "role.handler.queryRoleByName")
+	defer span.End()
+
+	r, err := h.roleBus.QueryByName(ctx, c.Param("name"))
+	if errors.Is(err, roleBus.ErrRoleNotFound) {
+		c.Error(errs.New(http.StatusNotFound, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "queryByName: %s", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, toAppRole(r))
+}
+
+func (h *handler) QueryRoles(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "role.handler.queryRoles")
+	defer span.End()
+
+	rs, err := h.roleBus.Query(ctx)
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "query: %s", err))
+		return
+	}
+
+	roles := make([]role, len(rs))
+	for i, r := range rs {
+		roles[i] = toAppRole(r)
+	}
+
+	c.JSON(http.StatusOK, roles)
+}
+
+// QueryPermissions lists every permission a role can grant.
+func (h *handler) QueryPermissions(c *gin.Context) {
+	c.JSON(http.StatusOK, roleBus.Permissions())
+}
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"time"
+
+	roleBus "github.com/hamidoujand/jumble/internal/domains/role/bus"
+)
+
+type role struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+	BuiltIn     bool     `json:"builtIn"`
+	CreatedAt   string   `json:"createdAt"`
+	UpdatedAt   string   `json:"updatedAt"`
+}
+
+func toAppRole(r roleBus.Role) role {
+	perms := r.Permissions
+	//admin holds every permission no matter what is stored.
+	if r.Name == roleBus.Admin {
+		perms = roleBus.Permissions()
+	}
+
+	return role{
+		Name:        r.Name,
+		Description: r.Description,
+		Permissions: perms,
+		BuiltIn:     r.BuiltIn,
+		CreatedAt:   r.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:   r.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+// ==============================================================================
+
+type newRole struct {
+	Name        string   `json:"name" binding:"required,max=32"`
+	Description string   `json:"description" binding:"max=400"`
+	Permissions []string `json:"permissions" binding:"required,dive,required"`
+}
+
+func toBusNewRole(nr newRole) roleBus.NewRole {
+	return roleBus.NewRole{
+		Name:        nr.Name,
+		Description: nr.Description,
+		Permissions: nr.Permissions,
+	}
+}
+
+type updateRole struct {
+	Description *string  `json:"description" binding:"omitempty,max=400"`
+	Permissions []string `json:"permissions" binding:"omitempty,dive,required"`
+}
+
+func toBusUpdateRole(ur updateRole) roleBus.UpdateRole {
+	return roleBus.UpdateRole{
+		Description: ur.Description,
+		Permissions: ur.Permissions,
+	}
+}
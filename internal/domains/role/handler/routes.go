@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/hamidoujand/jumble/internal/auth"
+	roleBus "github.com/hamidoujand/jumble/internal/domains/role/bus"
+	"github.com/hamidoujand/jumble/internal/mid"
+	"github.com/hamidoujand/jumble/pkg/logger"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type Conf struct {
+	Router   *gin.Engine
+	AuthConf mid.AuthConf
+	RoleBus  *roleBus.Bus
+	Tracer   trace.Tracer
+	Logger   *logger.Logger
+}
+
+// RegisterRoutes takes the mux and register endpoints on it.
+func RegisterRoutes(cfg Conf) {
+	h := handler{
+		roleBus: cfg.RoleBus,
+		tracer:  cfg.Tracer,
+	}
+
+	authenticated := mid.Authenticate(cfg.AuthConf)
+
+	api := mid.RequireScope(auth.ScopeAPI)
+
+	read := mid.RequirePermission(cfg.Logger, cfg.RoleBus, roleBus.PermRolesRead)
+	write := mid.RequirePermission(cfg.Logger, cfg.RoleBus, roleBus.PermRolesWrite)
+
+	//roles are shared by every org, members can read them but only global admins change them.
+	global := mid.GlobalScope()
+
+	//changing what roles grant is off limits while impersonating.
+	notImpersonated := mid.NotImpersonated()
+
+	roles := cfg.Router.Group("/v1/roles", authenticated, api)
+
+	roles.GET("/", read, h.QueryRoles)
+	roles.GET("/permissions", read, h.QueryPermissions)
+	roles.GET("/:name", read, h.QueryRoleByName)
+	roles.POST("/", global, notImpersonated, write, h.CreateRole)
+	roles.PUT("/:name", global, notImpersonated, write, h.UpdateRole)
+	roles.DELETE("/:name", global, notImpersonated, write, h.DeleteRole)
+}
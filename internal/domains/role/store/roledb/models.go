@@ -0,0 +1,39 @@
+package roledb
+
+import (
+	"time"
+
+	roleBus "github.com/hamidoujand/jumble/internal/domains/role/bus"
+	"github.com/hamidoujand/jumble/internal/sqldb"
+)
+
+type role struct {
+	Name        string          `db:"name"`
+	Description string          `db:"description"`
+	Permissions sqldb.TextArray `db:"permissions"`
+	BuiltIn     bool            `db:"built_in"`
+	CreatedAt   time.Time       `db:"created_at"`
+	UpdatedAt   time.Time       `db:"updated_at"`
+}
+
+func fromBusRole(r roleBus.Role) role {
+	return role{
+		Name:        r.Name,
+		Description: r.Description,
+		Permissions: sqldb.TextArray(r.Permissions),
+		BuiltIn:     r.BuiltIn,
+		CreatedAt:   r.CreatedAt,
+		UpdatedAt:   r.UpdatedAt,
+	}
+}
+
+func toBusRole(r role) roleBus.Role {
+	return roleBus.Role{
+		Name:        r.Name,
+		Description: r.Description,
+		Permissions: []string(r.Permissions),
+		BuiltIn:     r.BuiltIn,
+		CreatedAt:   r.CreatedAt,
+		UpdatedAt:   r.UpdatedAt,
+	}
+}
@@ -0,0 +1,198 @@
+package roledb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	roleBus "github.com/hamidoujand/jumble/internal/domains/role/bus"
+	"github.com/hamidoujand/jumble/internal/sqldb"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	uniqueViolation = "23505"
+)
+
+type Store struct {
+	db     *sqlx.DB
+	tracer trace.Tracer
+}
+
+func NewStore(db *sqlx.DB, tracer trace.Tracer) *Store {
+	return &Store{
+		db:     db,
+		tracer: tracer,
+	}
+}
+
+func (s *Store) Create(ctx context.Context, r roleBus.Role) error {
+	const q = `
+	INSERT INTO roles (name,description,permissions,built_in,created_at,updated_at)
+	VALUES (:name,:description,:permissions,:built_in,:created_at,:updated_at)
+	`
+
+	ctx, span := s.tracer.Start(ctx, "role.store.create")
+	defer span.End()
+
+	if _, err := s.db.NamedExecContext(ctx, q, fromBusRole(r)); err != nil {
+		var pgerror *pgconn.PgError
+		if errors.As(err, &pgerror) {
+			if pgerror.Code == uniqueViolation {
+				return roleBus.ErrDuplicatedRole
+			}
+		}
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) Update(ctx context.Context, r roleBus.Role) error {
+	const q = `
+	UPDATE roles 
+	SET 
+		description = :description,
+		permissions = :permissions,
+		updated_at = :updated_at
+	WHERE 
+		name = :name
+	`
+
+	ctx, span := s.tracer.Start(ctx, "role.store.update")
+	defer span.End()
+
+	if _, err := s.db.NamedExecContext(ctx, q, fromBusRole(r)); err != nil {
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) Delete(ctx context.Context, r roleBus.Role) error {
+	const q = `DELETE FROM roles WHERE name = :name`
+
+	ctx, span := s.tracer.Start(ctx, "role.store.delete")
+	defer span.End()
+
+	if _, err := s.db.NamedExecContext(ctx, q, fromBusRole(r)); err != nil {
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) QueryByName(ctx context.Context, name string) (roleBus.Role, error) {
+	data := map[string]any{
+		"name": name,
+	}
+
+	const q = `SELECT * FROM roles WHERE name = :name`
+
+	ctx, span := s.tracer.Start(ctx, "role.store.queryByName")
+	defer span.End()
+
+	rows, err := s.db.NamedQueryContext(ctx, q, data)
+	if err != nil {
+		return roleBus.Role{}, fmt.Errorf("namedQueryContext: %w", err)
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		return roleBus.Role{}, roleBus.ErrRoleNotFound
+	}
+
+	var r role
+	if err := rows.StructScan(&r); err != nil {
+		return roleBus.Role{}, fmt.Errorf("structScan: %w", err)
+	}
+
+	return toBusRole(r), nil
+}
+
+func (s *Store) QueryByNames(ctx context.Context, names []string) ([]roleBus.Role, error) {
+	data := map[string]any{
+		"names": sqldb.TextArray(names),
+	}
+
+	const q = `SELECT * FROM roles WHERE name = ANY(:names) ORDER BY name`
+
+	ctx, span := s.tracer.Start(ctx, "role.store.queryByNames")
+	defer span.End()
+
+	return s.query(ctx, q, data)
+}
+
+func (s *Store) Query(ctx context.Context) ([]roleBus.Role, error) {
+	const q = `SELECT * FROM roles ORDER BY name`
+
+	ctx, span := s.tracer.Start(ctx, "role.store.query")
+	defer span.End()
+
+	return s.query(ctx, q, map[string]any{})
+}
+
+// CountUsers returns how many users hold the role, globally or as a member of an org, plus how
+// many groups hand it out.
+func (s *Store) CountUsers(ctx context.Context, name string) (int, error) {
+	data := map[string]any{
+		"name": name,
+	}
+
+	const q = `
+	SELECT
+		(SELECT count(1) FROM users WHERE :name = ANY(roles) AND deleted_at IS NULL) +
+		(SELECT count(1) FROM org_members WHERE :name = ANY(roles)) +
+		(SELECT count(1) FROM groups WHERE :name = ANY(roles))
+	`
+
+	ctx, span := s.tracer.Start(ctx, "role.store.countUsers")
+	defer span.End()
+
+	rows, err := s.db.NamedQueryContext(ctx, q, data)
+	if err != nil {
+		return 0, fmt.Errorf("namedQueryContext: %w", err)
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		return 0, nil
+	}
+
+	var n int
+	if err := rows.Scan(&n); err != nil {
+		return 0, fmt.Errorf("scan: %w", err)
+	}
+
+	return n, nil
+}
+
+// ==============================================================================
+
+func (s *Store) query(ctx context.Context, q string, data map[string]any) ([]roleBus.Role, error) {
+	rows, err := s.db.NamedQueryContext(ctx, q, data)
+	if err != nil {
+		return nil, fmt.Errorf("namedQueryContext: %w", err)
+	}
+
+	defer rows.Close()
+
+	var rs []roleBus.Role
+	for rows.Next() {
+		var r role
+		if err := rows.StructScan(&r); err != nil {
+			return nil, fmt.Errorf("structScan: %w", err)
+		}
+		rs = append(rs, toBusRole(r))
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("preparing next row to scan: %w", err)
+	}
+
+	return rs, nil
+}
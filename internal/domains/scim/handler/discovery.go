@@ -0,0 +1,234 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type supported struct {
+	Supported bool `json:"supported"`
+}
+
+type authenticationScheme struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Primary     bool   `json:"primary"`
+}
+
+type serviceProviderConfig struct {
+	Schemas []string  `json:"schemas"`
+	Patch   supported `json:"patch"`
+	Bulk    struct {
+		Supported      bool `json:"supported"`
+		MaxOperations  int  `json:"maxOperations"`
+		MaxPayloadSize int  `json:"maxPayloadSize"`
+	} `json:"bulk"`
+	Filter struct {
+		Supported  bool `json:"supported"`
+		MaxResults int  `json:"maxResults"`
+	} `json:"filter"`
+	ChangePassword        supported              `json:"changePassword"`
+	Sort                  supported              `json:"sort"`
+	ETag                  supported              `json:"etag"`
+	AuthenticationSchemes []authenticationScheme `json:"authenticationSchemes"`
+	Meta                  meta                   `json:"meta"`
+}
+
+func (h *handler) ServiceProviderConfig(c *gin.Context) {
+	_, span := h.tracer.Start(c.Request.Context(), "scim.handler.serviceProviderConfig")
+	defer span.End()
+
+	spc := serviceProviderConfig{
+		Schemas:        []string{schemaServiceProviderConfig},
+		Patch:          supported{Supported: true},
+		ChangePassword: supported{Supported: true},
+		AuthenticationSchemes: []authenticationScheme{{
+			Type:        "oauthbearertoken",
+			Name:        "OAuth Bearer Token",
+			Description: "Authentication with the bearer token configured for SCIM",
+			Primary:     true,
+		}},
+		Meta: meta{
+			ResourceType: "ServiceProviderConfig",
+			Location:     h.baseURL + "/ServiceProviderConfig",
+		},
+	}
+
+	//only "eq" filters on userName and displayName.
+	spc.Filter.Supported = true
+	spc.Filter.MaxResults = maxCount
+
+	respond(c, http.StatusOK, spc)
+}
+
+// ==============================================================================
+
+type schemaExtension struct {
+	Schema   string `json:"schema"`
+	Required bool   `json:"required"`
+}
+
+type resourceType struct {
+	Schemas          []string          `json:"schemas"`
+	ID               string            `json:"id"`
+	Name             string            `json:"name"`
+	Endpoint         string            `json:"endpoint"`
+	Schema           string            `json:"schema"`
+	SchemaExtensions []schemaExtension `json:"schemaExtensions,omitempty"`
+	Meta             meta              `json:"meta"`
+}
+
+func (h *handler) ResourceTypes(c *gin.Context) {
+	_, span := h.tracer.Start(c.Request.Context(), "scim.handler.resourceTypes")
+	defer span.End()
+
+	rts := []resourceType{
+		{
+			Schemas:          []string{schemaResourceType},
+			ID:               "User",
+			Name:             "User",
+			Endpoint:         "/Users",
+			Schema:           schemaUser,
+			SchemaExtensions: []schemaExtension{{Schema: schemaEnterpriseUser}},
+			Meta:             meta{ResourceType: "ResourceType", Location: h.baseURL + "/ResourceTypes/User"},
+		},
+		{
+			Schemas:  []string{schemaResourceType},
+			ID:       "Group",
+			Name:     "Group",
+			Endpoint: "/Groups",
+			Schema:   schemaGroup,
+			Meta:     meta{ResourceType: "ResourceType", Location: h.baseURL + "/ResourceTypes/Group"},
+		},
+	}
+
+	respond(c, http.StatusOK, newListResponse(rts, len(rts), 1))
+}
+
+// ==============================================================================
+
+// attribute describes one attribute of a schema, only the ones the service stores are listed.
+type attribute struct {
+	Name          string      `json:"name"`
+	Type          string      `json:"type"`
+	Description   string      `json:"description,omitempty"`
+	MultiValued   bool        `json:"multiValued"`
+	Required      bool        `json:"required"`
+	CaseExact     bool        `json:"caseExact"`
+	Mutability    string      `json:"mutability"`
+	Returned      string      `json:"returned"`
+	Uniqueness    string      `json:"uniqueness"`
+	SubAttributes []attribute `json:"subAttributes,omitempty"`
+}
+
+type schema struct {
+	Schemas     []string    `json:"schemas"`
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Attributes  []attribute `json:"attributes"`
+	Meta        meta        `json:"meta"`
+}
+
+func stringAttribute(name string, required bool, uniqueness string) attribute {
+	return attribute{
+		Name:       name,
+		Type:       "string",
+		Required:   required,
+		Mutability: "readWrite",
+		Returned:   "default",
+		Uniqueness: uniqueness,
+	}
+}
+
+func (h *handler) Schemas(c *gin.Context) {
+	_, span := h.tracer.Start(c.Request.Context(), "scim.handler.schemas")
+	defer span.End()
+
+	userName := stringAttribute("userName", true, "server")
+	userName.Description = "The email address of the user."
+
+	password := stringAttribute("password", false, "none")
+	password.Mutability = "writeOnly"
+	password.Returned = "never"
+
+	emails := attribute{
+		Name:        "emails",
+		Type:        "complex",
+		MultiValued: true,
+		Mutability:  "readOnly",
+		Returned:    "default",
+		Uniqueness:  "none",
+		SubAttributes: []attribute{
+			stringAttribute("value", false, "none"),
+			stringAttribute("type", false, "none"),
+			{Name: "primary", Type: "boolean", Mutability: "readOnly", Returned: "default", Uniqueness: "none"},
+		},
+	}
+
+	members := attribute{
+		Name:        "members",
+		Type:        "complex",
+		MultiValued: true,
+		Mutability:  "readWrite",
+		Returned:    "default",
+		Uniqueness:  "none",
+		SubAttributes: []attribute{
+			stringAttribute("value", false, "none"),
+			{Name: "$ref", Type: "reference", Mutability: "readOnly", Returned: "default", Uniqueness: "none"},
+			stringAttribute("type", false, "none"),
+		},
+	}
+
+	schemas := []schema{
+		{
+			Schemas:     []string{schemaSchema},
+			ID:          schemaUser,
+			Name:        "User",
+			Description: "User Account",
+			Attributes: []attribute{
+				userName,
+				{
+					Name:       "name",
+					Type:       "complex",
+					Mutability: "readWrite",
+					Returned:   "default",
+					Uniqueness: "none",
+					SubAttributes: []attribute{
+						stringAttribute("formatted", false, "none"),
+						stringAttribute("givenName", false, "none"),
+						stringAttribute("familyName", false, "none"),
+					},
+				},
+				stringAttribute("displayName", false, "none"),
+				emails,
+				{Name: "active", Type: "boolean", Mutability: "readWrite", Returned: "default", Uniqueness: "none"},
+				password,
+			},
+			Meta: meta{ResourceType: "Schema", Location: h.baseURL + "/Schemas/" + schemaUser},
+		},
+		{
+			Schemas:     []string{schemaSchema},
+			ID:          schemaEnterpriseUser,
+			Name:        "EnterpriseUser",
+			Description: "Enterprise User",
+			Attributes:  []attribute{stringAttribute("department", false, "none")},
+			Meta:        meta{ResourceType: "Schema", Location: h.baseURL + "/Schemas/" + schemaEnterpriseUser},
+		},
+		{
+			Schemas:     []string{schemaSchema},
+			ID:          schemaGroup,
+			Name:        "Group",
+			Description: "Group",
+			Attributes: []attribute{
+				stringAttribute("displayName", true, "server"),
+				members,
+			},
+			Meta: meta{ResourceType: "Schema", Location: h.baseURL + "/Schemas/" + schemaGroup},
+		},
+	}
+
+	respond(c, http.StatusOK, newListResponse(schemas, len(schemas), 1))
+}
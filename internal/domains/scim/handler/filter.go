@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var errInvalidFilter = errors.New("invalid filter")
+
+// filter is a parsed "<attribute> eq <value>" expression, the only kind identity providers need to
+// look up a resource before creating it.
+type filter struct {
+	Attribute string
+	Value     string
+}
+
+// parseFilter parses the filter query parameter, attribute names are case insensitive and
+// returned lower cased. Only the attributes in supported can be filtered on.
+func parseFilter(raw string, supported ...string) (filter, error) {
+	attr, rest, ok := strings.Cut(strings.TrimSpace(raw), " ")
+	if !ok {
+		return filter{}, fmt.Errorf("%w: %q", errInvalidFilter, raw)
+	}
+
+	op, value, ok := strings.Cut(strings.TrimSpace(rest), " ")
+	if !ok || !strings.EqualFold(op, "eq") {
+		return filter{}, fmt.Errorf("%w: only the eq operator is supported", errInvalidFilter)
+	}
+
+	attr = strings.ToLower(attr)
+
+	var known bool
+	for _, s := range supported {
+		if strings.EqualFold(s, attr) {
+			known = true
+			break
+		}
+	}
+
+	if !known {
+		return filter{}, fmt.Errorf("%w: filtering on %q is not supported", errInvalidFilter, attr)
+	}
+
+	//the value is a JSON string, so "and"/"or" after it make the unmarshal fail.
+	var s string
+	if err := json.Unmarshal([]byte(strings.TrimSpace(value)), &s); err != nil {
+		return filter{}, fmt.Errorf("%w: value must be a quoted string", errInvalidFilter)
+	}
+
+	return filter{Attribute: attr, Value: s}, nil
+}
@@ -0,0 +1,337 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	groupBus "github.com/hamidoujand/jumble/internal/domains/group/bus"
+	"github.com/hamidoujand/jumble/internal/errs"
+)
+
+// CreateGroup creates a top level group without roles, what a group grants is still decided in
+// the service.
+func (h *handler) CreateGroup(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "scim.handler.createGroup")
+	defer span.End()
+
+	var wg writeGroup
+	if err := c.ShouldBindJSON(&wg); err != nil {
+		c.Error(err)
+		return
+	}
+
+	wanted, err := memberIDs(wg.Members)
+	if err != nil {
+		fail(c, http.StatusBadRequest, "invalidValue", err)
+		return
+	}
+
+	g, err := h.groupBus.Create(ctx, groupBus.NewGroup{Name: wg.DisplayName})
+	if errors.Is(err, groupBus.ErrDuplicatedGroup) {
+		fail(c, http.StatusConflict, "uniqueness", err)
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "create: %s", err))
+		return
+	}
+
+	members, ok := h.syncMembers(ctx, c, g, nil, toSet(wanted))
+	if !ok {
+		return
+	}
+
+	c.Header("Location", h.baseURL+"/Groups/"+g.ID.String())
+	respond(c, http.StatusCreated, h.toSCIMGroup(g, members))
+}
+
+// QueryGroups lists the groups, identity providers that only need the names can ask for the
+// members to be left out with excludedAttributes=members.
+func (h *handler) QueryGroups(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "scim.handler.queryGroups")
+	defer span.End()
+
+	startIndex, count, err := listParams(c)
+	if err != nil {
+		fail(c, http.StatusBadRequest, "invalidValue", err)
+		return
+	}
+
+	gs, err := h.groupBus.Query(ctx)
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "query: %s", err))
+		return
+	}
+
+	if raw := c.Query("filter"); raw != "" {
+		f, err := parseFilter(raw, "displayName")
+		if err != nil {
+			fail(c, http.StatusBadRequest, "invalidFilter", err)
+			return
+		}
+
+		var matched []groupBus.Group
+		for _, g := range gs {
+			if strings.EqualFold(g.Name, f.Value) {
+				matched = append(matched, g)
+			}
+		}
+		gs = matched
+	}
+
+	total := len(gs)
+
+	//groups are few, paging them in memory is fine.
+	from := min(startIndex-1, total)
+	gs = gs[from:min(from+count, total)]
+
+	withMembers := !strings.Contains(strings.ToLower(c.Query("excludedAttributes")), "members")
+
+	groups := make([]group, len(gs))
+	for i, g := range gs {
+		var members []groupBus.Member
+		if withMembers {
+			members, err = h.groupBus.QueryMembers(ctx, g.ID)
+			if err != nil {
+				c.Error(errs.New(http.StatusInternalServerError, "queryMembers: %s", err))
+				return
+			}
+		}
+		groups[i] = h.toSCIMGroup(g, members)
+	}
+
+	respond(c, http.StatusOK, newListResponse(groups, total, startIndex))
+}
+
+func (h *handler) QueryGroupByID(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "scim.handler.queryGroupByID")
+	defer span.End()
+
+	g, members, ok := h.loadGroup(ctx, c)
+	if !ok {
+		return
+	}
+
+	respond(c, http.StatusOK, h.toSCIMGroup(g, members))
+}
+
+// ReplaceGroup sets the name and the exact list of members.
+func (h *handler) ReplaceGroup(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "scim.handler.replaceGroup")
+	defer span.End()
+
+	g, current, ok := h.loadGroup(ctx, c)
+	if !ok {
+		return
+	}
+
+	var wg writeGroup
+	if err := c.ShouldBindJSON(&wg); err != nil {
+		c.Error(err)
+		return
+	}
+
+	wanted, err := memberIDs(wg.Members)
+	if err != nil {
+		fail(c, http.StatusBadRequest, "invalidValue", err)
+		return
+	}
+
+	g, ok = h.rename(ctx, c, g, &wg.DisplayName)
+	if !ok {
+		return
+	}
+
+	members, ok := h.syncMembers(ctx, c, g, current, toSet(wanted))
+	if !ok {
+		return
+	}
+
+	respond(c, http.StatusOK, h.toSCIMGroup(g, members))
+}
+
+// PatchGroup applies the operations, most providers only ever add and remove members this way.
+func (h *handler) PatchGroup(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "scim.handler.patchGroup")
+	defer span.End()
+
+	g, current, ok := h.loadGroup(ctx, c)
+	if !ok {
+		return
+	}
+
+	var pr patchRequest
+	if err := c.ShouldBindJSON(&pr); err != nil {
+		c.Error(err)
+		return
+	}
+
+	ids := make([]uuid.UUID, len(current))
+	for i, m := range current {
+		ids[i] = m.UserID
+	}
+
+	displayName, wanted, err := groupPatch(pr.Operations, ids)
+	if errors.Is(err, errInvalidPath) {
+		fail(c, http.StatusBadRequest, "invalidPath", err)
+		return
+	}
+
+	if err != nil {
+		fail(c, http.StatusBadRequest, "invalidValue", err)
+		return
+	}
+
+	g, ok = h.rename(ctx, c, g, displayName)
+	if !ok {
+		return
+	}
+
+	members, ok := h.syncMembers(ctx, c, g, current, wanted)
+	if !ok {
+		return
+	}
+
+	respond(c, http.StatusOK, h.toSCIMGroup(g, members))
+}
+
+func (h *handler) DeleteGroup(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "scim.handler.deleteGroup")
+	defer span.End()
+
+	g, _, ok := h.loadGroup(ctx, c)
+	if !ok {
+		return
+	}
+
+	err := h.groupBus.Delete(ctx, g)
+	if errors.Is(err, groupBus.ErrHasSubgroups) {
+		c.Error(errs.New(http.StatusConflict, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "delete: %s", err))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ==============================================================================
+
+// loadGroup fetches the group in the path and its direct members, when it fails the error is
+// already set on the context.
+func (h *handler) loadGroup(ctx context.Context, c *gin.Context) (groupBus.Group, []groupBus.Member, bool) {
+	p := c.Param("id")
+
+	groupID, err := uuid.Parse(p)
+	if err != nil {
+		c.Error(errs.New(http.StatusNotFound, "%s", groupBus.ErrGroupNotFound))
+		return groupBus.Group{}, nil, false
+	}
+
+	g, err := h.groupBus.QueryByID(ctx, groupID)
+	if errors.Is(err, groupBus.ErrGroupNotFound) {
+		c.Error(errs.New(http.StatusNotFound, "%s", err))
+		return groupBus.Group{}, nil, false
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "queryByID: %s", err))
+		return groupBus.Group{}, nil, false
+	}
+
+	members, err := h.groupBus.QueryMembers(ctx, g.ID)
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "queryMembers: %s", err))
+		return groupBus.Group{}, nil, false
+	}
+
+	return g, members, true
+}
+
+// rename changes the name of the group when name is set and differs.
+func (h *handler) rename(ctx context.Context, c *gin.Context, g groupBus.Group, name *string) (groupBus.Group, bool) {
+	if name == nil || *name == g.Name {
+		return g, true
+	}
+
+	if *name == "" || len(*name) > 120 {
+		fail(c, http.StatusBadRequest, "invalidValue", errors.New("displayName must be 1 to 120 characters"))
+		return groupBus.Group{}, false
+	}
+
+	updated, err := h.groupBus.Update(ctx, g, groupBus.UpdateGroup{Name: name})
+	if errors.Is(err, groupBus.ErrDuplicatedGroup) {
+		fail(c, http.StatusConflict, "uniqueness", err)
+		return groupBus.Group{}, false
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "update: %s", err))
+		return groupBus.Group{}, false
+	}
+
+	return updated, true
+}
+
+// syncMembers adds and removes members until the group has exactly the wanted ones, it returns
+// the members the group ends up with.
+func (h *handler) syncMembers(ctx context.Context, c *gin.Context, g groupBus.Group, current []groupBus.Member, wanted map[uuid.UUID]struct{}) ([]groupBus.Member, bool) {
+	members := make([]groupBus.Member, 0, len(wanted))
+	has := make(map[uuid.UUID]struct{}, len(current))
+
+	for _, m := range current {
+		if _, ok := wanted[m.UserID]; ok {
+			has[m.UserID] = struct{}{}
+			members = append(members, m)
+			continue
+		}
+
+		if err := h.groupBus.RemoveMember(ctx, m); err != nil && !errors.Is(err, groupBus.ErrMemberNotFound) {
+			c.Error(errs.New(http.StatusInternalServerError, "removeMember: %s", err))
+			return nil, false
+		}
+	}
+
+	for id := range wanted {
+		if _, ok := has[id]; ok {
+			continue
+		}
+
+		m, err := h.groupBus.AddMember(ctx, g, id)
+		switch {
+		case errors.Is(err, groupBus.ErrMemberNotFound):
+			fail(c, http.StatusBadRequest, "invalidValue", fmt.Errorf("unknown user: %s", id))
+			return nil, false
+
+		case errors.Is(err, groupBus.ErrDuplicatedMember):
+			//added by a concurrent request in the meantime.
+			m = groupBus.Member{GroupID: g.ID, UserID: id}
+
+		case err != nil:
+			c.Error(errs.New(http.StatusInternalServerError, "addMember: %s", err))
+			return nil, false
+		}
+
+		members = append(members, m)
+	}
+
+	return members, true
+}
+
+func toSet(ids []uuid.UUID) map[uuid.UUID]struct{} {
+	set := make(map[uuid.UUID]struct{}, len(ids))
+	for _, id := range ids {
+		set[id] = struct{}{}
+	}
+
+	return set
+}
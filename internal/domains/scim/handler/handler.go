@@ -0,0 +1,151 @@
+// Package handler provides the SCIM 2.0 endpoints identity providers use to provision users and
+// groups, see RFC 7643 and RFC 7644.
+package handler
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	groupBus "github.com/hamidoujand/jumble/internal/domains/group/bus"
+	userBus "github.com/hamidoujand/jumble/internal/domains/user/bus"
+	"github.com/hamidoujand/jumble/internal/errs"
+	"github.com/hamidoujand/jumble/pkg/logger"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	contentType = "application/scim+json"
+
+	//defaultCount and maxCount bound the page size of list responses, maxCount is advertised in
+	//the ServiceProviderConfig.
+	defaultCount = 100
+	maxCount     = 200
+)
+
+type handler struct {
+	userBus  *userBus.Bus
+	groupBus *groupBus.Bus
+	baseURL  string
+	tracer   trace.Tracer
+	log      *logger.Logger
+}
+
+// respond writes v as a SCIM response.
+func respond(c *gin.Context, status int, v any) {
+	c.Header("Content-Type", contentType)
+	c.JSON(status, v)
+}
+
+// fail sets the error along with the scimType renderErrors puts in the response, e.g.
+// "invalidFilter" or "uniqueness".
+func fail(c *gin.Context, status int, scimType string, err error) {
+	c.Set("scimType", scimType)
+	c.Error(errs.New(status, "%s", err))
+}
+
+// renderErrors renders the errors set by the handlers in the SCIM format, the app wide Error
+// middleware leaves responses that are already written alone.
+func (h *handler) renderErrors(c *gin.Context) {
+	c.Next()
+
+	if c.Writer.Written() || len(c.Errors) == 0 {
+		return
+	}
+
+	err := c.Errors.Last().Err
+
+	status := http.StatusInternalServerError
+	detail := http.StatusText(http.StatusInternalServerError)
+	scimType := c.GetString("scimType")
+
+	var appErr *errs.Error
+	var validationErrors validator.ValidationErrors
+
+	switch {
+	case errors.As(err, &appErr):
+		status = appErr.Code
+		if status != http.StatusInternalServerError {
+			detail = appErr.Message
+		}
+
+		h.log.Error(c.Request.Context(), "error while handling scim request", "err", err, "fileName", appErr.FileName, "funcName", appErr.FuncName)
+
+	case errors.As(err, &validationErrors):
+		status = http.StatusBadRequest
+		scimType = "invalidValue"
+		detail = validationErrors.Error()
+
+	default:
+		h.log.Error(c.Request.Context(), "unknown scim error", "err", err)
+	}
+
+	respond(c, status, scimError{
+		Schemas:  []string{schemaError},
+		Status:   strconv.Itoa(status),
+		ScimType: scimType,
+		Detail:   detail,
+	})
+}
+
+// bearer only lets requests carrying the token through, the hashes are compared so the time it
+// takes does not leak the length of the token either.
+func bearer(token string) gin.HandlerFunc {
+	want := sha256.Sum256([]byte(token))
+
+	return func(c *gin.Context) {
+		got, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		sum := sha256.Sum256([]byte(got))
+
+		if !ok || subtle.ConstantTimeCompare(want[:], sum[:]) != 1 {
+			c.Error(errs.New(http.StatusUnauthorized, "%s", http.StatusText(http.StatusUnauthorized)))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// listParams reads the 1-based startIndex and the count of a list request, out of range values
+// are clamped the way RFC 7644 asks for.
+func listParams(c *gin.Context) (int, int, error) {
+	startIndex, count := 1, defaultCount
+
+	if v := c.Query("startIndex"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, 0, errors.New("startIndex must be a number")
+		}
+		startIndex = max(n, 1)
+	}
+
+	if v := c.Query("count"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, 0, errors.New("count must be a number")
+		}
+		count = min(max(n, 0), maxCount)
+	}
+
+	return startIndex, count, nil
+}
+
+func newListResponse[T any](resources []T, total int, startIndex int) listResponse {
+	if resources == nil {
+		resources = []T{}
+	}
+
+	return listResponse{
+		Schemas:      []string{schemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}
+}
@@ -0,0 +1,205 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/hamidoujand/jumble/internal/mid"
+	"github.com/hamidoujand/jumble/pkg/logger"
+	"go.opentelemetry.io/otel"
+)
+
+func Test_ParseFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		raw    string
+		expect filter
+		err    bool
+	}{
+		{name: "eq", raw: `userName eq "john@doe.com"`, expect: filter{Attribute: "username", Value: "john@doe.com"}},
+		{name: "case_insensitive", raw: `USERNAME EQ "john@doe.com"`, expect: filter{Attribute: "username", Value: "john@doe.com"}},
+		{name: "escaped_quote", raw: `userName eq "jo\"hn"`, expect: filter{Attribute: "username", Value: `jo"hn`}},
+		{name: "unknown_attribute", raw: `title eq "boss"`, err: true},
+		{name: "unknown_operator", raw: `userName co "john"`, err: true},
+		{name: "unquoted", raw: `userName eq john`, err: true},
+		{name: "and", raw: `userName eq "john" and userName eq "jane"`, err: true},
+		{name: "empty", raw: ``, err: true},
+	}
+
+	for _, ts := range tests {
+		t.Run(ts.name, func(t *testing.T) {
+			f, err := parseFilter(ts.raw, "userName")
+			if ts.err {
+				if !errors.Is(err, errInvalidFilter) {
+					t.Fatalf("err=%s, got=%v", errInvalidFilter, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed to parse filter: %s", err)
+			}
+
+			if f != ts.expect {
+				t.Errorf("filter=%+v, got=%+v", ts.expect, f)
+			}
+		})
+	}
+}
+
+func Test_UserPatch(t *testing.T) {
+	ops := []patchOperation{
+		{Op: "Replace", Path: "active", Value: json.RawMessage(`"False"`)},
+		{Op: "replace", Value: json.RawMessage(`{
+			"name": {"givenName": "Jane", "familyName": "Doe"},
+			"displayName": "Jane D.",
+			"phoneNumbers": [{"value": "555"}]
+		}`)},
+		{Op: "add", Path: pathDepartment, Value: json.RawMessage(`"sales"`)},
+		{Op: "replace", Path: "userName", Value: json.RawMessage(`"jane@doe.com"`)},
+	}
+
+	uu, err := userPatch(ops)
+	if err != nil {
+		t.Fatalf("failed to patch: %s", err)
+	}
+
+	if uu.Enabled == nil || *uu.Enabled {
+		t.Errorf("expected the user to be disabled")
+	}
+
+	if uu.Name == nil || *uu.Name != "Jane D." {
+		t.Errorf("expected displayName to win over name, got=%v", uu.Name)
+	}
+
+	if uu.Department == nil || *uu.Department != "sales" {
+		t.Errorf("expected department to be sales, got=%v", uu.Department)
+	}
+
+	if uu.Email == nil || uu.Email.Address != "jane@doe.com" {
+		t.Errorf("expected email to be jane@doe.com, got=%v", uu.Email)
+	}
+
+	_, err = userPatch([]patchOperation{{Op: "remove", Path: "userName"}})
+	if !errors.Is(err, errInvalidPath) {
+		t.Errorf("err=%s, got=%v", errInvalidPath, err)
+	}
+
+	_, err = userPatch([]patchOperation{{Op: "replace", Path: "userName", Value: json.RawMessage(`"not an email"`)}})
+	if !errors.Is(err, errInvalidValue) {
+		t.Errorf("err=%s, got=%v", errInvalidValue, err)
+	}
+}
+
+func Test_GroupPatch(t *testing.T) {
+	a, b, c := uuid.New(), uuid.New(), uuid.New()
+
+	ops := []patchOperation{
+		{Op: "add", Path: "members", Value: json.RawMessage(`[{"value": "` + c.String() + `"}]`)},
+		{Op: "remove", Path: `members[value eq "` + a.String() + `"]`},
+		{Op: "replace", Path: "displayName", Value: json.RawMessage(`"engineering"`)},
+	}
+
+	name, members, err := groupPatch(ops, []uuid.UUID{a, b})
+	if err != nil {
+		t.Fatalf("failed to patch: %s", err)
+	}
+
+	if name == nil || *name != "engineering" {
+		t.Errorf("expected name to be engineering, got=%v", name)
+	}
+
+	expected := map[uuid.UUID]struct{}{b: {}, c: {}}
+	if len(members) != len(expected) {
+		t.Fatalf("members=%v, got=%v", expected, members)
+	}
+
+	for id := range expected {
+		if _, ok := members[id]; !ok {
+			t.Errorf("expected %s to be a member", id)
+		}
+	}
+
+	_, members, err = groupPatch([]patchOperation{{Op: "remove", Path: "members"}}, []uuid.UUID{a, b})
+	if err != nil {
+		t.Fatalf("failed to patch: %s", err)
+	}
+
+	if len(members) != 0 {
+		t.Errorf("expected all members to be removed, got=%v", members)
+	}
+
+	_, _, err = groupPatch([]patchOperation{{Op: "add", Path: "owners", Value: json.RawMessage(`[]`)}}, nil)
+	if !errors.Is(err, errInvalidPath) {
+		t.Errorf("err=%s, got=%v", errInvalidPath, err)
+	}
+}
+
+func Test_Bearer(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var output bytes.Buffer
+	fn := func(_ context.Context) string { return "0000000000000000000000000000000" }
+	log := logger.New(&output, logger.LevelDebug, "scim_test", fn)
+
+	router := gin.New()
+	router.Use(mid.Error(log))
+
+	RegisterRoutes(Conf{
+		Router:  router,
+		Token:   "secret",
+		BaseURL: "http://localhost",
+		Tracer:  otel.Tracer("scim_tests"),
+		Logger:  log,
+	})
+
+	tests := []struct {
+		name       string
+		auth       string
+		statusCode int
+	}{
+		{name: "no_token", statusCode: http.StatusUnauthorized},
+		{name: "wrong_token", auth: "Bearer wrong", statusCode: http.StatusUnauthorized},
+		{name: "token", auth: "Bearer secret", statusCode: http.StatusOK},
+	}
+
+	for _, ts := range tests {
+		t.Run(ts.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/scim/v2/ServiceProviderConfig", nil)
+			if ts.auth != "" {
+				r.Header.Set("Authorization", ts.auth)
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			if w.Code != ts.statusCode {
+				t.Fatalf("status=%d, got=%d", ts.statusCode, w.Code)
+			}
+
+			if ct := w.Header().Get("Content-Type"); ct != contentType {
+				t.Errorf("content type=%s, got=%s", contentType, ct)
+			}
+
+			if w.Code == http.StatusOK {
+				return
+			}
+
+			var e scimError
+			if err := json.NewDecoder(w.Body).Decode(&e); err != nil {
+				t.Fatalf("failed to decode error: %s", err)
+			}
+
+			if len(e.Schemas) != 1 || e.Schemas[0] != schemaError || e.Status != "401" {
+				t.Errorf("expected a scim error, got=%+v", e)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package handler
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	groupBus "github.com/hamidoujand/jumble/internal/domains/group/bus"
+	userBus "github.com/hamidoujand/jumble/internal/domains/user/bus"
+)
+
+// Schema URNs from RFC 7643 and RFC 7644.
+const (
+	schemaUser                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	schemaEnterpriseUser        = "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"
+	schemaGroup                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	schemaServiceProviderConfig = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	schemaResourceType          = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+	schemaSchema                = "urn:ietf:params:scim:schemas:core:2.0:Schema"
+	schemaListResponse          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	schemaPatchOp               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	schemaError                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+)
+
+type meta struct {
+	ResourceType string `json:"resourceType"`
+	Created      string `json:"created,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	Location     string `json:"location,omitempty"`
+}
+
+type name struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+// full returns the name as one string, the way users are named in this service.
+func (n name) full() string {
+	if n.Formatted != "" {
+		return n.Formatted
+	}
+
+	return strings.TrimSpace(n.GivenName + " " + n.FamilyName)
+}
+
+type email struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type enterpriseUser struct {
+	Department string `json:"department,omitempty"`
+}
+
+// ==============================================================================
+
+// user is a User resource, userName is the email address of the account.
+type user struct {
+	Schemas     []string        `json:"schemas"`
+	ID          string          `json:"id"`
+	UserName    string          `json:"userName"`
+	Name        name            `json:"name"`
+	DisplayName string          `json:"displayName"`
+	Emails      []email         `json:"emails"`
+	Active      bool            `json:"active"`
+	Enterprise  *enterpriseUser `json:"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User,omitempty"`
+	Meta        meta            `json:"meta"`
+}
+
+func (h *handler) toSCIMUser(usr userBus.User) user {
+	u := user{
+		Schemas:     []string{schemaUser},
+		ID:          usr.ID.String(),
+		UserName:    usr.Email.Address,
+		Name:        name{Formatted: usr.Name},
+		DisplayName: usr.Name,
+		Emails:      []email{{Value: usr.Email.Address, Type: "work", Primary: true}},
+		Active:      usr.Enabled,
+		Meta: meta{
+			ResourceType: "User",
+			Created:      usr.CreatedAt.Format(time.RFC3339),
+			LastModified: usr.UpdatedAt.Format(time.RFC3339),
+			Location:     h.baseURL + "/Users/" + usr.ID.String(),
+		},
+	}
+
+	if usr.Department != "" {
+		u.Schemas = append(u.Schemas, schemaEnterpriseUser)
+		u.Enterprise = &enterpriseUser{Department: usr.Department}
+	}
+
+	return u
+}
+
+// writeUser is the body of POST and PUT, attributes the service has no place for are ignored.
+type writeUser struct {
+	UserName    string          `json:"userName" binding:"required"`
+	Name        name            `json:"name"`
+	DisplayName string          `json:"displayName"`
+	Active      *bool           `json:"active"`
+	Password    string          `json:"password"`
+	Enterprise  *enterpriseUser `json:"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"`
+}
+
+// fullName picks the name of the account, falling back to the userName when none is given.
+func (wu writeUser) fullName() string {
+	if wu.DisplayName != "" {
+		return wu.DisplayName
+	}
+
+	if n := wu.Name.full(); n != "" {
+		return n
+	}
+
+	return wu.UserName
+}
+
+func (wu writeUser) department() string {
+	if wu.Enterprise == nil {
+		return ""
+	}
+
+	return wu.Enterprise.Department
+}
+
+// ==============================================================================
+
+type member struct {
+	Value string `json:"value"`
+	Ref   string `json:"$ref,omitempty"`
+	Type  string `json:"type,omitempty"`
+}
+
+type group struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id"`
+	DisplayName string   `json:"displayName"`
+	Members     []member `json:"members,omitempty"`
+	Meta        meta     `json:"meta"`
+}
+
+func (h *handler) toSCIMGroup(g groupBus.Group, members []groupBus.Member) group {
+	ms := make([]member, len(members))
+	for i, m := range members {
+		ms[i] = member{
+			Value: m.UserID.String(),
+			Ref:   h.baseURL + "/Users/" + m.UserID.String(),
+			Type:  "User",
+		}
+	}
+
+	return group{
+		Schemas:     []string{schemaGroup},
+		ID:          g.ID.String(),
+		DisplayName: g.Name,
+		Members:     ms,
+		Meta: meta{
+			ResourceType: "Group",
+			Created:      g.CreatedAt.Format(time.RFC3339),
+			LastModified: g.UpdatedAt.Format(time.RFC3339),
+			Location:     h.baseURL + "/Groups/" + g.ID.String(),
+		},
+	}
+}
+
+type writeGroup struct {
+	DisplayName string   `json:"displayName" binding:"required,max=120"`
+	Members     []member `json:"members"`
+}
+
+// ==============================================================================
+
+type listResponse struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults int      `json:"totalResults"`
+	StartIndex   int      `json:"startIndex"`
+	ItemsPerPage int      `json:"itemsPerPage"`
+	Resources    any      `json:"Resources"`
+}
+
+type patchRequest struct {
+	Operations []patchOperation `json:"Operations" binding:"required,gt=0,dive"`
+}
+
+// patchOperation is one change of a PATCH request, op is matched case insensitive since some
+// identity providers send "Replace".
+type patchOperation struct {
+	Op    string          `json:"op" binding:"required"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+// scimError is the error response, scimType narrows the reason down for some 400s.
+type scimError struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
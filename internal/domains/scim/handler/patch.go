@@ -0,0 +1,310 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	userBus "github.com/hamidoujand/jumble/internal/domains/user/bus"
+)
+
+var (
+	errInvalidPath  = errors.New("invalid path")
+	errInvalidValue = errors.New("invalid value")
+)
+
+// pathDepartment is the department of the enterprise extension, as a full attribute path.
+const pathDepartment = schemaEnterpriseUser + ":department"
+
+// userChanges collects the PATCH operations of a user. displayName wins over the parts of name
+// whatever order they come in.
+type userChanges struct {
+	update      userBus.UpdateUser
+	displayName *string
+	name        *string
+}
+
+// userPatch turns the operations into an update of the user. Attributes the service has no place
+// for, e.g. phone numbers, are ignored so providers sending the full profile keep working.
+func userPatch(ops []patchOperation) (userBus.UpdateUser, error) {
+	var uc userChanges
+
+	for _, op := range ops {
+		switch strings.ToLower(op.Op) {
+		case "add", "replace":
+			if op.Path != "" {
+				if err := uc.set(op.Path, op.Value); err != nil {
+					return userBus.UpdateUser{}, err
+				}
+				continue
+			}
+
+			var attrs map[string]json.RawMessage
+			if err := json.Unmarshal(op.Value, &attrs); err != nil {
+				return userBus.UpdateUser{}, fmt.Errorf("%w: value must be an object without a path", errInvalidValue)
+			}
+
+			for path, value := range attrs {
+				if err := uc.set(path, value); err != nil {
+					return userBus.UpdateUser{}, err
+				}
+			}
+
+		case "remove":
+			switch strings.ToLower(op.Path) {
+			case strings.ToLower(pathDepartment):
+				empty := ""
+				uc.update.Department = &empty
+			case "username", "displayname", "name", "name.formatted", "active":
+				return userBus.UpdateUser{}, fmt.Errorf("%w: %s can not be removed", errInvalidPath, op.Path)
+			}
+
+		default:
+			return userBus.UpdateUser{}, fmt.Errorf("%w: unknown op %q", errInvalidValue, op.Op)
+		}
+	}
+
+	switch {
+	case uc.displayName != nil:
+		uc.update.Name = uc.displayName
+	case uc.name != nil:
+		uc.update.Name = uc.name
+	}
+
+	return uc.update, nil
+}
+
+func (uc *userChanges) set(path string, value json.RawMessage) error {
+	switch strings.ToLower(path) {
+	case "active":
+		active, err := parseBool(value)
+		if err != nil {
+			return err
+		}
+		uc.update.Enabled = &active
+
+	case "username":
+		s, err := parseString(value)
+		if err != nil {
+			return err
+		}
+
+		addr, err := mail.ParseAddress(s)
+		if err != nil {
+			return fmt.Errorf("%w: userName must be an email address", errInvalidValue)
+		}
+		uc.update.Email = addr
+
+	case "displayname":
+		s, err := parseString(value)
+		if err != nil {
+			return err
+		}
+		uc.displayName = &s
+
+	case "name.formatted":
+		s, err := parseString(value)
+		if err != nil {
+			return err
+		}
+		uc.name = &s
+
+	case "name":
+		var n name
+		if err := json.Unmarshal(value, &n); err != nil {
+			return fmt.Errorf("%w: name must be an object", errInvalidValue)
+		}
+
+		if full := n.full(); full != "" {
+			uc.name = &full
+		}
+
+	case strings.ToLower(pathDepartment):
+		s, err := parseString(value)
+		if err != nil {
+			return err
+		}
+		uc.update.Department = &s
+
+	case strings.ToLower(schemaEnterpriseUser):
+		var ext struct {
+			Department *string `json:"department"`
+		}
+		if err := json.Unmarshal(value, &ext); err != nil {
+			return fmt.Errorf("%w: %s must be an object", errInvalidValue, schemaEnterpriseUser)
+		}
+
+		if ext.Department != nil {
+			uc.update.Department = ext.Department
+		}
+	}
+
+	return nil
+}
+
+// ==============================================================================
+
+// groupPatch applies the operations to the current members of a group, it returns the new name if
+// it changed and the members the group should end up with.
+func groupPatch(ops []patchOperation, current []uuid.UUID) (*string, map[uuid.UUID]struct{}, error) {
+	members := make(map[uuid.UUID]struct{}, len(current))
+	for _, id := range current {
+		members[id] = struct{}{}
+	}
+
+	var displayName *string
+
+	setMembers := func(op string, value json.RawMessage) error {
+		ids, err := parseMembers(value)
+		if err != nil {
+			return err
+		}
+
+		if op == "replace" {
+			clear(members)
+		}
+
+		for _, id := range ids {
+			members[id] = struct{}{}
+		}
+
+		return nil
+	}
+
+	for _, op := range ops {
+		opName := strings.ToLower(op.Op)
+		path := strings.ToLower(op.Path)
+
+		switch opName {
+		case "add", "replace":
+			switch path {
+			case "":
+				var attrs map[string]json.RawMessage
+				if err := json.Unmarshal(op.Value, &attrs); err != nil {
+					return nil, nil, fmt.Errorf("%w: value must be an object without a path", errInvalidValue)
+				}
+
+				for attr, value := range attrs {
+					switch strings.ToLower(attr) {
+					case "displayname":
+						s, err := parseString(value)
+						if err != nil {
+							return nil, nil, err
+						}
+						displayName = &s
+					case "members":
+						if err := setMembers(opName, value); err != nil {
+							return nil, nil, err
+						}
+					}
+				}
+
+			case "displayname":
+				s, err := parseString(op.Value)
+				if err != nil {
+					return nil, nil, err
+				}
+				displayName = &s
+
+			case "members":
+				if err := setMembers(opName, op.Value); err != nil {
+					return nil, nil, err
+				}
+
+			default:
+				return nil, nil, fmt.Errorf("%w: %q", errInvalidPath, op.Path)
+			}
+
+		case "remove":
+			switch {
+			case path == "members" && (len(op.Value) == 0 || string(op.Value) == "null"):
+				clear(members)
+
+			case path == "members":
+				ids, err := parseMembers(op.Value)
+				if err != nil {
+					return nil, nil, err
+				}
+
+				for _, id := range ids {
+					delete(members, id)
+				}
+
+			case strings.HasPrefix(path, "members[") && strings.HasSuffix(path, "]"):
+				//e.g. members[value eq "2819c223-7f76-453a-919d-413861904646"]
+				f, err := parseFilter(op.Path[len("members["):len(op.Path)-1], "value")
+				if err != nil {
+					return nil, nil, fmt.Errorf("%w: %w", errInvalidPath, err)
+				}
+
+				id, err := uuid.Parse(f.Value)
+				if err != nil {
+					return nil, nil, fmt.Errorf("%w: member %q", errInvalidValue, f.Value)
+				}
+				delete(members, id)
+
+			default:
+				return nil, nil, fmt.Errorf("%w: %q", errInvalidPath, op.Path)
+			}
+
+		default:
+			return nil, nil, fmt.Errorf("%w: unknown op %q", errInvalidValue, op.Op)
+		}
+	}
+
+	return displayName, members, nil
+}
+
+func parseMembers(value json.RawMessage) ([]uuid.UUID, error) {
+	var ms []member
+	if err := json.Unmarshal(value, &ms); err != nil {
+		return nil, fmt.Errorf("%w: members must be a list", errInvalidValue)
+	}
+
+	return memberIDs(ms)
+}
+
+func memberIDs(ms []member) ([]uuid.UUID, error) {
+	ids := make([]uuid.UUID, len(ms))
+	for i, m := range ms {
+		id, err := uuid.Parse(m.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: member %q", errInvalidValue, m.Value)
+		}
+		ids[i] = id
+	}
+
+	return ids, nil
+}
+
+// ==============================================================================
+
+func parseString(value json.RawMessage) (string, error) {
+	var s string
+	if err := json.Unmarshal(value, &s); err != nil {
+		return "", fmt.Errorf("%w: expected a string", errInvalidValue)
+	}
+
+	return s, nil
+}
+
+// parseBool accepts booleans and, since some providers send them that way, "True" and "False".
+func parseBool(value json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(value, &b); err == nil {
+		return b, nil
+	}
+
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b, nil
+		}
+	}
+
+	return false, fmt.Errorf("%w: expected a boolean", errInvalidValue)
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	groupBus "github.com/hamidoujand/jumble/internal/domains/group/bus"
+	userBus "github.com/hamidoujand/jumble/internal/domains/user/bus"
+	"github.com/hamidoujand/jumble/pkg/logger"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type Conf struct {
+	Router   *gin.Engine
+	UserBus  *userBus.Bus
+	GroupBus *groupBus.Bus
+
+	//Token is the bearer token the identity provider sends, without one SCIM is turned off.
+	Token string
+
+	//BaseURL is the public address of the service, used in the location of resources.
+	BaseURL string
+
+	Tracer trace.Tracer
+	Logger *logger.Logger
+}
+
+// RegisterRoutes takes the mux and register endpoints on it.
+func RegisterRoutes(cfg Conf) {
+	if cfg.Token == "" {
+		return
+	}
+
+	h := handler{
+		userBus:  cfg.UserBus,
+		groupBus: cfg.GroupBus,
+		baseURL:  cfg.BaseURL + "/scim/v2",
+		tracer:   cfg.Tracer,
+		log:      cfg.Logger,
+	}
+
+	//errors are rendered the SCIM way before the app wide middleware gets to them.
+	scim := cfg.Router.Group("/scim/v2", h.renderErrors, bearer(cfg.Token))
+
+	scim.GET("/ServiceProviderConfig", h.ServiceProviderConfig)
+	scim.GET("/ResourceTypes", h.ResourceTypes)
+	scim.GET("/Schemas", h.Schemas)
+
+	scim.POST("/Users", h.CreateUser)
+	scim.GET("/Users", h.QueryUsers)
+	scim.GET("/Users/:id", h.QueryUserByID)
+	scim.PUT("/Users/:id", h.ReplaceUser)
+	scim.PATCH("/Users/:id", h.PatchUser)
+	scim.DELETE("/Users/:id", h.DeleteUser)
+
+	scim.POST("/Groups", h.CreateGroup)
+	scim.GET("/Groups", h.QueryGroups)
+	scim.GET("/Groups/:id", h.QueryGroupByID)
+	scim.PUT("/Groups/:id", h.ReplaceGroup)
+	scim.PATCH("/Groups/:id", h.PatchGroup)
+	scim.DELETE("/Groups/:id", h.DeleteGroup)
+}
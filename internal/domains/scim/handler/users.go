@@ -0,0 +1,314 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	departmentBus "github.com/hamidoujand/jumble/internal/domains/department/bus"
+	userBus "github.com/hamidoujand/jumble/internal/domains/user/bus"
+	"github.com/hamidoujand/jumble/internal/errs"
+	"github.com/hamidoujand/jumble/internal/page"
+	"github.com/hamidoujand/jumble/internal/password"
+)
+
+// CreateUser provisions an account with the "user" role, higher roles come through groups. Without
+// a password in the request the account can only log in after a password reset.
+func (h *handler) CreateUser(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "scim.handler.createUser")
+	defer span.End()
+
+	var wu writeUser
+	if err := c.ShouldBindJSON(&wu); err != nil {
+		c.Error(err)
+		return
+	}
+
+	addr, err := mail.ParseAddress(wu.UserName)
+	if err != nil {
+		fail(c, http.StatusBadRequest, "invalidValue", fmt.Errorf("userName must be an email address: %w", err))
+		return
+	}
+
+	nu := userBus.NewUser{
+		Name:       wu.fullName(),
+		Email:      *addr,
+		Roles:      []userBus.Role{userBus.RoleUser},
+		Department: wu.department(),
+		Password:   wu.Password,
+	}
+
+	var usr userBus.User
+	if wu.Password != "" {
+		usr, err = h.userBus.Create(ctx, nu)
+	} else {
+		usr, err = h.userBus.CreateWithoutPassword(ctx, nu)
+	}
+
+	if h.updateFailed(c, err) {
+		return
+	}
+
+	if wu.Active != nil && !*wu.Active {
+		usr, err = h.userBus.Update(ctx, usr, userBus.UpdateUser{Enabled: wu.Active})
+		if err != nil {
+			c.Error(errs.New(http.StatusInternalServerError, "update: %s", err))
+			return
+		}
+	}
+
+	c.Header("Location", h.baseURL+"/Users/"+usr.ID.String())
+	respond(c, http.StatusCreated, h.toSCIMUser(usr))
+}
+
+func (h *handler) QueryUsers(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "scim.handler.queryUsers")
+	defer span.End()
+
+	startIndex, count, err := listParams(c)
+	if err != nil {
+		fail(c, http.StatusBadRequest, "invalidValue", err)
+		return
+	}
+
+	//identity providers look users up by userName before creating them.
+	if raw := c.Query("filter"); raw != "" {
+		f, err := parseFilter(raw, "userName")
+		if err != nil {
+			fail(c, http.StatusBadRequest, "invalidFilter", err)
+			return
+		}
+
+		var users []user
+		usr, err := h.userBus.QueryByEmail(ctx, mail.Address{Address: f.Value})
+		switch {
+		case err == nil:
+			users = append(users, h.toSCIMUser(usr))
+		case !errors.Is(err, userBus.ErrUserNotFound):
+			c.Error(errs.New(http.StatusInternalServerError, "queryByEmail: %s", err))
+			return
+		}
+
+		total := len(users)
+		if startIndex > 1 || count == 0 {
+			users = nil
+		}
+
+		respond(c, http.StatusOK, newListResponse(users, total, startIndex))
+		return
+	}
+
+	total, err := h.userBus.Count(ctx, userBus.QueryFilter{})
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "count: %s", err))
+		return
+	}
+
+	usrs, err := h.queryWindow(ctx, startIndex, count)
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "queryWindow: %s", err))
+		return
+	}
+
+	users := make([]user, len(usrs))
+	for i, usr := range usrs {
+		users[i] = h.toSCIMUser(usr)
+	}
+
+	respond(c, http.StatusOK, newListResponse(users, total, startIndex))
+}
+
+func (h *handler) QueryUserByID(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "scim.handler.queryUserByID")
+	defer span.End()
+
+	usr, ok := h.loadUser(ctx, c)
+	if !ok {
+		return
+	}
+
+	respond(c, http.StatusOK, h.toSCIMUser(usr))
+}
+
+// ReplaceUser sets every attribute the service keeps, a missing department clears it.
+func (h *handler) ReplaceUser(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "scim.handler.replaceUser")
+	defer span.End()
+
+	usr, ok := h.loadUser(ctx, c)
+	if !ok {
+		return
+	}
+
+	var wu writeUser
+	if err := c.ShouldBindJSON(&wu); err != nil {
+		c.Error(err)
+		return
+	}
+
+	addr, err := mail.ParseAddress(wu.UserName)
+	if err != nil {
+		fail(c, http.StatusBadRequest, "invalidValue", fmt.Errorf("userName must be an email address: %w", err))
+		return
+	}
+
+	fullName := wu.fullName()
+	department := wu.department()
+
+	uu := userBus.UpdateUser{
+		Name:       &fullName,
+		Email:      addr,
+		Department: &department,
+		Enabled:    wu.Active,
+	}
+
+	if wu.Password != "" {
+		uu.Password = &wu.Password
+	}
+
+	updated, err := h.userBus.Update(ctx, usr, uu)
+	if h.updateFailed(c, err) {
+		return
+	}
+
+	respond(c, http.StatusOK, h.toSCIMUser(updated))
+}
+
+// PatchUser applies the operations, deprovisioning usually arrives as a replace of active.
+func (h *handler) PatchUser(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "scim.handler.patchUser")
+	defer span.End()
+
+	usr, ok := h.loadUser(ctx, c)
+	if !ok {
+		return
+	}
+
+	var pr patchRequest
+	if err := c.ShouldBindJSON(&pr); err != nil {
+		c.Error(err)
+		return
+	}
+
+	uu, err := userPatch(pr.Operations)
+	if errors.Is(err, errInvalidPath) {
+		fail(c, http.StatusBadRequest, "invalidPath", err)
+		return
+	}
+
+	if err != nil {
+		fail(c, http.StatusBadRequest, "invalidValue", err)
+		return
+	}
+
+	updated, err := h.userBus.Update(ctx, usr, uu)
+	if h.updateFailed(c, err) {
+		return
+	}
+
+	respond(c, http.StatusOK, h.toSCIMUser(updated))
+}
+
+func (h *handler) DeleteUser(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "scim.handler.deleteUser")
+	defer span.End()
+
+	usr, ok := h.loadUser(ctx, c)
+	if !ok {
+		return
+	}
+
+	if err := h.userBus.Delete(ctx, usr); err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "delete: %s", err))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ==============================================================================
+
+// loadUser fetches the user in the path, when it fails the error is already set on the context.
+func (h *handler) loadUser(ctx context.Context, c *gin.Context) (userBus.User, bool) {
+	p := c.Param("id")
+
+	//ids are opaque to clients, an id that is not ours is just not found.
+	userID, err := uuid.Parse(p)
+	if err != nil {
+		c.Error(errs.New(http.StatusNotFound, "%s", userBus.ErrUserNotFound))
+		return userBus.User{}, false
+	}
+
+	usr, err := h.userBus.QueryByID(ctx, userID)
+	if errors.Is(err, userBus.ErrUserNotFound) {
+		c.Error(errs.New(http.StatusNotFound, "%s", err))
+		return userBus.User{}, false
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "queryByID: %s", err))
+		return userBus.User{}, false
+	}
+
+	return usr, true
+}
+
+// updateFailed maps the errors of creating or updating a user, it reports whether there was one.
+func (h *handler) updateFailed(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return false
+
+	case errors.Is(err, userBus.ErrDuplicatedEmail):
+		fail(c, http.StatusConflict, "uniqueness", userBus.ErrDuplicatedEmail)
+
+	case errors.Is(err, password.ErrWeakPassword) || errors.Is(err, userBus.ErrPasswordReused) ||
+		errors.Is(err, departmentBus.ErrDepartmentNotFound):
+		fail(c, http.StatusBadRequest, "invalidValue", err)
+
+	default:
+		c.Error(errs.New(http.StatusInternalServerError, "%s", err))
+	}
+
+	return true
+}
+
+// queryWindow returns count users starting at the 1-based startIndex. The store pages in multiples
+// of count, so a window that is not aligned to them spans two pages.
+func (h *handler) queryWindow(ctx context.Context, startIndex int, count int) ([]userBus.User, error) {
+	if count == 0 {
+		return nil, nil
+	}
+
+	order := userBus.Field{Name: userBus.OrderByCreatedAt, Dir: userBus.OrderByASC}
+
+	offset := startIndex - 1
+	first := page.Page{Number: offset/count + 1, Rows: count}
+
+	usrs, err := h.userBus.Query(ctx, userBus.QueryFilter{}, order, first)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	skip := offset % count
+	if skip == 0 {
+		return usrs, nil
+	}
+
+	next, err := h.userBus.Query(ctx, userBus.QueryFilter{}, order, page.Page{Number: first.Number + 1, Rows: count})
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	usrs = append(usrs, next...)
+	if skip >= len(usrs) {
+		return nil, nil
+	}
+
+	usrs = usrs[skip:]
+	return usrs[:min(count, len(usrs))], nil
+}
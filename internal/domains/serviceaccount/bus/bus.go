@@ -0,0 +1,165 @@
+// Package bus provides the business logic of service accounts and their API keys.
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrServiceAccountNotFound   = errors.New("service account not found")
+	ErrDuplicatedServiceAccount = errors.New("service account name already in use")
+	ErrServiceAccountDisabled   = errors.New("service account is disabled")
+	ErrKeyNotFound              = errors.New("api key not found")
+	ErrInvalidKey               = errors.New("invalid, expired or revoked api key")
+	ErrInvalidScopes            = errors.New("api keys need at least one scope")
+)
+
+type store interface {
+	Create(ctx context.Context, sa ServiceAccount) error
+	Update(ctx context.Context, sa ServiceAccount) error
+	Delete(ctx context.Context, sa ServiceAccount) error
+	QueryByID(ctx context.Context, id uuid.UUID) (ServiceAccount, error)
+	Query(ctx context.Context) ([]ServiceAccount, error)
+	CreateKey(ctx context.Context, k APIKey) error
+	RevokeKey(ctx context.Context, k APIKey, revokedAt time.Time) error
+	UseKey(ctx context.Context, k APIKey, usedAt time.Time) error
+	QueryKeyByID(ctx context.Context, serviceAccountID uuid.UUID, id uuid.UUID) (APIKey, error)
+	QueryKeyByPrefix(ctx context.Context, prefix string) (APIKey, error)
+	QueryKeys(ctx context.Context, serviceAccountID uuid.UUID) ([]APIKey, error)
+}
+
+// roleValidator makes sure roles exist before they are given to a service account, the role bus
+// satisfies it.
+type roleValidator interface {
+	Validate(ctx context.Context, names []string) error
+}
+
+type Bus struct {
+	store store
+	roles roleValidator
+}
+
+// Option configures optional dependencies of the Bus.
+type Option func(*Bus)
+
+// WithRoleValidator rejects service accounts with roles that do not exist.
+func WithRoleValidator(v roleValidator) Option {
+	return func(b *Bus) {
+		b.roles = v
+	}
+}
+
+func New(store store, opts ...Option) *Bus {
+	b := Bus{store: store}
+
+	for _, opt := range opts {
+		opt(&b)
+	}
+
+	return &b
+}
+
+func (b *Bus) Create(ctx context.Context, ns NewServiceAccount) (ServiceAccount, error) {
+	if err := b.validateRoles(ctx, ns.Roles); err != nil {
+		return ServiceAccount{}, err
+	}
+
+	roles := ns.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+
+	now := time.Now().Truncate(time.Microsecond)
+
+	sa := ServiceAccount{
+		ID:          uuid.New(),
+		Name:        ns.Name,
+		Description: ns.Description,
+		Roles:       roles,
+		Enabled:     true,
+		CreatedBy:   ns.CreatedBy,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	if err := b.store.Create(ctx, sa); err != nil {
+		return ServiceAccount{}, fmt.Errorf("create: %w", err)
+	}
+
+	return sa, nil
+}
+
+// Update changes the service account, disabling it stops all of its keys at once.
+func (b *Bus) Update(ctx context.Context, sa ServiceAccount, us UpdateServiceAccount) (ServiceAccount, error) {
+	if us.Name != nil {
+		sa.Name = *us.Name
+	}
+
+	if us.Description != nil {
+		sa.Description = *us.Description
+	}
+
+	if us.Roles != nil {
+		if err := b.validateRoles(ctx, us.Roles); err != nil {
+			return ServiceAccount{}, err
+		}
+		sa.Roles = us.Roles
+	}
+
+	if us.Enabled != nil {
+		sa.Enabled = *us.Enabled
+	}
+
+	sa.UpdatedAt = time.Now().Truncate(time.Microsecond)
+	if err := b.store.Update(ctx, sa); err != nil {
+		return ServiceAccount{}, fmt.Errorf("update: %w", err)
+	}
+
+	return sa, nil
+}
+
+// Delete removes the service account along with its keys.
+func (b *Bus) Delete(ctx context.Context, sa ServiceAccount) error {
+	if err := b.store.Delete(ctx, sa); err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+
+	return nil
+}
+
+func (b *Bus) QueryByID(ctx context.Context, id uuid.UUID) (ServiceAccount, error) {
+	sa, err := b.store.QueryByID(ctx, id)
+	if err != nil {
+		return ServiceAccount{}, fmt.Errorf("queryByID: %w", err)
+	}
+
+	return sa, nil
+}
+
+func (b *Bus) Query(ctx context.Context) ([]ServiceAccount, error) {
+	sas, err := b.store.Query(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return sas, nil
+}
+
+// ==============================================================================
+
+func (b *Bus) validateRoles(ctx context.Context, roles []string) error {
+	if b.roles == nil || len(roles) == 0 {
+		return nil
+	}
+
+	if err := b.roles.Validate(ctx, roles); err != nil {
+		return fmt.Errorf("validateRoles: %w", err)
+	}
+
+	return nil
+}
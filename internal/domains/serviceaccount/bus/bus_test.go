@@ -0,0 +1,211 @@
+package bus_test
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hamidoujand/jumble/internal/dbtest"
+	roleBus "github.com/hamidoujand/jumble/internal/domains/role/bus"
+	"github.com/hamidoujand/jumble/internal/domains/role/store/roledb"
+	"github.com/hamidoujand/jumble/internal/domains/serviceaccount/bus"
+	"github.com/hamidoujand/jumble/internal/domains/serviceaccount/store/serviceaccountdb"
+	"github.com/hamidoujand/jumble/pkg/docker"
+	"github.com/hamidoujand/jumble/pkg/telemetry"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var container docker.Container
+var tracer trace.Tracer
+
+func TestMain(m *testing.M) {
+	// before all
+	var err error
+	container, err = dbtest.CreateDBContainer()
+	if err != nil {
+		log.Fatalf("createDBContainer: %s", err)
+	}
+
+	defer docker.StopContainer(container.Name)
+	cfg := telemetry.Config{
+		ServiceName: "serviceaccount_bus_test",
+		Host:        "",
+		Build:       "v0.0.1",
+	}
+
+	cleanup, err := telemetry.SetupOTelSDK(cfg)
+	if err != nil {
+		log.Fatalf("setupOTelSDK: %s", err)
+	}
+
+	tracer = otel.Tracer("serviceaccount_bus_tests")
+
+	defer cleanup(context.Background())
+
+	// tests
+	os.Exit(m.Run())
+
+}
+
+func Test_ServiceAccounts(t *testing.T) {
+	t.Parallel()
+
+	db := dbtest.New(t, container, "service_accounts")
+	rb := roleBus.New(roledb.NewStore(db, tracer))
+	b := bus.New(serviceaccountdb.NewStore(db, tracer), bus.WithRoleValidator(rb))
+
+	if _, err := b.Create(context.Background(), bus.NewServiceAccount{Name: "ci", Roles: []string{"missing"}}); !errors.Is(err, roleBus.ErrRoleNotFound) {
+		t.Fatalf("expected error to be %v, got %v", roleBus.ErrRoleNotFound, err)
+	}
+
+	sa, err := b.Create(context.Background(), bus.NewServiceAccount{
+		Name:        "ci",
+		Description: "deploys from the pipeline",
+		Roles:       []string{"admin"},
+	})
+	if err != nil {
+		t.Fatalf("expected to create a service account: %s", err)
+	}
+
+	if !sa.Enabled {
+		t.Errorf("expected new service accounts to be enabled")
+	}
+
+	if _, err := b.Create(context.Background(), bus.NewServiceAccount{Name: "ci"}); !errors.Is(err, bus.ErrDuplicatedServiceAccount) {
+		t.Fatalf("expected error to be %v, got %v", bus.ErrDuplicatedServiceAccount, err)
+	}
+
+	name := "ci-bot"
+	updated, err := b.Update(context.Background(), sa, bus.UpdateServiceAccount{Name: &name})
+	if err != nil {
+		t.Fatalf("expected to update the service account: %s", err)
+	}
+
+	fetched, err := b.QueryByID(context.Background(), sa.ID)
+	if err != nil {
+		t.Fatalf("expected to query the service account: %s", err)
+	}
+
+	if fetched.Name != name || !fetched.UpdatedAt.Equal(updated.UpdatedAt) {
+		t.Errorf("expected the update to be stored, got %+v", fetched)
+	}
+
+	if err := b.Delete(context.Background(), fetched); err != nil {
+		t.Fatalf("expected to delete the service account: %s", err)
+	}
+
+	if _, err := b.QueryByID(context.Background(), sa.ID); !errors.Is(err, bus.ErrServiceAccountNotFound) {
+		t.Fatalf("expected error to be %v, got %v", bus.ErrServiceAccountNotFound, err)
+	}
+}
+
+func Test_APIKeys(t *testing.T) {
+	t.Parallel()
+
+	db := dbtest.New(t, container, "api_keys")
+	b := bus.New(serviceaccountdb.NewStore(db, tracer))
+
+	sa, err := b.Create(context.Background(), bus.NewServiceAccount{Name: "ci", Roles: []string{"admin"}})
+	if err != nil {
+		t.Fatalf("expected to create a service account: %s", err)
+	}
+
+	if _, _, err := b.CreateKey(context.Background(), sa, bus.NewAPIKey{Name: "deploy"}); !errors.Is(err, bus.ErrInvalidScopes) {
+		t.Fatalf("expected error to be %v, got %v", bus.ErrInvalidScopes, err)
+	}
+
+	if _, _, err := b.CreateKey(context.Background(), sa, bus.NewAPIKey{Name: "deploy", Scopes: []string{"nope"}}); !errors.Is(err, roleBus.ErrUnknownPermission) {
+		t.Fatalf("expected error to be %v, got %v", roleBus.ErrUnknownPermission, err)
+	}
+
+	k, key, err := b.CreateKey(context.Background(), sa, bus.NewAPIKey{
+		Name:   "deploy",
+		Scopes: []string{roleBus.PermUsersRead},
+	})
+	if err != nil {
+		t.Fatalf("expected to create a key: %s", err)
+	}
+
+	if !strings.HasPrefix(key, bus.KeyPrefix+"_"+k.Prefix+"_") {
+		t.Errorf("expected key to start with its prefix %q, got %q", k.Prefix, key)
+	}
+
+	gotSA, gotKey, err := b.Authenticate(context.Background(), key)
+	if err != nil {
+		t.Fatalf("expected to authenticate with the key: %s", err)
+	}
+
+	if gotSA.ID != sa.ID || gotKey.ID != k.ID {
+		t.Errorf("expected key %s of %s, got key %s of %s", k.ID, sa.ID, gotKey.ID, gotSA.ID)
+	}
+
+	if gotKey.LastUsedAt == nil {
+		t.Errorf("expected the use of the key to be recorded")
+	}
+
+	if _, _, err := b.Authenticate(context.Background(), key+"x"); !errors.Is(err, bus.ErrInvalidKey) {
+		t.Errorf("expected error to be %v, got %v", bus.ErrInvalidKey, err)
+	}
+
+	if _, _, err := b.Authenticate(context.Background(), "not-a-key"); !errors.Is(err, bus.ErrInvalidKey) {
+		t.Errorf("expected error to be %v, got %v", bus.ErrInvalidKey, err)
+	}
+
+	//disabling the service account stops its keys.
+	disabled := false
+	sa, err = b.Update(context.Background(), sa, bus.UpdateServiceAccount{Enabled: &disabled})
+	if err != nil {
+		t.Fatalf("expected to disable the service account: %s", err)
+	}
+
+	if _, _, err := b.Authenticate(context.Background(), key); !errors.Is(err, bus.ErrServiceAccountDisabled) {
+		t.Errorf("expected error to be %v, got %v", bus.ErrServiceAccountDisabled, err)
+	}
+
+	enabled := true
+	sa, err = b.Update(context.Background(), sa, bus.UpdateServiceAccount{Enabled: &enabled})
+	if err != nil {
+		t.Fatalf("expected to enable the service account: %s", err)
+	}
+
+	revoked, err := b.RevokeKey(context.Background(), k)
+	if err != nil {
+		t.Fatalf("expected to revoke the key: %s", err)
+	}
+
+	if revoked.RevokedAt == nil {
+		t.Errorf("expected the key to be revoked")
+	}
+
+	if _, _, err := b.Authenticate(context.Background(), key); !errors.Is(err, bus.ErrInvalidKey) {
+		t.Errorf("expected error to be %v, got %v", bus.ErrInvalidKey, err)
+	}
+
+	expiresAt := time.Now().Add(-time.Minute)
+	_, expired, err := b.CreateKey(context.Background(), sa, bus.NewAPIKey{
+		Name:      "expired",
+		Scopes:    []string{roleBus.PermUsersRead},
+		ExpiresAt: &expiresAt,
+	})
+	if err != nil {
+		t.Fatalf("expected to create a key: %s", err)
+	}
+
+	if _, _, err := b.Authenticate(context.Background(), expired); !errors.Is(err, bus.ErrInvalidKey) {
+		t.Errorf("expected error to be %v, got %v", bus.ErrInvalidKey, err)
+	}
+
+	ks, err := b.QueryKeys(context.Background(), sa.ID)
+	if err != nil {
+		t.Fatalf("expected to query keys: %s", err)
+	}
+
+	if len(ks) != 2 {
+		t.Errorf("expected 2 keys, got %d", len(ks))
+	}
+}
@@ -0,0 +1,148 @@
+package bus
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	roleBus "github.com/hamidoujand/jumble/internal/domains/role/bus"
+)
+
+// KeyPrefix starts every API key, it makes leaked keys easy to spot for secret scanners.
+const KeyPrefix = "jmb"
+
+// lastUsedPrecision is how stale the last use of a key may get, writing it on every request
+// would turn each API call into a database write.
+const lastUsedPrecision = time.Minute
+
+// CreateKey issues an API key for the service account and returns it along with the plain key,
+// which is not kept and can not be shown again.
+func (b *Bus) CreateKey(ctx context.Context, sa ServiceAccount, nk NewAPIKey) (APIKey, string, error) {
+	if len(nk.Scopes) == 0 {
+		return APIKey{}, "", ErrInvalidScopes
+	}
+
+	if err := roleBus.ValidatePermissions(nk.Scopes); err != nil {
+		return APIKey{}, "", fmt.Errorf("validatePermissions: %w", err)
+	}
+
+	prefix := make([]byte, 6)
+	if _, err := rand.Read(prefix); err != nil {
+		return APIKey{}, "", fmt.Errorf("read: %w", err)
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return APIKey{}, "", fmt.Errorf("read: %w", err)
+	}
+
+	//the secret is random, so a fast hash is enough for lookups.
+	p := hex.EncodeToString(prefix)
+	key := KeyPrefix + "_" + p + "_" + base64.RawURLEncoding.EncodeToString(secret)
+
+	k := APIKey{
+		ID:               uuid.New(),
+		ServiceAccountID: sa.ID,
+		Name:             nk.Name,
+		Prefix:           p,
+		Hash:             hashKey(key),
+		Scopes:           nk.Scopes,
+		ExpiresAt:        nk.ExpiresAt,
+		CreatedAt:        time.Now().Truncate(time.Microsecond),
+	}
+
+	if err := b.store.CreateKey(ctx, k); err != nil {
+		return APIKey{}, "", fmt.Errorf("createKey: %w", err)
+	}
+
+	return k, key, nil
+}
+
+// RevokeKey stops the key from being accepted, revoked keys are kept so their use stays traceable.
+func (b *Bus) RevokeKey(ctx context.Context, k APIKey) (APIKey, error) {
+	if k.RevokedAt != nil {
+		return k, nil
+	}
+
+	now := time.Now().Truncate(time.Microsecond)
+	if err := b.store.RevokeKey(ctx, k, now); err != nil {
+		return APIKey{}, fmt.Errorf("revokeKey: %w", err)
+	}
+
+	k.RevokedAt = &now
+	return k, nil
+}
+
+func (b *Bus) QueryKeyByID(ctx context.Context, serviceAccountID uuid.UUID, id uuid.UUID) (APIKey, error) {
+	k, err := b.store.QueryKeyByID(ctx, serviceAccountID, id)
+	if err != nil {
+		return APIKey{}, fmt.Errorf("queryKeyByID: %w", err)
+	}
+
+	return k, nil
+}
+
+func (b *Bus) QueryKeys(ctx context.Context, serviceAccountID uuid.UUID) ([]APIKey, error) {
+	ks, err := b.store.QueryKeys(ctx, serviceAccountID)
+	if err != nil {
+		return nil, fmt.Errorf("queryKeys: %w", err)
+	}
+
+	return ks, nil
+}
+
+// Authenticate returns the service account and key behind a plain key. Unknown, revoked and
+// expired keys all fail with ErrInvalidKey.
+func (b *Bus) Authenticate(ctx context.Context, key string) (ServiceAccount, APIKey, error) {
+	parts := strings.SplitN(key, "_", 3)
+	if len(parts) != 3 || parts[0] != KeyPrefix {
+		return ServiceAccount{}, APIKey{}, ErrInvalidKey
+	}
+
+	k, err := b.store.QueryKeyByPrefix(ctx, parts[1])
+	if errors.Is(err, ErrKeyNotFound) {
+		return ServiceAccount{}, APIKey{}, ErrInvalidKey
+	}
+
+	if err != nil {
+		return ServiceAccount{}, APIKey{}, fmt.Errorf("queryKeyByPrefix: %w", err)
+	}
+
+	now := time.Now().Truncate(time.Microsecond)
+	if subtle.ConstantTimeCompare(hashKey(key), k.Hash) != 1 || !k.Active(now) {
+		return ServiceAccount{}, APIKey{}, ErrInvalidKey
+	}
+
+	sa, err := b.store.QueryByID(ctx, k.ServiceAccountID)
+	if err != nil {
+		return ServiceAccount{}, APIKey{}, fmt.Errorf("queryByID: %w", err)
+	}
+
+	if !sa.Enabled {
+		return ServiceAccount{}, APIKey{}, ErrServiceAccountDisabled
+	}
+
+	if k.LastUsedAt == nil || now.Sub(*k.LastUsedAt) > lastUsedPrecision {
+		if err := b.store.UseKey(ctx, k, now); err != nil {
+			return ServiceAccount{}, APIKey{}, fmt.Errorf("useKey: %w", err)
+		}
+		k.LastUsedAt = &now
+	}
+
+	return sa, k, nil
+}
+
+// ==============================================================================
+
+func hashKey(key string) []byte {
+	sum := sha256.Sum256([]byte(key))
+	return sum[:]
+}
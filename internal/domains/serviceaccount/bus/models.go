@@ -0,0 +1,62 @@
+package bus
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ServiceAccount is a non-human identity for machine clients, it gets permissions through roles
+// like users do and authenticates with API keys instead of a password.
+type ServiceAccount struct {
+	ID          uuid.UUID
+	Name        string
+	Description string
+	Roles       []string
+	Enabled     bool
+	CreatedBy   *uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type NewServiceAccount struct {
+	Name        string
+	Description string
+	Roles       []string
+	CreatedBy   *uuid.UUID
+}
+
+// UpdateServiceAccount changes the fields that are set.
+type UpdateServiceAccount struct {
+	Name        *string
+	Description *string
+	Roles       []string
+	Enabled     *bool
+}
+
+// APIKey belongs to a service account, Scopes are the permissions it is limited to. Only the
+// hash of the key is kept, the Prefix is shown to tell keys apart.
+type APIKey struct {
+	ID               uuid.UUID
+	ServiceAccountID uuid.UUID
+	Name             string
+	Prefix           string
+	Hash             []byte
+	Scopes           []string
+	ExpiresAt        *time.Time
+	LastUsedAt       *time.Time
+	RevokedAt        *time.Time
+	CreatedAt        time.Time
+}
+
+// Active reports whether the key can still be used at the given time.
+func (k APIKey) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// NewAPIKey issues a key, a nil ExpiresAt means it never expires.
+type NewAPIKey struct {
+	Name      string
+	Scopes    []string
+	ExpiresAt *time.Time
+}
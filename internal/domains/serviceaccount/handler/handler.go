@@ -0,0 +1,292 @@
+// Package handler provides endpoints to manage service accounts and their API keys.
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	roleBus "github.com/hamidoujand/jumble/internal/domains/role/bus"
+	serviceAccountBus "github.com/hamidoujand/jumble/internal/domains/serviceaccount/bus"
+	userBus "github.com/hamidoujand/jumble/internal/domains/user/bus"
+	"github.com/hamidoujand/jumble/internal/errs"
+	"github.com/hamidoujand/jumble/internal/mid"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type handler struct {
+	serviceAccountBus *serviceAccountBus.Bus
+	roleBus           *roleBus.Bus
+	tracer            trace.Tracer
+}
+
+func (h *handler) CreateServiceAccount(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "serviceaccount.handler.createServiceAccount")
+	defer span.End()
+
+	var ns newServiceAccount
+	if err := c.ShouldBindJSON(&ns); err != nil {
+		c.Error(err)
+		return
+	}
+
+	busSA := toBusNewServiceAccount(ns)
+
+	if !h.canAssignRoles(c, busSA.Roles) {
+		return
+	}
+
+	//service accounts can create other service accounts, only users are recorded as creators.
+	if val, ok := c.Get("user"); ok {
+		if usr, ok := val.(userBus.User); ok {
+			busSA.CreatedBy = &usr.ID
+		}
+	}
+
+	sa, err := h.serviceAccountBus.Create(ctx, busSA)
+	if errors.Is(err, serviceAccountBus.ErrDuplicatedServiceAccount) || errors.Is(err, roleBus.ErrRoleNotFound) {
+		c.Error(errs.New(http.StatusBadRequest, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "create: %s", err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, toAppServiceAccount(sa))
+}
+
+func (h *handler) UpdateServiceAccount(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "serviceaccount.handler.updateServiceAccount")
+	defer span.End()
+
+	var us updateServiceAccount
+	if err := c.ShouldBindJSON(&us); err != nil {
+		c.Error(err)
+		return
+	}
+
+	busSA := toBusUpdateServiceAccount(us)
+
+	if !h.canAssignRoles(c, busSA.Roles) {
+		return
+	}
+
+	sa, ok := h.loadServiceAccount(ctx, c)
+	if !ok {
+		return
+	}
+
+	updated, err := h.serviceAccountBus.Update(ctx, sa, busSA)
+	if errors.Is(err, serviceAccountBus.ErrDuplicatedServiceAccount) || errors.Is(err, roleBus.ErrRoleNotFound) {
+		c.Error(errs.New(http.StatusBadRequest, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "update: %s", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, toAppServiceAccount(updated))
+}
+
+func (h *handler) DeleteServiceAccount(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "serviceaccount.handler.deleteServiceAccount")
+	defer span.End()
+
+	sa, ok := h.loadServiceAccount(ctx, c)
+	if !ok {
+		return
+	}
+
+	if err := h.serviceAccountBus.Delete(ctx, sa); err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "delete: %s", err))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *handler) QueryServiceAccountByID(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "serviceaccount.handler.queryServiceAccountByID")
+	defer span.End()
+
+	sa, ok := h.loadServiceAccount(ctx, c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, toAppServiceAccount(sa))
+}
+
+func (h *handler) QueryServiceAccounts(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "serviceaccount.handler.queryServiceAccounts")
+	defer span.End()
+
+	sas, err := h.serviceAccountBus.Query(ctx)
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "query: %s", err))
+		return
+	}
+
+	apps := make([]serviceAccount, len(sas))
+	for i, sa := range sas {
+		apps[i] = toAppServiceAccount(sa)
+	}
+
+	c.JSON(http.StatusOK, apps)
+}
+
+// ==============================================================================
+
+func (h *handler) CreateKey(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "serviceaccount.handler.createKey")
+	defer span.End()
+
+	var nk newAPIKey
+	if err := c.ShouldBindJSON(&nk); err != nil {
+		c.Error(err)
+		return
+	}
+
+	sa, ok := h.loadServiceAccount(ctx, c)
+	if !ok {
+		return
+	}
+
+	k, key, err := h.serviceAccountBus.CreateKey(ctx, sa, toBusNewAPIKey(nk))
+	if errors.Is(err, serviceAccountBus.ErrInvalidScopes) || errors.Is(err, roleBus.ErrUnknownPermission) {
+		c.Error(errs.New(http.StatusBadRequest, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "createKey: %s", err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, createdKey{apiKey: toAppAPIKey(k), Key: key})
+}
+
+func (h *handler) QueryKeys(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "serviceaccount.handler.queryKeys")
+	defer span.End()
+
+	sa, ok := h.loadServiceAccount(ctx, c)
+	if !ok {
+		return
+	}
+
+	ks, err := h.serviceAccountBus.QueryKeys(ctx, sa.ID)
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "queryKeys: %s", err))
+		return
+	}
+
+	apps := make([]apiKey, len(ks))
+	for i, k := range ks {
+		apps[i] = toAppAPIKey(k)
+	}
+
+	c.JSON(http.StatusOK, apps)
+}
+
+func (h *handler) RevokeKey(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "serviceaccount.handler.revokeKey")
+	defer span.End()
+
+	sa, ok := h.loadServiceAccount(ctx, c)
+	if !ok {
+		return
+	}
+
+	p := c.Param("keyId")
+
+	keyID, err := uuid.Parse(p)
+	if err != nil {
+		c.Error(errs.New(http.StatusBadRequest, "invalid key id: %s", p))
+		return
+	}
+
+	k, err := h.serviceAccountBus.QueryKeyByID(ctx, sa.ID, keyID)
+	if errors.Is(err, serviceAccountBus.ErrKeyNotFound) {
+		c.Error(errs.New(http.StatusNotFound, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "queryKeyByID: %s", err))
+		return
+	}
+
+	if _, err := h.serviceAccountBus.RevokeKey(ctx, k); err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "revokeKey: %s", err))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ==============================================================================
+
+// canAssignRoles makes sure only users who can assign roles directly can hand them out to a
+// service account, and only roles that grant nothing they lack. When they can not the error is
+// already set on the context.
+func (h *handler) canAssignRoles(c *gin.Context, roles []string) bool {
+	if len(roles) == 0 {
+		return true
+	}
+
+	ps, err := mid.Permissions(c, h.roleBus)
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "permissions: %s", err))
+		return false
+	}
+
+	if !ps.Has(roleBus.PermUsersRolesAssign) {
+		c.Error(errs.New(http.StatusForbidden, "assigning roles needs the %s permission", roleBus.PermUsersRolesAssign))
+		return false
+	}
+
+	ok, err := mid.CanGrant(c, h.roleBus, roles)
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "canGrant: %s", err))
+		return false
+	}
+
+	if !ok {
+		c.Error(errs.New(http.StatusForbidden, "roles can not grant permissions you do not have"))
+		return false
+	}
+
+	return true
+}
+
+// loadServiceAccount fetches the service account in the path, when it fails the error is already
+// set on the context.
+func (h *handler) loadServiceAccount(ctx context.Context, c *gin.Context) (serviceAccountBus.ServiceAccount, bool) {
+	p := c.Param("id")
+
+	id, err := uuid.Parse(p)
+	if err != nil {
+		c.Error(errs.New(http.StatusBadRequest, "invalid id: %s", p))
+		return serviceAccountBus.ServiceAccount{}, false
+	}
+
+	sa, err := h.serviceAccountBus.QueryByID(ctx, id)
+	if errors.Is(err, serviceAccountBus.ErrServiceAccountNotFound) {
+		c.Error(errs.New(http.StatusNotFound, "%s", err))
+		return serviceAccountBus.ServiceAccount{}, false
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "queryByID: %s", err))
+		return serviceAccountBus.ServiceAccount{}, false
+	}
+
+	return sa, true
+}
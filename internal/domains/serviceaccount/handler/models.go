@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"time"
+
+	serviceAccountBus "github.com/hamidoujand/jumble/internal/domains/serviceaccount/bus"
+)
+
+type serviceAccount struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Roles       []string `json:"roles"`
+	Enabled     bool     `json:"enabled"`
+	CreatedBy   *string  `json:"createdBy"`
+	CreatedAt   string   `json:"createdAt"`
+	UpdatedAt   string   `json:"updatedAt"`
+}
+
+func toAppServiceAccount(sa serviceAccountBus.ServiceAccount) serviceAccount {
+	var createdBy *string
+	if sa.CreatedBy != nil {
+		id := sa.CreatedBy.String()
+		createdBy = &id
+	}
+
+	return serviceAccount{
+		ID:          sa.ID.String(),
+		Name:        sa.Name,
+		Description: sa.Description,
+		Roles:       sa.Roles,
+		Enabled:     sa.Enabled,
+		CreatedBy:   createdBy,
+		CreatedAt:   sa.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:   sa.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+type apiKey struct {
+	ID               string   `json:"id"`
+	ServiceAccountID string   `json:"serviceAccountId"`
+	Name             string   `json:"name"`
+	Prefix           string   `json:"prefix"`
+	Scopes           []string `json:"scopes"`
+	ExpiresAt        *string  `json:"expiresAt"`
+	LastUsedAt       *string  `json:"lastUsedAt"`
+	RevokedAt        *string  `json:"revokedAt"`
+	CreatedAt        string   `json:"createdAt"`
+}
+
+func toAppAPIKey(k serviceAccountBus.APIKey) apiKey {
+	return apiKey{
+		ID:               k.ID.String(),
+		ServiceAccountID: k.ServiceAccountID.String(),
+		Name:             k.Name,
+		Prefix:           k.Prefix,
+		Scopes:           k.Scopes,
+		ExpiresAt:        formatTime(k.ExpiresAt),
+		LastUsedAt:       formatTime(k.LastUsedAt),
+		RevokedAt:        formatTime(k.RevokedAt),
+		CreatedAt:        k.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+// createdKey is only returned when the key is issued, it is the one time the plain key is shown.
+type createdKey struct {
+	apiKey
+	Key string `json:"key"`
+}
+
+func formatTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+
+	s := t.Format(time.RFC3339)
+	return &s
+}
+
+// ==============================================================================
+
+type newServiceAccount struct {
+	Name        string   `json:"name" binding:"required,max=120"`
+	Description string   `json:"description" binding:"max=400"`
+	Roles       []string `json:"roles" binding:"omitempty,dive,required,max=32"`
+}
+
+func toBusNewServiceAccount(ns newServiceAccount) serviceAccountBus.NewServiceAccount {
+	return serviceAccountBus.NewServiceAccount{
+		Name:        ns.Name,
+		Description: ns.Description,
+		Roles:       ns.Roles,
+	}
+}
+
+type updateServiceAccount struct {
+	Name        *string  `json:"name" binding:"omitempty,min=1,max=120"`
+	Description *string  `json:"description" binding:"omitempty,max=400"`
+	Roles       []string `json:"roles" binding:"omitempty,dive,required,max=32"`
+	Enabled     *bool    `json:"enabled"`
+}
+
+func toBusUpdateServiceAccount(us updateServiceAccount) serviceAccountBus.UpdateServiceAccount {
+	return serviceAccountBus.UpdateServiceAccount{
+		Name:        us.Name,
+		Description: us.Description,
+		Roles:       us.Roles,
+		Enabled:     us.Enabled,
+	}
+}
+
+// newAPIKey issues a key that expires after ExpiresIn seconds, or never when it is left out.
+type newAPIKey struct {
+	Name      string   `json:"name" binding:"required,max=120"`
+	Scopes    []string `json:"scopes" binding:"required,min=1,dive,required,max=64"`
+	ExpiresIn *int     `json:"expiresIn" binding:"omitempty,min=60"`
+}
+
+func toBusNewAPIKey(nk newAPIKey) serviceAccountBus.NewAPIKey {
+	var expiresAt *time.Time
+	if nk.ExpiresIn != nil {
+		t := time.Now().Add(time.Duration(*nk.ExpiresIn) * time.Second).Truncate(time.Microsecond)
+		expiresAt = &t
+	}
+
+	return serviceAccountBus.NewAPIKey{
+		Name:      nk.Name,
+		Scopes:    nk.Scopes,
+		ExpiresAt: expiresAt,
+	}
+}
@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/hamidoujand/jumble/internal/auth"
+	roleBus "github.com/hamidoujand/jumble/internal/domains/role/bus"
+	serviceAccountBus "github.com/hamidoujand/jumble/internal/domains/serviceaccount/bus"
+	"github.com/hamidoujand/jumble/internal/mid"
+	"github.com/hamidoujand/jumble/pkg/logger"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type Conf struct {
+	Router            *gin.Engine
+	AuthConf          mid.AuthConf
+	ServiceAccountBus *serviceAccountBus.Bus
+	RoleBus           *roleBus.Bus
+	Tracer            trace.Tracer
+	Logger            *logger.Logger
+}
+
+// RegisterRoutes takes the mux and register endpoints on it.
+func RegisterRoutes(cfg Conf) {
+	h := handler{
+		serviceAccountBus: cfg.ServiceAccountBus,
+		roleBus:           cfg.RoleBus,
+		tracer:            cfg.Tracer,
+	}
+
+	authenticated := mid.Authenticate(cfg.AuthConf)
+
+	api := mid.RequireScope(auth.ScopeAPI)
+
+	can := func(perms ...string) gin.HandlerFunc {
+		return mid.RequirePermission(cfg.Logger, cfg.RoleBus, perms...)
+	}
+
+	//service accounts are not tied to an org, so org scoped tokens can not manage them. Their keys
+	//are credentials, so impersonators can not either.
+	sas := cfg.Router.Group("/v1/service-accounts", authenticated, api, mid.GlobalScope(), mid.NotImpersonated())
+
+	sas.GET("/", can(roleBus.PermServiceAccountsRead), h.QueryServiceAccounts)
+	sas.GET("/:id", can(roleBus.PermServiceAccountsRead), h.QueryServiceAccountByID)
+	sas.POST("/", can(roleBus.PermServiceAccountsWrite), h.CreateServiceAccount)
+	sas.PUT("/:id", can(roleBus.PermServiceAccountsWrite), h.UpdateServiceAccount)
+	sas.DELETE("/:id", can(roleBus.PermServiceAccountsWrite), h.DeleteServiceAccount)
+	sas.GET("/:id/keys", can(roleBus.PermServiceAccountsRead), h.QueryKeys)
+	sas.POST("/:id/keys", can(roleBus.PermServiceAccountsWrite), h.CreateKey)
+	sas.DELETE("/:id/keys/:keyId", can(roleBus.PermServiceAccountsWrite), h.RevokeKey)
+}
@@ -0,0 +1,89 @@
+package serviceaccountdb
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	serviceAccountBus "github.com/hamidoujand/jumble/internal/domains/serviceaccount/bus"
+	"github.com/hamidoujand/jumble/internal/sqldb"
+)
+
+type serviceAccount struct {
+	ID          uuid.UUID       `db:"id"`
+	Name        string          `db:"name"`
+	Description string          `db:"description"`
+	Roles       sqldb.TextArray `db:"roles"`
+	Enabled     bool            `db:"enabled"`
+	CreatedBy   *uuid.UUID      `db:"created_by"`
+	CreatedAt   time.Time       `db:"created_at"`
+	UpdatedAt   time.Time       `db:"updated_at"`
+}
+
+func fromBusServiceAccount(sa serviceAccountBus.ServiceAccount) serviceAccount {
+	return serviceAccount{
+		ID:          sa.ID,
+		Name:        sa.Name,
+		Description: sa.Description,
+		Roles:       sqldb.TextArray(sa.Roles),
+		Enabled:     sa.Enabled,
+		CreatedBy:   sa.CreatedBy,
+		CreatedAt:   sa.CreatedAt,
+		UpdatedAt:   sa.UpdatedAt,
+	}
+}
+
+func toBusServiceAccount(sa serviceAccount) serviceAccountBus.ServiceAccount {
+	return serviceAccountBus.ServiceAccount{
+		ID:          sa.ID,
+		Name:        sa.Name,
+		Description: sa.Description,
+		Roles:       []string(sa.Roles),
+		Enabled:     sa.Enabled,
+		CreatedBy:   sa.CreatedBy,
+		CreatedAt:   sa.CreatedAt,
+		UpdatedAt:   sa.UpdatedAt,
+	}
+}
+
+type apiKey struct {
+	ID               uuid.UUID       `db:"id"`
+	ServiceAccountID uuid.UUID       `db:"service_account_id"`
+	Name             string          `db:"name"`
+	Prefix           string          `db:"prefix"`
+	Hash             []byte          `db:"key_hash"`
+	Scopes           sqldb.TextArray `db:"scopes"`
+	ExpiresAt        *time.Time      `db:"expires_at"`
+	LastUsedAt       *time.Time      `db:"last_used_at"`
+	RevokedAt        *time.Time      `db:"revoked_at"`
+	CreatedAt        time.Time       `db:"created_at"`
+}
+
+func fromBusAPIKey(k serviceAccountBus.APIKey) apiKey {
+	return apiKey{
+		ID:               k.ID,
+		ServiceAccountID: k.ServiceAccountID,
+		Name:             k.Name,
+		Prefix:           k.Prefix,
+		Hash:             k.Hash,
+		Scopes:           sqldb.TextArray(k.Scopes),
+		ExpiresAt:        k.ExpiresAt,
+		LastUsedAt:       k.LastUsedAt,
+		RevokedAt:        k.RevokedAt,
+		CreatedAt:        k.CreatedAt,
+	}
+}
+
+func toBusAPIKey(k apiKey) serviceAccountBus.APIKey {
+	return serviceAccountBus.APIKey{
+		ID:               k.ID,
+		ServiceAccountID: k.ServiceAccountID,
+		Name:             k.Name,
+		Prefix:           k.Prefix,
+		Hash:             k.Hash,
+		Scopes:           []string(k.Scopes),
+		ExpiresAt:        k.ExpiresAt,
+		LastUsedAt:       k.LastUsedAt,
+		RevokedAt:        k.RevokedAt,
+		CreatedAt:        k.CreatedAt,
+	}
+}
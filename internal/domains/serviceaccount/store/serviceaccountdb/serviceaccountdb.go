@@ -0,0 +1,282 @@
+package serviceaccountdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	serviceAccountBus "github.com/hamidoujand/jumble/internal/domains/serviceaccount/bus"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const uniqueViolation = "23505"
+
+type Store struct {
+	db     *sqlx.DB
+	tracer trace.Tracer
+}
+
+func NewStore(db *sqlx.DB, tracer trace.Tracer) *Store {
+	return &Store{
+		db:     db,
+		tracer: tracer,
+	}
+}
+
+func (s *Store) Create(ctx context.Context, sa serviceAccountBus.ServiceAccount) error {
+	const q = `
+	INSERT INTO service_accounts (id,name,description,roles,enabled,created_by,created_at,updated_at)
+	VALUES (:id,:name,:description,:roles,:enabled,:created_by,:created_at,:updated_at)
+	`
+
+	ctx, span := s.tracer.Start(ctx, "serviceaccount.store.create")
+	defer span.End()
+
+	if _, err := s.db.NamedExecContext(ctx, q, fromBusServiceAccount(sa)); err != nil {
+		return mapError(err)
+	}
+
+	return nil
+}
+
+func (s *Store) Update(ctx context.Context, sa serviceAccountBus.ServiceAccount) error {
+	const q = `
+	UPDATE service_accounts
+	SET
+		name = :name,
+		description = :description,
+		roles = :roles,
+		enabled = :enabled,
+		updated_at = :updated_at
+	WHERE
+		id = :id
+	`
+
+	ctx, span := s.tracer.Start(ctx, "serviceaccount.store.update")
+	defer span.End()
+
+	if _, err := s.db.NamedExecContext(ctx, q, fromBusServiceAccount(sa)); err != nil {
+		return mapError(err)
+	}
+
+	return nil
+}
+
+func (s *Store) Delete(ctx context.Context, sa serviceAccountBus.ServiceAccount) error {
+	const q = `DELETE FROM service_accounts WHERE id = :id`
+
+	ctx, span := s.tracer.Start(ctx, "serviceaccount.store.delete")
+	defer span.End()
+
+	if _, err := s.db.NamedExecContext(ctx, q, fromBusServiceAccount(sa)); err != nil {
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) QueryByID(ctx context.Context, id uuid.UUID) (serviceAccountBus.ServiceAccount, error) {
+	data := map[string]any{
+		"id": id,
+	}
+
+	const q = `SELECT * FROM service_accounts WHERE id = :id`
+
+	ctx, span := s.tracer.Start(ctx, "serviceaccount.store.queryByID")
+	defer span.End()
+
+	sas, err := s.query(ctx, q, data)
+	if err != nil {
+		return serviceAccountBus.ServiceAccount{}, err
+	}
+
+	if len(sas) == 0 {
+		return serviceAccountBus.ServiceAccount{}, serviceAccountBus.ErrServiceAccountNotFound
+	}
+
+	return sas[0], nil
+}
+
+func (s *Store) Query(ctx context.Context) ([]serviceAccountBus.ServiceAccount, error) {
+	const q = `SELECT * FROM service_accounts ORDER BY name`
+
+	ctx, span := s.tracer.Start(ctx, "serviceaccount.store.query")
+	defer span.End()
+
+	return s.query(ctx, q, map[string]any{})
+}
+
+// ==============================================================================
+
+func (s *Store) CreateKey(ctx context.Context, k serviceAccountBus.APIKey) error {
+	const q = `
+	INSERT INTO api_keys (id,service_account_id,name,prefix,key_hash,scopes,expires_at,last_used_at,revoked_at,created_at)
+	VALUES (:id,:service_account_id,:name,:prefix,:key_hash,:scopes,:expires_at,:last_used_at,:revoked_at,:created_at)
+	`
+
+	ctx, span := s.tracer.Start(ctx, "serviceaccount.store.createKey")
+	defer span.End()
+
+	if _, err := s.db.NamedExecContext(ctx, q, fromBusAPIKey(k)); err != nil {
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) RevokeKey(ctx context.Context, k serviceAccountBus.APIKey, revokedAt time.Time) error {
+	data := map[string]any{
+		"id":         k.ID,
+		"revoked_at": revokedAt,
+	}
+
+	const q = `UPDATE api_keys SET revoked_at = :revoked_at WHERE id = :id AND revoked_at IS NULL`
+
+	ctx, span := s.tracer.Start(ctx, "serviceaccount.store.revokeKey")
+	defer span.End()
+
+	if _, err := s.db.NamedExecContext(ctx, q, data); err != nil {
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) UseKey(ctx context.Context, k serviceAccountBus.APIKey, usedAt time.Time) error {
+	data := map[string]any{
+		"id":           k.ID,
+		"last_used_at": usedAt,
+	}
+
+	const q = `UPDATE api_keys SET last_used_at = :last_used_at WHERE id = :id`
+
+	ctx, span := s.tracer.Start(ctx, "serviceaccount.store.useKey")
+	defer span.End()
+
+	if _, err := s.db.NamedExecContext(ctx, q, data); err != nil {
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) QueryKeyByID(ctx context.Context, serviceAccountID uuid.UUID, id uuid.UUID) (serviceAccountBus.APIKey, error) {
+	data := map[string]any{
+		"id":                 id,
+		"service_account_id": serviceAccountID,
+	}
+
+	const q = `SELECT * FROM api_keys WHERE id = :id AND service_account_id = :service_account_id`
+
+	ctx, span := s.tracer.Start(ctx, "serviceaccount.store.queryKeyByID")
+	defer span.End()
+
+	ks, err := s.queryKeys(ctx, q, data)
+	if err != nil {
+		return serviceAccountBus.APIKey{}, err
+	}
+
+	if len(ks) == 0 {
+		return serviceAccountBus.APIKey{}, serviceAccountBus.ErrKeyNotFound
+	}
+
+	return ks[0], nil
+}
+
+func (s *Store) QueryKeyByPrefix(ctx context.Context, prefix string) (serviceAccountBus.APIKey, error) {
+	data := map[string]any{
+		"prefix": prefix,
+	}
+
+	const q = `SELECT * FROM api_keys WHERE prefix = :prefix`
+
+	ctx, span := s.tracer.Start(ctx, "serviceaccount.store.queryKeyByPrefix")
+	defer span.End()
+
+	ks, err := s.queryKeys(ctx, q, data)
+	if err != nil {
+		return serviceAccountBus.APIKey{}, err
+	}
+
+	if len(ks) == 0 {
+		return serviceAccountBus.APIKey{}, serviceAccountBus.ErrKeyNotFound
+	}
+
+	return ks[0], nil
+}
+
+func (s *Store) QueryKeys(ctx context.Context, serviceAccountID uuid.UUID) ([]serviceAccountBus.APIKey, error) {
+	data := map[string]any{
+		"service_account_id": serviceAccountID,
+	}
+
+	const q = `SELECT * FROM api_keys WHERE service_account_id = :service_account_id ORDER BY created_at DESC`
+
+	ctx, span := s.tracer.Start(ctx, "serviceaccount.store.queryKeys")
+	defer span.End()
+
+	return s.queryKeys(ctx, q, data)
+}
+
+// ==============================================================================
+
+func (s *Store) query(ctx context.Context, q string, data map[string]any) ([]serviceAccountBus.ServiceAccount, error) {
+	rows, err := s.db.NamedQueryContext(ctx, q, data)
+	if err != nil {
+		return nil, fmt.Errorf("namedQueryContext: %w", err)
+	}
+
+	defer rows.Close()
+
+	var sas []serviceAccountBus.ServiceAccount
+	for rows.Next() {
+		var sa serviceAccount
+		if err := rows.StructScan(&sa); err != nil {
+			return nil, fmt.Errorf("structScan: %w", err)
+		}
+		sas = append(sas, toBusServiceAccount(sa))
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("preparing next row to scan: %w", err)
+	}
+
+	return sas, nil
+}
+
+func (s *Store) queryKeys(ctx context.Context, q string, data map[string]any) ([]serviceAccountBus.APIKey, error) {
+	rows, err := s.db.NamedQueryContext(ctx, q, data)
+	if err != nil {
+		return nil, fmt.Errorf("namedQueryContext: %w", err)
+	}
+
+	defer rows.Close()
+
+	var ks []serviceAccountBus.APIKey
+	for rows.Next() {
+		var k apiKey
+		if err := rows.StructScan(&k); err != nil {
+			return nil, fmt.Errorf("structScan: %w", err)
+		}
+		ks = append(ks, toBusAPIKey(k))
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("preparing next row to scan: %w", err)
+	}
+
+	return ks, nil
+}
+
+func mapError(err error) error {
+	var pgerror *pgconn.PgError
+	if errors.As(err, &pgerror) && pgerror.Code == uniqueViolation {
+		return serviceAccountBus.ErrDuplicatedServiceAccount
+	}
+
+	return fmt.Errorf("namedExecContext: %w", err)
+}
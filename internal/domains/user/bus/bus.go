@@ -2,59 +2,243 @@ package bus
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"net/mail"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	auditBus "github.com/hamidoujand/jumble/internal/domains/audit/bus"
 	"github.com/hamidoujand/jumble/internal/page"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/hamidoujand/jumble/internal/password"
 )
 
 var (
 	ErrDuplicatedEmail = errors.New("email already in use")
 	ErrUserNotFound    = errors.New("user not found")
+	ErrInvalidToken    = errors.New("invalid or expired token")
+	ErrEmailVerified   = errors.New("email already verified")
+	ErrTooManyRequests = errors.New("too many requests, try again later")
+
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	ErrPasswordExpired    = errors.New("password expired, it has to be changed")
+	ErrPasswordReused     = errors.New("password was used recently, choose another one")
 )
 
 type store interface {
 	Create(ctx context.Context, usr User) error
 	Update(ctx context.Context, usr User) error
-	Delete(ctx context.Context, usr User) error
+	Delete(ctx context.Context, usr User, deletedAt time.Time) error
+	PurgeDeletedUsers(ctx context.Context, before time.Time) (int, error)
 	QueryByID(ctx context.Context, userId uuid.UUID) (User, error)
+	QueryByIDInOrg(ctx context.Context, orgID uuid.UUID, userID uuid.UUID) (User, error)
 	QueryByEmail(ctx context.Context, email mail.Address) (User, error)
 	Query(ctx context.Context, filters QueryFilter, orderBy Field, page page.Page) ([]User, error)
 	Count(ctx context.Context, filters QueryFilter) (int, error)
+	CreateToken(ctx context.Context, t Token) error
+	QueryTokenByHash(ctx context.Context, hash []byte, purpose string) (Token, error)
+	QueryLatestToken(ctx context.Context, userID uuid.UUID, purpose string) (Token, error)
+	UseToken(ctx context.Context, t Token, usedAt time.Time) error
+	DeleteTokens(ctx context.Context, userID uuid.UUID, purpose string) error
+	DeleteExpiredTokens(ctx context.Context, now time.Time) (int, error)
+	UseMFACounter(ctx context.Context, userID uuid.UUID, counter int64) error
+	ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codes []RecoveryCode) error
+	UseRecoveryCode(ctx context.Context, userID uuid.UUID, hash []byte, usedAt time.Time) error
+	CreatePasskeySession(ctx context.Context, s PasskeySession) error
+	DeletePasskeySession(ctx context.Context, challenge []byte, purpose string) (PasskeySession, error)
+	DeleteExpiredPasskeySessions(ctx context.Context, now time.Time) (int, error)
+	CreatePasskey(ctx context.Context, pk Passkey) error
+	UpdatePasskey(ctx context.Context, pk Passkey) error
+	QueryPasskeys(ctx context.Context, userID uuid.UUID) ([]Passkey, error)
+	QueryPasskeyByCredentialID(ctx context.Context, credentialID []byte) (Passkey, error)
+	QueryThrottle(ctx context.Context, key string) (Throttle, error)
+	IncrementThrottle(ctx context.Context, key string, now time.Time, windowStart time.Time) (Throttle, error)
+	LockThrottle(ctx context.Context, key string, until time.Time) error
+	DeleteThrottle(ctx context.Context, key string) error
+	DeleteStaleThrottles(ctx context.Context, before time.Time) (int, error)
+	QueryPasswordHistory(ctx context.Context, userID uuid.UUID, limit int) ([][]byte, error)
+	AddPasswordHistory(ctx context.Context, userID uuid.UUID, hash []byte, createdAt time.Time, keep int) error
+	CreateInvitation(ctx context.Context, inv Invitation) error
+	AcceptInvitation(ctx context.Context, inv Invitation, acceptedAt time.Time) error
+	DeleteInvitation(ctx context.Context, inv Invitation) error
+	QueryInvitationByID(ctx context.Context, id uuid.UUID) (Invitation, error)
+	QueryInvitationByHash(ctx context.Context, hash []byte) (Invitation, error)
+	QueryPendingInvitations(ctx context.Context, now time.Time) ([]Invitation, error)
+	DeleteExpiredInvitations(ctx context.Context, now time.Time) (int, error)
+	CreateFederatedSession(ctx context.Context, s FederatedSession) error
+	DeleteFederatedSession(ctx context.Context, stateHash []byte, provider string) (FederatedSession, error)
+	DeleteExpiredFederatedSessions(ctx context.Context, now time.Time) (int, error)
+	CreateIdentity(ctx context.Context, id Identity) error
+	UpdateIdentity(ctx context.Context, id Identity) error
+	QueryIdentity(ctx context.Context, provider string, subject string) (Identity, error)
+	CreateSession(ctx context.Context, s Session) error
+	UpdateSession(ctx context.Context, s Session) error
+	QuerySessionByID(ctx context.Context, userID uuid.UUID, id uuid.UUID) (Session, error)
+	QuerySessionByIDInOrg(ctx context.Context, orgID uuid.UUID, userID uuid.UUID, id uuid.UUID) (Session, error)
+	QueryActiveSessions(ctx context.Context, userID uuid.UUID, now time.Time) ([]Session, error)
+	RevokeSessions(ctx context.Context, userID uuid.UUID, keepID uuid.UUID, revokedAt time.Time) error
+	DeleteExpiredSessions(ctx context.Context, now time.Time) (int, error)
+}
+
+// auditor records security relevant events, the audit bus satisfies it.
+type auditor interface {
+	Create(ctx context.Context, ne auditBus.NewEntry) (auditBus.Entry, error)
+}
+
+// roleValidator makes sure roles exist before they are assigned, the role bus satisfies it.
+type roleValidator interface {
+	Validate(ctx context.Context, names []string) error
+}
+
+// departmentValidator makes sure a department exists before users join it, the department bus
+// satisfies it.
+type departmentValidator interface {
+	Validate(ctx context.Context, name string) error
 }
 
 type Bus struct {
-	store store
+	store       store
+	auditor     auditor
+	roles       roleValidator
+	departments departmentValidator
+	lockout     LockoutPolicy
+	policy      password.Policy
+	hasher      password.Hasher
+
+	//dummyHash is compared against when the email is unknown, so both cases cost one hash compare
+	//and the response time does not tell which emails have an account.
+	dummyHash func() []byte
 }
 
-func New(store store) *Bus {
-	return &Bus{store: store}
+// Option configures optional dependencies and settings of the Bus.
+type Option func(*Bus)
+
+// WithAuditor records lockouts and other security events with the given auditor.
+func WithAuditor(a auditor) Option {
+	return func(b *Bus) {
+		b.auditor = a
+	}
+}
+
+// WithRoleValidator rejects users with roles that do not exist.
+func WithRoleValidator(v roleValidator) Option {
+	return func(b *Bus) {
+		b.roles = v
+	}
+}
+
+// WithDepartmentValidator rejects users with departments that do not exist.
+func WithDepartmentValidator(v departmentValidator) Option {
+	return func(b *Bus) {
+		b.departments = v
+	}
+}
+
+// WithPasswordPolicy checks new passwords against the policy, without it any password is accepted.
+func WithPasswordPolicy(p password.Policy) Option {
+	return func(b *Bus) {
+		b.policy = p
+	}
+}
+
+// WithHasher replaces the password.DefaultArgon2id hasher, hashes made by other hashers keep
+// working and get upgraded on the next login.
+func WithHasher(h password.Hasher) Option {
+	return func(b *Bus) {
+		b.hasher = h
+	}
+}
+
+// WithLockoutPolicy replaces the DefaultLockoutPolicy.
+func WithLockoutPolicy(p LockoutPolicy) Option {
+	return func(b *Bus) {
+		b.lockout = p
+	}
+}
+
+func New(store store, opts ...Option) *Bus {
+	b := Bus{
+		store:   store,
+		lockout: DefaultLockoutPolicy,
+		hasher:  password.DefaultArgon2id,
+	}
+
+	for _, opt := range opts {
+		opt(&b)
+	}
+
+	b.dummyHash = sync.OnceValue(func() []byte {
+		hash, _ := b.hasher.Hash("dummy password")
+		return hash
+	})
+
+	return &b
 }
 
 func (b *Bus) Create(ctx context.Context, nu NewUser) (User, error) {
-	bs, err := bcrypt.GenerateFromPassword([]byte(nu.Password), bcrypt.DefaultCost)
+	if err := b.validateRoles(ctx, nu.Roles); err != nil {
+		return User{}, err
+	}
+
+	if err := b.validateDepartment(ctx, nu.Department); err != nil {
+		return User{}, err
+	}
+
+	if err := b.policy.Validate(nu.Password, nu.Name, nu.Email); err != nil {
+		return User{}, fmt.Errorf("validate: %w", err)
+	}
+
+	bs, err := b.hasher.Hash(nu.Password)
 	if err != nil {
-		return User{}, fmt.Errorf("generateFromPassword: %w", err)
+		return User{}, fmt.Errorf("hash: %w", err)
 	}
 
+	return b.create(ctx, nu, bs)
+}
+
+// CreateWithoutPassword creates an account for users managed by another system, e.g. an identity
+// provider. The password is random and thrown away, a password reset is needed to log in with one.
+func (b *Bus) CreateWithoutPassword(ctx context.Context, nu NewUser) (User, error) {
+	if err := b.validateRoles(ctx, nu.Roles); err != nil {
+		return User{}, err
+	}
+
+	if err := b.validateDepartment(ctx, nu.Department); err != nil {
+		return User{}, err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return User{}, fmt.Errorf("read: %w", err)
+	}
+
+	bs, err := b.hasher.Hash(base64.RawURLEncoding.EncodeToString(secret))
+	if err != nil {
+		return User{}, fmt.Errorf("hash: %w", err)
+	}
+
+	return b.create(ctx, nu, bs)
+}
+
+func (b *Bus) create(ctx context.Context, nu NewUser, passwordHash []byte) (User, error) {
 	//strip the monotonic clock from the time to not mess your timestamps.
 	//removes the nanoseconds and keeps the microseconds.
 	now := time.Now().Truncate(time.Microsecond)
 
 	usr := User{
-		ID:           uuid.New(),
-		Name:         nu.Name,
-		Email:        nu.Email,
-		Roles:        nu.Roles,
-		PasswordHash: bs,
-		Department:   nu.Department,
-		Enabled:      true,
-		CreatedAt:    now,
-		UpdatedAt:    now,
+		ID:                uuid.New(),
+		Name:              nu.Name,
+		Email:             nu.Email,
+		Roles:             nu.Roles,
+		PasswordHash:      passwordHash,
+		Department:        nu.Department,
+		Enabled:           true,
+		PasswordChangedAt: now,
+		CreatedAt:         now,
+		UpdatedAt:         now,
 	}
 
 	if err := b.store.Create(ctx, usr); err != nil {
@@ -65,51 +249,104 @@ func (b *Bus) Create(ctx context.Context, nu NewUser) (User, error) {
 }
 
 func (b *Bus) Update(ctx context.Context, usr User, updates UpdateUser) (User, error) {
+	now := time.Now().Truncate(time.Microsecond)
+
 	if updates.Name != nil {
 		usr.Name = *updates.Name
 	}
 
+	var emailChanged bool
 	if updates.Email != nil {
+		//a new address needs to be verified again.
+		if usr.Email.Address != updates.Email.Address {
+			usr.EmailVerifiedAt = nil
+			emailChanged = true
+		}
 		usr.Email = *updates.Email
 	}
 
 	if updates.Department != nil {
+		if err := b.validateDepartment(ctx, *updates.Department); err != nil {
+			return User{}, err
+		}
 		usr.Department = *updates.Department
 	}
 
 	if updates.Roles != nil {
+		if err := b.validateRoles(ctx, updates.Roles); err != nil {
+			return User{}, err
+		}
 		usr.Roles = updates.Roles
 	}
 
+	var oldHash []byte
 	if updates.Password != nil {
-		bs, err := bcrypt.GenerateFromPassword([]byte(*updates.Password), bcrypt.DefaultCost)
+		if err := b.policy.Validate(*updates.Password, usr.Name, usr.Email); err != nil {
+			return User{}, fmt.Errorf("validate: %w", err)
+		}
+
+		if err := b.checkHistory(ctx, usr, *updates.Password); err != nil {
+			return User{}, err
+		}
+
+		bs, err := b.hasher.Hash(*updates.Password)
 		if err != nil {
-			return User{}, fmt.Errorf("generateFromPassword: %w", err)
+			return User{}, fmt.Errorf("hash: %w", err)
 		}
 
+		oldHash = usr.PasswordHash
 		usr.PasswordHash = bs
+		//tokens issued before this moment are no longer accepted.
+		usr.PasswordChangedAt = now
 	}
 
 	if updates.Enabled != nil {
 		usr.Enabled = *updates.Enabled
 	}
 
-	usr.UpdatedAt = time.Now()
+	usr.UpdatedAt = now
 	if err := b.store.Update(ctx, usr); err != nil {
 		return User{}, fmt.Errorf("update: %w", err)
 	}
 
+	//the current password counts as one of the last N, history keeps the ones before it.
+	if oldHash != nil && b.policy.History > 1 {
+		if err := b.store.AddPasswordHistory(ctx, usr.ID, oldHash, now, b.policy.History-1); err != nil {
+			return User{}, fmt.Errorf("addPasswordHistory: %w", err)
+		}
+	}
+
+	//verification links sent to the old address must not verify the new one.
+	if emailChanged {
+		if err := b.store.DeleteTokens(ctx, usr.ID, PurposeEmailVerification); err != nil {
+			return User{}, fmt.Errorf("deleteTokens: %w", err)
+		}
+	}
+
 	return usr, nil
 }
 
+// Delete deletes the user, they are gone for every query right away but their data is only
+// removed once PurgeDeletedUsers runs.
 func (b *Bus) Delete(ctx context.Context, usr User) error {
-	if err := b.store.Delete(ctx, usr); err != nil {
+	if err := b.store.Delete(ctx, usr, time.Now().Truncate(time.Microsecond)); err != nil {
 		return fmt.Errorf("delete: %w", err)
 	}
 
 	return nil
 }
 
+// PurgeDeletedUsers removes users deleted longer than retention ago for good, it returns how many
+// were removed.
+func (b *Bus) PurgeDeletedUsers(ctx context.Context, retention time.Duration) (int, error) {
+	n, err := b.store.PurgeDeletedUsers(ctx, time.Now().Add(-retention))
+	if err != nil {
+		return 0, fmt.Errorf("purgeDeletedUsers: %w", err)
+	}
+
+	return n, nil
+}
+
 func (b *Bus) QueryByID(ctx context.Context, id uuid.UUID) (User, error) {
 	usr, err := b.store.QueryByID(ctx, id)
 	if err != nil {
@@ -119,6 +356,16 @@ func (b *Bus) QueryByID(ctx context.Context, id uuid.UUID) (User, error) {
 	return usr, nil
 }
 
+// QueryByIDInOrg is QueryByID for org scoped requests, users outside the org are not found.
+func (b *Bus) QueryByIDInOrg(ctx context.Context, orgID uuid.UUID, id uuid.UUID) (User, error) {
+	usr, err := b.store.QueryByIDInOrg(ctx, orgID, id)
+	if err != nil {
+		return User{}, fmt.Errorf("queryByIDInOrg: %w", err)
+	}
+
+	return usr, nil
+}
+
 func (b *Bus) QueryByEmail(ctx context.Context, email mail.Address) (User, error) {
 	usr, err := b.store.QueryByEmail(ctx, email)
 	if err != nil {
@@ -141,15 +388,124 @@ func (b *Bus) Query(ctx context.Context, filters QueryFilter, order Field, page
 	return usrs, nil
 }
 
+// Authenticate checks the credentials, every failure is reported as ErrInvalidCredentials.
+// Passwords older than the max age of the policy return ErrPasswordExpired, they can only be
+// used to ChangePassword. Hashes made by an older algorithm or with weaker parameters than the
+// current hasher are replaced on the way.
 func (b *Bus) Authenticate(ctx context.Context, email mail.Address, password string) (User, error) {
+	usr, err := b.checkPassword(ctx, email, password)
+	if err != nil {
+		return User{}, err
+	}
+
+	//the plain password is only around at login, so this is the one chance to upgrade the hash.
+	if b.hasher.NeedsRehash(usr.PasswordHash) {
+		bs, err := b.hasher.Hash(password)
+		if err != nil {
+			return User{}, fmt.Errorf("hash: %w", err)
+		}
+
+		//the password itself did not change, so PasswordChangedAt stays as is.
+		usr.PasswordHash = bs
+		if err := b.store.Update(ctx, usr); err != nil {
+			return User{}, fmt.Errorf("update: %w", err)
+		}
+	}
+
+	if b.policy.Expired(usr.PasswordChangedAt, time.Now()) {
+		return User{}, ErrPasswordExpired
+	}
+
+	return usr, nil
+}
+
+// ChangePassword sets a new password for a user that knows the current one, expired or not. Every
+// session of the user but keepSession is revoked, uuid.Nil keeps none.
+func (b *Bus) ChangePassword(ctx context.Context, email mail.Address, current string, newPassword string, keepSession uuid.UUID) (User, error) {
+	usr, err := b.checkPassword(ctx, email, current)
+	if err != nil {
+		return User{}, err
+	}
+
+	updated, err := b.Update(ctx, usr, UpdateUser{Password: &newPassword})
+	if err != nil {
+		return User{}, fmt.Errorf("update: %w", err)
+	}
+
+	if err := b.store.RevokeSessions(ctx, usr.ID, keepSession, updated.PasswordChangedAt); err != nil {
+		return User{}, fmt.Errorf("revokeSessions: %w", err)
+	}
+
+	return updated, nil
+}
+
+// ==============================================================================
+
+func (b *Bus) checkPassword(ctx context.Context, email mail.Address, pass string) (User, error) {
 	usr, err := b.store.QueryByEmail(ctx, email)
+	if errors.Is(err, ErrUserNotFound) {
+		password.Verify(b.dummyHash(), pass)
+		return User{}, ErrInvalidCredentials
+	}
+
 	if err != nil {
 		return User{}, fmt.Errorf("queryByEmail: %w", err)
 	}
 
-	if err := bcrypt.CompareHashAndPassword(usr.PasswordHash, []byte(password)); err != nil {
-		return User{}, fmt.Errorf("compareHashAndPass: %w", err)
+	if err := password.Verify(usr.PasswordHash, pass); err != nil {
+		if errors.Is(err, password.ErrMismatch) {
+			return User{}, ErrInvalidCredentials
+		}
+		return User{}, fmt.Errorf("verify: %w", err)
 	}
 
 	return usr, nil
 }
+
+func (b *Bus) validateRoles(ctx context.Context, roles []Role) error {
+	if b.roles == nil || len(roles) == 0 {
+		return nil
+	}
+
+	if err := b.roles.Validate(ctx, RolesToString(roles)); err != nil {
+		return fmt.Errorf("validateRoles: %w", err)
+	}
+
+	return nil
+}
+
+func (b *Bus) validateDepartment(ctx context.Context, department string) error {
+	if b.departments == nil || department == "" {
+		return nil
+	}
+
+	if err := b.departments.Validate(ctx, department); err != nil {
+		return fmt.Errorf("validateDepartment: %w", err)
+	}
+
+	return nil
+}
+
+// checkHistory rejects the current password and the previous ones the policy remembers.
+func (b *Bus) checkHistory(ctx context.Context, usr User, pass string) error {
+	if b.policy.History <= 0 {
+		return nil
+	}
+
+	hashes := [][]byte{usr.PasswordHash}
+	if b.policy.History > 1 {
+		previous, err := b.store.QueryPasswordHistory(ctx, usr.ID, b.policy.History-1)
+		if err != nil {
+			return fmt.Errorf("queryPasswordHistory: %w", err)
+		}
+		hashes = append(hashes, previous...)
+	}
+
+	for _, hash := range hashes {
+		if password.Verify(hash, pass) == nil {
+			return ErrPasswordReused
+		}
+	}
+
+	return nil
+}
@@ -6,18 +6,26 @@ import (
 	"log"
 	"net/mail"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/uuid"
 	"github.com/hamidoujand/jumble/internal/dbtest"
+	auditBus "github.com/hamidoujand/jumble/internal/domains/audit/bus"
+	orgBus "github.com/hamidoujand/jumble/internal/domains/org/bus"
+	"github.com/hamidoujand/jumble/internal/domains/org/store/orgdb"
+	roleBus "github.com/hamidoujand/jumble/internal/domains/role/bus"
 	"github.com/hamidoujand/jumble/internal/domains/user/bus"
 	"github.com/hamidoujand/jumble/internal/domains/user/store/userdb"
 	"github.com/hamidoujand/jumble/internal/page"
+	"github.com/hamidoujand/jumble/internal/password"
 	"github.com/hamidoujand/jumble/pkg/docker"
 	"github.com/hamidoujand/jumble/pkg/telemetry"
+	"github.com/hamidoujand/jumble/pkg/totp"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/trace"
-	"golang.org/x/crypto/bcrypt"
 )
 
 var container docker.Container
@@ -67,7 +75,7 @@ func Test_CreateUser(t *testing.T) {
 			Address: "john@gmail.com",
 		},
 		Roles:      []bus.Role{bus.RoleUser},
-		Department: "Sales",
+		Department: "sales",
 		Password:   "test1234",
 	}
 
@@ -77,7 +85,7 @@ func Test_CreateUser(t *testing.T) {
 	}
 
 	//check password
-	if err := bcrypt.CompareHashAndPassword(usr.PasswordHash, []byte(nu.Password)); err != nil {
+	if err := password.Verify(usr.PasswordHash, nu.Password); err != nil {
 		t.Fatalf("passwords did not match")
 	}
 }
@@ -97,7 +105,7 @@ func Test_GetByID(t *testing.T) {
 			Address: "john@gmail.com",
 		},
 		Roles:      []bus.Role{bus.RoleUser},
-		Department: "Sales",
+		Department: "sales",
 		Password:   "test1234",
 	}
 
@@ -135,7 +143,7 @@ func Test_UpdateUser(t *testing.T) {
 			Address: "john@gmail.com",
 		},
 		Roles:      []bus.Role{bus.RoleUser},
-		Department: "Sales",
+		Department: "sales",
 		Password:   "test1234",
 	}
 
@@ -180,7 +188,7 @@ func Test_UpdateUser(t *testing.T) {
 		t.Errorf("email=%s, got=%s", email.Address, updated.Email.Address)
 	}
 
-	if err := bcrypt.CompareHashAndPassword(updated.PasswordHash, []byte(pass)); err != nil {
+	if err := password.Verify(updated.PasswordHash, pass); err != nil {
 		t.Errorf("password does not match: %s", err)
 	}
 
@@ -204,7 +212,7 @@ func Test_DeleteUser(t *testing.T) {
 			Address: "john@gmail.com",
 		},
 		Roles:      []bus.Role{bus.RoleUser},
-		Department: "Sales",
+		Department: "sales",
 		Password:   "test1234",
 	}
 
@@ -225,6 +233,29 @@ func Test_DeleteUser(t *testing.T) {
 	if !errors.Is(err, bus.ErrUserNotFound) {
 		t.Errorf("err=%s, got=%s", bus.ErrUserNotFound, err)
 	}
+
+	//the email is free again while the deleted user waits for the purge.
+	if _, err := b.Create(context.Background(), nu); err != nil {
+		t.Fatalf("failed to create a user with the email of a deleted one: %s", err)
+	}
+
+	n, err := b.PurgeDeletedUsers(context.Background(), time.Hour)
+	if err != nil {
+		t.Fatalf("failed to purge deleted users: %s", err)
+	}
+
+	if n != 0 {
+		t.Errorf("expected users deleted within the retention to be kept, got=%d", n)
+	}
+
+	n, err = b.PurgeDeletedUsers(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("failed to purge deleted users: %s", err)
+	}
+
+	if n != 1 {
+		t.Errorf("expected the deleted user to be purged, got=%d", n)
+	}
 }
 
 func Test_QueryByEmail(t *testing.T) {
@@ -242,7 +273,7 @@ func Test_QueryByEmail(t *testing.T) {
 			Address: "john@gmail.com",
 		},
 		Roles:      []bus.Role{bus.RoleUser},
-		Department: "Sales",
+		Department: "sales",
 		Password:   "test1234",
 	}
 
@@ -274,7 +305,7 @@ func Test_Query(t *testing.T) {
 
 	//query by name and department
 	name := "John Doe"
-	department := "Sales"
+	department := "sales"
 
 	f := bus.QueryFilter{
 		Name:       &name,
@@ -353,7 +384,7 @@ func querySetup(t *testing.T, b *bus.Bus) {
 				Address: "john@doe.com",
 			},
 			Roles:      []bus.Role{bus.RoleUser},
-			Department: "Sales",
+			Department: "sales",
 			Password:   "test123",
 		},
 		{
@@ -363,7 +394,7 @@ func querySetup(t *testing.T, b *bus.Bus) {
 				Address: "Jane@doe.com",
 			},
 			Roles:      []bus.Role{bus.RoleUser},
-			Department: "Shipping",
+			Department: "shipping",
 			Password:   "test123",
 		},
 		{
@@ -373,7 +404,7 @@ func querySetup(t *testing.T, b *bus.Bus) {
 				Address: "mike@doe.com",
 			},
 			Roles:      []bus.Role{bus.RoleAdmin},
-			Department: "Sales",
+			Department: "sales",
 			Password:   "test123",
 		},
 		{
@@ -383,7 +414,7 @@ func querySetup(t *testing.T, b *bus.Bus) {
 				Address: "tom@doe.com",
 			},
 			Roles:      []bus.Role{bus.RoleAdmin},
-			Department: "Shipping",
+			Department: "shipping",
 			Password:   "test123",
 		},
 	}
@@ -396,3 +427,1023 @@ func querySetup(t *testing.T, b *bus.Bus) {
 	}
 
 }
+
+func Test_ResetPassword(t *testing.T) {
+	t.Parallel()
+
+	db := dbtest.New(t, container, "reset_password")
+	store := userdb.NewStore(db, tracer)
+
+	b := bus.New(store)
+
+	nu := bus.NewUser{
+		Name: "John Doe",
+		Email: mail.Address{
+			Name:    "John Doe",
+			Address: "john@gmail.com",
+		},
+		Roles:      []bus.Role{bus.RoleUser},
+		Department: "sales",
+		Password:   "test1234",
+	}
+
+	usr, err := b.Create(context.Background(), nu)
+	if err != nil {
+		t.Fatalf("failed to create a user: %s", err)
+	}
+
+	_, _, err = b.CreatePasswordReset(context.Background(), mail.Address{Address: "jane@gmail.com"}, time.Minute)
+	if !errors.Is(err, bus.ErrUserNotFound) {
+		t.Fatalf("err=%s, got=%v", bus.ErrUserNotFound, err)
+	}
+
+	_, token, err := b.CreatePasswordReset(context.Background(), usr.Email, time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create password reset: %s", err)
+	}
+
+	if _, err := b.CreateSession(context.Background(), usr, "Firefox", "127.0.0.1", time.Hour); err != nil {
+		t.Fatalf("failed to create a session: %s", err)
+	}
+
+	pass := "test54321"
+	updated, err := b.ResetPassword(context.Background(), token, pass)
+	if err != nil {
+		t.Fatalf("failed to reset password: %s", err)
+	}
+
+	ss, err := b.QuerySessions(context.Background(), usr)
+	if err != nil {
+		t.Fatalf("failed to query sessions: %s", err)
+	}
+
+	if len(ss) != 0 {
+		t.Errorf("expected the reset to revoke every session, got=%+v", ss)
+	}
+
+	if err := password.Verify(updated.PasswordHash, pass); err != nil {
+		t.Errorf("password does not match: %s", err)
+	}
+
+	if !updated.PasswordChangedAt.After(usr.PasswordChangedAt) {
+		t.Errorf("passwordChangedAt should move forward")
+	}
+
+	//tokens are single-use.
+	_, err = b.ResetPassword(context.Background(), token, "another1234")
+	if !errors.Is(err, bus.ErrInvalidToken) {
+		t.Errorf("err=%s, got=%v", bus.ErrInvalidToken, err)
+	}
+
+	//expired tokens are rejected.
+	_, token, err = b.CreatePasswordReset(context.Background(), usr.Email, -time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create password reset: %s", err)
+	}
+
+	_, err = b.ResetPassword(context.Background(), token, "another1234")
+	if !errors.Is(err, bus.ErrInvalidToken) {
+		t.Errorf("err=%s, got=%v", bus.ErrInvalidToken, err)
+	}
+}
+
+func Test_VerifyEmail(t *testing.T) {
+	t.Parallel()
+
+	db := dbtest.New(t, container, "verify_email")
+	store := userdb.NewStore(db, tracer)
+
+	b := bus.New(store)
+
+	nu := bus.NewUser{
+		Name: "John Doe",
+		Email: mail.Address{
+			Name:    "John Doe",
+			Address: "john@gmail.com",
+		},
+		Roles:      []bus.Role{bus.RoleUser},
+		Department: "sales",
+		Password:   "test1234",
+	}
+
+	usr, err := b.Create(context.Background(), nu)
+	if err != nil {
+		t.Fatalf("failed to create a user: %s", err)
+	}
+
+	if usr.EmailVerifiedAt != nil {
+		t.Fatal("new users should not be verified")
+	}
+
+	token, err := b.CreateEmailVerification(context.Background(), usr, time.Minute, time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create email verification: %s", err)
+	}
+
+	//resend is rate limited.
+	_, err = b.CreateEmailVerification(context.Background(), usr, time.Minute, time.Minute)
+	if !errors.Is(err, bus.ErrTooManyRequests) {
+		t.Errorf("err=%s, got=%v", bus.ErrTooManyRequests, err)
+	}
+
+	verified, err := b.VerifyEmail(context.Background(), token)
+	if err != nil {
+		t.Fatalf("failed to verify email: %s", err)
+	}
+
+	if verified.EmailVerifiedAt == nil {
+		t.Fatal("expected email to be verified")
+	}
+
+	_, err = b.CreateEmailVerification(context.Background(), verified, time.Minute, time.Minute)
+	if !errors.Is(err, bus.ErrEmailVerified) {
+		t.Errorf("err=%s, got=%v", bus.ErrEmailVerified, err)
+	}
+
+	//changing the address needs a new verification.
+	email := mail.Address{Name: "John Doe", Address: "john@doe.com"}
+	updated, err := b.Update(context.Background(), verified, bus.UpdateUser{Email: &email})
+	if err != nil {
+		t.Fatalf("update failed: %s", err)
+	}
+
+	if updated.EmailVerifiedAt != nil {
+		t.Error("expected email verification to be reset")
+	}
+}
+
+func Test_MFA(t *testing.T) {
+	t.Parallel()
+
+	db := dbtest.New(t, container, "mfa")
+	store := userdb.NewStore(db, tracer)
+
+	b := bus.New(store)
+
+	nu := bus.NewUser{
+		Name: "John Doe",
+		Email: mail.Address{
+			Name:    "John Doe",
+			Address: "john@gmail.com",
+		},
+		Roles:      []bus.Role{bus.RoleUser},
+		Department: "sales",
+		Password:   "test1234",
+	}
+
+	usr, err := b.Create(context.Background(), nu)
+	if err != nil {
+		t.Fatalf("failed to create a user: %s", err)
+	}
+
+	enrolled, err := b.EnrollMFA(context.Background(), usr)
+	if err != nil {
+		t.Fatalf("failed to enroll mfa: %s", err)
+	}
+
+	if enrolled.MFASecret == "" || enrolled.MFAEnabledAt != nil {
+		t.Fatal("expected a pending mfa secret")
+	}
+
+	code, err := totp.Code(enrolled.MFASecret, totp.Counter(time.Now()))
+	if err != nil {
+		t.Fatalf("failed to generate code: %s", err)
+	}
+
+	enabled, codes, err := b.ConfirmMFA(context.Background(), enrolled, code)
+	if err != nil {
+		t.Fatalf("failed to confirm mfa: %s", err)
+	}
+
+	if enabled.MFAEnabledAt == nil {
+		t.Fatal("expected mfa to be enabled")
+	}
+
+	if len(codes) != 10 {
+		t.Fatalf("recoveryCodes=%d, got=%d", 10, len(codes))
+	}
+
+	//the code used to confirm can not be replayed.
+	err = b.VerifyMFA(context.Background(), enabled, code)
+	if !errors.Is(err, bus.ErrInvalidMFACode) {
+		t.Errorf("err=%s, got=%v", bus.ErrInvalidMFACode, err)
+	}
+
+	if err := b.UseRecoveryCode(context.Background(), enabled, codes[0]); err != nil {
+		t.Fatalf("failed to use recovery code: %s", err)
+	}
+
+	err = b.UseRecoveryCode(context.Background(), enabled, codes[0])
+	if !errors.Is(err, bus.ErrInvalidMFACode) {
+		t.Errorf("err=%s, got=%v", bus.ErrInvalidMFACode, err)
+	}
+}
+
+func Test_Passkey(t *testing.T) {
+	t.Parallel()
+
+	db := dbtest.New(t, container, "passkey")
+	store := userdb.NewStore(db, tracer)
+
+	b := bus.New(store)
+
+	nu := bus.NewUser{
+		Name: "John Doe",
+		Email: mail.Address{
+			Name:    "John Doe",
+			Address: "john@gmail.com",
+		},
+		Roles:      []bus.Role{bus.RoleUser},
+		Department: "sales",
+		Password:   "test1234",
+	}
+
+	usr, err := b.Create(context.Background(), nu)
+	if err != nil {
+		t.Fatalf("failed to create a user: %s", err)
+	}
+
+	challenge, err := b.CreatePasskeySession(context.Background(), usr.ID, bus.PurposePasskeyRegistration, time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create passkey session: %s", err)
+	}
+
+	//challenges are bound to their ceremony.
+	_, err = b.ConsumePasskeySession(context.Background(), challenge, bus.PurposePasskeyLogin)
+	if !errors.Is(err, bus.ErrInvalidToken) {
+		t.Errorf("err=%s, got=%v", bus.ErrInvalidToken, err)
+	}
+
+	session, err := b.ConsumePasskeySession(context.Background(), challenge, bus.PurposePasskeyRegistration)
+	if err != nil {
+		t.Fatalf("failed to consume passkey session: %s", err)
+	}
+
+	if session.UserID != usr.ID {
+		t.Errorf("userID=%s, got=%s", usr.ID, session.UserID)
+	}
+
+	_, err = b.ConsumePasskeySession(context.Background(), challenge, bus.PurposePasskeyRegistration)
+	if !errors.Is(err, bus.ErrInvalidToken) {
+		t.Errorf("err=%s, got=%v", bus.ErrInvalidToken, err)
+	}
+
+	np := bus.NewPasskey{
+		CredentialID: []byte("credential-id"),
+		PublicKey:    []byte("public-key"),
+		Name:         "laptop",
+	}
+
+	pk, err := b.CreatePasskey(context.Background(), usr, np)
+	if err != nil {
+		t.Fatalf("failed to create passkey: %s", err)
+	}
+
+	_, err = b.CreatePasskey(context.Background(), usr, np)
+	if !errors.Is(err, bus.ErrDuplicatedPasskey) {
+		t.Errorf("err=%s, got=%v", bus.ErrDuplicatedPasskey, err)
+	}
+
+	if _, err := b.UsePasskey(context.Background(), pk, 5); err != nil {
+		t.Fatalf("failed to use passkey: %s", err)
+	}
+
+	got, err := b.QueryPasskeyByCredentialID(context.Background(), np.CredentialID)
+	if err != nil {
+		t.Fatalf("failed to query passkey: %s", err)
+	}
+
+	if got.SignCount != 5 || got.LastUsedAt == nil {
+		t.Errorf("expected sign count and last used to be updated: %+v", got)
+	}
+}
+
+func Test_Lockout(t *testing.T) {
+	t.Parallel()
+
+	db := dbtest.New(t, container, "lockout")
+	store := userdb.NewStore(db, tracer)
+
+	var audit auditRecorder
+	policy := bus.LockoutPolicy{
+		MaxFailures:   3,
+		MaxIPFailures: 10,
+		Window:        time.Minute,
+		Lockout:       time.Minute,
+	}
+
+	b := bus.New(store, bus.WithLockoutPolicy(policy), bus.WithAuditor(&audit))
+
+	nu := bus.NewUser{
+		Name: "John Doe",
+		Email: mail.Address{
+			Name:    "John Doe",
+			Address: "john@gmail.com",
+		},
+		Roles:      []bus.Role{bus.RoleUser},
+		Department: "sales",
+		Password:   "test1234",
+	}
+
+	usr, err := b.Create(context.Background(), nu)
+	if err != nil {
+		t.Fatalf("failed to create a user: %s", err)
+	}
+
+	//unknown emails and wrong passwords look the same.
+	_, err = b.Authenticate(context.Background(), mail.Address{Address: "jane@gmail.com"}, "test1234")
+	if !errors.Is(err, bus.ErrInvalidCredentials) {
+		t.Errorf("err=%s, got=%v", bus.ErrInvalidCredentials, err)
+	}
+
+	_, err = b.Authenticate(context.Background(), usr.Email, "wrong-password")
+	if !errors.Is(err, bus.ErrInvalidCredentials) {
+		t.Errorf("err=%s, got=%v", bus.ErrInvalidCredentials, err)
+	}
+
+	const ip = "10.0.0.1"
+	for range policy.MaxFailures {
+		if _, err := b.CheckLogin(context.Background(), usr.Email, ip); err != nil {
+			t.Fatalf("expected login to be allowed: %s", err)
+		}
+
+		if err := b.LoginFailed(context.Background(), usr.Email, ip); err != nil {
+			t.Fatalf("failed to record login failure: %s", err)
+		}
+	}
+
+	wait, err := b.CheckLogin(context.Background(), usr.Email, ip)
+	if !errors.Is(err, bus.ErrLoginLocked) {
+		t.Fatalf("err=%s, got=%v", bus.ErrLoginLocked, err)
+	}
+
+	if wait <= 0 || wait > policy.Lockout {
+		t.Errorf("expected wait to be within the lockout, got=%s", wait)
+	}
+
+	if len(audit.entries) != 1 || audit.entries[0].Action != auditBus.ActionLoginLocked {
+		t.Errorf("expected a lockout audit entry, got=%+v", audit.entries)
+	}
+
+	if err := b.Unlock(context.Background(), uuid.New(), usr); err != nil {
+		t.Fatalf("failed to unlock: %s", err)
+	}
+
+	if _, err := b.CheckLogin(context.Background(), usr.Email, ip); err != nil {
+		t.Errorf("expected login to be allowed after unlock: %s", err)
+	}
+}
+
+func Test_PasswordPolicy(t *testing.T) {
+	t.Parallel()
+
+	db := dbtest.New(t, container, "password_policy")
+	store := userdb.NewStore(db, tracer)
+
+	policy := password.Policy{
+		MinLength:    8,
+		RequireDigit: true,
+		History:      3,
+	}
+
+	b := bus.New(store, bus.WithPasswordPolicy(policy))
+
+	nu := bus.NewUser{
+		Name: "John Doe",
+		Email: mail.Address{
+			Name:    "John Doe",
+			Address: "john@gmail.com",
+		},
+		Roles:      []bus.Role{bus.RoleUser},
+		Department: "sales",
+		Password:   "johnpassword1",
+	}
+
+	_, err := b.Create(context.Background(), nu)
+	if !errors.Is(err, password.ErrWeakPassword) {
+		t.Fatalf("err=%s, got=%v", password.ErrWeakPassword, err)
+	}
+
+	nu.Password = "test1234"
+	usr, err := b.Create(context.Background(), nu)
+	if err != nil {
+		t.Fatalf("failed to create a user: %s", err)
+	}
+
+	current := nu.Password
+	for _, pass := range []string{"test2345", "test3456"} {
+		usr, err = b.ChangePassword(context.Background(), usr.Email, current, pass, uuid.Nil)
+		if err != nil {
+			t.Fatalf("failed to change password to %q: %s", pass, err)
+		}
+		current = pass
+	}
+
+	//the last 3 passwords, including the current one, can not be reused.
+	for _, pass := range []string{"test1234", "test2345", "test3456"} {
+		_, err := b.Update(context.Background(), usr, bus.UpdateUser{Password: &pass})
+		if !errors.Is(err, bus.ErrPasswordReused) {
+			t.Errorf("password %q: err=%s, got=%v", pass, bus.ErrPasswordReused, err)
+		}
+	}
+
+	pass := "test4567"
+	if _, err := b.Update(context.Background(), usr, bus.UpdateUser{Password: &pass}); err != nil {
+		t.Errorf("expected a new password to be accepted: %s", err)
+	}
+
+	_, err = b.ChangePassword(context.Background(), usr.Email, "wrong-password", "test5678", uuid.Nil)
+	if !errors.Is(err, bus.ErrInvalidCredentials) {
+		t.Errorf("err=%s, got=%v", bus.ErrInvalidCredentials, err)
+	}
+}
+
+func Test_Rehash(t *testing.T) {
+	t.Parallel()
+
+	db := dbtest.New(t, container, "rehash")
+	store := userdb.NewStore(db, tracer)
+
+	//users created before the switch to argon2id have bcrypt hashes.
+	legacy := bus.New(store, bus.WithHasher(password.Bcrypt{Cost: 4}))
+
+	nu := bus.NewUser{
+		Name: "John Doe",
+		Email: mail.Address{
+			Name:    "John Doe",
+			Address: "john@gmail.com",
+		},
+		Roles:      []bus.Role{bus.RoleUser},
+		Department: "sales",
+		Password:   "test1234",
+	}
+
+	usr, err := legacy.Create(context.Background(), nu)
+	if err != nil {
+		t.Fatalf("failed to create a user: %s", err)
+	}
+
+	argon := password.Argon2id{Time: 1, Memory: 1024, Threads: 1, SaltLength: 16, KeyLength: 32}
+	b := bus.New(store, bus.WithHasher(argon))
+
+	if _, err := b.Authenticate(context.Background(), usr.Email, nu.Password); err != nil {
+		t.Fatalf("failed to authenticate with a bcrypt hash: %s", err)
+	}
+
+	upgraded, err := b.QueryByID(context.Background(), usr.ID)
+	if err != nil {
+		t.Fatalf("failed to query user: %s", err)
+	}
+
+	if argon.NeedsRehash(upgraded.PasswordHash) {
+		t.Errorf("expected the hash to be upgraded to argon2id, got=%s", upgraded.PasswordHash)
+	}
+
+	if !upgraded.PasswordChangedAt.Equal(usr.PasswordChangedAt) {
+		t.Errorf("passwordChangedAt=%s, got=%s", usr.PasswordChangedAt, upgraded.PasswordChangedAt)
+	}
+
+	//a stronger configuration upgrades argon2id hashes as well.
+	stronger := argon
+	stronger.Time = 2
+	b = bus.New(store, bus.WithHasher(stronger))
+
+	if _, err := b.Authenticate(context.Background(), usr.Email, nu.Password); err != nil {
+		t.Fatalf("failed to authenticate: %s", err)
+	}
+
+	upgraded, err = b.QueryByID(context.Background(), usr.ID)
+	if err != nil {
+		t.Fatalf("failed to query user: %s", err)
+	}
+
+	if stronger.NeedsRehash(upgraded.PasswordHash) {
+		t.Errorf("expected the hash to be upgraded to the new params, got=%s", upgraded.PasswordHash)
+	}
+}
+
+func Test_Invitations(t *testing.T) {
+	t.Parallel()
+
+	db := dbtest.New(t, container, "invitations")
+	store := userdb.NewStore(db, tracer)
+
+	recorder := auditRecorder{}
+	b := bus.New(store, bus.WithAuditor(&recorder))
+
+	admin, err := b.Create(context.Background(), bus.NewUser{
+		Name:       "John Doe",
+		Email:      mail.Address{Name: "John Doe", Address: "john@gmail.com"},
+		Roles:      []bus.Role{bus.RoleAdmin},
+		Department: "sales",
+		Password:   "test1234",
+	})
+	if err != nil {
+		t.Fatalf("failed to create a user: %s", err)
+	}
+
+	ni := bus.NewInvitation{
+		Email:      mail.Address{Address: "jane@gmail.com"},
+		Roles:      []bus.Role{bus.RoleUser, bus.RoleAdmin},
+		Department: "shipping",
+		InvitedBy:  admin.ID,
+		TTL:        time.Hour,
+	}
+
+	//accounts that already exist can not be invited.
+	_, _, err = b.CreateInvitation(context.Background(), bus.NewInvitation{Email: admin.Email, TTL: time.Hour})
+	if !errors.Is(err, bus.ErrDuplicatedEmail) {
+		t.Fatalf("err=%s, got=%v", bus.ErrDuplicatedEmail, err)
+	}
+
+	inv, token, err := b.CreateInvitation(context.Background(), ni)
+	if err != nil {
+		t.Fatalf("failed to create invitation: %s", err)
+	}
+
+	pending, err := b.QueryPendingInvitations(context.Background())
+	if err != nil {
+		t.Fatalf("failed to query pending invitations: %s", err)
+	}
+
+	if len(pending) != 1 || pending[0].ID != inv.ID {
+		t.Fatalf("expected the invitation to be pending, got=%v", pending)
+	}
+
+	if diff := cmp.Diff(ni.Roles, pending[0].Roles, cmp.AllowUnexported(bus.Role{})); diff != "" {
+		t.Errorf("roles mismatch:\n%s", diff)
+	}
+
+	_, err = b.AcceptInvitation(context.Background(), "unknown", "Jane Doe", "test1234")
+	if !errors.Is(err, bus.ErrInvalidToken) {
+		t.Errorf("err=%s, got=%v", bus.ErrInvalidToken, err)
+	}
+
+	usr, err := b.AcceptInvitation(context.Background(), token, "Jane Doe", "test1234")
+	if err != nil {
+		t.Fatalf("failed to accept invitation: %s", err)
+	}
+
+	if usr.Email.Address != ni.Email.Address || usr.Department != ni.Department {
+		t.Errorf("expected the account to follow the invitation, got=%+v", usr)
+	}
+
+	if diff := cmp.Diff(ni.Roles, usr.Roles, cmp.AllowUnexported(bus.Role{})); diff != "" {
+		t.Errorf("roles mismatch:\n%s", diff)
+	}
+
+	if usr.EmailVerifiedAt == nil {
+		t.Error("expected the email to be verified")
+	}
+
+	//invitations are single-use.
+	_, err = b.AcceptInvitation(context.Background(), token, "Jane Doe", "test1234")
+	if !errors.Is(err, bus.ErrInvalidToken) {
+		t.Errorf("err=%s, got=%v", bus.ErrInvalidToken, err)
+	}
+
+	//expired invitations are rejected and purged.
+	ni.Email = mail.Address{Address: "bob@gmail.com"}
+	ni.TTL = -time.Minute
+	expired, token, err := b.CreateInvitation(context.Background(), ni)
+	if err != nil {
+		t.Fatalf("failed to create invitation: %s", err)
+	}
+
+	_, err = b.AcceptInvitation(context.Background(), token, "Bob Doe", "test1234")
+	if !errors.Is(err, bus.ErrInvalidToken) {
+		t.Errorf("err=%s, got=%v", bus.ErrInvalidToken, err)
+	}
+
+	n, err := b.DeleteExpiredInvitations(context.Background())
+	if err != nil {
+		t.Fatalf("failed to delete expired invitations: %s", err)
+	}
+
+	if n != 1 {
+		t.Errorf("expected 1 expired invitation to be deleted, got=%d", n)
+	}
+
+	_, err = b.QueryInvitationByID(context.Background(), expired.ID)
+	if !errors.Is(err, bus.ErrInvitationNotFound) {
+		t.Errorf("err=%s, got=%v", bus.ErrInvitationNotFound, err)
+	}
+
+	//revoked invitations can not be accepted.
+	ni.TTL = time.Hour
+	revoked, token, err := b.CreateInvitation(context.Background(), ni)
+	if err != nil {
+		t.Fatalf("failed to create invitation: %s", err)
+	}
+
+	if err := b.RevokeInvitation(context.Background(), admin.ID, revoked); err != nil {
+		t.Fatalf("failed to revoke invitation: %s", err)
+	}
+
+	_, err = b.AcceptInvitation(context.Background(), token, "Bob Doe", "test1234")
+	if !errors.Is(err, bus.ErrInvalidToken) {
+		t.Errorf("err=%s, got=%v", bus.ErrInvalidToken, err)
+	}
+
+	actions := make([]string, len(recorder.entries))
+	for i, e := range recorder.entries {
+		actions[i] = e.Action
+	}
+
+	expected := []string{
+		auditBus.ActionUserInvited,
+		auditBus.ActionInvitationAccepted,
+		auditBus.ActionUserInvited,
+		auditBus.ActionUserInvited,
+		auditBus.ActionInvitationRevoked,
+	}
+
+	if diff := cmp.Diff(expected, actions); diff != "" {
+		t.Errorf("audit mismatch:\n%s", diff)
+	}
+}
+
+func Test_FederatedLogin(t *testing.T) {
+	t.Parallel()
+
+	db := dbtest.New(t, container, "federated_login")
+	store := userdb.NewStore(db, tracer)
+
+	var audit auditRecorder
+	b := bus.New(store, bus.WithAuditor(&audit))
+
+	state, fs, err := b.CreateFederatedSession(context.Background(), "corp", time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create federated session: %s", err)
+	}
+
+	//states are bound to their provider.
+	if _, err := b.ConsumeFederatedSession(context.Background(), state, "other"); !errors.Is(err, bus.ErrInvalidToken) {
+		t.Errorf("err=%s, got=%v", bus.ErrInvalidToken, err)
+	}
+
+	got, err := b.ConsumeFederatedSession(context.Background(), state, "corp")
+	if err != nil {
+		t.Fatalf("failed to consume federated session: %s", err)
+	}
+
+	if got.Nonce != fs.Nonce || got.CodeVerifier != fs.CodeVerifier {
+		t.Errorf("session=%+v, got=%+v", fs, got)
+	}
+
+	if _, err := b.ConsumeFederatedSession(context.Background(), state, "corp"); !errors.Is(err, bus.ErrInvalidToken) {
+		t.Errorf("err=%s, got=%v", bus.ErrInvalidToken, err)
+	}
+
+	john, err := b.Create(context.Background(), bus.NewUser{
+		Name:       "John Doe",
+		Email:      mail.Address{Name: "John Doe", Address: "john@gmail.com"},
+		Roles:      []bus.Role{bus.RoleUser},
+		Department: "sales",
+		Password:   "test1234",
+	})
+	if err != nil {
+		t.Fatalf("failed to create a user: %s", err)
+	}
+
+	policy := bus.ProvisionPolicy{Enabled: true, Roles: []bus.Role{bus.RoleUser}}
+
+	eu := bus.ExternalUser{
+		Provider: "corp",
+		Subject:  "1001",
+		Name:     "John Doe",
+		Email:    mail.Address{Address: "john@gmail.com"},
+	}
+
+	if _, err := b.FederatedLogin(context.Background(), eu, policy); !errors.Is(err, bus.ErrUnverifiedEmail) {
+		t.Errorf("err=%s, got=%v", bus.ErrUnverifiedEmail, err)
+	}
+
+	//a verified email links the identity to the existing account.
+	eu.EmailVerified = true
+	usr, err := b.FederatedLogin(context.Background(), eu, policy)
+	if err != nil {
+		t.Fatalf("failed to log in: %s", err)
+	}
+
+	if usr.ID != john.ID || usr.EmailVerifiedAt == nil {
+		t.Errorf("expected the verified account of john, got=%+v", usr)
+	}
+
+	//once linked, the subject is what counts.
+	eu.Email = mail.Address{Address: "john.doe@corp.example"}
+	eu.EmailVerified = false
+	if usr, err := b.FederatedLogin(context.Background(), eu, policy); err != nil || usr.ID != john.ID {
+		t.Errorf("expected john, got=%v, err=%v", usr.ID, err)
+	}
+
+	admin, err := b.Create(context.Background(), bus.NewUser{
+		Name:       "Admin Doe",
+		Email:      mail.Address{Name: "Admin Doe", Address: "admin@gmail.com"},
+		Roles:      []bus.Role{bus.RoleAdmin},
+		Department: "sales",
+		Password:   "test1234",
+	})
+	if err != nil {
+		t.Fatalf("failed to create a user: %s", err)
+	}
+
+	//elevated accounts are not handed to whoever holds their address at the provider.
+	if _, err := b.FederatedLogin(context.Background(), bus.ExternalUser{
+		Provider:      "corp",
+		Subject:       "1003",
+		Email:         admin.Email,
+		EmailVerified: true,
+	}, policy); !errors.Is(err, bus.ErrLinkingDenied) {
+		t.Errorf("err=%s, got=%v", bus.ErrLinkingDenied, err)
+	}
+
+	jane := bus.ExternalUser{
+		Provider:      "corp",
+		Subject:       "1002",
+		Name:          "Jane Doe",
+		Email:         mail.Address{Address: "jane@corp.example"},
+		EmailVerified: true,
+	}
+
+	if _, err := b.FederatedLogin(context.Background(), jane, bus.ProvisionPolicy{}); !errors.Is(err, bus.ErrProvisioningDenied) {
+		t.Errorf("err=%s, got=%v", bus.ErrProvisioningDenied, err)
+	}
+
+	usr, err = b.FederatedLogin(context.Background(), jane, policy)
+	if err != nil {
+		t.Fatalf("failed to provision a user: %s", err)
+	}
+
+	if usr.Name != "Jane Doe" || usr.EmailVerifiedAt == nil || len(usr.Roles) != 1 || usr.Roles[0] != bus.RoleUser {
+		t.Errorf("unexpected provisioned user %+v", usr)
+	}
+
+	actions := make([]string, len(audit.entries))
+	for i, e := range audit.entries {
+		actions[i] = e.Action
+	}
+
+	expected := []string{auditBus.ActionIdentityLinked, auditBus.ActionUserProvisioned}
+	if diff := cmp.Diff(expected, actions); diff != "" {
+		t.Errorf("audit mismatch:\n%s", diff)
+	}
+}
+
+func Test_Impersonation(t *testing.T) {
+	t.Parallel()
+
+	db := dbtest.New(t, container, "impersonation")
+	store := userdb.NewStore(db, tracer)
+
+	recorder := auditRecorder{}
+	b := bus.New(store, bus.WithAuditor(&recorder))
+
+	newUser := func(email string, role bus.Role) bus.User {
+		usr, err := b.Create(context.Background(), bus.NewUser{
+			Name:       "John Doe",
+			Email:      mail.Address{Name: "John Doe", Address: email},
+			Roles:      []bus.Role{role},
+			Department: "sales",
+			Password:   "test1234",
+		})
+		if err != nil {
+			t.Fatalf("failed to create a user: %s", err)
+		}
+		return usr
+	}
+
+	admin := newUser("admin@gmail.com", bus.RoleAdmin)
+	other := newUser("other@gmail.com", bus.RoleAdmin)
+	usr := newUser("john@gmail.com", bus.RoleUser)
+
+	all := make(roleBus.PermissionSet)
+	for _, perm := range roleBus.Permissions() {
+		all[perm] = struct{}{}
+	}
+
+	none := make(roleBus.PermissionSet)
+	support := roleBus.PermissionSet{roleBus.PermUsersImpersonate: {}, roleBus.PermUsersRead: {}}
+	billing := roleBus.PermissionSet{roleBus.PermUsersRead: {}, roleBus.PermOAuthClientsWrite: {}}
+
+	if err := b.Impersonate(context.Background(), admin, all, admin, all, "127.0.0.1"); !errors.Is(err, bus.ErrImpersonateSelf) {
+		t.Errorf("err=%s, got=%v", bus.ErrImpersonateSelf, err)
+	}
+
+	if err := b.Impersonate(context.Background(), admin, all, other, all, "127.0.0.1"); !errors.Is(err, bus.ErrImpersonateAdmin) {
+		t.Errorf("err=%s, got=%v", bus.ErrImpersonateAdmin, err)
+	}
+
+	//support staff with a custom role can not act as someone holding permissions they lack.
+	if err := b.Impersonate(context.Background(), other, support, usr, billing, "127.0.0.1"); !errors.Is(err, bus.ErrImpersonateGreater) {
+		t.Errorf("err=%s, got=%v", bus.ErrImpersonateGreater, err)
+	}
+
+	if err := b.Impersonate(context.Background(), admin, all, usr, none, "127.0.0.1"); err != nil {
+		t.Fatalf("failed to impersonate: %s", err)
+	}
+
+	if err := b.RecordImpersonatedRequest(context.Background(), admin.ID, usr, "GET", "/v1/users/"+usr.ID.String(), "127.0.0.1"); err != nil {
+		t.Fatalf("failed to record request: %s", err)
+	}
+
+	enabled := false
+	disabled, err := b.Update(context.Background(), usr, bus.UpdateUser{Enabled: &enabled})
+	if err != nil {
+		t.Fatalf("failed to disable user: %s", err)
+	}
+
+	if err := b.Impersonate(context.Background(), admin, all, disabled, none, "127.0.0.1"); !errors.Is(err, bus.ErrImpersonateDisabled) {
+		t.Errorf("err=%s, got=%v", bus.ErrImpersonateDisabled, err)
+	}
+
+	if len(recorder.entries) != 2 {
+		t.Fatalf("expected 2 audit entries, got=%d", len(recorder.entries))
+	}
+
+	for i, action := range []string{auditBus.ActionImpersonationStarted, auditBus.ActionImpersonatedRequest} {
+		e := recorder.entries[i]
+		if e.Action != action || e.ActorID != admin.ID || e.Target != "user:"+usr.ID.String() {
+			t.Errorf("expected %s by the admin on the user, got=%+v", action, e)
+		}
+	}
+
+	if recorder.entries[1].Details["path"] != "/v1/users/"+usr.ID.String() {
+		t.Errorf("expected the path to be recorded, got=%v", recorder.entries[1].Details)
+	}
+}
+
+func Test_Sessions(t *testing.T) {
+	t.Parallel()
+
+	db := dbtest.New(t, container, "sessions")
+	store := userdb.NewStore(db, tracer)
+
+	recorder := auditRecorder{}
+	b := bus.New(store, bus.WithAuditor(&recorder))
+
+	usr, err := b.Create(context.Background(), bus.NewUser{
+		Name:       "John Doe",
+		Email:      mail.Address{Name: "John Doe", Address: "john@gmail.com"},
+		Roles:      []bus.Role{bus.RoleUser},
+		Department: "sales",
+		Password:   "test1234",
+	})
+	if err != nil {
+		t.Fatalf("failed to create a user: %s", err)
+	}
+
+	laptop, err := b.CreateSession(context.Background(), usr, "Firefox", "127.0.0.1", time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create a session: %s", err)
+	}
+
+	phone, err := b.CreateSession(context.Background(), usr, strings.Repeat("a", 600), "127.0.0.2", time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create a session: %s", err)
+	}
+
+	if len(phone.UserAgent) != 512 {
+		t.Errorf("expected the user agent to be cut to 512 bytes, got=%d", len(phone.UserAgent))
+	}
+
+	if _, err := b.CreateSession(context.Background(), usr, "Chrome", "127.0.0.3", -time.Minute); err != nil {
+		t.Fatalf("failed to create a session: %s", err)
+	}
+
+	ss, err := b.QuerySessions(context.Background(), usr)
+	if err != nil {
+		t.Fatalf("failed to query sessions: %s", err)
+	}
+
+	if len(ss) != 2 {
+		t.Fatalf("expected the 2 sessions that did not expire, got=%d", len(ss))
+	}
+
+	if _, err := b.QuerySessionByID(context.Background(), uuid.New(), laptop.ID); !errors.Is(err, bus.ErrSessionNotFound) {
+		t.Errorf("err=%s, got=%v", bus.ErrSessionNotFound, err)
+	}
+
+	if err := b.RevokeSession(context.Background(), laptop, usr.ID, "127.0.0.2"); err != nil {
+		t.Fatalf("failed to revoke session: %s", err)
+	}
+
+	revoked, err := b.QuerySessionByID(context.Background(), usr.ID, laptop.ID)
+	if err != nil {
+		t.Fatalf("failed to query session: %s", err)
+	}
+
+	if err := revoked.Check(time.Now()); !errors.Is(err, bus.ErrSessionRevoked) {
+		t.Errorf("err=%s, got=%v", bus.ErrSessionRevoked, err)
+	}
+
+	if err := phone.Check(time.Now()); err != nil {
+		t.Errorf("expected the other session to still be usable, got=%s", err)
+	}
+
+	ss, err = b.QuerySessions(context.Background(), usr)
+	if err != nil {
+		t.Fatalf("failed to query sessions: %s", err)
+	}
+
+	if len(ss) != 1 || ss[0].ID != phone.ID {
+		t.Errorf("expected only the phone session to be left, got=%+v", ss)
+	}
+
+	if len(recorder.entries) != 1 || recorder.entries[0].Action != auditBus.ActionSessionRevoked {
+		t.Errorf("expected the revocation to be audited, got=%+v", recorder.entries)
+	}
+
+	n, err := b.DeleteExpiredSessions(context.Background())
+	if err != nil {
+		t.Fatalf("failed to delete expired sessions: %s", err)
+	}
+
+	if n != 2 {
+		t.Errorf("expected the revoked and the expired session to be deleted, got=%d", n)
+	}
+
+	if _, err := b.CreateSession(context.Background(), usr, "Safari", "127.0.0.4", time.Hour); err != nil {
+		t.Fatalf("failed to create a session: %s", err)
+	}
+
+	//changing the password ends every other session.
+	if _, err := b.ChangePassword(context.Background(), usr.Email, "test1234", "test5678", phone.ID); err != nil {
+		t.Fatalf("failed to change password: %s", err)
+	}
+
+	ss, err = b.QuerySessions(context.Background(), usr)
+	if err != nil {
+		t.Fatalf("failed to query sessions: %s", err)
+	}
+
+	if len(ss) != 1 || ss[0].ID != phone.ID {
+		t.Errorf("expected only the session that changed the password to be left, got=%+v", ss)
+	}
+}
+
+type auditRecorder struct {
+	entries []auditBus.NewEntry
+}
+
+func (a *auditRecorder) Create(ctx context.Context, ne auditBus.NewEntry) (auditBus.Entry, error) {
+	a.entries = append(a.entries, ne)
+	return auditBus.Entry{Action: ne.Action}, nil
+}
+
+func Test_QueryInOrg(t *testing.T) {
+	t.Parallel()
+
+	db := dbtest.New(t, container, "query_in_org")
+	b := bus.New(userdb.NewStore(db, tracer))
+	orgs := orgBus.New(orgdb.NewStore(db, tracer))
+
+	create := func(email string) bus.User {
+		usr, err := b.Create(context.Background(), bus.NewUser{
+			Name:       "John Doe",
+			Email:      mail.Address{Name: "John Doe", Address: email},
+			Roles:      []bus.Role{bus.RoleUser},
+			Department: "sales",
+			Password:   "test1234",
+		})
+		if err != nil {
+			t.Fatalf("failed to create a user: %s", err)
+		}
+
+		return usr
+	}
+
+	member := create("john@gmail.com")
+	outsider := create("jane@gmail.com")
+
+	o, err := orgs.Create(context.Background(), member.ID, orgBus.NewOrg{Name: "Acme", Slug: "acme"})
+	if err != nil {
+		t.Fatalf("failed to create an org: %s", err)
+	}
+
+	if _, err := b.QueryByIDInOrg(context.Background(), o.ID, member.ID); err != nil {
+		t.Errorf("failed to query a member: %s", err)
+	}
+
+	if _, err := b.QueryByIDInOrg(context.Background(), o.ID, outsider.ID); !errors.Is(err, bus.ErrUserNotFound) {
+		t.Errorf("err=%s, got=%v", bus.ErrUserNotFound, err)
+	}
+
+	memberSession, err := b.CreateSession(context.Background(), member, "Firefox", "127.0.0.1", time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create a session: %s", err)
+	}
+
+	outsiderSession, err := b.CreateSession(context.Background(), outsider, "Firefox", "127.0.0.1", time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create a session: %s", err)
+	}
+
+	if _, err := b.QuerySessionByIDInOrg(context.Background(), o.ID, member.ID, memberSession.ID); err != nil {
+		t.Errorf("failed to query the session of a member: %s", err)
+	}
+
+	if _, err := b.QuerySessionByIDInOrg(context.Background(), o.ID, outsider.ID, outsiderSession.ID); !errors.Is(err, bus.ErrSessionNotFound) {
+		t.Errorf("err=%s, got=%v", bus.ErrSessionNotFound, err)
+	}
+}
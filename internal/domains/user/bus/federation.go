@@ -0,0 +1,217 @@
+package bus
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/mail"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	auditBus "github.com/hamidoujand/jumble/internal/domains/audit/bus"
+)
+
+var (
+	ErrIdentityNotFound   = errors.New("identity not found")
+	ErrUnverifiedEmail    = errors.New("email is not verified by the identity provider")
+	ErrProvisioningDenied = errors.New("no account exists for this identity")
+	ErrLinkingDenied      = errors.New("accounts with elevated roles are not linked to identities by email")
+)
+
+// Identity links an account at an external identity provider to a user.
+type Identity struct {
+	Provider    string
+	Subject     string
+	UserID      uuid.UUID
+	Email       string
+	LastLoginAt *time.Time
+	CreatedAt   time.Time
+}
+
+// ExternalUser is what a provider asserts about the user logging in.
+type ExternalUser struct {
+	Provider      string
+	Subject       string
+	Name          string
+	Email         mail.Address
+	EmailVerified bool
+}
+
+// ProvisionPolicy decides what happens to users logging in through a provider for the first time
+// without an account, Roles and Department are given to the account created for them.
+type ProvisionPolicy struct {
+	Enabled    bool
+	Roles      []Role
+	Department string
+}
+
+// FederatedSession holds the secrets of a login at an external provider until it comes back to
+// the callback, it is looked up by the hash of the state.
+type FederatedSession struct {
+	StateHash    []byte
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+	ExpiresAt    time.Time
+	CreatedAt    time.Time
+}
+
+// CreateFederatedSession starts a login at the provider and returns the plain state along with
+// the session.
+func (b *Bus) CreateFederatedSession(ctx context.Context, provider string, ttl time.Duration) (string, FederatedSession, error) {
+	var secrets [3]string
+	for i := range secrets {
+		bs := make([]byte, 32)
+		if _, err := rand.Read(bs); err != nil {
+			return "", FederatedSession{}, fmt.Errorf("read: %w", err)
+		}
+		secrets[i] = base64.RawURLEncoding.EncodeToString(bs)
+	}
+
+	now := time.Now().Truncate(time.Microsecond)
+
+	s := FederatedSession{
+		StateHash:    hashToken(secrets[0]),
+		Provider:     provider,
+		Nonce:        secrets[1],
+		CodeVerifier: secrets[2],
+		ExpiresAt:    now.Add(ttl),
+		CreatedAt:    now,
+	}
+
+	if err := b.store.CreateFederatedSession(ctx, s); err != nil {
+		return "", FederatedSession{}, fmt.Errorf("createFederatedSession: %w", err)
+	}
+
+	return secrets[0], s, nil
+}
+
+// ConsumeFederatedSession ends the login with the given state, each state can be used once.
+func (b *Bus) ConsumeFederatedSession(ctx context.Context, state string, provider string) (FederatedSession, error) {
+	s, err := b.store.DeleteFederatedSession(ctx, hashToken(state), provider)
+	if err != nil {
+		return FederatedSession{}, fmt.Errorf("deleteFederatedSession: %w", err)
+	}
+
+	if time.Now().After(s.ExpiresAt) {
+		return FederatedSession{}, ErrInvalidToken
+	}
+
+	return s, nil
+}
+
+// DeleteExpiredFederatedSessions removes logins that never came back, it returns how many were
+// removed.
+func (b *Bus) DeleteExpiredFederatedSessions(ctx context.Context) (int, error) {
+	n, err := b.store.DeleteExpiredFederatedSessions(ctx, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("deleteExpiredFederatedSessions: %w", err)
+	}
+
+	return n, nil
+}
+
+// FederatedLogin returns the user behind an external identity. An identity seen for the first time
+// is linked to the account with the same email, or to a new account when the policy allows it,
+// both only when the provider verified the email. Accounts holding more than the user role are
+// never linked by email, whoever controls the address at the provider would get their access.
+func (b *Bus) FederatedLogin(ctx context.Context, eu ExternalUser, policy ProvisionPolicy) (User, error) {
+	now := time.Now().Truncate(time.Microsecond)
+
+	id, err := b.store.QueryIdentity(ctx, eu.Provider, eu.Subject)
+	switch {
+	case err == nil:
+		usr, err := b.store.QueryByID(ctx, id.UserID)
+		if err != nil {
+			return User{}, fmt.Errorf("queryByID: %w", err)
+		}
+
+		id.LastLoginAt = &now
+		if err := b.store.UpdateIdentity(ctx, id); err != nil {
+			return User{}, fmt.Errorf("updateIdentity: %w", err)
+		}
+
+		return usr, nil
+
+	case !errors.Is(err, ErrIdentityNotFound):
+		return User{}, fmt.Errorf("queryIdentity: %w", err)
+	}
+
+	//anyone can claim any address at some providers, only a verified one ties the identity to an
+	//account.
+	if !eu.EmailVerified {
+		return User{}, ErrUnverifiedEmail
+	}
+
+	action := auditBus.ActionIdentityLinked
+
+	usr, err := b.store.QueryByEmail(ctx, eu.Email)
+	switch {
+	case errors.Is(err, ErrUserNotFound):
+		if !policy.Enabled {
+			return User{}, ErrProvisioningDenied
+		}
+
+		name := eu.Name
+		if name == "" {
+			name = eu.Email.Address
+		}
+
+		usr, err = b.CreateWithoutPassword(ctx, NewUser{
+			Name:       name,
+			Email:      mail.Address{Name: name, Address: eu.Email.Address},
+			Roles:      policy.Roles,
+			Department: policy.Department,
+		})
+		if err != nil {
+			return User{}, fmt.Errorf("createWithoutPassword: %w", err)
+		}
+
+		action = auditBus.ActionUserProvisioned
+
+	case err != nil:
+		return User{}, fmt.Errorf("queryByEmail: %w", err)
+
+	case slices.ContainsFunc(usr.Roles, func(r Role) bool { return r != RoleUser }):
+		return User{}, ErrLinkingDenied
+	}
+
+	//the provider just proved the user owns the address.
+	if usr.EmailVerifiedAt == nil {
+		usr.EmailVerifiedAt = &now
+		if err := b.store.Update(ctx, usr); err != nil {
+			return User{}, fmt.Errorf("update: %w", err)
+		}
+	}
+
+	id = Identity{
+		Provider:    eu.Provider,
+		Subject:     eu.Subject,
+		UserID:      usr.ID,
+		Email:       eu.Email.Address,
+		LastLoginAt: &now,
+		CreatedAt:   now,
+	}
+
+	if err := b.store.CreateIdentity(ctx, id); err != nil {
+		return User{}, fmt.Errorf("createIdentity: %w", err)
+	}
+
+	err = b.audit(ctx, auditBus.NewEntry{
+		ActorID: usr.ID,
+		Action:  action,
+		Target:  "user:" + usr.ID.String(),
+		Details: map[string]string{
+			"provider": eu.Provider,
+			"subject":  eu.Subject,
+		},
+	})
+	if err != nil {
+		return User{}, fmt.Errorf("audit: %w", err)
+	}
+
+	return usr, nil
+}
@@ -2,6 +2,8 @@ package bus
 
 import (
 	"time"
+
+	"github.com/google/uuid"
 )
 
 type QueryFilter struct {
@@ -10,4 +12,10 @@ type QueryFilter struct {
 	Roles          []Role
 	StartCreatedAt *time.Time
 	EndCreatedAt   *time.Time
+
+	//OrgID limits the result to members of the org.
+	OrgID *uuid.UUID
+
+	//Groups limits the result to members of any of the groups or of their subgroups.
+	Groups []uuid.UUID
 }
@@ -0,0 +1,73 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/google/uuid"
+	auditBus "github.com/hamidoujand/jumble/internal/domains/audit/bus"
+	roleBus "github.com/hamidoujand/jumble/internal/domains/role/bus"
+)
+
+var (
+	ErrImpersonateSelf     = errors.New("users can not impersonate themselves")
+	ErrImpersonateAdmin    = errors.New("admins can not be impersonated")
+	ErrImpersonateDisabled = errors.New("disabled users can not be impersonated")
+	ErrImpersonateGreater  = errors.New("users with permissions the actor lacks can not be impersonated")
+)
+
+// Impersonate checks that the actor may act as the target and records that they started to.
+// actorPerms and targetPerms are what each of them is granted through roles and groups, the target
+// can not hold a permission the actor lacks so support staff can not borrow more access than they
+// have. Admins are never impersonated, not even by other admins.
+func (b *Bus) Impersonate(ctx context.Context, actor User, actorPerms roleBus.PermissionSet, target User, targetPerms roleBus.PermissionSet, ip string) error {
+	if actor.ID == target.ID {
+		return ErrImpersonateSelf
+	}
+
+	if !target.Enabled {
+		return ErrImpersonateDisabled
+	}
+
+	if slices.Contains(target.Roles, RoleAdmin) {
+		return ErrImpersonateAdmin
+	}
+
+	if !actorPerms.Covers(targetPerms) {
+		return ErrImpersonateGreater
+	}
+
+	err := b.audit(ctx, auditBus.NewEntry{
+		ActorID: actor.ID,
+		Action:  auditBus.ActionImpersonationStarted,
+		Target:  "user:" + target.ID.String(),
+		IP:      ip,
+	})
+	if err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+
+	return nil
+}
+
+// RecordImpersonatedRequest records a request the actor made as the user, so everything done
+// while impersonating can be traced back to both of them.
+func (b *Bus) RecordImpersonatedRequest(ctx context.Context, actorID uuid.UUID, usr User, method string, path string, ip string) error {
+	err := b.audit(ctx, auditBus.NewEntry{
+		ActorID: actorID,
+		Action:  auditBus.ActionImpersonatedRequest,
+		Target:  "user:" + usr.ID.String(),
+		IP:      ip,
+		Details: map[string]string{
+			"method": method,
+			"path":   path,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+
+	return nil
+}
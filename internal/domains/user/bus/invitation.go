@@ -0,0 +1,202 @@
+package bus
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	auditBus "github.com/hamidoujand/jumble/internal/domains/audit/bus"
+)
+
+var ErrInvitationNotFound = errors.New("invitation not found")
+
+// Invitation lets the owner of Email create an account with the roles and department picked by
+// the admin that invited them, only the hash of the token sent to them is stored.
+type Invitation struct {
+	ID         uuid.UUID
+	Email      mail.Address
+	Roles      []Role
+	Department string
+	Hash       []byte
+	InvitedBy  uuid.UUID
+	ExpiresAt  time.Time
+	AcceptedAt *time.Time
+	CreatedAt  time.Time
+}
+
+type NewInvitation struct {
+	Email      mail.Address
+	Roles      []Role
+	Department string
+	InvitedBy  uuid.UUID
+	TTL        time.Duration
+}
+
+// CreateInvitation invites the email to sign up, the plain token is returned only once so it can
+// be sent to the invitee.
+func (b *Bus) CreateInvitation(ctx context.Context, ni NewInvitation) (Invitation, string, error) {
+	if err := b.validateRoles(ctx, ni.Roles); err != nil {
+		return Invitation{}, "", err
+	}
+
+	if err := b.validateDepartment(ctx, ni.Department); err != nil {
+		return Invitation{}, "", err
+	}
+
+	_, err := b.store.QueryByEmail(ctx, ni.Email)
+	switch {
+	case err == nil:
+		return Invitation{}, "", ErrDuplicatedEmail
+	case !errors.Is(err, ErrUserNotFound):
+		return Invitation{}, "", fmt.Errorf("queryByEmail: %w", err)
+	}
+
+	bs := make([]byte, 32)
+	if _, err := rand.Read(bs); err != nil {
+		return Invitation{}, "", fmt.Errorf("read: %w", err)
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(bs)
+	now := time.Now().Truncate(time.Microsecond)
+
+	inv := Invitation{
+		ID:         uuid.New(),
+		Email:      ni.Email,
+		Roles:      ni.Roles,
+		Department: ni.Department,
+		Hash:       hashToken(token),
+		InvitedBy:  ni.InvitedBy,
+		ExpiresAt:  now.Add(ni.TTL),
+		CreatedAt:  now,
+	}
+
+	if err := b.store.CreateInvitation(ctx, inv); err != nil {
+		return Invitation{}, "", fmt.Errorf("createInvitation: %w", err)
+	}
+
+	err = b.audit(ctx, auditBus.NewEntry{
+		ActorID: ni.InvitedBy,
+		Action:  auditBus.ActionUserInvited,
+		Target:  "invitation:" + inv.ID.String(),
+		Details: map[string]string{
+			"email": inv.Email.Address,
+			"roles": strings.Join(RolesToString(inv.Roles), ","),
+		},
+	})
+	if err != nil {
+		return Invitation{}, "", fmt.Errorf("audit: %w", err)
+	}
+
+	return inv, token, nil
+}
+
+// AcceptInvitation creates the account of the invitee with the roles and department of the
+// invitation. The email is marked as verified, the token could only be read from that inbox.
+func (b *Bus) AcceptInvitation(ctx context.Context, token string, name string, password string) (User, error) {
+	inv, err := b.store.QueryInvitationByHash(ctx, hashToken(token))
+	if errors.Is(err, ErrInvitationNotFound) {
+		return User{}, ErrInvalidToken
+	}
+
+	if err != nil {
+		return User{}, fmt.Errorf("queryInvitationByHash: %w", err)
+	}
+
+	now := time.Now().Truncate(time.Microsecond)
+	if inv.AcceptedAt != nil || now.After(inv.ExpiresAt) {
+		return User{}, ErrInvalidToken
+	}
+
+	//the account goes first so a weak password does not burn the invitation, two concurrent
+	//accepts can not both get here since the email is unique.
+	usr, err := b.Create(ctx, NewUser{
+		Name:       name,
+		Email:      inv.Email,
+		Roles:      inv.Roles,
+		Department: inv.Department,
+		Password:   password,
+	})
+	if err != nil {
+		return User{}, fmt.Errorf("create: %w", err)
+	}
+
+	if err := b.store.AcceptInvitation(ctx, inv, now); err != nil {
+		return User{}, fmt.Errorf("acceptInvitation: %w", err)
+	}
+
+	usr.EmailVerifiedAt = &now
+	if err := b.store.Update(ctx, usr); err != nil {
+		return User{}, fmt.Errorf("update: %w", err)
+	}
+
+	err = b.audit(ctx, auditBus.NewEntry{
+		ActorID: usr.ID,
+		Action:  auditBus.ActionInvitationAccepted,
+		Target:  "user:" + usr.ID.String(),
+		Details: map[string]string{
+			"invitation": inv.ID.String(),
+		},
+	})
+	if err != nil {
+		return User{}, fmt.Errorf("audit: %w", err)
+	}
+
+	return usr, nil
+}
+
+// RevokeInvitation deletes the invitation so its token can no longer be accepted.
+func (b *Bus) RevokeInvitation(ctx context.Context, actorID uuid.UUID, inv Invitation) error {
+	if err := b.store.DeleteInvitation(ctx, inv); err != nil {
+		return fmt.Errorf("deleteInvitation: %w", err)
+	}
+
+	err := b.audit(ctx, auditBus.NewEntry{
+		ActorID: actorID,
+		Action:  auditBus.ActionInvitationRevoked,
+		Target:  "invitation:" + inv.ID.String(),
+		Details: map[string]string{
+			"email": inv.Email.Address,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+
+	return nil
+}
+
+func (b *Bus) QueryInvitationByID(ctx context.Context, id uuid.UUID) (Invitation, error) {
+	inv, err := b.store.QueryInvitationByID(ctx, id)
+	if err != nil {
+		return Invitation{}, fmt.Errorf("queryInvitationByID: %w", err)
+	}
+
+	return inv, nil
+}
+
+// QueryPendingInvitations returns the invitations that are neither accepted nor expired.
+func (b *Bus) QueryPendingInvitations(ctx context.Context) ([]Invitation, error) {
+	invs, err := b.store.QueryPendingInvitations(ctx, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("queryPendingInvitations: %w", err)
+	}
+
+	return invs, nil
+}
+
+// DeleteExpiredInvitations removes the invitations that expired without being accepted, the
+// accepted ones stay as a record of who invited whom.
+func (b *Bus) DeleteExpiredInvitations(ctx context.Context) (int, error) {
+	n, err := b.store.DeleteExpiredInvitations(ctx, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("deleteExpiredInvitations: %w", err)
+	}
+
+	return n, nil
+}
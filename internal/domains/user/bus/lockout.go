@@ -0,0 +1,205 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	auditBus "github.com/hamidoujand/jumble/internal/domains/audit/bus"
+)
+
+var (
+	ErrLoginLocked      = errors.New("too many failed login attempts, try again later")
+	ErrThrottleNotFound = errors.New("throttle not found")
+)
+
+// LockoutPolicy controls how failed logins are throttled, counters are kept per email and per ip.
+type LockoutPolicy struct {
+	//MaxFailures locks the account after that many failures within Window.
+	MaxFailures int
+
+	//MaxIPFailures locks the ip after that many failures within Window, across all accounts.
+	MaxIPFailures int
+
+	//Window is how long a failure counts, it starts over after Window without failures.
+	Window time.Duration
+
+	//Lockout is how long a locked account or ip has to wait.
+	Lockout time.Duration
+
+	//BaseDelay is the wait after the first failure, it doubles with each one up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+var DefaultLockoutPolicy = LockoutPolicy{
+	MaxFailures:   5,
+	MaxIPFailures: 50,
+	Window:        15 * time.Minute,
+	Lockout:       15 * time.Minute,
+	BaseDelay:     time.Second,
+	MaxDelay:      30 * time.Second,
+}
+
+// Throttle is the failed attempts counter of a single key.
+type Throttle struct {
+	Key          string
+	Failures     int
+	LastFailedAt time.Time
+	LockedUntil  *time.Time
+}
+
+// CheckLogin tells if a login for the email from the ip is allowed right now, when it is not
+// ErrLoginLocked is returned along with how long the client has to wait.
+func (b *Bus) CheckLogin(ctx context.Context, email mail.Address, ip string) (time.Duration, error) {
+	now := time.Now()
+
+	var wait time.Duration
+	for _, key := range []string{emailKey(email), ipKey(ip)} {
+		t, err := b.store.QueryThrottle(ctx, key)
+		if errors.Is(err, ErrThrottleNotFound) {
+			continue
+		}
+
+		if err != nil {
+			return 0, fmt.Errorf("queryThrottle: %w", err)
+		}
+
+		wait = max(wait, b.lockout.wait(t, now))
+	}
+
+	if wait > 0 {
+		return wait, ErrLoginLocked
+	}
+
+	return 0, nil
+}
+
+// LoginFailed counts a failed attempt against the email and the ip, locking them once they reach
+// the limit of the policy.
+func (b *Bus) LoginFailed(ctx context.Context, email mail.Address, ip string) error {
+	now := time.Now().Truncate(time.Microsecond)
+
+	keys := []struct {
+		key   string
+		limit int
+	}{
+		{key: emailKey(email), limit: b.lockout.MaxFailures},
+		{key: ipKey(ip), limit: b.lockout.MaxIPFailures},
+	}
+
+	for _, k := range keys {
+		t, err := b.store.IncrementThrottle(ctx, k.key, now, now.Add(-b.lockout.Window))
+		if err != nil {
+			return fmt.Errorf("incrementThrottle: %w", err)
+		}
+
+		if t.Failures < k.limit || (t.LockedUntil != nil && t.LockedUntil.After(now)) {
+			continue
+		}
+
+		until := now.Add(b.lockout.Lockout)
+		if err := b.store.LockThrottle(ctx, k.key, until); err != nil {
+			return fmt.Errorf("lockThrottle: %w", err)
+		}
+
+		err = b.audit(ctx, auditBus.NewEntry{
+			Action: auditBus.ActionLoginLocked,
+			Target: k.key,
+			IP:     ip,
+			Details: map[string]string{
+				"failures":    fmt.Sprint(t.Failures),
+				"lockedUntil": until.Format(time.RFC3339),
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// LoginSucceeded clears the failures of the email, the ip keeps its counter so one valid account
+// can not be used to reset it.
+func (b *Bus) LoginSucceeded(ctx context.Context, email mail.Address) error {
+	if err := b.store.DeleteThrottle(ctx, emailKey(email)); err != nil {
+		return fmt.Errorf("deleteThrottle: %w", err)
+	}
+
+	return nil
+}
+
+// Unlock clears the failures and the lockout of the user's account.
+func (b *Bus) Unlock(ctx context.Context, actorID uuid.UUID, usr User) error {
+	if err := b.store.DeleteThrottle(ctx, emailKey(usr.Email)); err != nil {
+		return fmt.Errorf("deleteThrottle: %w", err)
+	}
+
+	err := b.audit(ctx, auditBus.NewEntry{
+		ActorID: actorID,
+		Action:  auditBus.ActionUserUnlock,
+		Target:  "user:" + usr.ID.String(),
+	})
+	if err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteStaleThrottles removes the counters that no longer affect any login.
+func (b *Bus) DeleteStaleThrottles(ctx context.Context) (int, error) {
+	n, err := b.store.DeleteStaleThrottles(ctx, time.Now().Add(-max(b.lockout.Window, b.lockout.Lockout)))
+	if err != nil {
+		return 0, fmt.Errorf("deleteStaleThrottles: %w", err)
+	}
+
+	return n, nil
+}
+
+// ==============================================================================
+
+// wait returns how long until the next attempt of the throttle is allowed.
+func (p LockoutPolicy) wait(t Throttle, now time.Time) time.Duration {
+	if t.LockedUntil != nil && t.LockedUntil.After(now) {
+		return t.LockedUntil.Sub(now)
+	}
+
+	if t.Failures == 0 || now.Sub(t.LastFailedAt) > p.Window {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := 1; i < t.Failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, p.MaxDelay)
+
+	return max(t.LastFailedAt.Add(delay).Sub(now), 0)
+}
+
+// audit records the entry if an auditor is set.
+func (b *Bus) audit(ctx context.Context, ne auditBus.NewEntry) error {
+	if b.auditor == nil {
+		return nil
+	}
+
+	if _, err := b.auditor.Create(ctx, ne); err != nil {
+		return fmt.Errorf("create: %w", err)
+	}
+
+	return nil
+}
+
+func emailKey(email mail.Address) string {
+	return "email:" + strings.ToLower(email.Address)
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
@@ -0,0 +1,194 @@
+package bus
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hamidoujand/jumble/pkg/totp"
+)
+
+var (
+	ErrMFAEnabled     = errors.New("mfa already enabled")
+	ErrMFANotEnrolled = errors.New("mfa is not enrolled")
+	ErrInvalidMFACode = errors.New("invalid mfa code")
+)
+
+const (
+	//number of recovery codes handed out when mfa gets enabled.
+	recoveryCodeCount = 10
+
+	//number of 30s steps a code is accepted before or after the current one.
+	totpSkew = 1
+)
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// RecoveryCode is a single-use code that replaces a totp code when the user lost the device,
+// only the hash of it is stored.
+type RecoveryCode struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Hash      []byte
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// EnrollMFA generates a new totp secret for the user, mfa is not enforced until the user confirms
+// it with a valid code. Enrolling again before confirming replaces the pending secret.
+func (b *Bus) EnrollMFA(ctx context.Context, usr User) (User, error) {
+	if usr.MFAEnabledAt != nil {
+		return User{}, ErrMFAEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return User{}, fmt.Errorf("generateSecret: %w", err)
+	}
+
+	usr.MFASecret = secret
+	usr.UpdatedAt = time.Now().Truncate(time.Microsecond)
+
+	if err := b.store.Update(ctx, usr); err != nil {
+		return User{}, fmt.Errorf("update: %w", err)
+	}
+
+	return usr, nil
+}
+
+// ConfirmMFA enables mfa once the user proves the authenticator is set up, the plain recovery codes
+// are returned only once so they can be handed to the user.
+func (b *Bus) ConfirmMFA(ctx context.Context, usr User, code string) (User, []string, error) {
+	if usr.MFAEnabledAt != nil {
+		return User{}, nil, ErrMFAEnabled
+	}
+
+	if usr.MFASecret == "" {
+		return User{}, nil, ErrMFANotEnrolled
+	}
+
+	if err := b.checkTOTP(ctx, usr, code); err != nil {
+		return User{}, nil, err
+	}
+
+	now := time.Now().Truncate(time.Microsecond)
+	usr.MFAEnabledAt = &now
+	usr.UpdatedAt = now
+
+	if err := b.store.Update(ctx, usr); err != nil {
+		return User{}, nil, fmt.Errorf("update: %w", err)
+	}
+
+	codes, err := b.issueRecoveryCodes(ctx, usr)
+	if err != nil {
+		return User{}, nil, fmt.Errorf("issueRecoveryCodes: %w", err)
+	}
+
+	return usr, codes, nil
+}
+
+// DisableMFA turns mfa off for the user, it asks for a valid code so a stolen access token is not
+// enough to remove the second factor.
+func (b *Bus) DisableMFA(ctx context.Context, usr User, code string) (User, error) {
+	if usr.MFAEnabledAt == nil {
+		return User{}, ErrMFANotEnrolled
+	}
+
+	if err := b.checkTOTP(ctx, usr, code); err != nil {
+		return User{}, err
+	}
+
+	usr.MFASecret = ""
+	usr.MFAEnabledAt = nil
+	usr.UpdatedAt = time.Now().Truncate(time.Microsecond)
+
+	if err := b.store.Update(ctx, usr); err != nil {
+		return User{}, fmt.Errorf("update: %w", err)
+	}
+
+	if err := b.store.ReplaceRecoveryCodes(ctx, usr.ID, nil); err != nil {
+		return User{}, fmt.Errorf("replaceRecoveryCodes: %w", err)
+	}
+
+	return usr, nil
+}
+
+// VerifyMFA checks the totp code of a user with mfa enabled, every code is accepted only once.
+func (b *Bus) VerifyMFA(ctx context.Context, usr User, code string) error {
+	if usr.MFAEnabledAt == nil {
+		return ErrMFANotEnrolled
+	}
+
+	return b.checkTOTP(ctx, usr, code)
+}
+
+// UseRecoveryCode consumes one of the user's recovery codes in place of a totp code.
+func (b *Bus) UseRecoveryCode(ctx context.Context, usr User, code string) error {
+	if usr.MFAEnabledAt == nil {
+		return ErrMFANotEnrolled
+	}
+
+	if err := b.store.UseRecoveryCode(ctx, usr.ID, hashToken(normalizeRecoveryCode(code)), time.Now()); err != nil {
+		return fmt.Errorf("useRecoveryCode: %w", err)
+	}
+
+	return nil
+}
+
+// ==============================================================================
+
+func (b *Bus) checkTOTP(ctx context.Context, usr User, code string) error {
+	counter, ok := totp.Validate(usr.MFASecret, code, time.Now(), totpSkew)
+	if !ok {
+		return ErrInvalidMFACode
+	}
+
+	//store only accepts counters newer than the last used one, this stops replaying a seen code.
+	if err := b.store.UseMFACounter(ctx, usr.ID, counter); err != nil {
+		return fmt.Errorf("useMFACounter: %w", err)
+	}
+
+	return nil
+}
+
+func (b *Bus) issueRecoveryCodes(ctx context.Context, usr User) ([]string, error) {
+	now := time.Now().Truncate(time.Microsecond)
+
+	codes := make([]string, recoveryCodeCount)
+	rcs := make([]RecoveryCode, recoveryCodeCount)
+
+	for i := range codes {
+		//50 bits each, written as "xxxxx-xxxxx" to be easy to type.
+		bs := make([]byte, 5)
+		if _, err := rand.Read(bs); err != nil {
+			return nil, fmt.Errorf("read: %w", err)
+		}
+
+		code := strings.ToLower(recoveryEncoding.EncodeToString(bs))
+		codes[i] = code[:5] + "-" + code[5:]
+
+		rcs[i] = RecoveryCode{
+			ID:        uuid.New(),
+			UserID:    usr.ID,
+			Hash:      hashToken(code),
+			CreatedAt: now,
+		}
+	}
+
+	if err := b.store.ReplaceRecoveryCodes(ctx, usr.ID, rcs); err != nil {
+		return nil, fmt.Errorf("replaceRecoveryCodes: %w", err)
+	}
+
+	return codes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ReplaceAll(code, "-", "")
+	code = strings.ReplaceAll(code, " ", "")
+	return strings.ToLower(code)
+}
@@ -8,15 +8,19 @@ import (
 )
 
 type User struct {
-	ID           uuid.UUID
-	Name         string
-	Email        mail.Address
-	Roles        []Role
-	PasswordHash []byte
-	Department   string
-	Enabled      bool
-	CreatedAt    time.Time
-	UpdatedAt    time.Time
+	ID                uuid.UUID
+	Name              string
+	Email             mail.Address
+	Roles             []Role
+	PasswordHash      []byte
+	Department        string
+	Enabled           bool
+	EmailVerifiedAt   *time.Time
+	PasswordChangedAt time.Time
+	MFASecret         string
+	MFAEnabledAt      *time.Time
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
 }
 
 type NewUser struct {
@@ -0,0 +1,149 @@
+package bus
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrPasskeyNotFound   = errors.New("passkey not found")
+	ErrDuplicatedPasskey = errors.New("passkey already registered")
+)
+
+// Purposes of a passkey ceremony, a challenge is only valid for the ceremony it was created for.
+const (
+	PurposePasskeyRegistration = "passkey_registration"
+	PurposePasskeyLogin        = "passkey_login"
+)
+
+// Passkey is a WebAuthn credential registered by a user.
+type Passkey struct {
+	ID           uuid.UUID
+	UserID       uuid.UUID
+	CredentialID []byte
+	PublicKey    []byte
+	SignCount    uint32
+	Name         string
+	LastUsedAt   *time.Time
+	CreatedAt    time.Time
+}
+
+type NewPasskey struct {
+	CredentialID []byte
+	PublicKey    []byte
+	SignCount    uint32
+	Name         string
+}
+
+// PasskeySession holds the challenge of an ongoing ceremony, UserID is uuid.Nil for logins since
+// the user is not known until the assertion comes back.
+type PasskeySession struct {
+	Challenge []byte
+	UserID    uuid.UUID
+	Purpose   string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+// CreatePasskeySession starts a ceremony and returns its challenge.
+func (b *Bus) CreatePasskeySession(ctx context.Context, userID uuid.UUID, purpose string, ttl time.Duration) ([]byte, error) {
+	challenge := make([]byte, 32)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+
+	now := time.Now().Truncate(time.Microsecond)
+
+	s := PasskeySession{
+		Challenge: challenge,
+		UserID:    userID,
+		Purpose:   purpose,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+
+	if err := b.store.CreatePasskeySession(ctx, s); err != nil {
+		return nil, fmt.Errorf("createPasskeySession: %w", err)
+	}
+
+	return challenge, nil
+}
+
+// ConsumePasskeySession ends the ceremony with the given challenge, each challenge can be used once.
+func (b *Bus) ConsumePasskeySession(ctx context.Context, challenge []byte, purpose string) (PasskeySession, error) {
+	s, err := b.store.DeletePasskeySession(ctx, challenge, purpose)
+	if err != nil {
+		return PasskeySession{}, fmt.Errorf("deletePasskeySession: %w", err)
+	}
+
+	if time.Now().After(s.ExpiresAt) {
+		return PasskeySession{}, ErrInvalidToken
+	}
+
+	return s, nil
+}
+
+// CreatePasskey stores a verified credential for the user.
+func (b *Bus) CreatePasskey(ctx context.Context, usr User, np NewPasskey) (Passkey, error) {
+	pk := Passkey{
+		ID:           uuid.New(),
+		UserID:       usr.ID,
+		CredentialID: np.CredentialID,
+		PublicKey:    np.PublicKey,
+		SignCount:    np.SignCount,
+		Name:         np.Name,
+		CreatedAt:    time.Now().Truncate(time.Microsecond),
+	}
+
+	if err := b.store.CreatePasskey(ctx, pk); err != nil {
+		return Passkey{}, fmt.Errorf("createPasskey: %w", err)
+	}
+
+	return pk, nil
+}
+
+func (b *Bus) QueryPasskeys(ctx context.Context, userID uuid.UUID) ([]Passkey, error) {
+	pks, err := b.store.QueryPasskeys(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("queryPasskeys: %w", err)
+	}
+
+	return pks, nil
+}
+
+func (b *Bus) QueryPasskeyByCredentialID(ctx context.Context, credentialID []byte) (Passkey, error) {
+	pk, err := b.store.QueryPasskeyByCredentialID(ctx, credentialID)
+	if err != nil {
+		return Passkey{}, fmt.Errorf("queryPasskeyByCredentialID: %w", err)
+	}
+
+	return pk, nil
+}
+
+// UsePasskey records a successful assertion with the sign count reported by the authenticator.
+func (b *Bus) UsePasskey(ctx context.Context, pk Passkey, signCount uint32) (Passkey, error) {
+	now := time.Now().Truncate(time.Microsecond)
+	pk.SignCount = signCount
+	pk.LastUsedAt = &now
+
+	if err := b.store.UpdatePasskey(ctx, pk); err != nil {
+		return Passkey{}, fmt.Errorf("updatePasskey: %w", err)
+	}
+
+	return pk, nil
+}
+
+// DeleteExpiredPasskeySessions removes the ceremonies that were never finished.
+func (b *Bus) DeleteExpiredPasskeySessions(ctx context.Context) (int, error) {
+	n, err := b.store.DeleteExpiredPasskeySessions(ctx, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("deleteExpiredPasskeySessions: %w", err)
+	}
+
+	return n, nil
+}
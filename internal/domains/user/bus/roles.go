@@ -4,11 +4,14 @@ import (
 	"database/sql/driver"
 	"fmt"
 	"strings"
+
+	roleBus "github.com/hamidoujand/jumble/internal/domains/role/bus"
 )
 
+// The built-in roles, any other role is created at runtime and managed by the role domain.
 var (
-	RoleAdmin = newRole("admin")
-	RoleUser  = newRole("user")
+	RoleAdmin = Role{value: "admin"}
+	RoleUser  = Role{value: "user"}
 )
 
 // Role represents a role in our system, since requires some validation, created a new custom type for it.
@@ -16,15 +19,6 @@ type Role struct {
 	value string
 }
 
-var validRoles = make(map[string]Role)
-
-func newRole(val string) Role {
-	r := Role{value: val}
-
-	validRoles[val] = r
-	return r
-}
-
 func (r Role) String() string {
 	return r.value
 }
@@ -68,7 +62,7 @@ func (rs RoleSlice) Value() (driver.Value, error) {
 	}
 
 	// Format each role with proper quoting for PostgreSQL array
-	//since Roles are defined by App not user input then it is a list of comma-separated values
+	//since role names are validated then it is a list of comma-separated values
 	qouted := make([]string, len(rs))
 
 	for i, role := range rs {
@@ -88,7 +82,7 @@ func (rs *RoleSlice) parsePostgresArray(arr string) error {
 		return nil
 	}
 
-	// Simple split on comma - no need for complex parsing since the Roles are defined by the App not user input.
+	// Simple split on comma - no need for complex parsing since role names never contain one.
 	elements := strings.Split(s, ",")
 	roles := make([]Role, len(elements))
 	for i, elem := range elements {
@@ -109,12 +103,11 @@ func (rs *RoleSlice) parsePostgresArray(arr string) error {
 
 // ------------------------------------------------------------------------------
 func parseRole(val string) (Role, error) {
-	r, ok := validRoles[val]
-	if !ok {
+	if !roleBus.ValidName(val) {
 		return Role{}, fmt.Errorf("invalid role: %s", val)
 	}
 
-	return r, nil
+	return Role{value: val}, nil
 }
 
 func RolesToString(roles []Role) []string {
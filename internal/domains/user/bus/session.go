@@ -0,0 +1,164 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	auditBus "github.com/hamidoujand/jumble/internal/domains/audit/bus"
+)
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrSessionRevoked  = errors.New("session is revoked")
+	ErrSessionExpired  = errors.New("session is expired")
+)
+
+const (
+	// maxUserAgent is the size of the user_agent column, longer ones are cut.
+	maxUserAgent = 512
+
+	// touchInterval is how stale last seen can get before a request updates it, so not every
+	// request ends up writing to the db.
+	touchInterval = time.Minute
+)
+
+// Session is a login of a user, every token issued for it carries its ID so it can be revoked
+// without touching the other logins.
+type Session struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	UserAgent  string
+	IP         string
+	LastSeenAt time.Time
+	ExpiresAt  time.Time
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+}
+
+// Check returns why the session can not be used anymore, if it can not.
+func (s Session) Check(now time.Time) error {
+	if s.RevokedAt != nil {
+		return ErrSessionRevoked
+	}
+
+	if !now.Before(s.ExpiresAt) {
+		return ErrSessionExpired
+	}
+
+	return nil
+}
+
+// CreateSession records a login from the given client, it lasts as long as the tokens issued for it.
+func (b *Bus) CreateSession(ctx context.Context, usr User, userAgent string, ip string, ttl time.Duration) (Session, error) {
+	if len(userAgent) > maxUserAgent {
+		userAgent = strings.ToValidUTF8(userAgent[:maxUserAgent], "")
+	}
+
+	now := time.Now().Truncate(time.Microsecond)
+
+	s := Session{
+		ID:         uuid.New(),
+		UserID:     usr.ID,
+		UserAgent:  userAgent,
+		IP:         ip,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(ttl),
+		CreatedAt:  now,
+	}
+
+	if err := b.store.CreateSession(ctx, s); err != nil {
+		return Session{}, fmt.Errorf("createSession: %w", err)
+	}
+
+	return s, nil
+}
+
+// QuerySessionByID returns the session of the user, sessions of other users are not found.
+func (b *Bus) QuerySessionByID(ctx context.Context, userID uuid.UUID, id uuid.UUID) (Session, error) {
+	s, err := b.store.QuerySessionByID(ctx, userID, id)
+	if err != nil {
+		return Session{}, fmt.Errorf("querySessionByID: %w", err)
+	}
+
+	return s, nil
+}
+
+// QuerySessionByIDInOrg is QuerySessionByID for org scoped tokens, sessions of users outside the
+// org are not found.
+func (b *Bus) QuerySessionByIDInOrg(ctx context.Context, orgID uuid.UUID, userID uuid.UUID, id uuid.UUID) (Session, error) {
+	s, err := b.store.QuerySessionByIDInOrg(ctx, orgID, userID, id)
+	if err != nil {
+		return Session{}, fmt.Errorf("querySessionByIDInOrg: %w", err)
+	}
+
+	return s, nil
+}
+
+// QuerySessions returns the sessions of the user that can still be used.
+func (b *Bus) QuerySessions(ctx context.Context, usr User) ([]Session, error) {
+	ss, err := b.store.QueryActiveSessions(ctx, usr.ID, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("queryActiveSessions: %w", err)
+	}
+
+	return ss, nil
+}
+
+// TouchSession moves last seen of the session to now, it only writes once in a while.
+func (b *Bus) TouchSession(ctx context.Context, s Session) (Session, error) {
+	now := time.Now().Truncate(time.Microsecond)
+	if now.Sub(s.LastSeenAt) < touchInterval {
+		return s, nil
+	}
+
+	s.LastSeenAt = now
+
+	if err := b.store.UpdateSession(ctx, s); err != nil {
+		return Session{}, fmt.Errorf("updateSession: %w", err)
+	}
+
+	return s, nil
+}
+
+// RevokeSession ends the session, tokens issued for it are rejected from now on.
+func (b *Bus) RevokeSession(ctx context.Context, s Session, actorID uuid.UUID, ip string) error {
+	if s.RevokedAt != nil {
+		return nil
+	}
+
+	now := time.Now().Truncate(time.Microsecond)
+	s.RevokedAt = &now
+
+	if err := b.store.UpdateSession(ctx, s); err != nil {
+		return fmt.Errorf("updateSession: %w", err)
+	}
+
+	err := b.audit(ctx, auditBus.NewEntry{
+		ActorID: actorID,
+		Action:  auditBus.ActionSessionRevoked,
+		Target:  "user:" + s.UserID.String(),
+		IP:      ip,
+		Details: map[string]string{
+			"session": s.ID.String(),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteExpiredSessions removes the sessions whose tokens expired, along with the revoked ones.
+func (b *Bus) DeleteExpiredSessions(ctx context.Context) (int, error) {
+	n, err := b.store.DeleteExpiredSessions(ctx, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("deleteExpiredSessions: %w", err)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,195 @@
+package bus
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/mail"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Purposes a user token can be issued for, a token is only valid for the purpose it was created for.
+const (
+	PurposePasswordReset     = "password_reset"
+	PurposeEmailVerification = "email_verification"
+)
+
+// Token represents a single-use secret sent to a user, only the hash of it is stored.
+type Token struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Purpose   string
+	Hash      []byte
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// CreatePasswordReset issues a reset token for the user with the given email, the plain token
+// is returned only once so it can be handed to the user.
+func (b *Bus) CreatePasswordReset(ctx context.Context, email mail.Address, ttl time.Duration) (User, string, error) {
+	usr, err := b.store.QueryByEmail(ctx, email)
+	if err != nil {
+		return User{}, "", fmt.Errorf("queryByEmail: %w", err)
+	}
+
+	if !usr.Enabled {
+		return User{}, "", ErrUserNotFound
+	}
+
+	token, err := b.issueToken(ctx, usr, PurposePasswordReset, ttl)
+	if err != nil {
+		return User{}, "", fmt.Errorf("issueToken: %w", err)
+	}
+
+	return usr, token, nil
+}
+
+// ResetPassword consumes the reset token and sets the new password for its owner, all of their
+// sessions are revoked.
+func (b *Bus) ResetPassword(ctx context.Context, token string, password string) (User, error) {
+	t, err := b.consumeToken(ctx, token, PurposePasswordReset)
+	if err != nil {
+		return User{}, fmt.Errorf("consumeToken: %w", err)
+	}
+
+	usr, err := b.store.QueryByID(ctx, t.UserID)
+	if err != nil {
+		return User{}, fmt.Errorf("queryByID: %w", err)
+	}
+
+	updated, err := b.Update(ctx, usr, UpdateUser{Password: &password})
+	if err != nil {
+		return User{}, fmt.Errorf("update: %w", err)
+	}
+
+	if err := b.store.RevokeSessions(ctx, usr.ID, uuid.Nil, updated.PasswordChangedAt); err != nil {
+		return User{}, fmt.Errorf("revokeSessions: %w", err)
+	}
+
+	//any other reset link sent before is useless now.
+	if err := b.store.DeleteTokens(ctx, usr.ID, PurposePasswordReset); err != nil {
+		return User{}, fmt.Errorf("deleteTokens: %w", err)
+	}
+
+	return updated, nil
+}
+
+// CreateEmailVerification issues a token to verify the email address of the user, resend is the minimum
+// time between two tokens so the user's inbox can not be flooded.
+func (b *Bus) CreateEmailVerification(ctx context.Context, usr User, ttl time.Duration, resend time.Duration) (string, error) {
+	if usr.EmailVerifiedAt != nil {
+		return "", ErrEmailVerified
+	}
+
+	latest, err := b.store.QueryLatestToken(ctx, usr.ID, PurposeEmailVerification)
+	switch {
+	case err == nil:
+		if time.Since(latest.CreatedAt) < resend {
+			return "", ErrTooManyRequests
+		}
+	case !errors.Is(err, ErrInvalidToken):
+		return "", fmt.Errorf("queryLatestToken: %w", err)
+	}
+
+	token, err := b.issueToken(ctx, usr, PurposeEmailVerification, ttl)
+	if err != nil {
+		return "", fmt.Errorf("issueToken: %w", err)
+	}
+
+	return token, nil
+}
+
+// VerifyEmail consumes the verification token and marks the email of its owner as verified.
+func (b *Bus) VerifyEmail(ctx context.Context, token string) (User, error) {
+	t, err := b.consumeToken(ctx, token, PurposeEmailVerification)
+	if err != nil {
+		return User{}, fmt.Errorf("consumeToken: %w", err)
+	}
+
+	usr, err := b.store.QueryByID(ctx, t.UserID)
+	if err != nil {
+		return User{}, fmt.Errorf("queryByID: %w", err)
+	}
+
+	now := time.Now().Truncate(time.Microsecond)
+	usr.EmailVerifiedAt = &now
+	usr.UpdatedAt = now
+
+	if err := b.store.Update(ctx, usr); err != nil {
+		return User{}, fmt.Errorf("update: %w", err)
+	}
+
+	if err := b.store.DeleteTokens(ctx, usr.ID, PurposeEmailVerification); err != nil {
+		return User{}, fmt.Errorf("deleteTokens: %w", err)
+	}
+
+	return usr, nil
+}
+
+// DeleteExpiredTokens removes all the tokens that are expired.
+func (b *Bus) DeleteExpiredTokens(ctx context.Context) (int, error) {
+	n, err := b.store.DeleteExpiredTokens(ctx, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("deleteExpiredTokens: %w", err)
+	}
+
+	return n, nil
+}
+
+// ==============================================================================
+
+func (b *Bus) issueToken(ctx context.Context, usr User, purpose string, ttl time.Duration) (string, error) {
+	//32 bytes of randomness, no need for slow hashing on lookups.
+	bs := make([]byte, 32)
+	if _, err := rand.Read(bs); err != nil {
+		return "", fmt.Errorf("read: %w", err)
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(bs)
+	now := time.Now().Truncate(time.Microsecond)
+
+	t := Token{
+		ID:        uuid.New(),
+		UserID:    usr.ID,
+		Purpose:   purpose,
+		Hash:      hashToken(token),
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+
+	if err := b.store.CreateToken(ctx, t); err != nil {
+		return "", fmt.Errorf("createToken: %w", err)
+	}
+
+	return token, nil
+}
+
+func (b *Bus) consumeToken(ctx context.Context, token string, purpose string) (Token, error) {
+	t, err := b.store.QueryTokenByHash(ctx, hashToken(token), purpose)
+	if err != nil {
+		return Token{}, fmt.Errorf("queryTokenByHash: %w", err)
+	}
+
+	now := time.Now()
+	if t.UsedAt != nil || now.After(t.ExpiresAt) {
+		return Token{}, ErrInvalidToken
+	}
+
+	//store only marks it if nobody else used it in the meantime.
+	if err := b.store.UseToken(ctx, t, now); err != nil {
+		return Token{}, fmt.Errorf("useToken: %w", err)
+	}
+
+	return t, nil
+}
+
+func hashToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
@@ -0,0 +1,219 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"net/mail"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hamidoujand/jumble/internal/auth"
+	departmentBus "github.com/hamidoujand/jumble/internal/domains/department/bus"
+	roleBus "github.com/hamidoujand/jumble/internal/domains/role/bus"
+	"github.com/hamidoujand/jumble/internal/domains/user/bus"
+	"github.com/hamidoujand/jumble/internal/errs"
+	"github.com/hamidoujand/jumble/internal/oidc"
+)
+
+// federatedSessionTTL is how long a user has to log in at the provider.
+const federatedSessionTTL = 10 * time.Minute
+
+// stateCookie ties the callback to the browser that started the login, without it an attacker
+// could have a victim finish a login into the attacker's account.
+const stateCookie = "jumble_oidc_state"
+
+// BeginFederatedLogin sends the browser to the provider in the path to log in.
+func (h *handler) BeginFederatedLogin(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "user.handler.beginFederatedLogin")
+	defer span.End()
+
+	p, ok := h.loadProvider(c)
+	if !ok {
+		return
+	}
+
+	name := p.Config().Name
+
+	state, fs, err := h.userBus.CreateFederatedSession(ctx, name, federatedSessionTTL)
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "createFederatedSession: %s", err))
+		return
+	}
+
+	authURL, err := p.AuthURL(ctx, h.callbackURL(name), state, fs.Nonce, fs.CodeVerifier)
+	if err != nil {
+		c.Error(errs.New(http.StatusBadGateway, "authURL: %s", err))
+		return
+	}
+
+	//lax, the callback is a top level navigation coming back from the provider.
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(stateCookie, state, int(federatedSessionTTL.Seconds()), h.callbackPath(name), "", h.secureCookies(), true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// FinishFederatedLogin is where the provider sends the browser back to, the code is exchanged for
+// an ID token and the user behind it gets a token of our own.
+func (h *handler) FinishFederatedLogin(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "user.handler.finishFederatedLogin")
+	defer span.End()
+
+	p, ok := h.loadProvider(c)
+	if !ok {
+		return
+	}
+
+	cfg := p.Config()
+
+	cookie, cookieErr := c.Cookie(stateCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(stateCookie, "", -1, h.callbackPath(cfg.Name), "", h.secureCookies(), true)
+
+	state := c.Query("state")
+	if state == "" || cookieErr != nil || subtle.ConstantTimeCompare([]byte(state), []byte(cookie)) != 1 {
+		c.Error(errs.New(http.StatusBadRequest, "%s", bus.ErrInvalidToken))
+		return
+	}
+
+	//the session goes either way, a state is good for one attempt.
+	fs, err := h.userBus.ConsumeFederatedSession(ctx, state, cfg.Name)
+	if errors.Is(err, bus.ErrInvalidToken) {
+		c.Error(errs.New(http.StatusBadRequest, "%s", bus.ErrInvalidToken))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "consumeFederatedSession: %s", err))
+		return
+	}
+
+	if e := c.Query("error"); e != "" {
+		c.Error(errs.New(http.StatusUnauthorized, "identity provider: %s: %s", e, c.Query("error_description")))
+		return
+	}
+
+	code := c.Query("code")
+	if code == "" {
+		c.Error(errs.New(http.StatusBadRequest, "code is required"))
+		return
+	}
+
+	claims, err := p.Exchange(ctx, code, h.callbackURL(cfg.Name), fs.CodeVerifier, fs.Nonce)
+	if errors.Is(err, oidc.ErrExchange) || errors.Is(err, oidc.ErrInvalidIDToken) {
+		c.Error(errs.New(http.StatusUnauthorized, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusBadGateway, "exchange: %s", err))
+		return
+	}
+
+	addr, err := mail.ParseAddress(claims.Email)
+	if err != nil {
+		c.Error(errs.New(http.StatusForbidden, "identity provider did not share a valid email"))
+		return
+	}
+
+	roles := cfg.Roles
+	if len(roles) == 0 {
+		roles = []string{bus.RoleUser.String()}
+	}
+
+	busRoles, err := bus.ParseManyRoles(roles)
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "parseManyRoles: %s", err))
+		return
+	}
+
+	usr, err := h.userBus.FederatedLogin(ctx, bus.ExternalUser{
+		Provider:      cfg.Name,
+		Subject:       claims.Subject,
+		Name:          claims.Name,
+		Email:         *addr,
+		EmailVerified: claims.EmailVerified,
+	}, bus.ProvisionPolicy{
+		Enabled:    !cfg.DisableProvisioning,
+		Roles:      busRoles,
+		Department: cfg.Department,
+	})
+	if errors.Is(err, bus.ErrUnverifiedEmail) || errors.Is(err, bus.ErrProvisioningDenied) || errors.Is(err, bus.ErrLinkingDenied) {
+		c.Error(errs.New(http.StatusForbidden, "%s", err))
+		return
+	}
+
+	//the roles or department of the provider config do not exist.
+	if errors.Is(err, roleBus.ErrRoleNotFound) || errors.Is(err, departmentBus.ErrDepartmentNotFound) {
+		c.Error(errs.New(http.StatusInternalServerError, "provider %s is misconfigured: %s", cfg.Name, err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "federatedLogin: %s", err))
+		return
+	}
+
+	if !usr.Enabled {
+		c.Error(errs.New(http.StatusUnauthorized, "user is disabled"))
+		return
+	}
+
+	amr := []string{auth.AMRFederated}
+	trustedMFA := cfg.TrustMFA && slices.Contains(claims.AMR, auth.AMRMFA)
+	if trustedMFA {
+		amr = append(amr, auth.AMRMFA)
+	}
+
+	//a provider login does not skip the second factor users enrolled with us, unless the provider
+	//is trusted to have asked for one.
+	if usr.MFAEnabledAt != nil && !trustedMFA {
+		challenge, err := h.mfaChallenge(usr, amr)
+		if err != nil {
+			c.Error(errs.New(http.StatusInternalServerError, "mfaChallenge: %s", err))
+			return
+		}
+
+		c.JSON(http.StatusOK, mfaChallenge{MFARequired: true, Challenge: challenge})
+		return
+	}
+
+	token, err := h.generateToken(ctx, c, usr, amr)
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "generateToken: %s", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, Token{Token: token})
+}
+
+// ==============================================================================
+
+// loadProvider looks up the provider in the path, when it fails the error is already set on the
+// context.
+func (h *handler) loadProvider(c *gin.Context) (*oidc.Provider, bool) {
+	name := c.Param("provider")
+
+	p, ok := h.providers[name]
+	if !ok {
+		c.Error(errs.New(http.StatusNotFound, "unknown identity provider: %s", name))
+		return nil, false
+	}
+
+	return p, true
+}
+
+func (h *handler) callbackPath(provider string) string {
+	return "/v1/auth/oidc/" + provider + "/callback"
+}
+
+// callbackURL is the redirect URI registered at the provider.
+func (h *handler) callbackURL(provider string) string {
+	return strings.TrimSuffix(h.baseURL, "/") + h.callbackPath(provider)
+}
+
+func (h *handler) secureCookies() bool {
+	return strings.HasPrefix(h.baseURL, "https://")
+}
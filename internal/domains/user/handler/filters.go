@@ -3,13 +3,15 @@ package handler
 import (
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/hamidoujand/jumble/internal/domains/user/bus"
 )
 
 type Filters struct {
 	Name           *string  `form:"name" binding:"omitempty,min=4,max=120"`
-	Department     *string  `form:"department" binding:"omitempty,oneof=sales marketing"`
-	Roles          []string `form:"roles" binding:"omitempty,dive,oneof=user admin"`
+	Department     *string  `form:"department" binding:"omitempty,max=100"`
+	Roles          []string `form:"roles" binding:"omitempty,dive,max=32"`
+	Groups         []string `form:"groups" binding:"omitempty,dive,uuid"`
 	StartCreatedAt *string  `form:"startCreatedAt" binding:"omitempty,datetime=2006-01-02T15:04:05Z07:00"` //RFC3339
 	EndCreatedAt   *string  `form:"endCreatedAt" binding:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`   //RFC3339
 }
@@ -25,6 +27,14 @@ func (f Filters) ToBusQueryFilter() (bus.QueryFilter, error) {
 		busQueryFilters.Roles = parsedRoles
 	}
 
+	for _, g := range f.Groups {
+		id, err := uuid.Parse(g)
+		if err != nil {
+			return bus.QueryFilter{}, err
+		}
+		busQueryFilters.Groups = append(busQueryFilters.Groups, id)
+	}
+
 	if f.Department != nil {
 		busQueryFilters.Department = f.Department
 	}
@@ -2,9 +2,12 @@
 package handler
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/mail"
+	"net/url"
 	"slices"
 	"time"
 
@@ -12,19 +15,58 @@ import (
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
 	"github.com/hamidoujand/jumble/internal/auth"
+	"github.com/hamidoujand/jumble/internal/authz"
+	departmentBus "github.com/hamidoujand/jumble/internal/domains/department/bus"
+	groupBus "github.com/hamidoujand/jumble/internal/domains/group/bus"
+	oauthBus "github.com/hamidoujand/jumble/internal/domains/oauth/bus"
+	orgBus "github.com/hamidoujand/jumble/internal/domains/org/bus"
+	roleBus "github.com/hamidoujand/jumble/internal/domains/role/bus"
 	"github.com/hamidoujand/jumble/internal/domains/user/bus"
 	"github.com/hamidoujand/jumble/internal/errs"
+	"github.com/hamidoujand/jumble/internal/mailer"
+	"github.com/hamidoujand/jumble/internal/mid"
+	"github.com/hamidoujand/jumble/internal/oidc"
 	"github.com/hamidoujand/jumble/internal/page"
+	"github.com/hamidoujand/jumble/internal/password"
+	"github.com/hamidoujand/jumble/internal/webauthn"
+	"github.com/hamidoujand/jumble/pkg/cache"
+	"github.com/hamidoujand/jumble/pkg/logger"
+	"github.com/hamidoujand/jumble/pkg/totp"
 	"go.opentelemetry.io/otel/trace"
 )
 
+// mfaChallengeTTL is how long a client has to send the second factor after the password check.
+const mfaChallengeTTL = 5 * time.Minute
+
 type handler struct {
-	userBus     *bus.Bus
-	a           *auth.Auth
-	kid         string
-	issuer      string
-	tokenMaxAge time.Duration
-	tracer      trace.Tracer
+	userBus       *bus.Bus
+	roleBus       *roleBus.Bus
+	orgBus        *orgBus.Bus
+	groupBus      *groupBus.Bus
+	authz         *authz.Authorizer
+	a             *auth.Auth
+	kid           string
+	issuer        string
+	tokenMaxAge   time.Duration
+	resetTokenTTL time.Duration
+	verifyTTL     time.Duration
+	verifyResend  time.Duration
+	inviteTTL     time.Duration
+	inviteURL     string
+	mailer        mailer.Mailer
+	baseURL       string
+	webauthn      *webauthn.WebAuthn
+	providers     map[string]*oidc.Provider
+
+	requireVerifiedEmail bool
+	introspections       *cache.Cache[introspection]
+	impersonationTTL     time.Duration
+	cookies              *mid.Cookies
+	authConf             mid.AuthConf
+	oauthBus             *oauthBus.Bus
+
+	tracer trace.Tracer
+	log    *logger.Logger
 }
 
 func (h *handler) CreateUser(c *gin.Context) {
@@ -44,7 +86,8 @@ func (h *handler) CreateUser(c *gin.Context) {
 	}
 
 	usr, err := h.userBus.Create(ctx, busUser)
-	if errors.Is(err, bus.ErrDuplicatedEmail) {
+	if errors.Is(err, bus.ErrDuplicatedEmail) || errors.Is(err, password.ErrWeakPassword) ||
+		errors.Is(err, roleBus.ErrRoleNotFound) || errors.Is(err, departmentBus.ErrDepartmentNotFound) {
 		c.Error(errs.New(http.StatusBadRequest, "create: %s", err))
 		return
 	}
@@ -55,22 +98,21 @@ func (h *handler) CreateUser(c *gin.Context) {
 	}
 
 	appUser := toAppUser(usr)
-	claims := auth.Claims{
-		Roles: appUser.Roles,
-		RegisteredClaims: jwt.RegisteredClaims{
-			Issuer:    h.issuer,
-			Subject:   appUser.ID,
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(h.tokenMaxAge)),
-		},
-	}
 
-	token, err := h.a.GenerateToken(h.kid, claims)
+	token, err := h.generateToken(ctx, c, usr, []string{auth.AMRPassword})
 	if err != nil {
 		c.Error(errs.New(http.StatusInternalServerError, "generateToken: %s", err))
 		return
 	}
 
+	verifyToken, err := h.userBus.CreateEmailVerification(ctx, usr, h.verifyTTL, h.verifyResend)
+	if err != nil {
+		//the user can still ask for another one, no need to fail the whole request.
+		h.log.Error(ctx, "createEmailVerification", "userID", usr.ID, "err", err.Error())
+	} else {
+		h.sendVerification(ctx, usr, verifyToken)
+	}
+
 	appUser.Token = token
 	c.JSON(http.StatusCreated, appUser)
 }
@@ -87,7 +129,19 @@ func (h *handler) QueryUserByID(c *gin.Context) {
 		return
 	}
 
-	usr, err := h.userBus.QueryByID(ctx, userID)
+	val, ok := c.Get("user")
+	if !ok {
+		c.Error(errs.New(http.StatusUnauthorized, "%s", http.StatusText(http.StatusUnauthorized)))
+		return
+	}
+
+	current, ok := val.(bus.User)
+	if !ok {
+		c.Error(errs.New(http.StatusUnauthorized, "%s", http.StatusText(http.StatusUnauthorized)))
+		return
+	}
+
+	usr, err := h.queryUser(ctx, c, userID)
 	if errors.Is(err, bus.ErrUserNotFound) {
 		c.Error(errs.New(http.StatusNotFound, "queryByID: %s", err))
 		return
@@ -98,6 +152,10 @@ func (h *handler) QueryUserByID(c *gin.Context) {
 		return
 	}
 
+	if !h.authorize(c, current, authz.ActionUserRead, usr) {
+		return
+	}
+
 	c.JSON(http.StatusOK, toAppUser(usr))
 }
 
@@ -125,13 +183,7 @@ func (h *handler) DeleteUser(c *gin.Context) {
 		return
 	}
 
-	//either the user itself or admin can delete a user .
-	if userId != usr.ID && !isAdmin(usr.Roles) {
-		c.Error(errs.New(http.StatusUnauthorized, "unauthorized to take this action"))
-		return
-	}
-
-	targetUser, err := h.userBus.QueryByID(ctx, userId)
+	targetUser, err := h.queryUser(ctx, c, userId)
 	if errors.Is(err, bus.ErrUserNotFound) {
 		c.Error(errs.New(http.StatusNotFound, "%s", err))
 		return
@@ -142,6 +194,10 @@ func (h *handler) DeleteUser(c *gin.Context) {
 		return
 	}
 
+	if !h.authorize(c, usr, authz.ActionUserDelete, targetUser) {
+		return
+	}
+
 	//delete the target user
 	if err := h.userBus.Delete(ctx, targetUser); err != nil {
 		c.Error(errs.New(http.StatusInternalServerError, "delete: %s", err))
@@ -174,8 +230,21 @@ func (h *handler) UpdateUser(c *gin.Context) {
 		return
 	}
 
+	target := usr
 	if usr.ID != targetID {
-		c.Error(errs.New(http.StatusUnauthorized, "%s", http.StatusText(http.StatusUnauthorized)))
+		target, err = h.queryUser(ctx, c, targetID)
+		if errors.Is(err, bus.ErrUserNotFound) {
+			c.Error(errs.New(http.StatusNotFound, "%s", err))
+			return
+		}
+
+		if err != nil {
+			c.Error(errs.New(http.StatusInternalServerError, "queryByID: %s", err))
+			return
+		}
+	}
+
+	if !h.authorize(c, usr, authz.ActionUserUpdate, target) {
 		return
 	}
 
@@ -191,8 +260,8 @@ func (h *handler) UpdateUser(c *gin.Context) {
 		return
 	}
 
-	updated, err := h.userBus.Update(ctx, usr, busUserUpdate)
-	if errors.Is(err, bus.ErrDuplicatedEmail) {
+	updated, err := h.userBus.Update(ctx, target, busUserUpdate)
+	if errors.Is(err, bus.ErrDuplicatedEmail) || errors.Is(err, departmentBus.ErrDepartmentNotFound) {
 		c.Error(errs.New(http.StatusBadRequest, "%s", err))
 		return
 	}
@@ -209,24 +278,6 @@ func (h *handler) UpdateRole(c *gin.Context) {
 	ctx, span := h.tracer.Start(c.Request.Context(), "user.handler.updateRole")
 	defer span.End()
 
-	val, ok := c.Get("user")
-	if !ok {
-		c.Error(errs.New(http.StatusUnauthorized, "%s", http.StatusText(http.StatusUnauthorized)))
-		return
-	}
-
-	admin, ok := val.(bus.User)
-	if !ok {
-		c.Error(errs.New(http.StatusUnauthorized, "%s", http.StatusText(http.StatusUnauthorized)))
-		return
-	}
-
-	//must be an admin
-	if !isAdmin(admin.Roles) {
-		c.Error(errs.New(http.StatusUnauthorized, "%s", http.StatusText(http.StatusUnauthorized)))
-		return
-	}
-
 	//target userID
 	p := c.Param("id")
 	userId, err := uuid.Parse(p)
@@ -247,8 +298,12 @@ func (h *handler) UpdateRole(c *gin.Context) {
 		return
 	}
 
+	if !h.canGrant(c, bus.RolesToString(busUpdateRoles.Roles)) {
+		return
+	}
+
 	//fetch the usr from db
-	usr, err := h.userBus.QueryByID(ctx, userId)
+	usr, err := h.queryUser(ctx, c, userId)
 	if errors.Is(err, bus.ErrUserNotFound) {
 		c.Error(errs.New(http.StatusNotFound, "%s", err))
 		return
@@ -265,6 +320,11 @@ func (h *handler) UpdateRole(c *gin.Context) {
 	}
 
 	updated, err := h.userBus.Update(ctx, usr, busUpdateRoles)
+	if errors.Is(err, roleBus.ErrRoleNotFound) {
+		c.Error(errs.New(http.StatusBadRequest, "%s", err))
+		return
+	}
+
 	if err != nil {
 		c.Error(errs.New(http.StatusInternalServerError, "queryByID: %s", err))
 		return
@@ -297,13 +357,7 @@ func (h *handler) DisableUser(c *gin.Context) {
 		return
 	}
 
-	//either the user itself or admin can delete a user .
-	if userId != usr.ID && !isAdmin(usr.Roles) {
-		c.Error(errs.New(http.StatusUnauthorized, "%s", http.StatusText(http.StatusUnauthorized)))
-		return
-	}
-
-	targetUser, err := h.userBus.QueryByID(ctx, userId)
+	targetUser, err := h.queryUser(ctx, c, userId)
 	if errors.Is(err, bus.ErrUserNotFound) {
 		c.Error(errs.New(http.StatusNotFound, "%s", err))
 		return
@@ -314,6 +368,10 @@ func (h *handler) DisableUser(c *gin.Context) {
 		return
 	}
 
+	if !h.authorize(c, usr, authz.ActionUserDisable, targetUser) {
+		return
+	}
+
 	enabled := false
 	uu := updateUser{
 		Enabled: &enabled,
@@ -331,6 +389,48 @@ func (h *handler) DisableUser(c *gin.Context) {
 	c.JSON(http.StatusOK, toAppUser(updated))
 }
 
+func (h *handler) UnlockUser(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "user.handler.unlockUser")
+	defer span.End()
+
+	val, ok := c.Get("user")
+	if !ok {
+		c.Error(errs.New(http.StatusUnauthorized, "%s", http.StatusText(http.StatusUnauthorized)))
+		return
+	}
+
+	admin, ok := val.(bus.User)
+	if !ok {
+		c.Error(errs.New(http.StatusUnauthorized, "%s", http.StatusText(http.StatusUnauthorized)))
+		return
+	}
+
+	p := c.Param("id")
+	userId, err := uuid.Parse(p)
+	if err != nil {
+		c.Error(errs.New(http.StatusBadRequest, "invalid user id: %s", p))
+		return
+	}
+
+	usr, err := h.queryUser(ctx, c, userId)
+	if errors.Is(err, bus.ErrUserNotFound) {
+		c.Error(errs.New(http.StatusNotFound, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "queryByID: %s", err))
+		return
+	}
+
+	if err := h.userBus.Unlock(ctx, admin.ID, usr); err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "unlock: %s", err))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
 func (h *handler) Query(c *gin.Context) {
 	ctx, span := h.tracer.Start(c.Request.Context(), "user.handler.query")
 	defer span.End()
@@ -358,6 +458,29 @@ func (h *handler) Query(c *gin.Context) {
 		return
 	}
 
+	//org scoped tokens only see the members of their org.
+	if m, ok := mid.Membership(c); ok {
+		busFilter.OrgID = &m.OrgID
+	}
+
+	ps, err := mid.Permissions(c, h.roleBus)
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "permissions: %s", err))
+		return
+	}
+
+	//without users:read only the users of one's own department are listed.
+	if !ps.Has(roleBus.PermUsersRead) {
+		val, _ := c.Get("user")
+		usr, ok := val.(bus.User)
+		if !ok || !ps.Has(roleBus.PermUsersDepartmentRead) || usr.Department == "" {
+			c.Error(errs.New(http.StatusForbidden, "you are not allowed to take this action"))
+			return
+		}
+
+		busFilter.Department = &usr.Department
+	}
+
 	//order by
 	orderBy, err := bus.ParseOrderBy(c.Query("order_by"))
 	if err != nil {
@@ -402,33 +525,636 @@ func (h *handler) Authenticate(c *gin.Context) {
 		return
 	}
 
+	ip := c.ClientIP()
+
+	wait, err := h.userBus.CheckLogin(ctx, *email, ip)
+	if errors.Is(err, bus.ErrLoginLocked) {
+		c.Header("Retry-After", retryAfter(wait))
+		c.Error(errs.New(http.StatusTooManyRequests, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "checkLogin: %s", err))
+		return
+	}
+
 	usr, err := h.userBus.Authenticate(ctx, *email, authData.Password)
+	if errors.Is(err, bus.ErrInvalidCredentials) {
+		if err := h.userBus.LoginFailed(ctx, *email, ip); err != nil {
+			h.log.Error(ctx, "loginFailed", "err", err.Error())
+		}
+
+		c.Error(errs.New(http.StatusUnauthorized, "%s", bus.ErrInvalidCredentials))
+		return
+	}
+
+	//the password was right but it can only be used to change the password at "/password/change",
+	//the failures are cleared once that succeeds.
+	if errors.Is(err, bus.ErrPasswordExpired) {
+		c.Error(errs.New(http.StatusForbidden, "%s", bus.ErrPasswordExpired))
+		return
+	}
+
 	if err != nil {
-		c.Error(errs.New(http.StatusBadRequest, "invalid email or password: %s", err))
+		c.Error(errs.New(http.StatusInternalServerError, "authenticate: %s", err))
 		return
 	}
 
-	claims := auth.Claims{
-		Roles: bus.RolesToString(usr.Roles),
+	//the password alone is not enough, the client has to finish the login at "/login/mfa". The
+	//failures are only cleared there, otherwise logging in again would reset the count of wrong codes.
+	if usr.MFAEnabledAt != nil {
+		challenge, err := h.mfaChallenge(usr, []string{auth.AMRPassword})
+		if err != nil {
+			c.Error(errs.New(http.StatusInternalServerError, "mfaChallenge: %s", err))
+			return
+		}
+
+		c.JSON(http.StatusOK, mfaChallenge{MFARequired: true, Challenge: challenge})
+		return
+	}
+
+	token, err := h.generateToken(ctx, c, usr, []string{auth.AMRPassword})
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "generateToken: %s", err))
+		return
+	}
+
+	if err := h.userBus.LoginSucceeded(ctx, usr.Email); err != nil {
+		h.log.Error(ctx, "loginSucceeded", "userID", usr.ID, "err", err.Error())
+	}
+
+	t := Token{Token: token}
+	c.JSON(http.StatusOK, t)
+}
+
+func (h *handler) AuthenticateMFA(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "user.handler.authenticateMFA")
+	defer span.End()
+
+	var lm loginMFA
+	if err := c.ShouldBindJSON(&lm); err != nil {
+		c.Error(err)
+		return
+	}
+
+	claims, err := h.a.ParseToken(ctx, lm.Challenge)
+	if err != nil || claims.TokenType != auth.TokenTypeMFAChallenge {
+		c.Error(errs.New(http.StatusUnauthorized, "invalid mfa challenge"))
+		return
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		c.Error(errs.New(http.StatusUnauthorized, "invalid mfa challenge"))
+		return
+	}
+
+	usr, err := h.userBus.QueryByID(ctx, userID)
+	if errors.Is(err, bus.ErrUserNotFound) {
+		c.Error(errs.New(http.StatusUnauthorized, "invalid mfa challenge"))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "queryByID: %s", err))
+		return
+	}
+
+	//same rules as access tokens, a password change in the meantime kills the challenge.
+	if !usr.Enabled || claims.IssuedAt == nil || claims.IssuedAt.Before(usr.PasswordChangedAt.Truncate(time.Second)) {
+		c.Error(errs.New(http.StatusUnauthorized, "invalid mfa challenge"))
+		return
+	}
+
+	//guessing codes counts against the same limits as guessing passwords.
+	ip := c.ClientIP()
+
+	wait, err := h.userBus.CheckLogin(ctx, usr.Email, ip)
+	if errors.Is(err, bus.ErrLoginLocked) {
+		c.Header("Retry-After", retryAfter(wait))
+		c.Error(errs.New(http.StatusTooManyRequests, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "checkLogin: %s", err))
+		return
+	}
+
+	//the challenge carries how the first factor was proven, a password or a provider.
+	amr := append(slices.Clone(claims.AMR), auth.AMROTP, auth.AMRMFA)
+	if lm.Code != "" {
+		err = h.userBus.VerifyMFA(ctx, usr, lm.Code)
+	} else {
+		amr = append(slices.Clone(claims.AMR), auth.AMRMFA)
+		err = h.userBus.UseRecoveryCode(ctx, usr, lm.RecoveryCode)
+	}
+
+	if errors.Is(err, bus.ErrInvalidMFACode) || errors.Is(err, bus.ErrMFANotEnrolled) {
+		if err := h.userBus.LoginFailed(ctx, usr.Email, ip); err != nil {
+			h.log.Error(ctx, "loginFailed", "err", err.Error())
+		}
+
+		c.Error(errs.New(http.StatusUnauthorized, "%s", bus.ErrInvalidMFACode))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "verifyMFA: %s", err))
+		return
+	}
+
+	token, err := h.generateToken(ctx, c, usr, amr)
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "generateToken: %s", err))
+		return
+	}
+
+	if err := h.userBus.LoginSucceeded(ctx, usr.Email); err != nil {
+		h.log.Error(ctx, "loginSucceeded", "userID", usr.ID, "err", err.Error())
+	}
+
+	c.JSON(http.StatusOK, Token{Token: token})
+}
+
+func (h *handler) EnrollMFA(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "user.handler.enrollMFA")
+	defer span.End()
+
+	val, ok := c.Get("user")
+	if !ok {
+		c.Error(errs.New(http.StatusUnauthorized, "%s", http.StatusText(http.StatusUnauthorized)))
+		return
+	}
+
+	usr, ok := val.(bus.User)
+	if !ok {
+		c.Error(errs.New(http.StatusUnauthorized, "%s", http.StatusText(http.StatusUnauthorized)))
+		return
+	}
+
+	enrolled, err := h.userBus.EnrollMFA(ctx, usr)
+	if errors.Is(err, bus.ErrMFAEnabled) {
+		c.Error(errs.New(http.StatusBadRequest, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "enrollMFA: %s", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, mfaEnrollment{
+		Secret: enrolled.MFASecret,
+		URI:    totp.ProvisioningURI(h.issuer, enrolled.Email.Address, enrolled.MFASecret),
+	})
+}
+
+func (h *handler) ConfirmMFA(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "user.handler.confirmMFA")
+	defer span.End()
+
+	val, ok := c.Get("user")
+	if !ok {
+		c.Error(errs.New(http.StatusUnauthorized, "%s", http.StatusText(http.StatusUnauthorized)))
+		return
+	}
+
+	usr, ok := val.(bus.User)
+	if !ok {
+		c.Error(errs.New(http.StatusUnauthorized, "%s", http.StatusText(http.StatusUnauthorized)))
+		return
+	}
+
+	var mc mfaCode
+	if err := c.ShouldBindJSON(&mc); err != nil {
+		c.Error(err)
+		return
+	}
+
+	_, codes, err := h.userBus.ConfirmMFA(ctx, usr, mc.Code)
+	if errors.Is(err, bus.ErrMFAEnabled) || errors.Is(err, bus.ErrMFANotEnrolled) || errors.Is(err, bus.ErrInvalidMFACode) {
+		c.Error(errs.New(http.StatusBadRequest, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "confirmMFA: %s", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, recoveryCodes{RecoveryCodes: codes})
+}
+
+func (h *handler) DisableMFA(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "user.handler.disableMFA")
+	defer span.End()
+
+	val, ok := c.Get("user")
+	if !ok {
+		c.Error(errs.New(http.StatusUnauthorized, "%s", http.StatusText(http.StatusUnauthorized)))
+		return
+	}
+
+	usr, ok := val.(bus.User)
+	if !ok {
+		c.Error(errs.New(http.StatusUnauthorized, "%s", http.StatusText(http.StatusUnauthorized)))
+		return
+	}
+
+	var mc mfaCode
+	if err := c.ShouldBindJSON(&mc); err != nil {
+		c.Error(err)
+		return
+	}
+
+	_, err := h.userBus.DisableMFA(ctx, usr, mc.Code)
+	if errors.Is(err, bus.ErrMFANotEnrolled) || errors.Is(err, bus.ErrInvalidMFACode) {
+		c.Error(errs.New(http.StatusBadRequest, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "disableMFA: %s", err))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *handler) ForgotPassword(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "user.handler.forgotPassword")
+	defer span.End()
+
+	var fp forgotPassword
+	if err := c.ShouldBindJSON(&fp); err != nil {
+		c.Error(err)
+		return
+	}
+
+	email, err := mail.ParseAddress(fp.Email)
+	if err != nil {
+		c.Error(errs.New(http.StatusBadRequest, "parseAddress: %s", err))
+		return
+	}
+
+	usr, token, err := h.userBus.CreatePasswordReset(ctx, *email, h.resetTokenTTL)
+	if err != nil {
+		//unknown emails get the same response, otherwise this endpoint tells who has an account.
+		if !errors.Is(err, bus.ErrUserNotFound) {
+			h.log.Error(ctx, "createPasswordReset", "err", err.Error())
+		}
+
+		c.Status(http.StatusAccepted)
+		return
+	}
+
+	//sending happens in the background so the response time does not tell if the email exists either.
+	h.sendEmail(ctx, usr.Email, "password_reset", emailData{
+		Name:      usr.Name,
+		Token:     token,
+		ExpiresIn: h.resetTokenTTL.String(),
+	})
+
+	c.Status(http.StatusAccepted)
+}
+
+func (h *handler) ResetPassword(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "user.handler.resetPassword")
+	defer span.End()
+
+	var rp resetPassword
+	if err := c.ShouldBindJSON(&rp); err != nil {
+		c.Error(err)
+		return
+	}
+
+	_, err := h.userBus.ResetPassword(ctx, rp.Token, rp.Password)
+	if errors.Is(err, bus.ErrInvalidToken) {
+		c.Error(errs.New(http.StatusBadRequest, "%s", bus.ErrInvalidToken))
+		return
+	}
+
+	if errors.Is(err, password.ErrWeakPassword) || errors.Is(err, bus.ErrPasswordReused) {
+		c.Error(errs.New(http.StatusBadRequest, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "resetPassword: %s", err))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ChangePassword lets users that know their password set a new one, it is the only way forward
+// once a password expired since those can not log in anymore.
+func (h *handler) ChangePassword(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "user.handler.changePassword")
+	defer span.End()
+
+	var cp changePassword
+	if err := c.ShouldBindJSON(&cp); err != nil {
+		c.Error(err)
+		return
+	}
+
+	email, err := mail.ParseAddress(cp.Email)
+	if err != nil {
+		c.Error(errs.New(http.StatusBadRequest, "parseAddress: %s", err))
+		return
+	}
+
+	ip := c.ClientIP()
+
+	//the current password is guessed here just like at login, so it is throttled the same way.
+	wait, err := h.userBus.CheckLogin(ctx, *email, ip)
+	if errors.Is(err, bus.ErrLoginLocked) {
+		c.Header("Retry-After", retryAfter(wait))
+		c.Error(errs.New(http.StatusTooManyRequests, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "checkLogin: %s", err))
+		return
+	}
+
+	usr, err := h.userBus.ChangePassword(ctx, *email, cp.Password, cp.NewPassword, uuid.Nil)
+	if errors.Is(err, bus.ErrInvalidCredentials) {
+		if err := h.userBus.LoginFailed(ctx, *email, ip); err != nil {
+			h.log.Error(ctx, "loginFailed", "err", err.Error())
+		}
+
+		c.Error(errs.New(http.StatusUnauthorized, "%s", bus.ErrInvalidCredentials))
+		return
+	}
+
+	if errors.Is(err, password.ErrWeakPassword) || errors.Is(err, bus.ErrPasswordReused) {
+		c.Error(errs.New(http.StatusBadRequest, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "changePassword: %s", err))
+		return
+	}
+
+	//the password alone does not log in users with mfa, so it can not clear the wrong codes either.
+	if usr.MFAEnabledAt == nil {
+		if err := h.userBus.LoginSucceeded(ctx, *email); err != nil {
+			h.log.Error(ctx, "loginSucceeded", "err", err.Error())
+		}
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *handler) VerifyEmail(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "user.handler.verifyEmail")
+	defer span.End()
+
+	token := c.Query("token")
+	if token == "" {
+		c.Error(errs.New(http.StatusBadRequest, "token is required"))
+		return
+	}
+
+	usr, err := h.userBus.VerifyEmail(ctx, token)
+	if errors.Is(err, bus.ErrInvalidToken) {
+		c.Error(errs.New(http.StatusBadRequest, "%s", bus.ErrInvalidToken))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "verifyEmail: %s", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, toAppUser(usr))
+}
+
+func (h *handler) ResendVerification(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "user.handler.resendVerification")
+	defer span.End()
+
+	val, ok := c.Get("user")
+	if !ok {
+		c.Error(errs.New(http.StatusUnauthorized, "%s", http.StatusText(http.StatusUnauthorized)))
+		return
+	}
+
+	usr, ok := val.(bus.User)
+	if !ok {
+		c.Error(errs.New(http.StatusUnauthorized, "%s", http.StatusText(http.StatusUnauthorized)))
+		return
+	}
+
+	token, err := h.userBus.CreateEmailVerification(ctx, usr, h.verifyTTL, h.verifyResend)
+	if errors.Is(err, bus.ErrEmailVerified) {
+		c.Error(errs.New(http.StatusBadRequest, "%s", err))
+		return
+	}
+
+	if errors.Is(err, bus.ErrTooManyRequests) {
+		c.Header("Retry-After", retryAfter(h.verifyResend))
+		c.Error(errs.New(http.StatusTooManyRequests, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "createEmailVerification: %s", err))
+		return
+	}
+
+	h.sendVerification(ctx, usr, token)
+	c.Status(http.StatusAccepted)
+}
+
+// ==============================================================================
+
+// generateToken starts a session for the client of the request and issues an access token for
+// it, amr lists the methods used to authenticate. In cookie mode the token is set as the session
+// cookie as well.
+func (h *handler) generateToken(ctx context.Context, c *gin.Context, usr bus.User, amr []string) (string, error) {
+	s, err := h.userBus.CreateSession(ctx, usr, c.Request.UserAgent(), c.ClientIP(), h.tokenMaxAge)
+	if err != nil {
+		return "", fmt.Errorf("createSession: %w", err)
+	}
+
+	return h.sessionToken(c, usr, s, amr)
+}
+
+// sessionToken issues a token for a session that already exists, it expires along with the
+// session and is also set in the session cookie when cookies are used.
+func (h *handler) sessionToken(c *gin.Context, usr bus.User, s bus.Session, amr []string) (string, error) {
+	return h.signSession(c, h.sessionClaims(usr, s, amr), s)
+}
+
+// sessionClaims returns the claims of a token for the session s.
+func (h *handler) sessionClaims(usr bus.User, s bus.Session, amr []string) auth.Claims {
+	return auth.Claims{
+		Roles:     bus.RolesToString(usr.Roles),
+		AMR:       amr,
+		Scope:     auth.ScopeAPI,
+		SessionID: s.ID.String(),
 		RegisteredClaims: jwt.RegisteredClaims{
 			Issuer:    h.issuer,
 			Subject:   usr.ID.String(),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(h.tokenMaxAge)),
+			ExpiresAt: jwt.NewNumericDate(s.ExpiresAt),
 		},
 	}
+}
 
+// signSession signs the claims of a token for the session s and sets the session cookie when
+// cookies are used.
+func (h *handler) signSession(c *gin.Context, claims auth.Claims, s bus.Session) (string, error) {
 	token, err := h.a.GenerateToken(h.kid, claims)
 	if err != nil {
-		c.Error(errs.New(http.StatusInternalServerError, "generateToken: %s", err))
+		return "", err
+	}
+
+	if h.cookies != nil {
+		if err := mid.SetSessionCookies(c, *h.cookies, token, time.Until(s.ExpiresAt)); err != nil {
+			return "", fmt.Errorf("setSessionCookies: %w", err)
+		}
+	}
+
+	return token, nil
+}
+
+// mfaChallenge issues the token that proves the first factor, amr, until the second one is sent
+// to "/login/mfa".
+func (h *handler) mfaChallenge(usr bus.User, amr []string) (string, error) {
+	now := time.Now()
+
+	claims := auth.Claims{
+		AMR:       amr,
+		TokenType: auth.TokenTypeMFAChallenge,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    h.issuer,
+			Subject:   usr.ID.String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(mfaChallengeTTL)),
+		},
+	}
+
+	return h.a.GenerateToken(h.kid, claims)
+}
+
+func (h *handler) sendVerification(ctx context.Context, usr bus.User, token string) {
+	var link string
+	if h.baseURL != "" {
+		link = h.baseURL + "/v1/users/verify?token=" + url.QueryEscape(token)
+	}
+
+	h.sendEmail(ctx, usr.Email, "email_verification", emailData{
+		Name:      usr.Name,
+		Token:     token,
+		Link:      link,
+		ExpiresIn: h.verifyTTL.String(),
+	})
+}
+
+// sendEmail renders the template and sends it in the background, failures are only logged.
+func (h *handler) sendEmail(ctx context.Context, to mail.Address, template string, data emailData) {
+	msg, err := mailer.Render(to, template, data)
+	if err != nil {
+		h.log.Error(ctx, "render email", "template", template, "err", err.Error())
 		return
 	}
 
-	t := Token{Token: token}
-	c.JSON(http.StatusOK, t)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Second*30)
+		defer cancel()
+
+		if err := h.mailer.Send(ctx, msg); err != nil {
+			h.log.Error(ctx, "send email", "template", template, "err", err.Error())
+		}
+	}()
 }
 
-// ==============================================================================
-func isAdmin(roles []bus.Role) bool {
-	return slices.Contains(roles, bus.RoleAdmin)
+// retryAfter formats a wait for the Retry-After header, in whole seconds rounded up.
+func retryAfter(wait time.Duration) string {
+	return fmt.Sprint(int64((wait + time.Second - 1) / time.Second))
+}
+
+// canGrant makes sure the roles grant nothing the authenticated user lacks, so assigning roles
+// can not hand out more access than they have. When it fails the error is already set on the
+// context.
+func (h *handler) canGrant(c *gin.Context, roles []string) bool {
+	ok, err := mid.CanGrant(c, h.roleBus, roles)
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "canGrant: %s", err))
+		return false
+	}
+
+	if !ok {
+		c.Error(errs.New(http.StatusForbidden, "roles can not grant permissions you do not have"))
+		return false
+	}
+
+	return true
+}
+
+// authorize checks if usr can take the action on the target user, when it can not the error is
+// already set on the context and false is returned.
+func (h *handler) authorize(c *gin.Context, usr bus.User, action string, target bus.User) bool {
+	ps, err := mid.Permissions(c, h.roleBus)
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "permissions: %s", err))
+		return false
+	}
+
+	sub := authz.Subject{
+		ID:          usr.ID,
+		Department:  usr.Department,
+		Permissions: ps,
+	}
+
+	res := authz.Resource{
+		Type:       authz.ResourceUser,
+		ID:         target.ID.String(),
+		OwnerID:    target.ID,
+		Department: target.Department,
+	}
+
+	//acting in an org, the target is only part of it when it is a member too.
+	if m, ok := mid.Membership(c); ok {
+		sub.OrgID = m.OrgID
+
+		_, err := h.orgBus.QueryMember(c.Request.Context(), m.OrgID, target.ID)
+		switch {
+		case err == nil:
+			res.OrgID = m.OrgID
+		case !errors.Is(err, orgBus.ErrMemberNotFound):
+			c.Error(errs.New(http.StatusInternalServerError, "queryMember: %s", err))
+			return false
+		}
+	}
+
+	err = h.authz.Authorize(c.Request.Context(), sub, action, res)
+	if errors.Is(err, authz.ErrDenied) {
+		c.Error(errs.New(http.StatusForbidden, "%s", authz.ErrDenied))
+		return false
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "authorize: %s", err))
+		return false
+	}
+
+	return true
+}
+
+// queryUser finds the user a request acts on, org scoped tokens only find the members of their org.
+func (h *handler) queryUser(ctx context.Context, c *gin.Context, id uuid.UUID) (bus.User, error) {
+	if m, ok := mid.Membership(c); ok {
+		return h.userBus.QueryByIDInOrg(ctx, m.OrgID, id)
+	}
+
+	return h.userBus.QueryByID(ctx, id)
 }
@@ -6,25 +6,42 @@ import (
 	"crypto/rand"
 	"crypto/rsa"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
 	"github.com/hamidoujand/jumble/internal/auth"
+	"github.com/hamidoujand/jumble/internal/authz"
 	"github.com/hamidoujand/jumble/internal/dbtest"
+	departmentBus "github.com/hamidoujand/jumble/internal/domains/department/bus"
+	"github.com/hamidoujand/jumble/internal/domains/department/store/departmentdb"
+	oauthBus "github.com/hamidoujand/jumble/internal/domains/oauth/bus"
+	"github.com/hamidoujand/jumble/internal/domains/oauth/store/oauthdb"
+	roleBus "github.com/hamidoujand/jumble/internal/domains/role/bus"
+	"github.com/hamidoujand/jumble/internal/domains/role/store/roledb"
+	serviceAccountBus "github.com/hamidoujand/jumble/internal/domains/serviceaccount/bus"
+	"github.com/hamidoujand/jumble/internal/domains/serviceaccount/store/serviceaccountdb"
 	"github.com/hamidoujand/jumble/internal/domains/user/bus"
 	"github.com/hamidoujand/jumble/internal/domains/user/store/userdb"
 	"github.com/hamidoujand/jumble/internal/errs"
+	"github.com/hamidoujand/jumble/internal/mailer"
 	"github.com/hamidoujand/jumble/internal/mid"
+	"github.com/hamidoujand/jumble/pkg/cache"
 	"github.com/hamidoujand/jumble/pkg/docker"
 	"github.com/hamidoujand/jumble/pkg/logger"
 	"github.com/hamidoujand/jumble/pkg/telemetry"
+	"github.com/hamidoujand/jumble/pkg/totp"
 	"go.opentelemetry.io/otel"
 )
 
@@ -86,6 +103,20 @@ func Test_CreateUser(t *testing.T) {
 			isModelErr: true,
 			statusCode: http.StatusBadRequest,
 		},
+		{
+			name: "create_user_unknown_department",
+			newUser: newUser{
+				Name:            "Jane Doe",
+				Email:           "jane@doe.com",
+				Roles:           []string{"user"},
+				Department:      "loading",
+				Password:        "test1234",
+				PasswordConfirm: "test1234",
+			},
+			expectErr:  true,
+			isModelErr: false,
+			statusCode: http.StatusBadRequest,
+		},
 		{
 			name: "create_user_duplicated_email",
 			newUser: newUser{
@@ -147,10 +178,7 @@ func Test_CreateUser(t *testing.T) {
 
 					expectedFailedFields := []string{
 						"name",
-						"department",
 						"email",
-						"password",
-						"passwordConfirm",
 					}
 
 					for _, field := range expectedFailedFields {
@@ -178,12 +206,10 @@ func Test_UpdateUser(t *testing.T) {
 		{
 			name: "update_user_200",
 			updates: updateUser{
-				Name:            newPointer("Jane Doe"),
-				Email:           newPointer("jane@doe.com"),
-				Deaprtment:      newPointer("marketing"),
-				Password:        newPointer("1234test"),
-				PasswordConfirm: newPointer("1234test"),
-				Enabled:         newPointer(false),
+				Name:       newPointer("Jane Doe"),
+				Email:      newPointer("jane@doe.com"),
+				Deaprtment: newPointer("marketing"),
+				Enabled:    newPointer(false),
 			},
 			expectErr:  false,
 			isModelErr: false,
@@ -192,12 +218,10 @@ func Test_UpdateUser(t *testing.T) {
 		{
 			name: "update_user_400",
 			updates: updateUser{
-				Name:            newPointer("Ja"),
-				Email:           newPointer("janedoe.com"),
-				Deaprtment:      newPointer("mark"),
-				Password:        newPointer("test"),
-				PasswordConfirm: newPointer("14tesst"),
-				Enabled:         newPointer(false),
+				Name:       newPointer("Ja"),
+				Email:      newPointer("janedoe.com"),
+				Deaprtment: newPointer("mark"),
+				Enabled:    newPointer(false),
 			},
 			expectErr:  true,
 			isModelErr: true,
@@ -288,10 +312,7 @@ func Test_UpdateUser(t *testing.T) {
 
 					expectedFailedFields := []string{
 						"name",
-						"department",
 						"email",
-						"password",
-						"passwordConfirm",
 					}
 
 					for _, field := range expectedFailedFields {
@@ -352,10 +373,16 @@ func Test_Query(t *testing.T) {
 			statusCode:         http.StatusOK,
 			isOrderBy:          true,
 		},
+		{
+			//Will only reads the users of marketing, the filter can not widen that.
+			name:               "fetch_own_department",
+			query:              "/v1/department/users?department=sales",
+			expectedNumRecords: 2,
+			statusCode:         http.StatusOK,
+		},
 	}
 
 	setup := setupPerTest(t)
-	setup.router.GET("/v1/users", setup.h.Query)
 	//seed the db
 	users := []newUser{
 		{
@@ -392,18 +419,33 @@ func Test_Query(t *testing.T) {
 		},
 	}
 
+	var will bus.User
 	for _, usr := range users {
 		busUser, err := toBusNewUser(usr)
 		if err != nil {
 			t.Fatalf("toBusUser: %s", err)
 		}
 
-		_, err = setup.userBus.Create(context.Background(), busUser)
+		created, err := setup.userBus.Create(context.Background(), busUser)
 		if err != nil {
 			t.Fatalf("create: %s", err)
 		}
+
+		if created.Email.Address == "will@doe.com" {
+			will = created
+		}
+	}
+
+	as := func(perm string) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			c.Set("user", will)
+			c.Set("permissions", roleBus.PermissionSet{perm: {}})
+		}
 	}
 
+	setup.router.GET("/v1/users", as(roleBus.PermUsersRead), setup.h.Query)
+	setup.router.GET("/v1/department/users", as(roleBus.PermUsersDepartmentRead), setup.h.Query)
+
 	for _, ts := range tests {
 		t.Run(ts.name, func(t *testing.T) {
 			r := httptest.NewRequest(http.MethodGet, ts.query, nil)
@@ -439,12 +481,13 @@ func Test_Query(t *testing.T) {
 // =============================================================================
 
 type setup struct {
-	h       handler
-	userBus *bus.Bus
-	router  *gin.Engine
+	h                 handler
+	userBus           *bus.Bus
+	serviceAccountBus *serviceAccountBus.Bus
+	router            *gin.Engine
 }
 
-func setupPerTest(t *testing.T) setup {
+func setupPerTest(t *testing.T, opts ...bus.Option) setup {
 	db := dbtest.New(t, container, "create_user_api")
 	cfg := telemetry.Config{
 		ServiceName: "user_bus_test",
@@ -459,24 +502,38 @@ func setupPerTest(t *testing.T) setup {
 
 	tracer := otel.Tracer("user_handlers_tests")
 	usrStore := userdb.NewStore(db, tracer)
-	usrBus := bus.New(usrStore)
+	opts = append([]bus.Option{bus.WithDepartmentValidator(departmentBus.New(departmentdb.NewStore(db, tracer)))}, opts...)
+	usrBus := bus.New(usrStore, opts...)
 
 	ks := newKeyStore(t)
 	issuer := "jumple_tests"
-	a := auth.New(ks, usrBus, issuer)
+	a := auth.New(ks, issuer, auth.WithAudience("jumble_tests"))
 	kid := uuid.NewString()
 
 	var output bytes.Buffer
 	fn := func(_ context.Context) string { return "0000000000000000000000000000000" }
 	logger := logger.New(&output, logger.LevelDebug, "handler_test", fn)
 
+	rlBus := roleBus.New(roledb.NewStore(db, tracer))
+
 	h := handler{
-		userBus:     usrBus,
-		a:           a,
-		kid:         kid,
-		issuer:      issuer,
-		tokenMaxAge: time.Minute,
-		tracer:      tracer,
+		userBus:       usrBus,
+		roleBus:       rlBus,
+		authz:         authz.New(logger, authz.DefaultPolicies),
+		a:             a,
+		kid:           kid,
+		issuer:        issuer,
+		tokenMaxAge:   time.Minute,
+		resetTokenTTL: time.Minute,
+		verifyTTL:     time.Minute,
+		verifyResend:  time.Minute,
+		mailer:        &mailer.Memory{},
+		tracer:        tracer,
+		log:           logger,
+		//caching would answer the revoked session case from the first lookup.
+		introspections: cache.New[introspection](0, maxIntrospections),
+		authConf:       mid.AuthConf{Log: logger, Auth: a, UserBus: usrBus, RoleBus: rlBus},
+		oauthBus:       oauthBus.New(oauthdb.NewStore(db, tracer)),
 	}
 
 	router := gin.New()
@@ -489,9 +546,738 @@ func setupPerTest(t *testing.T) setup {
 	})
 
 	return setup{
-		h:       h,
-		userBus: usrBus,
-		router:  router,
+		h:                 h,
+		userBus:           usrBus,
+		serviceAccountBus: serviceAccountBus.New(serviceaccountdb.NewStore(db, tracer), serviceAccountBus.WithRoleValidator(rlBus)),
+		router:            router,
+	}
+}
+
+func Test_Me(t *testing.T) {
+	t.Parallel()
+	gin.SetMode(gin.TestMode)
+
+	setup := setupPerTest(t)
+
+	busUser, err := toBusNewUser(newUser{
+		Name:            "John Doe",
+		Email:           "john@doe.com",
+		Roles:           []string{"user"},
+		Department:      "sales",
+		Password:        "test1234",
+		PasswordConfirm: "test1234",
+	})
+	if err != nil {
+		t.Fatalf("failed toBusNewUser: %s", err)
+	}
+
+	created, err := setup.userBus.Create(context.Background(), busUser)
+	if err != nil {
+		t.Fatalf("failed to create new user: %s", err)
+	}
+
+	current, err := setup.userBus.CreateSession(context.Background(), created, "Firefox", "127.0.0.1", time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create a session: %s", err)
+	}
+
+	if _, err := setup.userBus.CreateSession(context.Background(), created, "Chrome", "127.0.0.2", time.Hour); err != nil {
+		t.Fatalf("failed to create a session: %s", err)
+	}
+
+	//an org scoped token of a login with a second factor.
+	orgID := uuid.New()
+	amr := []string{auth.AMRPassword, auth.AMROTP, auth.AMRMFA}
+
+	setup.router.Use(func(c *gin.Context) {
+		c.Set("user", created)
+		c.Set("session", current)
+		c.Set("claims", auth.Claims{Org: orgID.String(), AMR: amr, SessionID: current.ID.String()})
+	})
+
+	setup.router.GET("/v1/users/me", setup.h.QueryMe)
+	setup.router.PATCH("/v1/users/me", setup.h.UpdateMe)
+	setup.router.PUT("/v1/users/me/password", setup.h.ChangeMyPassword)
+	setup.router.DELETE("/v1/users/me", setup.h.DeleteMe)
+
+	send := func(method string, p string, body any) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		if body != nil {
+			if err := json.NewEncoder(&buf).Encode(body); err != nil {
+				t.Fatalf("failed to encode body to json: %s", err)
+			}
+		}
+
+		r := httptest.NewRequest(method, p, &buf)
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		setup.router.ServeHTTP(w, r)
+		return w
+	}
+
+	w := send(http.MethodGet, "/v1/users/me", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d, got=%d", http.StatusOK, w.Code)
+	}
+
+	var me user
+	if err := json.NewDecoder(w.Body).Decode(&me); err != nil {
+		t.Fatalf("failed to decode user from response: %s", err)
+	}
+
+	if me.ID != created.ID.String() {
+		t.Errorf("id=%s, got=%s", created.ID, me.ID)
+	}
+
+	w = send(http.MethodPatch, "/v1/users/me", updateMe{Name: newPointer("Jane Doe")})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d, got=%d", http.StatusOK, w.Code)
+	}
+
+	if err := json.NewDecoder(w.Body).Decode(&me); err != nil {
+		t.Fatalf("failed to decode user from response: %s", err)
+	}
+
+	if me.Name != "Jane Doe" || me.Department != "sales" {
+		t.Errorf("expected only the name to change, got=%+v", me)
+	}
+
+	w = send(http.MethodPut, "/v1/users/me/password", changeMyPassword{Password: "wrong1234", NewPassword: "1234test", NewPasswordConfirm: "1234test"})
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status=%d, got=%d", http.StatusUnauthorized, w.Code)
+	}
+
+	w = send(http.MethodPut, "/v1/users/me/password", changeMyPassword{Password: "test1234", NewPassword: "1234test", NewPasswordConfirm: "1234test"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d, got=%d", http.StatusOK, w.Code)
+	}
+
+	var tk Token
+	if err := json.NewDecoder(w.Body).Decode(&tk); err != nil || tk.Token == "" {
+		t.Fatalf("expected a new token, got=%+v, err=%v", tk, err)
+	}
+
+	claims, err := setup.h.a.ParseToken(context.Background(), tk.Token)
+	if err != nil {
+		t.Fatalf("failed to parse the new token: %s", err)
+	}
+
+	if claims.SessionID != current.ID.String() {
+		t.Errorf("expected the new token to keep the session, sid=%s, got=%s", current.ID, claims.SessionID)
+	}
+
+	if claims.Org != orgID.String() {
+		t.Errorf("expected the new token to stay in the org, org=%s, got=%s", orgID, claims.Org)
+	}
+
+	if !slices.Equal(claims.AMR, amr) {
+		t.Errorf("amr=%v, got=%v", amr, claims.AMR)
+	}
+
+	ss, err := setup.userBus.QuerySessions(context.Background(), created)
+	if err != nil {
+		t.Fatalf("failed to query sessions: %s", err)
+	}
+
+	if len(ss) != 1 || ss[0].ID != current.ID {
+		t.Errorf("expected the other sessions to be revoked, got=%+v", ss)
+	}
+
+	w = send(http.MethodDelete, "/v1/users/me", nil)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("status=%d, got=%d", http.StatusNoContent, w.Code)
+	}
+
+	if _, err := setup.userBus.QueryByID(context.Background(), created.ID); !errors.Is(err, bus.ErrUserNotFound) {
+		t.Errorf("err=%s, got=%v", bus.ErrUserNotFound, err)
+	}
+}
+
+func Test_MFALockout(t *testing.T) {
+	t.Parallel()
+	gin.SetMode(gin.TestMode)
+
+	setup := setupPerTest(t, bus.WithLockoutPolicy(bus.LockoutPolicy{
+		MaxFailures:   3,
+		MaxIPFailures: 100,
+		Window:        time.Minute,
+		Lockout:       time.Minute,
+	}))
+
+	busUser, err := toBusNewUser(newUser{
+		Name:            "John Doe",
+		Email:           "john@doe.com",
+		Roles:           []string{"user"},
+		Department:      "sales",
+		Password:        "test1234",
+		PasswordConfirm: "test1234",
+	})
+	if err != nil {
+		t.Fatalf("failed toBusNewUser: %s", err)
+	}
+
+	created, err := setup.userBus.Create(context.Background(), busUser)
+	if err != nil {
+		t.Fatalf("failed to create new user: %s", err)
+	}
+
+	enrolled, err := setup.userBus.EnrollMFA(context.Background(), created)
+	if err != nil {
+		t.Fatalf("failed to enroll mfa: %s", err)
+	}
+
+	code, err := totp.Code(enrolled.MFASecret, totp.Counter(time.Now()))
+	if err != nil {
+		t.Fatalf("failed to generate code: %s", err)
+	}
+
+	if _, _, err := setup.userBus.ConfirmMFA(context.Background(), enrolled, code); err != nil {
+		t.Fatalf("failed to confirm mfa: %s", err)
+	}
+
+	setup.router.POST("/v1/users/login", setup.h.Authenticate)
+	setup.router.POST("/v1/users/login/mfa", setup.h.AuthenticateMFA)
+
+	send := func(p string, body any) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatalf("failed to encode body to json: %s", err)
+		}
+
+		r := httptest.NewRequest(http.MethodPost, p, &buf)
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		setup.router.ServeHTTP(w, r)
+		return w
+	}
+
+	login := func() (*httptest.ResponseRecorder, string) {
+		w := send("/v1/users/login", authenticate{Email: "john@doe.com", Password: "test1234", PasswordConfirm: "test1234"})
+		if w.Code != http.StatusOK {
+			return w, ""
+		}
+
+		var mc mfaChallenge
+		if err := json.NewDecoder(w.Body).Decode(&mc); err != nil {
+			t.Fatalf("failed to decode challenge: %s", err)
+		}
+
+		return w, mc.Challenge
+	}
+
+	//logging in with the right password between wrong codes must not reset the count.
+	for range 3 {
+		w, challenge := login()
+		if w.Code != http.StatusOK || challenge == "" {
+			t.Fatalf("expected an mfa challenge, status=%d", w.Code)
+		}
+
+		w = send("/v1/users/login/mfa", loginMFA{Challenge: challenge, Code: "000000"})
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("status=%d, got=%d", http.StatusUnauthorized, w.Code)
+		}
+	}
+
+	if w, _ := login(); w.Code != http.StatusTooManyRequests {
+		t.Errorf("expected the account to be locked after wrong codes, status=%d, got=%d", http.StatusTooManyRequests, w.Code)
+	}
+}
+
+func Test_Impersonate(t *testing.T) {
+	t.Parallel()
+	gin.SetMode(gin.TestMode)
+
+	setup := setupPerTest(t)
+
+	newRole := func(name string, perms ...string) {
+		if _, err := setup.h.roleBus.Create(context.Background(), roleBus.NewRole{Name: name, Permissions: perms}); err != nil {
+			t.Fatalf("failed to create role %s: %s", name, err)
+		}
+	}
+
+	newRole("support", roleBus.PermUsersImpersonate, roleBus.PermUsersRead)
+	newRole("billing", roleBus.PermUsersRead, roleBus.PermOAuthClientsWrite)
+
+	create := func(email string, role string) bus.User {
+		busUser, err := toBusNewUser(newUser{
+			Name:            "John Doe",
+			Email:           email,
+			Roles:           []string{role},
+			Department:      "sales",
+			Password:        "test1234",
+			PasswordConfirm: "test1234",
+		})
+		if err != nil {
+			t.Fatalf("failed toBusNewUser: %s", err)
+		}
+
+		usr, err := setup.userBus.Create(context.Background(), busUser)
+		if err != nil {
+			t.Fatalf("failed to create new user: %s", err)
+		}
+
+		return usr
+	}
+
+	support := create("support@doe.com", "support")
+	billing := create("billing@doe.com", "billing")
+	usr := create("john@doe.com", "user")
+
+	setup.router.Use(func(c *gin.Context) {
+		c.Set("user", support)
+	})
+
+	setup.router.POST("/v1/users/:id/impersonate", setup.h.Impersonate)
+
+	tests := []struct {
+		name       string
+		target     bus.User
+		statusCode int
+	}{
+		{
+			//billing grants a permission support does not have.
+			name:       "greater_permissions",
+			target:     billing,
+			statusCode: http.StatusForbidden,
+		},
+		{
+			name:       "lesser_permissions",
+			target:     usr,
+			statusCode: http.StatusOK,
+		},
+	}
+
+	for _, ts := range tests {
+		t.Run(ts.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/v1/users/"+ts.target.ID.String()+"/impersonate", nil)
+			w := httptest.NewRecorder()
+
+			setup.router.ServeHTTP(w, r)
+
+			if w.Code != ts.statusCode {
+				t.Errorf("status=%d, got=%d", ts.statusCode, w.Code)
+			}
+		})
+	}
+}
+
+func Test_UpdateRole(t *testing.T) {
+	t.Parallel()
+	gin.SetMode(gin.TestMode)
+
+	setup := setupPerTest(t)
+
+	newRole := func(name string, perms ...string) {
+		if _, err := setup.h.roleBus.Create(context.Background(), roleBus.NewRole{Name: name, Permissions: perms}); err != nil {
+			t.Fatalf("failed to create role %s: %s", name, err)
+		}
+	}
+
+	newRole("manager", roleBus.PermUsersRolesAssign, roleBus.PermUsersRead)
+	newRole("reader", roleBus.PermUsersRead)
+	newRole("billing", roleBus.PermUsersRead, roleBus.PermOAuthClientsWrite)
+
+	create := func(email string, role string) bus.User {
+		busUser, err := toBusNewUser(newUser{
+			Name:            "John Doe",
+			Email:           email,
+			Roles:           []string{role},
+			Department:      "sales",
+			Password:        "test1234",
+			PasswordConfirm: "test1234",
+		})
+		if err != nil {
+			t.Fatalf("failed toBusNewUser: %s", err)
+		}
+
+		usr, err := setup.userBus.Create(context.Background(), busUser)
+		if err != nil {
+			t.Fatalf("failed to create new user: %s", err)
+		}
+
+		return usr
+	}
+
+	manager := create("manager@doe.com", "manager")
+	usr := create("john@doe.com", "user")
+
+	setup.router.Use(func(c *gin.Context) {
+		c.Set("user", manager)
+	})
+
+	setup.router.PUT("/v1/users/roles/:id", setup.h.UpdateRole)
+
+	tests := []struct {
+		name       string
+		roles      []string
+		statusCode int
+	}{
+		{
+			name:       "grant_admin",
+			roles:      []string{"admin"},
+			statusCode: http.StatusForbidden,
+		},
+		{
+			//billing grants a permission the manager does not have.
+			name:       "greater_permissions",
+			roles:      []string{"user", "billing"},
+			statusCode: http.StatusForbidden,
+		},
+		{
+			name:       "lesser_permissions",
+			roles:      []string{"user", "reader"},
+			statusCode: http.StatusOK,
+		},
+	}
+
+	for _, ts := range tests {
+		t.Run(ts.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := json.NewEncoder(&buf).Encode(updateUserRoles{Roles: ts.roles}); err != nil {
+				t.Fatalf("failed to encode roles to json: %s", err)
+			}
+
+			r := httptest.NewRequest(http.MethodPut, "/v1/users/roles/"+usr.ID.String(), &buf)
+			w := httptest.NewRecorder()
+
+			r.Header.Set("Content-Type", "application/json")
+
+			setup.router.ServeHTTP(w, r)
+
+			if w.Code != ts.statusCode {
+				t.Errorf("status=%d, got=%d", ts.statusCode, w.Code)
+			}
+		})
+	}
+
+	got, err := setup.userBus.QueryByID(context.Background(), usr.ID)
+	if err != nil {
+		t.Fatalf("failed to query user: %s", err)
+	}
+
+	roles := bus.RolesToString(got.Roles)
+	if !slices.Equal(roles, []string{"user", "reader"}) {
+		t.Errorf("roles=%v, got=%v", []string{"user", "reader"}, roles)
+	}
+}
+
+func Test_Introspect(t *testing.T) {
+	t.Parallel()
+	gin.SetMode(gin.TestMode)
+
+	setup := setupPerTest(t)
+	ctx := context.Background()
+
+	busUser, err := toBusNewUser(newUser{
+		Name:            "John Doe",
+		Email:           "john@doe.com",
+		Roles:           []string{"user"},
+		Department:      "sales",
+		Password:        "test1234",
+		PasswordConfirm: "test1234",
+	})
+	if err != nil {
+		t.Fatalf("failed toBusNewUser: %s", err)
+	}
+
+	usr, err := setup.userBus.Create(ctx, busUser)
+	if err != nil {
+		t.Fatalf("failed to create new user: %s", err)
+	}
+
+	if _, err := setup.h.roleBus.Create(ctx, roleBus.NewRole{Name: "resource-server", Permissions: []string{roleBus.PermTokensIntrospect}}); err != nil {
+		t.Fatalf("failed to create role: %s", err)
+	}
+
+	sa, err := setup.serviceAccountBus.Create(ctx, serviceAccountBus.NewServiceAccount{Name: "api", Roles: []string{"resource-server"}})
+	if err != nil {
+		t.Fatalf("failed to create service account: %s", err)
+	}
+
+	_, key, err := setup.serviceAccountBus.CreateKey(ctx, sa, serviceAccountBus.NewAPIKey{Name: "introspect", Scopes: []string{roleBus.PermTokensIntrospect}})
+	if err != nil {
+		t.Fatalf("failed to create api key: %s", err)
+	}
+
+	_, readOnlyKey, err := setup.serviceAccountBus.CreateKey(ctx, sa, serviceAccountBus.NewAPIKey{Name: "read", Scopes: []string{roleBus.PermUsersRead}})
+	if err != nil {
+		t.Fatalf("failed to create api key: %s", err)
+	}
+
+	newSession := func() bus.Session {
+		s, err := setup.userBus.CreateSession(ctx, usr, "test", "127.0.0.1", time.Hour)
+		if err != nil {
+			t.Fatalf("failed to create session: %s", err)
+		}
+
+		return s
+	}
+
+	newToken := func(sessionID string, expiresAt time.Time, aud ...string) string {
+		token, err := setup.h.a.GenerateToken(setup.h.kid, auth.Claims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   usr.ID.String(),
+				Audience:  aud,
+				IssuedAt:  jwt.NewNumericDate(time.Now()),
+				ExpiresAt: jwt.NewNumericDate(expiresAt),
+			},
+			Roles:     []string{"user"},
+			SessionID: sessionID,
+		})
+		if err != nil {
+			t.Fatalf("failed to generate token: %s", err)
+		}
+
+		return token
+	}
+
+	newActor := func(email string, roles ...string) bus.User {
+		busActor, err := toBusNewUser(newUser{
+			Name:            "Jane Doe",
+			Email:           email,
+			Roles:           append([]string{"user"}, roles...),
+			Department:      "sales",
+			Password:        "test1234",
+			PasswordConfirm: "test1234",
+		})
+		if err != nil {
+			t.Fatalf("failed toBusNewUser: %s", err)
+		}
+
+		actor, err := setup.userBus.Create(ctx, busActor)
+		if err != nil {
+			t.Fatalf("failed to create new user: %s", err)
+		}
+
+		return actor
+	}
+
+	newImpersonationToken := func(actor bus.User) string {
+		token, err := setup.h.a.GenerateToken(setup.h.kid, auth.Claims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   usr.ID.String(),
+				IssuedAt:  jwt.NewNumericDate(time.Now()),
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			},
+			Roles: []string{"user"},
+			Act:   &auth.Actor{Subject: actor.ID.String()},
+		})
+		if err != nil {
+			t.Fatalf("failed to generate token: %s", err)
+		}
+
+		return token
+	}
+
+	newClientToken := func(client oauthBus.Client, scope string) string {
+		token, err := setup.h.a.GenerateToken(setup.h.kid, auth.Claims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   client.ID.String(),
+				IssuedAt:  jwt.NewNumericDate(time.Now()),
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			},
+			Scope:    scope,
+			ClientID: client.ID.String(),
+		})
+		if err != nil {
+			t.Fatalf("failed to generate token: %s", err)
+		}
+
+		return token
+	}
+
+	if _, err := setup.h.roleBus.Create(ctx, roleBus.NewRole{Name: "support", Permissions: []string{roleBus.PermUsersImpersonate}}); err != nil {
+		t.Fatalf("failed to create role: %s", err)
+	}
+
+	support := newActor("support@doe.com", "support")
+	//without the permission the impersonation ends, even though the token was issued.
+	formerSupport := newActor("former@doe.com")
+
+	newClient := func(name string, grants ...string) oauthBus.Client {
+		client, _, err := setup.h.oauthBus.CreateClient(ctx, oauthBus.NewClient{
+			Name:         name,
+			GrantTypes:   grants,
+			Scopes:       []string{"reports"},
+			Confidential: true,
+		})
+		if err != nil {
+			t.Fatalf("failed to create client: %s", err)
+		}
+
+		return client
+	}
+
+	client := newClient("reports", oauthBus.GrantClientCredentials)
+	deleted := newClient("deleted", oauthBus.GrantClientCredentials)
+	deletedToken := newClientToken(deleted, "reports")
+	if err := setup.h.oauthBus.DeleteClient(ctx, deleted); err != nil {
+		t.Fatalf("failed to delete client: %s", err)
+	}
+
+	active := newSession()
+	revoked := newSession()
+	if err := setup.userBus.RevokeSession(ctx, revoked, usr.ID, "127.0.0.1"); err != nil {
+		t.Fatalf("failed to revoke session: %s", err)
+	}
+
+	userToken := newToken(active.ID.String(), time.Now().Add(time.Hour))
+
+	authenticated := mid.Authenticate(mid.AuthConf{
+		Log:               setup.h.log,
+		Auth:              setup.h.a,
+		UserBus:           setup.userBus,
+		RoleBus:           setup.h.roleBus,
+		ServiceAccountBus: setup.serviceAccountBus,
+	})
+
+	setup.router.POST("/v1/auth/introspect",
+		authenticated,
+		mid.ServiceAccountOnly(),
+		mid.RequirePermission(setup.h.log, setup.h.roleBus, roleBus.PermTokensIntrospect),
+		setup.h.Introspect,
+	)
+
+	tests := []struct {
+		name          string
+		token         string
+		apiKey        string
+		authorization string
+		statusCode    int
+		active        bool
+		subject       string
+	}{
+		{
+			name:       "active",
+			token:      userToken,
+			apiKey:     key,
+			statusCode: http.StatusOK,
+			active:     true,
+			subject:    usr.ID.String(),
+		},
+		{
+			name:       "impersonation",
+			token:      newImpersonationToken(support),
+			apiKey:     key,
+			statusCode: http.StatusOK,
+			active:     true,
+			subject:    usr.ID.String(),
+		},
+		{
+			name:       "revoked_impersonation",
+			token:      newImpersonationToken(formerSupport),
+			apiKey:     key,
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "client_credentials",
+			token:      newClientToken(client, "reports"),
+			apiKey:     key,
+			statusCode: http.StatusOK,
+			active:     true,
+			subject:    client.ID.String(),
+		},
+		{
+			name:       "deleted_client",
+			token:      deletedToken,
+			apiKey:     key,
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "scope_not_allowed",
+			token:      newClientToken(client, "admin"),
+			apiKey:     key,
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "revoked_session",
+			token:      newToken(revoked.ID.String(), time.Now().Add(time.Hour)),
+			apiKey:     key,
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "expired",
+			token:      newToken(active.ID.String(), time.Now().Add(-time.Minute)),
+			apiKey:     key,
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "foreign_audience",
+			token:      newToken(active.ID.String(), time.Now().Add(time.Hour), "other_service"),
+			apiKey:     key,
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "no_credentials",
+			token:      userToken,
+			statusCode: http.StatusUnauthorized,
+		},
+		{
+			name:       "invalid_api_key",
+			token:      userToken,
+			apiKey:     "jmb_000000000000_invalid",
+			statusCode: http.StatusUnauthorized,
+		},
+		{
+			//people check their own tokens by using them.
+			name:          "user_token",
+			token:         userToken,
+			authorization: "Bearer " + userToken,
+			statusCode:    http.StatusForbidden,
+		},
+		{
+			name:       "missing_permission",
+			token:      userToken,
+			apiKey:     readOnlyKey,
+			statusCode: http.StatusForbidden,
+		},
+	}
+
+	for _, ts := range tests {
+		t.Run(ts.name, func(t *testing.T) {
+			form := url.Values{"token": {ts.token}}
+
+			r := httptest.NewRequest(http.MethodPost, "/v1/auth/introspect", strings.NewReader(form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			if ts.apiKey != "" {
+				r.Header.Set("X-API-Key", ts.apiKey)
+			}
+
+			if ts.authorization != "" {
+				r.Header.Set("Authorization", ts.authorization)
+			}
+
+			w := httptest.NewRecorder()
+			setup.router.ServeHTTP(w, r)
+
+			if w.Code != ts.statusCode {
+				t.Fatalf("status=%d, got=%d: %s", ts.statusCode, w.Code, w.Body.String())
+			}
+
+			if ts.statusCode != http.StatusOK {
+				return
+			}
+
+			var res introspection
+			if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+				t.Fatalf("failed to decode response: %s", err)
+			}
+
+			if res.Active != ts.active {
+				t.Errorf("active=%t, got=%t", ts.active, res.Active)
+			}
+
+			if ts.active && res.Subject != ts.subject {
+				t.Errorf("subject=%s, got=%s", ts.subject, res.Subject)
+			}
+
+			if !ts.active && res.Subject != "" {
+				t.Errorf("expected inactive tokens to leave out the claims, got subject=%s", res.Subject)
+			}
+		})
 	}
 }
 
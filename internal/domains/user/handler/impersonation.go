@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/hamidoujand/jumble/internal/auth"
+	roleBus "github.com/hamidoujand/jumble/internal/domains/role/bus"
+	"github.com/hamidoujand/jumble/internal/domains/user/bus"
+	"github.com/hamidoujand/jumble/internal/errs"
+	"github.com/hamidoujand/jumble/internal/mid"
+)
+
+// Impersonate issues a short lived token for the user in the path that carries the admin in the
+// "act" claim, every request made with it is audited under both of them.
+func (h *handler) Impersonate(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "user.handler.impersonate")
+	defer span.End()
+
+	val, ok := c.Get("user")
+	if !ok {
+		c.Error(errs.New(http.StatusUnauthorized, "%s", http.StatusText(http.StatusUnauthorized)))
+		return
+	}
+
+	admin, ok := val.(bus.User)
+	if !ok {
+		c.Error(errs.New(http.StatusUnauthorized, "%s", http.StatusText(http.StatusUnauthorized)))
+		return
+	}
+
+	p := c.Param("id")
+	userID, err := uuid.Parse(p)
+	if err != nil {
+		c.Error(errs.New(http.StatusBadRequest, "invalid user id: %s", p))
+		return
+	}
+
+	usr, err := h.queryUser(ctx, c, userID)
+	if errors.Is(err, bus.ErrUserNotFound) {
+		c.Error(errs.New(http.StatusNotFound, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "queryByID: %s", err))
+		return
+	}
+
+	adminPerms, err := mid.Permissions(c, h.roleBus)
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "permissions: %s", err))
+		return
+	}
+
+	roles, usrPerms, err := h.impersonationRoles(ctx, c, usr)
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "impersonationRoles: %s", err))
+		return
+	}
+
+	err = h.userBus.Impersonate(ctx, admin, adminPerms, usr, usrPerms, c.ClientIP())
+	if errors.Is(err, bus.ErrImpersonateSelf) || errors.Is(err, bus.ErrImpersonateDisabled) {
+		c.Error(errs.New(http.StatusBadRequest, "%s", err))
+		return
+	}
+
+	if errors.Is(err, bus.ErrImpersonateAdmin) || errors.Is(err, bus.ErrImpersonateGreater) {
+		c.Error(errs.New(http.StatusForbidden, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "impersonate: %s", err))
+		return
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(h.impersonationTTL)
+
+	claims := auth.Claims{
+		Roles: roles,
+		Scope: auth.ScopeAPI,
+		Act:   &auth.Actor{Subject: admin.ID.String()},
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    h.issuer,
+			Subject:   usr.ID.String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	//in an org the impersonation stays in it.
+	if m, ok := mid.Membership(c); ok {
+		claims.Org = m.OrgID.String()
+	}
+
+	token, err := h.a.GenerateToken(h.kid, claims)
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "generateToken: %s", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, impersonationToken{
+		Token:     token,
+		ExpiresAt: expiresAt.Format(time.RFC3339),
+	})
+}
+
+// impersonationRoles returns the roles and permissions usr has where the impersonation happens, in
+// the org of the request when there is one.
+func (h *handler) impersonationRoles(ctx context.Context, c *gin.Context, usr bus.User) ([]string, roleBus.PermissionSet, error) {
+	m, ok := mid.Membership(c)
+	if !ok {
+		ps, err := mid.UserPermissions(ctx, h.roleBus, h.groupBus, usr)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return bus.RolesToString(usr.Roles), ps, nil
+	}
+
+	target, err := h.orgBus.QueryMember(ctx, m.OrgID, usr.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("queryMember: %w", err)
+	}
+
+	ps, err := h.roleBus.Permissions(ctx, target.Roles)
+	if err != nil {
+		return nil, nil, fmt.Errorf("permissions: %w", err)
+	}
+
+	return target.Roles, ps, nil
+}
@@ -0,0 +1,215 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+	"github.com/hamidoujand/jumble/internal/auth"
+	oauthBus "github.com/hamidoujand/jumble/internal/domains/oauth/bus"
+	orgBus "github.com/hamidoujand/jumble/internal/domains/org/bus"
+	"github.com/hamidoujand/jumble/internal/domains/user/bus"
+	"github.com/hamidoujand/jumble/internal/errs"
+	"github.com/hamidoujand/jumble/internal/mid"
+)
+
+// maxIntrospections caps how many introspection results are cached at once.
+const maxIntrospections = 10_000
+
+// Introspect tells other services whether an access token is still good, see RFC 7662. Tokens
+// that fail any check are reported as inactive instead of failing the request. Active results are
+// cached for a short while, so disabling a user or revoking a session can take that long to show
+// up here. Impersonation tokens are never cached, Authenticate re-checks the admin behind them on
+// every request.
+func (h *handler) Introspect(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "user.handler.introspect")
+	defer span.End()
+
+	var ir introspectionRequest
+	if err := c.ShouldBindWith(&ir, binding.Form); err != nil {
+		c.Error(err)
+		return
+	}
+
+	sum := sha256.Sum256([]byte(ir.Token))
+	key := hex.EncodeToString(sum[:])
+
+	c.Header("Cache-Control", "no-store")
+
+	if res, ok := h.introspections.Get(key); ok {
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	res, err := h.introspect(ctx, ir.Token)
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "introspect: %s", err))
+		return
+	}
+
+	if res.Active && res.Act == nil {
+		h.introspections.Set(key, res, time.Unix(res.Exp, 0))
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// introspect runs the checks Authenticate runs for each request, errors are only returned when
+// the answer is not known.
+func (h *handler) introspect(ctx context.Context, token string) (introspection, error) {
+	inactive := introspection{Active: false}
+
+	claims, err := h.a.ParseToken(ctx, token)
+	if err != nil || claims.TokenType != "" || claims.ExpiresAt == nil || claims.IssuedAt == nil {
+		return inactive, nil
+	}
+
+	res := introspection{
+		Active:    true,
+		Subject:   claims.Subject,
+		Scope:     claims.Scope,
+		ClientID:  claims.ClientID,
+		Issuer:    claims.Issuer,
+		Audience:  claims.Audience,
+		Org:       claims.Org,
+		SessionID: claims.SessionID,
+		Act:       claims.Act,
+		Exp:       claims.ExpiresAt.Unix(),
+		Iat:       claims.IssuedAt.Unix(),
+	}
+
+	//client credential tokens have the client as subject, there is no user behind them.
+	if claims.ClientID != "" && claims.Subject == claims.ClientID {
+		return h.introspectClient(ctx, claims, res)
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return inactive, nil
+	}
+
+	//org scoped tokens only find users and sessions in the org.
+	var orgID *uuid.UUID
+	if claims.Org != "" {
+		id, err := uuid.Parse(claims.Org)
+		if err != nil {
+			return inactive, nil
+		}
+		orgID = &id
+	}
+
+	var usr bus.User
+	if orgID != nil {
+		usr, err = h.userBus.QueryByIDInOrg(ctx, *orgID, userID)
+	} else {
+		usr, err = h.userBus.QueryByID(ctx, userID)
+	}
+
+	if errors.Is(err, bus.ErrUserNotFound) {
+		return inactive, nil
+	}
+
+	if err != nil {
+		return introspection{}, err
+	}
+
+	if !usr.Enabled || claims.IssuedAt.Before(usr.PasswordChangedAt.Truncate(time.Second)) {
+		return inactive, nil
+	}
+
+	if h.requireVerifiedEmail && usr.EmailVerifiedAt == nil {
+		return inactive, nil
+	}
+
+	if claims.SessionID != "" {
+		sessionID, err := uuid.Parse(claims.SessionID)
+		if err != nil {
+			return inactive, nil
+		}
+
+		var s bus.Session
+		if orgID != nil {
+			s, err = h.userBus.QuerySessionByIDInOrg(ctx, *orgID, usr.ID, sessionID)
+		} else {
+			s, err = h.userBus.QuerySessionByID(ctx, usr.ID, sessionID)
+		}
+
+		if errors.Is(err, bus.ErrSessionNotFound) {
+			return inactive, nil
+		}
+
+		if err != nil {
+			return introspection{}, err
+		}
+
+		if s.Check(time.Now()) != nil {
+			return inactive, nil
+		}
+	}
+
+	if claims.Act != nil {
+		_, err := mid.CheckImpersonation(ctx, h.authConf, claims.Act, usr, orgID)
+		if errors.Is(err, mid.ErrImpersonationDenied) {
+			return inactive, nil
+		}
+
+		if err != nil {
+			return introspection{}, err
+		}
+	}
+
+	//like Authenticate, roles are the current ones and not the ones in the token.
+	res.Roles = bus.RolesToString(usr.Roles)
+
+	if orgID != nil {
+		m, err := h.orgBus.QueryMember(ctx, *orgID, usr.ID)
+		if errors.Is(err, orgBus.ErrMemberNotFound) {
+			return inactive, nil
+		}
+
+		if err != nil {
+			return introspection{}, err
+		}
+
+		res.Roles = m.Roles
+	}
+
+	return res, nil
+}
+
+// introspectClient checks that the client behind a client credential token still exists and may
+// still use the grant, deleting the client or taking the grant away ends its tokens.
+func (h *handler) introspectClient(ctx context.Context, claims auth.Claims, res introspection) (introspection, error) {
+	inactive := introspection{Active: false}
+
+	if h.oauthBus == nil {
+		return inactive, nil
+	}
+
+	clientID, err := uuid.Parse(claims.ClientID)
+	if err != nil {
+		return inactive, nil
+	}
+
+	client, err := h.oauthBus.QueryClientByID(ctx, clientID)
+	if errors.Is(err, oauthBus.ErrClientNotFound) {
+		return inactive, nil
+	}
+
+	if err != nil {
+		return introspection{}, err
+	}
+
+	if !client.AllowsGrant(oauthBus.GrantClientCredentials) || !client.AllowsScopes(strings.Fields(claims.Scope)) {
+		return inactive, nil
+	}
+
+	return res, nil
+}
@@ -0,0 +1,212 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/hamidoujand/jumble/internal/auth"
+	departmentBus "github.com/hamidoujand/jumble/internal/domains/department/bus"
+	roleBus "github.com/hamidoujand/jumble/internal/domains/role/bus"
+	"github.com/hamidoujand/jumble/internal/domains/user/bus"
+	"github.com/hamidoujand/jumble/internal/errs"
+	"github.com/hamidoujand/jumble/internal/mid"
+	"github.com/hamidoujand/jumble/internal/password"
+)
+
+// maxInviteTTL caps the lifetime an admin can pick for a single invitation.
+const maxInviteTTL = 30 * 24 * time.Hour
+
+func (h *handler) CreateInvitation(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "user.handler.createInvitation")
+	defer span.End()
+
+	val, ok := c.Get("user")
+	if !ok {
+		c.Error(errs.New(http.StatusUnauthorized, "%s", http.StatusText(http.StatusUnauthorized)))
+		return
+	}
+
+	admin, ok := val.(bus.User)
+	if !ok {
+		c.Error(errs.New(http.StatusUnauthorized, "%s", http.StatusText(http.StatusUnauthorized)))
+		return
+	}
+
+	var ni newInvitation
+	if err := c.ShouldBindJSON(&ni); err != nil {
+		c.Error(err)
+		return
+	}
+
+	ttl := h.inviteTTL
+	if ni.ExpiresIn != "" {
+		d, err := time.ParseDuration(ni.ExpiresIn)
+		if err != nil || d <= 0 || d > maxInviteTTL {
+			c.Error(errs.New(http.StatusBadRequest, "expiresIn must be a duration between 0 and %s", maxInviteTTL))
+			return
+		}
+		ttl = d
+	}
+
+	busInv, err := toBusNewInvitation(ni, admin.ID, ttl)
+	if err != nil {
+		c.Error(errs.New(http.StatusBadRequest, "toBusNewInvitation: %s", err))
+		return
+	}
+
+	//inviting someone as a plain user only needs the invite permission, anything more is handing
+	//out roles.
+	for _, role := range busInv.Roles {
+		if role == bus.RoleUser {
+			continue
+		}
+
+		ps, err := mid.Permissions(c, h.roleBus)
+		if err != nil {
+			c.Error(errs.New(http.StatusInternalServerError, "permissions: %s", err))
+			return
+		}
+
+		if !ps.Has(roleBus.PermUsersRolesAssign) {
+			c.Error(errs.New(http.StatusForbidden, "assigning roles needs the %s permission", roleBus.PermUsersRolesAssign))
+			return
+		}
+
+		if !h.canGrant(c, bus.RolesToString(busInv.Roles)) {
+			return
+		}
+
+		break
+	}
+
+	inv, token, err := h.userBus.CreateInvitation(ctx, busInv)
+	if errors.Is(err, bus.ErrDuplicatedEmail) || errors.Is(err, roleBus.ErrRoleNotFound) ||
+		errors.Is(err, departmentBus.ErrDepartmentNotFound) {
+		c.Error(errs.New(http.StatusBadRequest, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "createInvitation: %s", err))
+		return
+	}
+
+	var link string
+	if h.inviteURL != "" {
+		link = h.inviteURL + "?token=" + url.QueryEscape(token)
+	}
+
+	//the token only ever goes to the invitee's inbox, accepting it proves they own the address.
+	h.sendEmail(ctx, inv.Email, "invitation", emailData{
+		Name:      inv.Email.Address,
+		Token:     token,
+		Link:      link,
+		ExpiresIn: ttl.String(),
+	})
+
+	c.JSON(http.StatusCreated, toAppInvitation(inv))
+}
+
+func (h *handler) QueryInvitations(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "user.handler.queryInvitations")
+	defer span.End()
+
+	invs, err := h.userBus.QueryPendingInvitations(ctx)
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "queryPendingInvitations: %s", err))
+		return
+	}
+
+	apps := make([]invitation, len(invs))
+	for i, inv := range invs {
+		apps[i] = toAppInvitation(inv)
+	}
+
+	c.JSON(http.StatusOK, apps)
+}
+
+func (h *handler) RevokeInvitation(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "user.handler.revokeInvitation")
+	defer span.End()
+
+	val, ok := c.Get("user")
+	if !ok {
+		c.Error(errs.New(http.StatusUnauthorized, "%s", http.StatusText(http.StatusUnauthorized)))
+		return
+	}
+
+	admin, ok := val.(bus.User)
+	if !ok {
+		c.Error(errs.New(http.StatusUnauthorized, "%s", http.StatusText(http.StatusUnauthorized)))
+		return
+	}
+
+	p := c.Param("id")
+	invID, err := uuid.Parse(p)
+	if err != nil {
+		c.Error(errs.New(http.StatusBadRequest, "invalid invitation id: %s", p))
+		return
+	}
+
+	inv, err := h.userBus.QueryInvitationByID(ctx, invID)
+	if errors.Is(err, bus.ErrInvitationNotFound) {
+		c.Error(errs.New(http.StatusNotFound, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "queryInvitationByID: %s", err))
+		return
+	}
+
+	if err := h.userBus.RevokeInvitation(ctx, admin.ID, inv); err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "revokeInvitation: %s", err))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// AcceptInvitation creates the account of the invitee and logs them in.
+func (h *handler) AcceptInvitation(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "user.handler.acceptInvitation")
+	defer span.End()
+
+	var ai acceptInvitation
+	if err := c.ShouldBindJSON(&ai); err != nil {
+		c.Error(err)
+		return
+	}
+
+	usr, err := h.userBus.AcceptInvitation(ctx, ai.Token, ai.Name, ai.Password)
+	if errors.Is(err, bus.ErrInvalidToken) {
+		c.Error(errs.New(http.StatusBadRequest, "%s", bus.ErrInvalidToken))
+		return
+	}
+
+	//roles or the department may have been deleted since the invitation was sent.
+	if errors.Is(err, bus.ErrDuplicatedEmail) || errors.Is(err, password.ErrWeakPassword) ||
+		errors.Is(err, roleBus.ErrRoleNotFound) || errors.Is(err, departmentBus.ErrDepartmentNotFound) {
+		c.Error(errs.New(http.StatusBadRequest, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "acceptInvitation: %s", err))
+		return
+	}
+
+	token, err := h.generateToken(ctx, c, usr, []string{auth.AMRPassword})
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "generateToken: %s", err))
+		return
+	}
+
+	appUser := toAppUser(usr)
+	appUser.Token = token
+	c.JSON(http.StatusCreated, appUser)
+}
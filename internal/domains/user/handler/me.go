@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hamidoujand/jumble/internal/auth"
+	"github.com/hamidoujand/jumble/internal/domains/user/bus"
+	"github.com/hamidoujand/jumble/internal/errs"
+	"github.com/hamidoujand/jumble/internal/mid"
+	"github.com/hamidoujand/jumble/internal/password"
+)
+
+// The "me" endpoints act on the authenticated user, clients do not need the id from the token and
+// no policy is checked since users always own themselves.
+
+func (h *handler) QueryMe(c *gin.Context) {
+	_, span := h.tracer.Start(c.Request.Context(), "user.handler.queryMe")
+	defer span.End()
+
+	usr, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, toAppUser(usr))
+}
+
+// UpdateMe updates the profile of the current user, the department and whether the account is
+// enabled are left to admins.
+func (h *handler) UpdateMe(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "user.handler.updateMe")
+	defer span.End()
+
+	usr, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var um updateMe
+	if err := c.ShouldBindJSON(&um); err != nil {
+		c.Error(err)
+		return
+	}
+
+	busUserUpdate, err := toBusUpdateMe(um)
+	if err != nil {
+		c.Error(errs.New(http.StatusBadRequest, "toBusUpdateMe: %s", err))
+		return
+	}
+
+	updated, err := h.userBus.Update(ctx, usr, busUserUpdate)
+	if errors.Is(err, bus.ErrDuplicatedEmail) {
+		c.Error(errs.New(http.StatusBadRequest, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "update: %s", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, toAppUser(updated))
+}
+
+// ChangeMyPassword sets a new password for the current user once the current one is confirmed.
+// Changing it revokes every token issued before along with the other sessions, so a new token is
+// handed out for the session of this client.
+func (h *handler) ChangeMyPassword(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "user.handler.changeMyPassword")
+	defer span.End()
+
+	usr, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var cp changeMyPassword
+	if err := c.ShouldBindJSON(&cp); err != nil {
+		c.Error(err)
+		return
+	}
+
+	ip := c.ClientIP()
+
+	//a stolen token should not allow guessing the password any faster than the login does.
+	wait, err := h.userBus.CheckLogin(ctx, usr.Email, ip)
+	if errors.Is(err, bus.ErrLoginLocked) {
+		c.Header("Retry-After", retryAfter(wait))
+		c.Error(errs.New(http.StatusTooManyRequests, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "checkLogin: %s", err))
+		return
+	}
+
+	//tokens without a session keep none, the client gets a new one below.
+	current, hasSession := mid.Session(c)
+
+	updated, err := h.userBus.ChangePassword(ctx, usr.Email, cp.Password, cp.NewPassword, current.ID)
+	if errors.Is(err, bus.ErrInvalidCredentials) {
+		if err := h.userBus.LoginFailed(ctx, usr.Email, ip); err != nil {
+			h.log.Error(ctx, "loginFailed", "err", err.Error())
+		}
+
+		c.Error(errs.New(http.StatusUnauthorized, "%s", bus.ErrInvalidCredentials))
+		return
+	}
+
+	if errors.Is(err, password.ErrWeakPassword) || errors.Is(err, bus.ErrPasswordReused) {
+		c.Error(errs.New(http.StatusBadRequest, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "changePassword: %s", err))
+		return
+	}
+
+	if err := h.userBus.LoginSucceeded(ctx, usr.Email); err != nil {
+		h.log.Error(ctx, "loginSucceeded", "err", err.Error())
+	}
+
+	var token string
+	if hasSession {
+		//the new token stands in for the current one, it stays in the same org and keeps the
+		//methods the user logged in with so a second factor is not lost.
+		claims := h.sessionClaims(updated, current, []string{auth.AMRPassword})
+		if cur, ok := currentClaims(c); ok {
+			if len(cur.AMR) > 0 {
+				claims.AMR = cur.AMR
+			}
+			claims.Org = cur.Org
+		}
+
+		if m, ok := mid.Membership(c); ok {
+			claims.Roles = m.Roles
+		}
+
+		token, err = h.signSession(c, claims, current)
+	} else {
+		token, err = h.generateToken(ctx, c, updated, []string{auth.AMRPassword})
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "generateToken: %s", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, Token{Token: token})
+}
+
+func (h *handler) DeleteMe(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "user.handler.deleteMe")
+	defer span.End()
+
+	usr, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if err := h.userBus.Delete(ctx, usr); err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "delete: %s", err))
+		return
+	}
+
+	if h.cookies != nil {
+		mid.ClearSessionCookies(c, *h.cookies)
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// currentUser returns the user set by Authenticate, when it is missing the error is already set
+// on the context and false is returned.
+// currentClaims returns the claims of the token the request was authenticated with.
+func currentClaims(c *gin.Context) (auth.Claims, bool) {
+	val, ok := c.Get("claims")
+	if !ok {
+		return auth.Claims{}, false
+	}
+
+	claims, ok := val.(auth.Claims)
+	return claims, ok
+}
+
+func currentUser(c *gin.Context) (bus.User, bool) {
+	val, ok := c.Get("user")
+	if !ok {
+		c.Error(errs.New(http.StatusUnauthorized, "%s", http.StatusText(http.StatusUnauthorized)))
+		return bus.User{}, false
+	}
+
+	usr, ok := val.(bus.User)
+	if !ok {
+		c.Error(errs.New(http.StatusUnauthorized, "%s", http.StatusText(http.StatusUnauthorized)))
+		return bus.User{}, false
+	}
+
+	return usr, true
+}
@@ -5,31 +5,41 @@ import (
 	"net/mail"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/hamidoujand/jumble/internal/auth"
 	"github.com/hamidoujand/jumble/internal/domains/user/bus"
+	"github.com/hamidoujand/jumble/internal/webauthn"
 )
 
 type user struct {
-	ID         string   `json:"id"`
-	Name       string   `json:"name"`
-	Email      string   `json:"email"`
-	Roles      []string `json:"roles"`
-	Department string   `json:"department"`
-	Enabled    bool     `json:"enabled"`
-	CreatedAt  string   `json:"createdAt"`
-	UpdatedAt  string   `json:"updatedAt"`
-	Token      string   `json:"token,omitempty"`
+	ID              string   `json:"id"`
+	Name            string   `json:"name"`
+	Email           string   `json:"email"`
+	EmailVerifiedAt string   `json:"emailVerifiedAt,omitempty"`
+	Roles           []string `json:"roles"`
+	Department      string   `json:"department"`
+	Enabled         bool     `json:"enabled"`
+	CreatedAt       string   `json:"createdAt"`
+	UpdatedAt       string   `json:"updatedAt"`
+	Token           string   `json:"token,omitempty"`
 }
 
 func toAppUser(usr bus.User) user {
+	var verifiedAt string
+	if usr.EmailVerifiedAt != nil {
+		verifiedAt = usr.EmailVerifiedAt.Format(time.RFC3339)
+	}
+
 	return user{
-		ID:         usr.ID.String(),
-		Name:       usr.Name,
-		Email:      usr.Email.Address,
-		Roles:      bus.RolesToString(usr.Roles),
-		Department: usr.Department,
-		Enabled:    usr.Enabled,
-		CreatedAt:  usr.CreatedAt.Format(time.RFC3339),
-		UpdatedAt:  usr.UpdatedAt.Format(time.RFC3339),
+		ID:              usr.ID.String(),
+		Name:            usr.Name,
+		Email:           usr.Email.Address,
+		EmailVerifiedAt: verifiedAt,
+		Roles:           bus.RolesToString(usr.Roles),
+		Department:      usr.Department,
+		Enabled:         usr.Enabled,
+		CreatedAt:       usr.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:       usr.UpdatedAt.Format(time.RFC3339),
 	}
 }
 
@@ -62,13 +72,26 @@ type Token struct {
 	Token string `json:"token"`
 }
 
+type mfaChallenge struct {
+	MFARequired bool   `json:"mfaRequired"`
+	Challenge   string `json:"challenge"`
+}
+
+type loginMFA struct {
+	Challenge    string `json:"challenge" binding:"required"`
+	Code         string `json:"code" binding:"required_without=RecoveryCode"`
+	RecoveryCode string `json:"recoveryCode" binding:"required_without=Code"`
+}
+
 //==============================================================================
 
+// newUser is used to sign up, anyone can do that so the only role one can ask for is "user", the
+// others are assigned later by users with the "users:roles:assign" permission.
 type newUser struct {
 	Name            string   `json:"name" binding:"required,min=4"`
 	Email           string   `json:"email" binding:"required,email"`
-	Roles           []string `json:"roles" binding:"gt=0,dive,required,oneof=admin user"`
-	Department      string   `json:"department" binding:"required,oneof=sales shipping marketing"`
+	Roles           []string `json:"roles" binding:"gt=0,dive,required,oneof=user"`
+	Department      string   `json:"department" binding:"required,max=100"`
 	Password        string   `json:"password" binding:"required,min=8,max=128"`
 	PasswordConfirm string   `json:"passwordConfirm" binding:"required,eqfield=Password"`
 }
@@ -94,34 +117,60 @@ func toBusNewUser(nu newUser) (bus.NewUser, error) {
 }
 
 // ==============================================================================
+// updateUser has no password, it is changed with the current one through the password endpoints
+// so a stolen token is not enough to take the account over.
 type updateUser struct {
-	Name            *string `json:"name" binding:"omitempty,min=4"`
-	Email           *string `json:"email" binding:"omitempty,email"`
-	Deaprtment      *string `json:"department" binding:"omitempty,oneof=sales shipping marketing"`
-	Password        *string `json:"password" binding:"omitempty,min=8,max=128"`
-	PasswordConfirm *string `json:"passwordConfirm" binding:"omitempty,eqfield=Password"`
-	Enabled         *bool   `json:"enabled"`
+	Name       *string `json:"name" binding:"omitempty,min=4"`
+	Email      *string `json:"email" binding:"omitempty,email"`
+	Deaprtment *string `json:"department" binding:"omitempty,max=100"`
+	Enabled    *bool   `json:"enabled"`
 }
 
 func toBusUpdateUser(uu updateUser) (bus.UpdateUser, error) {
-	email, err := mail.ParseAddress(*uu.Email)
-	if err != nil {
-		return bus.UpdateUser{}, fmt.Errorf("parseAddress: %w", err)
+	var email *mail.Address
+	if uu.Email != nil {
+		addr, err := mail.ParseAddress(*uu.Email)
+		if err != nil {
+			return bus.UpdateUser{}, fmt.Errorf("parseAddress: %w", err)
+		}
+		email = addr
 	}
 
 	return bus.UpdateUser{
 		Name:       uu.Name,
 		Email:      email,
 		Department: uu.Deaprtment,
-		Password:   uu.Password,
 		Enabled:    uu.Enabled,
 	}, nil
 }
 
 //==============================================================================
 
+type updateMe struct {
+	Name  *string `json:"name" binding:"omitempty,min=4"`
+	Email *string `json:"email" binding:"omitempty,email"`
+}
+
+func toBusUpdateMe(um updateMe) (bus.UpdateUser, error) {
+	var email *mail.Address
+	if um.Email != nil {
+		addr, err := mail.ParseAddress(*um.Email)
+		if err != nil {
+			return bus.UpdateUser{}, fmt.Errorf("parseAddress: %w", err)
+		}
+		email = addr
+	}
+
+	return bus.UpdateUser{
+		Name:  um.Name,
+		Email: email,
+	}, nil
+}
+
+//==============================================================================
+
 type updateUserRoles struct {
-	Roles []string `json:"roles" binding:"required,oneof=user admin"`
+	Roles []string `json:"roles" binding:"required,gt=0,dive,required,max=32"`
 }
 
 func toBusUpdateUserRoles(ur updateUserRoles) (bus.UpdateUser, error) {
@@ -132,3 +181,209 @@ func toBusUpdateUserRoles(ur updateUserRoles) (bus.UpdateUser, error) {
 
 	return bus.UpdateUser{Roles: roles}, nil
 }
+
+//==============================================================================
+
+type forgotPassword struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type resetPassword struct {
+	Token           string `json:"token" binding:"required"`
+	Password        string `json:"password" binding:"required,min=8,max=128"`
+	PasswordConfirm string `json:"passwordConfirm" binding:"required,eqfield=Password"`
+}
+
+type changePassword struct {
+	Email              string `json:"email" binding:"required,email"`
+	Password           string `json:"password" binding:"required"`
+	NewPassword        string `json:"newPassword" binding:"required,min=8,max=128"`
+	NewPasswordConfirm string `json:"newPasswordConfirm" binding:"required,eqfield=NewPassword"`
+}
+
+type changeMyPassword struct {
+	Password           string `json:"password" binding:"required"`
+	NewPassword        string `json:"newPassword" binding:"required,min=8,max=128"`
+	NewPasswordConfirm string `json:"newPasswordConfirm" binding:"required,eqfield=NewPassword"`
+}
+
+//==============================================================================
+
+type mfaEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type mfaCode struct {
+	Code string `json:"code" binding:"required,len=6,numeric"`
+}
+
+type recoveryCodes struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+//==============================================================================
+
+// newInvitation picks the roles and department of the account the invitee gets, ExpiresIn
+// overrides the default lifetime of the invitation, e.g. "24h".
+type newInvitation struct {
+	Email      string   `json:"email" binding:"required,email"`
+	Roles      []string `json:"roles" binding:"required,gt=0,dive,required,max=32"`
+	Department string   `json:"department" binding:"required,max=100"`
+	ExpiresIn  string   `json:"expiresIn"`
+}
+
+func toBusNewInvitation(ni newInvitation, invitedBy uuid.UUID, ttl time.Duration) (bus.NewInvitation, error) {
+	roles, err := bus.ParseManyRoles(ni.Roles)
+	if err != nil {
+		return bus.NewInvitation{}, fmt.Errorf("parseManyRoles: %w", err)
+	}
+
+	email, err := mail.ParseAddress(ni.Email)
+	if err != nil {
+		return bus.NewInvitation{}, fmt.Errorf("parseAddress: %w", err)
+	}
+
+	return bus.NewInvitation{
+		Email:      *email,
+		Roles:      roles,
+		Department: ni.Department,
+		InvitedBy:  invitedBy,
+		TTL:        ttl,
+	}, nil
+}
+
+type invitation struct {
+	ID         string   `json:"id"`
+	Email      string   `json:"email"`
+	Roles      []string `json:"roles"`
+	Department string   `json:"department"`
+	InvitedBy  string   `json:"invitedBy,omitempty"`
+	ExpiresAt  string   `json:"expiresAt"`
+	CreatedAt  string   `json:"createdAt"`
+}
+
+func toAppInvitation(inv bus.Invitation) invitation {
+	var invitedBy string
+	if inv.InvitedBy != uuid.Nil {
+		invitedBy = inv.InvitedBy.String()
+	}
+
+	return invitation{
+		ID:         inv.ID.String(),
+		Email:      inv.Email.Address,
+		Roles:      bus.RolesToString(inv.Roles),
+		Department: inv.Department,
+		InvitedBy:  invitedBy,
+		ExpiresAt:  inv.ExpiresAt.Format(time.RFC3339),
+		CreatedAt:  inv.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+type acceptInvitation struct {
+	Token           string `json:"token" binding:"required"`
+	Name            string `json:"name" binding:"required,min=4"`
+	Password        string `json:"password" binding:"required,min=8,max=128"`
+	PasswordConfirm string `json:"passwordConfirm" binding:"required,eqfield=Password"`
+}
+
+//==============================================================================
+
+// emailData is passed to all the email templates.
+type emailData struct {
+	Name      string
+	Token     string
+	Link      string
+	ExpiresIn string
+}
+
+//==============================================================================
+
+type publicKeyOptions struct {
+	PublicKey any `json:"publicKey"`
+}
+
+type registerPasskey struct {
+	Name       string            `json:"name" binding:"omitempty,max=100"`
+	Credential webauthn.Response `json:"credential" binding:"required"`
+}
+
+type loginPasskey struct {
+	Credential webauthn.Response `json:"credential" binding:"required"`
+}
+
+type passkey struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	LastUsedAt string `json:"lastUsedAt,omitempty"`
+	CreatedAt  string `json:"createdAt"`
+}
+
+func toAppPasskey(pk bus.Passkey) passkey {
+	var lastUsedAt string
+	if pk.LastUsedAt != nil {
+		lastUsedAt = pk.LastUsedAt.Format(time.RFC3339)
+	}
+
+	return passkey{
+		ID:         pk.ID.String(),
+		Name:       pk.Name,
+		LastUsedAt: lastUsedAt,
+		CreatedAt:  pk.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+//==============================================================================
+
+// introspectionRequest follows RFC 7662, the hint is accepted but only access tokens are known.
+type introspectionRequest struct {
+	Token         string `form:"token" binding:"required"`
+	TokenTypeHint string `form:"token_type_hint"`
+}
+
+// introspection is the RFC 7662 response, inactive tokens only get "active": false.
+type introspection struct {
+	Active    bool        `json:"active"`
+	Subject   string      `json:"sub,omitempty"`
+	Scope     string      `json:"scope,omitempty"`
+	ClientID  string      `json:"client_id,omitempty"`
+	Issuer    string      `json:"iss,omitempty"`
+	Audience  []string    `json:"aud,omitempty"`
+	Org       string      `json:"org,omitempty"`
+	SessionID string      `json:"sid,omitempty"`
+	Roles     []string    `json:"roles,omitempty"`
+	Act       *auth.Actor `json:"act,omitempty"`
+	Exp       int64       `json:"exp,omitempty"`
+	Iat       int64       `json:"iat,omitempty"`
+}
+
+//==============================================================================
+
+type impersonationToken struct {
+	Token     string `json:"token"`
+	ExpiresAt string `json:"expiresAt"`
+}
+
+//==============================================================================
+
+type session struct {
+	ID         string `json:"id"`
+	UserAgent  string `json:"userAgent"`
+	IP         string `json:"ip"`
+	Current    bool   `json:"current"`
+	LastSeenAt string `json:"lastSeenAt"`
+	ExpiresAt  string `json:"expiresAt"`
+	CreatedAt  string `json:"createdAt"`
+}
+
+func toAppSession(s bus.Session, current bool) session {
+	return session{
+		ID:         s.ID.String(),
+		UserAgent:  s.UserAgent,
+		IP:         s.IP,
+		Current:    current,
+		LastSeenAt: s.LastSeenAt.Format(time.RFC3339),
+		ExpiresAt:  s.ExpiresAt.Format(time.RFC3339),
+		CreatedAt:  s.CreatedAt.Format(time.RFC3339),
+	}
+}
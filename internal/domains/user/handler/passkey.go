@@ -0,0 +1,238 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/hamidoujand/jumble/internal/auth"
+	"github.com/hamidoujand/jumble/internal/domains/user/bus"
+	"github.com/hamidoujand/jumble/internal/errs"
+	"github.com/hamidoujand/jumble/internal/webauthn"
+)
+
+func (h *handler) BeginPasskeyRegistration(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "user.handler.beginPasskeyRegistration")
+	defer span.End()
+
+	val, ok := c.Get("user")
+	if !ok {
+		c.Error(errs.New(http.StatusUnauthorized, "%s", http.StatusText(http.StatusUnauthorized)))
+		return
+	}
+
+	usr, ok := val.(bus.User)
+	if !ok {
+		c.Error(errs.New(http.StatusUnauthorized, "%s", http.StatusText(http.StatusUnauthorized)))
+		return
+	}
+
+	existing, err := h.userBus.QueryPasskeys(ctx, usr.ID)
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "queryPasskeys: %s", err))
+		return
+	}
+
+	//stops the browser from registering the same authenticator twice.
+	exclude := make([][]byte, len(existing))
+	for i, pk := range existing {
+		exclude[i] = pk.CredentialID
+	}
+
+	challenge, err := h.userBus.CreatePasskeySession(ctx, usr.ID, bus.PurposePasskeyRegistration, h.webauthn.Timeout())
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "createPasskeySession: %s", err))
+		return
+	}
+
+	wu := webauthn.User{
+		ID:          usr.ID[:],
+		Name:        usr.Email.Address,
+		DisplayName: usr.Name,
+	}
+
+	c.JSON(http.StatusOK, publicKeyOptions{PublicKey: h.webauthn.CreationOptions(challenge, wu, exclude)})
+}
+
+func (h *handler) FinishPasskeyRegistration(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "user.handler.finishPasskeyRegistration")
+	defer span.End()
+
+	val, ok := c.Get("user")
+	if !ok {
+		c.Error(errs.New(http.StatusUnauthorized, "%s", http.StatusText(http.StatusUnauthorized)))
+		return
+	}
+
+	usr, ok := val.(bus.User)
+	if !ok {
+		c.Error(errs.New(http.StatusUnauthorized, "%s", http.StatusText(http.StatusUnauthorized)))
+		return
+	}
+
+	var rp registerPasskey
+	if err := c.ShouldBindJSON(&rp); err != nil {
+		c.Error(err)
+		return
+	}
+
+	challenge, err := rp.Credential.Challenge()
+	if err != nil {
+		c.Error(errs.New(http.StatusBadRequest, "%s", err))
+		return
+	}
+
+	session, err := h.userBus.ConsumePasskeySession(ctx, challenge, bus.PurposePasskeyRegistration)
+	if errors.Is(err, bus.ErrInvalidToken) {
+		c.Error(errs.New(http.StatusBadRequest, "%s", bus.ErrInvalidToken))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "consumePasskeySession: %s", err))
+		return
+	}
+
+	//the ceremony must have been started by the same user.
+	if session.UserID != usr.ID {
+		c.Error(errs.New(http.StatusBadRequest, "%s", bus.ErrInvalidToken))
+		return
+	}
+
+	cred, err := h.webauthn.VerifyRegistration(rp.Credential, session.Challenge)
+	if err != nil {
+		c.Error(errs.New(http.StatusBadRequest, "verifyRegistration: %s", err))
+		return
+	}
+
+	name := rp.Name
+	if name == "" {
+		name = "passkey"
+	}
+
+	pk, err := h.userBus.CreatePasskey(ctx, usr, bus.NewPasskey{
+		CredentialID: cred.ID,
+		PublicKey:    cred.PublicKey,
+		SignCount:    cred.SignCount,
+		Name:         name,
+	})
+	if errors.Is(err, bus.ErrDuplicatedPasskey) {
+		c.Error(errs.New(http.StatusBadRequest, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "createPasskey: %s", err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, toAppPasskey(pk))
+}
+
+func (h *handler) BeginPasskeyLogin(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "user.handler.beginPasskeyLogin")
+	defer span.End()
+
+	//passkeys are discoverable, the user is only known once the assertion comes back.
+	challenge, err := h.userBus.CreatePasskeySession(ctx, uuid.Nil, bus.PurposePasskeyLogin, h.webauthn.Timeout())
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "createPasskeySession: %s", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, publicKeyOptions{PublicKey: h.webauthn.RequestOptions(challenge, nil)})
+}
+
+func (h *handler) FinishPasskeyLogin(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "user.handler.finishPasskeyLogin")
+	defer span.End()
+
+	var lp loginPasskey
+	if err := c.ShouldBindJSON(&lp); err != nil {
+		c.Error(err)
+		return
+	}
+
+	challenge, err := lp.Credential.Challenge()
+	if err != nil {
+		c.Error(errs.New(http.StatusBadRequest, "%s", err))
+		return
+	}
+
+	session, err := h.userBus.ConsumePasskeySession(ctx, challenge, bus.PurposePasskeyLogin)
+	if errors.Is(err, bus.ErrInvalidToken) {
+		c.Error(errs.New(http.StatusUnauthorized, "%s", bus.ErrInvalidToken))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "consumePasskeySession: %s", err))
+		return
+	}
+
+	credID, err := lp.Credential.CredentialID()
+	if err != nil {
+		c.Error(errs.New(http.StatusBadRequest, "%s", err))
+		return
+	}
+
+	pk, err := h.userBus.QueryPasskeyByCredentialID(ctx, credID)
+	if errors.Is(err, bus.ErrPasskeyNotFound) {
+		c.Error(errs.New(http.StatusUnauthorized, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "queryPasskeyByCredentialID: %s", err))
+		return
+	}
+
+	//authenticators report the user handle given at registration, it must match the owner.
+	if handle, err := lp.Credential.UserHandle(); err != nil || (len(handle) > 0 && !bytes.Equal(handle, pk.UserID[:])) {
+		c.Error(errs.New(http.StatusUnauthorized, "user handle mismatch"))
+		return
+	}
+
+	cred := webauthn.Credential{
+		ID:        pk.CredentialID,
+		PublicKey: pk.PublicKey,
+		SignCount: pk.SignCount,
+	}
+
+	signCount, err := h.webauthn.VerifyAssertion(lp.Credential, session.Challenge, cred)
+	if err != nil {
+		if errors.Is(err, webauthn.ErrSignCount) {
+			h.log.Warn(ctx, "passkey sign count did not increase", "passkeyID", pk.ID, "userID", pk.UserID)
+		}
+
+		c.Error(errs.New(http.StatusUnauthorized, "verifyAssertion: %s", err))
+		return
+	}
+
+	if _, err := h.userBus.UsePasskey(ctx, pk, signCount); err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "usePasskey: %s", err))
+		return
+	}
+
+	usr, err := h.userBus.QueryByID(ctx, pk.UserID)
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "queryByID: %s", err))
+		return
+	}
+
+	if !usr.Enabled {
+		c.Error(errs.New(http.StatusUnauthorized, "user is disabled"))
+		return
+	}
+
+	//user verification is required, so the passkey alone covers both factors.
+	token, err := h.generateToken(ctx, c, usr, []string{auth.AMRHardwareKey, auth.AMRMFA})
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "generateToken: %s", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, Token{Token: token})
+}
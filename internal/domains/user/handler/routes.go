@@ -5,48 +5,164 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/hamidoujand/jumble/internal/auth"
+	"github.com/hamidoujand/jumble/internal/authz"
+	oauthBus "github.com/hamidoujand/jumble/internal/domains/oauth/bus"
+	orgBus "github.com/hamidoujand/jumble/internal/domains/org/bus"
+	roleBus "github.com/hamidoujand/jumble/internal/domains/role/bus"
 	"github.com/hamidoujand/jumble/internal/domains/user/bus"
+	"github.com/hamidoujand/jumble/internal/mailer"
 	"github.com/hamidoujand/jumble/internal/mid"
+	"github.com/hamidoujand/jumble/internal/oidc"
+	"github.com/hamidoujand/jumble/internal/webauthn"
+	"github.com/hamidoujand/jumble/pkg/cache"
 	"github.com/hamidoujand/jumble/pkg/logger"
 	"go.opentelemetry.io/otel/trace"
 )
 
 type Conf struct {
-	Router      *gin.Engine
-	UserBus     *bus.Bus
-	Auth        *auth.Auth
-	Kid         string
-	Issuer      string
-	TokenMaxAge time.Duration
-	Tracer      trace.Tracer
-	Logger      *logger.Logger
+	Router        *gin.Engine
+	AuthConf      mid.AuthConf
+	UserBus       *bus.Bus
+	RoleBus       *roleBus.Bus
+	OrgBus        *orgBus.Bus
+	Authorizer    *authz.Authorizer
+	Auth          *auth.Auth
+	Kid           string
+	Issuer        string
+	TokenMaxAge   time.Duration
+	ResetTokenTTL time.Duration
+	Mailer        mailer.Mailer
+	WebAuthn      *webauthn.WebAuthn
+
+	//BaseURL is the public address of the service, used to build links in emails.
+	BaseURL string
+
+	//email verification settings, whether it is required is part of AuthConf.
+	VerifyTokenTTL       time.Duration
+	VerifyResendInterval time.Duration
+
+	//invitation settings, InviteURL is the page invitees land on, the token is added as a query.
+	InviteTTL time.Duration
+	InviteURL string
+
+	//DisableSignup turns off anonymous sign up, new accounts then only come through invitations.
+	DisableSignup bool
+
+	//Providers are the external identity providers users can log in with, the redirect URI to
+	//register at each is BaseURL + "/v1/auth/oidc/<name>/callback".
+	Providers []*oidc.Provider
+
+	//IntrospectionCacheTTL is how long introspection results are reused, zero turns caching off.
+	IntrospectionCacheTTL time.Duration
+
+	//ImpersonationTTL is the lifetime of the tokens admins get to act as other users.
+	ImpersonationTTL time.Duration
+
+	//OAuthBus checks the clients behind client credential tokens at introspection, without it
+	//those tokens are reported as inactive.
+	OAuthBus *oauthBus.Bus
+
+	Tracer trace.Tracer
+	Logger *logger.Logger
 }
 
 // RegisterRoutes takes the mux and register endpoints on it.
 func RegisterRoutes(cfg Conf) {
+	providers := make(map[string]*oidc.Provider, len(cfg.Providers))
+	for _, p := range cfg.Providers {
+		providers[p.Config().Name] = p
+	}
+
 	usr := handler{
-		userBus:     cfg.UserBus,
-		a:           cfg.Auth,
-		kid:         cfg.Kid,
-		issuer:      cfg.Issuer,
-		tokenMaxAge: cfg.TokenMaxAge,
-		tracer:      cfg.Tracer,
+		userBus:       cfg.UserBus,
+		roleBus:       cfg.RoleBus,
+		orgBus:        cfg.OrgBus,
+		groupBus:      cfg.AuthConf.GroupBus,
+		authz:         cfg.Authorizer,
+		a:             cfg.Auth,
+		kid:           cfg.Kid,
+		issuer:        cfg.Issuer,
+		tokenMaxAge:   cfg.TokenMaxAge,
+		resetTokenTTL: cfg.ResetTokenTTL,
+		verifyTTL:     cfg.VerifyTokenTTL,
+		verifyResend:  cfg.VerifyResendInterval,
+		inviteTTL:     cfg.InviteTTL,
+		inviteURL:     cfg.InviteURL,
+		mailer:        cfg.Mailer,
+		baseURL:       cfg.BaseURL,
+		webauthn:      cfg.WebAuthn,
+		providers:     providers,
+
+		requireVerifiedEmail: cfg.AuthConf.RequireVerifiedEmail,
+		introspections:       cache.New[introspection](cfg.IntrospectionCacheTTL, maxIntrospections),
+		impersonationTTL:     cfg.ImpersonationTTL,
+		cookies:              cfg.AuthConf.Cookies,
+		authConf:             cfg.AuthConf,
+		oauthBus:             cfg.OAuthBus,
+
+		tracer: cfg.Tracer,
+		log:    cfg.Logger,
 	}
 
 	users := cfg.Router.Group("/v1/users")
 
-	admin := mid.Authorized(usr.a, map[string]struct{}{bus.RoleAdmin.String(): {}})
-	user := mid.Authorized(usr.a, map[string]struct{}{bus.RoleUser.String(): {}})
-	adminOrUser := mid.Authorized(usr.a, map[string]struct{}{bus.RoleUser.String(): {}, bus.RoleUser.String(): {}})
+	authConf := cfg.AuthConf
+	authenticated := mid.Authenticate(authConf)
+
+	//unverified users still need to be able to ask for a new verification email.
+	authConf.RequireVerifiedEmail = false
+	authenticatedUnverified := mid.Authenticate(authConf)
+
+	//tokens issued to OAuth clients only reach the API when they were granted it.
+	api := mid.RequireScope(auth.ScopeAPI)
 
-	authenticated := mid.Authenticate(cfg.Logger, cfg.Auth, cfg.UserBus)
+	can := func(perms ...string) gin.HandlerFunc {
+		return mid.RequirePermission(cfg.Logger, cfg.RoleBus, perms...)
+	}
+
+	//who can read, update, delete or disable which user is decided by the authz policies. Global
+	//roles and lockouts are not org business, org admins manage member roles under /v1/orgs.
+	if !cfg.DisableSignup {
+		users.POST("/", usr.CreateUser)
+	}
 
-	users.POST("/", usr.CreateUser)
-	users.GET("/:id", usr.QueryUserByID, authenticated)
-	users.DELETE("/:id", usr.DeleteUser, authenticated, adminOrUser)
-	users.PUT("/:id", usr.UpdateUser, authenticated, user)
-	users.PUT("/roles/:id", usr.UpdateRole, authenticated, admin)
-	users.PUT("/disable/:id", usr.DisableUser, authenticated, adminOrUser)
-	users.GET("/", usr.Query)
+	users.POST("/invitations", authenticated, api, mid.GlobalScope(), can(roleBus.PermUsersInvite), usr.CreateInvitation)
+	users.GET("/invitations", authenticated, api, mid.GlobalScope(), can(roleBus.PermUsersInvite), usr.QueryInvitations)
+	users.DELETE("/invitations/:id", authenticated, api, mid.GlobalScope(), can(roleBus.PermUsersInvite), usr.RevokeInvitation)
+	users.POST("/invitations/accept", usr.AcceptInvitation)
+	users.GET("/:id", authenticated, api, usr.QueryUserByID)
+	users.DELETE("/:id", authenticated, api, mid.NotImpersonated(), usr.DeleteUser)
+	users.PUT("/:id", authenticated, api, mid.NotImpersonated(), usr.UpdateUser)
+	users.PUT("/roles/:id", authenticated, api, mid.GlobalScope(), mid.NotImpersonated(), can(roleBus.PermUsersRolesAssign), usr.UpdateRole)
+	users.PUT("/disable/:id", authenticated, api, usr.DisableUser)
+	users.PUT("/unlock/:id", authenticated, api, mid.GlobalScope(), can(roleBus.PermUsersUnlock), usr.UnlockUser)
+	users.GET("/", authenticated, api, usr.Query)
 	users.POST("/login", usr.Authenticate)
+	users.POST("/login/mfa", usr.AuthenticateMFA)
+	users.POST("/login/passkey/begin", usr.BeginPasskeyLogin)
+	users.POST("/login/passkey/finish", usr.FinishPasskeyLogin)
+	users.POST("/password/forgot", usr.ForgotPassword)
+	users.POST("/password/reset", usr.ResetPassword)
+	users.POST("/password/change", usr.ChangePassword)
+	users.GET("/verify", usr.VerifyEmail)
+	users.POST("/verify/resend", authenticatedUnverified, api, usr.ResendVerification)
+	users.POST("/mfa/enroll", authenticated, api, mid.NotImpersonated(), usr.EnrollMFA)
+	users.POST("/mfa/confirm", authenticated, api, mid.NotImpersonated(), usr.ConfirmMFA)
+	users.POST("/mfa/disable", authenticated, api, mid.NotImpersonated(), usr.DisableMFA)
+	users.POST("/passkeys/register/begin", authenticated, api, mid.NotImpersonated(), usr.BeginPasskeyRegistration)
+	users.POST("/passkeys/register/finish", authenticated, api, mid.NotImpersonated(), usr.FinishPasskeyRegistration)
+	users.GET("/me", authenticated, api, usr.QueryMe)
+	users.PATCH("/me", authenticated, api, mid.NotImpersonated(), usr.UpdateMe)
+	users.PUT("/me/password", authenticated, api, mid.NotImpersonated(), usr.ChangeMyPassword)
+	users.DELETE("/me", authenticated, api, mid.NotImpersonated(), usr.DeleteMe)
+	users.GET("/me/sessions", authenticated, api, usr.QuerySessions)
+	users.DELETE("/me/sessions/:sid", authenticated, api, mid.NotImpersonated(), usr.RevokeSession)
+	users.POST("/:id/impersonate", authenticated, api, mid.GlobalScope(), mid.NotImpersonated(), can(roleBus.PermUsersImpersonate), usr.Impersonate)
+
+	//introspection is for resource servers, people check their own tokens by using them.
+	cfg.Router.POST("/v1/auth/introspect", authenticated, api, mid.ServiceAccountOnly(), can(roleBus.PermTokensIntrospect), usr.Introspect)
+
+	federated := cfg.Router.Group("/v1/auth/oidc")
+	federated.GET("/:provider/login", usr.BeginFederatedLogin)
+	federated.GET("/:provider/callback", usr.FinishFederatedLogin)
 }
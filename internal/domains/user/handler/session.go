@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/hamidoujand/jumble/internal/domains/user/bus"
+	"github.com/hamidoujand/jumble/internal/errs"
+	"github.com/hamidoujand/jumble/internal/mid"
+)
+
+// QuerySessions lists where the current user is logged in, the session of the request is marked.
+func (h *handler) QuerySessions(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "user.handler.querySessions")
+	defer span.End()
+
+	val, ok := c.Get("user")
+	if !ok {
+		c.Error(errs.New(http.StatusUnauthorized, "%s", http.StatusText(http.StatusUnauthorized)))
+		return
+	}
+
+	usr, ok := val.(bus.User)
+	if !ok {
+		c.Error(errs.New(http.StatusUnauthorized, "%s", http.StatusText(http.StatusUnauthorized)))
+		return
+	}
+
+	ss, err := h.userBus.QuerySessions(ctx, usr)
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "querySessions: %s", err))
+		return
+	}
+
+	current, _ := mid.Session(c)
+
+	apps := make([]session, len(ss))
+	for i, s := range ss {
+		apps[i] = toAppSession(s, s.ID == current.ID)
+	}
+
+	c.JSON(http.StatusOK, apps)
+}
+
+// RevokeSession logs the current user out of one of their sessions, it can be the one of the
+// request.
+func (h *handler) RevokeSession(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "user.handler.revokeSession")
+	defer span.End()
+
+	val, ok := c.Get("user")
+	if !ok {
+		c.Error(errs.New(http.StatusUnauthorized, "%s", http.StatusText(http.StatusUnauthorized)))
+		return
+	}
+
+	usr, ok := val.(bus.User)
+	if !ok {
+		c.Error(errs.New(http.StatusUnauthorized, "%s", http.StatusText(http.StatusUnauthorized)))
+		return
+	}
+
+	p := c.Param("sid")
+	sessionID, err := uuid.Parse(p)
+	if err != nil {
+		c.Error(errs.New(http.StatusBadRequest, "invalid session id: %s", p))
+		return
+	}
+
+	s, err := h.userBus.QuerySessionByID(ctx, usr.ID, sessionID)
+	if errors.Is(err, bus.ErrSessionNotFound) {
+		c.Error(errs.New(http.StatusNotFound, "%s", err))
+		return
+	}
+
+	if err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "querySessionByID: %s", err))
+		return
+	}
+
+	if err := h.userBus.RevokeSession(ctx, s, usr.ID, c.ClientIP()); err != nil {
+		c.Error(errs.New(http.StatusInternalServerError, "revokeSession: %s", err))
+		return
+	}
+
+	//scripts can not remove HttpOnly cookies, revoking the current session is how browsers log out.
+	if current, ok := mid.Session(c); ok && current.ID == s.ID && h.cookies != nil {
+		mid.ClearSessionCookies(c, *h.cookies)
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,141 @@
+package userdb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	usrBus "github.com/hamidoujand/jumble/internal/domains/user/bus"
+)
+
+func (s *Store) CreateFederatedSession(ctx context.Context, fs usrBus.FederatedSession) error {
+	const q = `
+	INSERT INTO federated_sessions (state_hash,provider,nonce,code_verifier,expires_at,created_at)
+	VALUES (:state_hash,:provider,:nonce,:code_verifier,:expires_at,:created_at)
+	`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.createFederatedSession")
+	defer span.End()
+
+	if _, err := s.db.NamedExecContext(ctx, q, fromBusFederatedSession(fs)); err != nil {
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) DeleteFederatedSession(ctx context.Context, stateHash []byte, provider string) (usrBus.FederatedSession, error) {
+	data := map[string]any{
+		"state_hash": stateHash,
+		"provider":   provider,
+	}
+
+	//deleting while reading makes sure a state only finishes one login.
+	const q = `DELETE FROM federated_sessions WHERE state_hash = :state_hash AND provider = :provider RETURNING *`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.deleteFederatedSession")
+	defer span.End()
+
+	rows, err := s.db.NamedQueryContext(ctx, q, data)
+	if err != nil {
+		return usrBus.FederatedSession{}, fmt.Errorf("namedQueryContext: %w", err)
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		return usrBus.FederatedSession{}, usrBus.ErrInvalidToken
+	}
+
+	var fs federatedSession
+	if err := rows.StructScan(&fs); err != nil {
+		return usrBus.FederatedSession{}, fmt.Errorf("structScan: %w", err)
+	}
+
+	return toBusFederatedSession(fs), nil
+}
+
+func (s *Store) DeleteExpiredFederatedSessions(ctx context.Context, now time.Time) (int, error) {
+	data := map[string]any{
+		"now": now,
+	}
+
+	const q = `DELETE FROM federated_sessions WHERE expires_at < :now`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.deleteExpiredFederatedSessions")
+	defer span.End()
+
+	res, err := s.db.NamedExecContext(ctx, q, data)
+	if err != nil {
+		return 0, fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("rowsAffected: %w", err)
+	}
+
+	return int(n), nil
+}
+
+func (s *Store) CreateIdentity(ctx context.Context, id usrBus.Identity) error {
+	const q = `
+	INSERT INTO user_identities (provider,subject,user_id,email,last_login_at,created_at)
+	VALUES (:provider,:subject,:user_id,:email,:last_login_at,:created_at)
+	`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.createIdentity")
+	defer span.End()
+
+	if _, err := s.db.NamedExecContext(ctx, q, fromBusIdentity(id)); err != nil {
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) UpdateIdentity(ctx context.Context, id usrBus.Identity) error {
+	const q = `
+	UPDATE user_identities SET email = :email, last_login_at = :last_login_at
+	WHERE provider = :provider AND subject = :subject
+	`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.updateIdentity")
+	defer span.End()
+
+	if _, err := s.db.NamedExecContext(ctx, q, fromBusIdentity(id)); err != nil {
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) QueryIdentity(ctx context.Context, provider string, subject string) (usrBus.Identity, error) {
+	data := map[string]any{
+		"provider": provider,
+		"subject":  subject,
+	}
+
+	const q = `SELECT * FROM user_identities WHERE provider = :provider AND subject = :subject`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.queryIdentity")
+	defer span.End()
+
+	rows, err := s.db.NamedQueryContext(ctx, q, data)
+	if err != nil {
+		return usrBus.Identity{}, fmt.Errorf("namedQueryContext: %w", err)
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		return usrBus.Identity{}, usrBus.ErrIdentityNotFound
+	}
+
+	var id identity
+	if err := rows.StructScan(&id); err != nil {
+		return usrBus.Identity{}, fmt.Errorf("structScan: %w", err)
+	}
+
+	return toBusIdentity(id), nil
+}
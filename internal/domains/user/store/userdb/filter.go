@@ -9,7 +9,8 @@ import (
 )
 
 func applyFilters(filters usrbus.QueryFilter, data map[string]any, buf *bytes.Buffer) {
-	var whereClause []string
+	//deleted users wait for their purge, they are not listed anymore.
+	whereClause := []string{"deleted_at IS NULL"}
 
 	if filters.Name != nil {
 		//first add to sqlx data map
@@ -38,6 +39,22 @@ func applyFilters(filters usrbus.QueryFilter, data map[string]any, buf *bytes.Bu
 		whereClause = append(whereClause, "created_at <= :end_created_at")
 	}
 
+	if filters.OrgID != nil {
+		data["org_id"] = *filters.OrgID
+		whereClause = append(whereClause, "id IN (SELECT user_id FROM org_members WHERE org_id = :org_id)")
+	}
+
+	if filters.Groups != nil {
+		data["groups"] = filters.Groups
+		whereClause = append(whereClause, `id IN (
+		WITH RECURSIVE subgroups AS (
+			SELECT id FROM groups WHERE id = ANY(:groups)
+			UNION
+			SELECT g.id FROM groups g JOIN subgroups s ON g.parent_id = s.id
+		)
+		SELECT user_id FROM group_members WHERE group_id IN (SELECT id FROM subgroups))`)
+	}
+
 	//join all of them with " AND "
 	if len(whereClause) > 0 {
 		buf.WriteString(" WHERE ")
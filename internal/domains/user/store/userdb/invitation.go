@@ -0,0 +1,180 @@
+package userdb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	usrBus "github.com/hamidoujand/jumble/internal/domains/user/bus"
+)
+
+func (s *Store) CreateInvitation(ctx context.Context, inv usrBus.Invitation) error {
+	const q = `
+	INSERT INTO invitations (id,email,roles,department,token_hash,invited_by,expires_at,accepted_at,created_at)
+	VALUES (:id,:email,:roles,:department,:token_hash,:invited_by,:expires_at,:accepted_at,:created_at)
+	`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.createInvitation")
+	defer span.End()
+
+	if _, err := s.db.NamedExecContext(ctx, q, fromBusInvitation(inv)); err != nil {
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) AcceptInvitation(ctx context.Context, inv usrBus.Invitation, acceptedAt time.Time) error {
+	data := map[string]any{
+		"id":          inv.ID,
+		"accepted_at": acceptedAt,
+	}
+
+	//the "accepted_at IS NULL" makes sure an invitation is only accepted once.
+	const q = `UPDATE invitations SET accepted_at = :accepted_at WHERE id = :id AND accepted_at IS NULL`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.acceptInvitation")
+	defer span.End()
+
+	res, err := s.db.NamedExecContext(ctx, q, data)
+	if err != nil {
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rowsAffected: %w", err)
+	}
+
+	if n == 0 {
+		return usrBus.ErrInvalidToken
+	}
+
+	return nil
+}
+
+func (s *Store) DeleteInvitation(ctx context.Context, inv usrBus.Invitation) error {
+	data := map[string]any{
+		"id": inv.ID,
+	}
+
+	const q = `DELETE FROM invitations WHERE id = :id`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.deleteInvitation")
+	defer span.End()
+
+	if _, err := s.db.NamedExecContext(ctx, q, data); err != nil {
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) QueryInvitationByID(ctx context.Context, id uuid.UUID) (usrBus.Invitation, error) {
+	data := map[string]any{
+		"id": id,
+	}
+
+	const q = `SELECT * FROM invitations WHERE id = :id`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.queryInvitationByID")
+	defer span.End()
+
+	return s.queryInvitation(ctx, q, data)
+}
+
+func (s *Store) QueryInvitationByHash(ctx context.Context, hash []byte) (usrBus.Invitation, error) {
+	data := map[string]any{
+		"token_hash": hash,
+	}
+
+	const q = `SELECT * FROM invitations WHERE token_hash = :token_hash`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.queryInvitationByHash")
+	defer span.End()
+
+	return s.queryInvitation(ctx, q, data)
+}
+
+func (s *Store) QueryPendingInvitations(ctx context.Context, now time.Time) ([]usrBus.Invitation, error) {
+	data := map[string]any{
+		"now": now,
+	}
+
+	const q = `
+	SELECT * FROM invitations
+	WHERE accepted_at IS NULL AND expires_at > :now
+	ORDER BY created_at DESC
+	`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.queryPendingInvitations")
+	defer span.End()
+
+	rows, err := s.db.NamedQueryContext(ctx, q, data)
+	if err != nil {
+		return nil, fmt.Errorf("namedQueryContext: %w", err)
+	}
+
+	defer rows.Close()
+
+	var invs []usrBus.Invitation
+	for rows.Next() {
+		var inv invitation
+		if err := rows.StructScan(&inv); err != nil {
+			return nil, fmt.Errorf("structScan: %w", err)
+		}
+		invs = append(invs, toBusInvitation(inv))
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("preparing next row to scan: %w", err)
+	}
+
+	return invs, nil
+}
+
+func (s *Store) DeleteExpiredInvitations(ctx context.Context, now time.Time) (int, error) {
+	data := map[string]any{
+		"now": now,
+	}
+
+	const q = `DELETE FROM invitations WHERE accepted_at IS NULL AND expires_at < :now`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.deleteExpiredInvitations")
+	defer span.End()
+
+	res, err := s.db.NamedExecContext(ctx, q, data)
+	if err != nil {
+		return 0, fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("rowsAffected: %w", err)
+	}
+
+	return int(n), nil
+}
+
+// ==============================================================================
+
+func (s *Store) queryInvitation(ctx context.Context, q string, data map[string]any) (usrBus.Invitation, error) {
+	rows, err := s.db.NamedQueryContext(ctx, q, data)
+	if err != nil {
+		return usrBus.Invitation{}, fmt.Errorf("namedQueryContext: %w", err)
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		return usrBus.Invitation{}, usrBus.ErrInvitationNotFound
+	}
+
+	var inv invitation
+	if err := rows.StructScan(&inv); err != nil {
+		return usrBus.Invitation{}, fmt.Errorf("structScan: %w", err)
+	}
+
+	return toBusInvitation(inv), nil
+}
@@ -0,0 +1,106 @@
+package userdb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	usrBus "github.com/hamidoujand/jumble/internal/domains/user/bus"
+)
+
+func (s *Store) UseMFACounter(ctx context.Context, userID uuid.UUID, counter int64) error {
+	data := map[string]any{
+		"id":      userID,
+		"counter": counter,
+	}
+
+	//only moves forward, a code of an already used or older time step updates nothing.
+	const q = `UPDATE users SET mfa_last_counter = :counter WHERE id = :id AND mfa_last_counter < :counter`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.useMFACounter")
+	defer span.End()
+
+	res, err := s.db.NamedExecContext(ctx, q, data)
+	if err != nil {
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rowsAffected: %w", err)
+	}
+
+	if n == 0 {
+		return usrBus.ErrInvalidMFACode
+	}
+
+	return nil
+}
+
+func (s *Store) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codes []usrBus.RecoveryCode) error {
+	const del = `DELETE FROM user_recovery_codes WHERE user_id = :user_id`
+
+	const ins = `
+	INSERT INTO user_recovery_codes (id,user_id,code_hash,used_at,created_at)
+	VALUES (:id,:user_id,:code_hash,:used_at,:created_at)
+	`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.replaceRecoveryCodes")
+	defer span.End()
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginTxx: %w", err)
+	}
+
+	defer tx.Rollback()
+
+	if _, err := tx.NamedExecContext(ctx, del, map[string]any{"user_id": userID}); err != nil {
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	for _, rc := range codes {
+		if _, err := tx.NamedExecContext(ctx, ins, fromBusRecoveryCode(rc)); err != nil {
+			return fmt.Errorf("namedExecContext: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) UseRecoveryCode(ctx context.Context, userID uuid.UUID, hash []byte, usedAt time.Time) error {
+	data := map[string]any{
+		"user_id":   userID,
+		"code_hash": hash,
+		"used_at":   usedAt,
+	}
+
+	const q = `
+	UPDATE user_recovery_codes SET used_at = :used_at
+	WHERE user_id = :user_id AND code_hash = :code_hash AND used_at IS NULL
+	`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.useRecoveryCode")
+	defer span.End()
+
+	res, err := s.db.NamedExecContext(ctx, q, data)
+	if err != nil {
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rowsAffected: %w", err)
+	}
+
+	if n == 0 {
+		return usrBus.ErrInvalidMFACode
+	}
+
+	return nil
+}
@@ -10,18 +10,33 @@ import (
 )
 
 type user struct {
-	ID           uuid.UUID        `db:"id"`
-	Name         string           `db:"name"`
-	Email        string           `db:"email"`
-	Roles        usrBus.RoleSlice `db:"roles"`
-	PasswordHash []byte           `db:"password_hash"`
-	Department   sql.NullString   `db:"department"`
-	Enabled      bool             `db:"enabled"`
-	CreatedAt    time.Time        `db:"created_at"`
-	UpdatedAt    time.Time        `db:"updated_at"`
+	ID                uuid.UUID        `db:"id"`
+	Name              string           `db:"name"`
+	Email             string           `db:"email"`
+	Roles             usrBus.RoleSlice `db:"roles"`
+	PasswordHash      []byte           `db:"password_hash"`
+	Department        sql.NullString   `db:"department"`
+	Enabled           bool             `db:"enabled"`
+	EmailVerifiedAt   sql.NullTime     `db:"email_verified_at"`
+	PasswordChangedAt time.Time        `db:"password_changed_at"`
+	MFASecret         sql.NullString   `db:"mfa_secret"`
+	MFAEnabledAt      sql.NullTime     `db:"mfa_enabled_at"`
+	MFALastCounter    int64            `db:"mfa_last_counter"`
+	CreatedAt         time.Time        `db:"created_at"`
+	UpdatedAt         time.Time        `db:"updated_at"`
+	DeletedAt         sql.NullTime     `db:"deleted_at"`
 }
 
 func fromBusUser(usr usrBus.User) user {
+	var verifiedAt sql.NullTime
+	if usr.EmailVerifiedAt != nil {
+		verifiedAt = sql.NullTime{Time: *usr.EmailVerifiedAt, Valid: true}
+	}
+
+	var mfaEnabledAt sql.NullTime
+	if usr.MFAEnabledAt != nil {
+		mfaEnabledAt = sql.NullTime{Time: *usr.MFAEnabledAt, Valid: true}
+	}
 
 	return user{
 		ID:           usr.ID,
@@ -34,9 +49,13 @@ func fromBusUser(usr usrBus.User) user {
 			//When usr.Department is not empty: Valid becomes true, telling the database this field should be stored with the given string value
 			Valid: usr.Department != "",
 		},
-		Enabled:   usr.Enabled,
-		CreatedAt: usr.CreatedAt,
-		UpdatedAt: usr.UpdatedAt,
+		Enabled:           usr.Enabled,
+		EmailVerifiedAt:   verifiedAt,
+		PasswordChangedAt: usr.PasswordChangedAt,
+		MFASecret:         sql.NullString{String: usr.MFASecret, Valid: usr.MFASecret != ""},
+		MFAEnabledAt:      mfaEnabledAt,
+		CreatedAt:         usr.CreatedAt,
+		UpdatedAt:         usr.UpdatedAt,
 	}
 }
 
@@ -46,15 +65,375 @@ func toUserBus(usr user) usrBus.User {
 		Address: usr.Email,
 	}
 
+	var verifiedAt *time.Time
+	if usr.EmailVerifiedAt.Valid {
+		verifiedAt = &usr.EmailVerifiedAt.Time
+	}
+
+	var mfaEnabledAt *time.Time
+	if usr.MFAEnabledAt.Valid {
+		mfaEnabledAt = &usr.MFAEnabledAt.Time
+	}
+
 	return usrBus.User{
-		ID:           usr.ID,
-		Name:         usr.Name,
-		Email:        email,
-		Roles:        []usrBus.Role(usr.Roles),
-		PasswordHash: usr.PasswordHash,
-		Department:   usr.Department.String,
-		Enabled:      usr.Enabled,
-		CreatedAt:    usr.CreatedAt,
-		UpdatedAt:    usr.UpdatedAt,
+		ID:                usr.ID,
+		Name:              usr.Name,
+		Email:             email,
+		Roles:             []usrBus.Role(usr.Roles),
+		PasswordHash:      usr.PasswordHash,
+		Department:        usr.Department.String,
+		Enabled:           usr.Enabled,
+		EmailVerifiedAt:   verifiedAt,
+		PasswordChangedAt: usr.PasswordChangedAt,
+		MFASecret:         usr.MFASecret.String,
+		MFAEnabledAt:      mfaEnabledAt,
+		CreatedAt:         usr.CreatedAt,
+		UpdatedAt:         usr.UpdatedAt,
+	}
+}
+
+// ==============================================================================
+
+type token struct {
+	ID        uuid.UUID    `db:"id"`
+	UserID    uuid.UUID    `db:"user_id"`
+	Purpose   string       `db:"purpose"`
+	Hash      []byte       `db:"token_hash"`
+	ExpiresAt time.Time    `db:"expires_at"`
+	UsedAt    sql.NullTime `db:"used_at"`
+	CreatedAt time.Time    `db:"created_at"`
+}
+
+func fromBusToken(t usrBus.Token) token {
+	var usedAt sql.NullTime
+	if t.UsedAt != nil {
+		usedAt = sql.NullTime{Time: *t.UsedAt, Valid: true}
+	}
+
+	return token{
+		ID:        t.ID,
+		UserID:    t.UserID,
+		Purpose:   t.Purpose,
+		Hash:      t.Hash,
+		ExpiresAt: t.ExpiresAt,
+		UsedAt:    usedAt,
+		CreatedAt: t.CreatedAt,
+	}
+}
+
+func toBusToken(t token) usrBus.Token {
+	var usedAt *time.Time
+	if t.UsedAt.Valid {
+		usedAt = &t.UsedAt.Time
+	}
+
+	return usrBus.Token{
+		ID:        t.ID,
+		UserID:    t.UserID,
+		Purpose:   t.Purpose,
+		Hash:      t.Hash,
+		ExpiresAt: t.ExpiresAt,
+		UsedAt:    usedAt,
+		CreatedAt: t.CreatedAt,
+	}
+}
+
+// ==============================================================================
+
+type recoveryCode struct {
+	ID        uuid.UUID    `db:"id"`
+	UserID    uuid.UUID    `db:"user_id"`
+	Hash      []byte       `db:"code_hash"`
+	UsedAt    sql.NullTime `db:"used_at"`
+	CreatedAt time.Time    `db:"created_at"`
+}
+
+func fromBusRecoveryCode(rc usrBus.RecoveryCode) recoveryCode {
+	var usedAt sql.NullTime
+	if rc.UsedAt != nil {
+		usedAt = sql.NullTime{Time: *rc.UsedAt, Valid: true}
+	}
+
+	return recoveryCode{
+		ID:        rc.ID,
+		UserID:    rc.UserID,
+		Hash:      rc.Hash,
+		UsedAt:    usedAt,
+		CreatedAt: rc.CreatedAt,
+	}
+}
+
+// ==============================================================================
+
+type passkey struct {
+	ID           uuid.UUID    `db:"id"`
+	UserID       uuid.UUID    `db:"user_id"`
+	CredentialID []byte       `db:"credential_id"`
+	PublicKey    []byte       `db:"public_key"`
+	SignCount    int64        `db:"sign_count"`
+	Name         string       `db:"name"`
+	LastUsedAt   sql.NullTime `db:"last_used_at"`
+	CreatedAt    time.Time    `db:"created_at"`
+}
+
+func fromBusPasskey(pk usrBus.Passkey) passkey {
+	var lastUsedAt sql.NullTime
+	if pk.LastUsedAt != nil {
+		lastUsedAt = sql.NullTime{Time: *pk.LastUsedAt, Valid: true}
+	}
+
+	return passkey{
+		ID:           pk.ID,
+		UserID:       pk.UserID,
+		CredentialID: pk.CredentialID,
+		PublicKey:    pk.PublicKey,
+		SignCount:    int64(pk.SignCount),
+		Name:         pk.Name,
+		LastUsedAt:   lastUsedAt,
+		CreatedAt:    pk.CreatedAt,
+	}
+}
+
+func toBusPasskey(pk passkey) usrBus.Passkey {
+	var lastUsedAt *time.Time
+	if pk.LastUsedAt.Valid {
+		lastUsedAt = &pk.LastUsedAt.Time
+	}
+
+	return usrBus.Passkey{
+		ID:           pk.ID,
+		UserID:       pk.UserID,
+		CredentialID: pk.CredentialID,
+		PublicKey:    pk.PublicKey,
+		SignCount:    uint32(pk.SignCount),
+		Name:         pk.Name,
+		LastUsedAt:   lastUsedAt,
+		CreatedAt:    pk.CreatedAt,
+	}
+}
+
+type passkeySession struct {
+	Challenge []byte        `db:"challenge"`
+	UserID    uuid.NullUUID `db:"user_id"`
+	Purpose   string        `db:"purpose"`
+	ExpiresAt time.Time     `db:"expires_at"`
+	CreatedAt time.Time     `db:"created_at"`
+}
+
+func fromBusPasskeySession(s usrBus.PasskeySession) passkeySession {
+	return passkeySession{
+		Challenge: s.Challenge,
+		UserID:    uuid.NullUUID{UUID: s.UserID, Valid: s.UserID != uuid.Nil},
+		Purpose:   s.Purpose,
+		ExpiresAt: s.ExpiresAt,
+		CreatedAt: s.CreatedAt,
+	}
+}
+
+func toBusPasskeySession(s passkeySession) usrBus.PasskeySession {
+	return usrBus.PasskeySession{
+		Challenge: s.Challenge,
+		UserID:    s.UserID.UUID,
+		Purpose:   s.Purpose,
+		ExpiresAt: s.ExpiresAt,
+		CreatedAt: s.CreatedAt,
+	}
+}
+
+// ==============================================================================
+
+type throttle struct {
+	Key          string       `db:"key"`
+	Failures     int          `db:"failures"`
+	LastFailedAt time.Time    `db:"last_failed_at"`
+	LockedUntil  sql.NullTime `db:"locked_until"`
+}
+
+func toBusThrottle(t throttle) usrBus.Throttle {
+	var lockedUntil *time.Time
+	if t.LockedUntil.Valid {
+		lockedUntil = &t.LockedUntil.Time
+	}
+
+	return usrBus.Throttle{
+		Key:          t.Key,
+		Failures:     t.Failures,
+		LastFailedAt: t.LastFailedAt,
+		LockedUntil:  lockedUntil,
+	}
+}
+
+type invitation struct {
+	ID         uuid.UUID        `db:"id"`
+	Email      string           `db:"email"`
+	Roles      usrBus.RoleSlice `db:"roles"`
+	Department sql.NullString   `db:"department"`
+	Hash       []byte           `db:"token_hash"`
+	InvitedBy  uuid.NullUUID    `db:"invited_by"`
+	ExpiresAt  time.Time        `db:"expires_at"`
+	AcceptedAt sql.NullTime     `db:"accepted_at"`
+	CreatedAt  time.Time        `db:"created_at"`
+}
+
+func fromBusInvitation(inv usrBus.Invitation) invitation {
+	var acceptedAt sql.NullTime
+	if inv.AcceptedAt != nil {
+		acceptedAt = sql.NullTime{Time: *inv.AcceptedAt, Valid: true}
+	}
+
+	return invitation{
+		ID:         inv.ID,
+		Email:      inv.Email.Address,
+		Roles:      usrBus.RoleSlice(inv.Roles),
+		Department: sql.NullString{String: inv.Department, Valid: inv.Department != ""},
+		Hash:       inv.Hash,
+		InvitedBy:  uuid.NullUUID{UUID: inv.InvitedBy, Valid: inv.InvitedBy != uuid.Nil},
+		ExpiresAt:  inv.ExpiresAt,
+		AcceptedAt: acceptedAt,
+		CreatedAt:  inv.CreatedAt,
+	}
+}
+
+func toBusInvitation(inv invitation) usrBus.Invitation {
+	var acceptedAt *time.Time
+	if inv.AcceptedAt.Valid {
+		acceptedAt = &inv.AcceptedAt.Time
+	}
+
+	return usrBus.Invitation{
+		ID:         inv.ID,
+		Email:      mail.Address{Address: inv.Email},
+		Roles:      []usrBus.Role(inv.Roles),
+		Department: inv.Department.String,
+		Hash:       inv.Hash,
+		InvitedBy:  inv.InvitedBy.UUID,
+		ExpiresAt:  inv.ExpiresAt,
+		AcceptedAt: acceptedAt,
+		CreatedAt:  inv.CreatedAt,
+	}
+}
+
+// ==============================================================================
+
+type federatedSession struct {
+	StateHash    []byte    `db:"state_hash"`
+	Provider     string    `db:"provider"`
+	Nonce        string    `db:"nonce"`
+	CodeVerifier string    `db:"code_verifier"`
+	ExpiresAt    time.Time `db:"expires_at"`
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+func fromBusFederatedSession(s usrBus.FederatedSession) federatedSession {
+	return federatedSession{
+		StateHash:    s.StateHash,
+		Provider:     s.Provider,
+		Nonce:        s.Nonce,
+		CodeVerifier: s.CodeVerifier,
+		ExpiresAt:    s.ExpiresAt,
+		CreatedAt:    s.CreatedAt,
+	}
+}
+
+func toBusFederatedSession(s federatedSession) usrBus.FederatedSession {
+	return usrBus.FederatedSession{
+		StateHash:    s.StateHash,
+		Provider:     s.Provider,
+		Nonce:        s.Nonce,
+		CodeVerifier: s.CodeVerifier,
+		ExpiresAt:    s.ExpiresAt,
+		CreatedAt:    s.CreatedAt,
+	}
+}
+
+// ==============================================================================
+
+type identity struct {
+	Provider    string       `db:"provider"`
+	Subject     string       `db:"subject"`
+	UserID      uuid.UUID    `db:"user_id"`
+	Email       string       `db:"email"`
+	LastLoginAt sql.NullTime `db:"last_login_at"`
+	CreatedAt   time.Time    `db:"created_at"`
+}
+
+func fromBusIdentity(id usrBus.Identity) identity {
+	var lastLoginAt sql.NullTime
+	if id.LastLoginAt != nil {
+		lastLoginAt = sql.NullTime{Time: *id.LastLoginAt, Valid: true}
+	}
+
+	return identity{
+		Provider:    id.Provider,
+		Subject:     id.Subject,
+		UserID:      id.UserID,
+		Email:       id.Email,
+		LastLoginAt: lastLoginAt,
+		CreatedAt:   id.CreatedAt,
+	}
+}
+
+func toBusIdentity(id identity) usrBus.Identity {
+	var lastLoginAt *time.Time
+	if id.LastLoginAt.Valid {
+		lastLoginAt = &id.LastLoginAt.Time
+	}
+
+	return usrBus.Identity{
+		Provider:    id.Provider,
+		Subject:     id.Subject,
+		UserID:      id.UserID,
+		Email:       id.Email,
+		LastLoginAt: lastLoginAt,
+		CreatedAt:   id.CreatedAt,
+	}
+}
+
+// ==============================================================================
+
+type session struct {
+	ID         uuid.UUID    `db:"id"`
+	UserID     uuid.UUID    `db:"user_id"`
+	UserAgent  string       `db:"user_agent"`
+	IP         string       `db:"ip"`
+	LastSeenAt time.Time    `db:"last_seen_at"`
+	ExpiresAt  time.Time    `db:"expires_at"`
+	RevokedAt  sql.NullTime `db:"revoked_at"`
+	CreatedAt  time.Time    `db:"created_at"`
+}
+
+func fromBusSession(s usrBus.Session) session {
+	var revokedAt sql.NullTime
+	if s.RevokedAt != nil {
+		revokedAt = sql.NullTime{Time: *s.RevokedAt, Valid: true}
+	}
+
+	return session{
+		ID:         s.ID,
+		UserID:     s.UserID,
+		UserAgent:  s.UserAgent,
+		IP:         s.IP,
+		LastSeenAt: s.LastSeenAt,
+		ExpiresAt:  s.ExpiresAt,
+		RevokedAt:  revokedAt,
+		CreatedAt:  s.CreatedAt,
+	}
+}
+
+func toBusSession(s session) usrBus.Session {
+	var revokedAt *time.Time
+	if s.RevokedAt.Valid {
+		revokedAt = &s.RevokedAt.Time
+	}
+
+	return usrBus.Session{
+		ID:         s.ID,
+		UserID:     s.UserID,
+		UserAgent:  s.UserAgent,
+		IP:         s.IP,
+		LastSeenAt: s.LastSeenAt,
+		ExpiresAt:  s.ExpiresAt,
+		RevokedAt:  revokedAt,
+		CreatedAt:  s.CreatedAt,
 	}
 }
@@ -0,0 +1,187 @@
+package userdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	usrBus "github.com/hamidoujand/jumble/internal/domains/user/bus"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func (s *Store) CreatePasskeySession(ctx context.Context, ps usrBus.PasskeySession) error {
+	const q = `
+	INSERT INTO webauthn_sessions (challenge,user_id,purpose,expires_at,created_at)
+	VALUES (:challenge,:user_id,:purpose,:expires_at,:created_at)
+	`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.createPasskeySession")
+	defer span.End()
+
+	if _, err := s.db.NamedExecContext(ctx, q, fromBusPasskeySession(ps)); err != nil {
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) DeletePasskeySession(ctx context.Context, challenge []byte, purpose string) (usrBus.PasskeySession, error) {
+	data := map[string]any{
+		"challenge": challenge,
+		"purpose":   purpose,
+	}
+
+	//deleting while reading makes sure only one request can finish a ceremony.
+	const q = `DELETE FROM webauthn_sessions WHERE challenge = :challenge AND purpose = :purpose RETURNING *`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.deletePasskeySession")
+	defer span.End()
+
+	rows, err := s.db.NamedQueryContext(ctx, q, data)
+	if err != nil {
+		return usrBus.PasskeySession{}, fmt.Errorf("namedQueryContext: %w", err)
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		return usrBus.PasskeySession{}, usrBus.ErrInvalidToken
+	}
+
+	var ps passkeySession
+	if err := rows.StructScan(&ps); err != nil {
+		return usrBus.PasskeySession{}, fmt.Errorf("structScan: %w", err)
+	}
+
+	return toBusPasskeySession(ps), nil
+}
+
+func (s *Store) DeleteExpiredPasskeySessions(ctx context.Context, now time.Time) (int, error) {
+	data := map[string]any{
+		"now": now,
+	}
+
+	const q = `DELETE FROM webauthn_sessions WHERE expires_at < :now`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.deleteExpiredPasskeySessions")
+	defer span.End()
+
+	res, err := s.db.NamedExecContext(ctx, q, data)
+	if err != nil {
+		return 0, fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("rowsAffected: %w", err)
+	}
+
+	return int(n), nil
+}
+
+func (s *Store) CreatePasskey(ctx context.Context, pk usrBus.Passkey) error {
+	const q = `
+	INSERT INTO user_passkeys (id,user_id,credential_id,public_key,sign_count,name,last_used_at,created_at)
+	VALUES (:id,:user_id,:credential_id,:public_key,:sign_count,:name,:last_used_at,:created_at)
+	`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.createPasskey")
+	defer span.End()
+
+	if _, err := s.db.NamedExecContext(ctx, q, fromBusPasskey(pk)); err != nil {
+		var pgerror *pgconn.PgError
+		if errors.As(err, &pgerror) {
+			if pgerror.Code == uniqueViolation {
+				return usrBus.ErrDuplicatedPasskey
+			}
+		}
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) UpdatePasskey(ctx context.Context, pk usrBus.Passkey) error {
+	const q = `
+	UPDATE user_passkeys 
+	SET 
+		sign_count = :sign_count,
+		name = :name,
+		last_used_at = :last_used_at
+	WHERE 
+		id = :id
+	`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.updatePasskey")
+	defer span.End()
+
+	if _, err := s.db.NamedExecContext(ctx, q, fromBusPasskey(pk)); err != nil {
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) QueryPasskeys(ctx context.Context, userID uuid.UUID) ([]usrBus.Passkey, error) {
+	data := map[string]any{
+		"user_id": userID,
+	}
+
+	const q = `SELECT * FROM user_passkeys WHERE user_id = :user_id ORDER BY created_at`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.queryPasskeys")
+	defer span.End()
+
+	rows, err := s.db.NamedQueryContext(ctx, q, data)
+	if err != nil {
+		return nil, fmt.Errorf("namedQueryContext: %w", err)
+	}
+
+	defer rows.Close()
+
+	var pks []usrBus.Passkey
+	for rows.Next() {
+		var pk passkey
+		if err := rows.StructScan(&pk); err != nil {
+			return nil, fmt.Errorf("structScan: %w", err)
+		}
+		pks = append(pks, toBusPasskey(pk))
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("preparing next row to scan: %w", err)
+	}
+
+	return pks, nil
+}
+
+func (s *Store) QueryPasskeyByCredentialID(ctx context.Context, credentialID []byte) (usrBus.Passkey, error) {
+	data := map[string]any{
+		"credential_id": credentialID,
+	}
+
+	const q = `SELECT * FROM user_passkeys WHERE credential_id = :credential_id`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.queryPasskeyByCredentialID")
+	defer span.End()
+
+	rows, err := s.db.NamedQueryContext(ctx, q, data)
+	if err != nil {
+		return usrBus.Passkey{}, fmt.Errorf("namedQueryContext: %w", err)
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		return usrBus.Passkey{}, usrBus.ErrPasskeyNotFound
+	}
+
+	var pk passkey
+	if err := rows.StructScan(&pk); err != nil {
+		return usrBus.Passkey{}, fmt.Errorf("structScan: %w", err)
+	}
+
+	return toBusPasskey(pk), nil
+}
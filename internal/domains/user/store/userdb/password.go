@@ -0,0 +1,97 @@
+package userdb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func (s *Store) QueryPasswordHistory(ctx context.Context, userID uuid.UUID, limit int) ([][]byte, error) {
+	data := map[string]any{
+		"user_id": userID,
+		"limit":   limit,
+	}
+
+	const q = `
+	SELECT password_hash FROM password_history 
+	WHERE user_id = :user_id 
+	ORDER BY created_at DESC 
+	LIMIT :limit
+	`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.queryPasswordHistory")
+	defer span.End()
+
+	rows, err := s.db.NamedQueryContext(ctx, q, data)
+	if err != nil {
+		return nil, fmt.Errorf("namedQueryContext: %w", err)
+	}
+
+	defer rows.Close()
+
+	var hashes [][]byte
+	for rows.Next() {
+		var hash []byte
+		if err := rows.Scan(&hash); err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		hashes = append(hashes, hash)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("preparing next row to scan: %w", err)
+	}
+
+	return hashes, nil
+}
+
+func (s *Store) AddPasswordHistory(ctx context.Context, userID uuid.UUID, hash []byte, createdAt time.Time, keep int) error {
+	data := map[string]any{
+		"user_id":       userID,
+		"password_hash": hash,
+		"created_at":    createdAt,
+		"keep":          keep,
+	}
+
+	const ins = `
+	INSERT INTO password_history (user_id,password_hash,created_at)
+	VALUES (:user_id,:password_hash,:created_at)
+	`
+
+	//only the newest "keep" entries are ever checked, older ones are dropped.
+	const trim = `
+	DELETE FROM password_history 
+	WHERE user_id = :user_id AND id NOT IN (
+		SELECT id FROM password_history 
+		WHERE user_id = :user_id 
+		ORDER BY created_at DESC 
+		LIMIT :keep
+	)
+	`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.addPasswordHistory")
+	defer span.End()
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginTxx: %w", err)
+	}
+
+	defer tx.Rollback()
+
+	if _, err := tx.NamedExecContext(ctx, ins, data); err != nil {
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	if _, err := tx.NamedExecContext(ctx, trim, data); err != nil {
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+
+	return nil
+}
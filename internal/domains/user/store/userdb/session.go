@@ -0,0 +1,197 @@
+package userdb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	usrBus "github.com/hamidoujand/jumble/internal/domains/user/bus"
+)
+
+func (s *Store) CreateSession(ctx context.Context, ss usrBus.Session) error {
+	const q = `
+	INSERT INTO sessions (id,user_id,user_agent,ip,last_seen_at,expires_at,revoked_at,created_at)
+	VALUES (:id,:user_id,:user_agent,:ip,:last_seen_at,:expires_at,:revoked_at,:created_at)
+	`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.createSession")
+	defer span.End()
+
+	if _, err := s.db.NamedExecContext(ctx, q, fromBusSession(ss)); err != nil {
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) UpdateSession(ctx context.Context, ss usrBus.Session) error {
+	const q = `
+	UPDATE sessions
+	SET
+		last_seen_at = :last_seen_at,
+		revoked_at = :revoked_at
+	WHERE
+		id = :id
+	`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.updateSession")
+	defer span.End()
+
+	if _, err := s.db.NamedExecContext(ctx, q, fromBusSession(ss)); err != nil {
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) QuerySessionByID(ctx context.Context, userID uuid.UUID, id uuid.UUID) (usrBus.Session, error) {
+	data := map[string]any{
+		"id":      id,
+		"user_id": userID,
+	}
+
+	const q = `SELECT * FROM sessions WHERE id = :id AND user_id = :user_id`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.querySessionByID")
+	defer span.End()
+
+	rows, err := s.db.NamedQueryContext(ctx, q, data)
+	if err != nil {
+		return usrBus.Session{}, fmt.Errorf("namedQueryContext: %w", err)
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		return usrBus.Session{}, usrBus.ErrSessionNotFound
+	}
+
+	var ss session
+	if err := rows.StructScan(&ss); err != nil {
+		return usrBus.Session{}, fmt.Errorf("structScan: %w", err)
+	}
+
+	return toBusSession(ss), nil
+}
+
+// QuerySessionByIDInOrg only finds the session when its user is a member of the org.
+func (s *Store) QuerySessionByIDInOrg(ctx context.Context, orgID uuid.UUID, userID uuid.UUID, id uuid.UUID) (usrBus.Session, error) {
+	data := map[string]any{
+		"id":      id,
+		"user_id": userID,
+		"org_id":  orgID,
+	}
+
+	const q = `
+	SELECT s.* FROM sessions s
+	JOIN org_members m ON m.user_id = s.user_id AND m.org_id = :org_id
+	WHERE s.id = :id AND s.user_id = :user_id`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.querySessionByIDInOrg")
+	defer span.End()
+
+	rows, err := s.db.NamedQueryContext(ctx, q, data)
+	if err != nil {
+		return usrBus.Session{}, fmt.Errorf("namedQueryContext: %w", err)
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		return usrBus.Session{}, usrBus.ErrSessionNotFound
+	}
+
+	var ss session
+	if err := rows.StructScan(&ss); err != nil {
+		return usrBus.Session{}, fmt.Errorf("structScan: %w", err)
+	}
+
+	return toBusSession(ss), nil
+}
+
+func (s *Store) QueryActiveSessions(ctx context.Context, userID uuid.UUID, now time.Time) ([]usrBus.Session, error) {
+	data := map[string]any{
+		"user_id": userID,
+		"now":     now,
+	}
+
+	const q = `
+	SELECT * FROM sessions
+	WHERE
+		user_id = :user_id AND
+		revoked_at IS NULL AND
+		expires_at > :now
+	ORDER BY last_seen_at DESC
+	`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.queryActiveSessions")
+	defer span.End()
+
+	rows, err := s.db.NamedQueryContext(ctx, q, data)
+	if err != nil {
+		return nil, fmt.Errorf("namedQueryContext: %w", err)
+	}
+
+	defer rows.Close()
+
+	var ss []usrBus.Session
+	for rows.Next() {
+		var se session
+		if err := rows.StructScan(&se); err != nil {
+			return nil, fmt.Errorf("structScan: %w", err)
+		}
+		ss = append(ss, toBusSession(se))
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("preparing next row to scan: %w", err)
+	}
+
+	return ss, nil
+}
+
+func (s *Store) RevokeSessions(ctx context.Context, userID uuid.UUID, keepID uuid.UUID, revokedAt time.Time) error {
+	data := map[string]any{
+		"user_id":    userID,
+		"keep_id":    keepID,
+		"revoked_at": revokedAt,
+	}
+
+	const q = `
+	UPDATE sessions SET revoked_at = :revoked_at
+	WHERE user_id = :user_id AND id <> :keep_id AND revoked_at IS NULL`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.revokeSessions")
+	defer span.End()
+
+	if _, err := s.db.NamedExecContext(ctx, q, data); err != nil {
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) DeleteExpiredSessions(ctx context.Context, now time.Time) (int, error) {
+	data := map[string]any{
+		"now": now,
+	}
+
+	//tokens of deleted sessions are rejected as well, so revoked ones do not need to be kept.
+	const q = `DELETE FROM sessions WHERE expires_at < :now OR revoked_at IS NOT NULL`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.deleteExpiredSessions")
+	defer span.End()
+
+	res, err := s.db.NamedExecContext(ctx, q, data)
+	if err != nil {
+		return 0, fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("rowsAffected: %w", err)
+	}
+
+	return int(n), nil
+}
@@ -0,0 +1,140 @@
+package userdb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	usrBus "github.com/hamidoujand/jumble/internal/domains/user/bus"
+)
+
+func (s *Store) QueryThrottle(ctx context.Context, key string) (usrBus.Throttle, error) {
+	data := map[string]any{
+		"key": key,
+	}
+
+	const q = `SELECT * FROM login_throttles WHERE key = :key`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.queryThrottle")
+	defer span.End()
+
+	rows, err := s.db.NamedQueryContext(ctx, q, data)
+	if err != nil {
+		return usrBus.Throttle{}, fmt.Errorf("namedQueryContext: %w", err)
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		return usrBus.Throttle{}, usrBus.ErrThrottleNotFound
+	}
+
+	var t throttle
+	if err := rows.StructScan(&t); err != nil {
+		return usrBus.Throttle{}, fmt.Errorf("structScan: %w", err)
+	}
+
+	return toBusThrottle(t), nil
+}
+
+func (s *Store) IncrementThrottle(ctx context.Context, key string, now time.Time, windowStart time.Time) (usrBus.Throttle, error) {
+	data := map[string]any{
+		"key":          key,
+		"now":          now,
+		"window_start": windowStart,
+	}
+
+	//a single statement so concurrent failures are all counted, the counter starts over when the
+	//last failure fell out of the window.
+	const q = `
+	INSERT INTO login_throttles (key,failures,last_failed_at) 
+	VALUES (:key,1,:now)
+	ON CONFLICT (key) DO UPDATE 
+	SET 
+		failures = CASE WHEN login_throttles.last_failed_at < :window_start THEN 1 ELSE login_throttles.failures + 1 END,
+		last_failed_at = :now
+	RETURNING *
+	`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.incrementThrottle")
+	defer span.End()
+
+	rows, err := s.db.NamedQueryContext(ctx, q, data)
+	if err != nil {
+		return usrBus.Throttle{}, fmt.Errorf("namedQueryContext: %w", err)
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		return usrBus.Throttle{}, fmt.Errorf("moving cursor to next row: %w", rows.Err())
+	}
+
+	var t throttle
+	if err := rows.StructScan(&t); err != nil {
+		return usrBus.Throttle{}, fmt.Errorf("structScan: %w", err)
+	}
+
+	return toBusThrottle(t), nil
+}
+
+func (s *Store) LockThrottle(ctx context.Context, key string, until time.Time) error {
+	data := map[string]any{
+		"key":          key,
+		"locked_until": until,
+	}
+
+	const q = `UPDATE login_throttles SET locked_until = :locked_until WHERE key = :key`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.lockThrottle")
+	defer span.End()
+
+	if _, err := s.db.NamedExecContext(ctx, q, data); err != nil {
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) DeleteThrottle(ctx context.Context, key string) error {
+	data := map[string]any{
+		"key": key,
+	}
+
+	const q = `DELETE FROM login_throttles WHERE key = :key`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.deleteThrottle")
+	defer span.End()
+
+	if _, err := s.db.NamedExecContext(ctx, q, data); err != nil {
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) DeleteStaleThrottles(ctx context.Context, before time.Time) (int, error) {
+	data := map[string]any{
+		"before": before,
+	}
+
+	const q = `
+	DELETE FROM login_throttles 
+	WHERE last_failed_at < :before AND (locked_until IS NULL OR locked_until < :before)
+	`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.deleteStaleThrottles")
+	defer span.End()
+
+	res, err := s.db.NamedExecContext(ctx, q, data)
+	if err != nil {
+		return 0, fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("rowsAffected: %w", err)
+	}
+
+	return int(n), nil
+}
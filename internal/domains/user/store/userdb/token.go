@@ -0,0 +1,161 @@
+package userdb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	usrBus "github.com/hamidoujand/jumble/internal/domains/user/bus"
+)
+
+func (s *Store) CreateToken(ctx context.Context, t usrBus.Token) error {
+	const q = `
+	INSERT INTO user_tokens (id,user_id,purpose,token_hash,expires_at,used_at,created_at)
+	VALUES (:id,:user_id,:purpose,:token_hash,:expires_at,:used_at,:created_at)
+	`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.createToken")
+	defer span.End()
+
+	if _, err := s.db.NamedExecContext(ctx, q, fromBusToken(t)); err != nil {
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) QueryTokenByHash(ctx context.Context, hash []byte, purpose string) (usrBus.Token, error) {
+	data := map[string]any{
+		"token_hash": hash,
+		"purpose":    purpose,
+	}
+
+	const q = `SELECT * FROM user_tokens WHERE token_hash = :token_hash AND purpose = :purpose`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.queryTokenByHash")
+	defer span.End()
+
+	rows, err := s.db.NamedQueryContext(ctx, q, data)
+	if err != nil {
+		return usrBus.Token{}, fmt.Errorf("namedQueryContext: %w", err)
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		return usrBus.Token{}, usrBus.ErrInvalidToken
+	}
+
+	var t token
+	if err := rows.StructScan(&t); err != nil {
+		return usrBus.Token{}, fmt.Errorf("structScan: %w", err)
+	}
+
+	return toBusToken(t), nil
+}
+
+func (s *Store) QueryLatestToken(ctx context.Context, userID uuid.UUID, purpose string) (usrBus.Token, error) {
+	data := map[string]any{
+		"user_id": userID,
+		"purpose": purpose,
+	}
+
+	const q = `
+	SELECT * FROM user_tokens 
+	WHERE user_id = :user_id AND purpose = :purpose 
+	ORDER BY created_at DESC 
+	LIMIT 1
+	`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.queryLatestToken")
+	defer span.End()
+
+	rows, err := s.db.NamedQueryContext(ctx, q, data)
+	if err != nil {
+		return usrBus.Token{}, fmt.Errorf("namedQueryContext: %w", err)
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		return usrBus.Token{}, usrBus.ErrInvalidToken
+	}
+
+	var t token
+	if err := rows.StructScan(&t); err != nil {
+		return usrBus.Token{}, fmt.Errorf("structScan: %w", err)
+	}
+
+	return toBusToken(t), nil
+}
+
+func (s *Store) UseToken(ctx context.Context, t usrBus.Token, usedAt time.Time) error {
+	data := map[string]any{
+		"id":      t.ID,
+		"used_at": usedAt,
+	}
+
+	//the "used_at IS NULL" makes sure two concurrent requests can not both use the same token.
+	const q = `UPDATE user_tokens SET used_at = :used_at WHERE id = :id AND used_at IS NULL`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.useToken")
+	defer span.End()
+
+	res, err := s.db.NamedExecContext(ctx, q, data)
+	if err != nil {
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rowsAffected: %w", err)
+	}
+
+	if n == 0 {
+		return usrBus.ErrInvalidToken
+	}
+
+	return nil
+}
+
+func (s *Store) DeleteTokens(ctx context.Context, userID uuid.UUID, purpose string) error {
+	data := map[string]any{
+		"user_id": userID,
+		"purpose": purpose,
+	}
+
+	const q = `DELETE FROM user_tokens WHERE user_id = :user_id AND purpose = :purpose`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.deleteTokens")
+	defer span.End()
+
+	if _, err := s.db.NamedExecContext(ctx, q, data); err != nil {
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) DeleteExpiredTokens(ctx context.Context, now time.Time) (int, error) {
+	data := map[string]any{
+		"now": now,
+	}
+
+	const q = `DELETE FROM user_tokens WHERE expires_at < :now`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.deleteExpiredTokens")
+	defer span.End()
+
+	res, err := s.db.NamedExecContext(ctx, q, data)
+	if err != nil {
+		return 0, fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("rowsAffected: %w", err)
+	}
+
+	return int(n), nil
+}
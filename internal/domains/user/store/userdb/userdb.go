@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"net/mail"
+	"time"
 
 	"github.com/google/uuid"
 	usrBus "github.com/hamidoujand/jumble/internal/domains/user/bus"
@@ -36,8 +37,8 @@ func NewStore(db *sqlx.DB, tracer trace.Tracer) *Store {
 
 func (s *Store) Create(ctx context.Context, usr usrBus.User) error {
 	const q = `
-	INSERT INTO users (id,name,email,password_hash,roles,enabled,department,created_at,updated_at) 
-	VALUES (:id,:name,:email,:password_hash,:roles,:enabled,:department,:created_at,:updated_at) 
+	INSERT INTO users (id,name,email,password_hash,roles,enabled,department,email_verified_at,password_changed_at,mfa_secret,mfa_enabled_at,created_at,updated_at) 
+	VALUES (:id,:name,:email,:password_hash,:roles,:enabled,:department,:email_verified_at,:password_changed_at,:mfa_secret,:mfa_enabled_at,:created_at,:updated_at) 
 	`
 
 	ctx, span := s.tracer.Start(ctx, "user.store.create")
@@ -67,6 +68,10 @@ func (s *Store) Update(ctx context.Context, usr usrBus.User) error {
 		roles = :roles,
 		enabled = :enabled,
 		department = :department, 
+		email_verified_at = :email_verified_at,
+		password_changed_at = :password_changed_at,
+		mfa_secret = :mfa_secret,
+		mfa_enabled_at = :mfa_enabled_at,
 		updated_at = :updated_at
 	WHERE 
 		id = :id;
@@ -87,27 +92,90 @@ func (s *Store) Update(ctx context.Context, usr usrBus.User) error {
 	return nil
 }
 
-func (s *Store) Delete(ctx context.Context, usr usrBus.User) error {
-	//passing a value of User type entirely so this layer does not need to do "not found" check
-	const q = `
-	DELETE FROM users WHERE id = :id;
+// Delete marks the user as deleted, the row is kept until PurgeDeletedUsers removes it. What grants
+// access goes right away: linked identities so providers can not log into the account anymore and
+// memberships so orgs and groups do not list the user. The department is cleared so it does not
+// keep the department in use.
+func (s *Store) Delete(ctx context.Context, usr usrBus.User, deletedAt time.Time) error {
+	data := map[string]any{
+		"id":         usr.ID,
+		"deleted_at": deletedAt,
+	}
+
+	const qUser = `
+	UPDATE users 
+	SET 
+		deleted_at = :deleted_at,
+		department = NULL
+	WHERE 
+		id = :id AND deleted_at IS NULL
 	`
+
+	qs := []string{
+		`DELETE FROM user_identities WHERE user_id = :id`,
+		`DELETE FROM org_members WHERE user_id = :id`,
+		`DELETE FROM group_members WHERE user_id = :id`,
+	}
+
 	ctx, span := s.tracer.Start(ctx, "user.store.delete")
 	defer span.End()
 
-	if _, err := s.db.NamedExecContext(ctx, q, fromBusUser(usr)); err != nil {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginTxx: %w", err)
+	}
+
+	defer tx.Rollback()
+
+	if _, err := tx.NamedExecContext(ctx, qUser, data); err != nil {
 		return fmt.Errorf("namedExecContext: %w", err)
 	}
+
+	for _, q := range qs {
+		if _, err := tx.NamedExecContext(ctx, q, data); err != nil {
+			return fmt.Errorf("namedExecContext: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+
 	return nil
 }
 
+// PurgeDeletedUsers removes users deleted before the given time for good, along with everything
+// that references them.
+func (s *Store) PurgeDeletedUsers(ctx context.Context, before time.Time) (int, error) {
+	data := map[string]any{
+		"before": before,
+	}
+
+	const q = `DELETE FROM users WHERE deleted_at < :before`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.purgeDeletedUsers")
+	defer span.End()
+
+	res, err := s.db.NamedExecContext(ctx, q, data)
+	if err != nil {
+		return 0, fmt.Errorf("namedExecContext: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("rowsAffected: %w", err)
+	}
+
+	return int(n), nil
+}
+
 func (s *Store) QueryByID(ctx context.Context, id uuid.UUID) (usrBus.User, error) {
 
 	data := map[string]any{
 		"id": id.String(),
 	}
 
-	const q = `SELECT * FROM users WHERE id = :id`
+	const q = `SELECT * FROM users WHERE id = :id AND deleted_at IS NULL`
 
 	ctx, span := s.tracer.Start(ctx, "user.store.queryByID")
 	defer span.End()
@@ -133,6 +201,40 @@ func (s *Store) QueryByID(ctx context.Context, id uuid.UUID) (usrBus.User, error
 	return toUserBus(usr), nil
 }
 
+// QueryByIDInOrg only finds the user when they are a member of the org.
+func (s *Store) QueryByIDInOrg(ctx context.Context, orgID uuid.UUID, id uuid.UUID) (usrBus.User, error) {
+	data := map[string]any{
+		"id":     id,
+		"org_id": orgID,
+	}
+
+	const q = `
+	SELECT u.* FROM users u
+	JOIN org_members m ON m.user_id = u.id AND m.org_id = :org_id
+	WHERE u.id = :id AND u.deleted_at IS NULL`
+
+	ctx, span := s.tracer.Start(ctx, "user.store.queryByIDInOrg")
+	defer span.End()
+
+	rows, err := s.db.NamedQueryContext(ctx, q, data)
+	if err != nil {
+		return usrBus.User{}, fmt.Errorf("namedQueryContext: %w", err)
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		return usrBus.User{}, usrBus.ErrUserNotFound
+	}
+
+	var usr user
+	if err := rows.StructScan(&usr); err != nil {
+		return usrBus.User{}, fmt.Errorf("structScan: %w", err)
+	}
+
+	return toUserBus(usr), nil
+}
+
 func (s *Store) QueryByEmail(ctx context.Context, email mail.Address) (usrBus.User, error) {
 	data := struct {
 		Email string `db:"email"`
@@ -140,7 +242,7 @@ func (s *Store) QueryByEmail(ctx context.Context, email mail.Address) (usrBus.Us
 		Email: email.Address,
 	}
 
-	const q = `SELECT * FROM users WHERE email = :email;`
+	const q = `SELECT * FROM users WHERE email = :email AND deleted_at IS NULL;`
 
 	ctx, span := s.tracer.Start(ctx, "user.store.queryByEmail")
 	defer span.End()
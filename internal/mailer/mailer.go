@@ -0,0 +1,154 @@
+// Package mailer provides support for sending emails through pluggable backends.
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/hamidoujand/jumble/pkg/logger"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Message represents an email with both a plain text and an html body.
+type Message struct {
+	To      mail.Address
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer knows how to deliver a message, every backend implements it.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Instrument wraps a mailer so every send is traced and logged.
+func Instrument(m Mailer, backend string, log *logger.Logger, tracer trace.Tracer) Mailer {
+	return &instrumented{
+		next:    m,
+		backend: backend,
+		log:     log,
+		tracer:  tracer,
+	}
+}
+
+type instrumented struct {
+	next    Mailer
+	backend string
+	log     *logger.Logger
+	tracer  trace.Tracer
+}
+
+func (i *instrumented) Send(ctx context.Context, msg Message) error {
+	ctx, span := i.tracer.Start(ctx, "mailer.send", trace.WithAttributes(
+		attribute.String("mail.backend", i.backend),
+		attribute.String("mail.subject", msg.Subject),
+	))
+	defer span.End()
+
+	startedAt := time.Now()
+	if err := i.next.Send(ctx, msg); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "send failed")
+		i.log.Error(ctx, "email failed", "backend", i.backend, "to", msg.To.Address, "subject", msg.Subject, "err", err.Error())
+		return err
+	}
+
+	i.log.Info(ctx, "email sent", "backend", i.backend, "to", msg.To.Address, "subject", msg.Subject, "took", time.Since(startedAt))
+	return nil
+}
+
+// ==============================================================================
+
+// build creates the raw RFC 5322 message, a multipart/alternative when both bodies exist.
+func build(from mail.Address, msg Message) ([]byte, error) {
+	var buf bytes.Buffer
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+
+	domain := "localhost"
+	if _, d, ok := strings.Cut(from.Address, "@"); ok {
+		domain = d
+	}
+
+	headers := []struct{ key, val string }{
+		{"From", from.String()},
+		{"To", msg.To.String()},
+		{"Subject", mime.QEncoding.Encode("utf-8", msg.Subject)},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"Message-ID", fmt.Sprintf("<%s@%s>", hex.EncodeToString(id), domain)},
+		{"MIME-Version", "1.0"},
+	}
+
+	for _, h := range headers {
+		fmt.Fprintf(&buf, "%s: %s\r\n", h.key, h.val)
+	}
+
+	//plain text only.
+	if msg.HTML == "" {
+		buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+		buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		if err := writeQP(&buf, msg.Text); err != nil {
+			return nil, fmt.Errorf("writeQP: %w", err)
+		}
+		return buf.Bytes(), nil
+	}
+
+	mw := multipart.NewWriter(&buf)
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", mw.Boundary())
+
+	//the last part is the preferred one for clients.
+	parts := []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	}
+
+	for _, p := range parts {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {p.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("createPart: %w", err)
+		}
+
+		var part bytes.Buffer
+		if err := writeQP(&part, p.body); err != nil {
+			return nil, fmt.Errorf("writeQP: %w", err)
+		}
+
+		if _, err := w.Write(part.Bytes()); err != nil {
+			return nil, fmt.Errorf("write: %w", err)
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		return nil, fmt.Errorf("close: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func writeQP(buf *bytes.Buffer, body string) error {
+	qp := quotedprintable.NewWriter(buf)
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return err
+	}
+
+	return qp.Close()
+}
@@ -0,0 +1,169 @@
+package mailer_test
+
+import (
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hamidoujand/jumble/internal/mailer"
+)
+
+func Test_Render(t *testing.T) {
+	to := mail.Address{Name: "John Doe", Address: "john@doe.com"}
+
+	tests := []struct {
+		name    string
+		subject string
+	}{
+		{name: "password_reset", subject: "Reset your password"},
+		{name: "email_verification", subject: "Verify your email address"},
+		{name: "invitation", subject: "You have been invited to jumble"},
+	}
+
+	for _, ts := range tests {
+		t.Run(ts.name, func(t *testing.T) {
+			data := struct {
+				Name      string
+				Token     string
+				Link      string
+				ExpiresIn string
+			}{
+				Name:      "John <Doe>",
+				Token:     "token1234",
+				Link:      "http://localhost/?token=token1234",
+				ExpiresIn: "30m0s",
+			}
+
+			msg, err := mailer.Render(to, ts.name, data)
+			if err != nil {
+				t.Fatalf("render: %s", err)
+			}
+
+			if msg.Subject != ts.subject {
+				t.Errorf("subject=%q, got=%q", ts.subject, msg.Subject)
+			}
+
+			if !strings.HasPrefix(msg.Text, "Hi John <Doe>,") {
+				t.Errorf("unexpected text body:\n%s", msg.Text)
+			}
+
+			//html must be escaped.
+			if !strings.Contains(msg.HTML, "John &lt;Doe&gt;") {
+				t.Errorf("expected escaped name in html body:\n%s", msg.HTML)
+			}
+
+			for _, body := range []string{msg.Text, msg.HTML} {
+				if !strings.Contains(body, data.Token) {
+					t.Errorf("expected token in body:\n%s", body)
+				}
+			}
+		})
+	}
+
+	_, err := mailer.Render(to, "unknown", nil)
+	if err == nil {
+		t.Error("expected unknown template to fail")
+	}
+}
+
+func Test_File(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outbox")
+	from := mail.Address{Name: "jumble", Address: "no-reply@jumble.local"}
+
+	f, err := mailer.NewFile(dir, from)
+	if err != nil {
+		t.Fatalf("newFile: %s", err)
+	}
+
+	msg := mailer.Message{
+		To:      mail.Address{Name: "John Doe", Address: "john@doe.com"},
+		Subject: "Hello",
+		Text:    "plain body",
+		HTML:    "<p>html body</p>",
+	}
+
+	if err := f.Send(context.Background(), msg); err != nil {
+		t.Fatalf("send: %s", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("readDir: %s", err)
+	}
+
+	if len(entries) != 1 {
+		t.Fatalf("files=%d, got=%d", 1, len(entries))
+	}
+
+	file, err := os.Open(filepath.Join(dir, entries[0].Name()))
+	if err != nil {
+		t.Fatalf("open: %s", err)
+	}
+	defer file.Close()
+
+	parsed, err := mail.ReadMessage(file)
+	if err != nil {
+		t.Fatalf("readMessage: %s", err)
+	}
+
+	if got := parsed.Header.Get("Subject"); got != msg.Subject {
+		t.Errorf("subject=%q, got=%q", msg.Subject, got)
+	}
+
+	if got := parsed.Header.Get("From"); !strings.Contains(got, from.Address) {
+		t.Errorf("from=%q, got=%q", from.Address, got)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("parseMediaType: %s", err)
+	}
+
+	if mediaType != "multipart/alternative" {
+		t.Fatalf("mediaType=%s, got=%s", "multipart/alternative", mediaType)
+	}
+
+	mr := multipart.NewReader(parsed.Body, params["boundary"])
+	var bodies []string
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			t.Fatalf("nextPart: %s", err)
+		}
+
+		//multipart reader decodes quoted-printable parts.
+		bs, err := io.ReadAll(p)
+		if err != nil {
+			t.Fatalf("readAll: %s", err)
+		}
+		bodies = append(bodies, string(bs))
+	}
+
+	if len(bodies) != 2 || bodies[0] != msg.Text || bodies[1] != msg.HTML {
+		t.Errorf("unexpected bodies: %q", bodies)
+	}
+}
+
+func Test_Memory(t *testing.T) {
+	var m mailer.Memory
+
+	msg := mailer.Message{To: mail.Address{Address: "john@doe.com"}, Subject: "Hello", Text: "body"}
+	if err := m.Send(context.Background(), msg); err != nil {
+		t.Fatalf("send: %s", err)
+	}
+
+	msgs := m.Messages()
+	if len(msgs) != 1 || msgs[0].Subject != msg.Subject {
+		t.Errorf("unexpected messages: %+v", msgs)
+	}
+}
@@ -0,0 +1,72 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// File is a Mailer that drops every email as an .eml file into a directory instead of sending it,
+// meant for local development where opening the file is enough.
+type File struct {
+	dir  string
+	from mail.Address
+}
+
+// NewFile creates the outbox directory if needed and returns a file mailer.
+func NewFile(dir string, from mail.Address) (*File, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("mkdirAll: %w", err)
+	}
+
+	return &File{dir: dir, from: from}, nil
+}
+
+func (f *File) Send(ctx context.Context, msg Message) error {
+	raw, err := build(f.from, msg)
+	if err != nil {
+		return fmt.Errorf("build: %w", err)
+	}
+
+	//sortable by time and unique.
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000"), uuid.NewString())
+	path := filepath.Join(f.dir, name)
+
+	if err := os.WriteFile(path, raw, 0o640); err != nil {
+		return fmt.Errorf("writeFile: %w", err)
+	}
+
+	return nil
+}
+
+// ==============================================================================
+
+// Memory is a Mailer that keeps the emails in memory, meant for tests.
+type Memory struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func (m *Memory) Send(ctx context.Context, msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// Messages returns a copy of all the emails sent so far.
+func (m *Memory) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	msgs := make([]Message, len(m.messages))
+	copy(msgs, m.messages)
+	return msgs
+}
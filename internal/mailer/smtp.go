@@ -0,0 +1,126 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"time"
+)
+
+// SMTPConfig defines what is needed to deliver emails to an smtp server.
+type SMTPConfig struct {
+	// Addr of the server in "host:port" format.
+	Addr     string
+	Username string
+	Password string
+	From     mail.Address
+
+	// StartTLS upgrades the connection and refuses to send anything if the server does not support it.
+	StartTLS bool
+
+	// Timeout is used when the ctx passed to Send has no deadline.
+	Timeout time.Duration
+}
+
+// SMTP is a Mailer that delivers emails to an smtp server.
+type SMTP struct {
+	cfg  SMTPConfig
+	host string
+}
+
+// NewSMTP creates an smtp mailer.
+func NewSMTP(cfg SMTPConfig) (*SMTP, error) {
+	host, _, err := net.SplitHostPort(cfg.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("splitHostPort: %w", err)
+	}
+
+	if cfg.Timeout == 0 {
+		cfg.Timeout = time.Second * 30
+	}
+
+	return &SMTP{cfg: cfg, host: host}, nil
+}
+
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+	raw, err := build(s.cfg.From, msg)
+	if err != nil {
+		return fmt.Errorf("build: %w", err)
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.cfg.Timeout)
+		defer cancel()
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.cfg.Addr)
+	if err != nil {
+		return fmt.Errorf("dial: %w", err)
+	}
+
+	//net/smtp is not ctx aware, the deadline on the conn bounds the whole conversation.
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return fmt.Errorf("setDeadline: %w", err)
+	}
+
+	c, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("newClient: %w", err)
+	}
+
+	defer c.Close()
+
+	if s.cfg.StartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return errors.New("server does not support STARTTLS")
+		}
+
+		if err := c.StartTLS(&tls.Config{ServerName: s.host, MinVersion: tls.VersionTLS12}); err != nil {
+			return fmt.Errorf("startTLS: %w", err)
+		}
+	}
+
+	if s.cfg.Username != "" {
+		//PlainAuth refuses to send credentials over an unencrypted connection unless it is localhost.
+		auth := smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.host)
+		if err := c.Auth(auth); err != nil {
+			return fmt.Errorf("auth: %w", err)
+		}
+	}
+
+	if err := c.Mail(s.cfg.From.Address); err != nil {
+		return fmt.Errorf("mail: %w", err)
+	}
+
+	if err := c.Rcpt(msg.To.Address); err != nil {
+		return fmt.Errorf("rcpt: %w", err)
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("data: %w", err)
+	}
+
+	if _, err := w.Write(raw); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("close data: %w", err)
+	}
+
+	if err := c.Quit(); err != nil {
+		return fmt.Errorf("quit: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"net/mail"
+	texttemplate "text/template"
+)
+
+// Each email has a "<name>.txt" and a "<name>.html" template, the text one also defines
+// the "<name>.subject" block.
+//
+//go:embed templates/*
+var templateFiles embed.FS
+
+var (
+	textTemplates = texttemplate.Must(texttemplate.ParseFS(templateFiles, "templates/*.txt"))
+	htmlTemplates = htmltemplate.Must(htmltemplate.ParseFS(templateFiles, "templates/*.html"))
+)
+
+// Render builds the message for "to" out of the templates with the given name.
+func Render(to mail.Address, name string, data any) (Message, error) {
+	var subject, text, html bytes.Buffer
+
+	if err := textTemplates.ExecuteTemplate(&subject, name+".subject", data); err != nil {
+		return Message{}, fmt.Errorf("execute subject: %w", err)
+	}
+
+	if err := textTemplates.ExecuteTemplate(&text, name+".txt", data); err != nil {
+		return Message{}, fmt.Errorf("execute text: %w", err)
+	}
+
+	if err := htmlTemplates.ExecuteTemplate(&html, name+".html", data); err != nil {
+		return Message{}, fmt.Errorf("execute html: %w", err)
+	}
+
+	return Message{
+		To:      to,
+		Subject: subject.String(),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
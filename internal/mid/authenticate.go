@@ -5,23 +5,83 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/hamidoujand/jumble/internal/auth"
+	groupBus "github.com/hamidoujand/jumble/internal/domains/group/bus"
+	orgBus "github.com/hamidoujand/jumble/internal/domains/org/bus"
+	roleBus "github.com/hamidoujand/jumble/internal/domains/role/bus"
+	serviceAccountBus "github.com/hamidoujand/jumble/internal/domains/serviceaccount/bus"
 	"github.com/hamidoujand/jumble/internal/domains/user/bus"
 	"github.com/hamidoujand/jumble/pkg/logger"
 )
 
-func Authenticate(log *logger.Logger, a *auth.Auth, usrBus *bus.Bus) gin.HandlerFunc {
+// ErrImpersonationDenied is returned when the admin behind an impersonation token can no longer
+// act as the user.
+var ErrImpersonationDenied = errors.New("impersonation denied")
+
+// AuthConf holds the dependencies of the Authenticate middleware.
+type AuthConf struct {
+	Log     *logger.Logger
+	Auth    *auth.Auth
+	UserBus *bus.Bus
+
+	//OrgBus checks the membership behind org scoped tokens, without it those tokens are rejected.
+	OrgBus *orgBus.Bus
+
+	//GroupBus adds the roles users get through their groups, they only apply outside of orgs.
+	GroupBus *groupBus.Bus
+
+	//RoleBus re-checks on every request that the admin behind an impersonation token may still
+	//act as the user, without it impersonation tokens are rejected.
+	RoleBus *roleBus.Bus
+
+	//ServiceAccountBus accepts API keys next to bearer tokens, without it API keys are rejected.
+	ServiceAccountBus *serviceAccountBus.Bus
+
+	//RequireVerifiedEmail rejects users that did not verify their email address yet.
+	RequireVerifiedEmail bool
+
+	//Cookies accepts the token from the session cookie when there is no authorization header,
+	//without it the cookie is ignored.
+	Cookies *Cookies
+}
+
+func Authenticate(cfg AuthConf) gin.HandlerFunc {
+	log := cfg.Log
+	a := cfg.Auth
+	usrBus := cfg.UserBus
+
 	return func(c *gin.Context) {
 		// using a 5 seconds ctx to hit the db
 		ctx, cancel := context.WithTimeout(c.Request.Context(), time.Second*5)
 		defer cancel()
 
+		if key, ok := apiKey(c.Request); ok {
+			authenticateKey(c, cfg, key)
+			return
+		}
+
 		token := c.Request.Header.Get("authorization")
 
+		if token == "" && cfg.Cookies != nil {
+			if cookie, err := c.Request.Cookie(cfg.Cookies.Name); err == nil && cookie.Value != "" {
+				//browsers attach cookies to requests other sites make, only our own pages can
+				//read the csrf cookie and send it back.
+				if !cfg.Cookies.validCSRF(c.Request) {
+					c.JSON(http.StatusForbidden, gin.H{"error": "invalid csrf token"})
+					c.Abort()
+					return
+				}
+
+				token = "Bearer " + cookie.Value
+			}
+		}
+
 		claims, err := a.VerifyToken(ctx, token)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
@@ -29,6 +89,13 @@ func Authenticate(log *logger.Logger, a *auth.Auth, usrBus *bus.Bus) gin.Handler
 			return
 		}
 
+		//mfa challenges and other special purpose tokens are not access tokens.
+		if claims.TokenType != "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token type"})
+			c.Abort()
+			return
+		}
+
 		if claims.Subject == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "you are not authorized to take this action"})
 			c.Abort()
@@ -42,8 +109,20 @@ func Authenticate(log *logger.Logger, a *auth.Auth, usrBus *bus.Bus) gin.Handler
 			return
 		}
 
+		//org scoped tokens only find users in the org, so removed members lose access right away.
+		var orgID *uuid.UUID
+		if claims.Org != "" {
+			id, err := uuid.Parse(claims.Org)
+			if err != nil || cfg.OrgBus == nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": fmt.Sprintf("invalid org: %s", claims.Org)})
+				c.Abort()
+				return
+			}
+			orgID = &id
+		}
+
 		//fetch the user from db
-		usr, err := usrBus.QueryByID(ctx, userID)
+		usr, err := queryUser(ctx, usrBus, orgID, userID)
 		if errors.Is(err, bus.ErrUserNotFound) {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": http.StatusText(http.StatusUnauthorized)})
 			c.Abort()
@@ -63,9 +142,303 @@ func Authenticate(log *logger.Logger, a *auth.Auth, usrBus *bus.Bus) gin.Handler
 			return
 		}
 
+		//changing the password revokes every token issued before it, "iat" only has seconds precision.
+		if claims.IssuedAt == nil || claims.IssuedAt.Before(usr.PasswordChangedAt.Truncate(time.Second)) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "token is revoked"})
+			c.Abort()
+			return
+		}
+
+		if cfg.RequireVerifiedEmail && usr.EmailVerifiedAt == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "email is not verified"})
+			c.Abort()
+			return
+		}
+
+		if claims.SessionID != "" {
+			s, ok := session(ctx, c, cfg, claims.SessionID, usr, orgID)
+			if !ok {
+				return
+			}
+
+			c.Set("session", s)
+		}
+
+		if claims.Act != nil {
+			actor, ok := impersonator(ctx, c, cfg, claims.Act, usr, orgID)
+			if !ok {
+				return
+			}
+
+			c.Set("actor", actor)
+		}
+
+		if orgID != nil {
+			member, err := cfg.OrgBus.QueryMember(ctx, *orgID, usr.ID)
+			if errors.Is(err, orgBus.ErrMemberNotFound) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "not a member of the org"})
+				c.Abort()
+				return
+			}
+
+			if err != nil {
+				log.Error(c.Request.Context(), "queryMember", "error", err.Error())
+				c.JSON(http.StatusInternalServerError, gin.H{"error": http.StatusText(http.StatusInternalServerError)})
+				c.Abort()
+				return
+			}
+
+			c.Set("membership", member)
+		}
+
+		if orgID == nil && cfg.GroupBus != nil {
+			roles, err := cfg.GroupBus.Roles(ctx, usr.ID)
+			if err != nil {
+				log.Error(c.Request.Context(), "groupRoles", "error", err.Error())
+				c.JSON(http.StatusInternalServerError, gin.H{"error": http.StatusText(http.StatusInternalServerError)})
+				c.Abort()
+				return
+			}
+
+			c.Set("groupRoles", roles)
+		}
+
 		c.Set("claims", claims)
 		c.Set("user", usr)
 
 		c.Next()
 	}
 }
+
+// session checks that the login behind the token was not revoked and updates its last seen, when
+// it fails the response is already written.
+func session(ctx context.Context, c *gin.Context, cfg AuthConf, sid string, usr bus.User, orgID *uuid.UUID) (bus.Session, bool) {
+	sessionID, err := uuid.Parse(sid)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": fmt.Sprintf("invalid session: %s", sid)})
+		c.Abort()
+		return bus.Session{}, false
+	}
+
+	var s bus.Session
+	if orgID != nil {
+		s, err = cfg.UserBus.QuerySessionByIDInOrg(ctx, *orgID, usr.ID, sessionID)
+	} else {
+		s, err = cfg.UserBus.QuerySessionByID(ctx, usr.ID, sessionID)
+	}
+	if errors.Is(err, bus.ErrSessionNotFound) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session is revoked"})
+		c.Abort()
+		return bus.Session{}, false
+	}
+
+	if err != nil {
+		cfg.Log.Error(c.Request.Context(), "querySessionByID", "error", err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": http.StatusText(http.StatusInternalServerError)})
+		c.Abort()
+		return bus.Session{}, false
+	}
+
+	if err := s.Check(time.Now()); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		c.Abort()
+		return bus.Session{}, false
+	}
+
+	//last seen is only informational, failing to update it should not fail the request.
+	touched, err := cfg.UserBus.TouchSession(ctx, s)
+	if err != nil {
+		cfg.Log.Error(c.Request.Context(), "touchSession", "error", err.Error())
+		return s, true
+	}
+
+	return touched, true
+}
+
+// Session returns the session of the authenticated user, only set for tokens issued at a login.
+func Session(c *gin.Context) (bus.Session, bool) {
+	val, ok := c.Get("session")
+	if !ok {
+		return bus.Session{}, false
+	}
+
+	s, ok := val.(bus.Session)
+	return s, ok
+}
+
+// impersonator checks the admin behind an impersonation token and records the request for the
+// audit log, when it fails the response is already written.
+func impersonator(ctx context.Context, c *gin.Context, cfg AuthConf, act *auth.Actor, usr bus.User, orgID *uuid.UUID) (bus.User, bool) {
+	actor, err := CheckImpersonation(ctx, cfg, act, usr, orgID)
+	if errors.Is(err, ErrImpersonationDenied) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		c.Abort()
+		return bus.User{}, false
+	}
+
+	if err != nil {
+		cfg.Log.Error(c.Request.Context(), "checkImpersonation", "error", err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": http.StatusText(http.StatusInternalServerError)})
+		c.Abort()
+		return bus.User{}, false
+	}
+
+	err = cfg.UserBus.RecordImpersonatedRequest(ctx, actor.ID, usr, c.Request.Method, c.Request.URL.Path, c.ClientIP())
+	if err != nil {
+		cfg.Log.Error(c.Request.Context(), "recordImpersonatedRequest", "error", err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": http.StatusText(http.StatusInternalServerError)})
+		c.Abort()
+		return bus.User{}, false
+	}
+
+	cfg.Log.Info(c.Request.Context(), "impersonated request", "actor", actor.ID, "user", usr.ID, "method", c.Request.Method, "path", c.Request.URL.Path)
+
+	return actor, true
+}
+
+// CheckImpersonation returns the admin behind an impersonation token for usr, after checking they
+// may still act as usr. Failed checks wrap ErrImpersonationDenied, other errors mean the answer
+// is not known.
+func CheckImpersonation(ctx context.Context, cfg AuthConf, act *auth.Actor, usr bus.User, orgID *uuid.UUID) (bus.User, error) {
+	actorID, err := uuid.Parse(act.Subject)
+	if err != nil {
+		return bus.User{}, fmt.Errorf("%w: invalid actor: %s", ErrImpersonationDenied, act.Subject)
+	}
+
+	//in an org the admin has to be a member too.
+	actor, err := queryUser(ctx, cfg.UserBus, orgID, actorID)
+	if errors.Is(err, bus.ErrUserNotFound) {
+		return bus.User{}, fmt.Errorf("%w: impersonator not found", ErrImpersonationDenied)
+	}
+
+	if err != nil {
+		return bus.User{}, fmt.Errorf("queryUser: %w", err)
+	}
+
+	//disabling the admin ends their impersonations right away.
+	if !actor.Enabled {
+		return bus.User{}, fmt.Errorf("%w: impersonator is disabled", ErrImpersonationDenied)
+	}
+
+	if cfg.RoleBus == nil {
+		return bus.User{}, fmt.Errorf("%w: impersonation tokens are not accepted", ErrImpersonationDenied)
+	}
+
+	actorPerms, err := impersonationPermissions(ctx, cfg, orgID, actor)
+	if err != nil {
+		return bus.User{}, fmt.Errorf("actorPermissions: %w", err)
+	}
+
+	usrPerms, err := impersonationPermissions(ctx, cfg, orgID, usr)
+	if err != nil {
+		return bus.User{}, fmt.Errorf("userPermissions: %w", err)
+	}
+
+	//the same rules as when the token was issued, so taking the permission away from the admin or
+	//granting the user more than the admin has ends the impersonation right away too.
+	if !actorPerms.Has(roleBus.PermUsersImpersonate) || !actorPerms.Covers(usrPerms) || slices.Contains(usr.Roles, bus.RoleAdmin) {
+		return bus.User{}, fmt.Errorf("%w: the impersonator may no longer act as the user", ErrImpersonationDenied)
+	}
+
+	return actor, nil
+}
+
+// impersonationPermissions returns what usr is granted where the impersonation happens, in the
+// org when there is one.
+func impersonationPermissions(ctx context.Context, cfg AuthConf, orgID *uuid.UUID, usr bus.User) (roleBus.PermissionSet, error) {
+	if orgID == nil {
+		return UserPermissions(ctx, cfg.RoleBus, cfg.GroupBus, usr)
+	}
+
+	m, err := cfg.OrgBus.QueryMember(ctx, *orgID, usr.ID)
+	if err != nil {
+		return nil, fmt.Errorf("queryMember: %w", err)
+	}
+
+	return cfg.RoleBus.Permissions(ctx, m.Roles)
+}
+
+// queryUser looks the user up in the org when the request is org scoped.
+func queryUser(ctx context.Context, users *bus.Bus, orgID *uuid.UUID, id uuid.UUID) (bus.User, error) {
+	if orgID != nil {
+		return users.QueryByIDInOrg(ctx, *orgID, id)
+	}
+
+	return users.QueryByID(ctx, id)
+}
+
+// Impersonator returns the admin acting as the authenticated user, only set for impersonation
+// tokens.
+func Impersonator(c *gin.Context) (bus.User, bool) {
+	val, ok := c.Get("actor")
+	if !ok {
+		return bus.User{}, false
+	}
+
+	actor, ok := val.(bus.User)
+	return actor, ok
+}
+
+// authenticateKey lets service accounts in with their API keys, they have no user so endpoints
+// that act on the current user reject them.
+func authenticateKey(c *gin.Context, cfg AuthConf, key string) {
+	if cfg.ServiceAccountBus == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "api keys are not accepted"})
+		c.Abort()
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), time.Second*5)
+	defer cancel()
+
+	sa, k, err := cfg.ServiceAccountBus.Authenticate(ctx, key)
+	if errors.Is(err, serviceAccountBus.ErrInvalidKey) || errors.Is(err, serviceAccountBus.ErrServiceAccountDisabled) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	if err != nil {
+		cfg.Log.Error(c.Request.Context(), "authenticateKey", "error", err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": http.StatusText(http.StatusInternalServerError)})
+		c.Abort()
+		return
+	}
+
+	c.Set("serviceAccount", sa)
+	c.Set("apiKey", k)
+
+	c.Next()
+}
+
+// apiKey reads the API key from the "X-API-Key" header or the "ApiKey" authorization scheme.
+func apiKey(r *http.Request) (string, bool) {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key, true
+	}
+
+	scheme, key, ok := strings.Cut(r.Header.Get("authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "ApiKey") {
+		return "", false
+	}
+
+	return strings.TrimSpace(key), true
+}
+
+// ServiceAccount returns the service account and key behind the request, only set when it was
+// authenticated with an API key.
+func ServiceAccount(c *gin.Context) (serviceAccountBus.ServiceAccount, serviceAccountBus.APIKey, bool) {
+	val, ok := c.Get("serviceAccount")
+	if !ok {
+		return serviceAccountBus.ServiceAccount{}, serviceAccountBus.APIKey{}, false
+	}
+
+	sa, ok := val.(serviceAccountBus.ServiceAccount)
+	if !ok {
+		return serviceAccountBus.ServiceAccount{}, serviceAccountBus.APIKey{}, false
+	}
+
+	k, _ := c.Get("apiKey")
+	key, ok := k.(serviceAccountBus.APIKey)
+	return sa, key, ok
+}
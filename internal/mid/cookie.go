@@ -0,0 +1,96 @@
+package mid
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CSRFHeader is where browser clients echo the value of the CSRF cookie back.
+const CSRFHeader = "X-CSRF-Token"
+
+// Cookies configures the cookie mode for browser clients. The access token is kept in an HttpOnly
+// cookie scripts can not read, and a second cookie holds the CSRF token that state changing
+// requests have to send back in the CSRFHeader.
+type Cookies struct {
+	Name     string
+	CSRFName string
+	Domain   string
+	SameSite http.SameSite
+}
+
+// ParseSameSite turns "strict", "lax" or "none" into the SameSite mode of a cookie.
+func ParseSameSite(s string) (http.SameSite, error) {
+	switch strings.ToLower(s) {
+	case "strict":
+		return http.SameSiteStrictMode, nil
+	case "lax":
+		return http.SameSiteLaxMode, nil
+	case "none":
+		return http.SameSiteNoneMode, nil
+	default:
+		return 0, fmt.Errorf("unknown same site mode: %s", s)
+	}
+}
+
+// SetSessionCookies hands the token to the browser along with a new CSRF token, both cookies live
+// as long as the token.
+func SetSessionCookies(c *gin.Context, ck Cookies, token string, maxAge time.Duration) error {
+	bs := make([]byte, 32)
+	if _, err := rand.Read(bs); err != nil {
+		return fmt.Errorf("read: %w", err)
+	}
+
+	http.SetCookie(c.Writer, ck.cookie(ck.Name, token, maxAge, true))
+	http.SetCookie(c.Writer, ck.cookie(ck.CSRFName, base64.RawURLEncoding.EncodeToString(bs), maxAge, false))
+
+	return nil
+}
+
+// ClearSessionCookies tells the browser to drop both cookies.
+func ClearSessionCookies(c *gin.Context, ck Cookies) {
+	http.SetCookie(c.Writer, ck.cookie(ck.Name, "", -1, true))
+	http.SetCookie(c.Writer, ck.cookie(ck.CSRFName, "", -1, false))
+}
+
+func (ck Cookies) cookie(name string, value string, maxAge time.Duration, httpOnly bool) *http.Cookie {
+	secs := int(maxAge / time.Second)
+	if maxAge < 0 {
+		secs = -1
+	}
+
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Domain:   ck.Domain,
+		MaxAge:   secs,
+		Secure:   true,
+		HttpOnly: httpOnly,
+		SameSite: ck.SameSite,
+	}
+}
+
+// validCSRF reports whether the request may go on, safe methods do not change state so they pass
+// without a token.
+func (ck Cookies) validCSRF(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+
+	cookie, err := r.Cookie(ck.CSRFName)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+
+	header := r.Header.Get(CSRFHeader)
+
+	return subtle.ConstantTimeCompare([]byte(header), []byte(cookie.Value)) == 1
+}
@@ -0,0 +1,146 @@
+package mid_test
+
+import (
+	"crypto/rsa"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hamidoujand/jumble/internal/auth"
+	"github.com/hamidoujand/jumble/internal/mid"
+)
+
+func Test_SetSessionCookies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	ck := mid.Cookies{Name: "session", CSRFName: "csrf", SameSite: http.SameSiteStrictMode}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	if err := mid.SetSessionCookies(c, ck, "token", time.Hour); err != nil {
+		t.Fatalf("failed to set cookies: %s", err)
+	}
+
+	cookies := make(map[string]*http.Cookie)
+	for _, cookie := range w.Result().Cookies() {
+		cookies[cookie.Name] = cookie
+	}
+
+	session, ok := cookies["session"]
+	if !ok || session.Value != "token" || !session.HttpOnly {
+		t.Errorf("expected an HttpOnly session cookie holding the token, got=%+v", session)
+	}
+
+	csrf, ok := cookies["csrf"]
+	if !ok || csrf.Value == "" || csrf.HttpOnly {
+		t.Errorf("expected a csrf cookie scripts can read, got=%+v", csrf)
+	}
+
+	for _, cookie := range []*http.Cookie{session, csrf} {
+		if cookie == nil {
+			continue
+		}
+
+		if !cookie.Secure || cookie.SameSite != http.SameSiteStrictMode || cookie.MaxAge != 3600 {
+			t.Errorf("expected a secure strict cookie living for the token, got=%+v", cookie)
+		}
+	}
+}
+
+func Test_CookieCSRF(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	ck := mid.Cookies{Name: "session", CSRFName: "csrf", SameSite: http.SameSiteLaxMode}
+
+	authenticated := mid.Authenticate(mid.AuthConf{
+		Auth:    auth.New(noKeys{}, "mid_test"),
+		Cookies: &ck,
+	})
+
+	router := gin.New()
+	router.GET("/", authenticated)
+	router.POST("/", authenticated)
+
+	tests := []struct {
+		name          string
+		method        string
+		authorization string
+		csrfCookie    string
+		csrfHeader    string
+		statusCode    int
+	}{
+		{
+			name:       "missing_csrf_token",
+			method:     http.MethodPost,
+			csrfCookie: "abc",
+			statusCode: http.StatusForbidden,
+		},
+		{
+			name:       "wrong_csrf_token",
+			method:     http.MethodPost,
+			csrfCookie: "abc",
+			csrfHeader: "xyz",
+			statusCode: http.StatusForbidden,
+		},
+		{
+			//passing the csrf check leaves the token to be verified, which fails here.
+			name:       "matching_csrf_token",
+			method:     http.MethodPost,
+			csrfCookie: "abc",
+			csrfHeader: "abc",
+			statusCode: http.StatusUnauthorized,
+		},
+		{
+			name:       "safe_method",
+			method:     http.MethodGet,
+			statusCode: http.StatusUnauthorized,
+		},
+		{
+			name:          "authorization_header",
+			method:        http.MethodPost,
+			authorization: "Bearer token",
+			statusCode:    http.StatusUnauthorized,
+		},
+	}
+
+	for _, ts := range tests {
+		t.Run(ts.name, func(t *testing.T) {
+			r := httptest.NewRequest(ts.method, "/", nil)
+			r.AddCookie(&http.Cookie{Name: "session", Value: "token"})
+
+			if ts.authorization != "" {
+				r.Header.Set("Authorization", ts.authorization)
+			}
+
+			if ts.csrfCookie != "" {
+				r.AddCookie(&http.Cookie{Name: "csrf", Value: ts.csrfCookie})
+			}
+
+			if ts.csrfHeader != "" {
+				r.Header.Set(mid.CSRFHeader, ts.csrfHeader)
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			if w.Code != ts.statusCode {
+				t.Errorf("status=%d, got=%d", ts.statusCode, w.Code)
+			}
+		})
+	}
+}
+
+// noKeys is enough for tokens that fail before their signature is checked.
+type noKeys struct{}
+
+func (noKeys) PrivateKey(kid string) (*rsa.PrivateKey, error) {
+	return nil, errors.New("no keys")
+}
+
+func (noKeys) PublicKey(kid string) (*rsa.PublicKey, error) {
+	return nil, errors.New("no keys")
+}
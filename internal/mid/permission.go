@@ -0,0 +1,231 @@
+package mid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hamidoujand/jumble/internal/auth"
+	groupBus "github.com/hamidoujand/jumble/internal/domains/group/bus"
+	orgBus "github.com/hamidoujand/jumble/internal/domains/org/bus"
+	roleBus "github.com/hamidoujand/jumble/internal/domains/role/bus"
+	"github.com/hamidoujand/jumble/internal/domains/user/bus"
+	"github.com/hamidoujand/jumble/pkg/logger"
+)
+
+var errNoUser = errors.New("no authenticated user in context")
+
+// RequirePermission only lets users through whose roles grant all of perms, it has to run after
+// Authenticate.
+func RequirePermission(log *logger.Logger, roles *roleBus.Bus, perms ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ps, err := Permissions(c, roles)
+		if errors.Is(err, errNoUser) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": http.StatusText(http.StatusUnauthorized)})
+			c.Abort()
+			return
+		}
+
+		if err != nil {
+			log.Error(c.Request.Context(), "permissions", "error", err.Error())
+			c.JSON(http.StatusInternalServerError, gin.H{"error": http.StatusText(http.StatusInternalServerError)})
+			c.Abort()
+			return
+		}
+
+		if !ps.HasAll(perms...) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "you are not allowed to take this action"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// GlobalScope rejects org scoped tokens, for actions that reach beyond a single org like managing
+// the roles themselves or the global roles of users.
+func GlobalScope() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := Membership(c); ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "not allowed with an org scoped token"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireScope only lets tokens through that were issued with all of scopes, so a token issued for
+// one service can not be replayed against another. It has to run after Authenticate, API keys
+// are only good for this service and always pass.
+func RequireScope(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := ServiceAccount(c); ok {
+			c.Next()
+			return
+		}
+
+		val, ok := c.Get("claims")
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": http.StatusText(http.StatusUnauthorized)})
+			c.Abort()
+			return
+		}
+
+		claims, ok := val.(auth.Claims)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": http.StatusText(http.StatusUnauthorized)})
+			c.Abort()
+			return
+		}
+
+		for _, scope := range scopes {
+			if !claims.HasScope(scope) {
+				c.Header("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope=%q`, strings.Join(scopes, " ")))
+				c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("token is missing the %q scope", scope)})
+				c.Abort()
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// NotImpersonated rejects impersonation tokens, for sensitive actions like changing credentials
+// or roles that support staff must not take in the name of a user.
+func NotImpersonated() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := Impersonator(c); ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "not allowed while impersonating"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// ServiceAccountOnly rejects everything but API keys, for endpoints meant for other services
+// rather than people.
+func ServiceAccountOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := ServiceAccount(c); !ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "only service accounts can take this action"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// Permissions returns the permissions of the authenticated user, they are looked up once per
+// request and kept in the context for the next middleware or handler that asks. With an org
+// scoped token only the roles the user has in that org count, otherwise the roles of the user
+// and of their groups do. Service accounts get the permissions of their roles that the scopes of
+// their API key allow.
+func Permissions(c *gin.Context, roles *roleBus.Bus) (roleBus.PermissionSet, error) {
+	if val, ok := c.Get("permissions"); ok {
+		if ps, ok := val.(roleBus.PermissionSet); ok {
+			return ps, nil
+		}
+	}
+
+	if sa, k, ok := ServiceAccount(c); ok {
+		ps, err := roles.Permissions(c.Request.Context(), sa.Roles)
+		if err != nil {
+			return nil, err
+		}
+
+		ps = ps.Restrict(k.Scopes)
+		c.Set("permissions", ps)
+		return ps, nil
+	}
+
+	val, ok := c.Get("user")
+	if !ok {
+		return nil, errNoUser
+	}
+
+	usr, ok := val.(bus.User)
+	if !ok {
+		return nil, errNoUser
+	}
+
+	//roles come from the user loaded by Authenticate, not the claims, so changes apply right away.
+	names := bus.RolesToString(usr.Roles)
+	if val, ok := c.Get("groupRoles"); ok {
+		if roles, ok := val.([]string); ok {
+			names = append(names, roles...)
+		}
+	}
+
+	if m, ok := Membership(c); ok {
+		names = m.Roles
+	}
+
+	ps, err := roles.Permissions(c.Request.Context(), names)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Set("permissions", ps)
+	return ps, nil
+}
+
+// CanGrant reports whether the authenticated user may hand out the named roles, which they can
+// only do when the roles grant no permission they lack themselves. Like impersonation, this keeps
+// anyone from giving out more access than they have.
+func CanGrant(c *gin.Context, roles *roleBus.Bus, names []string) (bool, error) {
+	ps, err := Permissions(c, roles)
+	if err != nil {
+		return false, fmt.Errorf("permissions: %w", err)
+	}
+
+	requested, err := roles.Permissions(c.Request.Context(), names)
+	if err != nil {
+		return false, fmt.Errorf("requested permissions: %w", err)
+	}
+
+	return ps.Covers(requested), nil
+}
+
+// UserPermissions returns what usr is granted outside of orgs through their own roles and the
+// roles of their groups, for checks about users other than the authenticated one. groups can be
+// nil when groups grant no roles.
+func UserPermissions(ctx context.Context, roles *roleBus.Bus, groups *groupBus.Bus, usr bus.User) (roleBus.PermissionSet, error) {
+	names := bus.RolesToString(usr.Roles)
+
+	if groups != nil {
+		groupRoles, err := groups.Roles(ctx, usr.ID)
+		if err != nil {
+			return nil, fmt.Errorf("groupRoles: %w", err)
+		}
+
+		names = append(names, groupRoles...)
+	}
+
+	ps, err := roles.Permissions(ctx, names)
+	if err != nil {
+		return nil, fmt.Errorf("permissions: %w", err)
+	}
+
+	return ps, nil
+}
+
+// Membership returns the org membership of the authenticated user, only set for org scoped tokens.
+func Membership(c *gin.Context) (orgBus.Member, bool) {
+	val, ok := c.Get("membership")
+	if !ok {
+		return orgBus.Member{}, false
+	}
+
+	m, ok := val.(orgBus.Member)
+	return m, ok
+}
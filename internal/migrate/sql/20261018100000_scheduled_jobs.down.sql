@@ -0,0 +1 @@
+DROP TABLE scheduled_jobs;
\ No newline at end of file
@@ -0,0 +1,8 @@
+CREATE TABLE scheduled_jobs(
+    name VARCHAR(100) PRIMARY KEY NOT NULL,
+    last_run_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_duration_ms BIGINT NOT NULL,
+    last_error TEXT NULL,
+    last_runner VARCHAR(255) NOT NULL,
+    next_run_at TIMESTAMP WITH TIME ZONE NOT NULL
+)
\ No newline at end of file
@@ -0,0 +1,7 @@
+-- deleted users are kept for a while before they are purged, their email is free right away.
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE NULL;
+
+ALTER TABLE users DROP CONSTRAINT users_email_key;
+CREATE UNIQUE INDEX users_email_key ON users(email) WHERE deleted_at IS NULL;
+
+CREATE INDEX users_deleted_at_idx ON users(deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,15 @@
+ALTER TABLE users ADD COLUMN password_changed_at TIMESTAMP WITH TIME ZONE NULL;
+UPDATE users SET password_changed_at = created_at;
+ALTER TABLE users ALTER COLUMN password_changed_at SET NOT NULL;
+
+CREATE TABLE user_tokens(
+    id UUID PRIMARY KEY NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose VARCHAR(50) NOT NULL,
+    token_hash BYTEA NOT NULL UNIQUE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX user_tokens_user_id_idx ON user_tokens(user_id, purpose);
\ No newline at end of file
@@ -0,0 +1,4 @@
+DROP TABLE user_recovery_codes;
+ALTER TABLE users DROP COLUMN mfa_last_counter;
+ALTER TABLE users DROP COLUMN mfa_enabled_at;
+ALTER TABLE users DROP COLUMN mfa_secret;
\ No newline at end of file
@@ -0,0 +1,12 @@
+ALTER TABLE users ADD COLUMN mfa_secret TEXT NULL;
+ALTER TABLE users ADD COLUMN mfa_enabled_at TIMESTAMP WITH TIME ZONE NULL;
+ALTER TABLE users ADD COLUMN mfa_last_counter BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE user_recovery_codes(
+    id UUID PRIMARY KEY NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash BYTEA NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    UNIQUE (user_id, code_hash)
+);
\ No newline at end of file
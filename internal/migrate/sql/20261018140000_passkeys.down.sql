@@ -0,0 +1,2 @@
+DROP TABLE webauthn_sessions;
+DROP TABLE user_passkeys;
\ No newline at end of file
@@ -0,0 +1,2 @@
+DROP TABLE login_throttles;
+DROP TABLE audit_log;
\ No newline at end of file
@@ -0,0 +1,18 @@
+CREATE TABLE audit_log(
+    id UUID PRIMARY KEY NOT NULL,
+    actor_id UUID NULL,
+    action VARCHAR(100) NOT NULL,
+    target VARCHAR(400) NOT NULL,
+    ip VARCHAR(64) NULL,
+    details JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX audit_log_target_idx ON audit_log(target, created_at);
+
+CREATE TABLE login_throttles(
+    key VARCHAR(400) PRIMARY KEY NOT NULL,
+    failures INT NOT NULL,
+    last_failed_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    locked_until TIMESTAMP WITH TIME ZONE NULL
+);
\ No newline at end of file
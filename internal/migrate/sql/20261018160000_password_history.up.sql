@@ -0,0 +1,8 @@
+CREATE TABLE password_history(
+    id UUID PRIMARY KEY NOT NULL DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    password_hash BYTEA NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX password_history_user_id_idx ON password_history(user_id, created_at);
\ No newline at end of file
@@ -0,0 +1,13 @@
+CREATE TABLE roles(
+    name VARCHAR(32) PRIMARY KEY NOT NULL,
+    description VARCHAR(400) NOT NULL DEFAULT '',
+    permissions TEXT[] NOT NULL DEFAULT '{}',
+    built_in BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- admin is granted every permission by the service itself, it needs none listed here.
+INSERT INTO roles (name,description,permissions,built_in,created_at,updated_at) VALUES
+    ('admin', 'Full access to every resource', '{}', TRUE, now(), now()),
+    ('user', 'Self service only', '{}', TRUE, now(), now());
\ No newline at end of file
@@ -0,0 +1,18 @@
+CREATE TABLE organizations(
+    id UUID PRIMARY KEY NOT NULL,
+    name VARCHAR(200) NOT NULL,
+    slug VARCHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE TABLE org_members(
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    roles TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (org_id, user_id)
+);
+
+CREATE INDEX org_members_user_id_idx ON org_members(user_id);
\ No newline at end of file
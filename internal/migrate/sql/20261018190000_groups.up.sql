@@ -0,0 +1,21 @@
+CREATE TABLE groups(
+    id UUID PRIMARY KEY NOT NULL,
+    name VARCHAR(120) NOT NULL UNIQUE,
+    description VARCHAR(400) NOT NULL DEFAULT '',
+    -- subgroups keep their parent from being deleted.
+    parent_id UUID NULL REFERENCES groups(id),
+    roles TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX groups_parent_id_idx ON groups(parent_id);
+
+CREATE TABLE group_members(
+    group_id UUID NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (group_id, user_id)
+);
+
+CREATE INDEX group_members_user_id_idx ON group_members(user_id);
\ No newline at end of file
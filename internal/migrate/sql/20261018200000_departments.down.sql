@@ -0,0 +1,2 @@
+DROP INDEX users_department_idx;
+DROP TABLE departments;
\ No newline at end of file
@@ -0,0 +1,18 @@
+CREATE TABLE departments(
+    name VARCHAR(100) PRIMARY KEY NOT NULL,
+    description VARCHAR(400) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- the departments the service used to hardcode, plus whatever users already have.
+INSERT INTO departments (name,created_at,updated_at) VALUES
+    ('sales', now(), now()),
+    ('shipping', now(), now()),
+    ('marketing', now(), now());
+
+INSERT INTO departments (name,created_at,updated_at)
+    SELECT DISTINCT department, now(), now() FROM users WHERE department IS NOT NULL AND department <> ''
+    ON CONFLICT (name) DO NOTHING;
+
+CREATE INDEX users_department_idx ON users(department);
\ No newline at end of file
@@ -0,0 +1,13 @@
+CREATE TABLE invitations(
+    id UUID PRIMARY KEY NOT NULL,
+    email VARCHAR(300) NOT NULL,
+    roles TEXT[] NOT NULL,
+    department VARCHAR(100) NULL,
+    token_hash BYTEA NOT NULL UNIQUE,
+    invited_by UUID NULL REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    accepted_at TIMESTAMP WITH TIME ZONE NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX invitations_email_idx ON invitations(email);
\ No newline at end of file
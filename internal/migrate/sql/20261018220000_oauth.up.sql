@@ -0,0 +1,44 @@
+CREATE TABLE oauth_clients(
+    id UUID PRIMARY KEY NOT NULL,
+    name VARCHAR(120) NOT NULL,
+    -- public clients like SPAs have no secret and must use PKCE.
+    secret_hash BYTEA NULL,
+    redirect_uris TEXT[] NOT NULL,
+    grant_types TEXT[] NOT NULL,
+    scopes TEXT[] NOT NULL,
+    created_by UUID NULL REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE TABLE oauth_codes(
+    code_hash BYTEA PRIMARY KEY NOT NULL,
+    client_id UUID NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    redirect_uri TEXT NOT NULL,
+    scopes TEXT[] NOT NULL,
+    nonce TEXT NOT NULL DEFAULT '',
+    code_challenge TEXT NOT NULL,
+    amr TEXT[] NOT NULL,
+    auth_time TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- every rotation adds a token to the family of the first one, reusing a rotated token revokes the family.
+CREATE TABLE oauth_refresh_tokens(
+    id UUID PRIMARY KEY NOT NULL,
+    token_hash BYTEA NOT NULL UNIQUE,
+    family_id UUID NOT NULL,
+    client_id UUID NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    scopes TEXT[] NOT NULL,
+    amr TEXT[] NOT NULL,
+    auth_time TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX oauth_refresh_tokens_family_idx ON oauth_refresh_tokens(family_id);
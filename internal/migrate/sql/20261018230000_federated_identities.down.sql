@@ -0,0 +1,2 @@
+DROP TABLE federated_sessions;
+DROP TABLE user_identities;
\ No newline at end of file
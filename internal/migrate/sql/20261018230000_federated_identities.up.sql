@@ -0,0 +1,20 @@
+CREATE TABLE user_identities(
+    provider VARCHAR(50) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    last_login_at TIMESTAMP WITH TIME ZONE NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (provider, subject)
+);
+
+CREATE INDEX user_identities_user_id_idx ON user_identities(user_id);
+
+CREATE TABLE federated_sessions(
+    state_hash BYTEA PRIMARY KEY NOT NULL,
+    provider VARCHAR(50) NOT NULL,
+    nonce VARCHAR(100) NOT NULL,
+    code_verifier VARCHAR(128) NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
@@ -0,0 +1,26 @@
+CREATE TABLE service_accounts(
+    id UUID PRIMARY KEY NOT NULL,
+    name VARCHAR(120) NOT NULL UNIQUE,
+    description VARCHAR(400) NOT NULL DEFAULT '',
+    roles TEXT[] NOT NULL DEFAULT '{}',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by UUID NULL REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE TABLE api_keys(
+    id UUID PRIMARY KEY NOT NULL,
+    service_account_id UUID NOT NULL REFERENCES service_accounts(id) ON DELETE CASCADE,
+    name VARCHAR(120) NOT NULL,
+    -- the prefix is part of the key, it is how a key is found without scanning every hash.
+    prefix VARCHAR(32) NOT NULL UNIQUE,
+    key_hash BYTEA NOT NULL,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    expires_at TIMESTAMP WITH TIME ZONE NULL,
+    last_used_at TIMESTAMP WITH TIME ZONE NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX api_keys_service_account_id_idx ON api_keys(service_account_id);
@@ -0,0 +1,3 @@
+DROP INDEX invitations_department_idx;
+ALTER TABLE invitations DROP CONSTRAINT invitations_department_fkey;
+ALTER TABLE users DROP CONSTRAINT users_department_fkey;
\ No newline at end of file
@@ -0,0 +1,16 @@
+-- departments used to be checked by the service only, users created without the check still need one.
+INSERT INTO departments (name,created_at,updated_at)
+    SELECT DISTINCT department, now(), now() FROM users WHERE department IS NOT NULL
+    ON CONFLICT (name) DO NOTHING;
+
+UPDATE invitations SET department = NULL
+    WHERE department IS NOT NULL AND department NOT IN (SELECT name FROM departments);
+
+-- renaming a department moves users and invitations along, deleting one with users is refused.
+ALTER TABLE users ADD CONSTRAINT users_department_fkey
+    FOREIGN KEY (department) REFERENCES departments(name) ON UPDATE CASCADE;
+
+ALTER TABLE invitations ADD CONSTRAINT invitations_department_fkey
+    FOREIGN KEY (department) REFERENCES departments(name) ON UPDATE CASCADE ON DELETE SET NULL;
+
+CREATE INDEX invitations_department_idx ON invitations(department);
@@ -0,0 +1 @@
+ALTER TABLE oauth_codes DROP COLUMN family_id;
\ No newline at end of file
@@ -0,0 +1,4 @@
+-- tokens issued for a code share its family, so reusing the code can revoke them.
+ALTER TABLE oauth_codes ADD COLUMN family_id UUID NULL;
+UPDATE oauth_codes SET family_id = gen_random_uuid();
+ALTER TABLE oauth_codes ALTER COLUMN family_id SET NOT NULL;
@@ -0,0 +1 @@
+-- revoked sessions can not be told apart from the ones revoked by users, they stay revoked.
\ No newline at end of file
@@ -0,0 +1,4 @@
+-- sessions used to be hidden once the password changed, they are revoked explicitly now.
+UPDATE sessions s SET revoked_at = u.password_changed_at
+FROM users u
+WHERE s.user_id = u.id AND s.revoked_at IS NULL AND s.created_at < u.password_changed_at;
@@ -0,0 +1 @@
+ALTER TABLE oauth_refresh_tokens DROP COLUMN session_id;
\ No newline at end of file
@@ -0,0 +1,4 @@
+-- refresh tokens live as long as the session of the login that issued them, the ones issued before
+-- they were tied to a session can not be revoked along with it and have to be granted again.
+DELETE FROM oauth_refresh_tokens;
+ALTER TABLE oauth_refresh_tokens ADD COLUMN session_id UUID NOT NULL REFERENCES sessions(id) ON DELETE CASCADE;
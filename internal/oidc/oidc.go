@@ -0,0 +1,398 @@
+// Package oidc implements the relying party side of the OpenID Connect authorization code flow, it
+// is used to log users in with an external identity provider. Only RS256 signed ID tokens are
+// accepted and PKCE S256 is always used.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var (
+	ErrInvalidIDToken = errors.New("invalid id token")
+	ErrExchange       = errors.New("code exchange failed")
+)
+
+// maxBody caps how much of a response from the provider is read.
+const maxBody = 1 << 20
+
+// jwksRefreshInterval limits how often the keys are fetched again for an unknown kid, so tokens
+// with made up kids can not make the service hammer the provider.
+const jwksRefreshInterval = time.Minute
+
+// Config holds the settings of one provider, it is usually read from JSON.
+type Config struct {
+	//Name is used in the login and callback URLs and to link identities, it must never change.
+	Name         string   `json:"name"`
+	Issuer       string   `json:"issuer"`
+	ClientID     string   `json:"clientId"`
+	ClientSecret string   `json:"clientSecret"`
+	Scopes       []string `json:"scopes"`
+
+	//Roles and Department are given to users created on their first login.
+	Roles      []string `json:"roles"`
+	Department string   `json:"department"`
+
+	//DisableProvisioning only lets users with an existing account log in.
+	DisableProvisioning bool `json:"disableProvisioning"`
+
+	//TrustMFA takes an "mfa" in the amr claim as the second factor of users who enrolled one with
+	//us, only for providers that enforce MFA themselves. Otherwise those users are challenged for
+	//their code after the provider.
+	TrustMFA bool `json:"trustMFA"`
+}
+
+// ParseConfigs reads a JSON array of provider configs.
+func ParseConfigs(s string) ([]Config, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	var cfgs []Config
+	if err := json.Unmarshal([]byte(s), &cfgs); err != nil {
+		return nil, fmt.Errorf("unmarshal: %w", err)
+	}
+
+	seen := make(map[string]bool, len(cfgs))
+	for _, cfg := range cfgs {
+		switch {
+		case cfg.Name == "" || cfg.Issuer == "" || cfg.ClientID == "":
+			return nil, errors.New("name, issuer and clientId are required")
+		case seen[cfg.Name]:
+			return nil, fmt.Errorf("provider %q is configured twice", cfg.Name)
+		case strings.ContainsAny(cfg.Name, "/?#%"):
+			return nil, fmt.Errorf("provider name %q can not be used in a URL path", cfg.Name)
+		}
+		seen[cfg.Name] = true
+	}
+
+	return cfgs, nil
+}
+
+// Claims are the claims of a verified ID token.
+type Claims struct {
+	jwt.RegisteredClaims
+	Nonce           string   `json:"nonce"`
+	AuthorizedParty string   `json:"azp,omitempty"`
+	AMR             []string `json:"amr,omitempty"`
+	Name            string   `json:"name,omitempty"`
+	Email           string   `json:"email,omitempty"`
+	EmailVerified   bool     `json:"email_verified,omitempty"`
+}
+
+// metadata is the part of the discovery document the relying party needs.
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type jwk struct {
+	KeyType  string `json:"kty"`
+	Use      string `json:"use"`
+	KeyID    string `json:"kid"`
+	Modulus  string `json:"n"`
+	Exponent string `json:"e"`
+}
+
+// Provider talks to one identity provider, the discovery document and keys are fetched on first
+// use and cached.
+type Provider struct {
+	cfg    Config
+	client *http.Client
+
+	mu          sync.Mutex
+	meta        *metadata
+	keys        map[string]*rsa.PublicKey
+	keysFetched time.Time
+}
+
+// New returns a provider, a nil client means http.DefaultClient.
+func New(cfg Config, client *http.Client) *Provider {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "profile", "email"}
+	}
+
+	if !slices.Contains(cfg.Scopes, "openid") {
+		cfg.Scopes = append([]string{"openid"}, cfg.Scopes...)
+	}
+
+	return &Provider{
+		cfg:    cfg,
+		client: client,
+	}
+}
+
+func (p *Provider) Config() Config {
+	return p.cfg
+}
+
+// AuthURL returns the address the browser is sent to for logging in at the provider.
+func (p *Provider) AuthURL(ctx context.Context, redirectURI string, state string, nonce string, verifier string) (string, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {redirectURI},
+		"scope":                 {strings.Join(p.cfg.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {Challenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+
+	sep := "?"
+	if strings.Contains(meta.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+
+	return meta.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// Exchange trades the code for tokens at the provider and returns the claims of the verified ID
+// token, nonce is the one sent along with the authorization request.
+func (p *Provider) Exchange(ctx context.Context, code string, redirectURI string, verifier string, nonce string) (Claims, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {verifier},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Claims{}, fmt.Errorf("newRequest: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return Claims{}, fmt.Errorf("do: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var tr struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxBody)).Decode(&tr); err != nil {
+		return Claims{}, fmt.Errorf("%w: status %d: decode: %w", ErrExchange, resp.StatusCode, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return Claims{}, fmt.Errorf("%w: %s: %s", ErrExchange, tr.Error, tr.ErrorDescription)
+	}
+
+	if tr.IDToken == "" {
+		return Claims{}, fmt.Errorf("%w: no id_token in the response", ErrExchange)
+	}
+
+	return p.Verify(ctx, tr.IDToken, nonce)
+}
+
+// Verify checks the signature, issuer, audience, lifetime and nonce of an ID token, see OpenID
+// Connect Core section 3.1.3.7.
+func (p *Provider) Verify(ctx context.Context, rawIDToken string, nonce string) (Claims, error) {
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}))
+
+	var claims Claims
+	_, err := parser.ParseWithClaims(rawIDToken, &claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.key(ctx, kid)
+	})
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: %w", ErrInvalidIDToken, err)
+	}
+
+	switch {
+	case claims.Issuer != p.cfg.Issuer:
+		return Claims{}, fmt.Errorf("%w: issuer %q", ErrInvalidIDToken, claims.Issuer)
+	case !claims.VerifyAudience(p.cfg.ClientID, true):
+		return Claims{}, fmt.Errorf("%w: audience %v", ErrInvalidIDToken, claims.Audience)
+	case len(claims.Audience) > 1 && claims.AuthorizedParty != p.cfg.ClientID:
+		return Claims{}, fmt.Errorf("%w: authorized party %q", ErrInvalidIDToken, claims.AuthorizedParty)
+	case claims.Subject == "":
+		return Claims{}, fmt.Errorf("%w: missing subject", ErrInvalidIDToken)
+	case claims.ExpiresAt == nil || claims.IssuedAt == nil:
+		return Claims{}, fmt.Errorf("%w: missing exp or iat", ErrInvalidIDToken)
+	case subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1:
+		return Claims{}, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+
+	return claims, nil
+}
+
+// NewSecret returns a random value for a state, nonce or PKCE verifier.
+func NewSecret() (string, error) {
+	bs := make([]byte, 32)
+	if _, err := rand.Read(bs); err != nil {
+		return "", fmt.Errorf("read: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(bs), nil
+}
+
+// Challenge returns the S256 PKCE challenge of the verifier.
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// ==============================================================================
+
+func (p *Provider) metadata(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.meta != nil {
+		return p.meta, nil
+	}
+
+	var meta metadata
+	if err := p.get(ctx, strings.TrimSuffix(p.cfg.Issuer, "/")+"/.well-known/openid-configuration", &meta); err != nil {
+		return nil, fmt.Errorf("discovery: %w", err)
+	}
+
+	//a document served for another issuer would let that issuer's tokens in.
+	if meta.Issuer != p.cfg.Issuer {
+		return nil, fmt.Errorf("discovery: issuer %q does not match %q", meta.Issuer, p.cfg.Issuer)
+	}
+
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, errors.New("discovery: endpoints missing from the document")
+	}
+
+	p.meta = &meta
+	return p.meta, nil
+}
+
+// key returns the signing key with the kid, the keys are fetched again when it is unknown since
+// providers rotate them.
+func (p *Provider) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if k, ok := p.keys[kid]; ok {
+		return k, nil
+	}
+
+	if time.Since(p.keysFetched) < jwksRefreshInterval {
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+
+	if err := p.get(ctx, meta.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.KeyType != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+
+		pub, err := k.rsa()
+		if err != nil {
+			return nil, fmt.Errorf("jwks: kid %q: %w", k.KeyID, err)
+		}
+		keys[k.KeyID] = pub
+	}
+
+	p.keys = keys
+	p.keysFetched = time.Now()
+
+	k, ok := p.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}
+
+	return k, nil
+}
+
+func (p *Provider) get(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("newRequest: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("do: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxBody)).Decode(v); err != nil {
+		return fmt.Errorf("decode: %w", err)
+	}
+
+	return nil
+}
+
+func (k jwk) rsa() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.Modulus)
+	if err != nil {
+		return nil, fmt.Errorf("modulus: %w", err)
+	}
+
+	e, err := base64.RawURLEncoding.DecodeString(k.Exponent)
+	if err != nil {
+		return nil, fmt.Errorf("exponent: %w", err)
+	}
+
+	exp := new(big.Int).SetBytes(e)
+	if len(n) < 256 || !exp.IsInt64() || exp.Int64() < 3 || exp.Int64() > 1<<31-1 {
+		return nil, errors.New("key too small or exponent out of range")
+	}
+
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}, nil
+}
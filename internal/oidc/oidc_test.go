@@ -0,0 +1,258 @@
+package oidc_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/hamidoujand/jumble/internal/oidc"
+)
+
+const (
+	clientID     = "jumble"
+	clientSecret = "s3cr3t"
+	redirectURI  = "http://localhost:8000/v1/auth/oidc/corp/callback"
+)
+
+func Test_Exchange(t *testing.T) {
+	idp := newIdP(t)
+	p := oidc.New(oidc.Config{Name: "corp", Issuer: idp.issuer, ClientID: clientID, ClientSecret: clientSecret}, idp.srv.Client())
+
+	verifier, _ := oidc.NewSecret()
+	authURL, err := p.AuthURL(context.Background(), redirectURI, "state-1", "nonce-1", verifier)
+	if err != nil {
+		t.Fatalf("authURL: %s", err)
+	}
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+
+	q := u.Query()
+	if q.Get("code_challenge") != oidc.Challenge(verifier) || q.Get("code_challenge_method") != "S256" {
+		t.Errorf("expected a S256 challenge in %s", authURL)
+	}
+
+	if q.Get("scope") != "openid profile email" || q.Get("state") != "state-1" || q.Get("nonce") != "nonce-1" {
+		t.Errorf("unexpected authorization request %s", authURL)
+	}
+
+	//the stub only hands out a token for the right code, verifier and client.
+	idp.issue("code-1", q.Get("code_challenge"), idp.claims("nonce-1"))
+
+	claims, err := p.Exchange(context.Background(), "code-1", redirectURI, verifier, "nonce-1")
+	if err != nil {
+		t.Fatalf("exchange: %s", err)
+	}
+
+	if claims.Subject != "248289761001" || claims.Email != "jane@corp.example" || !claims.EmailVerified {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+
+	idp.issue("code-2", q.Get("code_challenge"), idp.claims("nonce-1"))
+	if _, err := p.Exchange(context.Background(), "code-2", redirectURI, "wrong-verifier", "nonce-1"); !errors.Is(err, oidc.ErrExchange) {
+		t.Errorf("err=%s, got=%v", oidc.ErrExchange, err)
+	}
+}
+
+func Test_Verify(t *testing.T) {
+	idp := newIdP(t)
+	p := oidc.New(oidc.Config{Name: "corp", Issuer: idp.issuer, ClientID: clientID, ClientSecret: clientSecret}, idp.srv.Client())
+
+	tests := map[string]func(c *oidc.Claims){
+		"wrong nonce":    func(c *oidc.Claims) { c.Nonce = "other" },
+		"wrong audience": func(c *oidc.Claims) { c.Audience = jwt.ClaimStrings{"someone-else"} },
+		"wrong issuer":   func(c *oidc.Claims) { c.Issuer = "https://evil.example" },
+		"expired":        func(c *oidc.Claims) { c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute)) },
+		"no subject":     func(c *oidc.Claims) { c.Subject = "" },
+		"foreign azp": func(c *oidc.Claims) {
+			c.Audience = jwt.ClaimStrings{clientID, "other"}
+			c.AuthorizedParty = "other"
+		},
+	}
+
+	for name, mutate := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := idp.claims("nonce-1")
+			mutate(&c)
+
+			if _, err := p.Verify(context.Background(), idp.sign(t, c), "nonce-1"); !errors.Is(err, oidc.ErrInvalidIDToken) {
+				t.Errorf("err=%s, got=%v", oidc.ErrInvalidIDToken, err)
+			}
+		})
+	}
+
+	//tokens signed with a key the provider does not publish are rejected.
+	forged, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generateKey: %s", err)
+	}
+
+	tkn := jwt.NewWithClaims(jwt.SigningMethodRS256, idp.claims("nonce-1"))
+	tkn.Header["kid"] = "k1"
+	raw, err := tkn.SignedString(forged)
+	if err != nil {
+		t.Fatalf("signedString: %s", err)
+	}
+
+	if _, err := p.Verify(context.Background(), raw, "nonce-1"); !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Errorf("err=%s, got=%v", oidc.ErrInvalidIDToken, err)
+	}
+
+	if _, err := p.Verify(context.Background(), idp.sign(t, idp.claims("nonce-1")), "nonce-1"); err != nil {
+		t.Errorf("expected a valid token: %s", err)
+	}
+}
+
+func Test_ParseConfigs(t *testing.T) {
+	cfgs, err := oidc.ParseConfigs(`[{"name":"corp","issuer":"https://idp.example","clientId":"jumble","roles":["user"]}]`)
+	if err != nil {
+		t.Fatalf("parseConfigs: %s", err)
+	}
+
+	if len(cfgs) != 1 || cfgs[0].Name != "corp" || cfgs[0].Roles[0] != "user" {
+		t.Errorf("unexpected configs %+v", cfgs)
+	}
+
+	bad := []string{
+		`[{"name":"corp"}]`,
+		`[{"name":"a/b","issuer":"https://idp.example","clientId":"jumble"}]`,
+		`[{"name":"corp","issuer":"https://a.example","clientId":"x"},{"name":"corp","issuer":"https://b.example","clientId":"y"}]`,
+	}
+
+	for _, s := range bad {
+		if _, err := oidc.ParseConfigs(s); err == nil {
+			t.Errorf("expected %s to be rejected", s)
+		}
+	}
+}
+
+// ==============================================================================
+
+// idp is a stub identity provider serving discovery, keys and a token endpoint.
+type idp struct {
+	srv    *httptest.Server
+	issuer string
+	key    *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]grant
+}
+
+type grant struct {
+	challenge string
+	claims    oidc.Claims
+}
+
+func newIdP(t *testing.T) *idp {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generateKey: %s", err)
+	}
+
+	i := idp{key: key, codes: make(map[string]grant)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 i.issuer,
+			"authorization_endpoint": i.issuer + "/authorize",
+			"token_endpoint":         i.issuer + "/token",
+			"jwks_uri":               i.issuer + "/jwks",
+		})
+	})
+
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"use": "sig",
+				"kid": "k1",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+
+		i.mu.Lock()
+		g, ok := i.codes[r.FormValue("code")]
+		delete(i.codes, r.FormValue("code"))
+		i.mu.Unlock()
+
+		if id != clientID || secret != clientSecret || !ok || oidc.Challenge(r.FormValue("code_verifier")) != g.challenge ||
+			r.FormValue("redirect_uri") != redirectURI {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{
+			"access_token": "opaque",
+			"token_type":   "Bearer",
+			"id_token":     i.sign(t, g.claims),
+		})
+	})
+
+	i.srv = httptest.NewTLSServer(mux)
+	i.issuer = i.srv.URL
+	t.Cleanup(i.srv.Close)
+
+	return &i
+}
+
+// issue makes the code redeemable, like a login at the provider for a request with the challenge.
+func (i *idp) issue(code string, challenge string, c oidc.Claims) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.codes[code] = grant{challenge: challenge, claims: c}
+}
+
+func (i *idp) claims(nonce string) oidc.Claims {
+	now := time.Now()
+
+	return oidc.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    i.issuer,
+			Subject:   "248289761001",
+			Audience:  jwt.ClaimStrings{clientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+		},
+		Nonce:         nonce,
+		Name:          "Jane Doe",
+		Email:         "jane@corp.example",
+		EmailVerified: true,
+	}
+}
+
+func (i *idp) sign(t *testing.T, c oidc.Claims) string {
+	t.Helper()
+
+	tkn := jwt.NewWithClaims(jwt.SigningMethodRS256, c)
+	tkn.Header["kid"] = "k1"
+
+	raw, err := tkn.SignedString(i.key)
+	if err != nil {
+		t.Fatalf("signedString: %s", err)
+	}
+
+	return raw
+}
@@ -0,0 +1,113 @@
+package password
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// maxLineLength bounds a single "HASH:COUNT" line, 40 hex chars, a colon and the count.
+const maxLineLength = 64
+
+// BreachedFile looks up passwords in a local copy of the Pwned Passwords SHA-1 list, one
+// "HASH:COUNT" line per password sorted by hash, as produced by the official downloader.
+// Lookups binary search the file so the list never has to fit in memory, and like the range
+// api only the hash of the password is ever compared.
+type BreachedFile struct {
+	f    *os.File
+	size int64
+}
+
+// OpenBreachedFile opens the list, the file stays open for the life of the service.
+func OpenBreachedFile(path string) (*BreachedFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open: %w", err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("stat: %w", err)
+	}
+
+	return &BreachedFile{f: f, size: info.Size()}, nil
+}
+
+func (b *BreachedFile) Close() error {
+	return b.f.Close()
+}
+
+// Contains tells if the password is in the list, safe for concurrent use.
+func (b *BreachedFile) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	target := make([]byte, hex.EncodedLen(len(sum)))
+	hex.Encode(target, sum[:])
+	target = bytes.ToUpper(target)
+
+	//find the first line whose hash is not smaller than the target.
+	lo, hi := int64(0), b.size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+
+		hash, err := b.hashAt(mid)
+		if err != nil {
+			return false, err
+		}
+
+		if hash != nil && bytes.Compare(hash, target) < 0 {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+
+	hash, err := b.hashAt(lo)
+	if err != nil {
+		return false, err
+	}
+
+	return bytes.Equal(hash, target), nil
+}
+
+// hashAt returns the upper cased hash of the first line starting at or after off, nil when
+// there is none.
+func (b *BreachedFile) hashAt(off int64) ([]byte, error) {
+	start := off
+	if off > 0 {
+		//the line starts after the first newline at or after off-1.
+		buf := make([]byte, maxLineLength)
+		n, err := b.f.ReadAt(buf, off-1)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("readAt: %w", err)
+		}
+
+		i := bytes.IndexByte(buf[:n], '\n')
+		if i < 0 {
+			return nil, nil
+		}
+		start = off + int64(i)
+	}
+
+	if start >= b.size {
+		return nil, nil
+	}
+
+	buf := make([]byte, maxLineLength)
+	n, err := b.f.ReadAt(buf, start)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("readAt: %w", err)
+	}
+
+	line := buf[:n]
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+
+	hash, _, _ := bytes.Cut(line, []byte(":"))
+	return bytes.ToUpper(bytes.TrimSpace(hash)), nil
+}
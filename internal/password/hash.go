@@ -0,0 +1,208 @@
+package password
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrMismatch      = errors.New("password does not match the hash")
+	ErrUnknownHash   = errors.New("unknown hash format")
+	ErrMalformedHash = errors.New("malformed hash")
+)
+
+// Hasher hashes new passwords, stored hashes are checked with Verify whichever hasher made them.
+type Hasher interface {
+	Hash(password string) ([]byte, error)
+
+	//NeedsRehash tells if the hash was made by another algorithm or with weaker parameters.
+	NeedsRehash(hash []byte) bool
+}
+
+// Verify checks the password against a hash made by any of the supported hashers, the algorithm
+// and its parameters are read from the hash itself.
+func Verify(hash []byte, password string) error {
+	switch {
+	case bytes.HasPrefix(hash, []byte("$argon2id$")):
+		p, salt, key, err := decodeArgon2id(hash)
+		if err != nil {
+			return err
+		}
+
+		other := argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, uint32(len(key)))
+		if subtle.ConstantTimeCompare(key, other) != 1 {
+			return ErrMismatch
+		}
+
+		return nil
+
+	case isBcrypt(hash):
+		err := bcrypt.CompareHashAndPassword(hash, []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrMismatch
+		}
+
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrMalformedHash, err)
+		}
+
+		return nil
+	}
+
+	return ErrUnknownHash
+}
+
+// ==============================================================================
+
+// Argon2id hashes into PHC strings, e.g. "$argon2id$v=19$m=65536,t=3,p=4$<salt>$<key>".
+type Argon2id struct {
+	//Time is the number of passes over the memory.
+	Time uint32
+
+	//Memory is in KiB.
+	Memory  uint32
+	Threads uint8
+
+	SaltLength uint32
+	KeyLength  uint32
+}
+
+// DefaultArgon2id follows the second recommended option of RFC 9106.
+var DefaultArgon2id = Argon2id{
+	Time:       3,
+	Memory:     64 * 1024,
+	Threads:    4,
+	SaltLength: 16,
+	KeyLength:  32,
+}
+
+func (a Argon2id) Hash(password string) ([]byte, error) {
+	salt := make([]byte, a.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, a.Time, a.Memory, a.Threads, a.KeyLength)
+
+	enc := base64.RawStdEncoding
+	phc := fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, a.Memory, a.Time, a.Threads, enc.EncodeToString(salt), enc.EncodeToString(key))
+
+	return []byte(phc), nil
+}
+
+func (a Argon2id) NeedsRehash(hash []byte) bool {
+	p, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return true
+	}
+
+	return p.Time < a.Time || p.Memory < a.Memory || p.Threads < a.Threads ||
+		uint32(len(salt)) < a.SaltLength || uint32(len(key)) < a.KeyLength
+}
+
+func decodeArgon2id(hash []byte) (Argon2id, []byte, []byte, error) {
+	//"", "argon2id", "v=19", "m=65536,t=3,p=4", salt, key
+	parts := strings.Split(string(hash), "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return Argon2id{}, nil, nil, ErrMalformedHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return Argon2id{}, nil, nil, fmt.Errorf("%w: unsupported version %q", ErrMalformedHash, parts[2])
+	}
+
+	var p Argon2id
+	for param := range strings.SplitSeq(parts[3], ",") {
+		name, value, _ := strings.Cut(param, "=")
+
+		n, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return Argon2id{}, nil, nil, fmt.Errorf("%w: param %q", ErrMalformedHash, param)
+		}
+
+		switch name {
+		case "m":
+			p.Memory = uint32(n)
+		case "t":
+			p.Time = uint32(n)
+		case "p":
+			if n > 255 {
+				return Argon2id{}, nil, nil, fmt.Errorf("%w: param %q", ErrMalformedHash, param)
+			}
+			p.Threads = uint8(n)
+		default:
+			return Argon2id{}, nil, nil, fmt.Errorf("%w: param %q", ErrMalformedHash, param)
+		}
+	}
+
+	if p.Memory == 0 || p.Time == 0 || p.Threads == 0 {
+		return Argon2id{}, nil, nil, fmt.Errorf("%w: missing params", ErrMalformedHash)
+	}
+
+	enc := base64.RawStdEncoding
+
+	salt, err := enc.DecodeString(parts[4])
+	if err != nil {
+		return Argon2id{}, nil, nil, fmt.Errorf("%w: salt: %w", ErrMalformedHash, err)
+	}
+
+	key, err := enc.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return Argon2id{}, nil, nil, fmt.Errorf("%w: key", ErrMalformedHash)
+	}
+
+	return p, salt, key, nil
+}
+
+// ==============================================================================
+
+// Bcrypt is kept so hashes made before Argon2id still verify, its modular crypt format
+// "$2a$<cost>$..." lives next to the PHC strings.
+type Bcrypt struct {
+	Cost int
+}
+
+var DefaultBcrypt = Bcrypt{Cost: bcrypt.DefaultCost}
+
+func (b Bcrypt) Hash(password string) ([]byte, error) {
+	bs, err := bcrypt.GenerateFromPassword([]byte(password), b.Cost)
+	if err != nil {
+		return nil, fmt.Errorf("generateFromPassword: %w", err)
+	}
+
+	return bs, nil
+}
+
+func (b Bcrypt) NeedsRehash(hash []byte) bool {
+	if !isBcrypt(hash) {
+		return true
+	}
+
+	cost, err := bcrypt.Cost(hash)
+	if err != nil {
+		return true
+	}
+
+	return cost < b.Cost
+}
+
+func isBcrypt(hash []byte) bool {
+	for _, prefix := range []string{"$2a$", "$2b$", "$2y$"} {
+		if bytes.HasPrefix(hash, []byte(prefix)) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,200 @@
+package password_test
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hamidoujand/jumble/internal/password"
+)
+
+func Test_Validate(t *testing.T) {
+	p := password.Policy{
+		MinLength:     10,
+		MaxLength:     64,
+		RequireUpper:  true,
+		RequireLower:  true,
+		RequireDigit:  true,
+		RequireSymbol: true,
+	}
+
+	email := mail.Address{Name: "John Doe", Address: "jdoe@example.com"}
+
+	tests := []struct {
+		name     string
+		password string
+		valid    bool
+	}{
+		{name: "valid", password: "Correct-Horse-42", valid: true},
+		{name: "too_short", password: "Sh0rt-Pw"},
+		{name: "too_long", password: strings.Repeat("Aa1-", 20)},
+		{name: "no_upper", password: "correct-horse-42"},
+		{name: "no_lower", password: "CORRECT-HORSE-42"},
+		{name: "no_digit", password: "Correct-Horse-XX"},
+		{name: "no_symbol", password: "CorrectHorse42"},
+		{name: "contains_name", password: "Johnny-Horse-42"},
+		{name: "contains_email", password: "Jdoe-Horse-4242"},
+	}
+
+	for _, ts := range tests {
+		t.Run(ts.name, func(t *testing.T) {
+			err := p.Validate(ts.password, email.Name, email)
+			if ts.valid && err != nil {
+				t.Fatalf("expected password to be valid: %s", err)
+			}
+
+			if !ts.valid && !errors.Is(err, password.ErrWeakPassword) {
+				t.Fatalf("err=%s, got=%v", password.ErrWeakPassword, err)
+			}
+		})
+	}
+}
+
+func Test_Expired(t *testing.T) {
+	now := time.Now()
+
+	p := password.Policy{MaxAge: 24 * time.Hour}
+	if p.Expired(now.Add(-time.Hour), now) {
+		t.Error("expected recent password not to be expired")
+	}
+
+	if !p.Expired(now.Add(-48*time.Hour), now) {
+		t.Error("expected old password to be expired")
+	}
+
+	if (password.Policy{}).Expired(now.Add(-48*time.Hour), now) {
+		t.Error("expected no expiry without max age")
+	}
+}
+
+func Test_BreachedFile(t *testing.T) {
+	breached := []string{"password", "123456", "qwerty", "letmein", "dragon"}
+
+	//the list is sorted by hash, padded with random hashes around the real ones.
+	var lines []string
+	for _, pw := range breached {
+		lines = append(lines, sha1Hex(pw)+":42")
+	}
+	for i := range 500 {
+		lines = append(lines, sha1Hex(fmt.Sprintf("filler-%d", i))+":1")
+	}
+	slices.Sort(lines)
+
+	path := filepath.Join(t.TempDir(), "pwned.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\r\n")+"\r\n"), 0o600); err != nil {
+		t.Fatalf("writeFile: %s", err)
+	}
+
+	f, err := password.OpenBreachedFile(path)
+	if err != nil {
+		t.Fatalf("openBreachedFile: %s", err)
+	}
+	defer f.Close()
+
+	for _, pw := range append(breached, "filler-0", "filler-499") {
+		found, err := f.Contains(pw)
+		if err != nil {
+			t.Fatalf("contains: %s", err)
+		}
+
+		if !found {
+			t.Errorf("expected %q to be breached", pw)
+		}
+	}
+
+	for _, pw := range []string{"Correct-Horse-42", "filler-500", ""} {
+		found, err := f.Contains(pw)
+		if err != nil {
+			t.Fatalf("contains: %s", err)
+		}
+
+		if found {
+			t.Errorf("expected %q not to be breached", pw)
+		}
+	}
+
+	p := password.Policy{Breached: f}
+	if err := p.Validate("letmein", "", mail.Address{}); !errors.Is(err, password.ErrWeakPassword) {
+		t.Errorf("err=%s, got=%v", password.ErrWeakPassword, err)
+	}
+}
+
+func Test_Hash(t *testing.T) {
+	//cheap parameters, only the format and the upgrade rules are under test.
+	weak := password.Argon2id{Time: 1, Memory: 1024, Threads: 1, SaltLength: 16, KeyLength: 32}
+	strong := password.Argon2id{Time: 2, Memory: 2048, Threads: 1, SaltLength: 16, KeyLength: 32}
+	bc := password.Bcrypt{Cost: 4}
+
+	hashers := map[string]password.Hasher{
+		"argon2id": weak,
+		"bcrypt":   bc,
+	}
+
+	for name, h := range hashers {
+		t.Run(name, func(t *testing.T) {
+			hash, err := h.Hash("test1234")
+			if err != nil {
+				t.Fatalf("failed to hash: %s", err)
+			}
+
+			if err := password.Verify(hash, "test1234"); err != nil {
+				t.Errorf("expected the password to match: %s", err)
+			}
+
+			if err := password.Verify(hash, "test12345"); !errors.Is(err, password.ErrMismatch) {
+				t.Errorf("err=%s, got=%v", password.ErrMismatch, err)
+			}
+
+			if h.NeedsRehash(hash) {
+				t.Error("expected a fresh hash to not need a rehash")
+			}
+		})
+	}
+
+	hash, err := weak.Hash("test1234")
+	if err != nil {
+		t.Fatalf("failed to hash: %s", err)
+	}
+
+	if !strings.HasPrefix(string(hash), "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Errorf("unexpected phc string: %s", hash)
+	}
+
+	if !strong.NeedsRehash(hash) {
+		t.Error("expected weaker argon2id params to need a rehash")
+	}
+
+	legacy, err := bc.Hash("test1234")
+	if err != nil {
+		t.Fatalf("failed to hash: %s", err)
+	}
+
+	if !weak.NeedsRehash(legacy) {
+		t.Error("expected bcrypt hashes to need a rehash to argon2id")
+	}
+
+	if !(password.Bcrypt{Cost: 5}).NeedsRehash(legacy) {
+		t.Error("expected a lower bcrypt cost to need a rehash")
+	}
+
+	if err := password.Verify([]byte("plain"), "plain"); !errors.Is(err, password.ErrUnknownHash) {
+		t.Errorf("err=%s, got=%v", password.ErrUnknownHash, err)
+	}
+
+	if err := password.Verify([]byte("$argon2id$v=19$m=x$salt$key"), "test1234"); !errors.Is(err, password.ErrMalformedHash) {
+		t.Errorf("err=%s, got=%v", password.ErrMalformedHash, err)
+	}
+}
+
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
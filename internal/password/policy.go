@@ -0,0 +1,132 @@
+// Package password provides the rules new passwords have to follow.
+package password
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrWeakPassword is returned, wrapped with the list of violations, when a password does not
+// follow the policy.
+var ErrWeakPassword = errors.New("password does not meet the policy")
+
+// Breached looks up passwords in a list of known breached passwords.
+type Breached interface {
+	Contains(password string) (bool, error)
+}
+
+// Policy describes what a valid password looks like, zero values disable a rule.
+type Policy struct {
+	MinLength int
+	MaxLength int
+
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+
+	//History is how many previous passwords of a user can not be reused.
+	History int
+
+	//MaxAge forces users to rotate their password once it gets older than this.
+	MaxAge time.Duration
+
+	//Breached rejects passwords found in known breaches.
+	Breached Breached
+}
+
+// personal info shorter than this is too common to reject, e.g. "Al" in a name.
+const minPersonalLength = 3
+
+// Validate checks the password against the policy, name and email are the owner's so passwords
+// built from them can be rejected.
+func (p Policy) Validate(password string, name string, email mail.Address) error {
+	var violations []string
+
+	length := utf8.RuneCountInString(password)
+	if p.MinLength > 0 && length < p.MinLength {
+		violations = append(violations, fmt.Sprintf("must be at least %d characters", p.MinLength))
+	}
+
+	if p.MaxLength > 0 && length > p.MaxLength {
+		violations = append(violations, fmt.Sprintf("must be at most %d characters", p.MaxLength))
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+
+	if p.RequireUpper && !upper {
+		violations = append(violations, "must contain an upper case letter")
+	}
+
+	if p.RequireLower && !lower {
+		violations = append(violations, "must contain a lower case letter")
+	}
+
+	if p.RequireDigit && !digit {
+		violations = append(violations, "must contain a digit")
+	}
+
+	if p.RequireSymbol && !symbol {
+		violations = append(violations, "must contain a symbol")
+	}
+
+	if containsPersonal(password, name, email) {
+		violations = append(violations, "must not contain your name or email")
+	}
+
+	if p.Breached != nil {
+		found, err := p.Breached.Contains(password)
+		if err != nil {
+			return fmt.Errorf("breached: %w", err)
+		}
+
+		if found {
+			violations = append(violations, "appeared in a data breach, choose another one")
+		}
+	}
+
+	if len(violations) > 0 {
+		return fmt.Errorf("%w: %s", ErrWeakPassword, strings.Join(violations, ", "))
+	}
+
+	return nil
+}
+
+// Expired tells if a password changed at the given time has to be rotated.
+func (p Policy) Expired(changedAt time.Time, now time.Time) bool {
+	return p.MaxAge > 0 && now.Sub(changedAt) > p.MaxAge
+}
+
+func containsPersonal(password string, name string, email mail.Address) bool {
+	lowered := strings.ToLower(password)
+
+	parts := strings.Fields(strings.ToLower(name))
+
+	local, _, _ := strings.Cut(strings.ToLower(email.Address), "@")
+	parts = append(parts, local)
+
+	for _, part := range parts {
+		if utf8.RuneCountInString(part) >= minPersonalLength && strings.Contains(lowered, part) {
+			return true
+		}
+	}
+
+	return false
+}
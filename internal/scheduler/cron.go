@@ -0,0 +1,205 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// descriptors maps the well known cron shortcuts to their 5 field expression.
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+type bounds struct {
+	min int
+	max int
+}
+
+var (
+	minutes = bounds{min: 0, max: 59}
+	hours   = bounds{min: 0, max: 23}
+	dom     = bounds{min: 1, max: 31}
+	months  = bounds{min: 1, max: 12}
+	dow     = bounds{min: 0, max: 7} //both 0 and 7 are sunday.
+)
+
+// Schedule represents a parsed cron expression with minute precision.
+type Schedule struct {
+	expr    string
+	minute  uint64
+	hour    uint64
+	dom     uint64
+	month   uint64
+	dow     uint64
+	domStar bool
+	dowStar bool
+}
+
+// ParseCron parses a standard 5 fields cron expression "minute hour day-of-month month day-of-week"
+// or one of the descriptors like "@hourly" and "@daily".
+func ParseCron(expr string) (Schedule, error) {
+	spec := strings.TrimSpace(expr)
+	if d, ok := descriptors[spec]; ok {
+		spec = d
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return Schedule{}, fmt.Errorf("expected 5 fields, got %d: %q", len(fields), expr)
+	}
+
+	s := Schedule{expr: expr}
+
+	var err error
+	if s.minute, err = parseField(fields[0], minutes); err != nil {
+		return Schedule{}, fmt.Errorf("minute: %w", err)
+	}
+
+	if s.hour, err = parseField(fields[1], hours); err != nil {
+		return Schedule{}, fmt.Errorf("hour: %w", err)
+	}
+
+	if s.dom, err = parseField(fields[2], dom); err != nil {
+		return Schedule{}, fmt.Errorf("day of month: %w", err)
+	}
+
+	if s.month, err = parseField(fields[3], months); err != nil {
+		return Schedule{}, fmt.Errorf("month: %w", err)
+	}
+
+	if s.dow, err = parseField(fields[4], dow); err != nil {
+		return Schedule{}, fmt.Errorf("day of week: %w", err)
+	}
+
+	//fold 7 into 0 since time.Weekday only knows sunday as 0.
+	if has(s.dow, 7) {
+		s.dow |= 1
+	}
+
+	s.domStar = fields[2] == "*"
+	s.dowStar = fields[4] == "*"
+
+	return s, nil
+}
+
+// String returns the original expression.
+func (s Schedule) String() string {
+	return s.expr
+}
+
+// Next returns the first time after "t" that matches the schedule.
+func (s Schedule) Next(t time.Time) time.Time {
+	//start from the next whole minute.
+	t = t.Truncate(time.Minute).Add(time.Minute)
+
+	//leap days can be 8 years apart (2096 -> 2104), nothing valid is further than that.
+	limit := t.AddDate(8, 0, 0)
+
+	for t.Before(limit) {
+		if !has(s.month, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !has(s.hour, t.Hour()) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !has(s.minute, t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+// dayMatches follows the cron rule: when both day of month and day of week are restricted
+// a day matching either one of them is a match.
+func (s Schedule) dayMatches(t time.Time) bool {
+	d := has(s.dom, t.Day())
+	w := has(s.dow, int(t.Weekday()))
+
+	if s.domStar || s.dowStar {
+		return d && w
+	}
+
+	return d || w
+}
+
+// ==============================================================================
+
+func has(set uint64, val int) bool {
+	return set&(1<<uint(val)) != 0
+}
+
+// parseField parses a comma separated list of "*", "a", "a-b" each with an optional "/step".
+func parseField(field string, b bounds) (uint64, error) {
+	var set uint64
+
+	for part := range strings.SplitSeq(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepStr)
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step: %q", part)
+			}
+		}
+
+		var lo, hi int
+		switch {
+		case rng == "*":
+			lo, hi = b.min, b.max
+		case strings.Contains(rng, "-"):
+			from, to, _ := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(from); err != nil {
+				return 0, fmt.Errorf("invalid range: %q", part)
+			}
+
+			if hi, err = strconv.Atoi(to); err != nil {
+				return 0, fmt.Errorf("invalid range: %q", part)
+			}
+		default:
+			val, err := strconv.Atoi(rng)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value: %q", part)
+			}
+
+			lo, hi = val, val
+			//"5/10" means starting from 5 every 10.
+			if hasStep {
+				hi = b.max
+			}
+		}
+
+		if lo < b.min || hi > b.max || lo > hi {
+			return 0, fmt.Errorf("%q out of range [%d-%d]", part, b.min, b.max)
+		}
+
+		for i := lo; i <= hi; i += step {
+			set |= 1 << uint(i)
+		}
+	}
+
+	return set, nil
+}
@@ -0,0 +1,105 @@
+package scheduler_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hamidoujand/jumble/internal/scheduler"
+)
+
+func Test_ParseCron(t *testing.T) {
+	tests := []struct {
+		name      string
+		expr      string
+		expectErr bool
+	}{
+		{name: "every_minute", expr: "* * * * *"},
+		{name: "steps_and_lists", expr: "*/15 1,13 * * 1-5"},
+		{name: "descriptor", expr: "@daily"},
+		{name: "sunday_as_7", expr: "0 0 * * 7"},
+		{name: "too_few_fields", expr: "* * * *", expectErr: true},
+		{name: "out_of_range", expr: "60 * * * *", expectErr: true},
+		{name: "bad_step", expr: "*/0 * * * *", expectErr: true},
+		{name: "reversed_range", expr: "* 5-1 * * *", expectErr: true},
+		{name: "unknown_descriptor", expr: "@sometimes", expectErr: true},
+	}
+
+	for _, ts := range tests {
+		t.Run(ts.name, func(t *testing.T) {
+			_, err := scheduler.ParseCron(ts.expr)
+			if ts.expectErr && err == nil {
+				t.Fatalf("expected %q to fail", ts.expr)
+			}
+
+			if !ts.expectErr && err != nil {
+				t.Fatalf("expected %q to parse: %s", ts.expr, err)
+			}
+		})
+	}
+}
+
+func Test_Next(t *testing.T) {
+	//wednesday
+	from := time.Date(2025, time.October, 15, 10, 30, 20, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		expr     string
+		expected time.Time
+	}{
+		{
+			name:     "every_minute",
+			expr:     "* * * * *",
+			expected: time.Date(2025, time.October, 15, 10, 31, 0, 0, time.UTC),
+		},
+		{
+			name:     "hourly",
+			expr:     "@hourly",
+			expected: time.Date(2025, time.October, 15, 11, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "every_15_minutes",
+			expr:     "*/15 * * * *",
+			expected: time.Date(2025, time.October, 15, 10, 45, 0, 0, time.UTC),
+		},
+		{
+			name:     "daily_at_3",
+			expr:     "0 3 * * *",
+			expected: time.Date(2025, time.October, 16, 3, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "next_sunday",
+			expr:     "0 0 * * 7",
+			expected: time.Date(2025, time.October, 19, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "first_of_next_month",
+			expr:     "@monthly",
+			expected: time.Date(2025, time.November, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "dom_or_dow",
+			expr:     "0 0 20 * 5",
+			expected: time.Date(2025, time.October, 17, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "leap_day",
+			expr:     "0 0 29 2 *",
+			expected: time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, ts := range tests {
+		t.Run(ts.name, func(t *testing.T) {
+			s, err := scheduler.ParseCron(ts.expr)
+			if err != nil {
+				t.Fatalf("parseCron: %s", err)
+			}
+
+			got := s.Next(from)
+			if !got.Equal(ts.expected) {
+				t.Errorf("next=%s, got=%s", ts.expected, got)
+			}
+		})
+	}
+}
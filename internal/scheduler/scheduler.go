@@ -0,0 +1,331 @@
+// Package scheduler provides cron style periodic tasks that run exactly once across all replicas.
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hamidoujand/jumble/pkg/logger"
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// JobFunc is the work a job performs on every tick.
+type JobFunc func(ctx context.Context) error
+
+type job struct {
+	name     string
+	schedule Schedule
+	timeout  time.Duration
+	fn       JobFunc
+
+	mu      sync.Mutex
+	nextRun time.Time
+}
+
+// Status represents the state of a job as recorded by the leader that ran it last.
+type Status struct {
+	Name         string     `json:"name"`
+	Schedule     string     `json:"schedule"`
+	LastRunAt    *time.Time `json:"lastRunAt,omitempty"`
+	LastDuration string     `json:"lastDuration,omitempty"`
+	LastError    string     `json:"lastError,omitempty"`
+	LastRunner   string     `json:"lastRunner,omitempty"`
+	NextRunAt    time.Time  `json:"nextRunAt"`
+}
+
+// Scheduler runs registered jobs on their cron schedule, every tick is guarded by a postgres
+// advisory lock and the recorded last run so only one replica executes it.
+type Scheduler struct {
+	db     *sqlx.DB
+	log    *logger.Logger
+	tracer trace.Tracer
+	runner string
+
+	mu   sync.RWMutex
+	jobs []*job
+}
+
+// New creates a scheduler, jobs need to be added before calling Run.
+func New(db *sqlx.DB, log *logger.Logger, tracer trace.Tracer) *Scheduler {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unavailable"
+	}
+
+	return &Scheduler{
+		db:     db,
+		log:    log,
+		tracer: tracer,
+		runner: host,
+	}
+}
+
+// Add registers a job with a cron expression, timeout bounds a single execution of it.
+func (s *Scheduler) Add(name string, spec string, timeout time.Duration, fn JobFunc) error {
+	sched, err := ParseCron(spec)
+	if err != nil {
+		return fmt.Errorf("parseCron[%s]: %w", name, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, j := range s.jobs {
+		if j.name == name {
+			return fmt.Errorf("job %q already registered", name)
+		}
+	}
+
+	s.jobs = append(s.jobs, &job{
+		name:     name,
+		schedule: sched,
+		timeout:  timeout,
+		fn:       fn,
+	})
+
+	return nil
+}
+
+// Run starts all jobs and blocks until the ctx is canceled and running jobs are finished.
+func (s *Scheduler) Run(ctx context.Context) {
+	s.mu.RLock()
+	jobs := s.jobs
+	s.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, j := range jobs {
+		wg.Go(func() {
+			s.loop(ctx, j)
+		})
+	}
+
+	wg.Wait()
+}
+
+// Status returns the state of all registered jobs.
+func (s *Scheduler) Status(ctx context.Context) ([]Status, error) {
+	const q = `
+	SELECT name, last_run_at, last_duration_ms, last_error, last_runner
+	FROM scheduled_jobs
+	`
+
+	var rows []struct {
+		Name         string         `db:"name"`
+		LastRunAt    time.Time      `db:"last_run_at"`
+		LastDuration int64          `db:"last_duration_ms"`
+		LastError    sql.NullString `db:"last_error"`
+		LastRunner   string         `db:"last_runner"`
+	}
+
+	if err := s.db.SelectContext(ctx, &rows, q); err != nil {
+		return nil, fmt.Errorf("selectContext: %w", err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	statuses := make([]Status, len(s.jobs))
+	for i, j := range s.jobs {
+		j.mu.Lock()
+		statuses[i] = Status{
+			Name:      j.name,
+			Schedule:  j.schedule.String(),
+			NextRunAt: j.nextRun,
+		}
+		j.mu.Unlock()
+
+		for _, r := range rows {
+			if r.Name != j.name {
+				continue
+			}
+
+			lastRun := r.LastRunAt
+			statuses[i].LastRunAt = &lastRun
+			statuses[i].LastDuration = (time.Duration(r.LastDuration) * time.Millisecond).String()
+			statuses[i].LastError = r.LastError.String
+			statuses[i].LastRunner = r.LastRunner
+		}
+	}
+
+	return statuses, nil
+}
+
+// StatusHandler exposes the jobs status, meant to be registered on the debug mux.
+func (s *Scheduler) StatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), time.Second*5)
+		defer cancel()
+
+		statuses, err := s.Status(ctx)
+		if err != nil {
+			s.log.Error(ctx, "scheduler status", "err", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(statuses)
+	})
+}
+
+// ==============================================================================
+
+func (s *Scheduler) loop(ctx context.Context, j *job) {
+	for {
+		next := j.schedule.Next(time.Now())
+		if next.IsZero() {
+			s.log.Error(ctx, "job will never run", "job", j.name, "schedule", j.schedule.String())
+			return
+		}
+
+		j.mu.Lock()
+		j.nextRun = next
+		j.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if err := s.execute(ctx, j, next); err != nil {
+			s.log.Error(ctx, "job failed", "job", j.name, "err", err.Error())
+		}
+	}
+}
+
+// execute runs the job for the tick scheduled at "tick" if no other replica holds the lock
+// or has already run it.
+func (s *Scheduler) execute(ctx context.Context, j *job, tick time.Time) error {
+	ctx, span := s.tracer.Start(ctx, "scheduler.execute", trace.WithAttributes(attribute.String("job", j.name)))
+	defer span.End()
+
+	//session locks belong to a connection, so the lock is taken and released on the same one and
+	//no transaction stays open while the job runs.
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return fmt.Errorf("connx: %w", err)
+	}
+
+	defer conn.Close()
+
+	var locked bool
+	if err := conn.QueryRowxContext(ctx, `SELECT pg_try_advisory_lock($1)`, lockKey(j.name)).Scan(&locked); err != nil {
+		return fmt.Errorf("tryAdvisoryLock: %w", err)
+	}
+
+	if !locked {
+		s.log.Debug(ctx, "job is running on another replica", "job", j.name)
+		return nil
+	}
+
+	defer s.unlock(ctx, conn, j)
+
+	var lastRun time.Time
+	err = conn.QueryRowxContext(ctx, `SELECT last_run_at FROM scheduled_jobs WHERE name = $1`, j.name).Scan(&lastRun)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("query last run: %w", err)
+	}
+
+	//another replica already took care of this tick.
+	if !lastRun.Before(tick) {
+		s.log.Debug(ctx, "job already ran for this tick", "job", j.name, "tick", tick)
+		return nil
+	}
+
+	const q = `
+	INSERT INTO scheduled_jobs (name, last_run_at, last_duration_ms, last_error, last_runner, next_run_at)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	ON CONFLICT (name) DO UPDATE SET
+		last_run_at = EXCLUDED.last_run_at,
+		last_duration_ms = EXCLUDED.last_duration_ms,
+		last_error = EXCLUDED.last_error,
+		last_runner = EXCLUDED.last_runner,
+		next_run_at = EXCLUDED.next_run_at
+	`
+
+	//the tick is claimed before the job runs, a replica that crashes halfway does not get it run
+	//twice.
+	next := j.schedule.Next(tick)
+	if _, err := conn.ExecContext(ctx, q, j.name, tick, 0, nil, s.runner, next); err != nil {
+		return fmt.Errorf("claim run: %w", err)
+	}
+
+	s.log.Info(ctx, "job started", "job", j.name, "tick", tick)
+
+	jobCtx := ctx
+	if j.timeout > 0 {
+		var cancel context.CancelFunc
+		jobCtx, cancel = context.WithTimeout(ctx, j.timeout)
+		defer cancel()
+	}
+
+	startedAt := time.Now()
+	jobErr := j.fn(jobCtx)
+	took := time.Since(startedAt)
+
+	var lastErr sql.NullString
+	if jobErr != nil {
+		lastErr = sql.NullString{String: jobErr.Error(), Valid: true}
+		span.RecordError(jobErr)
+		span.SetStatus(codes.Error, "job failed")
+	}
+
+	//the job may have run until the ctx was canceled, the outcome is still worth recording.
+	recordCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Second*5)
+	defer cancel()
+
+	if _, err := conn.ExecContext(recordCtx, q, j.name, tick, took.Milliseconds(), lastErr, s.runner, next); err != nil {
+		return fmt.Errorf("record run: %w", err)
+	}
+
+	if jobErr != nil {
+		return fmt.Errorf("run: %w", jobErr)
+	}
+
+	s.log.Info(ctx, "job completed", "job", j.name, "took", took)
+	return nil
+}
+
+// unlock releases the lock of the job, a connection that may still hold it is discarded instead of
+// going back to the pool.
+func (s *Scheduler) unlock(ctx context.Context, conn *sqlx.Conn, j *job) {
+	unlockCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Second*5)
+	defer cancel()
+
+	var unlocked bool
+	err := conn.QueryRowxContext(unlockCtx, `SELECT pg_advisory_unlock($1)`, lockKey(j.name)).Scan(&unlocked)
+	if err == nil && unlocked {
+		return
+	}
+
+	s.log.Error(ctx, "advisory unlock failed, discarding the connection", "job", j.name, "err", err)
+
+	//returning driver.ErrBadConn from Raw makes database/sql close the connection, postgres
+	//releases session locks along with it.
+	_ = conn.Raw(func(any) error {
+		return driver.ErrBadConn
+	})
+}
+
+// lockKey maps a job name into the bigint key space of advisory locks.
+func lockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("scheduler:" + name))
+	return int64(h.Sum64())
+}
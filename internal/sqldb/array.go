@@ -0,0 +1,44 @@
+package sqldb
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+)
+
+// TextArray maps a PostgreSQL TEXT[] of names the app validates, e.g. roles or permissions, they
+// never contain commas, quotes or braces so they never need quoting.
+type TextArray []string
+
+func (ta *TextArray) Scan(val any) error {
+	var s string
+	switch v := val.(type) {
+	case nil:
+		*ta = TextArray{}
+		return nil
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	default:
+		return fmt.Errorf("unsupported type for text array: %T", v)
+	}
+
+	s = strings.Trim(s, "{}")
+	if s == "" {
+		*ta = TextArray{}
+		return nil
+	}
+
+	elements := strings.Split(s, ",")
+	for i, elem := range elements {
+		elements[i] = strings.Trim(elem, `"`)
+	}
+
+	*ta = elements
+	return nil
+}
+
+func (ta TextArray) Value() (driver.Value, error) {
+	return "{" + strings.Join(ta, ",") + "}", nil
+}
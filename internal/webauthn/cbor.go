@@ -0,0 +1,151 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// maxDepth bounds nested arrays and maps, authenticator data never goes deeper than a few levels.
+const maxDepth = 16
+
+var errCBORTruncated = errors.New("cbor: unexpected end of data")
+
+// decodeCBOR decodes the first CBOR item (RFC 8949) in data and returns the bytes after it.
+// Only the subset authenticators produce is supported: integers, byte and text strings, arrays,
+// maps and the simple values false, true and null.
+func decodeCBOR(data []byte) (any, []byte, error) {
+	return decodeItem(data, 0)
+}
+
+func decodeItem(data []byte, depth int) (any, []byte, error) {
+	if depth > maxDepth {
+		return nil, nil, errors.New("cbor: max depth exceeded")
+	}
+
+	if len(data) == 0 {
+		return nil, nil, errCBORTruncated
+	}
+
+	major := data[0] >> 5
+	info := data[0] & 0x1f
+
+	//simple values do not have a length argument.
+	if major == 7 {
+		switch info {
+		case 20:
+			return false, data[1:], nil
+		case 21:
+			return true, data[1:], nil
+		case 22:
+			return nil, data[1:], nil
+		default:
+			return nil, nil, fmt.Errorf("cbor: unsupported simple value %d", info)
+		}
+	}
+
+	arg, rest, err := decodeArgument(info, data[1:])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch major {
+	case 0:
+		if arg > 1<<63-1 {
+			return nil, nil, errors.New("cbor: integer overflow")
+		}
+		return int64(arg), rest, nil
+
+	case 1:
+		if arg > 1<<63-1 {
+			return nil, nil, errors.New("cbor: integer overflow")
+		}
+		return -1 - int64(arg), rest, nil
+
+	case 2, 3:
+		if arg > uint64(len(rest)) {
+			return nil, nil, errCBORTruncated
+		}
+
+		bs := rest[:arg]
+		if major == 3 {
+			return string(bs), rest[arg:], nil
+		}
+		return bs, rest[arg:], nil
+
+	case 4:
+		//every item takes at least one byte, this stops huge allocations from a bogus length.
+		if arg > uint64(len(rest)) {
+			return nil, nil, errCBORTruncated
+		}
+
+		items := make([]any, 0, arg)
+		for range arg {
+			var item any
+			item, rest, err = decodeItem(rest, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			items = append(items, item)
+		}
+		return items, rest, nil
+
+	case 5:
+		if arg > uint64(len(rest)) {
+			return nil, nil, errCBORTruncated
+		}
+
+		m := make(map[any]any, arg)
+		for range arg {
+			var key, val any
+			key, rest, err = decodeItem(rest, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, nil, fmt.Errorf("cbor: unsupported map key type %T", key)
+			}
+
+			val, rest, err = decodeItem(rest, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			m[key] = val
+		}
+		return m, rest, nil
+	}
+
+	return nil, nil, fmt.Errorf("cbor: unsupported major type %d", major)
+}
+
+func decodeArgument(info byte, data []byte) (uint64, []byte, error) {
+	switch {
+	case info < 24:
+		return uint64(info), data, nil
+	case info == 24:
+		if len(data) < 1 {
+			return 0, nil, errCBORTruncated
+		}
+		return uint64(data[0]), data[1:], nil
+	case info == 25:
+		if len(data) < 2 {
+			return 0, nil, errCBORTruncated
+		}
+		return uint64(binary.BigEndian.Uint16(data)), data[2:], nil
+	case info == 26:
+		if len(data) < 4 {
+			return 0, nil, errCBORTruncated
+		}
+		return uint64(binary.BigEndian.Uint32(data)), data[4:], nil
+	case info == 27:
+		if len(data) < 8 {
+			return 0, nil, errCBORTruncated
+		}
+		return binary.BigEndian.Uint64(data), data[8:], nil
+	}
+
+	return 0, nil, errors.New("cbor: indefinite lengths are not supported")
+}
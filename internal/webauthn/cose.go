@@ -0,0 +1,121 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// COSE algorithm identifiers we accept, ES256 covers almost every authenticator and RS256 is
+// what Windows Hello uses.
+const (
+	AlgES256 = -7
+	AlgRS256 = -257
+)
+
+// COSE key parameters (RFC 9053).
+const (
+	coseKty = 1
+	coseAlg = 3
+
+	coseCrv = -1
+	coseX   = -2
+	coseY   = -3
+
+	coseN = -1
+	coseE = -2
+
+	ktyEC2 = 2
+	ktyRSA = 3
+
+	crvP256 = 1
+)
+
+var ErrUnsupportedKey = errors.New("unsupported public key")
+
+// parsePublicKey decodes a COSE_Key into a public key of one of the supported algorithms.
+func parsePublicKey(raw []byte) (crypto.PublicKey, error) {
+	v, rest, err := decodeCBOR(raw)
+	if err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+
+	if len(rest) != 0 {
+		return nil, errors.New("trailing data after public key")
+	}
+
+	m, ok := v.(map[any]any)
+	if !ok {
+		return nil, ErrUnsupportedKey
+	}
+
+	kty, _ := m[int64(coseKty)].(int64)
+	alg, _ := m[int64(coseAlg)].(int64)
+
+	switch {
+	case kty == ktyEC2 && alg == AlgES256:
+		crv, _ := m[int64(coseCrv)].(int64)
+		x, _ := m[int64(coseX)].([]byte)
+		y, _ := m[int64(coseY)].([]byte)
+		if crv != crvP256 || len(x) != 32 || len(y) != 32 {
+			return nil, ErrUnsupportedKey
+		}
+
+		point := make([]byte, 0, 65)
+		point = append(point, 0x04)
+		point = append(point, x...)
+		point = append(point, y...)
+
+		pub, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), point)
+		if err != nil {
+			return nil, fmt.Errorf("parseUncompressedPublicKey: %w", err)
+		}
+		return pub, nil
+
+	case kty == ktyRSA && alg == AlgRS256:
+		n, _ := m[int64(coseN)].([]byte)
+		e, _ := m[int64(coseE)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, ErrUnsupportedKey
+		}
+
+		var exp int
+		for _, b := range e {
+			exp = exp<<8 | int(b)
+		}
+
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exp}, nil
+	}
+
+	return nil, ErrUnsupportedKey
+}
+
+// verifySignature checks sig over data with a COSE encoded public key.
+func verifySignature(rawKey []byte, data []byte, sig []byte) error {
+	pub, err := parsePublicKey(rawKey)
+	if err != nil {
+		return fmt.Errorf("parsePublicKey: %w", err)
+	}
+
+	hash := sha256.Sum256(data)
+
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(pub, hash[:], sig) {
+			return ErrInvalidSignature
+		}
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, hash[:], sig); err != nil {
+			return ErrInvalidSignature
+		}
+	default:
+		return ErrUnsupportedKey
+	}
+
+	return nil
+}
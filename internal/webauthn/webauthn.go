@@ -0,0 +1,466 @@
+// Package webauthn implements the relying party side of the WebAuthn registration and assertion
+// ceremonies needed for passkeys. Attestation statements are not verified, the service asks for
+// "none" attestation and trusts a credential because it was registered by an authenticated user.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidResponse  = errors.New("invalid webauthn response")
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrSignCount        = errors.New("sign count did not increase, authenticator may be cloned")
+)
+
+// authenticator data flags.
+const (
+	flagUserPresent      = 0x01
+	flagUserVerified     = 0x04
+	flagAttestedCredData = 0x40
+)
+
+// Config holds the relying party settings.
+type Config struct {
+	//RPID is the domain the credentials are scoped to, e.g. "example.com".
+	RPID   string
+	RPName string
+
+	//Origins lists every origin the browser may report, e.g. "https://app.example.com".
+	Origins []string
+
+	//Timeout is how long a ceremony may take.
+	Timeout time.Duration
+}
+
+type WebAuthn struct {
+	cfg    Config
+	rpHash [32]byte
+}
+
+func New(cfg Config) *WebAuthn {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 5 * time.Minute
+	}
+
+	return &WebAuthn{
+		cfg:    cfg,
+		rpHash: sha256.Sum256([]byte(cfg.RPID)),
+	}
+}
+
+// Timeout returns how long a ceremony may take.
+func (w *WebAuthn) Timeout() time.Duration {
+	return w.cfg.Timeout
+}
+
+// NewChallenge returns a fresh random challenge for a ceremony.
+func NewChallenge() ([]byte, error) {
+	bs := make([]byte, 32)
+	if _, err := rand.Read(bs); err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+
+	return bs, nil
+}
+
+// Credential is a registered public key credential.
+type Credential struct {
+	ID        []byte
+	PublicKey []byte //COSE encoded.
+	SignCount uint32
+}
+
+// User is the account a credential gets registered for.
+type User struct {
+	ID          []byte
+	Name        string
+	DisplayName string
+}
+
+// ==============================================================================
+
+type rpEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type userEntity struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type credentialParameter struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+type credentialDescriptor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+type authenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// CreationOptions is the "publicKey" argument of navigator.credentials.create().
+type CreationOptions struct {
+	Challenge              string                 `json:"challenge"`
+	RP                     rpEntity               `json:"rp"`
+	User                   userEntity             `json:"user"`
+	PubKeyCredParams       []credentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"`
+	ExcludeCredentials     []credentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection authenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// RequestOptions is the "publicKey" argument of navigator.credentials.get().
+type RequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	Timeout          int64                  `json:"timeout"`
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []credentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// CreationOptions builds the options to register a discoverable credential for the user,
+// exclude holds the ids of credentials the user already has.
+func (w *WebAuthn) CreationOptions(challenge []byte, usr User, exclude [][]byte) CreationOptions {
+	return CreationOptions{
+		Challenge: encode(challenge),
+		RP:        rpEntity{ID: w.cfg.RPID, Name: w.cfg.RPName},
+		User: userEntity{
+			ID:          encode(usr.ID),
+			Name:        usr.Name,
+			DisplayName: usr.DisplayName,
+		},
+		PubKeyCredParams: []credentialParameter{
+			{Type: "public-key", Alg: AlgES256},
+			{Type: "public-key", Alg: AlgRS256},
+		},
+		Timeout:            w.cfg.Timeout.Milliseconds(),
+		ExcludeCredentials: descriptors(exclude),
+		AuthenticatorSelection: authenticatorSelection{
+			ResidentKey:      "required",
+			UserVerification: "required",
+		},
+		Attestation: "none",
+	}
+}
+
+// RequestOptions builds the options for an assertion, an empty allow list lets the user pick any
+// passkey of this relying party.
+func (w *WebAuthn) RequestOptions(challenge []byte, allow [][]byte) RequestOptions {
+	return RequestOptions{
+		Challenge:        encode(challenge),
+		Timeout:          w.cfg.Timeout.Milliseconds(),
+		RPID:             w.cfg.RPID,
+		AllowCredentials: descriptors(allow),
+		UserVerification: "required",
+	}
+}
+
+// ==============================================================================
+
+// Response is the JSON form of a PublicKeyCredential, as returned by its toJSON() method. Binary
+// fields are base64url encoded.
+type Response struct {
+	ID       string `json:"id" binding:"required"`
+	RawID    string `json:"rawId" binding:"required"`
+	Type     string `json:"type" binding:"required"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON" binding:"required"`
+		AttestationObject string `json:"attestationObject,omitempty"`
+		AuthenticatorData string `json:"authenticatorData,omitempty"`
+		Signature         string `json:"signature,omitempty"`
+		UserHandle        string `json:"userHandle,omitempty"`
+	} `json:"response"`
+}
+
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+// Challenge returns the challenge the client signed, used to find the ceremony it belongs to.
+func (r Response) Challenge() ([]byte, error) {
+	cd, _, err := r.clientData()
+	if err != nil {
+		return nil, err
+	}
+
+	challenge, err := decode(cd.Challenge)
+	if err != nil {
+		return nil, fmt.Errorf("%w: challenge: %s", ErrInvalidResponse, err)
+	}
+
+	return challenge, nil
+}
+
+// CredentialID returns the id of the credential used.
+func (r Response) CredentialID() ([]byte, error) {
+	id, err := decode(r.RawID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: rawId: %s", ErrInvalidResponse, err)
+	}
+
+	return id, nil
+}
+
+// UserHandle returns the user id the credential was registered with, only set for assertions.
+func (r Response) UserHandle() ([]byte, error) {
+	handle, err := decode(r.Response.UserHandle)
+	if err != nil {
+		return nil, fmt.Errorf("%w: userHandle: %s", ErrInvalidResponse, err)
+	}
+
+	return handle, nil
+}
+
+// VerifyRegistration validates the response of navigator.credentials.create() against the
+// challenge of the ceremony and returns the new credential.
+func (w *WebAuthn) VerifyRegistration(r Response, challenge []byte) (Credential, error) {
+	if r.Type != "public-key" {
+		return Credential{}, fmt.Errorf("%w: type %q", ErrInvalidResponse, r.Type)
+	}
+
+	if err := w.verifyClientData(r, "webauthn.create", challenge); err != nil {
+		return Credential{}, err
+	}
+
+	rawAtt, err := decode(r.Response.AttestationObject)
+	if err != nil {
+		return Credential{}, fmt.Errorf("%w: attestationObject: %s", ErrInvalidResponse, err)
+	}
+
+	v, _, err := decodeCBOR(rawAtt)
+	if err != nil {
+		return Credential{}, fmt.Errorf("%w: attestationObject: %s", ErrInvalidResponse, err)
+	}
+
+	att, ok := v.(map[any]any)
+	if !ok {
+		return Credential{}, fmt.Errorf("%w: attestationObject is not a map", ErrInvalidResponse)
+	}
+
+	rawAuthData, ok := att["authData"].([]byte)
+	if !ok {
+		return Credential{}, fmt.Errorf("%w: authData missing", ErrInvalidResponse)
+	}
+
+	ad, err := w.parseAuthData(rawAuthData)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	if ad.flags&flagAttestedCredData == 0 {
+		return Credential{}, fmt.Errorf("%w: attested credential data missing", ErrInvalidResponse)
+	}
+
+	//make sure we can use the key before storing it.
+	if _, err := parsePublicKey(ad.publicKey); err != nil {
+		return Credential{}, fmt.Errorf("parsePublicKey: %w", err)
+	}
+
+	id, err := r.CredentialID()
+	if err != nil {
+		return Credential{}, err
+	}
+
+	if !bytes.Equal(id, ad.credentialID) {
+		return Credential{}, fmt.Errorf("%w: credential id mismatch", ErrInvalidResponse)
+	}
+
+	return Credential{
+		ID:        ad.credentialID,
+		PublicKey: ad.publicKey,
+		SignCount: ad.signCount,
+	}, nil
+}
+
+// VerifyAssertion validates the response of navigator.credentials.get() made with the given
+// credential and returns the new sign count to store.
+func (w *WebAuthn) VerifyAssertion(r Response, challenge []byte, cred Credential) (uint32, error) {
+	if r.Type != "public-key" {
+		return 0, fmt.Errorf("%w: type %q", ErrInvalidResponse, r.Type)
+	}
+
+	id, err := r.CredentialID()
+	if err != nil {
+		return 0, err
+	}
+
+	if !bytes.Equal(id, cred.ID) {
+		return 0, fmt.Errorf("%w: credential id mismatch", ErrInvalidResponse)
+	}
+
+	if err := w.verifyClientData(r, "webauthn.get", challenge); err != nil {
+		return 0, err
+	}
+
+	rawAuthData, err := decode(r.Response.AuthenticatorData)
+	if err != nil {
+		return 0, fmt.Errorf("%w: authenticatorData: %s", ErrInvalidResponse, err)
+	}
+
+	ad, err := w.parseAuthData(rawAuthData)
+	if err != nil {
+		return 0, err
+	}
+
+	sig, err := decode(r.Response.Signature)
+	if err != nil {
+		return 0, fmt.Errorf("%w: signature: %s", ErrInvalidResponse, err)
+	}
+
+	_, rawClientData, err := r.clientData()
+	if err != nil {
+		return 0, err
+	}
+
+	//the signature covers authenticatorData || sha256(clientDataJSON).
+	clientHash := sha256.Sum256(rawClientData)
+	signed := append(slices.Clone(rawAuthData), clientHash[:]...)
+
+	if err := verifySignature(cred.PublicKey, signed, sig); err != nil {
+		return 0, err
+	}
+
+	//authenticators without a counter always report 0.
+	if (ad.signCount != 0 || cred.SignCount != 0) && ad.signCount <= cred.SignCount {
+		return 0, ErrSignCount
+	}
+
+	return ad.signCount, nil
+}
+
+// ==============================================================================
+
+type authData struct {
+	flags        byte
+	signCount    uint32
+	credentialID []byte
+	publicKey    []byte
+}
+
+func (w *WebAuthn) parseAuthData(data []byte) (authData, error) {
+	//rpIdHash(32) flags(1) signCount(4)
+	if len(data) < 37 {
+		return authData{}, fmt.Errorf("%w: authenticator data too short", ErrInvalidResponse)
+	}
+
+	if subtle.ConstantTimeCompare(data[:32], w.rpHash[:]) != 1 {
+		return authData{}, fmt.Errorf("%w: rp id mismatch", ErrInvalidResponse)
+	}
+
+	ad := authData{
+		flags:     data[32],
+		signCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+
+	if ad.flags&flagUserPresent == 0 {
+		return authData{}, fmt.Errorf("%w: user not present", ErrInvalidResponse)
+	}
+
+	if ad.flags&flagUserVerified == 0 {
+		return authData{}, fmt.Errorf("%w: user not verified", ErrInvalidResponse)
+	}
+
+	if ad.flags&flagAttestedCredData == 0 {
+		return ad, nil
+	}
+
+	//aaguid(16) credentialIdLength(2) credentialId(L) credentialPublicKey
+	rest := data[37:]
+	if len(rest) < 18 {
+		return authData{}, fmt.Errorf("%w: attested credential data too short", ErrInvalidResponse)
+	}
+
+	idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if len(rest) < idLen {
+		return authData{}, fmt.Errorf("%w: credential id too short", ErrInvalidResponse)
+	}
+
+	ad.credentialID = rest[:idLen]
+	rest = rest[idLen:]
+
+	//the key is followed by optional extensions, decoding tells where it ends.
+	_, after, err := decodeCBOR(rest)
+	if err != nil {
+		return authData{}, fmt.Errorf("%w: credential public key: %s", ErrInvalidResponse, err)
+	}
+
+	ad.publicKey = rest[:len(rest)-len(after)]
+	return ad, nil
+}
+
+func (w *WebAuthn) verifyClientData(r Response, typ string, challenge []byte) error {
+	cd, _, err := r.clientData()
+	if err != nil {
+		return err
+	}
+
+	if cd.Type != typ {
+		return fmt.Errorf("%w: client data type %q", ErrInvalidResponse, cd.Type)
+	}
+
+	got, err := decode(cd.Challenge)
+	if err != nil || subtle.ConstantTimeCompare(got, challenge) != 1 {
+		return fmt.Errorf("%w: challenge mismatch", ErrInvalidResponse)
+	}
+
+	if !slices.Contains(w.cfg.Origins, cd.Origin) {
+		return fmt.Errorf("%w: origin %q not allowed", ErrInvalidResponse, cd.Origin)
+	}
+
+	return nil
+}
+
+func (r Response) clientData() (clientData, []byte, error) {
+	raw, err := decode(r.Response.ClientDataJSON)
+	if err != nil {
+		return clientData{}, nil, fmt.Errorf("%w: clientDataJSON: %s", ErrInvalidResponse, err)
+	}
+
+	var cd clientData
+	if err := json.Unmarshal(raw, &cd); err != nil {
+		return clientData{}, nil, fmt.Errorf("%w: clientDataJSON: %s", ErrInvalidResponse, err)
+	}
+
+	return cd, raw, nil
+}
+
+func descriptors(ids [][]byte) []credentialDescriptor {
+	ds := make([]credentialDescriptor, len(ids))
+	for i, id := range ids {
+		ds[i] = credentialDescriptor{Type: "public-key", ID: encode(id)}
+	}
+
+	return ds
+}
+
+func encode(bs []byte) string {
+	return base64.RawURLEncoding.EncodeToString(bs)
+}
+
+// decode accepts base64url with or without padding, browsers are not consistent about it.
+func decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
@@ -0,0 +1,236 @@
+package webauthn_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/hamidoujand/jumble/internal/webauthn"
+)
+
+const (
+	rpID   = "localhost"
+	origin = "http://localhost:8000"
+)
+
+func Test_Ceremonies(t *testing.T) {
+	w := webauthn.New(webauthn.Config{RPID: rpID, RPName: "jumble", Origins: []string{origin}})
+	a := newAuthenticator(t)
+
+	//registration.
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		t.Fatalf("newChallenge: %s", err)
+	}
+
+	userID := []byte("user-1234")
+	opts := w.CreationOptions(challenge, webauthn.User{ID: userID, Name: "john@doe.com"}, nil)
+
+	reg := a.create(t, opts.Challenge, origin)
+	got, err := reg.Challenge()
+	if err != nil {
+		t.Fatalf("challenge: %s", err)
+	}
+
+	if string(got) != string(challenge) {
+		t.Fatal("expected the challenge of the ceremony")
+	}
+
+	cred, err := w.VerifyRegistration(reg, challenge)
+	if err != nil {
+		t.Fatalf("verifyRegistration: %s", err)
+	}
+
+	if string(cred.ID) != string(a.credID) {
+		t.Fatal("expected the credential id of the authenticator")
+	}
+
+	//assertion.
+	challenge, _ = webauthn.NewChallenge()
+	reqOpts := w.RequestOptions(challenge, nil)
+
+	assertion := a.get(t, reqOpts.Challenge, origin, userID)
+	count, err := w.VerifyAssertion(assertion, challenge, cred)
+	if err != nil {
+		t.Fatalf("verifyAssertion: %s", err)
+	}
+
+	if count != a.count {
+		t.Errorf("signCount=%d, got=%d", a.count, count)
+	}
+
+	handle, err := assertion.UserHandle()
+	if err != nil || string(handle) != string(userID) {
+		t.Errorf("userHandle=%s, got=%s", userID, handle)
+	}
+
+	//replaying the same assertion does not increase the counter.
+	cred.SignCount = count
+	_, err = w.VerifyAssertion(assertion, challenge, cred)
+	if !errors.Is(err, webauthn.ErrSignCount) {
+		t.Errorf("err=%s, got=%v", webauthn.ErrSignCount, err)
+	}
+
+	//wrong challenge.
+	other, _ := webauthn.NewChallenge()
+	_, err = w.VerifyAssertion(a.get(t, reqOpts.Challenge, origin, userID), other, cred)
+	if !errors.Is(err, webauthn.ErrInvalidResponse) {
+		t.Errorf("err=%s, got=%v", webauthn.ErrInvalidResponse, err)
+	}
+
+	//wrong origin.
+	_, err = w.VerifyAssertion(a.get(t, reqOpts.Challenge, "http://evil.com", userID), challenge, cred)
+	if !errors.Is(err, webauthn.ErrInvalidResponse) {
+		t.Errorf("err=%s, got=%v", webauthn.ErrInvalidResponse, err)
+	}
+
+	//tampered signature.
+	tampered := a.get(t, reqOpts.Challenge, origin, userID)
+	sig, _ := base64.RawURLEncoding.DecodeString(tampered.Response.Signature)
+	sig[len(sig)-1] ^= 0xff
+	tampered.Response.Signature = base64.RawURLEncoding.EncodeToString(sig)
+
+	_, err = w.VerifyAssertion(tampered, challenge, cred)
+	if err == nil {
+		t.Error("expected tampered signature to fail")
+	}
+}
+
+// ==============================================================================
+
+// authenticator is a software ES256 authenticator.
+type authenticator struct {
+	key    *ecdsa.PrivateKey
+	credID []byte
+	count  uint32
+}
+
+func newAuthenticator(t *testing.T) *authenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generateKey: %s", err)
+	}
+
+	credID := make([]byte, 16)
+	rand.Read(credID)
+
+	return &authenticator{key: key, credID: credID}
+}
+
+func (a *authenticator) create(t *testing.T, challenge string, origin string) webauthn.Response {
+	point, err := a.key.PublicKey.Bytes()
+	if err != nil {
+		t.Fatalf("bytes: %s", err)
+	}
+
+	//COSE_Key: {1: 2, 3: -7, -1: 1, -2: x, -3: y}
+	var coseKey []byte
+	coseKey = append(coseKey, 0xa5)
+	coseKey = append(coseKey, 0x01, 0x02)
+	coseKey = append(coseKey, 0x03, 0x26)
+	coseKey = append(coseKey, 0x20, 0x01)
+	coseKey = append(coseKey, 0x21)
+	coseKey = append(coseKey, cborBytes(point[1:33])...)
+	coseKey = append(coseKey, 0x22)
+	coseKey = append(coseKey, cborBytes(point[33:])...)
+
+	authData := a.authData(0x45) //UP, UV, AT
+	authData = append(authData, make([]byte, 16)...)
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.credID)))
+	authData = append(authData, a.credID...)
+	authData = append(authData, coseKey...)
+
+	//{"fmt": "none", "attStmt": {}, "authData": authData}
+	var att []byte
+	att = append(att, 0xa3)
+	att = append(att, cborText("fmt")...)
+	att = append(att, cborText("none")...)
+	att = append(att, cborText("attStmt")...)
+	att = append(att, 0xa0)
+	att = append(att, cborText("authData")...)
+	att = append(att, cborBytes(authData)...)
+
+	var r webauthn.Response
+	r.ID = encode(a.credID)
+	r.RawID = encode(a.credID)
+	r.Type = "public-key"
+	r.Response.ClientDataJSON = encode(clientData(t, "webauthn.create", challenge, origin))
+	r.Response.AttestationObject = encode(att)
+
+	return r
+}
+
+func (a *authenticator) get(t *testing.T, challenge string, origin string, userHandle []byte) webauthn.Response {
+	a.count++
+
+	authData := a.authData(0x05) //UP, UV
+	cd := clientData(t, "webauthn.get", challenge, origin)
+	hash := sha256.Sum256(cd)
+
+	digest := sha256.Sum256(append(authData, hash[:]...))
+	sig, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatalf("signASN1: %s", err)
+	}
+
+	var r webauthn.Response
+	r.ID = encode(a.credID)
+	r.RawID = encode(a.credID)
+	r.Type = "public-key"
+	r.Response.ClientDataJSON = encode(cd)
+	r.Response.AuthenticatorData = encode(authData)
+	r.Response.Signature = encode(sig)
+	r.Response.UserHandle = encode(userHandle)
+
+	return r
+}
+
+func (a *authenticator) authData(flags byte) []byte {
+	rpHash := sha256.Sum256([]byte(rpID))
+
+	data := append([]byte{}, rpHash[:]...)
+	data = append(data, flags)
+	return binary.BigEndian.AppendUint32(data, a.count)
+}
+
+func clientData(t *testing.T, typ string, challenge string, origin string) []byte {
+	bs, err := json.Marshal(map[string]string{
+		"type":      typ,
+		"challenge": challenge,
+		"origin":    origin,
+	})
+	if err != nil {
+		t.Fatalf("marshal: %s", err)
+	}
+
+	return bs
+}
+
+func cborBytes(bs []byte) []byte {
+	return append(cborHead(2, len(bs)), bs...)
+}
+
+func cborText(s string) []byte {
+	return append(cborHead(3, len(s)), s...)
+}
+
+func cborHead(major byte, n int) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n < 256:
+		return []byte{major<<5 | 24, byte(n)}
+	default:
+		return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(n))
+	}
+}
+
+func encode(bs []byte) string {
+	return base64.RawURLEncoding.EncodeToString(bs)
+}
@@ -0,0 +1,86 @@
+// Package cache provides a small in-memory cache whose entries expire.
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+type entry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+// Cache keeps values for at most its ttl. It holds a bounded number of entries, when it is full
+// expired entries are dropped first and if that is not enough the cache starts over.
+type Cache[V any] struct {
+	mu         sync.Mutex
+	entries    map[string]entry[V]
+	ttl        time.Duration
+	maxEntries int
+}
+
+// New returns a cache that keeps values for ttl and holds up to maxEntries of them.
+func New[V any](ttl time.Duration, maxEntries int) *Cache[V] {
+	return &Cache[V]{
+		entries:    make(map[string]entry[V]),
+		ttl:        ttl,
+		maxEntries: maxEntries,
+	}
+}
+
+// Get returns the value of key when it is there and not expired.
+func (c *Cache[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+
+	if !time.Now().Before(e.expiresAt) {
+		delete(c.entries, key)
+		var zero V
+		return zero, false
+	}
+
+	return e.value, true
+}
+
+// Set stores the value of key until the ttl passes or until expiresAt when that comes first, a
+// zero expiresAt only uses the ttl.
+func (c *Cache[V]) Set(key string, value V, expiresAt time.Time) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+
+	exp := now.Add(c.ttl)
+	if !expiresAt.IsZero() && expiresAt.Before(exp) {
+		exp = expiresAt
+	}
+
+	if !now.Before(exp) {
+		return
+	}
+
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.maxEntries {
+		for k, e := range c.entries {
+			if !now.Before(e.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+
+		if len(c.entries) >= c.maxEntries {
+			clear(c.entries)
+		}
+	}
+
+	c.entries[key] = entry[V]{value: value, expiresAt: exp}
+}
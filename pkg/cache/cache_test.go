@@ -0,0 +1,83 @@
+package cache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hamidoujand/jumble/pkg/cache"
+)
+
+func Test_GetSet(t *testing.T) {
+	c := cache.New[int](time.Minute, 10)
+
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("expected an empty cache")
+	}
+
+	c.Set("a", 1, time.Time{})
+
+	got, ok := c.Get("a")
+	if !ok || got != 1 {
+		t.Errorf("value=%d, got=%d, ok=%t", 1, got, ok)
+	}
+}
+
+func Test_Expiry(t *testing.T) {
+	c := cache.New[int](50*time.Millisecond, 10)
+
+	c.Set("ttl", 1, time.Time{})
+	c.Set("expired", 2, time.Now().Add(-time.Second))
+	c.Set("early", 3, time.Now().Add(10*time.Millisecond))
+
+	if _, ok := c.Get("expired"); ok {
+		t.Error("expected values that already expired not to be kept")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	if _, ok := c.Get("early"); ok {
+		t.Error("expected expiresAt to win over a longer ttl")
+	}
+
+	if _, ok := c.Get("ttl"); !ok {
+		t.Error("expected value to be kept for the ttl")
+	}
+
+	time.Sleep(40 * time.Millisecond)
+
+	if _, ok := c.Get("ttl"); ok {
+		t.Error("expected value to expire after the ttl")
+	}
+}
+
+func Test_MaxEntries(t *testing.T) {
+	c := cache.New[int](time.Minute, 2)
+
+	c.Set("a", 1, time.Time{})
+	c.Set("b", 2, time.Time{})
+	c.Set("b", 3, time.Time{})
+
+	if got, ok := c.Get("a"); !ok || got != 1 {
+		t.Errorf("expected updating a key not to count as a new entry, got=%d, ok=%t", got, ok)
+	}
+
+	c.Set("c", 4, time.Time{})
+
+	if _, ok := c.Get("a"); ok {
+		t.Error("expected a full cache to start over")
+	}
+
+	if got, ok := c.Get("c"); !ok || got != 4 {
+		t.Errorf("value=%d, got=%d, ok=%t", 4, got, ok)
+	}
+}
+
+func Test_Disabled(t *testing.T) {
+	c := cache.New[int](0, 10)
+
+	c.Set("a", 1, time.Time{})
+
+	if _, ok := c.Get("a"); ok {
+		t.Error("expected a zero ttl to disable the cache")
+	}
+}
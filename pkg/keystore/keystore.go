@@ -12,6 +12,7 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 )
 
 var ErrKeyNotFound = errors.New("key not found")
@@ -21,6 +22,7 @@ const maxPEMSize = 1024 * 1024 //1MB
 type Key struct {
 	private *rsa.PrivateKey
 	public  *rsa.PublicKey
+	modTime time.Time
 }
 
 type KeyStore struct {
@@ -72,6 +74,12 @@ func (ks *KeyStore) LoadFromFileSystem(fsys fs.FS) (int, error) {
 
 		defer file.Close()
 
+		//stat the opened file, the entry of a symlinked secret describes the link.
+		info, err := file.Stat()
+		if err != nil {
+			return fmt.Errorf("stat %s: %w", path, err)
+		}
+
 		pemBytes, err := io.ReadAll(io.LimitReader(file, maxPEMSize))
 		if err != nil {
 			return fmt.Errorf("readAll: %w", err)
@@ -105,6 +113,7 @@ func (ks *KeyStore) LoadFromFileSystem(fsys fs.FS) (int, error) {
 		key := Key{
 			private: privateKey,
 			public:  &privateKey.PublicKey,
+			modTime: info.ModTime(),
 		}
 
 		ks.mu.Lock()
@@ -146,6 +155,20 @@ func (ks *KeyStore) PublicKey(kid string) (*rsa.PublicKey, error) {
 	return k.public, nil
 }
 
+// ModTime returns when the file of the key was last written, rotating a key writes a new file so
+// it tells how long the key has been in use.
+func (ks *KeyStore) ModTime(kid string) (time.Time, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	k, ok := ks.store[kid]
+	if !ok {
+		return time.Time{}, ErrKeyNotFound
+	}
+
+	return k.modTime, nil
+}
+
 func (ks *KeyStore) SetActiveKey(key string) error {
 	//check to see if the key is a valid key
 	found := func() bool {
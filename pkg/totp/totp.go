@@ -0,0 +1,129 @@
+// Package totp provides support for time-based one-time passwords (RFC 6238).
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// Period is the lifetime of a single code.
+	Period = 30 * time.Second
+
+	// Digits is the length of generated codes.
+	Digits = 6
+
+	// secretSize follows the RFC 4226 recommendation of 160 bits.
+	secretSize = 20
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret returns a random base32 encoded secret.
+func GenerateSecret() (string, error) {
+	bs := make([]byte, secretSize)
+	if _, err := rand.Read(bs); err != nil {
+		return "", fmt.Errorf("read: %w", err)
+	}
+
+	return encoding.EncodeToString(bs), nil
+}
+
+// Counter returns the time step "t" belongs to.
+func Counter(t time.Time) int64 {
+	return t.Unix() / int64(Period.Seconds())
+}
+
+// Code generates the code of the given time step.
+func Code(secret string, counter int64) (string, error) {
+	key, err := decode(secret)
+	if err != nil {
+		return "", err
+	}
+
+	return hotp(key, counter, Digits), nil
+}
+
+// Validate checks the code against the time step of "t" and "skew" steps around it to tolerate
+// clock drift, it returns the matched step so callers can reject codes that were already used.
+func Validate(secret string, code string, t time.Time, skew int) (int64, bool) {
+	key, err := decode(secret)
+	if err != nil {
+		return 0, false
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) != Digits {
+		return 0, false
+	}
+
+	current := Counter(t)
+	for i := -skew; i <= skew; i++ {
+		counter := current + int64(i)
+		expected := hotp(key, counter, Digits)
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return counter, true
+		}
+	}
+
+	return 0, false
+}
+
+// ProvisioningURI returns the "otpauth://" uri authenticator apps accept, usually shown as a QR code.
+func ProvisioningURI(issuer string, account string, secret string) string {
+	q := make(url.Values)
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(Digits))
+	q.Set("period", fmt.Sprint(int(Period.Seconds())))
+
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + account,
+		RawQuery: q.Encode(),
+	}
+
+	return u.String()
+}
+
+// ==============================================================================
+
+func decode(secret string) ([]byte, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return nil, fmt.Errorf("decode secret: %w", err)
+	}
+
+	return key, nil
+}
+
+// hotp implements RFC 4226 with HMAC-SHA1.
+func hotp(key []byte, counter int64, digits int) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	//dynamic truncation.
+	offset := sum[len(sum)-1] & 0x0f
+	bin := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for range digits {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", digits, bin%mod)
+}
@@ -0,0 +1,95 @@
+package totp_test
+
+import (
+	"encoding/base32"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/hamidoujand/jumble/pkg/totp"
+)
+
+// secret used by the RFC 6238 test vectors for SHA1.
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func Test_Code(t *testing.T) {
+	//last 6 digits of the 8 digits vectors from RFC 6238 appendix B.
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{unix: 59, code: "287082"},
+		{unix: 1111111109, code: "081804"},
+		{unix: 1111111111, code: "050471"},
+		{unix: 1234567890, code: "005924"},
+		{unix: 2000000000, code: "279037"},
+		{unix: 20000000000, code: "353130"},
+	}
+
+	for _, ts := range tests {
+		got, err := totp.Code(rfcSecret, totp.Counter(time.Unix(ts.unix, 0)))
+		if err != nil {
+			t.Fatalf("code: %s", err)
+		}
+
+		if got != ts.code {
+			t.Errorf("unix=%d: code=%s, got=%s", ts.unix, ts.code, got)
+		}
+	}
+}
+
+func Test_Validate(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatalf("generateSecret: %s", err)
+	}
+
+	now := time.Now()
+	code, err := totp.Code(secret, totp.Counter(now))
+	if err != nil {
+		t.Fatalf("code: %s", err)
+	}
+
+	counter, ok := totp.Validate(secret, code, now, 1)
+	if !ok {
+		t.Fatal("expected code to be valid")
+	}
+
+	if counter != totp.Counter(now) {
+		t.Errorf("counter=%d, got=%d", totp.Counter(now), counter)
+	}
+
+	//one step of drift is tolerated.
+	if _, ok := totp.Validate(secret, code, now.Add(totp.Period), 1); !ok {
+		t.Error("expected code to be valid with one step of drift")
+	}
+
+	if _, ok := totp.Validate(secret, code, now.Add(3*totp.Period), 1); ok {
+		t.Error("expected old code to be rejected")
+	}
+
+	if _, ok := totp.Validate(secret, "12345", now, 1); ok {
+		t.Error("expected short code to be rejected")
+	}
+}
+
+func Test_ProvisioningURI(t *testing.T) {
+	uri := totp.ProvisioningURI("jumble", "john@doe.com", "SECRET")
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+
+	if u.Scheme != "otpauth" || u.Host != "totp" {
+		t.Errorf("unexpected uri: %s", uri)
+	}
+
+	if u.Path != "/jumble:john@doe.com" {
+		t.Errorf("label=%s, got=%s", "/jumble:john@doe.com", u.Path)
+	}
+
+	if u.Query().Get("secret") != "SECRET" || u.Query().Get("issuer") != "jumble" {
+		t.Errorf("unexpected query: %s", u.RawQuery)
+	}
+}